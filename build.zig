@@ -21,6 +21,11 @@ pub fn build(b: *std.Build) void {
         "test-filter",
         "Filter for Zig unit tests",
     ) orelse &[0][]const u8{};
+    const sanitize_thread = b.option(
+        bool,
+        "sanitize-thread",
+        "Build unit tests with ThreadSanitizer to catch data races",
+    ) orelse false;
 
     const yaml_dep = b.dependency("yaml", .{
         .target = target,
@@ -60,6 +65,7 @@ pub fn build(b: *std.Build) void {
         .target = target,
         .optimize = optimize,
         .link_libc = true,
+        .sanitize_thread = sanitize_thread,
     });
     test_module.addImport("yaml", yaml_dep.module("yaml"));
     test_module.addImport("ghostty-vt", ghostty_vt);
@@ -73,6 +79,34 @@ pub fn build(b: *std.Build) void {
     const run_unit_tests = b.addRunArtifact(unit_tests);
     const test_step = b.step("test", "Run unit tests");
     test_step.dependOn(&run_unit_tests.step);
+
+    // The embeddable supervision module deliberately gets no `ghostty-vt`
+    // import: testing it on its own fails the build if supervision code ever
+    // starts depending on terminal or TUI modules.
+    const supervise_module = b.addModule("supervise", .{
+        .root_source_file = b.path("src/supervise.zig"),
+        .target = target,
+        .optimize = optimize,
+        .link_libc = true,
+    });
+    supervise_module.addImport("yaml", yaml_dep.module("yaml"));
+    const supervise_tests = b.addTest(.{
+        .root_module = supervise_module,
+        .filters = test_filters,
+    });
+    test_step.dependOn(&b.addRunArtifact(supervise_tests).step);
+
+    // Benchmarks always build optimized; debug timings are not meaningful.
+    const ring_bench = b.addExecutable(.{
+        .name = "ring-bench",
+        .root_module = b.createModule(.{
+            .root_source_file = b.path("src/ring/bench.zig"),
+            .target = target,
+            .optimize = .ReleaseFast,
+        }),
+    });
+    const bench_step = b.step("bench", "Run ring buffer benchmarks");
+    bench_step.dependOn(&b.addRunArtifact(ring_bench).step);
 }
 
 fn addVersionOptions(b: *std.Build, version: []const u8) *std.Build.Step.Options {
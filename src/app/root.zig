@@ -27,6 +27,7 @@ pub fn exitCodeForError(err: anyerror) u8 {
         error.UnknownFlag,
         error.MissingFlagValue,
         error.InvalidBool,
+        error.InvalidOverride,
         error.ClientUnifiedConflict,
         error.MultipleUnifiedOrientations,
         => 2,
@@ -43,9 +44,11 @@ pub fn shouldPrintGenericError(err: anyerror) bool {
         error.UnknownFlag,
         error.MissingFlagValue,
         error.InvalidBool,
+        error.InvalidOverride,
         error.MissingName,
         error.UnknownSignalCommand,
         error.CommandFailed,
+        error.PrimaryAlreadyRunning,
         => false,
         else => true,
     };
@@ -55,6 +58,7 @@ pub fn run(allocator: std.mem.Allocator, args: []const []const u8, output: Outpu
     var stdin = std.fs.File.stdin();
     var terminal_mode = terminal.mode.Mode.enterIfNeeded(argsNeedRawTerminal(args), stdin.handle);
     defer terminal_mode.restore();
+    if (terminal_mode.original != null) terminal.background.detect(stdin.handle, std.fs.File.stdout().handle);
     try runWithInput(allocator, args, FileInput.reader(&stdin), output);
 }
 
@@ -140,6 +144,14 @@ pub fn runInDirUntilStoppedWithInput(
             }
             return err;
         },
+        error.InvalidOverride => {
+            if (cli.invalidOverride(args)) |value| {
+                try output.writeAll("invalid override \"");
+                try output.writeAll(value);
+                try output.writeAll("\": want name=value\n");
+            }
+            return err;
+        },
         else => return err,
     };
     if (parsed.version_requested) {
@@ -147,6 +159,10 @@ pub fn runInDirUntilStoppedWithInput(
         try output.writeAll("\n");
         return;
     }
+    const overrides = try cli.overrides(allocator, parsed);
+    defer overrides.deinit(allocator);
+    const launch = launchOptions(parsed, overrides);
+
     if (std.mem.eql(u8, parsed.subcommand, "config-init")) {
         const path = try commands.config_init.runInDir(dir, parsed.args);
         try output.writeAll("Created starter configuration at ");
@@ -155,6 +171,21 @@ pub fn runInDirUntilStoppedWithInput(
         return;
     }
 
+    if (std.mem.eql(u8, parsed.subcommand, "doctor")) {
+        try commands.doctor.runInDir(
+            allocator,
+            dir,
+            parsed.config_file,
+            .{ .context = output.context, .write = output.write },
+        );
+        return;
+    }
+
+    if (parsed.dry_run) {
+        try modes.dry_run.run(allocator, dir, parsed.config_file, launch, output);
+        return;
+    }
+
     if (isSignalCommand(parsed.subcommand)) {
         try modes.signal.run(
             allocator,
@@ -167,13 +198,66 @@ pub fn runInDirUntilStoppedWithInput(
         return;
     }
 
+    if (std.mem.eql(u8, parsed.subcommand, "logs")) {
+        try modes.logs.run(allocator, dir, parsed.config_file, parsed.args, output);
+        return;
+    }
+
+    if (std.mem.eql(u8, parsed.subcommand, "env")) {
+        try modes.env.run(allocator, dir, parsed.config_file, parsed.args, output);
+        return;
+    }
+
+    if (std.mem.eql(u8, parsed.subcommand, "status")) {
+        try modes.status.run(allocator, dir, parsed.config_file, parsed.args, output);
+        return;
+    }
+
+    if (std.mem.eql(u8, parsed.subcommand, "snapshot")) {
+        try modes.snapshot.run(allocator, dir, parsed.config_file, parsed.args, output);
+        return;
+    }
+
+    if (std.mem.eql(u8, parsed.subcommand, "rpc")) {
+        try modes.rpc.run(allocator, dir, parsed.config_file, input, output);
+        return;
+    }
+
+    if (std.mem.eql(u8, parsed.subcommand, "run")) {
+        try modes.run.runUntilStopped(
+            allocator,
+            dir,
+            parsed.config_file,
+            launch,
+            parsed.args[1..],
+            output,
+            stopped,
+        );
+        return;
+    }
+
+    const starts_session = parsed.unified or parsed.mode == .client or std.mem.eql(u8, parsed.subcommand, "start");
+    var picked: ?[]const u8 = null;
+    defer if (picked) |path| allocator.free(path);
+    var picked_args: ?[]const []const u8 = null;
+    defer if (picked_args) |owned| allocator.free(owned);
+    if (starts_session and shouldPickConfig(parsed, dir, input)) {
+        picked = (try modes.config_picker.run(allocator, dir, input, output)) orelse return;
+        // A unified child primary reads its config from these flags.
+        picked_args = try std.mem.concat(allocator, []const u8, &.{ &.{ "-f", picked.? }, args });
+    }
+    const config_file = picked orelse parsed.config_file;
+    const session_args = picked_args orelse args;
+
     if (parsed.mode == .client and !parsed.unified) {
-        try modes.client.run(allocator, dir, parsed.config_file, input, output);
+        try modes.client.run(allocator, dir, config_file, input, output);
         return;
     }
 
     if (parsed.unified) {
-        try unified.runtime.run(allocator, dir, args, parsed.config_file, parsed.unified_orientation, input, output);
+        // Without an explicit orientation flag the runtime restores the saved split.
+        const orientation: cli.UnifiedSplit = if (parsed.unified_orientation_explicit) parsed.unified_orientation else .none;
+        try unified.runtime.run(allocator, dir, session_args, config_file, launch, orientation, input, output);
         return;
     }
 
@@ -181,7 +265,21 @@ pub fn runInDirUntilStoppedWithInput(
         !parsed.unified and
         std.mem.eql(u8, parsed.subcommand, "start"))
     {
-        try modes.primary.runUntilStopped(allocator, dir, parsed.config_file, input, output, stopped);
+        modes.primary.runUntilStopped(
+            allocator,
+            dir,
+            config_file,
+            launch,
+            parsed.takeover,
+            input,
+            output,
+            stopped,
+        ) catch |err| {
+            if (err == error.PrimaryAlreadyRunning) {
+                try output.writeAll("a primary server is already running for this config; pass --takeover to replace it\n");
+            }
+            return err;
+        };
         return;
     }
 
@@ -189,6 +287,26 @@ pub fn runInDirUntilStoppedWithInput(
     try output.writeAll("\n");
 }
 
+fn launchOptions(parsed: cli.Config, overrides: cli.Overrides) config.launch.Options {
+    return .{
+        .formation = parsed.formation,
+        .profile = parsed.profile,
+        .only = parsed.only,
+        .except = parsed.except,
+        .set = overrides.set,
+        .env = overrides.env,
+    };
+}
+
+/// Without `-f` or a config in `dir`, a start at a terminal offers recent and
+/// nearby configs instead of failing with `ConfigFileNotFound`.
+fn shouldPickConfig(parsed: cli.Config, dir: std.fs.Dir, input: Input) bool {
+    if (parsed.config_file.len > 0) return false;
+    const fd = input.fd orelse return false;
+    if (!std.posix.isatty(fd)) return false;
+    return !config.load.hasDefaultFile(dir);
+}
+
 fn isSignalCommand(subcommand: []const u8) bool {
     return std.mem.startsWith(u8, subcommand, "signal-");
 }
@@ -196,8 +314,16 @@ fn isSignalCommand(subcommand: []const u8) bool {
 fn argsNeedRawTerminal(args: []const []const u8) bool {
     const parsed = cli.parse(args) catch return false;
     if (parsed.version_requested) return false;
+    if (parsed.dry_run) return false;
     if (isSignalCommand(parsed.subcommand)) return false;
     if (std.mem.eql(u8, parsed.subcommand, "config-init")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "doctor")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "logs")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "run")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "status")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "env")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "snapshot")) return false;
+    if (std.mem.eql(u8, parsed.subcommand, "rpc")) return false;
     return parsed.unified or parsed.mode == .client or std.mem.eql(u8, parsed.subcommand, "start");
 }
 
@@ -355,8 +481,10 @@ test "app routes signal-list through config-derived socket" {
     var loaded = try config.runtime.loadInDir(std.testing.allocator, dir, "");
     defer loaded.deinit();
 
-    const socket_path = try ipc.socket.createPathForConfig(std.testing.allocator, &loaded.config);
+    const socket_path = try ipc.socket.pathForConfig(std.testing.allocator, &loaded.config);
     defer std.testing.allocator.free(socket_path);
+    try ipc.socket.makePrivateDir(std.fs.path.dirname(socket_path).?);
+    std.fs.deleteFileAbsolute(socket_path) catch {};
     defer std.fs.deleteFileAbsolute(socket_path) catch {};
 
     const address = try std.net.Address.initUnix(socket_path);
@@ -907,6 +1035,7 @@ test "app primary mode serves signal start list and stop commands" {
     };
     defer std.fs.deleteFileAbsolute(config_path) catch {};
     defer std.fs.deleteDirAbsolute(tmp_path) catch {};
+    defer std.fs.deleteFileAbsolute(tmp_path ++ "/work.json") catch {};
 
     var dir = try std.fs.openDirAbsolute(tmp_path, .{});
     defer dir.close();
@@ -948,6 +1077,28 @@ test "app primary mode serves signal start list and stop commands" {
     try runInDir(std.testing.allocator, dir, &.{"signal-list"}, test_io.TestOutput.writer(&out));
     try std.testing.expectEqualStrings("NAME\tSTATUS\napi\trunning\n", out.items);
 
+    out.clearRetainingCapacity();
+    try runInDir(std.testing.allocator, dir, &.{ "status", "--json" }, test_io.TestOutput.writer(&out));
+    try std.testing.expect(std.mem.startsWith(u8, out.items, "{\"processes\":[{\"label\":\"api\",\"status\":\"running\",\"pid\":"));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "\"exit_code\":null") != null);
+
+    out.clearRetainingCapacity();
+    var rpc_input = test_io.BytesInput{ .data =
+    \\{"id":1,"method":"list"}
+    \\{"id":2,"method":"switch","params":{"name":"api"}}
+    \\{"id":3,"method":"stop","params":{"name":"nope"}}
+    \\
+    };
+    try runInDirWithInput(std.testing.allocator, dir, &.{"rpc"}, rpc_input.reader(), test_io.TestOutput.writer(&out));
+    var rpc_lines = std.mem.splitScalar(u8, out.items, '\n');
+    try std.testing.expect(std.mem.startsWith(u8, rpc_lines.next().?, "{\"id\":1,\"result\":{\"processes\":[{\"label\":\"api\",\"status\":\"running\""));
+    try std.testing.expectEqualStrings("{\"id\":2,\"result\":{\"success\":true}}", rpc_lines.next().?);
+    try std.testing.expectEqualStrings("{\"id\":3,\"error\":\"process not found: nope\"}", rpc_lines.next().?);
+
+    out.clearRetainingCapacity();
+    try runInDir(std.testing.allocator, dir, &.{ "snapshot", "save", "work.json" }, test_io.TestOutput.writer(&out));
+    try std.testing.expectEqualStrings("saved 1 running processes to work.json\n", out.items);
+
     out.clearRetainingCapacity();
     try runInDir(std.testing.allocator, dir, &.{ "signal-stop", "api" }, test_io.TestOutput.writer(&out));
     try std.testing.expectEqualStrings("", out.items);
@@ -955,6 +1106,157 @@ test "app primary mode serves signal start list and stop commands" {
     try runInDir(std.testing.allocator, dir, &.{"signal-list"}, test_io.TestOutput.writer(&out));
     try std.testing.expectEqualStrings("NAME\tSTATUS\napi\tstopped\n", out.items);
 
+    out.clearRetainingCapacity();
+    try runInDir(std.testing.allocator, dir, &.{ "snapshot", "restore", "work.json" }, test_io.TestOutput.writer(&out));
+    try std.testing.expectEqualStrings("restored work.json: started 1, stopped 0\n", out.items);
+
+    out.clearRetainingCapacity();
+    try runInDir(std.testing.allocator, dir, &.{"signal-list"}, test_io.TestOutput.writer(&out));
+    try std.testing.expectEqualStrings("NAME\tSTATUS\napi\trunning\n", out.items);
+
+    stopped.store(true, .seq_cst);
+    unblockServer(socket_path);
+    thread.join();
+    if (run_state.err) |err| return err;
+}
+
+test "app run prints labeled output and fails when a process fails" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.writeFile(.{
+        .sub_path = "proctmux.yaml",
+        .data =
+        \\procs:
+        \\  build:
+        \\    shell: "echo built"
+        \\  lint:
+        \\    shell: "echo lint failed; exit 2"
+        \\
+        ,
+    });
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try runInDir(std.testing.allocator, tmp.dir, &.{ "run", "build" }, test_io.TestOutput.writer(&out));
+    try std.testing.expectEqualStrings("build | built\nbuild | exited with code 0\n", out.items);
+
+    out.clearRetainingCapacity();
+    try std.testing.expectError(
+        error.CommandFailed,
+        runInDir(std.testing.allocator, tmp.dir, &.{ "run", "build", "lint" }, test_io.TestOutput.writer(&out)),
+    );
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "build | built\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "lint  | lint failed\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "lint  | exited with code 2\n") != null);
+
+    out.clearRetainingCapacity();
+    try std.testing.expectError(
+        error.CommandFailed,
+        runInDir(std.testing.allocator, tmp.dir, &.{ "run", "nope" }, test_io.TestOutput.writer(&out)),
+    );
+    try std.testing.expectEqualStrings("unknown process: nope\n", out.items);
+    try std.testing.expectError(error.MissingName, runInDir(std.testing.allocator, tmp.dir, &.{"run"}, test_io.TestOutput.writer(&out)));
+}
+
+test "app dry run prints each autostart command, cwd, and env without starting them" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.writeFile(.{
+        .sub_path = "proctmux.yaml",
+        .data =
+        \\autostart_stagger_ms: 500
+        \\procs:
+        \\  api:
+        \\    cmd: ["node", "server.js", "--name", "it's me"]
+        \\    cwd: /srv/api
+        \\    autostart: true
+        \\    env:
+        \\      PORT: "3000"
+        \\  docs:
+        \\    shell: "touch started"
+        \\  worker:
+        \\    shell: "npm run worker"
+        \\    autostart: true
+        \\
+        ,
+    });
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try runInDir(std.testing.allocator, tmp.dir, &.{"--dry-run"}, test_io.TestOutput.writer(&out));
+
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "1. api\n   command: node server.js --name 'it'\\''s me'\n   cwd: /srv/api\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "     PORT=3000\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "     PROCTMUX_LABEL=api\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "2. worker (after 500ms)\n   command: sh -c 'npm run worker'\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "docs") == null);
+    try std.testing.expectError(error.FileNotFound, tmp.dir.access("started", .{}));
+
+    out.clearRetainingCapacity();
+    try runInDir(std.testing.allocator, tmp.dir, &.{ "--dry-run", "--only", "docs" }, test_io.TestOutput.writer(&out));
+    try std.testing.expect(std.mem.endsWith(u8, out.items, "\nno processes would autostart\n"));
+}
+
+test "app logs prints process output from the primary without color" {
+    const tmp_path = "/tmp/proctmux-zig-app-logs-test";
+    const config_path = tmp_path ++ "/proctmux.yaml";
+    std.fs.makeDirAbsolute(tmp_path) catch |err| switch (err) {
+        error.PathAlreadyExists => {},
+        else => return err,
+    };
+    defer std.fs.deleteFileAbsolute(config_path) catch {};
+    defer std.fs.deleteDirAbsolute(tmp_path) catch {};
+
+    var dir = try std.fs.openDirAbsolute(tmp_path, .{});
+    defer dir.close();
+    try dir.writeFile(.{
+        .sub_path = "proctmux.yaml",
+        .data =
+        \\procs:
+        \\  api:
+        \\    shell: "printf '\\033[31mred\\033[0m done\\n'; sleep 5"
+        \\    stop_timeout_ms: 500
+        \\
+        ,
+    });
+
+    var loaded = try config.load.loadFileInDir(std.testing.allocator, dir, "proctmux.yaml");
+    defer loaded.deinit();
+    const socket_path = try ipc.socket.pathForConfig(std.testing.allocator, &loaded.config);
+    defer std.testing.allocator.free(socket_path);
+
+    var stopped = std.atomic.Value(bool).init(false);
+    var run_state = AppPrimaryRun{
+        .dir_path = tmp_path,
+        .stopped = &stopped,
+    };
+    const thread = try std.Thread.spawn(.{}, runPrimaryApp, .{&run_state});
+    errdefer {
+        stopped.store(true, .seq_cst);
+        unblockServer(socket_path);
+        thread.join();
+    }
+    try waitForSocketFile(socket_path);
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try runInDir(std.testing.allocator, dir, &.{ "signal-start", "api" }, test_io.TestOutput.writer(&out));
+    var attempts: usize = 0;
+    while (std.mem.indexOf(u8, out.items, "done") == null and attempts < 100) : (attempts += 1) {
+        std.Thread.sleep(20 * std.time.ns_per_ms);
+        out.clearRetainingCapacity();
+        try runInDir(std.testing.allocator, dir, &.{ "logs", "--no-color", "--since", "1m", "api" }, test_io.TestOutput.writer(&out));
+    }
+    try std.testing.expectEqualStrings("red done\r\n", out.items);
+
+    try std.testing.expectError(
+        error.CommandFailed,
+        runInDir(std.testing.allocator, dir, &.{ "logs", "nope" }, test_io.TestOutput.writer(&out)),
+    );
+
     stopped.store(true, .seq_cst);
     unblockServer(socket_path);
     thread.join();
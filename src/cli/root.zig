@@ -23,9 +23,39 @@ pub const Config = struct {
     args: []const []const u8 = &.{},
     unified: bool = false,
     unified_orientation: UnifiedSplit = .none,
+    /// False when `unified_orientation` is only the `--unified` default, which
+    /// lets a saved runtime orientation take over.
+    unified_orientation_explicit: bool = false,
+    /// Replace a primary already running for the same config instead of refusing.
+    takeover: bool = false,
+    /// Print what each autostart process would run, then exit without
+    /// starting anything.
+    dry_run: bool = false,
+    /// Foreman-style `name=count` list choosing what autostarts and how many
+    /// replicas each gets; see `config.launch`.
+    formation: []const u8 = "",
+    /// Process selection flags; see `config.launch.select`.
+    profile: []const u8 = "",
+    only: []const u8 = "",
+    except: []const u8 = "",
+    /// The flags before the subcommand. `--set` and `--env` repeat, so
+    /// `overrides` collects them from here.
+    flag_args: []const []const u8 = &.{},
     version_requested: bool = false,
 };
 
+/// Repeatable `--set path=value` and `--env KEY=VALUE` flags, in order.
+/// Strings are borrowed from the parsed args.
+pub const Overrides = struct {
+    set: []const []const u8 = &.{},
+    env: []const []const u8 = &.{},
+
+    pub fn deinit(self: Overrides, allocator: std.mem.Allocator) void {
+        allocator.free(self.set);
+        allocator.free(self.env);
+    }
+};
+
 pub const deprecated_unified_toggle_message =
     \\--unified-toggle has been removed; use --unified or --unified-left/right/top/bottom with:
     \\layout:
@@ -38,10 +68,26 @@ pub const usage_text =
     \\Options:
     \\  -client
     \\        run in client mode (connects to primary)
+    \\  -dry-run
+    \\        print the command, cwd, and env of each process that would autostart, then exit
+    \\  -env KEY=VALUE
+    \\        set an environment variable in every process; repeatable
+    \\  -except string
+    \\        comma-separated processes to leave out of this run
     \\  -f string
     \\        path to config file (default: searches for proctmux.yaml in current directory)
+    \\  -formation string
+    \\        autostart only these processes with replica counts, e.g. "web=2,worker=3" (all=N sets the rest)
     \\  -mode string
     \\        mode: primary (process server) or client (UI only) (default "primary")
+    \\  -only string
+    \\        comma-separated processes to load; combines with -profile
+    \\  -profile string
+    \\        load only the processes listed under this name in the config's profiles
+    \\  -set path=value
+    \\        override a config value without editing the file, e.g. procs.api.autostart=false; repeatable
+    \\  -takeover
+    \\        stop a primary already running for this config and replace it
     \\  -unified
     \\        run in unified mode (client + server split view; shorthand for --unified-left)
     \\  -unified-bottom
@@ -75,6 +121,19 @@ pub const usage_text =
     \\  signal-restart <name>    Restart a process
     \\  signal-restart-running   Restart all running processes
     \\  signal-stop-running      Stop all running processes
+    \\  signal-scrollback <name> [lines]
+    \\                           Print recent output of a process
+    \\  status [--json]          Print process status, pid, uptime, exit code, and ports
+    \\  logs [-f] [--since <duration>] [--no-color] [--stderr] <name>
+    \\                           Print a process's output; -f keeps following it
+    \\  env <name>               Print the environment a process starts with; * marks
+    \\                           values set by env, add_path, or proctmux
+    \\  snapshot save <file>     Record which processes are running and which is selected
+    \\  snapshot restore <file>  Start and stop processes to match a saved snapshot
+    \\  rpc                      Serve JSON requests on stdin for editor integrations
+    \\  run <name...>            Start processes without the TUI and exit when they
+    \\                           finish; non-zero if any of them fails
+    \\  doctor                   Check the config, executables, sockets, and terminal
     \\
 ;
 
@@ -151,6 +210,58 @@ pub fn invalidBoolFlag(args: []const []const u8) ?BoolFlagDiagnostic {
     return null;
 }
 
+/// Returns the value of the first `--set` or `--env` flag that is not
+/// `name=value`.
+pub fn invalidOverride(args: []const []const u8) ?[]const u8 {
+    var i: usize = 0;
+    while (i < args.len) {
+        const arg = args[i];
+        if (std.mem.eql(u8, arg, "--")) return null;
+        if (arg.len <= 1 or arg[0] != '-') return null;
+
+        const parsed = parseFlagToken(arg) catch return null;
+        var value = parsed.value;
+        if (flagRequiresValue(parsed.kind) and value == null) {
+            i += 1;
+            if (i >= args.len) return null;
+            value = args[i];
+        }
+        if (parsed.kind == .set or parsed.kind == .env) {
+            if (!isAssignment(value.?)) return value.?;
+        }
+        i += 1;
+    }
+    return null;
+}
+
+/// Collects the `--set` and `--env` values of `cfg`, which `parse` has
+/// already validated.
+pub fn overrides(allocator: std.mem.Allocator, cfg: Config) !Overrides {
+    var set = std.array_list.Managed([]const u8).init(allocator);
+    defer set.deinit();
+    var env = std.array_list.Managed([]const u8).init(allocator);
+    defer env.deinit();
+
+    var i: usize = 0;
+    while (i < cfg.flag_args.len) : (i += 1) {
+        const parsed = parseFlagToken(cfg.flag_args[i]) catch continue;
+        if (!flagRequiresValue(parsed.kind)) continue;
+        const value = parsed.value orelse blk: {
+            i += 1;
+            break :blk cfg.flag_args[i];
+        };
+        switch (parsed.kind) {
+            .set => try set.append(value),
+            .env => try env.append(value),
+            else => {},
+        }
+    }
+
+    const set_values = try set.toOwnedSlice();
+    errdefer allocator.free(set_values);
+    return .{ .set = set_values, .env = try env.toOwnedSlice() };
+}
+
 pub fn parse(args: []const []const u8) !Config {
     if (deprecatedFlagMessage(args) != null) return error.DeprecatedFlag;
 
@@ -168,7 +279,7 @@ pub fn parse(args: []const []const u8) !Config {
 
         const parsed = try parseFlagToken(arg);
         const value = parsed.value orelse switch (parsed.kind) {
-            .config_file, .mode => blk: {
+            .config_file, .mode, .formation, .profile, .only, .except, .set, .env => blk: {
                 i += 1;
                 if (i >= args.len) return error.MissingFlagValue;
                 break :blk args[i];
@@ -180,6 +291,13 @@ pub fn parse(args: []const []const u8) !Config {
             .config_file => cfg.config_file = value,
             .mode => cfg.mode = parseMode(value),
             .client => client_mode = try parseBool(value),
+            .takeover => cfg.takeover = try parseBool(value),
+            .dry_run => cfg.dry_run = try parseBool(value),
+            .formation => cfg.formation = value,
+            .profile => cfg.profile = value,
+            .only => cfg.only = value,
+            .except => cfg.except = value,
+            .set, .env => if (!isAssignment(value)) return error.InvalidOverride,
             .unified => cfg.unified = try parseBool(value),
             .unified_left => try applyOrientation(&cfg, &orientation_count, .left, try parseBool(value)),
             .unified_right => try applyOrientation(&cfg, &orientation_count, .right, try parseBool(value)),
@@ -195,6 +313,7 @@ pub fn parse(args: []const []const u8) !Config {
     if (client_mode) cfg.mode = .client;
     if (cfg.unified and cfg.mode == .client) return error.ClientUnifiedConflict;
 
+    cfg.flag_args = args[0..i];
     cfg.args = args[i..];
     if (cfg.args.len > 0) cfg.subcommand = cfg.args[0];
     return cfg;
@@ -203,7 +322,15 @@ pub fn parse(args: []const []const u8) !Config {
 const FlagKind = enum {
     config_file,
     mode,
+    formation,
+    profile,
+    only,
+    except,
+    set,
+    env,
     client,
+    takeover,
+    dry_run,
     unified,
     unified_left,
     unified_right,
@@ -232,7 +359,15 @@ fn parseFlagToken(arg: []const u8) !ParsedFlag {
 
     if (std.mem.eql(u8, name, "f")) return .{ .kind = .config_file, .value = value };
     if (std.mem.eql(u8, name, "mode")) return .{ .kind = .mode, .value = value };
+    if (std.mem.eql(u8, name, "formation")) return .{ .kind = .formation, .value = value };
+    if (std.mem.eql(u8, name, "profile")) return .{ .kind = .profile, .value = value };
+    if (std.mem.eql(u8, name, "only")) return .{ .kind = .only, .value = value };
+    if (std.mem.eql(u8, name, "except")) return .{ .kind = .except, .value = value };
+    if (std.mem.eql(u8, name, "set")) return .{ .kind = .set, .value = value };
+    if (std.mem.eql(u8, name, "env")) return .{ .kind = .env, .value = value };
     if (std.mem.eql(u8, name, "client")) return .{ .kind = .client, .value = value };
+    if (std.mem.eql(u8, name, "takeover")) return .{ .kind = .takeover, .value = value };
+    if (std.mem.eql(u8, name, "dry-run")) return .{ .kind = .dry_run, .value = value };
     if (std.mem.eql(u8, name, "unified")) return .{ .kind = .unified, .value = value };
     if (std.mem.eql(u8, name, "unified-left")) return .{ .kind = .unified_left, .value = value };
     if (std.mem.eql(u8, name, "unified-right")) return .{ .kind = .unified_right, .value = value };
@@ -269,6 +404,7 @@ fn applyOrientation(cfg: *Config, count: *usize, orientation: UnifiedSplit, enab
     if (count.* > 0) return error.MultipleUnifiedOrientations;
     cfg.unified = true;
     cfg.unified_orientation = orientation;
+    cfg.unified_orientation_explicit = true;
     count.* += 1;
 }
 
@@ -285,14 +421,21 @@ fn flagName(arg: []const u8) []const u8 {
 
 fn flagRequiresValue(kind: FlagKind) bool {
     return switch (kind) {
-        .config_file, .mode => true,
+        .config_file, .mode, .formation, .profile, .only, .except, .set, .env => true,
         else => false,
     };
 }
 
+fn isAssignment(value: []const u8) bool {
+    const index = std.mem.indexOfScalar(u8, value, '=') orelse return false;
+    return index > 0;
+}
+
 fn flagRequiresBool(kind: FlagKind) bool {
     return switch (kind) {
         .client,
+        .takeover,
+        .dry_run,
         .unified,
         .unified_left,
         .unified_right,
@@ -315,6 +458,52 @@ test "default CLI config matches legacy parser" {
     try std.testing.expect(!cfg.version_requested);
 }
 
+test "takeover flag parses as a bool" {
+    try std.testing.expect((try parse(&.{"--takeover"})).takeover);
+    try std.testing.expect(!(try parse(&.{"--takeover=false"})).takeover);
+    try std.testing.expect(!(try parse(&.{})).takeover);
+}
+
+test "dry-run flag parses as a bool and keeps selection flags" {
+    const cfg = try parse(&.{ "--dry-run", "--profile", "backend" });
+    try std.testing.expect(cfg.dry_run);
+    try std.testing.expectEqualStrings("backend", cfg.profile);
+    try std.testing.expect(!(try parse(&.{"--dry-run=false"})).dry_run);
+}
+
+test "formation flag takes a value" {
+    try std.testing.expectEqualStrings("web=2,worker=3", (try parse(&.{ "--formation", "web=2,worker=3" })).formation);
+    try std.testing.expectEqualStrings("all=1", (try parse(&.{"-formation=all=1"})).formation);
+    try std.testing.expectError(error.MissingFlagValue, parse(&.{"--formation"}));
+}
+
+test "selection flags take values" {
+    const cfg = try parse(&.{ "--profile", "dev", "--only=api,db", "-except", "frontend", "--unified" });
+    try std.testing.expectEqualStrings("dev", cfg.profile);
+    try std.testing.expectEqualStrings("api,db", cfg.only);
+    try std.testing.expectEqualStrings("frontend", cfg.except);
+    try std.testing.expect(cfg.unified);
+}
+
+test "set and env flags repeat and need name=value" {
+    const cfg = try parse(&.{ "--set", "procs.api.autostart=false", "--env=DEBUG=1", "-set=log_level=debug", "--unified", "start" });
+    try std.testing.expect(cfg.unified);
+    try std.testing.expectEqualStrings("start", cfg.subcommand);
+
+    const values = try overrides(std.testing.allocator, cfg);
+    defer values.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(usize, 2), values.set.len);
+    try std.testing.expectEqualStrings("procs.api.autostart=false", values.set[0]);
+    try std.testing.expectEqualStrings("log_level=debug", values.set[1]);
+    try std.testing.expectEqual(@as(usize, 1), values.env.len);
+    try std.testing.expectEqualStrings("DEBUG=1", values.env[0]);
+
+    try std.testing.expectError(error.InvalidOverride, parse(&.{ "--set", "autostart" }));
+    try std.testing.expectError(error.InvalidOverride, parse(&.{"--env==1"}));
+    try std.testing.expectEqualStrings("=1", invalidOverride(&.{ "--set", "a=b", "--env==1" }).?);
+    try std.testing.expectError(error.MissingFlagValue, parse(&.{"--set"}));
+}
+
 test "version flag parses as a non-TUI request" {
     const cfg = try parse(&.{"--version"});
 
@@ -337,10 +526,12 @@ test "unified flags choose legacy-compatible orientation" {
     const unified = try parse(&.{"--unified"});
     try std.testing.expect(unified.unified);
     try std.testing.expectEqual(UnifiedSplit.left, unified.unified_orientation);
+    try std.testing.expect(!unified.unified_orientation_explicit);
 
     const right = try parse(&.{ "--unified-right", "-f=config.yaml" });
     try std.testing.expect(right.unified);
     try std.testing.expectEqual(UnifiedSplit.right, right.unified_orientation);
+    try std.testing.expect(right.unified_orientation_explicit);
     try std.testing.expectEqualStrings("config.yaml", right.config_file);
 
     const top = try parse(&.{"--unified-top"});
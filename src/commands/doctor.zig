@@ -0,0 +1,366 @@
+//! `doctor` CLI behavior for environment diagnosis.
+//! Each check prints one `ok`, `warn`, or `FAIL` line with a suggested fix, covering the config, process executables, the socket directory, stale sockets, and the terminal; any failure makes the command exit non-zero.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const ipc = @import("../ipc/root.zig");
+const proc = @import("../proc/root.zig");
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+const default_terminfo_dirs = [_][]const u8{
+    "/etc/terminfo",
+    "/lib/terminfo",
+    "/usr/share/terminfo",
+    "/usr/lib/terminfo",
+    "/usr/share/lib/terminfo",
+};
+
+pub const Level = enum {
+    ok,
+    warn,
+    fail,
+
+    fn tag(self: Level) []const u8 {
+        return switch (self) {
+            .ok => "ok  ",
+            .warn => "warn",
+            .fail => "FAIL",
+        };
+    }
+};
+
+/// Collects check results as they are printed so the command can fail once
+/// every check has run.
+const Report = struct {
+    output: Output,
+    failures: usize = 0,
+
+    fn check(self: *Report, level: Level, area: []const u8, comptime fmt: []const u8, args: anytype) !void {
+        if (level == .fail) self.failures += 1;
+        var buffer: [1024]u8 = undefined;
+        const text = std.fmt.bufPrint(&buffer, "{s}  {s}: " ++ fmt ++ "\n", .{ level.tag(), area } ++ args) catch return error.LineTooLong;
+        try self.output.writeAll(text);
+    }
+
+    fn fix(self: *Report, comptime fmt: []const u8, args: anytype) !void {
+        var buffer: [1024]u8 = undefined;
+        const text = std.fmt.bufPrint(&buffer, "      fix: " ++ fmt ++ "\n", args) catch return error.LineTooLong;
+        try self.output.writeAll(text);
+    }
+};
+
+/// Runs every check against the config `dir` and `config_file` select and
+/// returns `error.CommandFailed` after printing if any check failed.
+pub fn runInDir(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    output: Output,
+) !void {
+    var report = Report{ .output = output };
+    // Without a loadable config the directories still follow the environment.
+    var fallback_config = config.schema.Config.empty(allocator);
+    defer fallback_config.deinit();
+    var runtime_dir: []const u8 = undefined;
+
+    if (config.runtime.loadInDir(allocator, dir, config_file)) |loaded_value| {
+        var loaded = loaded_value;
+        defer loaded.deinit();
+        try checkConfig(&report, &loaded);
+        try checkProcesses(allocator, &report, dir, &loaded.config);
+        try checkPrimary(allocator, &report, &loaded.config);
+        runtime_dir = try config.paths.runtimeDir(allocator, &loaded.config, config.paths.Env.current());
+    } else |err| {
+        try report.check(.fail, "config", "cannot load: {s}", .{@errorName(err)});
+        switch (err) {
+            error.ConfigFileNotFound => try report.fix("run `proctmux config-init` here or pass -f <path>", .{}),
+            error.FileNotFound => try report.fix("check the -f path and any `include` entries", .{}),
+            error.DirectoryNotAbsolute => try report.fix("`runtime_dir` and `state_dir` must be absolute paths", .{}),
+            else => try report.fix("see docs/configuration.md for the expected shape of each field", .{}),
+        }
+        runtime_dir = try config.paths.runtimeDir(allocator, &fallback_config, config.paths.Env.current());
+    }
+    defer allocator.free(runtime_dir);
+
+    const socket_dir = try ipc.socket.dirInRuntimeDir(allocator, runtime_dir);
+    defer allocator.free(socket_dir);
+
+    try checkSocketDir(&report, runtime_dir);
+    try checkStaleSockets(allocator, &report, socket_dir);
+    // Older releases put sockets straight in `/tmp`.
+    try checkStaleSockets(allocator, &report, config.paths.legacy_dir);
+    try checkTerminal(allocator, &report);
+
+    if (report.failures > 0) return error.CommandFailed;
+}
+
+fn checkConfig(report: *Report, loaded: *const config.runtime.LoadedRuntimeConfig) !void {
+    try report.check(.ok, "config", "{s} ({d} processes)", .{ loaded.config.file_path, loaded.config.procs.count() });
+    for (loaded.warnings.items) |warning| {
+        try report.check(.warn, "config", "{s}: {s}", .{ warning.path, warning.message });
+    }
+}
+
+/// Resolves each process's executable through the PATH it will start with
+/// and checks that its working directory exists.
+fn checkProcesses(
+    allocator: std.mem.Allocator,
+    report: *Report,
+    dir: std.fs.Dir,
+    cfg: *const config.schema.Config,
+) !void {
+    var it = cfg.procs.iterator();
+    while (it.next()) |entry| {
+        const label = entry.key_ptr.*;
+        const proc_cfg = entry.value_ptr;
+        const command_spec = (try proc.builder.buildCommand(allocator, proc_cfg, cfg)) orelse {
+            try report.check(.fail, "process", "{s} has no `shell` or `cmd`", .{label});
+            try report.fix("give it a `shell` string or a `cmd` list", .{});
+            continue;
+        };
+        defer command_spec.deinit(allocator);
+
+        var env_map = try proc.env.buildMap(allocator, proc_cfg, .{});
+        defer env_map.deinit();
+        const executable = command_spec.argv[0];
+        if (!try findExecutable(allocator, executable, env_map.get("PATH") orelse proc.env.default_path)) {
+            try report.check(.fail, "process", "{s}: `{s}` not found on PATH", .{ label, executable });
+            try report.fix("install it, or add its directory to the process's `add_path`", .{});
+        }
+
+        if (command_spec.cwd.len > 0) {
+            dir.access(command_spec.cwd, .{}) catch {
+                try report.check(.fail, "process", "{s}: cwd {s} does not exist", .{ label, command_spec.cwd });
+                try report.fix("create the directory or correct `cwd`", .{});
+            };
+        }
+    }
+}
+
+fn checkPrimary(allocator: std.mem.Allocator, report: *Report, cfg: *const config.schema.Config) !void {
+    const path = try ipc.socket.pathForConfig(allocator, cfg);
+    defer allocator.free(path);
+
+    std.fs.accessAbsolute(path, .{}) catch {
+        return report.check(.ok, "primary", "not running for this config", .{});
+    };
+    ipc.socket.probePath(path) catch {
+        try report.check(.warn, "primary", "socket {s} has no listener", .{path});
+        return report.fix("the next `proctmux` start removes it; or delete it and {s}.lock", .{path});
+    };
+    try report.check(.ok, "primary", "running at {s}", .{path});
+}
+
+fn checkSocketDir(report: *Report, runtime_dir: []const u8) !void {
+    std.posix.access(runtime_dir, std.posix.W_OK) catch |err| switch (err) {
+        // A configured `runtime_dir` is created by the first primary.
+        error.FileNotFound => return report.check(.ok, "sockets", "{s} will be created on first start", .{runtime_dir}),
+        else => {
+            try report.check(.fail, "sockets", "{s} is not writable", .{runtime_dir});
+            return report.fix("make it writable, or point `runtime_dir` at a directory you own", .{});
+        },
+    };
+    try report.check(.ok, "sockets", "{s} is writable", .{runtime_dir});
+}
+
+/// Sockets left by primaries that crashed are harmless, since each start
+/// clears its own, but they pile up for configs that are no longer used.
+fn checkStaleSockets(allocator: std.mem.Allocator, report: *Report, dir_path: []const u8) !void {
+    const stale = try staleSockets(allocator, dir_path);
+    defer {
+        for (stale) |path| allocator.free(path);
+        allocator.free(stale);
+    }
+    if (stale.len == 0) return;
+
+    for (stale) |path| try report.check(.warn, "sockets", "stale socket {s}", .{path});
+    try report.fix("delete them with `rm {s}/proctmux-*.socket*` while no proctmux is running", .{dir_path});
+}
+
+/// Returns the `proctmux-*.socket` files in `dir_path` nobody listens on.
+pub fn staleSockets(allocator: std.mem.Allocator, dir_path: []const u8) ![][]const u8 {
+    var stale = std.array_list.Managed([]const u8).init(allocator);
+    errdefer {
+        for (stale.items) |path| allocator.free(path);
+        stale.deinit();
+    }
+
+    var dir = std.fs.openDirAbsolute(dir_path, .{ .iterate = true }) catch return stale.toOwnedSlice();
+    defer dir.close();
+    var it = dir.iterate();
+    while (try it.next()) |entry| {
+        if (!std.mem.startsWith(u8, entry.name, "proctmux-") or !std.mem.endsWith(u8, entry.name, ".socket")) continue;
+        const path = try std.fs.path.join(allocator, &.{ dir_path, entry.name });
+        ipc.socket.probePath(path) catch {
+            try stale.append(path);
+            continue;
+        };
+        allocator.free(path);
+    }
+    std.mem.sort([]const u8, stale.items, {}, lessThanString);
+    return stale.toOwnedSlice();
+}
+
+fn checkTerminal(allocator: std.mem.Allocator, report: *Report) !void {
+    const term = std.posix.getenv("TERM") orelse "";
+    if (term.len == 0 or std.mem.eql(u8, term, "dumb")) {
+        try report.check(.warn, "terminal", "TERM is {s}", .{if (term.len == 0) "not set" else "dumb"});
+        return report.fix("run proctmux in a terminal emulator, or export TERM=xterm-256color", .{});
+    }
+
+    var dirs = std.array_list.Managed([]const u8).init(allocator);
+    defer dirs.deinit();
+    var home_dir: ?[]const u8 = null;
+    defer if (home_dir) |path| allocator.free(path);
+    if (std.posix.getenv("TERMINFO")) |path| try dirs.append(path);
+    if (std.posix.getenv("HOME")) |home| {
+        home_dir = try std.fs.path.join(allocator, &.{ home, ".terminfo" });
+        try dirs.append(home_dir.?);
+    }
+    if (std.posix.getenv("TERMINFO_DIRS")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ':');
+        while (parts.next()) |path| if (path.len > 0) try dirs.append(path);
+    }
+    try dirs.appendSlice(&default_terminfo_dirs);
+
+    if (try hasTerminfo(allocator, term, dirs.items)) {
+        try report.check(.ok, "terminal", "TERM={s} has a terminfo entry", .{term});
+    } else {
+        try report.check(.warn, "terminal", "no terminfo entry for TERM={s}", .{term});
+        try report.fix("install your terminal's terminfo (often the ncurses-term package) or export TERM=xterm-256color", .{});
+    }
+}
+
+/// Looks for `term` under its first letter, or that letter in hex as macOS
+/// lays the database out.
+pub fn hasTerminfo(allocator: std.mem.Allocator, term: []const u8, dirs: []const []const u8) !bool {
+    if (term.len == 0) return false;
+    var hex_buf: [2]u8 = undefined;
+    const hex = std.fmt.bufPrint(&hex_buf, "{x:0>2}", .{term[0]}) catch unreachable;
+    for (dirs) |dir| {
+        for ([_][]const u8{ term[0..1], hex }) |bucket| {
+            const path = try std.fs.path.join(allocator, &.{ dir, bucket, term });
+            defer allocator.free(path);
+            std.fs.cwd().access(path, .{}) catch continue;
+            return true;
+        }
+    }
+    return false;
+}
+
+fn findExecutable(allocator: std.mem.Allocator, name: []const u8, path_value: []const u8) !bool {
+    if (std.mem.indexOfScalar(u8, name, '/') != null) {
+        std.fs.cwd().access(name, .{}) catch return false;
+        return true;
+    }
+    var dirs = std.mem.splitScalar(u8, path_value, ':');
+    while (dirs.next()) |dir| {
+        if (dir.len == 0) continue;
+        const candidate = try std.fs.path.join(allocator, &.{ dir, name });
+        defer allocator.free(candidate);
+        std.fs.cwd().access(candidate, .{}) catch continue;
+        return true;
+    }
+    return false;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.order(u8, a, b) == .lt;
+}
+
+test "doctor fails on a missing config and a missing executable" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try std.testing.expectError(error.CommandFailed, runInDir(std.testing.allocator, tmp.dir, "", TestOutput.writer(&out)));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "FAIL  config: cannot load: ConfigFileNotFound\n      fix: run `proctmux config-init`") != null);
+
+    try tmp.dir.writeFile(.{
+        .sub_path = "proctmux.yaml",
+        .data =
+        \\procs:
+        \\  api:
+        \\    cmd: ["proctmux-doctor-test-missing-binary"]
+        \\  ok:
+        \\    shell: "true"
+        \\
+        ,
+    });
+    out.clearRetainingCapacity();
+    try std.testing.expectError(error.CommandFailed, runInDir(std.testing.allocator, tmp.dir, "", TestOutput.writer(&out)));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "(2 processes)\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "FAIL  process: api: `proctmux-doctor-test-missing-binary` not found on PATH\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "process: ok:") == null);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "ok    primary: not running for this config\n") != null);
+}
+
+test "doctor lists sockets nobody listens on" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(dir_path);
+
+    const stale_path = try std.fs.path.join(std.testing.allocator, &.{ dir_path, "proctmux-stale.socket" });
+    defer std.testing.allocator.free(stale_path);
+    {
+        const address = try std.net.Address.initUnix(stale_path);
+        var listener = try address.listen(.{});
+        listener.deinit();
+    }
+    const live_path = try std.fs.path.join(std.testing.allocator, &.{ dir_path, "proctmux-live.socket" });
+    defer std.testing.allocator.free(live_path);
+    const live_address = try std.net.Address.initUnix(live_path);
+    var live = try live_address.listen(.{});
+    defer live.deinit();
+    try tmp.dir.writeFile(.{ .sub_path = "other.socket", .data = "" });
+
+    const stale = try staleSockets(std.testing.allocator, dir_path);
+    defer {
+        for (stale) |path| std.testing.allocator.free(path);
+        std.testing.allocator.free(stale);
+    }
+    try std.testing.expectEqual(@as(usize, 1), stale.len);
+    try std.testing.expectEqualStrings(stale_path, stale[0]);
+}
+
+test "doctor finds terminfo entries under letter and hex directories" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(dir_path);
+
+    try tmp.dir.makePath("x");
+    try tmp.dir.writeFile(.{ .sub_path = "x/xterm-test", .data = "" });
+    try tmp.dir.makePath("67");
+    try tmp.dir.writeFile(.{ .sub_path = "67/ghostty-test", .data = "" });
+
+    const dirs = [_][]const u8{dir_path};
+    try std.testing.expect(try hasTerminfo(std.testing.allocator, "xterm-test", &dirs));
+    try std.testing.expect(try hasTerminfo(std.testing.allocator, "ghostty-test", &dirs));
+    try std.testing.expect(!try hasTerminfo(std.testing.allocator, "vt-missing", &dirs));
+}
+
+const TestOutput = struct {
+    fn writer(out: *std.array_list.Managed(u8)) Output {
+        return .{
+            .context = out,
+            .write = write,
+        };
+    }
+
+    fn write(context: *anyopaque, bytes: []const u8) anyerror!void {
+        const out: *std.array_list.Managed(u8) = @ptrCast(@alignCast(context));
+        try out.appendSlice(bytes);
+    }
+};
@@ -0,0 +1,98 @@
+//! `env` CLI behavior over IPC.
+//! The command asks the running primary for the environment a process starts with, so the answer reflects the primary's own inherited environment rather than the shell running the command.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const ipc = @import("../ipc/root.zig");
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+/// Parses `env <name>`; `args[0]` is the subcommand itself.
+pub fn parse(args: []const []const u8) ![]const u8 {
+    if (args.len < 2) return error.MissingName;
+    if (args.len > 2) return error.UnexpectedArgument;
+    return args[1];
+}
+
+/// Prints every variable the process starts with, sorted by name. Variables
+/// that `env`, `add_path`, or proctmux set are marked with `*` and followed
+/// by their source and the value they replaced. A refused request, such as
+/// an unknown name, fails the command.
+pub fn runWithSocketPath(
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const label = try parse(args);
+
+    var ipc_client = try ipc.client.Client.connect(allocator, socket_path);
+    defer ipc_client.deinit();
+
+    const reply = try ipc_client.fetchEnv(label);
+    defer reply.deinit(allocator);
+    switch (reply) {
+        .data => |data| {
+            const text = try format(allocator, data.variables());
+            defer allocator.free(text);
+            try output.writeAll(text);
+        },
+        .refused => return error.CommandFailed,
+    }
+}
+
+pub fn runWithConfig(
+    allocator: std.mem.Allocator,
+    cfg: *const config.schema.Config,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const socket_path = try ipc.socket.getPathForConfig(allocator, cfg);
+    defer allocator.free(socket_path);
+
+    try runWithSocketPath(allocator, socket_path, args, output);
+}
+
+fn format(allocator: std.mem.Allocator, variables: []const ipc.protocol.EnvVariable) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    for (variables) |variable| {
+        const inherited = std.mem.eql(u8, variable.source, "inherited");
+        try out.writer().print("{s} {s}={s}", .{ if (inherited) " " else "*", variable.name, variable.value });
+        if (!inherited) {
+            try out.writer().print("  ({s}", .{variable.source});
+            if (variable.overridden) |own| try out.writer().print(", was {s}", .{own});
+            try out.append(')');
+        }
+        try out.append('\n');
+    }
+    return out.toOwnedSlice();
+}
+
+test "env parser takes exactly one name" {
+    try std.testing.expectEqualStrings("api", try parse(&.{ "env", "api" }));
+    try std.testing.expectError(error.MissingName, parse(&.{"env"}));
+    try std.testing.expectError(error.UnexpectedArgument, parse(&.{ "env", "api", "worker" }));
+}
+
+test "env output marks variables proctmux or the config set" {
+    const text = try format(std.testing.allocator, &.{
+        .{ .name = "HOME", .value = "/custom/home", .source = "config", .overridden = "/home/nick" },
+        .{ .name = "PROCTMUX_LABEL", .value = "api", .source = "metadata" },
+        .{ .name = "SHELL", .value = "/bin/zsh", .source = "inherited" },
+    });
+    defer std.testing.allocator.free(text);
+    try std.testing.expectEqualStrings(
+        "* HOME=/custom/home  (config, was /home/nick)\n" ++
+            "* PROCTMUX_LABEL=api  (metadata)\n" ++
+            "  SHELL=/bin/zsh\n",
+        text,
+    );
+}
@@ -0,0 +1,236 @@
+//! `logs` CLI behavior over IPC.
+//! Without `-f` the command prints a scrollback fetch; with it the connection becomes an output stream and bytes are copied to stdout until the primary closes it.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const ipc = @import("../ipc/root.zig");
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+pub const Options = struct {
+    label: []const u8,
+    follow: bool = false,
+    /// How far back output goes, in milliseconds before the command runs.
+    /// Null prints all recent output.
+    since_ms: ?u64 = null,
+    no_color: bool = false,
+    /// Only what the process wrote to stderr; needs `separate_stderr`.
+    stderr: bool = false,
+};
+
+/// Parses `logs [-f] [--since <duration>] [--no-color] [--stderr] <name>`;
+/// `args[0]` is the subcommand itself. Flags may come before or after the name.
+pub fn parse(args: []const []const u8) !Options {
+    var label: ?[]const u8 = null;
+    var options = Options{ .label = "" };
+    var i: usize = 1;
+    while (i < args.len) : (i += 1) {
+        const arg = args[i];
+        if (std.mem.eql(u8, arg, "-f") or std.mem.eql(u8, arg, "--follow")) {
+            options.follow = true;
+        } else if (std.mem.eql(u8, arg, "--no-color")) {
+            options.no_color = true;
+        } else if (std.mem.eql(u8, arg, "--stderr")) {
+            options.stderr = true;
+        } else if (std.mem.eql(u8, arg, "--since")) {
+            i += 1;
+            if (i >= args.len) return error.MissingDuration;
+            options.since_ms = try parseDuration(args[i]);
+        } else if (std.mem.startsWith(u8, arg, "--since=")) {
+            options.since_ms = try parseDuration(arg["--since=".len..]);
+        } else if (arg.len > 1 and arg[0] == '-') {
+            return error.UnknownLogsFlag;
+        } else {
+            if (label != null) return error.UnexpectedArgument;
+            label = arg;
+        }
+    }
+    options.label = label orelse return error.MissingName;
+    return options;
+}
+
+/// Parses durations such as `500ms`, `30s`, `5m`, or `2h` into milliseconds.
+pub fn parseDuration(text: []const u8) !u64 {
+    const units = [_]struct { suffix: []const u8, ms: u64 }{
+        .{ .suffix = "ms", .ms = 1 },
+        .{ .suffix = "s", .ms = std.time.ms_per_s },
+        .{ .suffix = "m", .ms = std.time.ms_per_min },
+        .{ .suffix = "h", .ms = std.time.ms_per_hour },
+    };
+    for (units) |unit| {
+        if (!std.mem.endsWith(u8, text, unit.suffix)) continue;
+        const count = std.fmt.parseInt(u64, text[0 .. text.len - unit.suffix.len], 10) catch return error.InvalidDuration;
+        return std.math.mul(u64, count, unit.ms) catch error.InvalidDuration;
+    }
+    return error.InvalidDuration;
+}
+
+/// Prints or follows one process's output from an already-running Primary
+/// Server. A refused request, such as an unknown name, fails the command.
+pub fn runWithSocketPath(
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const options = try parse(args);
+    const since_ms: ?i64 = if (options.since_ms) |ago|
+        std.time.milliTimestamp() -| (std.math.cast(i64, ago) orelse std.math.maxInt(i64))
+    else
+        null;
+    var sink = Sink{ .output = output, .no_color = options.no_color };
+
+    var ipc_client = try ipc.client.Client.connect(allocator, socket_path);
+    defer ipc_client.deinit();
+
+    if (!options.follow) {
+        const reply = try ipc_client.fetchScrollback(options.label, .{
+            .bytes = ipc.protocol.max_scrollback_bytes,
+            .since_ms = since_ms,
+            .stderr = options.stderr,
+        });
+        defer reply.deinit(allocator);
+        switch (reply) {
+            .data => |data| try sink.writeAll(data.data),
+            .refused => return error.CommandFailed,
+        }
+        return;
+    }
+
+    var response = try ipc_client.requestOutputStream(options.label, .{ .since_ms = since_ms, .stderr = options.stderr });
+    defer response.deinit(allocator);
+    if (!response.success) return error.CommandFailed;
+
+    while (true) {
+        const output_frame = ipc_client.readOutputFrame() catch |err| switch (err) {
+            // The primary closes streams when it shuts down.
+            error.EndOfStream, error.ConnectionResetByPeer => return,
+            else => return err,
+        };
+        defer output_frame.deinit(allocator);
+        try sink.writeAll(output_frame.payload);
+    }
+}
+
+pub fn runWithConfig(
+    allocator: std.mem.Allocator,
+    cfg: *const config.schema.Config,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const socket_path = try ipc.socket.getPathForConfig(allocator, cfg);
+    defer allocator.free(socket_path);
+
+    try runWithSocketPath(allocator, socket_path, args, output);
+}
+
+/// Writes process output, optionally without terminal escape sequences. The
+/// stripper keeps its state between writes because a sequence can be split
+/// across stream frames.
+const Sink = struct {
+    output: Output,
+    no_color: bool,
+    escape: EscapeState = .none,
+
+    const EscapeState = enum {
+        none,
+        /// Saw ESC and waits for the byte that picks the sequence kind.
+        escape,
+        /// Inside `ESC [`, which ends at a byte in 0x40..0x7e.
+        csi,
+        /// Inside `ESC ]`, which ends at BEL or `ESC \`.
+        osc,
+        osc_escape,
+    };
+
+    fn writeAll(self: *Sink, bytes: []const u8) !void {
+        if (!self.no_color) return self.output.writeAll(bytes);
+
+        var start: usize = 0;
+        for (bytes, 0..) |byte, index| {
+            const was_plain = self.escape == .none;
+            self.escape = switch (self.escape) {
+                .none => if (byte == 0x1b) .escape else .none,
+                .escape => switch (byte) {
+                    '[' => .csi,
+                    ']' => .osc,
+                    else => .none,
+                },
+                .csi => if (byte >= 0x40 and byte <= 0x7e) .none else .csi,
+                .osc => switch (byte) {
+                    0x07 => .none,
+                    0x1b => .osc_escape,
+                    else => .osc,
+                },
+                .osc_escape => if (byte == '\\') .none else .osc,
+            };
+            if (was_plain and self.escape != .none and index > start) {
+                try self.output.writeAll(bytes[start..index]);
+            }
+            if (self.escape != .none or !was_plain) start = index + 1;
+        }
+        if (self.escape == .none and start < bytes.len) try self.output.writeAll(bytes[start..]);
+    }
+};
+
+test "logs parser accepts follow since and no-color around the name" {
+    const plain = try parse(&.{ "logs", "api" });
+    try std.testing.expectEqualStrings("api", plain.label);
+    try std.testing.expect(!plain.follow);
+    try std.testing.expectEqual(@as(?u64, null), plain.since_ms);
+
+    const follow = try parse(&.{ "logs", "-f", "--since", "5m", "api", "--no-color", "--stderr" });
+    try std.testing.expectEqualStrings("api", follow.label);
+    try std.testing.expect(follow.follow);
+    try std.testing.expect(follow.no_color);
+    try std.testing.expect(follow.stderr);
+    try std.testing.expect(!plain.stderr);
+    try std.testing.expectEqual(@as(?u64, 5 * std.time.ms_per_min), follow.since_ms);
+
+    const inline_since = try parse(&.{ "logs", "--since=250ms", "--follow", "api" });
+    try std.testing.expectEqual(@as(?u64, 250), inline_since.since_ms);
+
+    try std.testing.expectError(error.MissingName, parse(&.{ "logs", "-f" }));
+    try std.testing.expectError(error.MissingDuration, parse(&.{ "logs", "api", "--since" }));
+    try std.testing.expectError(error.InvalidDuration, parse(&.{ "logs", "--since", "10", "api" }));
+    try std.testing.expectError(error.InvalidDuration, parse(&.{ "logs", "--since", "xm", "api" }));
+    try std.testing.expectError(error.UnknownLogsFlag, parse(&.{ "logs", "--tail", "api" }));
+    try std.testing.expectError(error.UnexpectedArgument, parse(&.{ "logs", "api", "worker" }));
+}
+
+test "logs sink strips escape sequences split across writes" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+    var sink = Sink{ .output = TestOutput.writer(&out), .no_color = true };
+
+    try sink.writeAll("\x1b[31mred\x1b[");
+    try sink.writeAll("0m plain \x1b]0;title\x07done\x1b]8;;url\x1b");
+    try sink.writeAll("\\\r\n");
+    try std.testing.expectEqualStrings("red plain done\r\n", out.items);
+
+    out.clearRetainingCapacity();
+    var colored = Sink{ .output = TestOutput.writer(&out), .no_color = false };
+    try colored.writeAll("\x1b[31mred\x1b[0m");
+    try std.testing.expectEqualStrings("\x1b[31mred\x1b[0m", out.items);
+}
+
+const TestOutput = struct {
+    fn writer(out: *std.array_list.Managed(u8)) Output {
+        return .{
+            .context = out,
+            .write = write,
+        };
+    }
+
+    fn write(context: *anyopaque, bytes: []const u8) anyerror!void {
+        const out: *std.array_list.Managed(u8) = @ptrCast(@alignCast(context));
+        try out.appendSlice(bytes);
+    }
+};
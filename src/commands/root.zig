@@ -2,9 +2,21 @@
 //! Keeping command modules behind this small import surface lets app routing stay independent of individual command implementations.
 
 pub const config_init = @import("config_init.zig");
+pub const doctor = @import("doctor.zig");
+pub const env = @import("env.zig");
+pub const logs = @import("logs.zig");
+pub const rpc = @import("rpc.zig");
 pub const signal = @import("signal.zig");
+pub const snapshot = @import("snapshot.zig");
+pub const status = @import("status.zig");
 
 test {
     _ = config_init;
+    _ = doctor;
+    _ = env;
+    _ = logs;
+    _ = rpc;
     _ = signal;
+    _ = snapshot;
+    _ = status;
 }
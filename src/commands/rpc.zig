@@ -0,0 +1,308 @@
+//! `rpc` CLI behavior: JSON over stdio for editor integrations.
+//! Each stdin line is one request mapped onto the stable IPC subset (list, start, stop, restart, switch, logs, follow); answers and followed output are written to stdout as JSON lines, so editors that cannot open Unix sockets can still drive the Primary Server.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const ipc = @import("../ipc/root.zig");
+const threads = @import("../threads/root.zig");
+const status = @import("status.zig");
+
+/// Longest request line accepted; requests are small JSON objects.
+const max_request_len = 64 * 1024;
+
+pub const Input = struct {
+    context: *anyopaque,
+    read: *const fn (context: *anyopaque, buffer: []u8) anyerror!usize,
+
+    fn readBytes(self: Input, buffer: []u8) !usize {
+        return self.read(self.context, buffer);
+    }
+};
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+pub const Method = enum {
+    list,
+    start,
+    stop,
+    restart,
+    @"switch",
+    logs,
+    follow,
+};
+
+const Request = struct {
+    id: ?u64 = null,
+    method: []const u8,
+    params: Params = .{},
+};
+
+const Params = struct {
+    name: []const u8 = "",
+    /// Limits `logs` and the history `follow` sends before live output.
+    lines: ?u32 = null,
+};
+
+/// Serves requests from `input` until it reaches end of file. Followed streams
+/// are closed before returning.
+pub fn runWithSocketPath(
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    input: Input,
+    output: Output,
+) !void {
+    var session = Session{
+        .allocator = allocator,
+        .socket_path = socket_path,
+        .output = output,
+        .followers = std.array_list.Managed(*Follower).init(allocator),
+    };
+    defer session.deinit();
+
+    var pending = std.array_list.Managed(u8).init(allocator);
+    defer pending.deinit();
+    var buffer: [4096]u8 = undefined;
+    while (true) {
+        const n = try input.readBytes(&buffer);
+        if (n == 0) break;
+        try pending.appendSlice(buffer[0..n]);
+
+        while (std.mem.indexOfScalar(u8, pending.items, '\n')) |end| {
+            const line = std.mem.trim(u8, pending.items[0..end], " \t\r");
+            if (line.len > 0) try session.handleLine(line);
+            const rest = pending.items.len - (end + 1);
+            std.mem.copyForwards(u8, pending.items[0..rest], pending.items[end + 1 ..]);
+            pending.shrinkRetainingCapacity(rest);
+        }
+        if (pending.items.len > max_request_len) return error.RequestTooLong;
+    }
+}
+
+pub fn runWithConfig(
+    allocator: std.mem.Allocator,
+    cfg: *const config.schema.Config,
+    input: Input,
+    output: Output,
+) !void {
+    const socket_path = try ipc.socket.getPathForConfig(allocator, cfg);
+    defer allocator.free(socket_path);
+
+    try runWithSocketPath(allocator, socket_path, input, output);
+}
+
+const Session = struct {
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    output: Output,
+    /// Follow threads write events while the main loop writes answers.
+    output_mutex: std.Thread.Mutex = .{},
+    followers: std.array_list.Managed(*Follower),
+
+    fn deinit(self: *Session) void {
+        for (self.followers.items) |follower| follower.cancel();
+        for (self.followers.items) |follower| {
+            follower.thread.join();
+            follower.client.deinit();
+            self.allocator.destroy(follower);
+        }
+        self.followers.deinit();
+    }
+
+    /// Answers one request line. Failures are reported to the editor as an
+    /// `error` answer; only output failures end the session.
+    fn handleLine(self: *Session, line: []const u8) !void {
+        const parsed = std.json.parseFromSlice(Request, self.allocator, line, .{
+            .ignore_unknown_fields = true,
+        }) catch return self.writeError(null, "invalid request");
+        defer parsed.deinit();
+        const request = parsed.value;
+
+        const method = std.meta.stringToEnum(Method, request.method) orelse
+            return self.writeError(request.id, "unknown method");
+        self.dispatch(method, request) catch |err| switch (err) {
+            error.MissingName => return self.writeError(request.id, "missing params.name"),
+            error.OutputFailed => return err,
+            else => return self.writeError(request.id, @errorName(err)),
+        };
+    }
+
+    fn dispatch(self: *Session, method: Method, request: Request) !void {
+        switch (method) {
+            .list => {
+                var snapshot_update = try ipc.client.readInitialSnapshotFromPath(self.allocator, self.socket_path);
+                defer snapshot_update.deinit();
+                const processes = try status.jsonProcesses(self.allocator, snapshot_update.snapshot(), std.time.milliTimestamp());
+                defer self.allocator.free(processes);
+                try self.write(.{ .id = request.id, .result = .{ .processes = processes } });
+            },
+            .start, .stop, .restart, .@"switch" => {
+                if (request.params.name.len == 0) return error.MissingName;
+                const action: ipc.protocol.Command = switch (method) {
+                    .start => .start,
+                    .stop => .stop,
+                    .restart => .restart,
+                    else => .switch_process,
+                };
+                const response = try ipc.client.sendCommandToPath(self.allocator, self.socket_path, 1, action, request.params.name);
+                defer response.deinit(self.allocator);
+                if (!response.success) return self.writeError(request.id, response.error_message);
+                try self.write(.{ .id = request.id, .result = .{ .success = true } });
+            },
+            .logs => {
+                if (request.params.name.len == 0) return error.MissingName;
+                var ipc_client = try ipc.client.Client.connect(self.allocator, self.socket_path);
+                defer ipc_client.deinit();
+                const reply = try ipc_client.fetchScrollback(request.params.name, .{ .lines = request.params.lines });
+                defer reply.deinit(self.allocator);
+                switch (reply) {
+                    .data => |data| {
+                        const encoded = try encodeBase64(self.allocator, data.data);
+                        defer self.allocator.free(encoded);
+                        try self.write(.{ .id = request.id, .result = .{ .data = encoded } });
+                    },
+                    .refused => |response| try self.writeError(request.id, response.error_message),
+                }
+            },
+            .follow => try self.follow(request),
+        }
+    }
+
+    fn follow(self: *Session, request: Request) !void {
+        if (request.params.name.len == 0) return error.MissingName;
+        var client = try ipc.client.Client.connect(self.allocator, self.socket_path);
+        var client_owned = true;
+        defer if (client_owned) client.deinit();
+
+        const response = try client.requestOutputStream(request.params.name, .{ .lines = request.params.lines });
+        defer response.deinit(self.allocator);
+        if (!response.success) return self.writeError(request.id, response.error_message);
+
+        try self.followers.ensureUnusedCapacity(1);
+        const follower = try self.allocator.create(Follower);
+        errdefer self.allocator.destroy(follower);
+        follower.* = .{ .session = self, .id = request.id, .client = client };
+
+        // Answer before the thread starts so the result precedes any output.
+        try self.write(.{ .id = request.id, .result = .{ .success = true } });
+        follower.thread = try threads.spawn(.{}, Follower.run, .{follower});
+        client_owned = false;
+        self.followers.appendAssumeCapacity(follower);
+    }
+
+    fn writeError(self: *Session, id: ?u64, message: []const u8) !void {
+        try self.write(.{ .id = id, .@"error" = message });
+    }
+
+    fn write(self: *Session, value: anytype) !void {
+        var line = std.array_list.Managed(u8).init(self.allocator);
+        defer line.deinit();
+        try line.writer().print("{f}\n", .{std.json.fmt(value, .{})});
+
+        self.output_mutex.lock();
+        defer self.output_mutex.unlock();
+        self.output.writeAll(line.items) catch return error.OutputFailed;
+    }
+};
+
+/// One `follow` request: a dedicated stream connection whose frames become
+/// `output` events tagged with the request id.
+const Follower = struct {
+    session: *Session,
+    id: ?u64,
+    client: ipc.client.Client,
+    thread: std.Thread = undefined,
+    cancelled: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+
+    /// Unblocks the frame read so the thread can be joined.
+    fn cancel(self: *Follower) void {
+        self.cancelled.store(true, .seq_cst);
+        std.posix.shutdown(self.client.stream.handle, .both) catch {};
+    }
+
+    fn run(self: *Follower) void {
+        const allocator = self.session.allocator;
+        while (true) {
+            const output_frame = self.client.readOutputFrame() catch break;
+            defer output_frame.deinit(allocator);
+            if (output_frame.payload.len == 0) continue;
+
+            const encoded = encodeBase64(allocator, output_frame.payload) catch break;
+            defer allocator.free(encoded);
+            self.session.write(.{ .id = self.id, .event = "output", .data = encoded }) catch break;
+        }
+        if (!self.cancelled.load(.seq_cst)) {
+            self.session.write(.{ .id = self.id, .event = "closed" }) catch {};
+        }
+    }
+};
+
+/// Output is base64-encoded, as in the IPC Protocol, so terminal control
+/// sequences and partial UTF-8 survive JSON.
+fn encodeBase64(allocator: std.mem.Allocator, bytes: []const u8) ![]u8 {
+    const encoder = std.base64.standard.Encoder;
+    const encoded = try allocator.alloc(u8, encoder.calcSize(bytes.len));
+    _ = encoder.encode(encoded, bytes);
+    return encoded;
+}
+
+test "rpc answers malformed and unknown requests without a primary" {
+    var input = TestInput{ .data =
+    \\not json
+    \\{"id":1,"method":"frobnicate"}
+    \\{"id":2,"method":"start","params":{}}
+    \\
+    };
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try runWithSocketPath(
+        std.testing.allocator,
+        "/tmp/proctmux-rpc-test-missing.socket",
+        input.reader(),
+        TestOutput.writer(&out),
+    );
+    try std.testing.expectEqualStrings(
+        "{\"id\":null,\"error\":\"invalid request\"}\n" ++
+            "{\"id\":1,\"error\":\"unknown method\"}\n" ++
+            "{\"id\":2,\"error\":\"missing params.name\"}\n",
+        out.items,
+    );
+}
+
+const TestInput = struct {
+    data: []const u8,
+    offset: usize = 0,
+
+    fn reader(self: *TestInput) Input {
+        return .{ .context = self, .read = read };
+    }
+
+    fn read(context: *anyopaque, buffer: []u8) anyerror!usize {
+        const self: *TestInput = @ptrCast(@alignCast(context));
+        const n = @min(buffer.len, self.data.len - self.offset);
+        @memcpy(buffer[0..n], self.data[self.offset..][0..n]);
+        self.offset += n;
+        return n;
+    }
+};
+
+const TestOutput = struct {
+    fn writer(out: *std.array_list.Managed(u8)) Output {
+        return .{
+            .context = out,
+            .write = write,
+        };
+    }
+
+    fn write(context: *anyopaque, bytes: []const u8) anyerror!void {
+        const out: *std.array_list.Managed(u8) = @ptrCast(@alignCast(context));
+        try out.appendSlice(bytes);
+    }
+};
@@ -1,5 +1,5 @@
 //! Signal-command CLI behavior over IPC.
-//! Mutation commands send Process Commands and exit; `signal-list` is intentionally read-only and formats the initial Client Snapshot instead of requiring a list command in the protocol.
+//! Mutation commands send Process Commands and exit; `signal-list` is intentionally read-only and formats the initial Client Snapshot instead of requiring a list command in the protocol, and `signal-scrollback` prints fetched process output.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
@@ -12,11 +12,18 @@ pub const ProcessCommand = struct {
     label: []const u8 = "",
 };
 
+pub const ScrollbackCommand = struct {
+    label: []const u8,
+    /// Null prints the default scrollback tail instead of a line count.
+    lines: ?u32 = null,
+};
+
 /// Parsed signal-command intent. Listing is separate from Process Commands so
 /// the IPC protocol does not need a request/response shape for process lists.
 pub const Plan = union(enum) {
     command: ProcessCommand,
     list,
+    scrollback: ScrollbackCommand,
 };
 
 pub const Sender = struct {
@@ -63,6 +70,12 @@ pub fn parse(subcommand: []const u8, args: []const []const u8) !Plan {
     if (std.mem.eql(u8, subcommand, "signal-list")) {
         return .list;
     }
+    if (std.mem.eql(u8, subcommand, "signal-scrollback")) {
+        return .{ .scrollback = .{
+            .label = try requiredName(args),
+            .lines = if (args.len > 2) std.fmt.parseInt(u32, args[2], 10) catch return error.InvalidLineCount else null,
+        } };
+    }
     return error.UnknownSignalCommand;
 }
 
@@ -79,6 +92,7 @@ pub fn runWithSender(
     _ = output;
     switch (plan) {
         .list => return error.ListRequiresSnapshot,
+        .scrollback => return error.ScrollbackRequiresConnection,
         .command => |command| {
             var response = try sender.sendCommand(command.action, command.label);
             defer response.deinit(allocator);
@@ -110,6 +124,16 @@ pub fn runWithSocketPath(
             defer response.deinit(allocator);
             if (!response.success) return error.CommandFailed;
         },
+        .scrollback => |scrollback| {
+            var ipc_client = try ipc.client.Client.connect(allocator, socket_path);
+            defer ipc_client.deinit();
+            const reply = try ipc_client.fetchScrollback(scrollback.label, .{ .lines = scrollback.lines });
+            defer reply.deinit(allocator);
+            switch (reply) {
+                .data => |data| try output.writeAll(data.data),
+                .refused => return error.CommandFailed,
+            }
+        },
     }
 }
 
@@ -175,13 +199,25 @@ test "signal command parser maps running and list commands" {
     try std.testing.expectEqual(Plan.list, list);
 }
 
+test "signal command parser maps scrollback with an optional line count" {
+    const tail = try parse("signal-scrollback", &.{ "signal-scrollback", "api", "50" });
+    try std.testing.expectEqualStrings("api", tail.scrollback.label);
+    try std.testing.expectEqual(@as(?u32, 50), tail.scrollback.lines);
+
+    const default_tail = try parse("signal-scrollback", &.{ "signal-scrollback", "api" });
+    try std.testing.expectEqual(@as(?u32, null), default_tail.scrollback.lines);
+
+    try std.testing.expectError(error.MissingName, parse("signal-scrollback", &.{"signal-scrollback"}));
+    try std.testing.expectError(error.InvalidLineCount, parse("signal-scrollback", &.{ "signal-scrollback", "api", "lots" }));
+}
+
 fn expectCommandPlan(plan: Plan, action: ipc.protocol.Command, label: []const u8) !void {
     switch (plan) {
         .command => |command| {
             try std.testing.expectEqual(action, command.action);
             try std.testing.expectEqualStrings(label, command.label);
         },
-        .list => return error.ExpectedCommandPlan,
+        .list, .scrollback => return error.ExpectedCommandPlan,
     }
 }
 
@@ -0,0 +1,291 @@
+//! `snapshot save|restore` CLI behavior over IPC.
+//! A saved snapshot is a small JSON file naming the processes that were running and the selected one, so a set of processes for one task can be brought back later. Filters are not part of it: they belong to each TUI client, and the primary never sees them.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const ipc = @import("../ipc/root.zig");
+
+pub const file_version: u32 = 1;
+const max_file_bytes = 1024 * 1024;
+/// A restore stops processes as one batch, which takes as long as the
+/// slowest `stop_timeout_ms`.
+const restore_response_timeout_ms: i32 = 60_000;
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+pub const Action = enum {
+    save,
+    restore,
+};
+
+pub const Options = struct {
+    action: Action,
+    path: []const u8,
+};
+
+/// The file on disk. Labels are borrowed from whatever they were read from.
+pub const File = struct {
+    version: u32 = file_version,
+    running: []const []const u8 = &.{},
+    selected: ?[]const u8 = null,
+};
+
+/// Parses `snapshot save|restore <file>`; `args[0]` is the subcommand itself.
+pub fn parse(args: []const []const u8) !Options {
+    if (args.len < 2) return error.MissingSnapshotAction;
+    const action = std.meta.stringToEnum(Action, args[1]) orelse return error.UnknownSnapshotAction;
+    if (args.len < 3) return error.MissingSnapshotFile;
+    if (args.len > 3) return error.UnexpectedArgument;
+    return .{ .action = action, .path = args[2] };
+}
+
+pub fn runWithSocketPath(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    socket_path: []const u8,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const options = try parse(args);
+    switch (options.action) {
+        .save => try save(allocator, dir, socket_path, options.path, output),
+        .restore => try restore(allocator, dir, socket_path, options.path, output),
+    }
+}
+
+pub fn runWithConfig(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    cfg: *const config.schema.Config,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const socket_path = try ipc.socket.getPathForConfig(allocator, cfg);
+    defer allocator.free(socket_path);
+
+    try runWithSocketPath(allocator, dir, socket_path, args, output);
+}
+
+fn save(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    socket_path: []const u8,
+    path: []const u8,
+    output: Output,
+) !void {
+    var snapshot_update = try ipc.client.readInitialSnapshotFromPath(allocator, socket_path);
+    defer snapshot_update.deinit();
+    const snapshot = snapshot_update.snapshot();
+
+    var running = std.array_list.Managed([]const u8).init(allocator);
+    defer running.deinit();
+    var selected: ?[]const u8 = null;
+    for (snapshot.processes) |item| {
+        if (isActive(item.status)) try running.append(item.label);
+        if (item.id == snapshot.current_process_id) selected = item.label;
+    }
+
+    const text = try format(allocator, .{ .running = running.items, .selected = selected });
+    defer allocator.free(text);
+    try dir.writeFile(.{ .sub_path = path, .data = text });
+
+    const message = try std.fmt.allocPrint(allocator, "saved {d} running processes to {s}\n", .{ running.items.len, path });
+    defer allocator.free(message);
+    try output.writeAll(message);
+}
+
+/// Starts what the file lists, stops what it does not, then selects its
+/// selected process. Labels this config no longer has are reported and
+/// skipped.
+fn restore(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    socket_path: []const u8,
+    path: []const u8,
+    output: Output,
+) !void {
+    const bytes = try dir.readFileAlloc(allocator, path, max_file_bytes);
+    defer allocator.free(bytes);
+    const parsed = try parseFile(allocator, bytes);
+    defer parsed.deinit();
+    const file = parsed.value;
+
+    var ipc_client = try ipc.client.Client.connect(allocator, socket_path);
+    defer ipc_client.deinit();
+    ipc_client.response_timeout_ms = restore_response_timeout_ms;
+
+    var snapshot_update = try ipc_client.readSnapshot();
+    defer snapshot_update.deinit();
+    const snapshot = snapshot_update.snapshot();
+
+    var plan = try Plan.init(allocator, snapshot, file);
+    defer plan.deinit();
+    for (plan.unknown.items) |label| {
+        const message = try std.fmt.allocPrint(allocator, "skipping unknown process: {s}\n", .{label});
+        defer allocator.free(message);
+        try output.writeAll(message);
+    }
+
+    if (plan.stop.items.len > 0) try sendBatch(allocator, &ipc_client, .stop, plan.stop.items, output);
+    if (plan.start.items.len > 0) try sendBatch(allocator, &ipc_client, .start, plan.start.items, output);
+    if (plan.select) |label| {
+        const request_id = try ipc_client.sendCommand(.switch_process, label);
+        var response = try ipc_client.readResponseFor(request_id);
+        defer response.deinit(allocator);
+        if (!response.success) return failed(allocator, response, output);
+    }
+
+    const message = try std.fmt.allocPrint(allocator, "restored {s}: started {d}, stopped {d}\n", .{ path, plan.start.items.len, plan.stop.items.len });
+    defer allocator.free(message);
+    try output.writeAll(message);
+}
+
+fn sendBatch(
+    allocator: std.mem.Allocator,
+    ipc_client: *ipc.client.Client,
+    action: ipc.protocol.Command,
+    labels: []const []const u8,
+    output: Output,
+) !void {
+    const request_id = try ipc_client.sendBatchCommand(action, labels);
+    var response = try ipc_client.readResponseFor(request_id);
+    defer response.deinit(allocator);
+    if (!response.success) return failed(allocator, response, output);
+}
+
+fn failed(allocator: std.mem.Allocator, response: ipc.protocol.Response, output: Output) !void {
+    const message = try std.fmt.allocPrint(allocator, "restore failed: {s}\n", .{response.error_message});
+    defer allocator.free(message);
+    try output.writeAll(message);
+    return error.CommandFailed;
+}
+
+/// What a restore changes. Labels are borrowed from the snapshot and file.
+const Plan = struct {
+    stop: std.array_list.Managed([]const u8),
+    start: std.array_list.Managed([]const u8),
+    unknown: std.array_list.Managed([]const u8),
+    select: ?[]const u8 = null,
+
+    fn init(allocator: std.mem.Allocator, snapshot: *const domain.client_snapshot.ClientSnapshot, file: File) !Plan {
+        var plan = Plan{
+            .stop = std.array_list.Managed([]const u8).init(allocator),
+            .start = std.array_list.Managed([]const u8).init(allocator),
+            .unknown = std.array_list.Managed([]const u8).init(allocator),
+        };
+        errdefer plan.deinit();
+
+        for (snapshot.processes) |item| {
+            if (isActive(item.status) and !contains(file.running, item.label)) try plan.stop.append(item.label);
+        }
+        for (file.running) |label| {
+            const item = findProcess(snapshot, label) orelse {
+                try plan.unknown.append(label);
+                continue;
+            };
+            if (!isActive(item.status)) try plan.start.append(label);
+        }
+        if (file.selected) |label| {
+            if (findProcess(snapshot, label) != null) plan.select = label else try plan.unknown.append(label);
+        }
+        return plan;
+    }
+
+    fn deinit(self: *Plan) void {
+        self.stop.deinit();
+        self.start.deinit();
+        self.unknown.deinit();
+    }
+};
+
+/// Running, or on its way there; a process that is stopping is not.
+fn isActive(status: domain.process.ProcessStatus) bool {
+    return switch (status) {
+        .running, .starting, .restarting => true,
+        else => false,
+    };
+}
+
+fn contains(labels: []const []const u8, label: []const u8) bool {
+    for (labels) |candidate| {
+        if (std.mem.eql(u8, candidate, label)) return true;
+    }
+    return false;
+}
+
+fn findProcess(
+    snapshot: *const domain.client_snapshot.ClientSnapshot,
+    label: []const u8,
+) ?domain.client_snapshot.ProcessSummary {
+    for (snapshot.processes) |item| {
+        if (std.mem.eql(u8, item.label, label)) return item;
+    }
+    return null;
+}
+
+pub fn format(allocator: std.mem.Allocator, file: File) ![]u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    try out.writer().print("{f}\n", .{std.json.fmt(file, .{ .whitespace = .indent_2 })});
+    return out.toOwnedSlice();
+}
+
+pub fn parseFile(allocator: std.mem.Allocator, bytes: []const u8) !std.json.Parsed(File) {
+    const parsed = std.json.parseFromSlice(File, allocator, bytes, .{ .allocate = .alloc_always }) catch
+        return error.InvalidSnapshotFile;
+    errdefer parsed.deinit();
+    if (parsed.value.version != file_version) return error.UnsupportedSnapshotVersion;
+    return parsed;
+}
+
+test "snapshot parser takes an action and one file" {
+    const options = try parse(&.{ "snapshot", "save", "frontend.json" });
+    try std.testing.expectEqual(Action.save, options.action);
+    try std.testing.expectEqualStrings("frontend.json", options.path);
+    try std.testing.expectEqual(Action.restore, (try parse(&.{ "snapshot", "restore", "a.json" })).action);
+    try std.testing.expectError(error.MissingSnapshotAction, parse(&.{"snapshot"}));
+    try std.testing.expectError(error.UnknownSnapshotAction, parse(&.{ "snapshot", "load", "a.json" }));
+    try std.testing.expectError(error.MissingSnapshotFile, parse(&.{ "snapshot", "save" }));
+    try std.testing.expectError(error.UnexpectedArgument, parse(&.{ "snapshot", "save", "a.json", "b.json" }));
+}
+
+test "snapshot files round trip and reject other versions" {
+    const text = try format(std.testing.allocator, .{ .running = &.{ "api", "web" }, .selected = "web" });
+    defer std.testing.allocator.free(text);
+
+    const parsed = try parseFile(std.testing.allocator, text);
+    defer parsed.deinit();
+    try std.testing.expectEqual(@as(usize, 2), parsed.value.running.len);
+    try std.testing.expectEqualStrings("web", parsed.value.running[1]);
+    try std.testing.expectEqualStrings("web", parsed.value.selected.?);
+
+    try std.testing.expectError(error.UnsupportedSnapshotVersion, parseFile(std.testing.allocator, "{\"version\":2}"));
+    try std.testing.expectError(error.InvalidSnapshotFile, parseFile(std.testing.allocator, "{\"running\":[],\"filter\":\"x\"}"));
+}
+
+test "snapshot restore plan stops extras, starts missing, and skips unknown labels" {
+    const processes = [_]domain.client_snapshot.ProcessSummary{
+        .{ .id = 1, .label = "api", .status = .running },
+        .{ .id = 2, .label = "db", .status = .running },
+        .{ .id = 3, .label = "web" },
+    };
+    const snapshot = domain.client_snapshot.ClientSnapshot{ .processes = &processes };
+
+    var plan = try Plan.init(std.testing.allocator, &snapshot, .{ .running = &.{ "api", "web", "gone" }, .selected = "web" });
+    defer plan.deinit();
+    try std.testing.expectEqual(@as(usize, 1), plan.stop.items.len);
+    try std.testing.expectEqualStrings("db", plan.stop.items[0]);
+    try std.testing.expectEqual(@as(usize, 1), plan.start.items.len);
+    try std.testing.expectEqualStrings("web", plan.start.items[0]);
+    try std.testing.expectEqual(@as(usize, 1), plan.unknown.items.len);
+    try std.testing.expectEqualStrings("gone", plan.unknown.items[0]);
+    try std.testing.expectEqualStrings("web", plan.select.?);
+}
@@ -0,0 +1,194 @@
+//! `status` CLI behavior over IPC.
+//! Like `signal-list` the command reads one Client Snapshot and never mutates server state; `--json` prints the process states as a single JSON document for scripts and editor integrations.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const ipc = @import("../ipc/root.zig");
+
+pub const Output = struct {
+    context: *anyopaque,
+    write: *const fn (context: *anyopaque, bytes: []const u8) anyerror!void,
+
+    fn writeAll(self: Output, bytes: []const u8) !void {
+        try self.write(self.context, bytes);
+    }
+};
+
+pub const Options = struct {
+    json: bool = false,
+};
+
+/// Parses `status [--json]`; `args[0]` is the subcommand itself.
+pub fn parse(args: []const []const u8) !Options {
+    var options = Options{};
+    for (args[@min(args.len, 1)..]) |arg| {
+        if (std.mem.eql(u8, arg, "--json")) {
+            options.json = true;
+        } else if (arg.len > 1 and arg[0] == '-') {
+            return error.UnknownStatusFlag;
+        } else {
+            return error.UnexpectedArgument;
+        }
+    }
+    return options;
+}
+
+pub fn runWithSocketPath(
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const options = try parse(args);
+
+    var snapshot_update = try ipc.client.readInitialSnapshotFromPath(allocator, socket_path);
+    defer snapshot_update.deinit();
+
+    const now_ms = std.time.milliTimestamp();
+    const text = if (options.json)
+        try formatJson(allocator, snapshot_update.snapshot(), now_ms)
+    else
+        try formatTable(allocator, snapshot_update.snapshot(), now_ms);
+    defer allocator.free(text);
+    try output.writeAll(text);
+}
+
+pub fn runWithConfig(
+    allocator: std.mem.Allocator,
+    cfg: *const config.schema.Config,
+    args: []const []const u8,
+    output: Output,
+) !void {
+    const socket_path = try ipc.socket.getPathForConfig(allocator, cfg);
+    defer allocator.free(socket_path);
+
+    try runWithSocketPath(allocator, socket_path, args, output);
+}
+
+/// One process in `status --json` and the `rpc` list result. Fields that do
+/// not apply to the current state are null rather than omitted so consumers
+/// see a fixed shape.
+pub const JsonProcess = struct {
+    label: []const u8,
+    status: []const u8,
+    pid: ?i32,
+    uptime_ms: ?i64,
+    exit_code: ?u32,
+    ports: []const []const u8,
+};
+
+/// Projects snapshot processes into `JsonProcess` values. The returned slice
+/// is owned by the caller; strings are borrowed from the snapshot.
+pub fn jsonProcesses(
+    allocator: std.mem.Allocator,
+    snapshot: *const domain.client_snapshot.ClientSnapshot,
+    now_ms: i64,
+) ![]JsonProcess {
+    const processes = try allocator.alloc(JsonProcess, snapshot.processes.len);
+    for (snapshot.processes, 0..) |item, index| {
+        processes[index] = .{
+            .label = item.label,
+            .status = @tagName(item.status),
+            .pid = if (item.pid > 0) item.pid else null,
+            .uptime_ms = uptimeMs(item, now_ms),
+            .exit_code = item.exit_code,
+            .ports = item.ports,
+        };
+    }
+    return processes;
+}
+
+/// Formats the snapshot as `{"processes":[...]}` followed by a newline.
+pub fn formatJson(
+    allocator: std.mem.Allocator,
+    snapshot: *const domain.client_snapshot.ClientSnapshot,
+    now_ms: i64,
+) ![]u8 {
+    const processes = try jsonProcesses(allocator, snapshot, now_ms);
+    defer allocator.free(processes);
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    try out.writer().print("{f}\n", .{std.json.fmt(.{ .processes = processes }, .{})});
+    return out.toOwnedSlice();
+}
+
+/// Formats a tab-delimited table; `-` marks values that do not apply.
+pub fn formatTable(
+    allocator: std.mem.Allocator,
+    snapshot: *const domain.client_snapshot.ClientSnapshot,
+    now_ms: i64,
+) ![]u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    const writer = out.writer();
+
+    try out.appendSlice("NAME\tSTATUS\tPID\tUPTIME\tEXIT\tPORTS\n");
+    for (snapshot.processes) |item| {
+        try writer.print("{s}\t{s}\t", .{ item.label, @tagName(item.status) });
+        if (item.pid > 0) try writer.print("{d}", .{item.pid}) else try out.append('-');
+        try out.append('\t');
+        if (uptimeMs(item, now_ms)) |uptime| try writeDuration(writer, uptime) else try out.append('-');
+        try out.append('\t');
+        if (item.exit_code) |code| try writer.print("{d}", .{code}) else try out.append('-');
+        try out.append('\t');
+        if (item.ports.len == 0) try out.append('-');
+        for (item.ports, 0..) |port, index| {
+            if (index > 0) try out.append(',');
+            try out.appendSlice(port);
+        }
+        try out.append('\n');
+    }
+    return out.toOwnedSlice();
+}
+
+fn uptimeMs(item: domain.client_snapshot.ProcessSummary, now_ms: i64) ?i64 {
+    if (item.status != .running or item.started_ms <= 0) return null;
+    return @max(0, now_ms - item.started_ms);
+}
+
+/// Writes `1h2m`, `3m4s`, or `5s`.
+fn writeDuration(writer: anytype, ms: i64) !void {
+    const seconds: u64 = @intCast(@divTrunc(ms, std.time.ms_per_s));
+    const hours = seconds / std.time.s_per_hour;
+    const minutes = seconds % std.time.s_per_hour / std.time.s_per_min;
+    if (hours > 0) return writer.print("{d}h{d}m", .{ hours, minutes });
+    if (minutes > 0) return writer.print("{d}m{d}s", .{ minutes, seconds % std.time.s_per_min });
+    try writer.print("{d}s", .{seconds});
+}
+
+test "status parser accepts only the json flag" {
+    try std.testing.expect(!(try parse(&.{"status"})).json);
+    try std.testing.expect((try parse(&.{ "status", "--json" })).json);
+    try std.testing.expectError(error.UnknownStatusFlag, parse(&.{ "status", "--yaml" }));
+    try std.testing.expectError(error.UnexpectedArgument, parse(&.{ "status", "api" }));
+}
+
+test "status formats snapshot processes as json and as a table" {
+    const processes = [_]domain.client_snapshot.ProcessSummary{
+        .{ .id = 1, .label = "api", .status = .running, .pid = 4242, .started_ms = 1_000, .ports = &.{"8080:80"} },
+        .{ .id = 2, .label = "migrate", .exit_code = 3 },
+    };
+    const snapshot = domain.client_snapshot.ClientSnapshot{ .processes = &processes };
+    const now_ms = 1_000 + 125 * std.time.ms_per_s;
+
+    const json = try formatJson(std.testing.allocator, &snapshot, now_ms);
+    defer std.testing.allocator.free(json);
+    try std.testing.expectEqualStrings(
+        "{\"processes\":[" ++
+            "{\"label\":\"api\",\"status\":\"running\",\"pid\":4242,\"uptime_ms\":125000,\"exit_code\":null,\"ports\":[\"8080:80\"]}," ++
+            "{\"label\":\"migrate\",\"status\":\"halted\",\"pid\":null,\"uptime_ms\":null,\"exit_code\":3,\"ports\":[]}" ++
+            "]}\n",
+        json,
+    );
+
+    const table = try formatTable(std.testing.allocator, &snapshot, now_ms);
+    defer std.testing.allocator.free(table);
+    try std.testing.expectEqualStrings(
+        "NAME\tSTATUS\tPID\tUPTIME\tEXIT\tPORTS\n" ++
+            "api\trunning\t4242\t2m5s\t-\t8080:80\n" ++
+            "migrate\thalted\t-\t-\t3\t-\n",
+        table,
+    );
+}
@@ -1,6 +1,7 @@
 //! Default Project Config values shared by config loading and tests.
 //! Defaults live separately from schema types so the schema can describe shape while this module describes proctmux's chosen behavior.
 
+const icons = @import("icons.zig");
 const schema = @import("schema.zig");
 
 pub const banner =
@@ -31,18 +32,62 @@ pub fn apply(cfg: *schema.Config, allocator: schema.Allocator) !void {
     try setListDefault(allocator, &cfg.keybinding.toggle_focus, &.{"ctrl+w"});
     try setListDefault(allocator, &cfg.keybinding.focus_client, &.{"ctrl+left"});
     try setListDefault(allocator, &cfg.keybinding.focus_server, &.{"ctrl+right"});
+    try setListDefault(allocator, &cfg.keybinding.rotate_split, &.{"ctrl+o"});
+    try setListDefault(allocator, &cfg.keybinding.grow_client, &.{"ctrl+shift+right"});
+    try setListDefault(allocator, &cfg.keybinding.shrink_client, &.{"ctrl+shift+left"});
+    try setListDefault(allocator, &cfg.keybinding.cycle_view, &.{"v"});
+    try setListDefault(allocator, &cfg.keybinding.cycle_sort, &.{"S"});
+    try setListDefault(allocator, &cfg.keybinding.toggle_pin, &.{"p"});
+    try setListDefault(allocator, &cfg.keybinding.toggle_mark, &.{"space"});
+    try setListDefault(allocator, &cfg.keybinding.toggle_messages, &.{"m"});
     try setListDefault(allocator, &cfg.keybinding.docs, &.{"d"});
+    try setListDefault(allocator, &cfg.keybinding.jump_to_error, &.{"e"});
+    try setListDefault(allocator, &cfg.keybinding.copy_command, &.{"y"});
+    try setListDefault(allocator, &cfg.keybinding.copy_pid, &.{"P"});
+    try setListDefault(allocator, &cfg.keybinding.copy_cwd, &.{"C"});
+    try setListDefault(allocator, &cfg.keybinding.copy_output, &.{"Y"});
+    try setListDefault(allocator, &cfg.keybinding.open_url, &.{"o"});
+    try setListDefault(allocator, &cfg.keybinding.open_cwd, &.{"O"});
+    try setListDefault(allocator, &cfg.keybinding.show_env, &.{"E"});
+    try setListDefault(allocator, &cfg.error_patterns, &.{ "error", "fatal", "panic", "exception" });
 
     if (cfg.layout.category_search_prefix.len == 0) cfg.layout.category_search_prefix = "cat:";
     if (cfg.layout.placeholder_banner.len == 0) cfg.layout.placeholder_banner = banner;
     if (cfg.layout.processes_list_width <= 0 or cfg.layout.processes_list_width > 100) {
         cfg.layout.processes_list_width = 30;
     }
+    if (cfg.layout.max_output_fps <= 0) cfg.layout.max_output_fps = 30;
+    if (cfg.layout.max_relay_bytes_per_tick <= 0) cfg.layout.max_relay_bytes_per_tick = 256 * 1024;
+    if (cfg.layout.last_line_preview.len == 0) cfg.layout.last_line_preview = "off";
 
-    if (cfg.style.pointer_char.len == 0) cfg.style.pointer_char = "▶";
+    icons.apply(&cfg.style);
     if (cfg.style.selected_process_color.len == 0) cfg.style.selected_process_color = "white";
     if (cfg.style.selected_process_bg_color.len == 0) cfg.style.selected_process_bg_color = "magenta";
     if (cfg.style.status_running_color.len == 0) cfg.style.status_running_color = "green";
     if (cfg.style.status_halting_color.len == 0) cfg.style.status_halting_color = "yellow";
     if (cfg.style.status_stopped_color.len == 0) cfg.style.status_stopped_color = "red";
+    if (cfg.style.placeholder_banner_color.len == 0) cfg.style.placeholder_banner_color = "cyan";
+    if (cfg.style.warning_color.len == 0) cfg.style.warning_color = "yellow";
+    if (cfg.style.unread_output_color.len == 0) cfg.style.unread_output_color = "cyan";
+    if (cfg.style.category_color_target.len == 0) cfg.style.category_color_target = "marker";
+
+    // The dark palette's yellow, cyan, and white-on-magenta wash out on light
+    // backgrounds, so the light defaults use darker 256-color shades.
+    icons.apply(&cfg.light_style);
+    if (cfg.light_style.selected_process_color.len == 0) cfg.light_style.selected_process_color = "white";
+    if (cfg.light_style.selected_process_bg_color.len == 0) cfg.light_style.selected_process_bg_color = "25";
+    if (cfg.light_style.status_running_color.len == 0) cfg.light_style.status_running_color = "28";
+    if (cfg.light_style.status_halting_color.len == 0) cfg.light_style.status_halting_color = "136";
+    if (cfg.light_style.status_stopped_color.len == 0) cfg.light_style.status_stopped_color = "160";
+    if (cfg.light_style.placeholder_banner_color.len == 0) cfg.light_style.placeholder_banner_color = "30";
+    if (cfg.light_style.warning_color.len == 0) cfg.light_style.warning_color = "166";
+    if (cfg.light_style.unread_output_color.len == 0) cfg.light_style.unread_output_color = "30";
+    if (cfg.light_style.category_color_target.len == 0) cfg.light_style.category_color_target = "marker";
+    if (cfg.background.len == 0) cfg.background = "auto";
+    if (cfg.general.on_quit.len == 0) cfg.general.on_quit = "stop";
+    if (cfg.general.on_bell.len == 0) cfg.general.on_bell = "ring";
+    if (cfg.general.refresh_interval_ms == 0) cfg.general.refresh_interval_ms = 50;
+    if (cfg.general.output_poll_interval_ms == 0) cfg.general.output_poll_interval_ms = 20;
+    if (cfg.general.watch_poll_interval_ms == 0) cfg.general.watch_poll_interval_ms = 500;
+    if (cfg.general.watch_debounce_ms == 0) cfg.general.watch_debounce_ms = 500;
 }
@@ -5,6 +5,8 @@ const std = @import("std");
 const schema = @import("schema.zig");
 
 pub fn toHash(allocator: schema.Allocator, cfg: *const schema.Config) ![]const u8 {
+    if (cfg.identity_hash.len > 0) return allocator.dupe(u8, cfg.identity_hash);
+
     var buf = std.array_list.Managed(u8).init(allocator);
     defer buf.deinit();
 
@@ -31,6 +33,22 @@ fn writeConfig(allocator: schema.Allocator, buf: *std.array_list.Managed(u8), cf
     try writeStringList(buf, "keybinding.toggle_focus", cfg.keybinding.toggle_focus);
     try writeStringList(buf, "keybinding.focus_client", cfg.keybinding.focus_client);
     try writeStringList(buf, "keybinding.focus_server", cfg.keybinding.focus_server);
+    try writeStringList(buf, "keybinding.rotate_split", cfg.keybinding.rotate_split);
+    try writeStringList(buf, "keybinding.grow_client", cfg.keybinding.grow_client);
+    try writeStringList(buf, "keybinding.shrink_client", cfg.keybinding.shrink_client);
+    try writeStringList(buf, "keybinding.cycle_view", cfg.keybinding.cycle_view);
+    try writeStringList(buf, "keybinding.cycle_sort", cfg.keybinding.cycle_sort);
+    try writeStringList(buf, "keybinding.toggle_pin", cfg.keybinding.toggle_pin);
+    try writeStringList(buf, "keybinding.toggle_mark", cfg.keybinding.toggle_mark);
+    try writeStringList(buf, "keybinding.toggle_messages", cfg.keybinding.toggle_messages);
+    try writeStringList(buf, "keybinding.jump_to_error", cfg.keybinding.jump_to_error);
+    try writeStringList(buf, "keybinding.copy_command", cfg.keybinding.copy_command);
+    try writeStringList(buf, "keybinding.copy_pid", cfg.keybinding.copy_pid);
+    try writeStringList(buf, "keybinding.copy_cwd", cfg.keybinding.copy_cwd);
+    try writeStringList(buf, "keybinding.copy_output", cfg.keybinding.copy_output);
+    try writeStringList(buf, "keybinding.open_url", cfg.keybinding.open_url);
+    try writeStringList(buf, "keybinding.open_cwd", cfg.keybinding.open_cwd);
+    try writeStringList(buf, "keybinding.show_env", cfg.keybinding.show_env);
     try writeStringList(buf, "keybinding.docs", cfg.keybinding.docs);
 
     try writeLine(buf, "layout.category_search_prefix", cfg.layout.category_search_prefix);
@@ -41,6 +59,12 @@ fn writeConfig(allocator: schema.Allocator, buf: *std.array_list.Managed(u8), cf
     try writeBool(buf, "layout.sort_process_list_running_first", cfg.layout.sort_process_list_running_first);
     try writeLine(buf, "layout.placeholder_banner", cfg.layout.placeholder_banner);
     try writeBool(buf, "layout.enable_debug_process_info", cfg.layout.enable_debug_process_info);
+    try writeLine(buf, "layout.details_pane", cfg.layout.details_pane);
+    try writeInt(buf, "layout.unified_client_ratio", cfg.layout.unified_client_ratio);
+    try writeInt(buf, "layout.selection_switch_debounce_ms", cfg.layout.selection_switch_debounce_ms);
+    try writeInt(buf, "layout.max_output_fps", cfg.layout.max_output_fps);
+    try writeInt(buf, "layout.max_relay_bytes_per_tick", cfg.layout.max_relay_bytes_per_tick);
+    try writeLine(buf, "layout.last_line_preview", cfg.layout.last_line_preview);
 
     try writeLine(buf, "style.selected_process_color", cfg.style.selected_process_color);
     try writeLine(buf, "style.selected_process_bg_color", cfg.style.selected_process_bg_color);
@@ -49,12 +73,61 @@ fn writeConfig(allocator: schema.Allocator, buf: *std.array_list.Managed(u8), cf
     try writeLine(buf, "style.status_halting_color", cfg.style.status_halting_color);
     try writeLine(buf, "style.status_stopped_color", cfg.style.status_stopped_color);
     try writeLine(buf, "style.pointer_char", cfg.style.pointer_char);
+    try writeLine(buf, "style.placeholder_banner_color", cfg.style.placeholder_banner_color);
+    try writeLine(buf, "style.warning_color", cfg.style.warning_color);
+    try writeLine(buf, "style.unread_output_color", cfg.style.unread_output_color);
+    try writeLine(buf, "style.icon_set", cfg.style.icon_set);
+    try writeLine(buf, "style.status_running_icon", cfg.style.status_running_icon);
+    try writeLine(buf, "style.status_halting_icon", cfg.style.status_halting_icon);
+    try writeLine(buf, "style.status_stopped_icon", cfg.style.status_stopped_icon);
+    try writeLine(buf, "style.status_failed_icon", cfg.style.status_failed_icon);
+    try writeLine(buf, "style.status_succeeded_icon", cfg.style.status_succeeded_icon);
+    try writeLine(buf, "style.status_unhealthy_icon", cfg.style.status_unhealthy_icon);
+    try writeCategoryColors(buf, "style.category_colors", cfg.style.category_colors);
+    try writeLine(buf, "style.category_color_target", cfg.style.category_color_target);
+    try writeLine(buf, "theme", cfg.theme);
+    try writeLine(buf, "background", cfg.background);
+    inline for (std.meta.fields(schema.StyleConfig)) |field| {
+        if (field.type == []const u8) try writeLine(buf, "light_style." ++ field.name, @field(cfg.light_style, field.name));
+    }
+    try writeCategoryColors(buf, "light_style.category_colors", cfg.light_style.category_colors);
+    try writeInt(buf, "views#len", @intCast(cfg.views.items.len));
+    for (cfg.views.items) |view| {
+        try writeLine(buf, "view.name", view.name);
+        try writeLine(buf, "view.filter", view.filter);
+        try writeBool(buf, "view.running_only", view.running_only);
+    }
 
     try writeBool(buf, "general.procs_from_make_targets", cfg.general.procs_from_make_targets);
     try writeBool(buf, "general.procs_from_package_json", cfg.general.procs_from_package_json);
+    try writeLine(buf, "general.on_quit", cfg.general.on_quit);
+    try writeLine(buf, "general.on_bell", cfg.general.on_bell);
+    try writeInt(buf, "general.refresh_interval_ms", cfg.general.refresh_interval_ms);
+    try writeInt(buf, "general.output_poll_interval_ms", cfg.general.output_poll_interval_ms);
+    try writeInt(buf, "general.watch_poll_interval_ms", cfg.general.watch_poll_interval_ms);
+    try writeInt(buf, "general.watch_debounce_ms", cfg.general.watch_debounce_ms);
+    try writeBool(buf, "security.token", cfg.security.token);
     try writeStringList(buf, "shell_cmd", cfg.shell_cmd);
     try writeLine(buf, "log_file", cfg.log_file);
     try writeLine(buf, "stdout_debug_log_file", cfg.stdout_debug_log_file);
+    try writeLine(buf, "log_level", cfg.log_level);
+    try writeLine(buf, "log_format", cfg.log_format);
+    try writeInt(buf, "log_max_size_mb", cfg.log_max_size_mb);
+    try writeInt(buf, "log_max_backups", cfg.log_max_backups);
+    try writeBool(buf, "log_compress", cfg.log_compress);
+    try writeInt(buf, "shutdown_timeout_ms", cfg.shutdown_timeout_ms);
+    try writeBool(buf, "auto_shutdown", cfg.auto_shutdown);
+    try writeInt(buf, "autostart_stagger_ms", cfg.autostart_stagger_ms);
+    try writeLine(buf, "metrics_addr", cfg.metrics_addr);
+    try writeLine(buf, "plugins_dir", cfg.plugins_dir);
+    try writeInt(buf, "plugin_timeout_ms", cfg.plugin_timeout_ms);
+    try writeStringList(buf, "error_patterns", cfg.error_patterns);
+    try writeStringList(buf, "redact_patterns", cfg.redact_patterns);
+    try writeStringList(buf, "clipboard_cmd", cfg.clipboard_cmd);
+    try writeInt(buf, "clipboard_output_lines", cfg.clipboard_output_lines);
+    try writeStringList(buf, "open_cmd", cfg.open_cmd);
+    try writeStringList(buf, "editor_cmd", cfg.editor_cmd);
+    try writeBool(buf, "strict_vars", cfg.strict_vars);
 
     var keys = try allocator.alloc([]const u8, cfg.procs.count());
     defer allocator.free(keys);
@@ -75,18 +148,52 @@ fn writeProcess(allocator: schema.Allocator, buf: *std.array_list.Managed(u8), l
     try writeStringList(buf, "proc.cmd", proc.cmd);
     try writeLine(buf, "proc.cwd", proc.cwd);
     try writeStringMap(allocator, buf, "proc.env", proc.env);
+    try writeBool(buf, "proc.env_clear", proc.env_clear);
+    try writeSecretMap(allocator, buf, proc.secrets);
     try writeInt(buf, "proc.stop", proc.stop);
     try writeInt(buf, "proc.stop_timeout_ms", proc.stop_timeout_ms);
     try writeBool(buf, "proc.autostart", proc.autostart);
     try writeBool(buf, "proc.autofocus", proc.autofocus);
     try writeLine(buf, "proc.description", proc.description);
     try writeLine(buf, "proc.docs", proc.docs);
+    try writeLine(buf, "proc.url", proc.url);
+    try writeBool(buf, "proc.open_url", proc.open_url);
     try writeStringList(buf, "proc.meta_tags", proc.meta_tags);
     try writeStringList(buf, "proc.categories", proc.categories);
     try writeStringList(buf, "proc.add_path", proc.add_path);
     try writeInt(buf, "proc.terminal_rows", proc.terminal_rows);
     try writeInt(buf, "proc.terminal_cols", proc.terminal_cols);
+    try writeLine(buf, "proc.term", proc.term);
+    try writeLine(buf, "proc.colorterm", proc.colorterm);
+    try writeBool(buf, "proc.force_color", proc.force_color);
+    try writeBool(buf, "proc.separate_stderr", proc.separate_stderr);
     try writeStringList(buf, "proc.on_kill", proc.on_kill);
+    try writeStringList(buf, "proc.pre_start", proc.pre_start);
+    try writeStringList(buf, "proc.post_start", proc.post_start);
+    try writeStringList(buf, "proc.pre_stop", proc.pre_stop);
+    try writeStringList(buf, "proc.post_stop", proc.post_stop);
+    try writeInt(buf, "proc.hook_timeout_ms", proc.hook_timeout_ms);
+    try writeInt(buf, "proc.idle_timeout_ms", proc.idle_timeout_ms);
+    try writeInt(buf, "proc.expect_output_within_ms", proc.expect_output_within_ms);
+    try writeInt(buf, "proc.startup_delay_ms", proc.startup_delay_ms);
+    try writeInt(buf, "proc.crash_loop_exits", proc.crash_loop_exits);
+    try writeInt(buf, "proc.crash_loop_window_ms", proc.crash_loop_window_ms);
+    try writeStringList(buf, "proc.shell_cmd", proc.shell_cmd);
+    try writeBool(buf, "proc.login_shell", proc.login_shell);
+    try writeBool(buf, "proc.interactive_shell", proc.interactive_shell);
+    try writeLine(buf, "proc.env_loader", proc.env_loader);
+    try writeStringList(buf, "proc.env_loader_cmd", proc.env_loader_cmd);
+    try writeStringList(buf, "proc.output_sinks", proc.output_sinks);
+    try writeStringList(buf, "proc.watch", proc.watch);
+    try writeStringList(buf, "proc.watch_ignore", proc.watch_ignore);
+    try writeInt(buf, "proc.watch_debounce_ms", proc.watch_debounce_ms);
+    try writeLine(buf, "proc.type", proc.@"type");
+    try writeLine(buf, "proc.image", proc.image);
+    try writeStringList(buf, "proc.ports", proc.ports);
+    try writeStringList(buf, "proc.volumes", proc.volumes);
+    try writeLine(buf, "proc.container_name", proc.container_name);
+    try writeInt(buf, "proc.replicas", proc.replicas);
+    try writeLine(buf, "proc.replica_group", proc.replica_group);
 }
 
 fn writeLine(buf: *std.array_list.Managed(u8), key: []const u8, value: []const u8) !void {
@@ -104,6 +211,13 @@ fn writeInt(buf: *std.array_list.Managed(u8), key: []const u8, value: i32) !void
     try buf.writer().print("{s}={}\n", .{ key, value });
 }
 
+fn writeCategoryColors(buf: *std.array_list.Managed(u8), key: []const u8, colors: []const schema.CategoryColor) !void {
+    try buf.writer().print("{s}#len={}\n", .{ key, colors.len });
+    for (colors) |entry| {
+        try buf.writer().print("{s}[{}:{s}]#len={}: {s}\n", .{ key, entry.category.len, entry.category, entry.color.len, entry.color });
+    }
+}
+
 fn writeStringList(buf: *std.array_list.Managed(u8), key: []const u8, list: schema.StringList) !void {
     try buf.writer().print("{s}#len={}\n", .{ key, list.items.len });
     for (list.items, 0..) |item, i| {
@@ -125,6 +239,25 @@ fn writeStringMap(allocator: schema.Allocator, buf: *std.array_list.Managed(u8),
     }
 }
 
+/// Hashes where each secret comes from; values are never resolved here.
+fn writeSecretMap(allocator: schema.Allocator, buf: *std.array_list.Managed(u8), map: schema.SecretMap) !void {
+    var keys = try allocator.alloc([]const u8, map.count());
+    defer allocator.free(keys);
+    var it = map.iterator();
+    var index: usize = 0;
+    while (it.next()) |entry| : (index += 1) keys[index] = entry.key_ptr.*;
+    std.mem.sort([]const u8, keys, {}, lessThanString);
+
+    try buf.writer().print("proc.secrets#len={}\n", .{keys.len});
+    for (keys) |name| {
+        const secret = map.get(name).?;
+        try buf.writer().print("proc.secrets.{s}\n", .{name});
+        try writeStringList(buf, "proc.secrets.cmd", secret.cmd);
+        try writeLine(buf, "proc.secrets.file", secret.file);
+        try writeStringList(buf, "proc.secrets.decrypt_cmd", secret.decrypt_cmd);
+    }
+}
+
 fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
     return std.mem.order(u8, a, b) == .lt;
 }
@@ -0,0 +1,88 @@
+//! Status marker and pointer glyph presets.
+//! `style.icon_set` picks the preset that fills every marker the config leaves empty; the ASCII preset doubles as the fallback for terminals that cannot draw anything else.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+pub const IconSet = enum {
+    default,
+    nerd,
+    ascii,
+};
+
+pub const Icons = struct {
+    pointer: []const u8,
+    running: []const u8,
+    halting: []const u8,
+    stopped: []const u8,
+    /// A run that exited on its own with a non-zero code.
+    failed: []const u8,
+    /// A run that exited on its own with code 0.
+    succeeded: []const u8,
+    /// A running process flagged as stuck by `expect_output_within_ms`.
+    unhealthy: []const u8,
+};
+
+pub const default = Icons{
+    .pointer = "▶",
+    .running = "●",
+    .halting = "◐",
+    .stopped = "■",
+    .failed = "✖",
+    .succeeded = "✔",
+    .unhealthy = "▲",
+};
+
+/// Font Awesome glyphs from the Nerd Fonts private use area.
+pub const nerd = Icons{
+    .pointer = "\u{f054}",
+    .running = "\u{f144}",
+    .halting = "\u{f252}",
+    .stopped = "\u{f28d}",
+    .failed = "\u{f057}",
+    .succeeded = "\u{f058}",
+    .unhealthy = "\u{f071}",
+};
+
+pub const ascii = Icons{
+    .pointer = ">",
+    .running = "*",
+    .halting = "~",
+    .stopped = "-",
+    .failed = "x",
+    .succeeded = "+",
+    .unhealthy = "?",
+};
+
+pub fn preset(set: IconSet) Icons {
+    return switch (set) {
+        .default => default,
+        .nerd => nerd,
+        .ascii => ascii,
+    };
+}
+
+/// Fills the empty marker fields of `style` from its `icon_set`.
+pub fn apply(style: *schema.StyleConfig) void {
+    const set = std.meta.stringToEnum(IconSet, style.icon_set) orelse .default;
+    const icons = preset(set);
+    if (style.pointer_char.len == 0) style.pointer_char = icons.pointer;
+    if (style.status_running_icon.len == 0) style.status_running_icon = icons.running;
+    if (style.status_halting_icon.len == 0) style.status_halting_icon = icons.halting;
+    if (style.status_stopped_icon.len == 0) style.status_stopped_icon = icons.stopped;
+    if (style.status_failed_icon.len == 0) style.status_failed_icon = icons.failed;
+    if (style.status_succeeded_icon.len == 0) style.status_succeeded_icon = icons.succeeded;
+    if (style.status_unhealthy_icon.len == 0) style.status_unhealthy_icon = icons.unhealthy;
+}
+
+test "icon sets fill only the markers the style leaves empty" {
+    var style = schema.StyleConfig{ .icon_set = "ascii", .status_failed_icon = "!" };
+    apply(&style);
+    try std.testing.expectEqualStrings(">", style.pointer_char);
+    try std.testing.expectEqualStrings("*", style.status_running_icon);
+    try std.testing.expectEqualStrings("!", style.status_failed_icon);
+
+    var nerd_style = schema.StyleConfig{ .icon_set = "nerd" };
+    apply(&nerd_style);
+    try std.testing.expectEqualStrings("\u{f144}", nerd_style.status_running_icon);
+}
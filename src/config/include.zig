@@ -0,0 +1,123 @@
+//! Include pattern expansion for multi-file configs.
+//! Patterns may use `*` and `?` inside any path segment; matches are sorted so the merge order never depends on directory iteration order.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+/// Resolves `pattern` against `base_dir` into sorted file paths. A pattern
+/// without wildcards is returned as-is so a missing file fails loudly at read
+/// time, while a wildcard that matches nothing yields no paths.
+pub fn expand(allocator: schema.Allocator, base_dir: []const u8, pattern: []const u8) ![][]const u8 {
+    const full = if (std.fs.path.isAbsolute(pattern))
+        try allocator.dupe(u8, pattern)
+    else
+        try std.fs.path.join(allocator, &.{ base_dir, pattern });
+    defer allocator.free(full);
+
+    var paths = std.array_list.Managed([]const u8).init(allocator);
+    errdefer freePathList(allocator, &paths);
+
+    if (!hasWildcard(full)) {
+        try paths.append(try allocator.dupe(u8, full));
+        return paths.toOwnedSlice();
+    }
+
+    try paths.append(try allocator.dupe(u8, if (std.fs.path.isAbsolute(full)) "/" else ""));
+    var segments = std.mem.tokenizeScalar(u8, full, '/');
+    while (segments.next()) |segment| {
+        var next = std.array_list.Managed([]const u8).init(allocator);
+        errdefer freePathList(allocator, &next);
+
+        for (paths.items) |prefix| {
+            if (hasWildcard(segment)) {
+                try appendMatches(allocator, &next, prefix, segment);
+            } else {
+                try next.append(try std.fs.path.join(allocator, &.{ prefix, segment }));
+            }
+        }
+
+        freePathList(allocator, &paths);
+        paths = next;
+    }
+
+    std.mem.sort([]const u8, paths.items, {}, lessThan);
+    return paths.toOwnedSlice();
+}
+
+pub fn freePaths(allocator: schema.Allocator, paths: [][]const u8) void {
+    for (paths) |path| allocator.free(path);
+    allocator.free(paths);
+}
+
+fn appendMatches(
+    allocator: schema.Allocator,
+    out: *std.array_list.Managed([]const u8),
+    prefix: []const u8,
+    segment: []const u8,
+) !void {
+    var dir = std.fs.cwd().openDir(if (prefix.len == 0) "." else prefix, .{ .iterate = true }) catch |err| switch (err) {
+        error.FileNotFound, error.NotDir => return,
+        else => return err,
+    };
+    defer dir.close();
+
+    var it = dir.iterate();
+    while (try it.next()) |entry| {
+        // Hidden entries only match patterns that ask for them, like a shell.
+        if (entry.name[0] == '.' and segment[0] != '.') continue;
+        if (!matches(segment, entry.name)) continue;
+        try out.append(try std.fs.path.join(allocator, &.{ prefix, entry.name }));
+    }
+}
+
+/// Matches a single path segment against `*` and `?` wildcards.
+pub fn matches(pattern: []const u8, name: []const u8) bool {
+    if (pattern.len == 0) return name.len == 0;
+    return switch (pattern[0]) {
+        '*' => matches(pattern[1..], name) or (name.len > 0 and matches(pattern, name[1..])),
+        '?' => name.len > 0 and matches(pattern[1..], name[1..]),
+        else => name.len > 0 and name[0] == pattern[0] and matches(pattern[1..], name[1..]),
+    };
+}
+
+fn hasWildcard(text: []const u8) bool {
+    return std.mem.indexOfAny(u8, text, "*?") != null;
+}
+
+fn lessThan(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+
+fn freePathList(allocator: schema.Allocator, list: *std.array_list.Managed([]const u8)) void {
+    for (list.items) |path| allocator.free(path);
+    list.deinit();
+}
+
+test "include patterns match segments and sort results" {
+    try std.testing.expect(matches("*.yaml", "api.yaml"));
+    try std.testing.expect(matches("proc?mux.yml", "proctmux.yml"));
+    try std.testing.expect(!matches("*.yaml", "api.yml"));
+
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("services/web");
+    try tmp.dir.makePath("services/api");
+    try tmp.dir.makePath("services/.cache");
+    try tmp.dir.writeFile(.{ .sub_path = "services/web/proctmux.yaml", .data = "" });
+    try tmp.dir.writeFile(.{ .sub_path = "services/api/proctmux.yaml", .data = "" });
+    try tmp.dir.writeFile(.{ .sub_path = "services/.cache/proctmux.yaml", .data = "" });
+
+    const base = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(base);
+
+    const paths = try expand(std.testing.allocator, base, "services/*/proctmux.yaml");
+    defer freePaths(std.testing.allocator, paths);
+
+    try std.testing.expectEqual(@as(usize, 2), paths.len);
+    try std.testing.expect(std.mem.endsWith(u8, paths[0], "services/api/proctmux.yaml"));
+    try std.testing.expect(std.mem.endsWith(u8, paths[1], "services/web/proctmux.yaml"));
+
+    const none = try expand(std.testing.allocator, base, "missing/*.yaml");
+    defer freePaths(std.testing.allocator, none);
+    try std.testing.expectEqual(@as(usize, 0), none.len);
+}
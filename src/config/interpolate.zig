@@ -0,0 +1,141 @@
+//! Config string interpolation.
+//! `${NAME}` and `${NAME:-default}` resolve from a process's own `env`, then the config's `vars` section, then the proctmux process environment, so projects can parameterize YAML without a preprocessor.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+/// Where `expand` looks names up.
+pub const Scope = struct {
+    vars: *const schema.StringMap,
+    /// The process's own `env`, consulted before `vars`.
+    env: ?*const schema.StringMap = null,
+    /// Fails on names nothing defines instead of leaving them as written.
+    strict: bool = false,
+};
+
+/// Expands `${NAME}` references in `input`. A name nothing defines is left
+/// as written, so the process shell can still expand it at start, unless
+/// `scope.strict` is set; `${NAME:-default}` falls back instead, and also when
+/// the value is empty, like a POSIX shell. `$${` is an escape for a literal
+/// `${` that the process shell should see instead.
+pub fn expand(allocator: schema.Allocator, input: []const u8, scope: Scope) ![]const u8 {
+    if (std.mem.indexOf(u8, input, "${") == null) return allocator.dupe(u8, input);
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+
+    var index: usize = 0;
+    while (index < input.len) {
+        const start = std.mem.indexOfPos(u8, input, index, "${") orelse {
+            try out.appendSlice(input[index..]);
+            break;
+        };
+
+        if (start > index and input[start - 1] == '$') {
+            try out.appendSlice(input[index .. start - 1]);
+            try out.appendSlice("${");
+            index = start + 2;
+            continue;
+        }
+
+        try out.appendSlice(input[index..start]);
+        const end = std.mem.indexOfScalarPos(u8, input, start + 2, '}') orelse return error.UnterminatedInterpolation;
+        try out.appendSlice(try resolve(input[start .. end + 1], scope));
+        index = end + 1;
+    }
+
+    return out.toOwnedSlice();
+}
+
+/// Resolves one `${...}` reference, returned whole when nothing defines it.
+fn resolve(reference: []const u8, scope: Scope) ![]const u8 {
+    const expression = reference[2 .. reference.len - 1];
+    var name = expression;
+    var fallback: ?[]const u8 = null;
+    if (std.mem.indexOf(u8, expression, ":-")) |separator| {
+        name = expression[0..separator];
+        fallback = expression[separator + 2 ..];
+    }
+    if (name.len == 0) return error.InvalidInterpolation;
+
+    const found = if (scope.env) |env| env.get(name) else null;
+    const value = found orelse scope.vars.get(name) orelse std.posix.getenv(name) orelse {
+        if (fallback) |default_value| return default_value;
+        if (scope.strict) return error.UndefinedInterpolation;
+        return reference;
+    };
+    if (value.len == 0) {
+        if (fallback) |default_value| return default_value;
+    }
+    return value;
+}
+
+test "interpolation prefers vars and applies defaults" {
+    var vars = schema.StringMap.init(std.testing.allocator);
+    defer vars.deinit();
+    try vars.put("PORT", "4000");
+    try vars.put("EMPTY", "");
+
+    const expanded = try expand(
+        std.testing.allocator,
+        "serve --port ${PORT} --host ${PROCTMUX_TEST_UNSET_HOST:-localhost} --tag ${EMPTY:-dev}",
+        .{ .vars = &vars },
+    );
+    defer std.testing.allocator.free(expanded);
+
+    try std.testing.expectEqualStrings("serve --port 4000 --host localhost --tag dev", expanded);
+}
+
+test "interpolation keeps escaped references for the shell" {
+    var vars = schema.StringMap.init(std.testing.allocator);
+    defer vars.deinit();
+
+    const expanded = try expand(std.testing.allocator, "for f in *; do echo $${f}; done", .{ .vars = &vars });
+    defer std.testing.allocator.free(expanded);
+
+    try std.testing.expectEqualStrings("for f in *; do echo ${f}; done", expanded);
+}
+
+test "interpolation rejects malformed references" {
+    var vars = schema.StringMap.init(std.testing.allocator);
+    defer vars.deinit();
+
+    try std.testing.expectError(error.UnterminatedInterpolation, expand(std.testing.allocator, "echo ${PORT", .{ .vars = &vars }));
+    try std.testing.expectError(error.InvalidInterpolation, expand(std.testing.allocator, "echo ${:-x}", .{ .vars = &vars }));
+}
+
+test "interpolation resolves the process env before vars" {
+    var vars = schema.StringMap.init(std.testing.allocator);
+    defer vars.deinit();
+    try vars.put("PORT", "4000");
+    try vars.put("HOST", "localhost");
+    var env = schema.StringMap.init(std.testing.allocator);
+    defer env.deinit();
+    try env.put("PORT", "5000");
+
+    const expanded = try expand(std.testing.allocator, "serve --port ${PORT} --host ${HOST}", .{ .vars = &vars, .env = &env });
+    defer std.testing.allocator.free(expanded);
+
+    try std.testing.expectEqualStrings("serve --port 5000 --host localhost", expanded);
+}
+
+test "interpolation leaves undefined names for the shell unless strict" {
+    var vars = schema.StringMap.init(std.testing.allocator);
+    defer vars.deinit();
+
+    const expanded = try expand(
+        std.testing.allocator,
+        "echo ${PROCTMUX_TEST_UNSET_NAME} ${PROCTMUX_TEST_UNSET_MODE:-dev}",
+        .{ .vars = &vars },
+    );
+    defer std.testing.allocator.free(expanded);
+    try std.testing.expectEqualStrings("echo ${PROCTMUX_TEST_UNSET_NAME} dev", expanded);
+
+    try std.testing.expectError(
+        error.UndefinedInterpolation,
+        expand(std.testing.allocator, "echo ${PROCTMUX_TEST_UNSET_NAME}", .{ .vars = &vars, .strict = true }),
+    );
+    const defaulted = try expand(std.testing.allocator, "echo ${PROCTMUX_TEST_UNSET_MODE:-dev}", .{ .vars = &vars, .strict = true });
+    defer std.testing.allocator.free(defaulted);
+    try std.testing.expectEqualStrings("echo dev", defaulted);
+}
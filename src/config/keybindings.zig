@@ -0,0 +1,129 @@
+//! Keybinding precedence and conflict detection.
+//! The TUI tests bindings in a fixed order, so a key bound to several actions always triggers the earliest; this module owns that order and reports each key that shadows a later action.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+pub const Action = enum {
+    focus_client,
+    focus_server,
+    rotate_split,
+    grow_client,
+    shrink_client,
+    toggle_focus,
+    filter,
+    submit_filter,
+    down,
+    up,
+    toggle_running,
+    cycle_view,
+    cycle_sort,
+    toggle_pin,
+    toggle_mark,
+    start,
+    stop,
+    restart,
+    toggle_help,
+    toggle_messages,
+    jump_to_error,
+    copy_command,
+    copy_pid,
+    copy_cwd,
+    copy_output,
+    open_url,
+    open_cwd,
+    show_env,
+    quit,
+    docs,
+};
+
+/// Split keys are handled by unified mode before the process list sees them.
+const split_actions = [_]Action{ .focus_client, .focus_server, .rotate_split, .grow_client, .shrink_client, .toggle_focus };
+
+/// Precedence while browsing the process list, earliest first.
+pub const normal_order = split_actions ++ [_]Action{ .filter, .down, .up, .toggle_running, .cycle_view, .cycle_sort, .toggle_pin, .toggle_mark, .start, .stop, .restart, .toggle_help, .toggle_messages, .jump_to_error, .copy_command, .copy_pid, .copy_cwd, .copy_output, .open_url, .open_cwd, .show_env, .quit, .docs };
+
+/// Precedence while typing a filter; every other key becomes filter text.
+pub const filter_order = split_actions ++ [_]Action{ .submit_filter, .filter };
+
+/// A key bound to both actions; `shadowed` never fires in the mode where
+/// both are active.
+pub const Conflict = struct {
+    key: []const u8,
+    winner: Action,
+    shadowed: Action,
+};
+
+pub fn bindings(keybinding: *const schema.KeybindingConfig, action: Action) []const []const u8 {
+    return switch (action) {
+        inline else => |tag| @field(keybinding, @tagName(tag)).items,
+    };
+}
+
+/// Lists every shadowed binding in precedence order. Keys in the returned
+/// conflicts are borrowed from `keybinding`.
+pub fn conflicts(allocator: std.mem.Allocator, keybinding: *const schema.KeybindingConfig) ![]Conflict {
+    var found = std.array_list.Managed(Conflict).init(allocator);
+    errdefer found.deinit();
+    try collect(&found, keybinding, &normal_order);
+    try collect(&found, keybinding, &filter_order);
+    return found.toOwnedSlice();
+}
+
+fn collect(found: *std.array_list.Managed(Conflict), keybinding: *const schema.KeybindingConfig, order: []const Action) !void {
+    for (order, 0..) |action, index| {
+        for (bindings(keybinding, action)) |key| {
+            const winner = for (order[0..index]) |earlier| {
+                if (contains(bindings(keybinding, earlier), key)) break earlier;
+            } else continue;
+            if (alreadyFound(found.items, key, winner, action)) continue;
+            try found.append(.{ .key = key, .winner = winner, .shadowed = action });
+        }
+    }
+}
+
+fn alreadyFound(found: []const Conflict, key: []const u8, winner: Action, shadowed: Action) bool {
+    for (found) |conflict| {
+        if (conflict.winner == winner and conflict.shadowed == shadowed and std.mem.eql(u8, conflict.key, key)) return true;
+    }
+    return false;
+}
+
+fn contains(keys: []const []const u8, key: []const u8) bool {
+    for (keys) |candidate| {
+        if (std.mem.eql(u8, candidate, key)) return true;
+    }
+    return false;
+}
+
+test "default keybindings do not conflict" {
+    var cfg = schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try @import("defaults.zig").apply(&cfg, std.testing.allocator);
+
+    const found = try conflicts(std.testing.allocator, &cfg.keybinding);
+    defer std.testing.allocator.free(found);
+    try std.testing.expectEqual(@as(usize, 0), found.len);
+}
+
+test "conflicts name the earlier action as the winner" {
+    var keybinding = schema.KeybindingConfig.empty(std.testing.allocator);
+    defer keybinding.deinit();
+    try schema.appendOwned(std.testing.allocator, &keybinding.quit, "q");
+    try schema.appendOwned(std.testing.allocator, &keybinding.start, "q");
+    try schema.appendOwned(std.testing.allocator, &keybinding.filter, "/");
+    try schema.appendOwned(std.testing.allocator, &keybinding.submit_filter, "/");
+    try schema.appendOwned(std.testing.allocator, &keybinding.toggle_focus, "tab");
+    try schema.appendOwned(std.testing.allocator, &keybinding.submit_filter, "tab");
+
+    const found = try conflicts(std.testing.allocator, &keybinding);
+    defer std.testing.allocator.free(found);
+    try std.testing.expectEqual(@as(usize, 3), found.len);
+    try std.testing.expectEqualStrings("q", found[0].key);
+    try std.testing.expectEqual(Action.start, found[0].winner);
+    try std.testing.expectEqual(Action.quit, found[0].shadowed);
+    try std.testing.expectEqual(Action.toggle_focus, found[1].winner);
+    try std.testing.expectEqual(Action.submit_filter, found[1].shadowed);
+    try std.testing.expectEqual(Action.submit_filter, found[2].winner);
+    try std.testing.expectEqual(Action.filter, found[2].shadowed);
+}
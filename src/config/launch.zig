@@ -0,0 +1,250 @@
+//! Launch-time process selection.
+//! CLI flags such as `--formation`, `--profile`, and `--set` reshape the loaded config, so a run can differ from the config file without editing it.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+/// Selection requested on the command line. The zero value leaves the config
+/// as written.
+pub const Options = struct {
+    /// Foreman-style `name=count` pairs, e.g. `web=2,worker=3`.
+    formation: []const u8 = "",
+    /// A `profiles` entry naming the processes to load.
+    profile: []const u8 = "",
+    /// Comma-separated processes to load in addition to the profile's.
+    only: []const u8 = "",
+    /// Comma-separated processes to leave out.
+    except: []const u8 = "",
+    /// `dotted.path=value` overrides merged over the config file before it
+    /// is decoded; see `load`.
+    set: []const []const u8 = &.{},
+    /// `KEY=VALUE` variables set in every process's `env`.
+    env: []const []const u8 = &.{},
+
+    pub fn isEmpty(self: Options) bool {
+        return self.formation.len == 0 and !self.selects() and self.set.len == 0 and self.env.len == 0;
+    }
+
+    fn selects(self: Options) bool {
+        return self.profile.len > 0 or self.only.len > 0 or self.except.len > 0;
+    }
+};
+
+/// Applies the formation to freshly decoded procs, before replica expansion.
+pub fn apply(procs: *schema.ProcessMap, options: Options) !void {
+    if (options.formation.len > 0) try applyFormation(procs, options.formation);
+}
+
+/// Autostarts each named process with `count` replicas. Processes the
+/// formation leaves out, or gives a count of 0, stay in the list but do not
+/// autostart; `all=<count>` sets the count for them instead.
+pub fn applyFormation(procs: *schema.ProcessMap, formation: []const u8) !void {
+    var all: i32 = 0;
+    var entries = std.mem.splitScalar(u8, formation, ',');
+    while (entries.next()) |entry| {
+        const parsed = try parseEntry(entry) orelse continue;
+        if (std.mem.eql(u8, parsed.name, "all")) {
+            all = parsed.count;
+        } else if (!procs.contains(parsed.name)) {
+            return error.UnknownFormationProcess;
+        }
+    }
+
+    var it = procs.iterator();
+    while (it.next()) |proc_entry| {
+        const count = countFor(formation, proc_entry.key_ptr.*) orelse all;
+        const proc = proc_entry.value_ptr;
+        proc.autostart = count > 0;
+        if (count > 0) proc.replicas = count;
+    }
+}
+
+/// Drops every process that `profile`, `only`, and `except` leave out. Names
+/// match a process label or the label its replicas were expanded from; an
+/// unknown name fails. This runs after Discovery so discovered processes can
+/// be selected too.
+pub fn select(cfg: *schema.Config, options: Options) !void {
+    if (!options.selects()) return;
+
+    var profile_labels: []const []const u8 = &.{};
+    if (options.profile.len > 0) {
+        const labels = cfg.profiles.getPtr(options.profile) orelse return error.UnknownProfile;
+        profile_labels = labels.items;
+    }
+    for (profile_labels) |name| try requireProcess(cfg, name);
+    var only = nameIterator(options.only);
+    while (only.next()) |name| try requireProcess(cfg, name);
+    var except = nameIterator(options.except);
+    while (except.next()) |name| try requireProcess(cfg, name);
+
+    const restricted = options.profile.len > 0 or options.only.len > 0;
+    var index: usize = 0;
+    while (index < cfg.procs.count()) {
+        const label = cfg.procs.keys()[index];
+        var proc = cfg.procs.values()[index];
+        var keep = !restricted or listSelects(profile_labels, label, &proc) or namesSelect(options.only, label, &proc);
+        if (namesSelect(options.except, label, &proc)) keep = false;
+        if (keep) {
+            index += 1;
+            continue;
+        }
+
+        cfg.procs.orderedRemoveAt(index);
+        cfg.allocator.free(label);
+        proc.deinit(cfg.allocator);
+    }
+}
+
+/// Sets each `KEY=VALUE` of `env` in every process, over what the config
+/// gives it. Runs after Discovery so discovered processes get them too.
+pub fn injectEnv(cfg: *schema.Config, env: []const []const u8) !void {
+    for (env) |entry| {
+        const eq = std.mem.indexOfScalar(u8, entry, '=') orelse return error.InvalidOverride;
+        if (eq == 0) return error.InvalidOverride;
+        for (cfg.procs.values()) |*proc| {
+            try schema.putOwnedString(cfg.allocator, &proc.env, entry[0..eq], entry[eq + 1 ..]);
+        }
+    }
+}
+
+fn requireProcess(cfg: *const schema.Config, name: []const u8) !void {
+    var it = cfg.procs.iterator();
+    while (it.next()) |entry| {
+        if (matches(name, entry.key_ptr.*, entry.value_ptr)) return;
+    }
+    return error.UnknownSelectedProcess;
+}
+
+fn matches(name: []const u8, label: []const u8, proc: *const schema.ProcessConfig) bool {
+    return std.mem.eql(u8, name, label) or std.mem.eql(u8, name, proc.replica_group);
+}
+
+fn listSelects(names: []const []const u8, label: []const u8, proc: *const schema.ProcessConfig) bool {
+    for (names) |name| {
+        if (matches(name, label, proc)) return true;
+    }
+    return false;
+}
+
+fn namesSelect(list: []const u8, label: []const u8, proc: *const schema.ProcessConfig) bool {
+    var names = nameIterator(list);
+    while (names.next()) |name| {
+        if (matches(name, label, proc)) return true;
+    }
+    return false;
+}
+
+/// Labels may contain spaces, so only commas separate names.
+const NameIterator = struct {
+    parts: std.mem.SplitIterator(u8, .scalar),
+
+    fn next(self: *NameIterator) ?[]const u8 {
+        while (self.parts.next()) |part| {
+            const name = std.mem.trim(u8, part, " \t");
+            if (name.len > 0) return name;
+        }
+        return null;
+    }
+};
+
+fn nameIterator(list: []const u8) NameIterator {
+    return .{ .parts = std.mem.splitScalar(u8, list, ',') };
+}
+
+const Entry = struct {
+    name: []const u8,
+    count: i32,
+};
+
+fn parseEntry(raw: []const u8) !?Entry {
+    const entry = std.mem.trim(u8, raw, " \t");
+    if (entry.len == 0) return null;
+    const eq = std.mem.indexOfScalar(u8, entry, '=') orelse return error.InvalidFormation;
+    const name = std.mem.trim(u8, entry[0..eq], " \t");
+    if (name.len == 0) return error.InvalidFormation;
+    const count = std.fmt.parseInt(i32, std.mem.trim(u8, entry[eq + 1 ..], " \t"), 10) catch return error.InvalidFormation;
+    if (count < 0) return error.InvalidFormation;
+    return .{ .name = name, .count = count };
+}
+
+fn countFor(formation: []const u8, label: []const u8) ?i32 {
+    var entries = std.mem.splitScalar(u8, formation, ',');
+    while (entries.next()) |entry| {
+        const parsed = (parseEntry(entry) catch null) orelse continue;
+        if (std.mem.eql(u8, parsed.name, label)) return parsed.count;
+    }
+    return null;
+}
+
+test "formation sets autostart and replica counts" {
+    var arena = std.heap.ArenaAllocator.init(std.testing.allocator);
+    defer arena.deinit();
+    const allocator = arena.allocator();
+
+    var procs = schema.ProcessMap.init(allocator);
+    for ([_][]const u8{ "web", "worker", "docs" }) |label| {
+        var proc = schema.ProcessConfig.empty(allocator);
+        proc.autostart = true;
+        try procs.put(label, proc);
+    }
+
+    try applyFormation(&procs, "web=2, worker=1");
+    try std.testing.expect(procs.get("web").?.autostart);
+    try std.testing.expectEqual(@as(i32, 2), procs.get("web").?.replicas);
+    try std.testing.expect(procs.get("worker").?.autostart);
+    try std.testing.expect(!procs.get("docs").?.autostart);
+
+    try applyFormation(&procs, "all=1,web=0");
+    try std.testing.expect(!procs.get("web").?.autostart);
+    try std.testing.expect(procs.get("docs").?.autostart);
+
+    try std.testing.expectError(error.UnknownFormationProcess, applyFormation(&procs, "api=1"));
+    try std.testing.expectError(error.InvalidFormation, applyFormation(&procs, "web"));
+    try std.testing.expectError(error.InvalidFormation, applyFormation(&procs, "web=-1"));
+}
+
+test "injected env overrides every process's env" {
+    var cfg = schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    for ([_][]const u8{ "api", "web" }) |label| {
+        var proc = schema.ProcessConfig.empty(std.testing.allocator);
+        try schema.putOwnedString(std.testing.allocator, &proc.env, "DEBUG", "0");
+        try cfg.procs.put(try std.testing.allocator.dupe(u8, label), proc);
+    }
+
+    try injectEnv(&cfg, &.{ "DEBUG=1", "TRACE=a=b" });
+    for (cfg.procs.values()) |proc| {
+        try std.testing.expectEqualStrings("1", proc.env.get("DEBUG").?);
+        try std.testing.expectEqualStrings("a=b", proc.env.get("TRACE").?);
+    }
+    try std.testing.expectError(error.InvalidOverride, injectEnv(&cfg, &.{"=1"}));
+}
+
+test "selection keeps profile and only processes minus except" {
+    var cfg = schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    for ([_][]const u8{ "api", "db", "frontend", "worker-1", "worker-2" }) |label| {
+        var proc = schema.ProcessConfig.empty(std.testing.allocator);
+        if (std.mem.startsWith(u8, label, "worker")) {
+            proc.owns_scalar_strings = true;
+            proc.replica_group = try std.testing.allocator.dupe(u8, "worker");
+        }
+        try cfg.procs.put(try std.testing.allocator.dupe(u8, label), proc);
+    }
+    var backend = schema.StringList.init(std.testing.allocator);
+    try schema.appendOwned(std.testing.allocator, &backend, "api");
+    try schema.appendOwned(std.testing.allocator, &backend, "worker");
+    try cfg.profiles.put(try std.testing.allocator.dupe(u8, "backend"), backend);
+
+    try std.testing.expectError(error.UnknownProfile, select(&cfg, .{ .profile = "mobile" }));
+    try std.testing.expectError(error.UnknownSelectedProcess, select(&cfg, .{ .only = "api, cache" }));
+
+    try select(&cfg, .{ .profile = "backend", .only = "db", .except = "worker-2" });
+    try std.testing.expectEqual(@as(usize, 3), cfg.procs.count());
+    try std.testing.expect(cfg.procs.contains("api"));
+    try std.testing.expect(cfg.procs.contains("db"));
+    try std.testing.expect(cfg.procs.contains("worker-1"));
+
+    try select(&cfg, .{ .except = "db" });
+    try std.testing.expect(!cfg.procs.contains("db"));
+}
@@ -5,11 +5,36 @@ const std = @import("std");
 const yaml_mod = @import("yaml");
 const schema = @import("schema.zig");
 const defaults = @import("defaults.zig");
+const interpolate = @import("interpolate.zig");
+const include = @import("include.zig");
+const launch = @import("launch.zig");
+const icons = @import("icons.zig");
+const keybindings = @import("keybindings.zig");
+const themes = @import("themes.zig");
 
 const Yaml = yaml_mod.Yaml;
 const Value = Yaml.Value;
 const Map = Yaml.Map;
 
+/// Bounds `extends` chains between templates so a cycle fails loading instead
+/// of recursing forever.
+const max_template_depth = 16;
+
+/// Bounds nested includes; a file that includes itself is caught earlier by the
+/// include stack.
+const max_include_depth = 16;
+
+/// Root-config state threaded through included files. Includes contribute
+/// procs only, so they share the root's templates and vars.
+const IncludeContext = struct {
+    templates: ?Value,
+    scope: interpolate.Scope,
+    stack: *std.array_list.Managed([]const u8),
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+    scratch_allocator: schema.Allocator,
+};
+
 pub const LoadedConfig = struct {
     parent_allocator: schema.Allocator,
     arena: *std.heap.ArenaAllocator,
@@ -36,6 +61,15 @@ pub fn loadFile(allocator: schema.Allocator, path: []const u8) !LoadedConfig {
 }
 
 pub fn loadFileInDir(allocator: schema.Allocator, dir: std.fs.Dir, path: []const u8) !LoadedConfig {
+    return loadFileInDirWithOptions(allocator, dir, path, .{});
+}
+
+pub fn loadFileInDirWithOptions(
+    allocator: schema.Allocator,
+    dir: std.fs.Dir,
+    path: []const u8,
+    options: launch.Options,
+) !LoadedConfig {
     const data = dir.readFileAlloc(allocator, path, 1024 * 1024) catch |err| switch (err) {
         error.FileNotFound => return error.FileNotFound,
         else => return err,
@@ -45,7 +79,22 @@ pub fn loadFileInDir(allocator: schema.Allocator, dir: std.fs.Dir, path: []const
     const absolute_path = try dir.realpathAlloc(allocator, path);
     defer allocator.free(absolute_path);
 
-    return loadFromSlice(allocator, data, absolute_path);
+    return loadFromSliceWithOptions(allocator, data, absolute_path, options);
+}
+
+/// Returned when no `-f` path was given and the directory has none of
+/// `default_file_names`, so callers can offer another way to pick a config.
+pub const NotFoundError = error{ConfigFileNotFound};
+
+pub const default_file_names = [_][]const u8{ "proctmux.yaml", "proctmux.yml", "procmux.yaml", "procmux.yml" };
+
+/// Whether `dir` has a file `loadDefaultInDir` would load.
+pub fn hasDefaultFile(dir: std.fs.Dir) bool {
+    for (default_file_names) |name| {
+        dir.access(name, .{}) catch continue;
+        return true;
+    }
+    return false;
 }
 
 pub fn loadDefault(allocator: schema.Allocator) !LoadedConfig {
@@ -53,19 +102,33 @@ pub fn loadDefault(allocator: schema.Allocator) !LoadedConfig {
 }
 
 pub fn loadDefaultInDir(allocator: schema.Allocator, dir: std.fs.Dir) !LoadedConfig {
-    const paths = [_][]const u8{ "proctmux.yaml", "proctmux.yml", "procmux.yaml", "procmux.yml" };
-    for (paths) |path| {
-        return loadFileInDir(allocator, dir, path) catch |err| switch (err) {
+    return loadDefaultInDirWithOptions(allocator, dir, .{});
+}
+
+pub fn loadDefaultInDirWithOptions(allocator: schema.Allocator, dir: std.fs.Dir, options: launch.Options) !LoadedConfig {
+    for (default_file_names) |path| {
+        return loadFileInDirWithOptions(allocator, dir, path, options) catch |err| switch (err) {
             error.FileNotFound => continue,
             else => return err,
         };
     }
-    return error.ConfigFileNotFound;
+    return NotFoundError.ConfigFileNotFound;
 }
 
 /// Parses YAML into an owned Project Config plus non-fatal warnings. Ownership
 /// transfer happens here so callers can deinit the result without YAML context.
 pub fn loadFromSlice(allocator: schema.Allocator, source: []const u8, source_path: []const u8) !LoadedConfig {
+    return loadFromSliceWithOptions(allocator, source, source_path, .{});
+}
+
+/// Loads like `loadFromSlice`, then reshapes procs with launch `options`
+/// before replicas expand.
+pub fn loadFromSliceWithOptions(
+    allocator: schema.Allocator,
+    source: []const u8,
+    source_path: []const u8,
+    options: launch.Options,
+) !LoadedConfig {
     const arena = try allocator.create(std.heap.ArenaAllocator);
     errdefer allocator.destroy(arena);
     arena.* = std.heap.ArenaAllocator.init(allocator);
@@ -79,7 +142,9 @@ pub fn loadFromSlice(allocator: schema.Allocator, source: []const u8, source_pat
     errdefer deinitWarnings(allocator, &warnings);
 
     const trimmed = std.mem.trim(u8, source, " \t\r\n");
-    if (std.mem.eql(u8, trimmed, "{}")) {
+    const empty_document = std.mem.eql(u8, trimmed, "{}");
+    if (empty_document and options.set.len == 0) {
+        try launch.apply(&cfg.procs, options);
         try defaults.apply(&cfg, arena_allocator);
         cfg.file_path = try arena_allocator.dupe(u8, source_path);
         return .{
@@ -93,13 +158,16 @@ pub fn loadFromSlice(allocator: schema.Allocator, source: []const u8, source_pat
     var yml: Yaml = .{ .source = source };
     defer yml.deinit(allocator);
 
-    yml.load(allocator) catch |err| switch (err) {
+    if (!empty_document) yml.load(allocator) catch |err| switch (err) {
         error.ParseFailure => return error.ParseFailure,
         else => return err,
     };
+    try applyOverrides(allocator, &yml, options.set);
 
-    try decodeDocument(arena_allocator, &cfg, &warnings, yml, allocator);
+    try decodeDocument(arena_allocator, &cfg, &warnings, yml, source_path, allocator, options);
+    try themes.apply(&cfg);
     try defaults.apply(&cfg, arena_allocator);
+    try addKeybindingWarnings(allocator, &warnings, &cfg.keybinding);
     cfg.file_path = try arena_allocator.dupe(u8, source_path);
 
     return .{
@@ -110,6 +178,56 @@ pub fn loadFromSlice(allocator: schema.Allocator, source: []const u8, source_pat
     };
 }
 
+/// Merges `--set` overrides into the parsed document, so they are decoded and
+/// validated like the file's own values. Each is `dotted.path=value`; missing
+/// maps along the path are created. A value in `[...]` or `{...}` is parsed as
+/// YAML flow syntax, anything else is taken as a plain string.
+fn applyOverrides(allocator: schema.Allocator, yml: *Yaml, overrides: []const []const u8) !void {
+    if (overrides.len == 0) return;
+    if (yml.docs.items.len == 0) try yml.docs.append(allocator, .empty);
+    const root = &yml.docs.items[0];
+    if (root.* == .empty) root.* = .{ .map = .empty };
+    if (root.* != .map) return error.TypeMismatch;
+
+    for (overrides) |override| {
+        const eq = std.mem.indexOfScalar(u8, override, '=') orelse return error.InvalidOverridePath;
+        var node = root;
+        var keys = std.mem.splitScalar(u8, override[0..eq], '.');
+        while (keys.next()) |key| {
+            if (key.len == 0) return error.InvalidOverridePath;
+            if (node.* != .map) {
+                node.deinit(allocator);
+                node.* = .{ .map = .empty };
+            }
+            const owned_key = try allocator.dupe(u8, key);
+            const entry = node.map.getOrPut(allocator, owned_key) catch |err| {
+                allocator.free(owned_key);
+                return err;
+            };
+            if (entry.found_existing) allocator.free(owned_key) else entry.value_ptr.* = .empty;
+            node = entry.value_ptr;
+        }
+        const value = try overrideValue(allocator, override[eq + 1 ..]);
+        node.deinit(allocator);
+        node.* = value;
+    }
+}
+
+fn overrideValue(allocator: schema.Allocator, text: []const u8) !Value {
+    const trimmed = std.mem.trim(u8, text, " \t");
+    const flow = trimmed.len > 0 and (trimmed[0] == '[' or trimmed[0] == '{');
+    if (!flow) return .{ .scalar = try allocator.dupe(u8, text) };
+
+    var parsed: Yaml = .{ .source = trimmed };
+    defer parsed.deinit(allocator);
+    parsed.load(allocator) catch return error.InvalidOverrideValue;
+    if (parsed.docs.items.len == 0) return .empty;
+    const value = parsed.docs.items[0];
+    // Ownership moves to the caller's document.
+    parsed.docs.items[0] = .empty;
+    return value;
+}
+
 fn deinitWarnings(allocator: schema.Allocator, warnings: *std.array_list.Managed(schema.Warning)) void {
     for (warnings.items) |warning| {
         allocator.free(warning.path);
@@ -132,16 +250,43 @@ fn addWarning(
     });
 }
 
+/// Conflicts are warnings, not errors: the precedence order in `keybindings`
+/// still makes every key do one predictable thing.
+fn addKeybindingWarnings(
+    allocator: schema.Allocator,
+    warnings: *std.array_list.Managed(schema.Warning),
+    keybinding: *const schema.KeybindingConfig,
+) !void {
+    const found = try keybindings.conflicts(allocator, keybinding);
+    defer allocator.free(found);
+    for (found) |conflict| {
+        var path_buffer: [64]u8 = undefined;
+        const path = try std.fmt.bufPrint(&path_buffer, "keybinding.{s}", .{@tagName(conflict.shadowed)});
+        const message = try std.fmt.allocPrint(allocator, "\"{s}\" is also bound to {s}, which takes precedence", .{ conflict.key, @tagName(conflict.winner) });
+        defer allocator.free(message);
+        try addWarning(allocator, warnings, .keybinding_conflict, path, message);
+    }
+}
+
 fn decodeDocument(
     allocator: schema.Allocator,
     cfg: *schema.Config,
     warnings: *std.array_list.Managed(schema.Warning),
     yml: Yaml,
+    source_path: []const u8,
     warning_allocator: schema.Allocator,
+    options: launch.Options,
 ) !void {
-    if (yml.docs.items.len == 0) return;
-    if (yml.docs.items[0] == .empty) return;
+    if (yml.docs.items.len == 0) return launch.apply(&cfg.procs, options);
+    if (yml.docs.items[0] == .empty) return launch.apply(&cfg.procs, options);
     var root = yml.docs.items[0].asMap() orelse return error.TypeMismatch;
+    const templates = root.get("templates");
+
+    if (root.get("strict_vars")) |value| cfg.strict_vars = try decodeBool(value);
+    var vars = schema.StringMap.init(allocator);
+    defer vars.deinit();
+    if (root.get("vars")) |value| try decodeVars(allocator, &vars, value, cfg.strict_vars);
+    const scope = interpolate.Scope{ .vars = &vars, .strict = cfg.strict_vars };
 
     var it = root.iterator();
     while (it.next()) |entry| {
@@ -152,23 +297,299 @@ fn decodeDocument(
         } else if (std.mem.eql(u8, key, "layout")) {
             try decodeLayout(allocator, &cfg.layout, value);
         } else if (std.mem.eql(u8, key, "style")) {
-            try decodeStyle(allocator, &cfg.style, value, warnings, warning_allocator);
+            try decodeStyle(allocator, &cfg.style, value, "style", warnings, warning_allocator);
+        } else if (std.mem.eql(u8, key, "theme")) {
+            cfg.theme = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "background")) {
+            if (scalar(value).len > 0 and std.meta.stringToEnum(schema.Background, scalar(value)) == null) return error.InvalidBackground;
+            cfg.background = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "themes")) {
+            try decodeThemes(allocator, &cfg.themes, value, warnings, warning_allocator);
         } else if (std.mem.eql(u8, key, "general")) {
             try decodeGeneral(allocator, &cfg.general, value, warnings, warning_allocator);
+        } else if (std.mem.eql(u8, key, "security")) {
+            try decodeSecurity(&cfg.security, value, warnings, warning_allocator);
         } else if (std.mem.eql(u8, key, "shell_cmd")) {
             try decodeStringList(allocator, &cfg.shell_cmd, value);
         } else if (std.mem.eql(u8, key, "log_file")) {
             cfg.log_file = try dupeString(allocator, value);
         } else if (std.mem.eql(u8, key, "stdout_debug_log_file")) {
             cfg.stdout_debug_log_file = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "log_level")) {
+            if (scalar(value).len > 0 and std.meta.stringToEnum(schema.LogLevel, scalar(value)) == null) return error.InvalidLogLevel;
+            cfg.log_level = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "log_format")) {
+            if (scalar(value).len > 0 and std.meta.stringToEnum(schema.LogFormat, scalar(value)) == null) return error.InvalidLogFormat;
+            cfg.log_format = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "log_max_size_mb")) {
+            cfg.log_max_size_mb = try decodeInt(value);
+        } else if (std.mem.eql(u8, key, "log_max_backups")) {
+            cfg.log_max_backups = try decodeInt(value);
+            if (cfg.log_max_backups < 0) return error.InvalidLogMaxBackups;
+        } else if (std.mem.eql(u8, key, "log_compress")) {
+            cfg.log_compress = try decodeBool(value);
+        } else if (std.mem.eql(u8, key, "shutdown_timeout_ms")) {
+            cfg.shutdown_timeout_ms = try decodeInt(value);
+        } else if (std.mem.eql(u8, key, "auto_shutdown")) {
+            cfg.auto_shutdown = try decodeBool(value);
+        } else if (std.mem.eql(u8, key, "autostart_stagger_ms")) {
+            cfg.autostart_stagger_ms = try decodeInt(value);
+            if (cfg.autostart_stagger_ms < 0) return error.InvalidAutostartStagger;
+        } else if (std.mem.eql(u8, key, "metrics_addr")) {
+            cfg.metrics_addr = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "runtime_dir")) {
+            if (scalar(value).len > 0 and !std.fs.path.isAbsolute(scalar(value))) return error.DirectoryNotAbsolute;
+            cfg.runtime_dir = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "state_dir")) {
+            if (scalar(value).len > 0 and !std.fs.path.isAbsolute(scalar(value))) return error.DirectoryNotAbsolute;
+            cfg.state_dir = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "plugins_dir")) {
+            cfg.plugins_dir = try dupeString(allocator, value);
+        } else if (std.mem.eql(u8, key, "plugin_timeout_ms")) {
+            cfg.plugin_timeout_ms = try decodeInt(value);
+            if (cfg.plugin_timeout_ms < 0) return error.InvalidPluginTimeout;
+        } else if (std.mem.eql(u8, key, "error_patterns")) {
+            try decodeStringList(allocator, &cfg.error_patterns, value);
+        } else if (std.mem.eql(u8, key, "redact_patterns")) {
+            try decodeStringList(allocator, &cfg.redact_patterns, value);
+        } else if (std.mem.eql(u8, key, "clipboard_cmd")) {
+            try decodeStringList(allocator, &cfg.clipboard_cmd, value);
+        } else if (std.mem.eql(u8, key, "clipboard_output_lines")) {
+            cfg.clipboard_output_lines = try decodeInt(value);
+            if (cfg.clipboard_output_lines < 0) return error.InvalidClipboardOutputLines;
+        } else if (std.mem.eql(u8, key, "open_cmd")) {
+            try decodeStringList(allocator, &cfg.open_cmd, value);
+        } else if (std.mem.eql(u8, key, "editor_cmd")) {
+            try decodeStringList(allocator, &cfg.editor_cmd, value);
         } else if (std.mem.eql(u8, key, "procs")) {
-            try decodeProcs(allocator, &cfg.procs, value, warnings, warning_allocator);
+            try decodeProcs(allocator, &cfg.procs, value, templates, scope, null, warnings, warning_allocator);
+        } else if (std.mem.eql(u8, key, "profiles")) {
+            try decodeProfiles(allocator, &cfg.profiles, value);
+        } else if (std.mem.eql(u8, key, "views")) {
+            try decodeViews(allocator, &cfg.views, value, warnings, warning_allocator);
+        } else if (std.mem.eql(u8, key, "templates")) {
+            if (value.asMap() == null) return error.TypeMismatch;
+        } else if (std.mem.eql(u8, key, "category_output_sinks")) {
+            // Applied to procs once includes are merged.
+        } else if (std.mem.eql(u8, key, "vars")) {
+            // Decoded before procs so interpolation sees every entry.
+        } else if (std.mem.eql(u8, key, "strict_vars")) {
+            // Decoded before vars, which it also applies to.
+        } else if (std.mem.eql(u8, key, "include")) {
+            // Decoded after the root procs so merge order is fixed.
         } else if (isDeadTopLevel(key)) {
             try addWarning(warning_allocator, warnings, .dead_field, key, "dead config field ignored");
         } else {
             try addWarning(warning_allocator, warnings, .unknown_field, key, "unknown config field ignored");
         }
     }
+
+    if (root.get("include")) |value| {
+        var stack = std.array_list.Managed([]const u8).init(warning_allocator);
+        defer {
+            for (stack.items) |path| warning_allocator.free(path);
+            stack.deinit();
+        }
+        try stack.append(std.fs.cwd().realpathAlloc(warning_allocator, source_path) catch try warning_allocator.dupe(u8, source_path));
+
+        const ctx = IncludeContext{
+            .templates = templates,
+            .scope = scope,
+            .stack = &stack,
+            .warnings = warnings,
+            .warning_allocator = warning_allocator,
+            .scratch_allocator = warning_allocator,
+        };
+        try decodeIncludes(allocator, &cfg.procs, value, std.fs.path.dirname(source_path) orelse ".", ctx);
+    }
+
+    try launch.apply(&cfg.procs, options);
+    try expandReplicas(allocator, &cfg.procs);
+    try resolveOutputSinks(allocator, &cfg.procs, root.get("category_output_sinks"));
+    try resolveDockerProcesses(allocator, &cfg.procs);
+}
+
+/// Replaces each process with more than one replica by `<label>-1`..`<label>-N`.
+/// Replicas keep the original label as `replica_group` and get their index in
+/// `PROCTMUX_REPLICA`. This runs before the passes that derive names from the
+/// label, so sinks and containers are per replica.
+fn expandReplicas(allocator: schema.Allocator, procs: *schema.ProcessMap) !void {
+    var expanded = schema.ProcessMap.init(allocator);
+    errdefer expanded.deinit();
+
+    var it = procs.iterator();
+    while (it.next()) |entry| {
+        const label = entry.key_ptr.*;
+        const proc = entry.value_ptr;
+        if (proc.replicas <= 1) {
+            if (expanded.contains(label)) return error.DuplicateProcess;
+            try expanded.put(label, proc.*);
+            continue;
+        }
+
+        var index: i32 = 1;
+        while (index <= proc.replicas) : (index += 1) {
+            const replica_label = try std.fmt.allocPrint(allocator, "{s}-{d}", .{ label, index });
+            if (procs.contains(replica_label) or expanded.contains(replica_label)) return error.DuplicateProcess;
+
+            var replica = try proc.clone(allocator);
+            replica.replica_group = try allocator.dupe(u8, label);
+            if (proc.container_name.len > 0) {
+                allocator.free(replica.container_name);
+                replica.container_name = try std.fmt.allocPrint(allocator, "{s}-{d}", .{ proc.container_name, index });
+            }
+            var index_buffer: [16]u8 = undefined;
+            const index_text = std.fmt.bufPrint(&index_buffer, "{d}", .{index}) catch unreachable;
+            try schema.putOwnedString(allocator, &replica.env, "PROCTMUX_REPLICA", index_text);
+            try expanded.put(replica_label, replica);
+        }
+    }
+
+    procs.deinit();
+    procs.* = expanded;
+}
+
+/// Requires an `image` for every docker process and names its container after
+/// the label when `container_name` is unset.
+fn resolveDockerProcesses(allocator: schema.Allocator, procs: *schema.ProcessMap) !void {
+    var it = procs.iterator();
+    while (it.next()) |entry| {
+        const proc = entry.value_ptr;
+        if (!proc.isDocker()) continue;
+        if (proc.image.len == 0) return error.MissingDockerImage;
+        // `docker run` passes env through its own environment, which would
+        // have to hold the resolved values.
+        if (proc.secrets.count() > 0) return error.DockerSecretsUnsupported;
+        if (proc.container_name.len > 0) continue;
+
+        const name = try std.fmt.allocPrint(allocator, "proctmux-{s}", .{entry.key_ptr.*});
+        // Docker names allow only [a-zA-Z0-9_.-].
+        for (name) |*c| {
+            if (!std.ascii.isAlphanumeric(c.*) and c.* != '_' and c.* != '.' and c.* != '-') c.* = '-';
+        }
+        proc.container_name = name;
+    }
+}
+
+/// Expands each process's `output_sinks`, then appends the sinks configured
+/// for its categories. A category sink shared by several processes is opened
+/// once per process, so `{label}` keeps their files apart.
+fn resolveOutputSinks(allocator: schema.Allocator, procs: *schema.ProcessMap, category_sinks: ?Value) !void {
+    const by_category = if (category_sinks) |value| value.asMap() orelse return error.TypeMismatch else null;
+
+    var it = procs.iterator();
+    while (it.next()) |entry| {
+        const label = entry.key_ptr.*;
+        const proc = entry.value_ptr;
+        const first_category = if (proc.categories.items.len > 0) proc.categories.items[0] else "";
+        for (proc.output_sinks.items) |*spec| {
+            const resolved = try resolveOutputSink(allocator, spec.*, label, first_category);
+            allocator.free(spec.*);
+            spec.* = resolved;
+        }
+
+        const map = by_category orelse continue;
+        for (proc.categories.items) |category| {
+            const value = map.get(category) orelse continue;
+            if (value.asList()) |list| {
+                for (list) |item| try appendOutputSink(allocator, proc, scalar(item), label, category);
+            } else {
+                try appendOutputSink(allocator, proc, scalar(value), label, category);
+            }
+        }
+    }
+}
+
+fn appendOutputSink(
+    allocator: schema.Allocator,
+    proc: *schema.ProcessConfig,
+    spec: []const u8,
+    label: []const u8,
+    category: []const u8,
+) !void {
+    const resolved = try resolveOutputSink(allocator, spec, label, category);
+    for (proc.output_sinks.items) |existing| {
+        if (std.mem.eql(u8, existing, resolved)) {
+            allocator.free(resolved);
+            return;
+        }
+    }
+    errdefer allocator.free(resolved);
+    try proc.output_sinks.append(resolved);
+}
+
+/// Validates one `kind:target` spec and expands its placeholders. Bare
+/// `syslog` and `journald` sinks are tagged with the process label.
+fn resolveOutputSink(allocator: schema.Allocator, spec: []const u8, label: []const u8, category: []const u8) ![]const u8 {
+    const colon = std.mem.indexOfScalar(u8, spec, ':');
+    const kind_name = spec[0 .. colon orelse spec.len];
+    const kind = std.meta.stringToEnum(schema.OutputSinkKind, kind_name) orelse return error.InvalidOutputSink;
+    const target = if (colon) |index| spec[index + 1 ..] else "";
+    if (target.len == 0) {
+        if (kind == .file) return error.InvalidOutputSink;
+        return std.fmt.allocPrint(allocator, "{s}:{s}", .{ kind_name, label });
+    }
+
+    const with_label = try std.mem.replaceOwned(u8, allocator, spec, "{label}", label);
+    defer allocator.free(with_label);
+    return std.mem.replaceOwned(u8, allocator, with_label, "{category}", category);
+}
+
+/// Merges included files in list order, with each glob's matches sorted, after
+/// the including file's own procs. Nested includes follow their parent file.
+fn decodeIncludes(allocator: schema.Allocator, procs: *schema.ProcessMap, value: Value, base_dir: []const u8, ctx: IncludeContext) !void {
+    if (value.asScalar() != null) return decodeIncludePattern(allocator, procs, value, base_dir, ctx);
+    const list = value.asList() orelse return error.TypeMismatch;
+    for (list) |item| try decodeIncludePattern(allocator, procs, item, base_dir, ctx);
+}
+
+fn decodeIncludePattern(allocator: schema.Allocator, procs: *schema.ProcessMap, value: Value, base_dir: []const u8, ctx: IncludeContext) !void {
+    const pattern = try interpolate.expand(ctx.scratch_allocator, value.asScalar() orelse return error.TypeMismatch, ctx.scope);
+    defer ctx.scratch_allocator.free(pattern);
+
+    const paths = try include.expand(ctx.scratch_allocator, base_dir, pattern);
+    defer include.freePaths(ctx.scratch_allocator, paths);
+    for (paths) |path| try decodeIncludedFile(allocator, procs, path, ctx);
+}
+
+fn decodeIncludedFile(allocator: schema.Allocator, procs: *schema.ProcessMap, path: []const u8, ctx: IncludeContext) !void {
+    const real_path = try std.fs.cwd().realpathAlloc(ctx.scratch_allocator, path);
+    {
+        errdefer ctx.scratch_allocator.free(real_path);
+        for (ctx.stack.items) |seen| {
+            if (std.mem.eql(u8, seen, real_path)) return error.IncludeCycle;
+        }
+        if (ctx.stack.items.len >= max_include_depth) return error.IncludeCycle;
+        try ctx.stack.append(real_path);
+    }
+    defer ctx.scratch_allocator.free(ctx.stack.pop().?);
+
+    const data = try std.fs.cwd().readFileAlloc(ctx.scratch_allocator, real_path, 1024 * 1024);
+    defer ctx.scratch_allocator.free(data);
+
+    var yml: Yaml = .{ .source = data };
+    defer yml.deinit(ctx.scratch_allocator);
+    yml.load(ctx.scratch_allocator) catch |err| switch (err) {
+        error.ParseFailure => return error.ParseFailure,
+        else => return err,
+    };
+
+    if (yml.docs.items.len == 0) return;
+    if (yml.docs.items[0] == .empty) return;
+    var root = yml.docs.items[0].asMap() orelse return error.TypeMismatch;
+    const include_dir = std.fs.path.dirname(real_path) orelse ".";
+
+    var it = root.iterator();
+    while (it.next()) |entry| {
+        const key = entry.key_ptr.*;
+        if (std.mem.eql(u8, key, "procs")) {
+            try decodeProcs(allocator, procs, entry.value_ptr.*, ctx.templates, ctx.scope, include_dir, ctx.warnings, ctx.warning_allocator);
+        } else if (!std.mem.eql(u8, key, "include")) {
+            try addWarning(ctx.warning_allocator, ctx.warnings, .unknown_field, key, "field ignored in included config");
+        }
+    }
+
+    if (root.get("include")) |value| try decodeIncludes(allocator, procs, value, include_dir, ctx);
 }
 
 fn decodeKeybinding(allocator: schema.Allocator, cfg: *schema.KeybindingConfig, value: Value) !void {
@@ -177,7 +598,7 @@ fn decodeKeybinding(allocator: schema.Allocator, cfg: *schema.KeybindingConfig,
     while (it.next()) |entry| {
         const key = entry.key_ptr.*;
         const v = entry.value_ptr.*;
-        if (std.mem.eql(u8, key, "quit")) try decodeStringList(allocator, &cfg.quit, v) else if (std.mem.eql(u8, key, "up")) try decodeStringList(allocator, &cfg.up, v) else if (std.mem.eql(u8, key, "down")) try decodeStringList(allocator, &cfg.down, v) else if (std.mem.eql(u8, key, "start")) try decodeStringList(allocator, &cfg.start, v) else if (std.mem.eql(u8, key, "stop")) try decodeStringList(allocator, &cfg.stop, v) else if (std.mem.eql(u8, key, "restart")) try decodeStringList(allocator, &cfg.restart, v) else if (std.mem.eql(u8, key, "filter")) try decodeStringList(allocator, &cfg.filter, v) else if (std.mem.eql(u8, key, "submit_filter")) try decodeStringList(allocator, &cfg.submit_filter, v) else if (std.mem.eql(u8, key, "toggle_running")) try decodeStringList(allocator, &cfg.toggle_running, v) else if (std.mem.eql(u8, key, "toggle_help")) try decodeStringList(allocator, &cfg.toggle_help, v) else if (std.mem.eql(u8, key, "toggle_focus")) try decodeStringList(allocator, &cfg.toggle_focus, v) else if (std.mem.eql(u8, key, "focus_client")) try decodeStringList(allocator, &cfg.focus_client, v) else if (std.mem.eql(u8, key, "focus_server")) try decodeStringList(allocator, &cfg.focus_server, v) else if (std.mem.eql(u8, key, "docs")) try decodeStringList(allocator, &cfg.docs, v);
+        if (std.mem.eql(u8, key, "quit")) try decodeStringList(allocator, &cfg.quit, v) else if (std.mem.eql(u8, key, "up")) try decodeStringList(allocator, &cfg.up, v) else if (std.mem.eql(u8, key, "down")) try decodeStringList(allocator, &cfg.down, v) else if (std.mem.eql(u8, key, "start")) try decodeStringList(allocator, &cfg.start, v) else if (std.mem.eql(u8, key, "stop")) try decodeStringList(allocator, &cfg.stop, v) else if (std.mem.eql(u8, key, "restart")) try decodeStringList(allocator, &cfg.restart, v) else if (std.mem.eql(u8, key, "filter")) try decodeStringList(allocator, &cfg.filter, v) else if (std.mem.eql(u8, key, "submit_filter")) try decodeStringList(allocator, &cfg.submit_filter, v) else if (std.mem.eql(u8, key, "toggle_running")) try decodeStringList(allocator, &cfg.toggle_running, v) else if (std.mem.eql(u8, key, "toggle_help")) try decodeStringList(allocator, &cfg.toggle_help, v) else if (std.mem.eql(u8, key, "toggle_focus")) try decodeStringList(allocator, &cfg.toggle_focus, v) else if (std.mem.eql(u8, key, "focus_client")) try decodeStringList(allocator, &cfg.focus_client, v) else if (std.mem.eql(u8, key, "focus_server")) try decodeStringList(allocator, &cfg.focus_server, v) else if (std.mem.eql(u8, key, "rotate_split")) try decodeStringList(allocator, &cfg.rotate_split, v) else if (std.mem.eql(u8, key, "grow_client")) try decodeStringList(allocator, &cfg.grow_client, v) else if (std.mem.eql(u8, key, "shrink_client")) try decodeStringList(allocator, &cfg.shrink_client, v) else if (std.mem.eql(u8, key, "cycle_view")) try decodeStringList(allocator, &cfg.cycle_view, v) else if (std.mem.eql(u8, key, "cycle_sort")) try decodeStringList(allocator, &cfg.cycle_sort, v) else if (std.mem.eql(u8, key, "toggle_pin")) try decodeStringList(allocator, &cfg.toggle_pin, v) else if (std.mem.eql(u8, key, "toggle_mark")) try decodeStringList(allocator, &cfg.toggle_mark, v) else if (std.mem.eql(u8, key, "toggle_messages")) try decodeStringList(allocator, &cfg.toggle_messages, v) else if (std.mem.eql(u8, key, "docs")) try decodeStringList(allocator, &cfg.docs, v) else if (std.mem.eql(u8, key, "jump_to_error")) try decodeStringList(allocator, &cfg.jump_to_error, v) else if (std.mem.eql(u8, key, "copy_command")) try decodeStringList(allocator, &cfg.copy_command, v) else if (std.mem.eql(u8, key, "copy_pid")) try decodeStringList(allocator, &cfg.copy_pid, v) else if (std.mem.eql(u8, key, "copy_cwd")) try decodeStringList(allocator, &cfg.copy_cwd, v) else if (std.mem.eql(u8, key, "copy_output")) try decodeStringList(allocator, &cfg.copy_output, v) else if (std.mem.eql(u8, key, "open_url")) try decodeStringList(allocator, &cfg.open_url, v) else if (std.mem.eql(u8, key, "open_cwd")) try decodeStringList(allocator, &cfg.open_cwd, v) else if (std.mem.eql(u8, key, "show_env")) try decodeStringList(allocator, &cfg.show_env, v);
     }
 }
 
@@ -203,6 +624,19 @@ fn decodeLayout(allocator: schema.Allocator, cfg: *schema.LayoutConfig, value: V
             cfg.placeholder_banner = try dupeString(allocator, v);
         } else if (std.mem.eql(u8, key, "enable_debug_process_info")) {
             cfg.enable_debug_process_info = try decodeBool(v);
+        } else if (std.mem.eql(u8, key, "details_pane")) {
+            cfg.details_pane = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "unified_client_ratio")) {
+            cfg.unified_client_ratio = try decodeInt(v);
+        } else if (std.mem.eql(u8, key, "selection_switch_debounce_ms")) {
+            cfg.selection_switch_debounce_ms = try decodeInt(v);
+        } else if (std.mem.eql(u8, key, "max_output_fps")) {
+            cfg.max_output_fps = try decodeBounded(v, schema.max_output_fps_bounds);
+        } else if (std.mem.eql(u8, key, "max_relay_bytes_per_tick")) {
+            cfg.max_relay_bytes_per_tick = try decodeBounded(v, schema.max_relay_bytes_per_tick_bounds);
+        } else if (std.mem.eql(u8, key, "last_line_preview")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.LastLinePreview, scalar(v)) == null) return error.InvalidLastLinePreview;
+            cfg.last_line_preview = try dupeString(allocator, v);
         }
     }
 }
@@ -211,6 +645,7 @@ fn decodeStyle(
     allocator: schema.Allocator,
     cfg: *schema.StyleConfig,
     value: Value,
+    path_prefix: []const u8,
     warnings: *std.array_list.Managed(schema.Warning),
     warning_allocator: schema.Allocator,
 ) !void {
@@ -233,14 +668,54 @@ fn decodeStyle(
             cfg.status_stopped_color = try dupeString(allocator, v);
         } else if (std.mem.eql(u8, key, "pointer_char")) {
             cfg.pointer_char = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "placeholder_banner_color")) {
+            cfg.placeholder_banner_color = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "warning_color")) {
+            cfg.warning_color = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "unread_output_color")) {
+            cfg.unread_output_color = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "icon_set")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(icons.IconSet, scalar(v)) == null) return error.InvalidIconSet;
+            cfg.icon_set = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_running_icon")) {
+            cfg.status_running_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_halting_icon")) {
+            cfg.status_halting_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_stopped_icon")) {
+            cfg.status_stopped_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_failed_icon")) {
+            cfg.status_failed_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_succeeded_icon")) {
+            cfg.status_succeeded_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "status_unhealthy_icon")) {
+            cfg.status_unhealthy_icon = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "category_colors")) {
+            cfg.category_colors = try decodeCategoryColors(allocator, v);
+        } else if (std.mem.eql(u8, key, "category_color_target")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.CategoryColorTarget, scalar(v)) == null) return error.InvalidCategoryColorTarget;
+            cfg.category_color_target = try dupeString(allocator, v);
         } else {
-            const path = try std.fmt.allocPrint(warning_allocator, "style.{s}", .{key});
+            const path = try std.fmt.allocPrint(warning_allocator, "{s}.{s}", .{ path_prefix, key });
             defer warning_allocator.free(path);
             try addWarning(warning_allocator, warnings, if (isDeadStyleField(key)) .dead_field else .unknown_field, path, "style field ignored");
         }
     }
 }
 
+fn decodeCategoryColors(allocator: schema.Allocator, value: Value) ![]const schema.CategoryColor {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    const colors = try allocator.alloc(schema.CategoryColor, map.count());
+    var it = map.iterator();
+    var index: usize = 0;
+    while (it.next()) |entry| : (index += 1) {
+        colors[index] = .{
+            .category = try allocator.dupe(u8, entry.key_ptr.*),
+            .color = try dupeString(allocator, entry.value_ptr.*),
+        };
+    }
+    return colors;
+}
+
 fn decodeGeneral(
     allocator: schema.Allocator,
     cfg: *schema.GeneralConfig,
@@ -248,7 +723,6 @@ fn decodeGeneral(
     warnings: *std.array_list.Managed(schema.Warning),
     warning_allocator: schema.Allocator,
 ) !void {
-    _ = allocator;
     var map = value.asMap() orelse return error.TypeMismatch;
     var it = map.iterator();
     while (it.next()) |entry| {
@@ -258,6 +732,20 @@ fn decodeGeneral(
             cfg.procs_from_make_targets = try decodeBool(v);
         } else if (std.mem.eql(u8, key, "procs_from_package_json")) {
             cfg.procs_from_package_json = try decodeBool(v);
+        } else if (std.mem.eql(u8, key, "on_quit")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.QuitAction, scalar(v)) == null) return error.InvalidQuitAction;
+            cfg.on_quit = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "on_bell")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.BellAction, scalar(v)) == null) return error.InvalidBellAction;
+            cfg.on_bell = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "refresh_interval_ms")) {
+            cfg.refresh_interval_ms = try decodeBounded(v, schema.refresh_interval_bounds);
+        } else if (std.mem.eql(u8, key, "output_poll_interval_ms")) {
+            cfg.output_poll_interval_ms = try decodeBounded(v, schema.output_poll_interval_bounds);
+        } else if (std.mem.eql(u8, key, "watch_poll_interval_ms")) {
+            cfg.watch_poll_interval_ms = try decodeBounded(v, schema.watch_poll_interval_bounds);
+        } else if (std.mem.eql(u8, key, "watch_debounce_ms")) {
+            cfg.watch_debounce_ms = try decodeBounded(v, schema.watch_debounce_bounds);
         } else {
             const path = try std.fmt.allocPrint(warning_allocator, "general.{s}", .{key});
             defer warning_allocator.free(path);
@@ -266,10 +754,33 @@ fn decodeGeneral(
     }
 }
 
+fn decodeSecurity(
+    cfg: *schema.SecurityConfig,
+    value: Value,
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        const key = entry.key_ptr.*;
+        if (std.mem.eql(u8, key, "token")) {
+            cfg.token = try decodeBool(entry.value_ptr.*);
+        } else {
+            const path = try std.fmt.allocPrint(warning_allocator, "security.{s}", .{key});
+            defer warning_allocator.free(path);
+            try addWarning(warning_allocator, warnings, .unknown_field, path, "security field ignored");
+        }
+    }
+}
+
 fn decodeProcs(
     allocator: schema.Allocator,
     procs: *schema.ProcessMap,
     value: Value,
+    templates: ?Value,
+    scope: interpolate.Scope,
+    cwd_base: ?[]const u8,
     warnings: *std.array_list.Managed(schema.Warning),
     warning_allocator: schema.Allocator,
 ) !void {
@@ -279,19 +790,69 @@ fn decodeProcs(
         var proc = schema.ProcessConfig.empty(allocator);
         errdefer proc.deinit(allocator);
 
-        try decodeProcess(allocator, entry.key_ptr.*, &proc, entry.value_ptr.*, warnings, warning_allocator);
+        try applyTemplate(allocator, entry.key_ptr.*, &proc, entry.value_ptr.*, templates, scope, warnings, warning_allocator, 0);
+        try decodeProcess(allocator, entry.key_ptr.*, &proc, entry.value_ptr.*, scope, warnings, warning_allocator);
+        try expandProcessFields(allocator, &proc, scope);
+        if (cwd_base) |base| try resolveIncludedCwd(allocator, &proc, base);
 
-        const label = try allocator.dupe(u8, entry.key_ptr.*);
+        const label = try interpolate.expand(allocator, entry.key_ptr.*, scope);
         errdefer allocator.free(label);
+        if (procs.contains(label)) return error.DuplicateProcess;
         try procs.put(label, proc);
     }
 }
 
+/// Expands `shell`, `cwd`, and `url` once templates and the process's own
+/// fields are merged, so their references see the process's whole `env`.
+fn expandProcessFields(allocator: schema.Allocator, proc: *schema.ProcessConfig, scope: interpolate.Scope) !void {
+    var process_scope = scope;
+    process_scope.env = &proc.env;
+    proc.shell = try interpolate.expand(allocator, proc.shell, process_scope);
+    proc.cwd = try interpolate.expand(allocator, proc.cwd, process_scope);
+    proc.url = try interpolate.expand(allocator, proc.url, process_scope);
+}
+
+/// Included procs run from their own file's directory, so relative `cwd`
+/// values stay valid wherever the root config lives.
+fn resolveIncludedCwd(allocator: schema.Allocator, proc: *schema.ProcessConfig, base: []const u8) !void {
+    if (std.fs.path.isAbsolute(proc.cwd)) return;
+    const resolved = try std.fs.path.join(allocator, &.{ base, proc.cwd });
+    if (proc.cwd.len > 0) allocator.free(proc.cwd);
+    proc.cwd = resolved;
+}
+
+/// Decodes the template named by `value`'s `extends` key (and its own parents)
+/// into `proc`. The process's own fields are decoded afterwards, so scalars and
+/// lists override the template while env entries merge.
+fn applyTemplate(
+    allocator: schema.Allocator,
+    label: []const u8,
+    proc: *schema.ProcessConfig,
+    value: Value,
+    templates: ?Value,
+    scope: interpolate.Scope,
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+    depth: usize,
+) !void {
+    const map = value.asMap() orelse return error.TypeMismatch;
+    const extends = map.get("extends") orelse return;
+    if (depth >= max_template_depth) return error.TemplateCycle;
+
+    const name = scalar(extends);
+    const template_map = (templates orelse return error.UnknownTemplate).asMap() orelse return error.TypeMismatch;
+    const template = template_map.get(name) orelse return error.UnknownTemplate;
+
+    try applyTemplate(allocator, label, proc, template, templates, scope, warnings, warning_allocator, depth + 1);
+    try decodeProcess(allocator, label, proc, template, scope, warnings, warning_allocator);
+}
+
 fn decodeProcess(
     allocator: schema.Allocator,
     label: []const u8,
     proc: *schema.ProcessConfig,
     value: Value,
+    scope: interpolate.Scope,
     warnings: *std.array_list.Managed(schema.Warning),
     warning_allocator: schema.Allocator,
 ) !void {
@@ -303,11 +864,15 @@ fn decodeProcess(
         if (std.mem.eql(u8, key, "shell")) {
             proc.shell = try dupeString(allocator, v);
         } else if (std.mem.eql(u8, key, "cmd")) {
-            try decodeStringList(allocator, &proc.cmd, v);
+            try replaceStringList(allocator, &proc.cmd, v);
         } else if (std.mem.eql(u8, key, "cwd")) {
             proc.cwd = try dupeString(allocator, v);
         } else if (std.mem.eql(u8, key, "env")) {
-            try decodeStringMap(allocator, &proc.env, v);
+            try decodeEnv(allocator, &proc.env, v, scope);
+        } else if (std.mem.eql(u8, key, "secrets")) {
+            try decodeSecrets(allocator, &proc.secrets, v, scope);
+        } else if (std.mem.eql(u8, key, "env_clear")) {
+            proc.env_clear = try decodeBool(v);
         } else if (std.mem.eql(u8, key, "stop")) {
             proc.stop = try decodeInt(v);
         } else if (std.mem.eql(u8, key, "stop_timeout_ms")) {
@@ -320,18 +885,90 @@ fn decodeProcess(
             proc.description = try dupeString(allocator, v);
         } else if (std.mem.eql(u8, key, "docs")) {
             proc.docs = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "url")) {
+            proc.url = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "open_url")) {
+            proc.open_url = try decodeBool(v);
         } else if (std.mem.eql(u8, key, "meta_tags")) {
-            try decodeStringList(allocator, &proc.meta_tags, v);
+            try replaceStringList(allocator, &proc.meta_tags, v);
         } else if (std.mem.eql(u8, key, "categories")) {
-            try decodeStringList(allocator, &proc.categories, v);
+            try replaceStringList(allocator, &proc.categories, v);
         } else if (std.mem.eql(u8, key, "add_path")) {
-            try decodeStringList(allocator, &proc.add_path, v);
+            try replaceStringList(allocator, &proc.add_path, v);
         } else if (std.mem.eql(u8, key, "terminal_rows")) {
             proc.terminal_rows = try decodeInt(v);
         } else if (std.mem.eql(u8, key, "terminal_cols")) {
             proc.terminal_cols = try decodeInt(v);
+        } else if (std.mem.eql(u8, key, "term")) {
+            proc.term = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "colorterm")) {
+            proc.colorterm = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "force_color")) {
+            proc.force_color = try decodeBool(v);
+        } else if (std.mem.eql(u8, key, "separate_stderr")) {
+            proc.separate_stderr = try decodeBool(v);
         } else if (std.mem.eql(u8, key, "on_kill")) {
-            try decodeStringList(allocator, &proc.on_kill, v);
+            try replaceStringList(allocator, &proc.on_kill, v);
+        } else if (std.mem.eql(u8, key, "pre_start")) {
+            try replaceStringList(allocator, &proc.pre_start, v);
+        } else if (std.mem.eql(u8, key, "post_start")) {
+            try replaceStringList(allocator, &proc.post_start, v);
+        } else if (std.mem.eql(u8, key, "pre_stop")) {
+            try replaceStringList(allocator, &proc.pre_stop, v);
+        } else if (std.mem.eql(u8, key, "post_stop")) {
+            try replaceStringList(allocator, &proc.post_stop, v);
+        } else if (std.mem.eql(u8, key, "hook_timeout_ms")) {
+            proc.hook_timeout_ms = try decodeInt(v);
+        } else if (std.mem.eql(u8, key, "idle_timeout_ms")) {
+            proc.idle_timeout_ms = try decodeInt(v);
+            if (proc.idle_timeout_ms < 0) return error.InvalidIdleTimeout;
+        } else if (std.mem.eql(u8, key, "expect_output_within_ms")) {
+            proc.expect_output_within_ms = try decodeInt(v);
+            if (proc.expect_output_within_ms < 0) return error.InvalidExpectOutputWithin;
+        } else if (std.mem.eql(u8, key, "startup_delay_ms")) {
+            proc.startup_delay_ms = try decodeInt(v);
+            if (proc.startup_delay_ms < 0) return error.InvalidStartupDelay;
+        } else if (std.mem.eql(u8, key, "crash_loop_exits")) {
+            proc.crash_loop_exits = try decodeInt(v);
+            if (proc.crash_loop_exits < 0) return error.InvalidCrashLoop;
+        } else if (std.mem.eql(u8, key, "crash_loop_window_ms")) {
+            proc.crash_loop_window_ms = try decodeInt(v);
+            if (proc.crash_loop_window_ms < 0) return error.InvalidCrashLoop;
+        } else if (std.mem.eql(u8, key, "shell_cmd")) {
+            try replaceStringList(allocator, &proc.shell_cmd, v);
+        } else if (std.mem.eql(u8, key, "login_shell")) {
+            proc.login_shell = try decodeBool(v);
+        } else if (std.mem.eql(u8, key, "interactive_shell")) {
+            proc.interactive_shell = try decodeBool(v);
+        } else if (std.mem.eql(u8, key, "env_loader")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.EnvLoader, scalar(v)) == null) return error.InvalidEnvLoader;
+            proc.env_loader = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "env_loader_cmd")) {
+            try replaceStringList(allocator, &proc.env_loader_cmd, v);
+        } else if (std.mem.eql(u8, key, "output_sinks")) {
+            try replaceStringList(allocator, &proc.output_sinks, v);
+        } else if (std.mem.eql(u8, key, "watch")) {
+            try replaceStringList(allocator, &proc.watch, v);
+        } else if (std.mem.eql(u8, key, "watch_ignore")) {
+            try replaceStringList(allocator, &proc.watch_ignore, v);
+        } else if (std.mem.eql(u8, key, "watch_debounce_ms")) {
+            proc.watch_debounce_ms = try decodeInt(v);
+        } else if (std.mem.eql(u8, key, "type")) {
+            if (scalar(v).len > 0 and std.meta.stringToEnum(schema.ProcessType, scalar(v)) == null) return error.InvalidProcessType;
+            proc.@"type" = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "image")) {
+            proc.image = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "ports")) {
+            try replaceStringList(allocator, &proc.ports, v);
+        } else if (std.mem.eql(u8, key, "volumes")) {
+            try replaceStringList(allocator, &proc.volumes, v);
+        } else if (std.mem.eql(u8, key, "container_name")) {
+            proc.container_name = try dupeString(allocator, v);
+        } else if (std.mem.eql(u8, key, "replicas")) {
+            proc.replicas = try decodeInt(v);
+            if (proc.replicas < 0) return error.InvalidReplicas;
+        } else if (std.mem.eql(u8, key, "extends")) {
+            // Resolved by applyTemplate before the process's own fields.
         } else {
             const path = try std.fmt.allocPrint(warning_allocator, "procs.{s}.{s}", .{ label, key });
             defer warning_allocator.free(path);
@@ -340,16 +977,168 @@ fn decodeProcess(
     }
 }
 
+fn decodeThemes(
+    allocator: schema.Allocator,
+    out: *schema.ThemeMap,
+    value: Value,
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        const path = try std.fmt.allocPrint(warning_allocator, "themes.{s}", .{entry.key_ptr.*});
+        defer warning_allocator.free(path);
+        const theme = try decodeTheme(allocator, entry.value_ptr.*, path, warnings, warning_allocator);
+
+        const name = try allocator.dupe(u8, entry.key_ptr.*);
+        errdefer allocator.free(name);
+        try out.put(name, theme);
+    }
+}
+
+/// A theme is either one flat palette used on both backgrounds or `dark` and
+/// `light` palettes; a missing variant copies the other.
+fn decodeTheme(
+    allocator: schema.Allocator,
+    value: Value,
+    path: []const u8,
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+) !schema.Theme {
+    const map = value.asMap() orelse return error.TypeMismatch;
+    const dark_value = map.get("dark");
+    const light_value = map.get("light");
+    if (dark_value == null and light_value == null) {
+        var style = schema.StyleConfig{};
+        try decodeStyle(allocator, &style, value, path, warnings, warning_allocator);
+        return .{ .dark = style, .light = style };
+    }
+
+    var theme = schema.Theme{};
+    if (dark_value) |v| {
+        const dark_path = try std.fmt.allocPrint(warning_allocator, "{s}.dark", .{path});
+        defer warning_allocator.free(dark_path);
+        try decodeStyle(allocator, &theme.dark, v, dark_path, warnings, warning_allocator);
+    }
+    if (light_value) |v| {
+        const light_path = try std.fmt.allocPrint(warning_allocator, "{s}.light", .{path});
+        defer warning_allocator.free(light_path);
+        try decodeStyle(allocator, &theme.light, v, light_path, warnings, warning_allocator);
+    }
+    if (dark_value == null) theme.dark = theme.light;
+    if (light_value == null) theme.light = theme.dark;
+    return theme;
+}
+
+fn decodeProfiles(allocator: schema.Allocator, out: *schema.ProfileMap, value: Value) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        var labels = schema.StringList.init(allocator);
+        errdefer schema.deinitStringList(&labels);
+        try decodeStringList(allocator, &labels, entry.value_ptr.*);
+
+        const name = try allocator.dupe(u8, entry.key_ptr.*);
+        errdefer allocator.free(name);
+        try out.put(name, labels);
+    }
+}
+
+/// Views keep their YAML order so number keys follow the config file.
+fn decodeViews(
+    allocator: schema.Allocator,
+    out: *schema.ViewList,
+    value: Value,
+    warnings: *std.array_list.Managed(schema.Warning),
+    warning_allocator: schema.Allocator,
+) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        var view = schema.ViewConfig{};
+        var fields = entry.value_ptr.asMap() orelse return error.TypeMismatch;
+        var field_it = fields.iterator();
+        while (field_it.next()) |field| {
+            const key = field.key_ptr.*;
+            const v = field.value_ptr.*;
+            if (std.mem.eql(u8, key, "filter")) {
+                view.filter = try dupeString(allocator, v);
+            } else if (std.mem.eql(u8, key, "running_only")) {
+                view.running_only = try decodeBool(v);
+            } else {
+                const path = try std.fmt.allocPrint(warning_allocator, "views.{s}.{s}", .{ entry.key_ptr.*, key });
+                defer warning_allocator.free(path);
+                try addWarning(warning_allocator, warnings, .unknown_field, path, "view field ignored");
+            }
+        }
+
+        view.name = try allocator.dupe(u8, entry.key_ptr.*);
+        errdefer allocator.free(view.name);
+        try out.append(view);
+    }
+}
+
 fn decodeStringList(allocator: schema.Allocator, out: *schema.StringList, value: Value) !void {
     const list = value.asList() orelse return error.TypeMismatch;
     for (list) |item| try schema.appendOwned(allocator, out, scalar(item));
 }
 
-fn decodeStringMap(allocator: schema.Allocator, out: *schema.StringMap, value: Value) !void {
+/// Decodes a list over any template-provided items instead of appending to them.
+fn replaceStringList(allocator: schema.Allocator, out: *schema.StringList, value: Value) !void {
+    for (out.items) |item| allocator.free(item);
+    out.clearRetainingCapacity();
+    try decodeStringList(allocator, out, value);
+}
+
+fn decodeEnv(allocator: schema.Allocator, out: *schema.StringMap, value: Value, scope: interpolate.Scope) !void {
     var map = value.asMap() orelse return error.TypeMismatch;
     var it = map.iterator();
     while (it.next()) |entry| {
-        try schema.putOwnedString(allocator, out, entry.key_ptr.*, scalar(entry.value_ptr.*));
+        const expanded = try interpolate.expand(allocator, scalar(entry.value_ptr.*), scope);
+        defer allocator.free(expanded);
+        try schema.putOwnedString(allocator, out, entry.key_ptr.*, expanded);
+    }
+}
+
+/// Secrets merge over a template's like `env`. Each must name exactly one of
+/// `cmd` and `file`, and `decrypt_cmd` only applies to a `file`.
+fn decodeSecrets(allocator: schema.Allocator, out: *schema.SecretMap, value: Value, scope: interpolate.Scope) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        var fields = entry.value_ptr.asMap() orelse return error.InvalidSecret;
+        var secret = schema.SecretConfig.empty(allocator);
+        errdefer secret.deinit(allocator);
+        var field_it = fields.iterator();
+        while (field_it.next()) |field| {
+            const key = field.key_ptr.*;
+            const v = field.value_ptr.*;
+            if (std.mem.eql(u8, key, "cmd")) {
+                try decodeStringList(allocator, &secret.cmd, v);
+            } else if (std.mem.eql(u8, key, "file")) {
+                if (secret.file.len > 0) allocator.free(secret.file);
+                secret.file = try interpolate.expand(allocator, scalar(v), scope);
+            } else if (std.mem.eql(u8, key, "decrypt_cmd")) {
+                try decodeStringList(allocator, &secret.decrypt_cmd, v);
+            } else {
+                return error.InvalidSecret;
+            }
+        }
+        const has_cmd = secret.cmd.items.len > 0;
+        const has_file = secret.file.len > 0;
+        if (has_cmd == has_file) return error.InvalidSecret;
+        if (has_cmd and secret.decrypt_cmd.items.len > 0) return error.InvalidSecret;
+        try schema.putOwnedSecret(allocator, out, entry.key_ptr.*, secret);
+    }
+}
+
+/// Later vars may reference earlier ones; the environment fills in the rest.
+fn decodeVars(allocator: schema.Allocator, out: *schema.StringMap, value: Value, strict: bool) !void {
+    var map = value.asMap() orelse return error.TypeMismatch;
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        try out.put(entry.key_ptr.*, try interpolate.expand(allocator, scalar(entry.value_ptr.*), .{ .vars = out, .strict = strict }));
     }
 }
 
@@ -365,6 +1154,12 @@ fn decodeInt(value: Value) !i32 {
     return std.fmt.parseInt(i32, scalar(value), 10);
 }
 
+fn decodeBounded(value: Value, bounds: schema.IntervalBounds) !i32 {
+    const n = try decodeInt(value);
+    if (!bounds.contains(n)) return error.IntervalOutOfRange;
+    return n;
+}
+
 fn decodeBool(value: Value) !bool {
     return switch (value) {
         .boolean => |b| b,
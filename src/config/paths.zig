@@ -0,0 +1,84 @@
+//! Runtime and state directory resolution.
+//! Sockets live in the runtime directory and saved UI state in the state directory; both follow the XDG base directory spec, can be overridden by `runtime_dir`/`state_dir`, and fall back to the `/tmp` layout older releases used.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+/// Where every file lived before XDG support, still searched so sessions
+/// started by an older release keep working.
+pub const legacy_dir = "/tmp";
+
+/// The environment variables directory resolution reads, captured so tests
+/// can resolve without touching the process environment.
+pub const Env = struct {
+    xdg_runtime_dir: []const u8 = "",
+    xdg_state_home: []const u8 = "",
+    home: []const u8 = "",
+
+    pub fn current() Env {
+        return .{
+            .xdg_runtime_dir = std.posix.getenv("XDG_RUNTIME_DIR") orelse "",
+            .xdg_state_home = std.posix.getenv("XDG_STATE_HOME") orelse "",
+            .home = std.posix.getenv("HOME") orelse "",
+        };
+    }
+};
+
+/// Returns the directory sockets are created in: `runtime_dir`, then
+/// `$XDG_RUNTIME_DIR`, then `/tmp`. The caller owns the result.
+pub fn runtimeDir(allocator: std.mem.Allocator, cfg: *const schema.Config, env: Env) ![]const u8 {
+    if (cfg.runtime_dir.len > 0) return allocator.dupe(u8, cfg.runtime_dir);
+    // The spec says relative values are invalid and must be ignored.
+    if (std.fs.path.isAbsolute(env.xdg_runtime_dir)) return allocator.dupe(u8, env.xdg_runtime_dir);
+    return allocator.dupe(u8, legacy_dir);
+}
+
+/// Returns the directory state files are saved in: `state_dir`, then
+/// `$XDG_STATE_HOME/proctmux`, then `~/.local/state/proctmux`, then `/tmp`.
+/// The directory may not exist yet. The caller owns the result.
+pub fn stateDir(allocator: std.mem.Allocator, cfg: *const schema.Config, env: Env) ![]const u8 {
+    if (cfg.state_dir.len > 0) return allocator.dupe(u8, cfg.state_dir);
+    return userStateDir(allocator, env);
+}
+
+/// Returns the state directory for files that belong to no one config, such
+/// as the recent-configs list: `stateDir` without the `state_dir` override.
+pub fn userStateDir(allocator: std.mem.Allocator, env: Env) ![]const u8 {
+    if (std.fs.path.isAbsolute(env.xdg_state_home)) return std.fs.path.join(allocator, &.{ env.xdg_state_home, "proctmux" });
+    if (std.fs.path.isAbsolute(env.home)) return std.fs.path.join(allocator, &.{ env.home, ".local", "state", "proctmux" });
+    return allocator.dupe(u8, legacy_dir);
+}
+
+test "directories prefer config overrides, then XDG, then legacy /tmp" {
+    var cfg = schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    const allocator = std.testing.allocator;
+
+    const env = Env{ .xdg_runtime_dir = "/run/user/1000", .xdg_state_home = "/home/me/.state", .home = "/home/me" };
+    const runtime = try runtimeDir(allocator, &cfg, env);
+    defer allocator.free(runtime);
+    try std.testing.expectEqualStrings("/run/user/1000", runtime);
+    const state = try stateDir(allocator, &cfg, env);
+    defer allocator.free(state);
+    try std.testing.expectEqualStrings("/home/me/.state/proctmux", state);
+
+    const home_only = try stateDir(allocator, &cfg, .{ .xdg_state_home = "relative", .home = "/home/me" });
+    defer allocator.free(home_only);
+    try std.testing.expectEqualStrings("/home/me/.local/state/proctmux", home_only);
+
+    const bare_runtime = try runtimeDir(allocator, &cfg, .{ .xdg_runtime_dir = "relative" });
+    defer allocator.free(bare_runtime);
+    try std.testing.expectEqualStrings(legacy_dir, bare_runtime);
+    const bare_state = try stateDir(allocator, &cfg, .{});
+    defer allocator.free(bare_state);
+    try std.testing.expectEqualStrings(legacy_dir, bare_state);
+
+    cfg.runtime_dir = "/var/run/proctmux";
+    cfg.state_dir = "/var/lib/proctmux";
+    const runtime_override = try runtimeDir(allocator, &cfg, env);
+    defer allocator.free(runtime_override);
+    try std.testing.expectEqualStrings("/var/run/proctmux", runtime_override);
+    const state_override = try stateDir(allocator, &cfg, env);
+    defer allocator.free(state_override);
+    try std.testing.expectEqualStrings("/var/lib/proctmux", state_override);
+}
@@ -0,0 +1,115 @@
+//! Recently used Project Configs.
+//! Interactive sessions record their config's absolute path in the user state directory, newest first, so running proctmux where there is no config can offer them instead of failing.
+
+const std = @import("std");
+const paths = @import("paths.zig");
+
+pub const file_name = "recent-configs";
+pub const max_entries = 20;
+
+/// Absolute config paths, newest first. Owns its strings.
+pub const List = struct {
+    allocator: std.mem.Allocator,
+    paths: [][]const u8,
+
+    pub fn deinit(self: *const List) void {
+        for (self.paths) |path| self.allocator.free(path);
+        self.allocator.free(self.paths);
+    }
+};
+
+/// Returns where the list lives; the caller owns the result.
+pub fn pathFor(allocator: std.mem.Allocator, env: paths.Env) ![]const u8 {
+    const dir = try paths.userStateDir(allocator, env);
+    defer allocator.free(dir);
+    return std.fs.path.join(allocator, &.{ dir, file_name });
+}
+
+/// Reads the list at `path`; a missing or unreadable file is an empty list.
+pub fn load(allocator: std.mem.Allocator, dir: std.fs.Dir, path: []const u8) !List {
+    var list = std.array_list.Managed([]const u8).init(allocator);
+    errdefer {
+        for (list.items) |item| allocator.free(item);
+        list.deinit();
+    }
+
+    const contents = dir.readFileAlloc(allocator, path, 64 * 1024) catch |err| switch (err) {
+        error.OutOfMemory => return err,
+        else => return .{ .allocator = allocator, .paths = try list.toOwnedSlice() },
+    };
+    defer allocator.free(contents);
+
+    var lines = std.mem.splitScalar(u8, contents, '\n');
+    while (lines.next()) |line| {
+        const trimmed = std.mem.trim(u8, line, " \t\r");
+        if (!std.fs.path.isAbsolute(trimmed) or list.items.len == max_entries) continue;
+        try list.append(try allocator.dupe(u8, trimmed));
+    }
+    return .{ .allocator = allocator, .paths = try list.toOwnedSlice() };
+}
+
+/// Moves `config_path` to the front of the list at `path`, dropping the
+/// oldest entries past `max_entries`.
+pub fn add(allocator: std.mem.Allocator, dir: std.fs.Dir, path: []const u8, config_path: []const u8) !void {
+    const existing = try load(allocator, dir, path);
+    defer existing.deinit();
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    defer out.deinit();
+    try out.writer().print("{s}\n", .{config_path});
+    var count: usize = 1;
+    for (existing.paths) |item| {
+        if (count == max_entries) break;
+        if (std.mem.eql(u8, item, config_path)) continue;
+        try out.writer().print("{s}\n", .{item});
+        count += 1;
+    }
+
+    if (std.fs.path.dirname(path)) |parent| try dir.makePath(parent);
+    try dir.writeFile(.{ .sub_path = path, .data = out.items });
+}
+
+/// Records `config_path` in the current user's list.
+pub fn remember(allocator: std.mem.Allocator, config_path: []const u8) !void {
+    const path = try pathFor(allocator, paths.Env.current());
+    defer allocator.free(path);
+    try add(allocator, std.fs.cwd(), path, config_path);
+}
+
+test "recent configs keep the newest first without duplicates" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const allocator = std.testing.allocator;
+
+    const empty = try load(allocator, tmp.dir, "state/recent-configs");
+    defer empty.deinit();
+    try std.testing.expectEqual(@as(usize, 0), empty.paths.len);
+
+    try add(allocator, tmp.dir, "state/recent-configs", "/work/api/proctmux.yaml");
+    try add(allocator, tmp.dir, "state/recent-configs", "/work/web/proctmux.yaml");
+    try add(allocator, tmp.dir, "state/recent-configs", "/work/api/proctmux.yaml");
+
+    const list = try load(allocator, tmp.dir, "state/recent-configs");
+    defer list.deinit();
+    try std.testing.expectEqual(@as(usize, 2), list.paths.len);
+    try std.testing.expectEqualStrings("/work/api/proctmux.yaml", list.paths[0]);
+    try std.testing.expectEqualStrings("/work/web/proctmux.yaml", list.paths[1]);
+}
+
+test "recent configs are capped and skip relative entries" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const allocator = std.testing.allocator;
+
+    try tmp.dir.writeFile(.{ .sub_path = file_name, .data = "relative/proctmux.yaml\n\n/a/proctmux.yaml\n" });
+    var index: usize = 0;
+    while (index < max_entries + 5) : (index += 1) {
+        var buffer: [64]u8 = undefined;
+        try add(allocator, tmp.dir, file_name, try std.fmt.bufPrint(&buffer, "/p{d}/proctmux.yaml", .{index}));
+    }
+
+    const list = try load(allocator, tmp.dir, file_name);
+    defer list.deinit();
+    try std.testing.expectEqual(@as(usize, max_entries), list.paths.len);
+    try std.testing.expectEqualStrings("/p24/proctmux.yaml", list.paths[0]);
+}
@@ -9,6 +9,14 @@ pub const load = @import("load.zig");
 pub const hash = @import("hash.zig");
 pub const template = @import("template.zig");
 pub const runtime = @import("runtime.zig");
+pub const interpolate = @import("interpolate.zig");
+pub const include = @import("include.zig");
+pub const launch = @import("launch.zig");
+pub const paths = @import("paths.zig");
+pub const recent = @import("recent.zig");
+pub const keybindings = @import("keybindings.zig");
+pub const themes = @import("themes.zig");
+pub const icons = @import("icons.zig");
 
 test {
     _ = schema;
@@ -17,6 +25,14 @@ test {
     _ = hash;
     _ = template;
     _ = runtime;
+    _ = interpolate;
+    _ = include;
+    _ = launch;
+    _ = paths;
+    _ = recent;
+    _ = keybindings;
+    _ = themes;
+    _ = icons;
 }
 
 test "defaults match current defaults" {
@@ -32,9 +48,24 @@ test "defaults match current defaults" {
     try std.testing.expectEqualStrings("?", cfg.keybinding.toggle_help.items[0]);
     try std.testing.expectEqualStrings("ctrl+w", cfg.keybinding.toggle_focus.items[0]);
     try std.testing.expectEqualStrings("d", cfg.keybinding.docs.items[0]);
+    try std.testing.expectEqualStrings("v", cfg.keybinding.cycle_view.items[0]);
+    try std.testing.expectEqualStrings("S", cfg.keybinding.cycle_sort.items[0]);
+    try std.testing.expectEqualStrings("p", cfg.keybinding.toggle_pin.items[0]);
+    try std.testing.expectEqualStrings("space", cfg.keybinding.toggle_mark.items[0]);
+    try std.testing.expectEqualStrings("m", cfg.keybinding.toggle_messages.items[0]);
+    try std.testing.expectEqualStrings("e", cfg.keybinding.jump_to_error.items[0]);
+    try std.testing.expectEqualStrings("y", cfg.keybinding.copy_command.items[0]);
+    try std.testing.expectEqualStrings("Y", cfg.keybinding.copy_output.items[0]);
+    try std.testing.expectEqualStrings("o", cfg.keybinding.open_url.items[0]);
+    try std.testing.expectEqualStrings("O", cfg.keybinding.open_cwd.items[0]);
+    try std.testing.expectEqualStrings("E", cfg.keybinding.show_env.items[0]);
+    try std.testing.expectEqualStrings("error", cfg.error_patterns.items[0]);
+    try std.testing.expectEqual(@as(usize, 0), cfg.redact_patterns.items.len);
 
     try std.testing.expectEqualStrings("cat:", cfg.layout.category_search_prefix);
     try std.testing.expectEqual(@as(i32, 30), cfg.layout.processes_list_width);
+    try std.testing.expectEqual(@as(i32, 30), cfg.layout.max_output_fps);
+    try std.testing.expectEqual(@as(i32, 256 * 1024), cfg.layout.max_relay_bytes_per_tick);
     try std.testing.expect(!cfg.layout.sort_process_list_running_first);
     try std.testing.expectEqualStrings("▶", cfg.style.pointer_char);
     try std.testing.expectEqualStrings("white", cfg.style.selected_process_color);
@@ -42,6 +73,13 @@ test "defaults match current defaults" {
     try std.testing.expectEqualStrings("green", cfg.style.status_running_color);
     try std.testing.expectEqualStrings("yellow", cfg.style.status_halting_color);
     try std.testing.expectEqualStrings("red", cfg.style.status_stopped_color);
+    try std.testing.expectEqualStrings("yellow", cfg.style.warning_color);
+    try std.testing.expectEqualStrings("136", cfg.light_style.status_halting_color);
+    try std.testing.expectEqualStrings("auto", cfg.background);
+    try std.testing.expectEqualStrings("stop", cfg.general.on_quit);
+    try std.testing.expectEqualStrings("ring", cfg.general.on_bell);
+    try std.testing.expectEqual(@as(i32, 50), cfg.general.refresh_interval_ms);
+    try std.testing.expectEqual(@as(i32, 500), cfg.general.watch_debounce_ms);
 }
 
 test "load full active config fixture" {
@@ -69,6 +107,8 @@ test "load full active config fixture" {
     try std.testing.expectEqualStrings("api", backend.env.get("ROLE").?);
     try std.testing.expectEqual(@as(i32, 3000), backend.stop_timeout_ms);
     try std.testing.expectEqual(@as(i32, 40), backend.terminal_rows);
+    try std.testing.expectEqualStrings("xterm-256color", backend.term);
+    try std.testing.expect(backend.force_color);
 
     const worker = loaded.config.procs.get("worker").?;
     try std.testing.expectEqualStrings("python", worker.cmd.items[0]);
@@ -126,6 +166,455 @@ test "load process docs literal block like the config-init template" {
     try std.testing.expectEqualStrings("API developer notes\nSecond line\n", proc.docs);
 }
 
+test "load process templates through extends" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\templates:
+        \\  base:
+        \\    cwd: "services"
+        \\    env:
+        \\      LOG_LEVEL: "info"
+        \\    categories: ["service"]
+        \\  node:
+        \\    extends: base
+        \\    shell: "npm start"
+        \\    env:
+        \\      NODE_ENV: "development"
+        \\procs:
+        \\  api:
+        \\    extends: node
+        \\    cwd: "services/api"
+        \\    env:
+        \\      LOG_LEVEL: "debug"
+        \\  worker:
+        \\    extends: node
+        \\    categories: ["jobs"]
+        \\
+    ,
+        "inline-templates.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
+
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expectEqualStrings("npm start", api.shell);
+    try std.testing.expectEqualStrings("services/api", api.cwd);
+    try std.testing.expectEqualStrings("debug", api.env.get("LOG_LEVEL").?);
+    try std.testing.expectEqualStrings("development", api.env.get("NODE_ENV").?);
+
+    const worker = loaded.config.procs.get("worker").?;
+    try std.testing.expectEqualStrings("services", worker.cwd);
+    try std.testing.expectEqual(@as(usize, 1), worker.categories.items.len);
+    try std.testing.expectEqualStrings("jobs", worker.categories.items[0]);
+}
+
+test "load rejects unknown and cyclic process templates" {
+    try std.testing.expectError(error.UnknownTemplate, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  api:
+        \\    extends: missing
+        \\
+    ,
+        "inline-missing-template.yaml",
+    ));
+
+    try std.testing.expectError(error.TemplateCycle, load.loadFromSlice(
+        std.testing.allocator,
+        \\templates:
+        \\  a:
+        \\    extends: b
+        \\  b:
+        \\    extends: a
+        \\procs:
+        \\  api:
+        \\    extends: a
+        \\
+    ,
+        "inline-cyclic-template.yaml",
+    ));
+}
+
+test "load resolves process and category output sinks" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\category_output_sinks:
+        \\  backend: "file:{config_dir}/logs/{category}/{label}.log"
+        \\  shared: [syslog, "journald:dev"]
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\    categories: [backend, shared]
+        \\    output_sinks: ["file:/tmp/{label}-{category}.log", syslog]
+        \\  worker:
+        \\    shell: work
+        \\
+    ,
+        "inline-output-sinks.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
+
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expectEqual(@as(usize, 4), api.output_sinks.items.len);
+    try std.testing.expectEqualStrings("file:/tmp/api-backend.log", api.output_sinks.items[0]);
+    try std.testing.expectEqualStrings("syslog:api", api.output_sinks.items[1]);
+    try std.testing.expectEqualStrings("file:{config_dir}/logs/backend/api.log", api.output_sinks.items[2]);
+    try std.testing.expectEqualStrings("journald:dev", api.output_sinks.items[3]);
+    try std.testing.expectEqual(@as(usize, 0), loaded.config.procs.get("worker").?.output_sinks.items.len);
+
+    try std.testing.expectError(error.InvalidOutputSink, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\    output_sinks: ["kafka:logs"]
+        \\
+    ,
+        "inline-bad-output-sink.yaml",
+    ));
+}
+
+test "load decodes process watch settings" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  api:
+        \\    shell: go run .
+        \\    watch: ["**/*.go", go.mod]
+        \\    watch_ignore: [vendor]
+        \\    watch_debounce_ms: 250
+        \\
+    ,
+        "inline-watch.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expectEqual(@as(usize, 2), api.watch.items.len);
+    try std.testing.expectEqualStrings("**/*.go", api.watch.items[0]);
+    try std.testing.expectEqualStrings("go.mod", api.watch.items[1]);
+    try std.testing.expectEqualStrings("vendor", api.watch_ignore.items[0]);
+    try std.testing.expectEqual(@as(i32, 250), api.watch_debounce_ms);
+}
+
+test "load validates docker processes and names their containers" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  "db main":
+        \\    type: docker
+        \\    image: postgres:16
+        \\    ports: ["5432:5432"]
+        \\    volumes: ["{config_dir}/data:/var/lib/postgresql/data"]
+        \\  cache:
+        \\    type: docker
+        \\    image: redis:7
+        \\    container_name: dev-redis
+        \\
+    ,
+        "inline-docker.yaml",
+    );
+    defer loaded.deinit();
+
+    const db = loaded.config.procs.get("db main").?;
+    try std.testing.expect(db.isDocker());
+    try std.testing.expectEqualStrings("proctmux-db-main", db.container_name);
+    try std.testing.expectEqualStrings("5432:5432", db.ports.items[0]);
+    try std.testing.expectEqualStrings("dev-redis", loaded.config.procs.get("cache").?.container_name);
+
+    try std.testing.expectError(error.MissingDockerImage, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  db:
+        \\    type: docker
+        \\
+    ,
+        "inline-docker-no-image.yaml",
+    ));
+    try std.testing.expectError(error.InvalidProcessType, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  db:
+        \\    type: podman
+        \\
+    ,
+        "inline-bad-type.yaml",
+    ));
+}
+
+test "load reads process secrets as references and rejects ambiguous ones" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  api:
+        \\    shell: node server.js
+        \\    secrets:
+        \\      DB_PASSWORD:
+        \\        cmd: ["pass", "show", "dev/db"]
+        \\      STRIPE_KEY:
+        \\        file: "{config_dir}/secrets/stripe.age"
+        \\        decrypt_cmd: ["age", "-d", "-i", "key.txt"]
+        \\
+    ,
+        "inline-secrets.yaml",
+    );
+    defer loaded.deinit();
+
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expectEqualStrings("show", api.secrets.get("DB_PASSWORD").?.cmd.items[1]);
+    const stripe = api.secrets.get("STRIPE_KEY").?;
+    try std.testing.expectEqualStrings("{config_dir}/secrets/stripe.age", stripe.file);
+    try std.testing.expectEqualStrings("age", stripe.decrypt_cmd.items[0]);
+
+    try std.testing.expectError(error.InvalidSecret, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  api:
+        \\    shell: node server.js
+        \\    secrets:
+        \\      DB_PASSWORD:
+        \\        cmd: ["pass", "show", "dev/db"]
+        \\        file: db.txt
+        \\
+    ,
+        "inline-secrets-both.yaml",
+    ));
+    try std.testing.expectError(error.DockerSecretsUnsupported, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  db:
+        \\    type: docker
+        \\    image: postgres:16
+        \\    secrets:
+        \\      POSTGRES_PASSWORD:
+        \\        cmd: ["pass", "show", "dev/db"]
+        \\
+    ,
+        "inline-docker-secrets.yaml",
+    ));
+}
+
+test "load expands process replicas into indexed instances" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  worker:
+        \\    shell: "work"
+        \\    replicas: 3
+        \\    output_sinks: ["file:logs/{label}.log"]
+        \\  api:
+        \\    shell: "serve"
+        \\    replicas: 1
+        \\
+    ,
+        "inline-replicas.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expect(!loaded.config.procs.contains("worker"));
+    try std.testing.expect(loaded.config.procs.contains("api"));
+    const second = loaded.config.procs.get("worker-2").?;
+    try std.testing.expectEqualStrings("worker", second.replica_group);
+    try std.testing.expectEqualStrings("2", second.env.get("PROCTMUX_REPLICA").?);
+    try std.testing.expectEqualStrings("file:logs/worker-2.log", second.output_sinks.items[0]);
+    try std.testing.expect(loaded.config.procs.contains("worker-3"));
+    try std.testing.expectEqual(@as(usize, 4), loaded.config.procs.count());
+
+    try std.testing.expectError(error.DuplicateProcess, load.loadFromSlice(
+        std.testing.allocator,
+        \\procs:
+        \\  worker:
+        \\    shell: "work"
+        \\    replicas: 2
+        \\  worker-2:
+        \\    shell: "other"
+        \\
+    ,
+        "inline-replica-clash.yaml",
+    ));
+}
+
+test "load interpolates vars into process fields" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\vars:
+        \\  PORT: "4000"
+        \\  ROOT: "services"
+        \\  API_DIR: "${ROOT}/api"
+        \\procs:
+        \\  "api ${PORT}":
+        \\    shell: "serve --port ${PORT} --mode ${PROCTMUX_TEST_UNSET_MODE:-dev}"
+        \\    cwd: "${API_DIR}"
+        \\    env:
+        \\      URL: "http://localhost:${PORT}"
+        \\
+    ,
+        "inline-vars.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
+
+    const api = loaded.config.procs.get("api 4000").?;
+    try std.testing.expectEqualStrings("serve --port 4000 --mode dev", api.shell);
+    try std.testing.expectEqualStrings("services/api", api.cwd);
+    try std.testing.expectEqualStrings("http://localhost:4000", api.env.get("URL").?);
+}
+
+test "load interpolates process env first and leaves undefined names for the shell" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\vars:
+        \\  PORT: "4000"
+        \\templates:
+        \\  web:
+        \\    env:
+        \\      ROOT: "web"
+        \\procs:
+        \\  api:
+        \\    extends: web
+        \\    shell: "serve --port ${PORT} --token ${PROCTMUX_TEST_UNSET_TOKEN}"
+        \\    cwd: "${ROOT}/api"
+        \\    env:
+        \\      PORT: "5000"
+        \\
+    ,
+        "inline-process-env-vars.yaml",
+    );
+    defer loaded.deinit();
+
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expectEqualStrings("serve --port 5000 --token ${PROCTMUX_TEST_UNSET_TOKEN}", api.shell);
+    try std.testing.expectEqualStrings("web/api", api.cwd);
+}
+
+test "load rejects undefined interpolation names with strict_vars" {
+    try std.testing.expectError(error.UndefinedInterpolation, load.loadFromSlice(
+        std.testing.allocator,
+        \\strict_vars: true
+        \\procs:
+        \\  api:
+        \\    shell: "serve --token ${PROCTMUX_TEST_UNSET_TOKEN}"
+        \\
+    ,
+        "inline-strict-vars.yaml",
+    ));
+}
+
+test "load merges included configs in sorted order" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.makePath("services/api");
+    try tmp.dir.makePath("services/web");
+    try tmp.dir.writeFile(.{ .sub_path = "proctmux.yaml", .data =
+        \\include:
+        \\  - "services/*/proctmux.yaml"
+        \\templates:
+        \\  node:
+        \\    shell: "npm start"
+        \\procs:
+        \\  db:
+        \\    shell: "postgres"
+        \\
+    });
+    try tmp.dir.writeFile(.{ .sub_path = "services/web/proctmux.yaml", .data =
+        \\procs:
+        \\  web:
+        \\    extends: node
+        \\
+    });
+    try tmp.dir.writeFile(.{ .sub_path = "services/api/proctmux.yaml", .data =
+        \\include: "worker.yaml"
+        \\procs:
+        \\  api:
+        \\    shell: "cargo run"
+        \\    cwd: "src"
+        \\
+    });
+    try tmp.dir.writeFile(.{ .sub_path = "services/api/worker.yaml", .data =
+        \\procs:
+        \\  worker:
+        \\    shell: "cargo run --bin worker"
+        \\
+    });
+
+    var loaded = try load.loadFileInDir(std.testing.allocator, tmp.dir, "proctmux.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
+
+    const labels = loaded.config.procs.keys();
+    try std.testing.expectEqual(@as(usize, 4), labels.len);
+    try std.testing.expectEqualStrings("db", labels[0]);
+    try std.testing.expectEqualStrings("api", labels[1]);
+    try std.testing.expectEqualStrings("worker", labels[2]);
+    try std.testing.expectEqualStrings("web", labels[3]);
+
+    const api = loaded.config.procs.get("api").?;
+    try std.testing.expect(std.mem.endsWith(u8, api.cwd, "services/api/src"));
+    try std.testing.expect(std.mem.endsWith(u8, loaded.config.procs.get("web").?.cwd, "services/web"));
+    try std.testing.expectEqualStrings("npm start", loaded.config.procs.get("web").?.shell);
+}
+
+test "load rejects include cycles and duplicate labels" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.writeFile(.{ .sub_path = "proctmux.yaml", .data = "include: \"a.yaml\"\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "a.yaml", .data = "include: \"proctmux.yaml\"\n" });
+    try std.testing.expectError(error.IncludeCycle, load.loadFileInDir(std.testing.allocator, tmp.dir, "proctmux.yaml"));
+
+    try tmp.dir.writeFile(.{ .sub_path = "dup.yaml", .data = "include: \"b.yaml\"\nprocs:\n  api:\n    shell: \"one\"\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "b.yaml", .data = "procs:\n  api:\n    shell: \"two\"\n" });
+    try std.testing.expectError(error.DuplicateProcess, load.loadFileInDir(std.testing.allocator, tmp.dir, "dup.yaml"));
+}
+
+test "load validates log level and format" {
+    var loaded = try load.loadFromSlice(
+        std.testing.allocator,
+        \\log_level: debug
+        \\log_format: json
+        \\procs:
+        \\  api:
+        \\    shell: "echo ok"
+        \\
+    ,
+        "inline-logging.yaml",
+    );
+    defer loaded.deinit();
+
+    try std.testing.expectEqualStrings("debug", loaded.config.log_level);
+    try std.testing.expectEqualStrings("json", loaded.config.log_format);
+    try std.testing.expectError(error.InvalidLogLevel, load.loadFromSlice(std.testing.allocator, "log_level: trace\n", "inline-bad-level.yaml"));
+    try std.testing.expectError(error.InvalidLogFormat, load.loadFromSlice(std.testing.allocator, "log_format: xml\n", "inline-bad-format.yaml"));
+}
+
+test "load validates general.on_quit and general.on_bell" {
+    var loaded = try load.loadFromSlice(std.testing.allocator, "general:\n  on_quit: ask\n", "inline-on-quit.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqualStrings("ask", loaded.config.general.on_quit);
+    try std.testing.expectError(error.InvalidQuitAction, load.loadFromSlice(std.testing.allocator, "general:\n  on_quit: later\n", "inline-bad-on-quit.yaml"));
+    try std.testing.expectError(error.InvalidBellAction, load.loadFromSlice(std.testing.allocator, "general:\n  on_bell: loud\n", "inline-bad-on-bell.yaml"));
+}
+
+test "load bounds refresh and poll intervals" {
+    var loaded = try load.loadFromSlice(std.testing.allocator, "general:\n  refresh_interval_ms: 250\n  watch_poll_interval_ms: 2000\n", "inline-intervals.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(i32, 250), loaded.config.general.refresh_interval_ms);
+    try std.testing.expectEqual(@as(i32, 2000), loaded.config.general.watch_poll_interval_ms);
+    try std.testing.expectEqual(@as(i32, 20), loaded.config.general.output_poll_interval_ms);
+    try std.testing.expectError(error.IntervalOutOfRange, load.loadFromSlice(std.testing.allocator, "general:\n  refresh_interval_ms: 1\n", "inline-fast-refresh.yaml"));
+    try std.testing.expectError(error.IntervalOutOfRange, load.loadFromSlice(std.testing.allocator, "layout:\n  max_output_fps: 1000\n", "inline-fast-fps.yaml"));
+    try std.testing.expectError(error.IntervalOutOfRange, load.loadFromSlice(std.testing.allocator, "layout:\n  max_relay_bytes_per_tick: 16\n", "inline-small-relay.yaml"));
+}
+
 test "load quoted process labels with spaces like legacy config" {
     var loaded = try load.loadFromSlice(
         std.testing.allocator,
@@ -209,8 +698,12 @@ test "load default in dir follows proctmux search order" {
     var tmp = std.testing.tmpDir(.{});
     defer tmp.cleanup();
 
+    try std.testing.expect(!load.hasDefaultFile(tmp.dir));
+    try std.testing.expectError(error.ConfigFileNotFound, load.loadDefaultInDir(std.testing.allocator, tmp.dir));
+
     try tmp.dir.writeFile(.{ .sub_path = "procmux.yml", .data = "{}\n" });
 
+    try std.testing.expect(load.hasDefaultFile(tmp.dir));
     var loaded = try load.loadDefaultInDir(std.testing.allocator, tmp.dir);
     defer loaded.deinit();
 
@@ -262,6 +755,118 @@ test "runtime config loads default file and applies Makefile discovery" {
     try std.testing.expect(loaded.config.procs.contains("make:test"));
 }
 
+test "runtime formation reshapes procs but keeps the plain socket identity" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.writeFile(.{ .sub_path = "proctmux.yaml", .data = 
+        \\procs:
+        \\  web:
+        \\    shell: "serve"
+        \\    autostart: true
+        \\  worker:
+        \\    shell: "work"
+        \\
+    });
+
+    var plain = try runtime.loadInDir(std.testing.allocator, tmp.dir, "proctmux.yaml");
+    defer plain.deinit();
+    var formed = try runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{
+        .formation = "worker=2",
+    });
+    defer formed.deinit();
+
+    try std.testing.expect(!formed.config.procs.get("web").?.autostart);
+    try std.testing.expect(formed.config.procs.get("worker-1").?.autostart);
+    try std.testing.expect(formed.config.procs.contains("worker-2"));
+
+    const plain_hash = try hash.toHash(std.testing.allocator, &plain.config);
+    defer std.testing.allocator.free(plain_hash);
+    const formed_hash = try hash.toHash(std.testing.allocator, &formed.config);
+    defer std.testing.allocator.free(formed_hash);
+    try std.testing.expectEqualStrings(plain_hash, formed_hash);
+
+    try std.testing.expectError(
+        error.UnknownFormationProcess,
+        runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{ .formation = "api=1" }),
+    );
+}
+
+test "runtime overrides merge over the file and inject env into every process" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.writeFile(.{ .sub_path = "proctmux.yaml", .data = 
+        \\procs:
+        \\  backend:
+        \\    shell: "serve"
+        \\    autostart: true
+        \\    env:
+        \\      PORT: "3000"
+        \\  web:
+        \\    shell: "vite"
+        \\
+    });
+
+    var plain = try runtime.loadInDir(std.testing.allocator, tmp.dir, "proctmux.yaml");
+    defer plain.deinit();
+    var loaded = try runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{
+        .set = &.{ "procs.backend.autostart=false", "procs.web.meta_tags=[ui, js]", "general.on_quit=ask" },
+        .env = &.{ "PORT=4000", "DEBUG=1" },
+    });
+    defer loaded.deinit();
+
+    try std.testing.expectEqualStrings("ask", loaded.config.general.on_quit);
+    const backend = loaded.config.procs.get("backend").?;
+    try std.testing.expect(!backend.autostart);
+    try std.testing.expectEqualStrings("4000", backend.env.get("PORT").?);
+    const web = loaded.config.procs.get("web").?;
+    try std.testing.expectEqualStrings("js", web.meta_tags.items[1]);
+    try std.testing.expectEqualStrings("1", web.env.get("DEBUG").?);
+
+    const plain_hash = try hash.toHash(std.testing.allocator, &plain.config);
+    defer std.testing.allocator.free(plain_hash);
+    const loaded_hash = try hash.toHash(std.testing.allocator, &loaded.config);
+    defer std.testing.allocator.free(loaded_hash);
+    try std.testing.expectEqualStrings(plain_hash, loaded_hash);
+
+    try std.testing.expectError(
+        error.InvalidOverridePath,
+        runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{ .set = &.{"procs..shell=x"} }),
+    );
+    try std.testing.expectError(
+        error.TypeMismatch,
+        runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{ .set = &.{"procs.web.autostart=maybe"} }),
+    );
+}
+
+test "runtime selection loads a profile with discovered processes" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.writeFile(.{ .sub_path = "proctmux.yaml", .data = 
+        \\general:
+        \\  procs_from_make_targets: true
+        \\profiles:
+        \\  backend: ["api", "make:build"]
+        \\procs:
+        \\  api:
+        \\    shell: "serve"
+        \\  frontend:
+        \\    shell: "vite"
+        \\
+    });
+    try tmp.dir.writeFile(.{ .sub_path = "Makefile", .data = "build:\n" });
+
+    var loaded = try runtime.loadInDirWithOptions(std.testing.allocator, tmp.dir, "proctmux.yaml", .{ .profile = "backend" });
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 2), loaded.config.procs.count());
+    try std.testing.expect(loaded.config.procs.contains("api"));
+    try std.testing.expect(loaded.config.procs.contains("make:build"));
+    try std.testing.expectEqualStrings("make:build", loaded.config.profiles.get("backend").?.items[1]);
+}
+
 test "dead and unknown fields warn and do not populate active config" {
     var loaded = try load.loadFile(std.testing.allocator, "testdata/phase2/config/dead-fields.yaml");
     defer loaded.deinit();
@@ -353,6 +958,132 @@ test "starter template parses docs and meta tags fields" {
     try std.testing.expectEqual(@as(usize, 0), loaded.warnings.items.len);
 }
 
+test "load warns about keys bound to several actions" {
+    var loaded = try load.loadFromSlice(std.testing.allocator,
+        \\keybinding:
+        \\  start: ["q"]
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\
+    , "inline-keybinding-conflict.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqual(@as(usize, 1), loaded.warnings.items.len);
+    const warning = loaded.warnings.items[0];
+    try std.testing.expectEqual(schema.WarningKind.keybinding_conflict, warning.kind);
+    try std.testing.expectEqualStrings("keybinding.quit", warning.path);
+    try std.testing.expectEqualStrings("\"q\" is also bound to start, which takes precedence", warning.message);
+}
+
+test "load applies a config theme under explicit style values" {
+    var loaded = try load.loadFromSlice(std.testing.allocator,
+        \\theme: dusk
+        \\themes:
+        \\  dusk:
+        \\    status_running_color: "#00ff87"
+        \\    warning_color: "208"
+        \\    glow: "#ffffff"
+        \\style:
+        \\  warning_color: "#ffaf00"
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\
+    , "inline-theme.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqualStrings("#00ff87", loaded.config.style.status_running_color);
+    try std.testing.expectEqualStrings("#ffaf00", loaded.config.style.warning_color);
+    try std.testing.expectEqualStrings("red", loaded.config.style.status_stopped_color);
+    try std.testing.expect(loaded.hasWarning("themes.dusk.glow"));
+
+    try std.testing.expectError(error.UnknownTheme, load.loadFromSlice(std.testing.allocator, "theme: vaporwave\n", "inline-bad-theme.yaml"));
+}
+
+test "load decodes light and dark theme variants" {
+    var loaded = try load.loadFromSlice(std.testing.allocator,
+        \\theme: paper
+        \\background: light
+        \\themes:
+        \\  paper:
+        \\    dark:
+        \\      status_running_color: "#00ff87"
+        \\    light:
+        \\      status_running_color: "#005f00"
+        \\  ink:
+        \\    light:
+        \\      warning_color: "#af5f00"
+        \\style:
+        \\  status_stopped_color: "#d70000"
+        \\
+    , "inline-theme-variants.yaml");
+    defer loaded.deinit();
+
+    try std.testing.expectEqualStrings("light", loaded.config.background);
+    try std.testing.expectEqualStrings("#00ff87", loaded.config.style.status_running_color);
+    try std.testing.expectEqualStrings("#005f00", loaded.config.light_style.status_running_color);
+    try std.testing.expectEqualStrings("#d70000", loaded.config.light_style.status_stopped_color);
+    try std.testing.expectEqualStrings("136", loaded.config.light_style.status_halting_color);
+    try std.testing.expectEqualStrings("#af5f00", loaded.config.themes.get("ink").?.dark.warning_color);
+
+    try std.testing.expectError(error.InvalidBackground, load.loadFromSlice(std.testing.allocator, "background: sepia\n", "inline-bad-background.yaml"));
+}
+
+test "load decodes category colors in config order" {
+    var loaded = try load.loadFromSlice(std.testing.allocator,
+        \\style:
+        \\  category_color_target: label
+        \\  category_colors:
+        \\    backend: cyan
+        \\    frontend: "#ff87d7"
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\
+    , "inline-category-colors.yaml");
+    defer loaded.deinit();
+
+    const colors = loaded.config.style.category_colors;
+    try std.testing.expectEqual(@as(usize, 2), colors.len);
+    try std.testing.expectEqualStrings("backend", colors[0].category);
+    try std.testing.expectEqualStrings("cyan", colors[0].color);
+    try std.testing.expectEqualStrings("#ff87d7", colors[1].color);
+    try std.testing.expectEqualStrings("label", loaded.config.style.category_color_target);
+    try std.testing.expectEqual(@as(usize, 2), loaded.config.light_style.category_colors.len);
+
+    try std.testing.expectError(error.InvalidCategoryColorTarget, load.loadFromSlice(std.testing.allocator,
+        \\style:
+        \\  category_color_target: row
+        \\
+    , "inline-bad-category-target.yaml"));
+}
+
+test "load keeps quick views in config order" {
+    var loaded = try load.loadFromSlice(std.testing.allocator,
+        \\views:
+        \\  backend:
+        \\    filter: "cat:server"
+        \\    running_only: true
+        \\  all:
+        \\    colour: blue
+        \\procs:
+        \\  api:
+        \\    shell: serve
+        \\
+    , "inline-views.yaml");
+    defer loaded.deinit();
+
+    const views = loaded.config.views.items;
+    try std.testing.expectEqual(@as(usize, 2), views.len);
+    try std.testing.expectEqualStrings("backend", views[0].name);
+    try std.testing.expectEqualStrings("cat:server", views[0].filter);
+    try std.testing.expect(views[0].running_only);
+    try std.testing.expectEqualStrings("all", views[1].name);
+    try std.testing.expectEqualStrings("", views[1].filter);
+    try std.testing.expect(loaded.hasWarning("views.all.colour"));
+}
+
 test "process list width follows clamp behavior" {
     const Case = struct { input: i32, expected: i32 };
     const cases = [_]Case{
@@ -3,6 +3,8 @@
 
 const std = @import("std");
 const discover = @import("../discover/root.zig");
+const hash = @import("hash.zig");
+const launch = @import("launch.zig");
 const load = @import("load.zig");
 
 pub const LoadedRuntimeConfig = load.LoadedConfig;
@@ -13,14 +15,34 @@ pub fn loadInDir(
     allocator: std.mem.Allocator,
     dir: std.fs.Dir,
     config_file: []const u8,
+) !LoadedRuntimeConfig {
+    return loadInDirWithOptions(allocator, dir, config_file, .{});
+}
+
+/// Loads like `loadInDir` with launch `options` applied. The socket identity
+/// stays that of the config without them, so clients and signal commands
+/// that load the plain file reach the same primary.
+pub fn loadInDirWithOptions(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    options: launch.Options,
 ) !LoadedRuntimeConfig {
     var loaded = if (config_file.len > 0)
-        try load.loadFileInDir(allocator, dir, config_file)
+        try load.loadFileInDirWithOptions(allocator, dir, config_file, options)
     else
-        try load.loadDefaultInDir(allocator, dir);
+        try load.loadDefaultInDirWithOptions(allocator, dir, options);
     errdefer loaded.deinit();
 
     const discovery_cwd = std.fs.path.dirname(loaded.config.file_path) orelse ".";
     try discover.apply_mod.apply(loaded.config.allocator, &loaded.config, discovery_cwd);
+    try launch.select(&loaded.config, options);
+    try launch.injectEnv(&loaded.config, options.env);
+
+    if (!options.isEmpty()) {
+        var plain = try loadInDir(allocator, dir, config_file);
+        defer plain.deinit();
+        loaded.config.identity_hash = try hash.toHash(loaded.config.allocator, &plain.config);
+    }
     return loaded;
 }
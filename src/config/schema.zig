@@ -8,6 +8,8 @@ pub const Allocator = std.mem.Allocator;
 pub const WarningKind = enum {
     dead_field,
     unknown_field,
+    /// A key bound to an action that an earlier action already claims.
+    keybinding_conflict,
 };
 
 pub const Warning = struct {
@@ -19,6 +21,13 @@ pub const Warning = struct {
 pub const StringList = std.array_list.Managed([]const u8);
 pub const StringMap = std.StringArrayHashMap([]const u8);
 pub const ProcessMap = std.StringArrayHashMap(ProcessConfig);
+/// Environment variable name to where its secret value comes from.
+pub const SecretMap = std.StringArrayHashMap(SecretConfig);
+/// Profile name to the process labels it selects.
+pub const ProfileMap = std.StringArrayHashMap(StringList);
+pub const ThemeMap = std.StringArrayHashMap(Theme);
+/// Quick views in config order; number keys select them by position.
+pub const ViewList = std.array_list.Managed(ViewConfig);
 
 pub const KeybindingConfig = struct {
     quit: StringList,
@@ -34,7 +43,23 @@ pub const KeybindingConfig = struct {
     toggle_focus: StringList,
     focus_client: StringList,
     focus_server: StringList,
+    rotate_split: StringList,
+    grow_client: StringList,
+    shrink_client: StringList,
+    cycle_view: StringList,
+    cycle_sort: StringList,
+    toggle_pin: StringList,
+    toggle_mark: StringList,
+    toggle_messages: StringList,
     docs: StringList,
+    jump_to_error: StringList,
+    copy_command: StringList,
+    copy_pid: StringList,
+    copy_cwd: StringList,
+    copy_output: StringList,
+    open_url: StringList,
+    open_cwd: StringList,
+    show_env: StringList,
 
     pub fn empty(allocator: Allocator) KeybindingConfig {
         return .{
@@ -51,7 +76,23 @@ pub const KeybindingConfig = struct {
             .toggle_focus = StringList.init(allocator),
             .focus_client = StringList.init(allocator),
             .focus_server = StringList.init(allocator),
+            .rotate_split = StringList.init(allocator),
+            .grow_client = StringList.init(allocator),
+            .shrink_client = StringList.init(allocator),
+            .cycle_view = StringList.init(allocator),
+            .cycle_sort = StringList.init(allocator),
+            .toggle_pin = StringList.init(allocator),
+            .toggle_mark = StringList.init(allocator),
+            .toggle_messages = StringList.init(allocator),
             .docs = StringList.init(allocator),
+            .jump_to_error = StringList.init(allocator),
+            .copy_command = StringList.init(allocator),
+            .copy_pid = StringList.init(allocator),
+            .copy_cwd = StringList.init(allocator),
+            .copy_output = StringList.init(allocator),
+            .open_url = StringList.init(allocator),
+            .open_cwd = StringList.init(allocator),
+            .show_env = StringList.init(allocator),
         };
     }
 
@@ -69,7 +110,23 @@ pub const KeybindingConfig = struct {
         deinitStringList(&self.toggle_focus);
         deinitStringList(&self.focus_client);
         deinitStringList(&self.focus_server);
+        deinitStringList(&self.rotate_split);
+        deinitStringList(&self.grow_client);
+        deinitStringList(&self.shrink_client);
+        deinitStringList(&self.cycle_view);
+        deinitStringList(&self.cycle_sort);
+        deinitStringList(&self.toggle_pin);
+        deinitStringList(&self.toggle_mark);
+        deinitStringList(&self.toggle_messages);
         deinitStringList(&self.docs);
+        deinitStringList(&self.jump_to_error);
+        deinitStringList(&self.copy_command);
+        deinitStringList(&self.copy_pid);
+        deinitStringList(&self.copy_cwd);
+        deinitStringList(&self.copy_output);
+        deinitStringList(&self.open_url);
+        deinitStringList(&self.open_cwd);
+        deinitStringList(&self.show_env);
     }
 };
 
@@ -82,6 +139,24 @@ pub const LayoutConfig = struct {
     sort_process_list_running_first: bool = false,
     placeholder_banner: []const u8 = "",
     enable_debug_process_info: bool = false,
+    details_pane: []const u8 = "",
+    unified_client_ratio: i32 = 0,
+    selection_switch_debounce_ms: i32 = 0,
+    max_output_fps: i32 = 0,
+    /// Live output primary mode writes to the terminal per relay pass; 0 uses
+    /// 256 KiB.
+    max_relay_bytes_per_tick: i32 = 0,
+    /// A `LastLinePreview` name; empty means `off`.
+    last_line_preview: []const u8 = "",
+};
+
+/// Where the process list shows each process's newest output line.
+pub const LastLinePreview = enum {
+    off,
+    /// A second, indented row under the process.
+    line,
+    /// Right-aligned after the label, truncated to the free width.
+    suffix,
 };
 
 pub const StyleConfig = struct {
@@ -92,13 +167,187 @@ pub const StyleConfig = struct {
     status_halting_color: []const u8 = "",
     status_stopped_color: []const u8 = "",
     pointer_char: []const u8 = "",
+    placeholder_banner_color: []const u8 = "",
+    /// Connection warnings such as a stale or lost primary.
+    warning_color: []const u8 = "",
+    /// Badge on processes that printed output since they were last selected.
+    unread_output_color: []const u8 = "",
+    /// An `icons.IconSet` name; empty means `default`.
+    icon_set: []const u8 = "",
+    status_running_icon: []const u8 = "",
+    status_halting_icon: []const u8 = "",
+    status_stopped_icon: []const u8 = "",
+    status_failed_icon: []const u8 = "",
+    status_succeeded_icon: []const u8 = "",
+    status_unhealthy_icon: []const u8 = "",
+    /// Category colors in config order; a process takes the color of its
+    /// first category listed here.
+    category_colors: []const CategoryColor = &.{},
+    /// A `CategoryColorTarget` name; empty means `marker`.
+    category_color_target: []const u8 = "",
+};
+
+pub const CategoryColor = struct {
+    category: []const u8 = "",
+    color: []const u8 = "",
+};
+
+/// What a category color tints in the process list: a swatch before the
+/// status marker, or the process label itself.
+pub const CategoryColorTarget = enum {
+    marker,
+    label,
+};
+
+/// Palettes for each terminal background; a theme without variants uses the
+/// same colors for both.
+pub const Theme = struct {
+    dark: StyleConfig = .{},
+    light: StyleConfig = .{},
+};
+
+/// A named filter preset the TUI can switch to with one key.
+pub const ViewConfig = struct {
+    name: []const u8 = "",
+    /// Filter text, in the same syntax as typed filters.
+    filter: []const u8 = "",
+    running_only: bool = false,
+};
+
+/// Terminal background the TUI picks colors for; `auto` asks the terminal.
+pub const Background = enum {
+    auto,
+    dark,
+    light,
+};
+
+/// Toolchain managers whose environment a process can load before exec.
+pub const EnvLoader = enum {
+    direnv,
+    mise,
+    nvm,
+    custom,
+};
+
+/// Where a secret environment value comes from; loading requires exactly one
+/// of `cmd` and `file`. Only the reference is config: the value is resolved
+/// when the process starts and lives only in its environment.
+pub const SecretConfig = struct {
+    /// Argv whose stdout, minus a trailing newline, is the value, e.g.
+    /// `["pass", "show", "dev/db"]`.
+    cmd: StringList,
+    /// File holding the value, relative to the process's cwd.
+    file: []const u8 = "",
+    /// Argv that decrypts `file`, which is appended as its last argument;
+    /// empty reads `file` as it is.
+    decrypt_cmd: StringList,
+
+    pub fn empty(allocator: Allocator) SecretConfig {
+        return .{
+            .cmd = StringList.init(allocator),
+            .decrypt_cmd = StringList.init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *SecretConfig, allocator: Allocator) void {
+        deinitStringList(&self.cmd);
+        deinitStringList(&self.decrypt_cmd);
+        if (self.file.len > 0) allocator.free(self.file);
+    }
+
+    pub fn clone(self: *const SecretConfig, allocator: Allocator) !SecretConfig {
+        var out = SecretConfig.empty(allocator);
+        errdefer out.deinit(allocator);
+        if (self.file.len > 0) out.file = try allocator.dupe(u8, self.file);
+        for (self.cmd.items) |item| try appendOwned(allocator, &out.cmd, item);
+        for (self.decrypt_cmd.items) |item| try appendOwned(allocator, &out.decrypt_cmd, item);
+        return out;
+    }
+};
+
+/// How a process runs. `docker` processes run their `image` in a container
+/// whose logs are followed in the PTY.
+pub const ProcessType = enum {
+    native,
+    docker,
+};
+
+/// Minimum severity written by the log sink.
+pub const LogLevel = enum {
+    debug,
+    info,
+    warn,
+    @"error",
+};
+
+/// Destinations that receive a copy of a process's output. Specs are written
+/// `kind:target`, e.g. `file:logs/api.log` or `syslog:api`.
+pub const OutputSinkKind = enum {
+    file,
+    syslog,
+    journald,
+};
+
+pub const LogFormat = enum {
+    text,
+    json,
+};
+
+/// What a client's `quit` key does with the processes still running.
+pub const QuitAction = enum {
+    stop,
+    detach,
+    ask,
+};
+
+/// How BEL characters in process output are surfaced.
+pub const BellAction = enum {
+    /// Ring the client terminal's bell and mark the process in the list.
+    ring,
+    mark,
+    off,
 };
 
 pub const GeneralConfig = struct {
     procs_from_make_targets: bool = false,
     procs_from_package_json: bool = false,
+    /// A `QuitAction` name; empty means `stop`.
+    on_quit: []const u8 = "",
+    /// A `BellAction` name; empty means `ring`.
+    on_bell: []const u8 = "",
+    /// How often the Primary Server checks for status changes to broadcast.
+    refresh_interval_ms: i32 = 0,
+    /// How often output streams check for new output.
+    output_poll_interval_ms: i32 = 0,
+    /// How often `watch` globs are rescanned.
+    watch_poll_interval_ms: i32 = 0,
+    /// `watch_debounce_ms` for processes that do not set their own.
+    watch_debounce_ms: i32 = 0,
 };
 
+pub const SecurityConfig = struct {
+    /// Require every IPC connection to present the random token the Primary
+    /// Server writes next to its socket.
+    token: bool = false,
+};
+
+/// Inclusive range for a tunable interval; 0 always means the default.
+pub const IntervalBounds = struct {
+    min: i32,
+    max: i32,
+
+    pub fn contains(self: IntervalBounds, value: i32) bool {
+        return value == 0 or (value >= self.min and value <= self.max);
+    }
+};
+
+pub const refresh_interval_bounds: IntervalBounds = .{ .min = 10, .max = 5000 };
+pub const output_poll_interval_bounds: IntervalBounds = .{ .min = 5, .max = 1000 };
+pub const watch_poll_interval_bounds: IntervalBounds = .{ .min = 50, .max = 60_000 };
+pub const watch_debounce_bounds: IntervalBounds = .{ .min = 10, .max = 60_000 };
+pub const max_output_fps_bounds: IntervalBounds = .{ .min = 1, .max = 240 };
+pub const max_relay_bytes_per_tick_bounds: IntervalBounds = .{ .min = 4096, .max = 64 * 1024 * 1024 };
+
 /// Owned config for one managed process. String ownership is explicit because
 /// entries may originate from YAML, discovery, defaults, or tests.
 pub const ProcessConfig = struct {
@@ -106,37 +355,136 @@ pub const ProcessConfig = struct {
     cmd: StringList,
     cwd: []const u8 = "",
     env: StringMap,
+    /// Starts the process from an empty environment instead of proctmux's
+    /// own; only `env`, `add_path`, and the `PROCTMUX_*` variables are set.
+    env_clear: bool = false,
+    /// Environment variables whose values are resolved at start from a
+    /// command or file; they override `env` and are never serialized.
+    secrets: SecretMap,
     stop: i32 = 0,
     stop_timeout_ms: i32 = 0,
     autostart: bool = false,
     autofocus: bool = false,
     description: []const u8 = "",
     docs: []const u8 = "",
+    /// Address the process serves, e.g. `http://localhost:3000`; the
+    /// `open_url` key opens it in the browser.
+    url: []const u8 = "",
+    /// Opens `url` once the process is running and its port accepts
+    /// connections, on every start.
+    open_url: bool = false,
     meta_tags: StringList,
     categories: StringList,
     add_path: StringList,
     terminal_rows: i32 = 0,
     terminal_cols: i32 = 0,
+    /// TERM for the child; empty keeps proctmux's own.
+    term: []const u8 = "",
+    /// COLORTERM for the child, e.g. `truecolor`; empty keeps proctmux's own.
+    colorterm: []const u8 = "",
+    /// Sets FORCE_COLOR=1 and CLICOLOR_FORCE=1 for tools that turn color off
+    /// when they do not recognize the terminal.
+    force_color: bool = false,
+    /// Gives the child a stderr pipe instead of the terminal, so its error
+    /// output is shown in color and kept apart for `logs --stderr`.
+    separate_stderr: bool = false,
     on_kill: StringList,
+    /// Lifecycle hook argv run by the process controller; see `proc/hooks.zig`.
+    pre_start: StringList,
+    post_start: StringList,
+    pre_stop: StringList,
+    post_stop: StringList,
+    /// Per-hook limit for the lifecycle hooks; 0 uses 30s.
+    hook_timeout_ms: i32 = 0,
+    /// Stops the running process after this long without output or input;
+    /// 0 never does.
+    idle_timeout_ms: i32 = 0,
+    /// Flags the running process as possibly stuck after this long without
+    /// output; 0 never does.
+    expect_output_within_ms: i32 = 0,
+    /// Holds back this process's autostart, on top of `autostart_stagger_ms`.
+    startup_delay_ms: i32 = 0,
+    /// Failed exits within `crash_loop_window_ms` that stop automatic
+    /// restarts; 0 uses 5.
+    crash_loop_exits: i32 = 0,
+    /// 0 uses 60s.
+    crash_loop_window_ms: i32 = 0,
+    /// Overrides the global `shell_cmd` for this process's `shell` string.
+    shell_cmd: StringList,
+    login_shell: bool = false,
+    interactive_shell: bool = false,
+    /// An `EnvLoader` name; empty runs the command directly.
+    env_loader: []const u8 = "",
+    /// Wrapper argv for the `custom` env loader.
+    env_loader_cmd: StringList,
+    /// `OutputSinkKind` specs with `{label}` and `{category}` already expanded.
+    output_sinks: StringList,
+    /// Globs, relative to `cwd`, whose changes restart the running process.
+    watch: StringList,
+    /// Globs pruned from the `watch` walk; `.git` is always skipped.
+    watch_ignore: StringList,
+    /// Quiet period after the last change before restarting; 0 uses 500.
+    watch_debounce_ms: i32 = 0,
+    /// A `ProcessType` name; empty means `native`.
+    @"type": []const u8 = "",
+    /// Container image for `type: docker`.
+    image: []const u8 = "",
+    /// `docker run --publish` specs, e.g. `8080:80`.
+    ports: StringList,
+    /// `docker run --volume` specs; `{config_dir}` and `{git_root}` expand.
+    volumes: StringList,
+    /// Docker container name; loading defaults it to `proctmux-<label>`.
+    container_name: []const u8 = "",
+    /// Instances to run; loading expands more than one into `<label>-1`..`<label>-N`.
+    replicas: i32 = 0,
+    /// Label of the definition a replica was expanded from; empty otherwise.
+    replica_group: []const u8 = "",
     owns_scalar_strings: bool = false,
 
     pub fn empty(allocator: Allocator) ProcessConfig {
         return .{
             .cmd = StringList.init(allocator),
             .env = StringMap.init(allocator),
+            .secrets = SecretMap.init(allocator),
             .meta_tags = StringList.init(allocator),
             .categories = StringList.init(allocator),
             .add_path = StringList.init(allocator),
             .on_kill = StringList.init(allocator),
+            .pre_start = StringList.init(allocator),
+            .post_start = StringList.init(allocator),
+            .pre_stop = StringList.init(allocator),
+            .post_stop = StringList.init(allocator),
+            .shell_cmd = StringList.init(allocator),
+            .env_loader_cmd = StringList.init(allocator),
+            .output_sinks = StringList.init(allocator),
+            .watch = StringList.init(allocator),
+            .watch_ignore = StringList.init(allocator),
+            .ports = StringList.init(allocator),
+            .volumes = StringList.init(allocator),
         };
     }
 
+    pub fn isDocker(self: *const ProcessConfig) bool {
+        return std.mem.eql(u8, self.@"type", @tagName(ProcessType.docker));
+    }
+
     pub fn deinit(self: *ProcessConfig, allocator: Allocator) void {
         deinitStringList(&self.cmd);
         deinitStringList(&self.meta_tags);
         deinitStringList(&self.categories);
         deinitStringList(&self.add_path);
         deinitStringList(&self.on_kill);
+        deinitStringList(&self.pre_start);
+        deinitStringList(&self.post_start);
+        deinitStringList(&self.pre_stop);
+        deinitStringList(&self.post_stop);
+        deinitStringList(&self.shell_cmd);
+        deinitStringList(&self.env_loader_cmd);
+        deinitStringList(&self.output_sinks);
+        deinitStringList(&self.watch);
+        deinitStringList(&self.watch_ignore);
+        deinitStringList(&self.ports);
+        deinitStringList(&self.volumes);
 
         var it = self.env.iterator();
         while (it.next()) |entry| {
@@ -144,13 +492,92 @@ pub const ProcessConfig = struct {
             allocator.free(entry.value_ptr.*);
         }
         self.env.deinit();
+        deinitSecretMap(allocator, &self.secrets);
 
         if (self.owns_scalar_strings) {
             if (self.shell.len > 0) allocator.free(self.shell);
             if (self.cwd.len > 0) allocator.free(self.cwd);
             if (self.description.len > 0) allocator.free(self.description);
             if (self.docs.len > 0) allocator.free(self.docs);
+            if (self.url.len > 0) allocator.free(self.url);
+            if (self.term.len > 0) allocator.free(self.term);
+            if (self.colorterm.len > 0) allocator.free(self.colorterm);
+            if (self.env_loader.len > 0) allocator.free(self.env_loader);
+            if (self.@"type".len > 0) allocator.free(self.@"type");
+            if (self.image.len > 0) allocator.free(self.image);
+            if (self.container_name.len > 0) allocator.free(self.container_name);
+            if (self.replica_group.len > 0) allocator.free(self.replica_group);
+        }
+    }
+
+    /// Deep copy whose strings are all owned by `allocator`.
+    pub fn clone(self: *const ProcessConfig, allocator: Allocator) !ProcessConfig {
+        var out = ProcessConfig.empty(allocator);
+        errdefer out.deinit(allocator);
+        out.owns_scalar_strings = true;
+
+        if (self.shell.len > 0) out.shell = try allocator.dupe(u8, self.shell);
+        if (self.cwd.len > 0) out.cwd = try allocator.dupe(u8, self.cwd);
+        if (self.description.len > 0) out.description = try allocator.dupe(u8, self.description);
+        if (self.docs.len > 0) out.docs = try allocator.dupe(u8, self.docs);
+        if (self.url.len > 0) out.url = try allocator.dupe(u8, self.url);
+        if (self.term.len > 0) out.term = try allocator.dupe(u8, self.term);
+        if (self.colorterm.len > 0) out.colorterm = try allocator.dupe(u8, self.colorterm);
+        if (self.env_loader.len > 0) out.env_loader = try allocator.dupe(u8, self.env_loader);
+        if (self.@"type".len > 0) out.@"type" = try allocator.dupe(u8, self.@"type");
+        if (self.image.len > 0) out.image = try allocator.dupe(u8, self.image);
+        if (self.container_name.len > 0) out.container_name = try allocator.dupe(u8, self.container_name);
+        if (self.replica_group.len > 0) out.replica_group = try allocator.dupe(u8, self.replica_group);
+        out.env_clear = self.env_clear;
+        out.stop = self.stop;
+        out.stop_timeout_ms = self.stop_timeout_ms;
+        out.autostart = self.autostart;
+        out.autofocus = self.autofocus;
+        out.open_url = self.open_url;
+        out.terminal_rows = self.terminal_rows;
+        out.terminal_cols = self.terminal_cols;
+        out.force_color = self.force_color;
+        out.separate_stderr = self.separate_stderr;
+        out.login_shell = self.login_shell;
+        out.interactive_shell = self.interactive_shell;
+        out.watch_debounce_ms = self.watch_debounce_ms;
+        out.hook_timeout_ms = self.hook_timeout_ms;
+        out.idle_timeout_ms = self.idle_timeout_ms;
+        out.expect_output_within_ms = self.expect_output_within_ms;
+        out.startup_delay_ms = self.startup_delay_ms;
+        out.crash_loop_exits = self.crash_loop_exits;
+        out.crash_loop_window_ms = self.crash_loop_window_ms;
+        out.replicas = self.replicas;
+
+        for (self.cmd.items) |item| try appendOwned(allocator, &out.cmd, item);
+        for (self.meta_tags.items) |item| try appendOwned(allocator, &out.meta_tags, item);
+        for (self.categories.items) |item| try appendOwned(allocator, &out.categories, item);
+        for (self.add_path.items) |item| try appendOwned(allocator, &out.add_path, item);
+        for (self.on_kill.items) |item| try appendOwned(allocator, &out.on_kill, item);
+        for (self.pre_start.items) |item| try appendOwned(allocator, &out.pre_start, item);
+        for (self.post_start.items) |item| try appendOwned(allocator, &out.post_start, item);
+        for (self.pre_stop.items) |item| try appendOwned(allocator, &out.pre_stop, item);
+        for (self.post_stop.items) |item| try appendOwned(allocator, &out.post_stop, item);
+        for (self.shell_cmd.items) |item| try appendOwned(allocator, &out.shell_cmd, item);
+        for (self.env_loader_cmd.items) |item| try appendOwned(allocator, &out.env_loader_cmd, item);
+        for (self.output_sinks.items) |item| try appendOwned(allocator, &out.output_sinks, item);
+        for (self.watch.items) |item| try appendOwned(allocator, &out.watch, item);
+        for (self.watch_ignore.items) |item| try appendOwned(allocator, &out.watch_ignore, item);
+        for (self.ports.items) |item| try appendOwned(allocator, &out.ports, item);
+        for (self.volumes.items) |item| try appendOwned(allocator, &out.volumes, item);
+
+        var env_it = self.env.iterator();
+        while (env_it.next()) |entry| {
+            try putOwnedString(allocator, &out.env, entry.key_ptr.*, entry.value_ptr.*);
         }
+        var secret_it = self.secrets.iterator();
+        while (secret_it.next()) |entry| {
+            var secret = try entry.value_ptr.clone(allocator);
+            errdefer secret.deinit(allocator);
+            try putOwnedSecret(allocator, &out.secrets, entry.key_ptr.*, secret);
+        }
+
+        return out;
     }
 };
 
@@ -163,11 +590,76 @@ pub const Config = struct {
     keybinding: KeybindingConfig,
     layout: LayoutConfig = .{},
     style: StyleConfig = .{},
+    /// Colors used on light terminal backgrounds. Not read from YAML: it
+    /// starts from the explicit `style` colors and fills the rest from the
+    /// theme's light variant and the light defaults.
+    light_style: StyleConfig = .{},
+    /// A `Background` name; empty means `auto`.
+    background: []const u8 = "",
+    /// Name of a built-in theme or an entry in `themes`; empty uses none.
+    theme: []const u8 = "",
+    themes: ThemeMap,
     general: GeneralConfig = .{},
+    security: SecurityConfig = .{},
     shell_cmd: StringList,
     log_file: []const u8 = "",
     stdout_debug_log_file: []const u8 = "",
     owns_log_paths: bool = false,
+    /// A `LogLevel` name; empty means `info`.
+    log_level: []const u8 = "",
+    /// A `LogFormat` name; empty means `text`.
+    log_format: []const u8 = "",
+    /// Rotate `log_file` past this many MiB; 0 uses the default, negative
+    /// never rotates.
+    log_max_size_mb: i32 = 0,
+    /// Rotated files kept beside `log_file`; 0 uses the default.
+    log_max_backups: i32 = 0,
+    /// Gzip rotated files.
+    log_compress: bool = false,
+    /// Overall budget for stopping every process on shutdown; 0 uses the default.
+    shutdown_timeout_ms: i32 = 0,
+    /// Exits once an `idle_timeout_ms` stop leaves no process running.
+    auto_shutdown: bool = false,
+    /// Gap between consecutive autostarts; 0 starts them all at once.
+    autostart_stagger_ms: i32 = 0,
+    /// `host:port` for the Prometheus endpoint; empty disables it.
+    metrics_addr: []const u8 = "",
+    /// Absolute directory for sockets; empty follows `$XDG_RUNTIME_DIR`.
+    runtime_dir: []const u8 = "",
+    /// Absolute directory for saved UI state; empty follows `$XDG_STATE_HOME`.
+    state_dir: []const u8 = "",
+    /// Directory of plugin executables that receive lifecycle events; relative
+    /// to the config file, empty disables plugins.
+    plugins_dir: []const u8 = "",
+    /// Per-event limit for each plugin run; 0 uses 5s.
+    plugin_timeout_ms: i32 = 0,
+    /// Output lines matching any of these count as errors: case-insensitive
+    /// substrings, or regexes with a `re:` prefix.
+    error_patterns: StringList,
+    /// Output spans replaced with `[REDACTED]` before they reach scrollback
+    /// or output sinks: case-insensitive substrings, or regexes with a `re:`
+    /// prefix. Empty by default, since matching holds back unfinished lines.
+    redact_patterns: StringList,
+    /// Command that reads copied text on stdin; empty copies with OSC 52 and
+    /// falls back to a detected clipboard tool for text too large for it.
+    clipboard_cmd: StringList,
+    /// Output lines `copy_output` copies; 0 uses the default.
+    clipboard_output_lines: i32 = 0,
+    /// Command given a process `url` to open; empty uses `open` on macOS and
+    /// `xdg-open` elsewhere.
+    open_cmd: StringList,
+    /// Command given a process cwd to open; empty uses `open_cmd`.
+    editor_cmd: StringList,
+    /// Fails loading on a `${NAME}` nothing defines instead of leaving it for
+    /// the process shell.
+    strict_vars: bool = false,
+    /// Hash of the config as written, set when launch options reshape procs so
+    /// clients that load the plain file still find this primary's socket.
+    /// Empty means the hash is computed from this config.
+    identity_hash: []const u8 = "",
+    /// Named process selections for `--profile`.
+    profiles: ProfileMap,
+    views: ViewList,
     procs: ProcessMap,
 
     pub fn empty(allocator: Allocator) Config {
@@ -175,6 +667,14 @@ pub const Config = struct {
             .allocator = allocator,
             .keybinding = KeybindingConfig.empty(allocator),
             .shell_cmd = StringList.init(allocator),
+            .error_patterns = StringList.init(allocator),
+            .redact_patterns = StringList.init(allocator),
+            .clipboard_cmd = StringList.init(allocator),
+            .open_cmd = StringList.init(allocator),
+            .editor_cmd = StringList.init(allocator),
+            .profiles = ProfileMap.init(allocator),
+            .themes = ThemeMap.init(allocator),
+            .views = ViewList.init(allocator),
             .procs = ProcessMap.init(allocator),
         };
     }
@@ -182,12 +682,27 @@ pub const Config = struct {
     pub fn deinit(self: *Config) void {
         self.keybinding.deinit();
         deinitStringList(&self.shell_cmd);
+        deinitStringList(&self.error_patterns);
+        deinitStringList(&self.redact_patterns);
+        deinitStringList(&self.clipboard_cmd);
+        deinitStringList(&self.open_cmd);
+        deinitStringList(&self.editor_cmd);
         var it = self.procs.iterator();
         while (it.next()) |entry| {
             self.allocator.free(entry.key_ptr.*);
             entry.value_ptr.deinit(self.allocator);
         }
         self.procs.deinit();
+        var profile_it = self.profiles.iterator();
+        while (profile_it.next()) |entry| {
+            self.allocator.free(entry.key_ptr.*);
+            deinitStringList(entry.value_ptr);
+        }
+        self.profiles.deinit();
+        for (self.themes.keys()) |name| self.allocator.free(name);
+        self.themes.deinit();
+        for (self.views.items) |view| self.allocator.free(view.name);
+        self.views.deinit();
         if (self.owns_file_path and self.file_path.len > 0) self.allocator.free(self.file_path);
         if (self.owns_log_paths) {
             if (self.log_file.len > 0) self.allocator.free(self.log_file);
@@ -206,6 +721,29 @@ pub fn appendOwned(allocator: Allocator, list: *StringList, value: []const u8) !
     try list.append(try allocator.dupe(u8, value));
 }
 
+pub fn deinitSecretMap(allocator: Allocator, map: *SecretMap) void {
+    var it = map.iterator();
+    while (it.next()) |entry| {
+        allocator.free(entry.key_ptr.*);
+        entry.value_ptr.deinit(allocator);
+    }
+    map.deinit();
+}
+
+/// Takes ownership of `secret`, replacing any earlier secret for `key`.
+pub fn putOwnedSecret(allocator: Allocator, map: *SecretMap, key: []const u8, secret: SecretConfig) !void {
+    const gop = try map.getOrPut(key);
+    if (gop.found_existing) {
+        gop.value_ptr.deinit(allocator);
+    } else {
+        gop.key_ptr.* = allocator.dupe(u8, key) catch |err| {
+            map.swapRemoveAt(gop.index);
+            return err;
+        };
+    }
+    gop.value_ptr.* = secret;
+}
+
 pub fn putOwnedString(allocator: Allocator, map: *StringMap, key: []const u8, value: []const u8) !void {
     const owned_key = try allocator.dupe(u8, key);
     errdefer allocator.free(owned_key);
@@ -30,6 +30,15 @@ pub fn content() []const u8 {
     \\general:
     \\  procs_from_make_targets: false
     \\  procs_from_package_json: false
+    \\  on_quit: "stop"
+    \\  on_bell: "ring"
+    \\  refresh_interval_ms: 50
+    \\  output_poll_interval_ms: 20
+    \\  watch_poll_interval_ms: 500
+    \\  watch_debounce_ms: 500
+    \\
+    \\security:
+    \\  token: false
     \\
     \\layout:
     \\  processes_list_width: 30
@@ -39,15 +48,28 @@ pub fn content() []const u8 {
     \\  sort_process_list_running_first: false
     \\  category_search_prefix: "cat:"
     \\  enable_debug_process_info: false
+    \\  details_pane: ""
+    \\  unified_client_ratio: 0
+    \\  selection_switch_debounce_ms: 0
+    \\  max_output_fps: 30
+    \\  max_relay_bytes_per_tick: 262144
+    \\  last_line_preview: "off"
+    \\
+    \\theme: "default"
+    \\background: "auto"
     \\
     \\style:
     \\  pointer_char: "▶"
+    \\  placeholder_banner_color: "cyan"
     \\  selected_process_color: "white"
     \\  selected_process_bg_color: "magenta"
     \\  unselected_process_color: "blue"
     \\  status_running_color: "green"
     \\  status_halting_color: "yellow"
     \\  status_stopped_color: "red"
+    \\  warning_color: "yellow"
+    \\  unread_output_color: "cyan"
+    \\  icon_set: "default"
     \\
     \\keybinding:
     \\  quit: ["q", "ctrl+c"]
@@ -63,11 +85,42 @@ pub fn content() []const u8 {
     \\  toggle_focus: ["ctrl+w"]
     \\  focus_client: ["ctrl+left"]
     \\  focus_server: ["ctrl+right"]
+    \\  rotate_split: ["ctrl+o"]
+    \\  grow_client: ["ctrl+shift+right"]
+    \\  shrink_client: ["ctrl+shift+left"]
+    \\  cycle_view: ["v"]
+    \\  cycle_sort: ["S"]
+    \\  toggle_pin: ["p"]
+    \\  toggle_mark: ["space"]
+    \\  toggle_messages: ["m"]
     \\  docs: ["d"]
+    \\  jump_to_error: ["e"]
+    \\  copy_command: ["y"]
+    \\  copy_pid: ["P"]
+    \\  copy_cwd: ["C"]
+    \\  copy_output: ["Y"]
+    \\  open_url: ["o"]
+    \\  open_cwd: ["O"]
+    \\  show_env: ["E"]
     \\
     \\shell_cmd: ["sh", "-c"]
     \\log_file: ""
     \\stdout_debug_log_file: ""
+    \\log_level: "info"
+    \\log_format: "text"
+    \\log_max_size_mb: 10
+    \\log_max_backups: 3
+    \\log_compress: false
+    \\metrics_addr: ""
+    \\runtime_dir: ""
+    \\state_dir: ""
+    \\plugins_dir: ""
+    \\error_patterns: ["error", "fatal", "panic", "exception"]
+    \\redact_patterns: []
+    \\clipboard_cmd: []
+    \\clipboard_output_lines: 200
+    \\open_cmd: []
+    \\editor_cmd: []
     \\
     ;
 }
@@ -0,0 +1,153 @@
+//! Named color themes for the TUI.
+//! A theme has a dark and a light palette and fills the `style` fields the config leaves empty before defaults apply, so explicit style values always win; themes in the config's `themes` map shadow built-ins of the same name.
+
+const std = @import("std");
+const schema = @import("schema.zig");
+
+pub const Builtin = struct {
+    name: []const u8,
+    theme: schema.Theme,
+};
+
+/// `default` is empty on purpose: the regular defaults are the default theme.
+pub const builtins = [_]Builtin{
+    .{ .name = "default", .theme = .{} },
+    .{ .name = "dracula", .theme = .{
+        .dark = .{
+            .selected_process_color = "#f8f8f2",
+            .selected_process_bg_color = "#44475a",
+            .unselected_process_color = "#f8f8f2",
+            .status_running_color = "#50fa7b",
+            .status_halting_color = "#f1fa8c",
+            .status_stopped_color = "#ff5555",
+            .placeholder_banner_color = "#bd93f9",
+            .warning_color = "#ffb86c",
+            .unread_output_color = "#8be9fd",
+        },
+        .light = .{
+            .selected_process_color = "#f8f8f2",
+            .selected_process_bg_color = "#644ac9",
+            .unselected_process_color = "#1f1f1f",
+            .status_running_color = "#14710a",
+            .status_halting_color = "#846e15",
+            .status_stopped_color = "#cb3a2a",
+            .placeholder_banner_color = "#644ac9",
+            .warning_color = "#a34d14",
+            .unread_output_color = "#036a96",
+        },
+    } },
+    .{ .name = "solarized", .theme = .{
+        .dark = .{
+            .selected_process_color = "#fdf6e3",
+            .selected_process_bg_color = "#268bd2",
+            .unselected_process_color = "#839496",
+            .status_running_color = "#859900",
+            .status_halting_color = "#b58900",
+            .status_stopped_color = "#dc322f",
+            .placeholder_banner_color = "#2aa198",
+            .warning_color = "#cb4b16",
+            .unread_output_color = "#2aa198",
+        },
+        .light = .{
+            .selected_process_color = "#fdf6e3",
+            .selected_process_bg_color = "#268bd2",
+            .unselected_process_color = "#657b83",
+            .status_running_color = "#859900",
+            .status_halting_color = "#b58900",
+            .status_stopped_color = "#dc322f",
+            .placeholder_banner_color = "#2aa198",
+            .warning_color = "#cb4b16",
+            .unread_output_color = "#2aa198",
+        },
+    } },
+    .{ .name = "nord", .theme = .{
+        .dark = .{
+            .selected_process_color = "#eceff4",
+            .selected_process_bg_color = "#5e81ac",
+            .unselected_process_color = "#d8dee9",
+            .status_running_color = "#a3be8c",
+            .status_halting_color = "#ebcb8b",
+            .status_stopped_color = "#bf616a",
+            .placeholder_banner_color = "#88c0d0",
+            .warning_color = "#d08770",
+            .unread_output_color = "#88c0d0",
+        },
+        .light = .{
+            .selected_process_color = "#eceff4",
+            .selected_process_bg_color = "#5e81ac",
+            .unselected_process_color = "#3b4252",
+            .status_running_color = "#4f7a3a",
+            .status_halting_color = "#9a7b1c",
+            .status_stopped_color = "#bf616a",
+            .placeholder_banner_color = "#5e81ac",
+            .warning_color = "#b8643f",
+            .unread_output_color = "#5e81ac",
+        },
+    } },
+    .{ .name = "gruvbox", .theme = .{
+        .dark = .{
+            .selected_process_color = "#fbf1c7",
+            .selected_process_bg_color = "#458588",
+            .unselected_process_color = "#ebdbb2",
+            .status_running_color = "#b8bb26",
+            .status_halting_color = "#fabd2f",
+            .status_stopped_color = "#fb4934",
+            .placeholder_banner_color = "#83a598",
+            .warning_color = "#fe8019",
+            .unread_output_color = "#8ec07c",
+        },
+        .light = .{
+            .selected_process_color = "#fbf1c7",
+            .selected_process_bg_color = "#076678",
+            .unselected_process_color = "#3c3836",
+            .status_running_color = "#79740e",
+            .status_halting_color = "#b57614",
+            .status_stopped_color = "#9d0006",
+            .placeholder_banner_color = "#076678",
+            .warning_color = "#af3a03",
+            .unread_output_color = "#427b58",
+        },
+    } },
+};
+
+/// Finds `name` among the config's own themes, then the built-ins.
+pub fn find(cfg: *const schema.Config, name: []const u8) ?schema.Theme {
+    if (cfg.themes.get(name)) |theme| return theme;
+    for (builtins) |builtin| {
+        if (std.mem.eql(u8, builtin.name, name)) return builtin.theme;
+    }
+    return null;
+}
+
+/// Seeds `light_style` from the explicit `style` colors, then fills the empty
+/// fields of each from the matching variant of `cfg.theme`. Runs before
+/// defaults so a theme that leaves a field empty still gets the default.
+pub fn apply(cfg: *schema.Config) !void {
+    const theme = if (cfg.theme.len == 0) schema.Theme{} else find(cfg, cfg.theme) orelse return error.UnknownTheme;
+    cfg.light_style = cfg.style;
+    fillEmpty(&cfg.style, theme.dark);
+    fillEmpty(&cfg.light_style, theme.light);
+}
+
+fn fillEmpty(style: *schema.StyleConfig, from: schema.StyleConfig) void {
+    inline for (std.meta.fields(schema.StyleConfig)) |field| {
+        if (@field(style, field.name).len == 0) @field(style, field.name) = @field(from, field.name);
+    }
+}
+
+test "themes fill only the style fields the config leaves empty" {
+    var cfg = schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.theme = "dracula";
+    cfg.style.status_running_color = "cyan";
+
+    try apply(&cfg);
+    try std.testing.expectEqualStrings("cyan", cfg.style.status_running_color);
+    try std.testing.expectEqualStrings("#ff5555", cfg.style.status_stopped_color);
+    try std.testing.expectEqualStrings("", cfg.style.pointer_char);
+    try std.testing.expectEqualStrings("cyan", cfg.light_style.status_running_color);
+    try std.testing.expectEqualStrings("#cb3a2a", cfg.light_style.status_stopped_color);
+
+    cfg.theme = "vaporwave";
+    try std.testing.expectError(error.UnknownTheme, apply(&cfg));
+}
@@ -36,38 +36,8 @@ fn merge(allocator: std.mem.Allocator, cfg: *config.schema.Config, discovered: *
         if (cfg.procs.contains(entry.key_ptr.*)) continue;
         const key = try allocator.dupe(u8, entry.key_ptr.*);
         errdefer allocator.free(key);
-        var value = try cloneProcessConfig(allocator, entry.value_ptr.*);
+        var value = try entry.value_ptr.clone(allocator);
         errdefer value.deinit(allocator);
         try cfg.procs.put(key, value);
     }
 }
-
-fn cloneProcessConfig(allocator: std.mem.Allocator, source: config.schema.ProcessConfig) !config.schema.ProcessConfig {
-    var out = config.schema.ProcessConfig.empty(allocator);
-    errdefer out.deinit(allocator);
-    out.owns_scalar_strings = true;
-
-    if (source.shell.len > 0) out.shell = try allocator.dupe(u8, source.shell);
-    if (source.cwd.len > 0) out.cwd = try allocator.dupe(u8, source.cwd);
-    if (source.description.len > 0) out.description = try allocator.dupe(u8, source.description);
-    if (source.docs.len > 0) out.docs = try allocator.dupe(u8, source.docs);
-    out.stop = source.stop;
-    out.stop_timeout_ms = source.stop_timeout_ms;
-    out.autostart = source.autostart;
-    out.autofocus = source.autofocus;
-    out.terminal_rows = source.terminal_rows;
-    out.terminal_cols = source.terminal_cols;
-
-    for (source.cmd.items) |item| try config.schema.appendOwned(allocator, &out.cmd, item);
-    for (source.meta_tags.items) |item| try config.schema.appendOwned(allocator, &out.meta_tags, item);
-    for (source.categories.items) |item| try config.schema.appendOwned(allocator, &out.categories, item);
-    for (source.add_path.items) |item| try config.schema.appendOwned(allocator, &out.add_path, item);
-    for (source.on_kill.items) |item| try config.schema.appendOwned(allocator, &out.on_kill, item);
-
-    var env_it = source.env.iterator();
-    while (env_it.next()) |entry| {
-        try config.schema.putOwnedString(allocator, &out.env, entry.key_ptr.*, entry.value_ptr.*);
-    }
-
-    return out;
-}
@@ -0,0 +1,91 @@
+//! State change notification for event-driven loops.
+//! Producers bump a generation counter and wake its futex, so background loops block until process state actually changes instead of polling on a timer.
+
+const std = @import("std");
+
+/// Any number of loops may wait on one Signal; each remembers the generation
+/// it last saw. `notify` is a single atomic add plus a futex wake, so process
+/// output can call it on every write.
+pub const Signal = struct {
+    generation: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
+    /// Earliest scheduled wakeup in Unix milliseconds; 0 means none.
+    wake_at_ms: std.atomic.Value(i64) = std.atomic.Value(i64).init(0),
+
+    pub fn notify(self: *Signal) void {
+        _ = self.generation.fetchAdd(1, .seq_cst);
+        std.Thread.Futex.wake(&self.generation, std.math.maxInt(u32));
+    }
+
+    /// Wakes waiters once `at_ms` passes, for state that changes with time
+    /// rather than with an event. The earliest pending time wins.
+    pub fn notifyAt(self: *Signal, at_ms: i64) void {
+        var current = self.wake_at_ms.load(.seq_cst);
+        while (current == 0 or at_ms < current) {
+            current = self.wake_at_ms.cmpxchgWeak(current, at_ms, .seq_cst, .seq_cst) orelse break;
+        }
+        // Waiters recompute their timeout against the new time.
+        self.notify();
+    }
+
+    pub fn current(self: *const Signal) u32 {
+        return self.generation.load(.seq_cst);
+    }
+
+    /// Blocks until the generation moves past `seen`, a scheduled wakeup is
+    /// due, or `timeout_ms` passes; null waits for a change alone. Returns the
+    /// generation to pass next time.
+    pub fn wait(self: *Signal, seen: u32, timeout_ms: ?u64) u32 {
+        var timeout_ns: ?u64 = if (timeout_ms) |ms| ms * std.time.ns_per_ms else null;
+        const due_ms = self.wake_at_ms.load(.seq_cst);
+        if (due_ms != 0) {
+            const until_ms = due_ms - std.time.milliTimestamp();
+            if (until_ms <= 0) {
+                _ = self.wake_at_ms.cmpxchgStrong(due_ms, 0, .seq_cst, .seq_cst);
+                return self.current();
+            }
+            const until_ns = @as(u64, @intCast(until_ms)) * std.time.ns_per_ms;
+            timeout_ns = if (timeout_ns) |ns| @min(ns, until_ns) else until_ns;
+        }
+
+        if (timeout_ns) |ns| {
+            std.Thread.Futex.timedWait(&self.generation, seen, ns) catch {};
+        } else {
+            std.Thread.Futex.wait(&self.generation, seen);
+        }
+        if (due_ms != 0 and std.time.milliTimestamp() >= due_ms) {
+            _ = self.wake_at_ms.cmpxchgStrong(due_ms, 0, .seq_cst, .seq_cst);
+        }
+        return self.current();
+    }
+};
+
+test "signal wait returns on notify, timeout, and scheduled wakeups" {
+    var signal = Signal{};
+    const seen = signal.current();
+
+    // Nothing changed, so only the timeout ends the wait.
+    try std.testing.expectEqual(seen, signal.wait(seen, 1));
+
+    signal.notify();
+    const next = signal.wait(seen, null);
+    try std.testing.expect(next != seen);
+
+    const Notifier = struct {
+        fn run(target: *Signal) void {
+            std.Thread.sleep(5 * std.time.ns_per_ms);
+            target.notify();
+        }
+    };
+    const thread = try std.Thread.spawn(.{}, Notifier.run, .{&signal});
+    const woken = signal.wait(next, null);
+    thread.join();
+    try std.testing.expect(woken != next);
+
+    signal.notifyAt(std.time.milliTimestamp() + 5);
+    signal.notifyAt(std.time.milliTimestamp() + 60_000);
+    const scheduled = signal.current();
+    const started_ms = std.time.milliTimestamp();
+    _ = signal.wait(scheduled, null);
+    try std.testing.expect(std.time.milliTimestamp() - started_ms < 1000);
+    try std.testing.expectEqual(@as(i64, 0), signal.wake_at_ms.load(.seq_cst));
+}
@@ -6,8 +6,11 @@ const config = @import("../config/root.zig");
 const process = @import("process.zig");
 const state = @import("state.zig");
 const fuzzy = @import("fuzzy.zig");
+const filter_query = @import("query.zig");
 
 pub const StringList = []const []const u8;
+pub const ViewConfig = config.schema.ViewConfig;
+pub const CategoryColor = config.schema.CategoryColor;
 
 pub const UiKeybindingConfig = struct {
     quit: StringList = &.{},
@@ -23,7 +26,23 @@ pub const UiKeybindingConfig = struct {
     toggle_focus: StringList = &.{},
     focus_client: StringList = &.{},
     focus_server: StringList = &.{},
+    rotate_split: StringList = &.{},
+    grow_client: StringList = &.{},
+    shrink_client: StringList = &.{},
+    cycle_view: StringList = &.{},
+    cycle_sort: StringList = &.{},
+    toggle_pin: StringList = &.{},
+    toggle_mark: StringList = &.{},
+    toggle_messages: StringList = &.{},
     docs: StringList = &.{},
+    jump_to_error: StringList = &.{},
+    copy_command: StringList = &.{},
+    copy_pid: StringList = &.{},
+    copy_cwd: StringList = &.{},
+    copy_output: StringList = &.{},
+    open_url: StringList = &.{},
+    open_cwd: StringList = &.{},
+    show_env: StringList = &.{},
 };
 
 pub const UiLayoutConfig = struct {
@@ -34,19 +53,79 @@ pub const UiLayoutConfig = struct {
     sort_process_list_running_first: bool = false,
     placeholder_banner: []const u8 = "",
     enable_debug_process_info: bool = false,
+    selection_switch_debounce_ms: i32 = 0,
+    /// A `config.schema.LastLinePreview` name.
+    last_line_preview: []const u8 = "off",
 };
 
 pub const UiStyleConfig = struct {
     pointer_char: []const u8 = ">",
+    selected_process_color: []const u8 = "",
+    selected_process_bg_color: []const u8 = "",
+    unselected_process_color: []const u8 = "",
     status_running_color: []const u8 = "green",
     status_halting_color: []const u8 = "yellow",
     status_stopped_color: []const u8 = "red",
+    warning_color: []const u8 = "yellow",
+    unread_output_color: []const u8 = "cyan",
+    placeholder_banner_color: []const u8 = "cyan",
+    status_running_icon: []const u8 = "●",
+    status_halting_icon: []const u8 = "◐",
+    status_stopped_icon: []const u8 = "■",
+    status_failed_icon: []const u8 = "✖",
+    status_succeeded_icon: []const u8 = "✔",
+    status_unhealthy_icon: []const u8 = "▲",
+    category_colors: []const CategoryColor = &.{},
+    /// A `config.schema.CategoryColorTarget` name.
+    category_color_target: []const u8 = "marker",
 };
 
 pub const UiConfig = struct {
     keybinding: UiKeybindingConfig = .{},
     layout: UiLayoutConfig = .{},
+    /// Colors for dark terminal backgrounds.
     style: UiStyleConfig = .{},
+    /// Colors for light terminal backgrounds; each client picks a palette
+    /// for its own terminal.
+    light_style: UiStyleConfig = .{},
+    /// A `config.schema.Background` name.
+    background: []const u8 = "auto",
+    /// Named filter presets in config order.
+    views: []const ViewConfig = &.{},
+    /// A `config.schema.QuitAction` name.
+    on_quit: []const u8 = "stop",
+    /// A `config.schema.BellAction` name.
+    on_bell: []const u8 = "ring",
+    /// Empty copies with OSC 52.
+    clipboard_cmd: StringList = &.{},
+    clipboard_output_lines: u32 = default_clipboard_output_lines,
+};
+
+/// Output lines `copy_output` copies when `clipboard_output_lines` is 0.
+pub const default_clipboard_output_lines: u32 = 200;
+
+/// Process list orderings the TUI cycles through at runtime. `config` is the
+/// order the `layout` sort options ask for.
+pub const SortMode = enum {
+    config,
+    alpha,
+    running_first,
+    recently_started,
+    most_output,
+
+    pub fn next(self: SortMode) SortMode {
+        return @enumFromInt((@intFromEnum(self) + 1) % std.meta.fields(SortMode).len);
+    }
+
+    pub fn label(self: SortMode) []const u8 {
+        return switch (self) {
+            .config => "config order",
+            .alpha => "alphabetical",
+            .running_first => "running first",
+            .recently_started => "recently started",
+            .most_output => "most output",
+        };
+    }
 };
 
 /// Client-safe view of one configured process. Fields are intentionally limited
@@ -56,9 +135,66 @@ pub const ProcessSummary = struct {
     label: []const u8,
     status: process.ProcessStatus = .halted,
     pid: i32 = -1,
+    /// Unix milliseconds when the running process started; 0 when stopped.
+    started_ms: i64 = 0,
+    /// Code of a run that exited on its own; null while running or after a stop.
+    exit_code: ?u32 = null,
+    /// Output bytes captured so far, rounded down by `coarseBytes`.
+    output_bytes: u64 = 0,
+    /// Output lines and bytes per second, rounded down the same way.
+    output_lines: u64 = 0,
+    output_rate: u64 = 0,
+    /// Unix milliseconds when the newest burst of output began, moving at most
+    /// once a second; clients compare it to flag unread output.
+    last_output_ms: i64 = 0,
+    /// BEL characters rung over every run; clients compare it to ring and
+    /// mark processes that rang since they were last viewed.
+    bells: u32 = 0,
     description: []const u8 = "",
     docs: []const u8 = "",
+    /// Address the process serves, for `open_url`.
+    url: []const u8 = "",
     categories: StringList = &.{},
+    /// Published `ports` of a docker process.
+    ports: StringList = &.{},
+    /// Bumped on each `watch` restart so clients can announce it.
+    watch_restarts: u32 = 0,
+    watch_change: []const u8 = "",
+    /// Bumped on each `idle_timeout_ms` stop so clients can announce it.
+    idle_stops: u32 = 0,
+    /// Seconds until a delayed autostart; 0 when none is pending.
+    autostart_in_s: u32 = 0,
+    /// Set while automatic restarts are refused after too many failed runs;
+    /// `crash_output` holds the last lines of the run that tripped it.
+    crash_looping: bool = false,
+    crash_output: []const u8 = "",
+    /// Set while a running process has printed nothing for its
+    /// `expect_output_within_ms`.
+    stuck: bool = false,
+    /// Note attached by a plugin, e.g. "ready on :3000".
+    annotation: []const u8 = "",
+    /// Newest non-empty output line without escape sequences; only filled
+    /// while `layout.last_line_preview` is on.
+    last_line: []const u8 = "",
+    /// Output lines matching `error_patterns` over every run; clients compare
+    /// it to count errors printed since a process was last viewed.
+    error_count: u32 = 0,
+};
+
+pub const StartupFailure = struct {
+    label: []const u8,
+    /// First error line of the run's output, or the spawn error.
+    reason: []const u8,
+};
+
+/// Outcome of the primary's autostart, published once its settle window has
+/// passed so runs that die straight away count as failed.
+pub const StartupSummary = struct {
+    /// Unix milliseconds when the report settled; 0 while autostart is still
+    /// settling or started nothing.
+    settled_ms: i64 = 0,
+    started: StringList = &.{},
+    failed: []const StartupFailure = &.{},
 };
 
 /// Complete replacement state for Client Sessions.
@@ -67,6 +203,7 @@ pub const ClientSnapshot = struct {
     current_process_id: u32 = 0,
     exiting: bool = false,
     ui: UiConfig = .{},
+    startup: StartupSummary = .{},
     processes: []const ProcessSummary = &.{},
 
     pub fn currentProcessId(self: ClientSnapshot) process.ProcessId {
@@ -118,54 +255,111 @@ pub fn summaryFromView(view: process.ProcessView) ProcessSummary {
         .label = view.label,
         .status = view.status,
         .pid = view.pid,
+        .started_ms = view.started_ms,
+        .exit_code = view.exit_code,
+        .output_bytes = coarseBytes(view.output_bytes),
+        .output_lines = coarseBytes(view.output_lines),
+        .output_rate = coarseBytes(view.output_rate),
+        .last_output_ms = view.last_output_ms,
+        .bells = view.bells,
         .description = view.config.description,
         .docs = view.config.docs,
+        .url = view.config.url,
         .categories = view.config.categories.items,
+        .ports = view.config.ports.items,
+        .watch_restarts = view.watch_restarts,
+        .watch_change = view.watch_change,
+        .idle_stops = view.idle_stops,
+        .autostart_in_s = view.autostart_in_s,
+        .crash_looping = view.crash_looping,
+        .crash_output = view.crash_output,
+        .stuck = view.stuck,
+        .annotation = view.annotation,
+        .last_line = view.last_line,
+        .error_count = view.error_count,
     };
 }
 
-/// Applies client-side process list filtering while preserving configured sort
-/// semantics for unscored filters. The returned slice is owned by the caller.
+/// Keeps the top four significant bits of an output byte count. Sorting by
+/// output only needs the magnitude, and steady output would otherwise change
+/// every snapshot the monitor polls.
+pub fn coarseBytes(bytes: u64) u64 {
+    if (bytes < 16) return bytes;
+    const shift: u6 = @intCast(63 - @clz(bytes) - 3);
+    return (bytes >> shift) << shift;
+}
+
+/// Applies client-side process list filtering while preserving `sort_mode` for
+/// unscored filters. The returned slice is owned by the caller.
 pub fn filteredProcesses(
     allocator: std.mem.Allocator,
     snapshot: *const ClientSnapshot,
     filter_text: []const u8,
     show_only_running: bool,
+    sort_mode: SortMode,
 ) ![]ProcessSummary {
-    const trimmed = std.mem.trim(u8, filter_text, " \t\r\n");
-    if (trimmed.len == 0) {
+    var query = try filter_query.Query.parse(allocator, filter_text, snapshot.ui.layout.category_search_prefix);
+    defer query.deinit();
+    if (query.isEmpty()) {
         const result = try selectRunningProcesses(allocator, snapshot.processes, show_only_running);
-        sortProcesses(&snapshot.ui, result);
+        sortProcesses(&snapshot.ui, sort_mode, result);
         return result;
     }
 
-    if (std.mem.startsWith(u8, trimmed, snapshot.ui.layout.category_search_prefix)) {
-        const raw = trimmed[snapshot.ui.layout.category_search_prefix.len..];
-        var result = std.array_list.Managed(ProcessSummary).init(allocator);
-        errdefer result.deinit();
-        for (snapshot.processes) |summary| {
-            if (show_only_running and summary.status != .running) continue;
-            if (matchesAllCategories(raw, summary.categories)) try result.append(summary);
-        }
-        const owned = try result.toOwnedSlice();
-        sortProcesses(&snapshot.ui, owned);
-        return owned;
-    }
-
+    // Fuzzy terms rank by score; filters without one keep the configured sort.
     var matches = std.array_list.Managed(fuzzy.Match).init(allocator);
     defer matches.deinit();
     for (snapshot.processes, 0..) |summary, index| {
         if (show_only_running and summary.status != .running) continue;
-        if (fuzzy.score(trimmed, summary.label)) |score| {
+        if (query.score(summary.label, summary.categories)) |score| {
             try matches.append(.{ .index = index, .score = score });
         }
     }
-    fuzzy.sortMatches(matches.items);
+    const ranked = query.ranked();
+    if (ranked) fuzzy.sortMatches(matches.items);
 
     var result = std.array_list.Managed(ProcessSummary).init(allocator);
     errdefer result.deinit();
     for (matches.items) |match| try result.append(snapshot.processes[match.index]);
-    return result.toOwnedSlice();
+    const owned = try result.toOwnedSlice();
+    if (!ranked) sortProcesses(&snapshot.ui, sort_mode, owned);
+    return owned;
+}
+
+/// A process list whose first `pinned_count` entries are pinned.
+pub const PinnedList = struct {
+    processes: []ProcessSummary,
+    pinned_count: usize,
+};
+
+/// Puts the processes labelled in `pinned` ahead of `filtered`, including
+/// pinned processes the filter left out. Pinned processes keep `sort_mode`
+/// order among themselves. The returned slice is owned by the caller.
+pub fn pinFirst(
+    allocator: std.mem.Allocator,
+    snapshot: *const ClientSnapshot,
+    filtered: []const ProcessSummary,
+    pinned: []const []const u8,
+    sort_mode: SortMode,
+) !PinnedList {
+    var result = std.array_list.Managed(ProcessSummary).init(allocator);
+    errdefer result.deinit();
+    for (snapshot.processes) |summary| {
+        if (containsLabel(pinned, summary.label)) try result.append(summary);
+    }
+    const pinned_count = result.items.len;
+    sortProcesses(&snapshot.ui, sort_mode, result.items);
+    for (filtered) |summary| {
+        if (!containsLabel(pinned, summary.label)) try result.append(summary);
+    }
+    return .{ .processes = try result.toOwnedSlice(), .pinned_count = pinned_count };
+}
+
+fn containsLabel(labels: []const []const u8, label: []const u8) bool {
+    for (labels) |candidate| {
+        if (std.mem.eql(u8, candidate, label)) return true;
+    }
+    return false;
 }
 
 fn selectRunningProcesses(
@@ -182,20 +376,31 @@ fn selectRunningProcesses(
     return result.toOwnedSlice();
 }
 
-fn sortProcesses(ui: *const UiConfig, items: []ProcessSummary) void {
-    if (!ui.layout.sort_process_list_running_first and !ui.layout.sort_process_list_alpha) return;
+fn sortProcesses(ui: *const UiConfig, sort_mode: SortMode, items: []ProcessSummary) void {
+    if (sort_mode == .config and !ui.layout.sort_process_list_running_first and !ui.layout.sort_process_list_alpha) return;
     var i: usize = 1;
     while (i < items.len) : (i += 1) {
         const value = items[i];
         var j = i;
-        while (j > 0 and lessProcess(ui, value, items[j - 1])) : (j -= 1) {
+        while (j > 0 and lessProcess(ui, sort_mode, value, items[j - 1])) : (j -= 1) {
             items[j] = items[j - 1];
         }
         items[j] = value;
     }
 }
 
-fn lessProcess(ui: *const UiConfig, a: ProcessSummary, b: ProcessSummary) bool {
+/// Ties keep snapshot order because the insertion sort is stable.
+fn lessProcess(ui: *const UiConfig, sort_mode: SortMode, a: ProcessSummary, b: ProcessSummary) bool {
+    return switch (sort_mode) {
+        .config => lessConfigured(ui, a, b),
+        .alpha => std.mem.order(u8, a.label, b.label) == .lt,
+        .running_first => a.status == .running and b.status != .running,
+        .recently_started => a.started_ms > b.started_ms,
+        .most_output => a.output_bytes > b.output_bytes,
+    };
+}
+
+fn lessConfigured(ui: *const UiConfig, a: ProcessSummary, b: ProcessSummary) bool {
     if (ui.layout.sort_process_list_running_first) {
         const a_running = a.status == .running;
         const b_running = b.status == .running;
@@ -207,43 +412,6 @@ fn lessProcess(ui: *const UiConfig, a: ProcessSummary, b: ProcessSummary) bool {
     return false;
 }
 
-fn matchesAllCategories(raw: []const u8, categories: []const []const u8) bool {
-    var parts = std.mem.splitScalar(u8, raw, ',');
-    while (parts.next()) |part| {
-        const wanted = std.mem.trim(u8, part, " \t\r\n");
-        var found = false;
-        for (categories) |category| {
-            if (fuzzyCategoryMatch(category, wanted)) {
-                found = true;
-                break;
-            }
-        }
-        if (!found) return false;
-    }
-    return true;
-}
-
-fn fuzzyCategoryMatch(a: []const u8, b: []const u8) bool {
-    return indexOfIgnoreCase(a, b) != null or indexOfIgnoreCase(b, a) != null;
-}
-
-fn indexOfIgnoreCase(haystack: []const u8, needle: []const u8) ?usize {
-    if (needle.len == 0) return 0;
-    if (needle.len > haystack.len) return null;
-    var i: usize = 0;
-    while (i + needle.len <= haystack.len) : (i += 1) {
-        var matched = true;
-        for (needle, 0..) |c, j| {
-            if (std.ascii.toLower(haystack[i + j]) != std.ascii.toLower(c)) {
-                matched = false;
-                break;
-            }
-        }
-        if (matched) return i;
-    }
-    return null;
-}
-
 pub fn fromConfig(cfg: *const config.schema.Config) UiConfig {
     return .{
         .keybinding = .{
@@ -260,7 +428,23 @@ pub fn fromConfig(cfg: *const config.schema.Config) UiConfig {
             .toggle_focus = cfg.keybinding.toggle_focus.items,
             .focus_client = cfg.keybinding.focus_client.items,
             .focus_server = cfg.keybinding.focus_server.items,
+            .rotate_split = cfg.keybinding.rotate_split.items,
+            .grow_client = cfg.keybinding.grow_client.items,
+            .shrink_client = cfg.keybinding.shrink_client.items,
+            .cycle_view = cfg.keybinding.cycle_view.items,
+            .cycle_sort = cfg.keybinding.cycle_sort.items,
+            .toggle_pin = cfg.keybinding.toggle_pin.items,
+            .toggle_mark = cfg.keybinding.toggle_mark.items,
+            .toggle_messages = cfg.keybinding.toggle_messages.items,
             .docs = cfg.keybinding.docs.items,
+            .jump_to_error = cfg.keybinding.jump_to_error.items,
+            .copy_command = cfg.keybinding.copy_command.items,
+            .copy_pid = cfg.keybinding.copy_pid.items,
+            .copy_cwd = cfg.keybinding.copy_cwd.items,
+            .copy_output = cfg.keybinding.copy_output.items,
+            .open_url = cfg.keybinding.open_url.items,
+            .open_cwd = cfg.keybinding.open_cwd.items,
+            .show_env = cfg.keybinding.show_env.items,
         },
         .layout = .{
             .category_search_prefix = cfg.layout.category_search_prefix,
@@ -270,13 +454,40 @@ pub fn fromConfig(cfg: *const config.schema.Config) UiConfig {
             .sort_process_list_running_first = cfg.layout.sort_process_list_running_first,
             .placeholder_banner = cfg.layout.placeholder_banner,
             .enable_debug_process_info = cfg.layout.enable_debug_process_info,
+            .selection_switch_debounce_ms = cfg.layout.selection_switch_debounce_ms,
+            .last_line_preview = cfg.layout.last_line_preview,
         },
-        .style = .{
-            .pointer_char = cfg.style.pointer_char,
-            .status_running_color = cfg.style.status_running_color,
-            .status_halting_color = cfg.style.status_halting_color,
-            .status_stopped_color = cfg.style.status_stopped_color,
-        },
+        .style = uiStyle(&cfg.style),
+        .light_style = uiStyle(&cfg.light_style),
+        .background = cfg.background,
+        .views = cfg.views.items,
+        .on_quit = cfg.general.on_quit,
+        .on_bell = cfg.general.on_bell,
+        .clipboard_cmd = cfg.clipboard_cmd.items,
+        .clipboard_output_lines = if (cfg.clipboard_output_lines > 0) @intCast(cfg.clipboard_output_lines) else default_clipboard_output_lines,
+    };
+}
+
+fn uiStyle(style: *const config.schema.StyleConfig) UiStyleConfig {
+    return .{
+        .pointer_char = style.pointer_char,
+        .selected_process_color = style.selected_process_color,
+        .selected_process_bg_color = style.selected_process_bg_color,
+        .unselected_process_color = style.unselected_process_color,
+        .status_running_color = style.status_running_color,
+        .status_halting_color = style.status_halting_color,
+        .status_stopped_color = style.status_stopped_color,
+        .warning_color = style.warning_color,
+        .unread_output_color = style.unread_output_color,
+        .placeholder_banner_color = style.placeholder_banner_color,
+        .status_running_icon = style.status_running_icon,
+        .status_halting_icon = style.status_halting_icon,
+        .status_stopped_icon = style.status_stopped_icon,
+        .status_failed_icon = style.status_failed_icon,
+        .status_succeeded_icon = style.status_succeeded_icon,
+        .status_unhealthy_icon = style.status_unhealthy_icon,
+        .category_colors = style.category_colors,
+        .category_color_target = style.category_color_target,
     };
 }
 
@@ -310,3 +521,128 @@ test "client snapshot includes only client-visible process data" {
     try std.testing.expectEqualStrings("API server", snapshot.view().processes[0].description);
     try std.testing.expectEqualStrings("backend", snapshot.view().processes[0].categories[0]);
 }
+
+test "client snapshot sort modes reorder the process list" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "web", .status = .halted, .output_bytes = 4096 },
+        .{ .id = 2, .label = "api", .status = .running, .started_ms = 1_000, .output_bytes = 64 },
+        .{ .id = 3, .label = "db", .status = .running, .started_ms = 5_000 },
+    };
+    const snapshot = ClientSnapshot{ .processes = &processes };
+
+    const expected = [_]struct { mode: SortMode, ids: [3]u32 }{
+        .{ .mode = .config, .ids = .{ 1, 2, 3 } },
+        .{ .mode = .alpha, .ids = .{ 2, 3, 1 } },
+        .{ .mode = .running_first, .ids = .{ 2, 3, 1 } },
+        .{ .mode = .recently_started, .ids = .{ 3, 2, 1 } },
+        .{ .mode = .most_output, .ids = .{ 1, 2, 3 } },
+    };
+    for (expected) |case| {
+        const sorted = try filteredProcesses(std.testing.allocator, &snapshot, "", false, case.mode);
+        defer std.testing.allocator.free(sorted);
+        for (case.ids, sorted) |id, summary| try std.testing.expectEqual(id, summary.id);
+    }
+    try std.testing.expectEqual(SortMode.config, SortMode.most_output.next());
+}
+
+test "client snapshot category filter uses AND matching and running-only toggle" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "backend", .status = .running, .pid = 101, .categories = &.{ "server", "api" } },
+        .{ .id = 2, .label = "api-gateway", .status = .halted, .categories = &.{ "server", "gateway" } },
+    };
+    const snapshot = ClientSnapshot{ .processes = &processes };
+
+    const result = try filteredProcesses(std.testing.allocator, &snapshot, "cat:server,api", false, .config);
+    defer std.testing.allocator.free(result);
+    try std.testing.expectEqual(@as(usize, 1), result.len);
+    try std.testing.expectEqualStrings("backend", result[0].label);
+
+    const running = try filteredProcesses(std.testing.allocator, &snapshot, "cat:server", true, .config);
+    defer std.testing.allocator.free(running);
+    try std.testing.expectEqual(@as(usize, 1), running.len);
+    try std.testing.expectEqualStrings("backend", running[0].label);
+}
+
+test "client snapshot configured sort puts running first then alpha" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "halted-zebra", .status = .halted },
+        .{ .id = 2, .label = "running-mango", .status = .running },
+        .{ .id = 3, .label = "halted-apple", .status = .halted },
+        .{ .id = 4, .label = "running-banana", .status = .running },
+    };
+    const snapshot = ClientSnapshot{
+        .processes = &processes,
+        .ui = .{ .layout = .{ .sort_process_list_running_first = true, .sort_process_list_alpha = true } },
+    };
+
+    const result = try filteredProcesses(std.testing.allocator, &snapshot, "", false, .config);
+    defer std.testing.allocator.free(result);
+    const labels = [_][]const u8{ "running-banana", "running-mango", "halted-apple", "halted-zebra" };
+    for (labels, result) |label, summary| try std.testing.expectEqualStrings(label, summary.label);
+}
+
+test "client snapshot fuzzy label search ignores configured sorting" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "zebra-api", .status = .halted },
+        .{ .id = 2, .label = "api-service", .status = .running },
+        .{ .id = 3, .label = "apple-api", .status = .halted },
+    };
+    const snapshot = ClientSnapshot{
+        .processes = &processes,
+        .ui = .{ .layout = .{ .sort_process_list_running_first = true, .sort_process_list_alpha = true } },
+    };
+
+    const result = try filteredProcesses(std.testing.allocator, &snapshot, "api", false, .config);
+    defer std.testing.allocator.free(result);
+    try std.testing.expectEqual(@as(usize, 3), result.len);
+}
+
+test "client snapshot regex and negated terms combine with AND and keep configured sorting" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "web-2", .status = .running },
+        .{ .id = 2, .label = "web-1", .status = .running },
+        .{ .id = 3, .label = "web-legacy", .status = .halted },
+        .{ .id = 4, .label = "db", .status = .running },
+    };
+    const snapshot = ClientSnapshot{
+        .processes = &processes,
+        .ui = .{ .layout = .{ .sort_process_list_alpha = true } },
+    };
+
+    const numbered = try filteredProcesses(std.testing.allocator, &snapshot, "re:^web-\\d+$", false, .config);
+    defer std.testing.allocator.free(numbered);
+    try std.testing.expectEqual(@as(usize, 2), numbered.len);
+    try std.testing.expectEqualStrings("web-1", numbered[0].label);
+    try std.testing.expectEqualStrings("web-2", numbered[1].label);
+
+    const not_first = try filteredProcesses(std.testing.allocator, &snapshot, "re:^web !1 !legacy", false, .config);
+    defer std.testing.allocator.free(not_first);
+    try std.testing.expectEqual(@as(usize, 1), not_first.len);
+    try std.testing.expectEqualStrings("web-2", not_first[0].label);
+}
+
+test "client snapshot rounds output bytes to four significant bits" {
+    try std.testing.expectEqual(@as(u64, 15), coarseBytes(15));
+    try std.testing.expectEqual(@as(u64, 30), coarseBytes(31));
+    try std.testing.expectEqual(@as(u64, 0xf000), coarseBytes(0xffff));
+    try std.testing.expectEqual(@as(u64, 0x8000), coarseBytes(0x8fff));
+}
+
+test "client snapshot pins processes ahead of the filtered list" {
+    const processes = [_]ProcessSummary{
+        .{ .id = 1, .label = "web", .status = .running },
+        .{ .id = 2, .label = "api", .status = .halted },
+        .{ .id = 3, .label = "db", .status = .running },
+    };
+    const snapshot = ClientSnapshot{ .processes = &processes };
+
+    const filtered = try filteredProcesses(std.testing.allocator, &snapshot, "", true, .config);
+    defer std.testing.allocator.free(filtered);
+    const list = try pinFirst(std.testing.allocator, &snapshot, filtered, &.{ "db", "api" }, .alpha);
+    defer std.testing.allocator.free(list.processes);
+
+    try std.testing.expectEqual(@as(usize, 2), list.pinned_count);
+    const ids = [_]u32{ 2, 3, 1 };
+    try std.testing.expectEqual(ids.len, list.processes.len);
+    for (ids, list.processes) |id, summary| try std.testing.expectEqual(id, summary.id);
+}
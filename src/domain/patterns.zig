@@ -0,0 +1,124 @@
+//! Line patterns shared by `error_patterns` and `redact_patterns`.
+//! A plain pattern matches case-insensitively anywhere in a line; one with the `re:` prefix is a regex.
+
+const std = @import("std");
+const query = @import("query.zig");
+const regex = @import("regex.zig");
+
+pub const Pattern = union(enum) {
+    /// Matched case-insensitively anywhere in the line.
+    substring: []const u8,
+    regex: regex.Regex,
+
+    /// First match starting at or after `from`.
+    pub fn find(self: *Pattern, line: []const u8, from: usize) ?regex.Span {
+        return switch (self.*) {
+            .substring => |needle| blk: {
+                const start = std.ascii.indexOfIgnoreCasePos(line, from, needle) orelse return null;
+                break :blk .{ .start = start, .end = start + needle.len };
+            },
+            .regex => |*compiled| compiled.find(line, from),
+        };
+    }
+
+    pub fn matches(self: *Pattern, line: []const u8) bool {
+        return switch (self.*) {
+            .substring => |needle| std.ascii.indexOfIgnoreCase(line, needle) != null,
+            .regex => |*compiled| compiled.isMatch(line),
+        };
+    }
+
+    fn deinit(self: *Pattern) void {
+        switch (self.*) {
+            .substring => {},
+            .regex => |*compiled| compiled.deinit(),
+        }
+    }
+};
+
+/// Compiled patterns. Regexes keep scratch state, so one set must not match
+/// from two threads at once.
+pub const PatternSet = struct {
+    allocator: std.mem.Allocator,
+    items: []Pattern = &.{},
+
+    /// Sources are borrowed. A `re:` source that does not compile is skipped
+    /// and returned through `invalid`, if given, so the caller can warn.
+    pub fn init(
+        allocator: std.mem.Allocator,
+        sources: []const []const u8,
+        invalid: ?*std.array_list.Managed([]const u8),
+    ) !PatternSet {
+        var items = std.array_list.Managed(Pattern).init(allocator);
+        errdefer {
+            for (items.items) |*item| item.deinit();
+            items.deinit();
+        }
+        for (sources) |source| {
+            if (source.len == 0) continue;
+            if (!std.mem.startsWith(u8, source, query.regex_prefix)) {
+                try items.append(.{ .substring = source });
+                continue;
+            }
+            const compiled = regex.Regex.compile(allocator, source[query.regex_prefix.len..]) catch |err| switch (err) {
+                error.InvalidRegex => {
+                    if (invalid) |list| try list.append(source);
+                    continue;
+                },
+                else => return err,
+            };
+            try items.append(.{ .regex = compiled });
+        }
+        return .{ .allocator = allocator, .items = try items.toOwnedSlice() };
+    }
+
+    pub fn deinit(self: *PatternSet) void {
+        for (self.items) |*item| item.deinit();
+        self.allocator.free(self.items);
+    }
+
+    pub fn isEmpty(self: PatternSet) bool {
+        return self.items.len == 0;
+    }
+
+    /// Whether any pattern matches `line`.
+    pub fn matches(self: *PatternSet, line: []const u8) bool {
+        for (self.items) |*item| {
+            if (item.matches(line)) return true;
+        }
+        return false;
+    }
+
+    /// Earliest match of any pattern starting at or after `from`; of matches
+    /// starting at the same byte, the longest.
+    pub fn find(self: *PatternSet, line: []const u8, from: usize) ?regex.Span {
+        var best: ?regex.Span = null;
+        for (self.items) |*item| {
+            const span = item.find(line, from) orelse continue;
+            if (best) |current| {
+                if (span.start > current.start or (span.start == current.start and span.end <= current.end)) continue;
+            }
+            best = span;
+        }
+        return best;
+    }
+};
+
+test "pattern sets match substrings case-insensitively and regexes after re:" {
+    var invalid = std.array_list.Managed([]const u8).init(std.testing.allocator);
+    defer invalid.deinit();
+    var set = try PatternSet.init(std.testing.allocator, &.{ "", "Fatal", "re:ghp_[a-z0-9]+", "re:(" }, &invalid);
+    defer set.deinit();
+
+    try std.testing.expectEqual(@as(usize, 2), set.items.len);
+    try std.testing.expectEqual(@as(usize, 1), invalid.items.len);
+    try std.testing.expectEqualStrings("re:(", invalid.items[0]);
+    try std.testing.expect(set.matches("a FATAL error"));
+    try std.testing.expect(set.matches("token ghp_abc1"));
+    try std.testing.expect(!set.matches("all good"));
+
+    const span = set.find("fatal: ghp_x1 and fatal", 1).?;
+    try std.testing.expectEqual(@as(usize, 7), span.start);
+    try std.testing.expectEqual(@as(usize, 13), span.end);
+    try std.testing.expect(set.find("nothing here", 0) == null);
+}
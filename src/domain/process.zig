@@ -4,12 +4,85 @@
 const std = @import("std");
 const config = @import("../config/root.zig");
 
+/// Where a process is in its lifecycle. `halted` is both "configured, never
+/// started" and "stopped"; `exited` is a run that ended on its own and keeps
+/// its exit code until the next start. Statuses only move through `next`.
 pub const ProcessStatus = enum(u8) {
     unknown = 0,
     running = 1,
     halting = 2,
     halted = 3,
     exited = 4,
+    starting = 5,
+    restarting = 6,
+
+    /// The status after `event`, or `error.InvalidTransition` when the event
+    /// cannot happen in this status.
+    pub fn next(self: ProcessStatus, event: StatusEvent) error{InvalidTransition}!ProcessStatus {
+        return switch (self) {
+            .halted => switch (event) {
+                .start => .starting,
+                else => error.InvalidTransition,
+            },
+            .starting => switch (event) {
+                .spawned => .running,
+                .start_failed => .halted,
+                else => error.InvalidTransition,
+            },
+            .running => switch (event) {
+                .stop => .halting,
+                .exit => .exited,
+                .restart => .restarting,
+                else => error.InvalidTransition,
+            },
+            // A stop signal usually ends the run; that exit is the stop
+            // finishing, not the process exiting on its own.
+            .halting => switch (event) {
+                .exit => .halting,
+                .released => .halted,
+                else => error.InvalidTransition,
+            },
+            .exited => switch (event) {
+                .stop => .halting,
+                .restart => .restarting,
+                .released => .halted,
+                else => error.InvalidTransition,
+            },
+            .restarting => switch (event) {
+                .exit, .released => .restarting,
+                .start => .starting,
+                .start_failed => .halted,
+                else => error.InvalidTransition,
+            },
+            .unknown => error.InvalidTransition,
+        };
+    }
+
+    /// Whether the status is on its way somewhere else.
+    pub fn isTransitional(self: ProcessStatus) bool {
+        return switch (self) {
+            .starting, .halting, .restarting => true,
+            .running, .halted, .exited, .unknown => false,
+        };
+    }
+};
+
+/// Lifecycle events the process controller applies to a ProcessStatus.
+pub const StatusEvent = enum {
+    /// A start was requested and its id reserved.
+    start,
+    /// The OS process was spawned and its output and exit threads are running.
+    spawned,
+    /// `pre_start`, docker, or the spawn itself failed.
+    start_failed,
+    /// A user stop began signalling the process.
+    stop,
+    /// The OS process ended.
+    exit,
+    /// The instance was released and its threads joined.
+    released,
+    /// A restart began; the start that follows it ends the restart.
+    restart,
 };
 
 /// Stable domain identifier assigned from sorted Project Config order. `none`
@@ -41,6 +114,8 @@ pub fn statusName(status: ProcessStatus) []const u8 {
         .halting => "Halting",
         .halted => "Halted",
         .exited => "Exited",
+        .starting => "Starting",
+        .restarting => "Restarting",
         .unknown => "Unknown",
     };
 }
@@ -49,6 +124,37 @@ pub const Process = struct {
     id: ProcessId,
     label: []const u8,
     config: *config.schema.ProcessConfig,
+    /// Restarts triggered by `watch`; written by the Primary's file watcher
+    /// under its mutex.
+    watch_restarts: u32 = 0,
+    /// File whose change caused the latest watch restart.
+    watch_change: []const u8 = "",
+    /// Stops for `idle_timeout_ms`; written by the Primary's idle monitor
+    /// under its mutex.
+    idle_stops: u32 = 0,
+    /// Seconds until a delayed autostart, rounded up; 0 when none is pending.
+    /// Written by the Primary's autostart scheduler under its mutex.
+    autostart_in_s: u32 = 0,
+    /// Set once too many runs failed in a row; written with `crash_output`
+    /// by the Primary's crash tracker under its mutex.
+    crash_looping: bool = false,
+    crash_output: []const u8 = "",
+    /// Set while the process has printed nothing for `expect_output_within_ms`;
+    /// written by the Primary's stuck detector under its mutex.
+    stuck: bool = false,
+    /// Latest note a plugin attached; written by the Primary's plugin
+    /// dispatcher under its mutex.
+    annotation: []const u8 = "",
+    /// Newest output line for list previews; written by the Primary's
+    /// preview refresh under its mutex.
+    last_line: []const u8 = "",
+    /// Output lines matching `error_patterns` over every run; written by the
+    /// Primary's error scanner under its mutex.
+    error_count: u32 = 0,
+    /// Shell-ready command line and resolved cwd; set once by the Primary
+    /// before it serves clients, which fetch them on demand.
+    command: []const u8 = "",
+    cwd: []const u8 = "",
 };
 
 pub const ProcessView = struct {
@@ -56,7 +162,31 @@ pub const ProcessView = struct {
     label: []const u8,
     status: ProcessStatus = .halted,
     pid: i32 = -1,
+    /// Unix milliseconds when the running process started; 0 when stopped.
+    started_ms: i64 = 0,
+    /// Code of a run that exited on its own; null while running or after a stop.
+    exit_code: ?u32 = null,
+    /// Output bytes captured over every run of the process.
+    output_bytes: u64 = 0,
+    /// Output lines over every run of the process.
+    output_lines: u64 = 0,
+    /// Output bytes per second, averaged over the last few seconds.
+    output_rate: u64 = 0,
+    /// Unix milliseconds when the newest burst of output began; 0 before any.
+    last_output_ms: i64 = 0,
+    /// BEL characters the process rang over every run.
+    bells: u32 = 0,
     config: *config.schema.ProcessConfig,
+    watch_restarts: u32 = 0,
+    watch_change: []const u8 = "",
+    idle_stops: u32 = 0,
+    autostart_in_s: u32 = 0,
+    crash_looping: bool = false,
+    crash_output: []const u8 = "",
+    stuck: bool = false,
+    annotation: []const u8 = "",
+    last_line: []const u8 = "",
+    error_count: u32 = 0,
 };
 
 /// Narrow status adapter used by domain code that needs live process facts
@@ -65,6 +195,13 @@ pub const ProcessController = struct {
     context: *anyopaque,
     get_process_status: *const fn (context: *anyopaque, id: ProcessId) ProcessStatus,
     get_pid: *const fn (context: *anyopaque, id: ProcessId) i32,
+    get_started_ms: *const fn (context: *anyopaque, id: ProcessId) i64 = noStartedMs,
+    get_exit_code: *const fn (context: *anyopaque, id: ProcessId) ?u32 = noExitCode,
+    get_output_bytes: *const fn (context: *anyopaque, id: ProcessId) u64 = noOutputBytes,
+    get_output_lines: *const fn (context: *anyopaque, id: ProcessId) u64 = noOutputBytes,
+    get_output_rate: *const fn (context: *anyopaque, id: ProcessId) u64 = noOutputBytes,
+    get_last_output_ms: *const fn (context: *anyopaque, id: ProcessId) i64 = noLastOutputMs,
+    get_bells: *const fn (context: *anyopaque, id: ProcessId) u32 = noBells,
 
     pub fn getProcessStatus(self: ProcessController, id: ProcessId) ProcessStatus {
         return self.get_process_status(self.context, id);
@@ -73,8 +210,56 @@ pub const ProcessController = struct {
     pub fn getPID(self: ProcessController, id: ProcessId) i32 {
         return self.get_pid(self.context, id);
     }
+
+    pub fn getStartedMs(self: ProcessController, id: ProcessId) i64 {
+        return self.get_started_ms(self.context, id);
+    }
+
+    pub fn getExitCode(self: ProcessController, id: ProcessId) ?u32 {
+        return self.get_exit_code(self.context, id);
+    }
+
+    pub fn getOutputBytes(self: ProcessController, id: ProcessId) u64 {
+        return self.get_output_bytes(self.context, id);
+    }
+
+    pub fn getOutputLines(self: ProcessController, id: ProcessId) u64 {
+        return self.get_output_lines(self.context, id);
+    }
+
+    pub fn getOutputRate(self: ProcessController, id: ProcessId) u64 {
+        return self.get_output_rate(self.context, id);
+    }
+
+    pub fn getLastOutputMs(self: ProcessController, id: ProcessId) i64 {
+        return self.get_last_output_ms(self.context, id);
+    }
+
+    pub fn getBells(self: ProcessController, id: ProcessId) u32 {
+        return self.get_bells(self.context, id);
+    }
 };
 
+fn noStartedMs(_: *anyopaque, _: ProcessId) i64 {
+    return 0;
+}
+
+fn noExitCode(_: *anyopaque, _: ProcessId) ?u32 {
+    return null;
+}
+
+fn noOutputBytes(_: *anyopaque, _: ProcessId) u64 {
+    return 0;
+}
+
+fn noLastOutputMs(_: *anyopaque, _: ProcessId) i64 {
+    return 0;
+}
+
+fn noBells(_: *anyopaque, _: ProcessId) u32 {
+    return 0;
+}
+
 /// Combines static process config with optional live controller-derived status.
 pub fn toView(proc: Process, controller: ?ProcessController) ProcessView {
     const status = if (controller) |ctl| ctl.getProcessStatus(proc.id) else ProcessStatus.halted;
@@ -84,7 +269,24 @@ pub fn toView(proc: Process, controller: ?ProcessController) ProcessView {
         .label = proc.label,
         .status = status,
         .pid = pid,
+        .started_ms = if (controller) |ctl| ctl.getStartedMs(proc.id) else 0,
+        .exit_code = if (controller) |ctl| ctl.getExitCode(proc.id) else null,
+        .output_bytes = if (controller) |ctl| ctl.getOutputBytes(proc.id) else 0,
+        .output_lines = if (controller) |ctl| ctl.getOutputLines(proc.id) else 0,
+        .output_rate = if (controller) |ctl| ctl.getOutputRate(proc.id) else 0,
+        .last_output_ms = if (controller) |ctl| ctl.getLastOutputMs(proc.id) else 0,
+        .bells = if (controller) |ctl| ctl.getBells(proc.id) else 0,
         .config = proc.config,
+        .watch_restarts = proc.watch_restarts,
+        .watch_change = proc.watch_change,
+        .idle_stops = proc.idle_stops,
+        .autostart_in_s = proc.autostart_in_s,
+        .crash_looping = proc.crash_looping,
+        .crash_output = proc.crash_output,
+        .stuck = proc.stuck,
+        .annotation = proc.annotation,
+        .last_line = proc.last_line,
+        .error_count = proc.error_count,
     };
 }
 
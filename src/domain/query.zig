@@ -0,0 +1,153 @@
+//! Process filter queries.
+//! Filter text splits on whitespace into terms that must all match: `re:` regex on the label, the category prefix (`cat:` by default) for categories, and fuzzy label search otherwise; a leading `!` negates any term.
+
+const std = @import("std");
+const fuzzy = @import("fuzzy.zig");
+const regex = @import("regex.zig");
+
+pub const regex_prefix = "re:";
+
+const Matcher = union(enum) {
+    fuzzy: []const u8,
+    categories: []const u8,
+    /// Null for a pattern that does not compile, which matches nothing so a
+    /// half-typed regex empties the list instead of showing everything.
+    regex: ?regex.Regex,
+};
+
+const Term = struct {
+    negated: bool,
+    matcher: Matcher,
+};
+
+/// Parsed filter text. Term strings borrow from the text passed to `parse`.
+pub const Query = struct {
+    terms: std.array_list.Managed(Term),
+
+    pub fn parse(allocator: std.mem.Allocator, text: []const u8, category_prefix: []const u8) !Query {
+        var query = Query{ .terms = std.array_list.Managed(Term).init(allocator) };
+        errdefer query.deinit();
+
+        var words = std.mem.tokenizeAny(u8, text, " \t\r\n");
+        while (words.next()) |word| {
+            const negated = word[0] == '!' and word.len > 1;
+            const body = if (negated) word[1..] else word;
+            const matcher: Matcher = if (std.mem.startsWith(u8, body, regex_prefix))
+                .{ .regex = regex.Regex.compile(allocator, body[regex_prefix.len..]) catch |err| switch (err) {
+                    error.InvalidRegex => null,
+                    else => return err,
+                } }
+            else if (category_prefix.len > 0 and std.mem.startsWith(u8, body, category_prefix))
+                .{ .categories = body[category_prefix.len..] }
+            else
+                .{ .fuzzy = body };
+            try query.terms.append(.{ .negated = negated, .matcher = matcher });
+        }
+        return query;
+    }
+
+    pub fn deinit(self: *Query) void {
+        for (self.terms.items) |*term| switch (term.matcher) {
+            .regex => |*compiled| if (compiled.*) |*pattern| pattern.deinit(),
+            else => {},
+        };
+        self.terms.deinit();
+    }
+
+    pub fn isEmpty(self: *const Query) bool {
+        return self.terms.items.len == 0;
+    }
+
+    /// Whether results should be ordered by fuzzy score instead of the
+    /// configured sort; true when any positive fuzzy term is present.
+    pub fn ranked(self: *const Query) bool {
+        for (self.terms.items) |term| {
+            if (!term.negated and term.matcher == .fuzzy) return true;
+        }
+        return false;
+    }
+
+    /// Returns the summed fuzzy score of a matching process, or null when
+    /// any term rejects it.
+    pub fn score(self: *Query, label: []const u8, categories: []const []const u8) ?i32 {
+        var total: i32 = 0;
+        for (self.terms.items) |*term| {
+            const term_score: ?i32 = switch (term.matcher) {
+                .fuzzy => |pattern| fuzzy.score(pattern, label),
+                .categories => |raw| if (matchesAllCategories(raw, categories)) 0 else null,
+                .regex => |*compiled| if (compiled.*) |*pattern| (if (pattern.isMatch(label)) 0 else null) else null,
+            };
+            if (term.negated) {
+                if (term_score != null) return null;
+            } else {
+                total += term_score orelse return null;
+            }
+        }
+        return total;
+    }
+};
+
+fn matchesAllCategories(raw: []const u8, categories: []const []const u8) bool {
+    var parts = std.mem.splitScalar(u8, raw, ',');
+    while (parts.next()) |part| {
+        const wanted = std.mem.trim(u8, part, " \t\r\n");
+        var found = false;
+        for (categories) |category| {
+            if (fuzzyCategoryMatch(category, wanted)) {
+                found = true;
+                break;
+            }
+        }
+        if (!found) return false;
+    }
+    return true;
+}
+
+fn fuzzyCategoryMatch(a: []const u8, b: []const u8) bool {
+    return indexOfIgnoreCase(a, b) != null or indexOfIgnoreCase(b, a) != null;
+}
+
+fn indexOfIgnoreCase(haystack: []const u8, needle: []const u8) ?usize {
+    if (needle.len == 0) return 0;
+    if (needle.len > haystack.len) return null;
+    var i: usize = 0;
+    while (i + needle.len <= haystack.len) : (i += 1) {
+        var matched = true;
+        for (needle, 0..) |c, j| {
+            if (std.ascii.toLower(haystack[i + j]) != std.ascii.toLower(c)) {
+                matched = false;
+                break;
+            }
+        }
+        if (matched) return i;
+    }
+    return null;
+}
+
+test "query terms combine with AND and support negation" {
+    var query = try Query.parse(std.testing.allocator, "api !re:^legacy- cat:server", "cat:");
+    defer query.deinit();
+
+    try std.testing.expect(query.ranked());
+    try std.testing.expect(query.score("web-api", &.{"server"}) != null);
+    try std.testing.expect(query.score("legacy-api", &.{"server"}) == null);
+    try std.testing.expect(query.score("web-api", &.{"worker"}) == null);
+    try std.testing.expect(query.score("worker", &.{"server"}) == null);
+}
+
+test "query regex terms match labels and broken patterns match nothing" {
+    var query = try Query.parse(std.testing.allocator, "re:-(api|db)$", "cat:");
+    defer query.deinit();
+    try std.testing.expect(!query.ranked());
+    try std.testing.expect(query.score("web-api", &.{}) != null);
+    try std.testing.expect(query.score("web-apis", &.{}) == null);
+
+    var broken = try Query.parse(std.testing.allocator, "re:(api", "cat:");
+    defer broken.deinit();
+    try std.testing.expect(broken.score("api", &.{}) == null);
+
+    var only_negated = try Query.parse(std.testing.allocator, "!cat:test", "cat:");
+    defer only_negated.deinit();
+    try std.testing.expect(only_negated.score("api", &.{"server"}) != null);
+    try std.testing.expect(only_negated.score("api-test", &.{"test"}) == null);
+}
@@ -0,0 +1,370 @@
+//! Minimal regular expressions for `re:` process filters.
+//! Patterns compile to a small instruction program run as a Pike VM, so matching is linear in the label length and never backtracks; supported syntax is literals, `.`, classes, `\d\w\s` escapes, `^`/`$`, groups, `|`, and `*`/`+`/`?`.
+
+const std = @import("std");
+
+const Set = std.StaticBitSet(256);
+
+/// Byte range of a match, end exclusive.
+pub const Span = struct {
+    start: usize,
+    end: usize,
+};
+
+const Inst = union(enum) {
+    set: Set,
+    split: struct { first: usize, second: usize },
+    jump: usize,
+    text_start,
+    text_end,
+    match,
+};
+
+const Node = union(enum) {
+    empty,
+    set: Set,
+    text_start,
+    text_end,
+    concat: []const Node,
+    alternate: []const Node,
+    star: *const Node,
+    plus: *const Node,
+    optional: *const Node,
+};
+
+pub const Regex = struct {
+    allocator: std.mem.Allocator,
+    program: []Inst,
+    // Thread lists and the per-step visited set are sized to the program once
+    // so matching never allocates.
+    current: []usize,
+    next: []usize,
+    visited: std.DynamicBitSetUnmanaged,
+
+    /// Compiles `pattern`; malformed patterns return `error.InvalidRegex`.
+    pub fn compile(allocator: std.mem.Allocator, pattern: []const u8) !Regex {
+        var arena = std.heap.ArenaAllocator.init(allocator);
+        defer arena.deinit();
+        var parser = Parser{ .arena = arena.allocator(), .pattern = pattern };
+        const root = try parser.parseAlternate();
+        if (parser.index != pattern.len) return error.InvalidRegex;
+
+        var program = std.array_list.Managed(Inst).init(allocator);
+        errdefer program.deinit();
+        try emit(&program, root);
+        try program.append(.match);
+
+        const owned = try program.toOwnedSlice();
+        errdefer allocator.free(owned);
+        const current = try allocator.alloc(usize, owned.len);
+        errdefer allocator.free(current);
+        const next = try allocator.alloc(usize, owned.len);
+        errdefer allocator.free(next);
+        const visited = try std.DynamicBitSetUnmanaged.initEmpty(allocator, owned.len);
+        return .{ .allocator = allocator, .program = owned, .current = current, .next = next, .visited = visited };
+    }
+
+    pub fn deinit(self: *Regex) void {
+        self.visited.deinit(self.allocator);
+        self.allocator.free(self.next);
+        self.allocator.free(self.current);
+        self.allocator.free(self.program);
+    }
+
+    /// Reports whether the pattern matches anywhere in `text`.
+    pub fn isMatch(self: *Regex, text: []const u8) bool {
+        var current_len: usize = 0;
+        var pos: usize = 0;
+        while (true) : (pos += 1) {
+            // Seeding a thread at every position makes the search unanchored.
+            self.visited.unsetAll();
+            for (self.current[0..current_len]) |pc| self.visited.set(pc);
+            if (self.addThread(self.current, &current_len, 0, pos, text.len)) return true;
+            if (pos == text.len) return false;
+
+            self.visited.unsetAll();
+            var next_len: usize = 0;
+            for (self.current[0..current_len]) |pc| {
+                switch (self.program[pc]) {
+                    .set => |set| if (set.isSet(text[pos])) {
+                        if (self.addThread(self.next, &next_len, pc + 1, pos + 1, text.len)) return true;
+                    },
+                    else => {},
+                }
+            }
+            std.mem.swap([]usize, &self.current, &self.next);
+            current_len = next_len;
+        }
+    }
+
+    /// Finds the leftmost longest non-empty match that starts at or after
+    /// `from`. Tries each start in turn, so it is quadratic in the text and
+    /// best kept to single lines.
+    pub fn find(self: *Regex, text: []const u8, from: usize) ?Span {
+        if (!self.isMatch(text)) return null;
+        var start = from;
+        while (start < text.len) : (start += 1) {
+            if (self.longestAt(text, start)) |end| return .{ .start = start, .end = end };
+        }
+        return null;
+    }
+
+    /// End of the longest non-empty match anchored at `start`.
+    fn longestAt(self: *Regex, text: []const u8, start: usize) ?usize {
+        var longest: ?usize = null;
+        var current_len: usize = 0;
+        self.visited.unsetAll();
+        _ = self.addThread(self.current, &current_len, 0, start, text.len);
+        var pos = start;
+        while (current_len > 0 and pos < text.len) : (pos += 1) {
+            self.visited.unsetAll();
+            var next_len: usize = 0;
+            for (self.current[0..current_len]) |pc| {
+                switch (self.program[pc]) {
+                    .set => |set| if (set.isSet(text[pos])) {
+                        if (self.addThread(self.next, &next_len, pc + 1, pos + 1, text.len)) longest = pos + 1;
+                    },
+                    else => {},
+                }
+            }
+            std.mem.swap([]usize, &self.current, &self.next);
+            current_len = next_len;
+        }
+        return longest;
+    }
+
+    /// Follows jumps, splits, and anchors from `pc`, queueing the character
+    /// instructions reached. Returns true once `match` is reachable.
+    fn addThread(self: *Regex, list: []usize, len: *usize, pc: usize, pos: usize, text_len: usize) bool {
+        if (self.visited.isSet(pc)) return false;
+        self.visited.set(pc);
+        switch (self.program[pc]) {
+            .match => return true,
+            .jump => |target| return self.addThread(list, len, target, pos, text_len),
+            // Both branches are queued even once one matches, so `find` can
+            // keep extending the match.
+            .split => |split| {
+                const first = self.addThread(list, len, split.first, pos, text_len);
+                const second = self.addThread(list, len, split.second, pos, text_len);
+                return first or second;
+            },
+            .text_start => return pos == 0 and self.addThread(list, len, pc + 1, pos, text_len),
+            .text_end => return pos == text_len and self.addThread(list, len, pc + 1, pos, text_len),
+            .set => {
+                list[len.*] = pc;
+                len.* += 1;
+                return false;
+            },
+        }
+    }
+};
+
+const Parser = struct {
+    arena: std.mem.Allocator,
+    pattern: []const u8,
+    index: usize = 0,
+
+    fn peek(self: *const Parser) ?u8 {
+        return if (self.index < self.pattern.len) self.pattern[self.index] else null;
+    }
+
+    fn parseAlternate(self: *Parser) anyerror!Node {
+        var branches = std.array_list.Managed(Node).init(self.arena);
+        try branches.append(try self.parseConcat());
+        while (self.peek() == '|') {
+            self.index += 1;
+            try branches.append(try self.parseConcat());
+        }
+        if (branches.items.len == 1) return branches.items[0];
+        return .{ .alternate = branches.items };
+    }
+
+    fn parseConcat(self: *Parser) !Node {
+        var items = std.array_list.Managed(Node).init(self.arena);
+        while (self.peek()) |c| {
+            if (c == '|' or c == ')') break;
+            try items.append(try self.parseRepeat());
+        }
+        return switch (items.items.len) {
+            0 => .empty,
+            1 => items.items[0],
+            else => .{ .concat = items.items },
+        };
+    }
+
+    fn parseRepeat(self: *Parser) !Node {
+        var node = try self.parseAtom();
+        while (self.peek()) |c| {
+            if (c != '*' and c != '+' and c != '?') break;
+            self.index += 1;
+            const inner = try self.arena.create(Node);
+            inner.* = node;
+            node = switch (c) {
+                '*' => .{ .star = inner },
+                '+' => .{ .plus = inner },
+                else => .{ .optional = inner },
+            };
+        }
+        return node;
+    }
+
+    fn parseAtom(self: *Parser) !Node {
+        const c = self.peek() orelse return error.InvalidRegex;
+        self.index += 1;
+        switch (c) {
+            '(' => {
+                const inner = try self.parseAlternate();
+                if (self.peek() != ')') return error.InvalidRegex;
+                self.index += 1;
+                return inner;
+            },
+            '[' => return .{ .set = try self.parseClass() },
+            '.' => {
+                var set = Set.initFull();
+                set.unset('\n');
+                return .{ .set = set };
+            },
+            '^' => return .text_start,
+            '$' => return .text_end,
+            '\\' => return .{ .set = try self.parseEscape() },
+            '*', '+', '?', ')' => return error.InvalidRegex,
+            else => return .{ .set = single(c) },
+        }
+    }
+
+    fn parseEscape(self: *Parser) !Set {
+        const c = self.peek() orelse return error.InvalidRegex;
+        self.index += 1;
+        var set = Set.initEmpty();
+        switch (c) {
+            'd', 'D' => set.setRangeValue(.{ .start = '0', .end = '9' + 1 }, true),
+            'w', 'W' => {
+                set.setRangeValue(.{ .start = '0', .end = '9' + 1 }, true);
+                set.setRangeValue(.{ .start = 'a', .end = 'z' + 1 }, true);
+                set.setRangeValue(.{ .start = 'A', .end = 'Z' + 1 }, true);
+                set.set('_');
+            },
+            's', 'S' => for (" \t\r\n\x0b\x0c") |space| set.set(space),
+            else => return single(c),
+        }
+        if (std.ascii.isUpper(c)) set.toggleAll();
+        return set;
+    }
+
+    fn parseClass(self: *Parser) !Set {
+        var set = Set.initEmpty();
+        const negated = self.peek() == '^';
+        if (negated) self.index += 1;
+        var first = true;
+        while (true) {
+            const c = self.peek() orelse return error.InvalidRegex;
+            if (c == ']' and !first) break;
+            first = false;
+            self.index += 1;
+            if (c == '\\') {
+                set.setUnion(try self.parseEscape());
+                continue;
+            }
+            if (self.peek() == '-' and self.index + 1 < self.pattern.len and self.pattern[self.index + 1] != ']') {
+                const end = self.pattern[self.index + 1];
+                if (end < c) return error.InvalidRegex;
+                self.index += 2;
+                set.setRangeValue(.{ .start = c, .end = @as(usize, end) + 1 }, true);
+                continue;
+            }
+            set.set(c);
+        }
+        self.index += 1;
+        if (negated) set.toggleAll();
+        return set;
+    }
+};
+
+fn single(c: u8) Set {
+    var set = Set.initEmpty();
+    set.set(c);
+    return set;
+}
+
+fn emit(program: *std.array_list.Managed(Inst), node: Node) std.mem.Allocator.Error!void {
+    switch (node) {
+        .empty => {},
+        .set => |set| try program.append(.{ .set = set }),
+        .text_start => try program.append(.text_start),
+        .text_end => try program.append(.text_end),
+        .concat => |items| for (items) |item| try emit(program, item),
+        .alternate => |branches| {
+            var jumps = std.array_list.Managed(usize).init(program.allocator);
+            defer jumps.deinit();
+            for (branches[0 .. branches.len - 1]) |branch| {
+                const split = program.items.len;
+                try program.append(.{ .split = .{ .first = split + 1, .second = 0 } });
+                try emit(program, branch);
+                try jumps.append(program.items.len);
+                try program.append(.{ .jump = 0 });
+                program.items[split].split.second = program.items.len;
+            }
+            try emit(program, branches[branches.len - 1]);
+            for (jumps.items) |jump| program.items[jump] = .{ .jump = program.items.len };
+        },
+        .star => |inner| {
+            const split = program.items.len;
+            try program.append(.{ .split = .{ .first = split + 1, .second = 0 } });
+            try emit(program, inner.*);
+            try program.append(.{ .jump = split });
+            program.items[split].split.second = program.items.len;
+        },
+        .plus => |inner| {
+            const start = program.items.len;
+            try emit(program, inner.*);
+            try program.append(.{ .split = .{ .first = start, .second = program.items.len + 1 } });
+        },
+        .optional => |inner| {
+            const split = program.items.len;
+            try program.append(.{ .split = .{ .first = split + 1, .second = 0 } });
+            try emit(program, inner.*);
+            program.items[split].split.second = program.items.len;
+        },
+    }
+}
+
+fn expectMatch(pattern: []const u8, text: []const u8, expected: bool) !void {
+    var regex = try Regex.compile(std.testing.allocator, pattern);
+    defer regex.deinit();
+    try std.testing.expectEqual(expected, regex.isMatch(text));
+}
+
+test "regex matches literals, classes, anchors, and repeats" {
+    try expectMatch("api", "web-api-2", true);
+    try expectMatch("^api", "web-api", false);
+    try expectMatch("^web-.*-\\d+$", "web-api-12", true);
+    try expectMatch("^web-.*-\\d+$", "web-api-x", false);
+    try expectMatch("(api|db)-[0-9]", "db-3", true);
+    try expectMatch("(api|db)-[0-9]", "cache-3", false);
+    try expectMatch("colou?r", "color", true);
+    try expectMatch("[^a-z]", "abc", false);
+    try expectMatch("a+b", "caab", true);
+    try expectMatch("x*", "", true);
+    try expectMatch("\\.zig$", "build.zig", true);
+}
+
+fn expectFind(pattern: []const u8, text: []const u8, from: usize, expected: ?Span) !void {
+    var regex = try Regex.compile(std.testing.allocator, pattern);
+    defer regex.deinit();
+    try std.testing.expectEqual(expected, regex.find(text, from));
+}
+
+test "regex find returns the leftmost longest non-empty match" {
+    try expectFind("ghp_[a-z0-9]+", "token ghp_abc123 set", 0, .{ .start = 6, .end = 16 });
+    try expectFind("a+", "baaab aa", 0, .{ .start = 1, .end = 4 });
+    try expectFind("a+", "baaab aa", 4, .{ .start = 6, .end = 8 });
+    try expectFind("^a", "baa", 1, null);
+    try expectFind("x*", "abc", 0, null);
+    try expectFind("(ab|abcd)", "xabcd", 0, .{ .start = 1, .end = 5 });
+}
+
+test "regex rejects malformed patterns" {
+    for ([_][]const u8{ "(api", "api)", "[a-", "*x", "a\\", "[z-a]" }) |pattern| {
+        try std.testing.expectError(error.InvalidRegex, Regex.compile(std.testing.allocator, pattern));
+    }
+}
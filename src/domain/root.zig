@@ -1,20 +1,26 @@
 //! Domain namespace and domain-level tests.
-//! This module provides a stable import seam for process, app state, filtering, fuzzy matching, and Client Snapshots.
+//! This module provides a stable import seam for process, app state, change signals, filter queries, fuzzy matching, output line patterns, and Client Snapshots, which own process list filtering and sorting.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
 
 pub const process = @import("process.zig");
 pub const state = @import("state.zig");
+pub const changes = @import("changes.zig");
 pub const fuzzy = @import("fuzzy.zig");
-pub const filter = @import("filter.zig");
+pub const query = @import("query.zig");
+pub const regex = @import("regex.zig");
+pub const patterns = @import("patterns.zig");
 pub const client_snapshot = @import("client_snapshot.zig");
 
 test {
     _ = process;
     _ = state;
+    _ = changes;
     _ = fuzzy;
-    _ = filter;
+    _ = query;
+    _ = regex;
+    _ = patterns;
     _ = client_snapshot;
 }
 
@@ -23,9 +29,43 @@ test "status names match public status strings" {
     try std.testing.expectEqualStrings("Halting", process.statusName(.halting));
     try std.testing.expectEqualStrings("Halted", process.statusName(.halted));
     try std.testing.expectEqualStrings("Exited", process.statusName(.exited));
+    try std.testing.expectEqualStrings("Starting", process.statusName(.starting));
+    try std.testing.expectEqualStrings("Restarting", process.statusName(.restarting));
     try std.testing.expectEqualStrings("Unknown", process.statusName(.unknown));
 }
 
+test "process status moves only through valid lifecycle events" {
+    const Status = process.ProcessStatus;
+
+    // Start, exit on its own, restart, then a user stop.
+    var status = Status.halted;
+    for ([_]struct { process.StatusEvent, Status }{
+        .{ .start, .starting },
+        .{ .spawned, .running },
+        .{ .exit, .exited },
+        .{ .restart, .restarting },
+        .{ .released, .restarting },
+        .{ .start, .starting },
+        .{ .spawned, .running },
+        .{ .stop, .halting },
+        .{ .exit, .halting },
+        .{ .released, .halted },
+    }) |step| {
+        status = try status.next(step[0]);
+        try std.testing.expectEqual(step[1], status);
+    }
+
+    try std.testing.expectEqual(Status.halted, try Status.starting.next(.start_failed));
+    try std.testing.expectEqual(Status.halted, try Status.restarting.next(.start_failed));
+    try std.testing.expectError(error.InvalidTransition, Status.halted.next(.stop));
+    try std.testing.expectError(error.InvalidTransition, Status.running.next(.start));
+    try std.testing.expectError(error.InvalidTransition, Status.halting.next(.restart));
+    try std.testing.expectError(error.InvalidTransition, Status.starting.next(.exit));
+    try std.testing.expectError(error.InvalidTransition, Status.unknown.next(.start));
+    try std.testing.expect(Status.restarting.isTransitional());
+    try std.testing.expect(!Status.exited.isTransitional());
+}
+
 test "process command prefers shell and quotes cmd args like legacy behavior" {
     var cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
     defer cfg.deinit(std.testing.allocator);
@@ -84,84 +124,6 @@ test "app state sorts process labels before assigning ids" {
     try std.testing.expect(app.getProcessByLabel("backend") != null);
 }
 
-test "category filter uses AND matching and running-only toggle" {
-    var api_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
-    defer api_cfg.deinit(std.testing.allocator);
-    try config.schema.appendOwned(std.testing.allocator, &api_cfg.categories, "server");
-    try config.schema.appendOwned(std.testing.allocator, &api_cfg.categories, "api");
-
-    var gateway_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
-    defer gateway_cfg.deinit(std.testing.allocator);
-    try config.schema.appendOwned(std.testing.allocator, &gateway_cfg.categories, "server");
-    try config.schema.appendOwned(std.testing.allocator, &gateway_cfg.categories, "gateway");
-
-    var cfg = config.schema.Config.empty(std.testing.allocator);
-    defer cfg.deinit();
-    try config.defaults.apply(&cfg, std.testing.allocator);
-
-    var views = [_]process.ProcessView{
-        .{ .id = process.ProcessId.fromInt(1), .label = "backend", .status = .running, .pid = 101, .config = &api_cfg },
-        .{ .id = process.ProcessId.fromInt(2), .label = "api-gateway", .status = .halted, .pid = -1, .config = &gateway_cfg },
-    };
-
-    const result = try filter.filterProcesses(std.testing.allocator, &cfg, views[0..], "cat:server,api", false);
-    defer std.testing.allocator.free(result);
-    try std.testing.expectEqual(@as(usize, 1), result.len);
-    try std.testing.expectEqualStrings("backend", result[0].label);
-
-    const running = try filter.filterProcesses(std.testing.allocator, &cfg, views[0..], "cat:server", true);
-    defer std.testing.allocator.free(running);
-    try std.testing.expectEqual(@as(usize, 1), running.len);
-    try std.testing.expectEqualStrings("backend", running[0].label);
-}
-
-test "sort running first then alpha" {
-    var cfg = config.schema.Config.empty(std.testing.allocator);
-    defer cfg.deinit();
-    try config.defaults.apply(&cfg, std.testing.allocator);
-    cfg.layout.sort_process_list_running_first = true;
-    cfg.layout.sort_process_list_alpha = true;
-
-    var empty_proc = config.schema.ProcessConfig.empty(std.testing.allocator);
-    defer empty_proc.deinit(std.testing.allocator);
-
-    var views = [_]process.ProcessView{
-        .{ .id = process.ProcessId.fromInt(1), .label = "halted-zebra", .status = .halted, .config = &empty_proc },
-        .{ .id = process.ProcessId.fromInt(2), .label = "running-mango", .status = .running, .config = &empty_proc },
-        .{ .id = process.ProcessId.fromInt(3), .label = "halted-apple", .status = .halted, .config = &empty_proc },
-        .{ .id = process.ProcessId.fromInt(4), .label = "running-banana", .status = .running, .config = &empty_proc },
-    };
-
-    const result = try filter.filterProcesses(std.testing.allocator, &cfg, views[0..], "", false);
-    defer std.testing.allocator.free(result);
-
-    try std.testing.expectEqualStrings("running-banana", result[0].label);
-    try std.testing.expectEqualStrings("running-mango", result[1].label);
-    try std.testing.expectEqualStrings("halted-apple", result[2].label);
-    try std.testing.expectEqualStrings("halted-zebra", result[3].label);
-}
-
-test "fuzzy label search ignores configured sorting" {
-    var cfg = config.schema.Config.empty(std.testing.allocator);
-    defer cfg.deinit();
-    try config.defaults.apply(&cfg, std.testing.allocator);
-    cfg.layout.sort_process_list_running_first = true;
-    cfg.layout.sort_process_list_alpha = true;
-
-    var empty_proc = config.schema.ProcessConfig.empty(std.testing.allocator);
-    defer empty_proc.deinit(std.testing.allocator);
-
-    var views = [_]process.ProcessView{
-        .{ .id = process.ProcessId.fromInt(1), .label = "zebra-api", .status = .halted, .config = &empty_proc },
-        .{ .id = process.ProcessId.fromInt(2), .label = "api-service", .status = .running, .config = &empty_proc },
-        .{ .id = process.ProcessId.fromInt(3), .label = "apple-api", .status = .halted, .config = &empty_proc },
-    };
-
-    const result = try filter.filterProcesses(std.testing.allocator, &cfg, views[0..], "api", false);
-    defer std.testing.allocator.free(result);
-    try std.testing.expectEqual(@as(usize, 3), result.len);
-}
-
 const FakeController = struct {
     status: process.ProcessStatus,
     pid: i32,
@@ -15,6 +15,8 @@ pub const Mode = enum {
 pub const AppState = struct {
     allocator: std.mem.Allocator,
     config: *config.schema.Config,
+    /// Filled by `init` and never resized after, so the Primary Server's
+    /// monitors and trackers may keep pointers to its processes.
     processes: std.array_list.Managed(process.Process),
     current_proc_id: process.ProcessId = .none,
     exiting: bool = false,
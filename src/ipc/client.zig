@@ -2,12 +2,98 @@
 //! The client buffers interleaved Snapshot and Response messages so TUI sessions can match command responses without losing the latest server snapshot.
 
 const std = @import("std");
+const frame = @import("frame.zig");
 const line_io = @import("line.zig");
 const protocol = @import("protocol.zig");
+const socket = @import("socket.zig");
+
+const log = std.log.scoped(.ipc);
 
 const max_response_line = 1024 * 1024;
 const default_response_timeout_ms = 5000;
 
+/// Reconnect delay that doubles from `initial_ms` up to `max_ms`, so a client
+/// waiting on a restarting primary neither spins nor lags far behind it.
+pub const Backoff = struct {
+    initial_ms: u64 = 100,
+    max_ms: u64 = 5000,
+    next_ms: u64 = 0,
+
+    pub fn nextDelayMs(self: *Backoff) u64 {
+        const delay = if (self.next_ms == 0) self.initial_ms else self.next_ms;
+        self.next_ms = @min(delay * 2, self.max_ms);
+        return delay;
+    }
+};
+
+/// Errors that mean the primary went away rather than misbehaved.
+pub fn isDisconnect(err: anyerror) bool {
+    return switch (err) {
+        error.EndOfStream,
+        error.ConnectionResetByPeer,
+        error.BrokenPipe,
+        error.NotConnected,
+        error.HeartbeatTimeout,
+        => true,
+        else => false,
+    };
+}
+
+/// Answer to `Client.fetchScrollback`.
+pub const ScrollbackReply = union(enum) {
+    data: protocol.ScrollbackData,
+    refused: protocol.Response,
+
+    pub fn deinit(self: *const ScrollbackReply, allocator: std.mem.Allocator) void {
+        switch (self.*) {
+            .data => |data| data.deinit(allocator),
+            .refused => |response| response.deinit(allocator),
+        }
+    }
+};
+
+/// Answer to an environment fetch: the variables, or the server's refusal.
+pub const EnvReply = union(enum) {
+    data: protocol.EnvData,
+    refused: protocol.Response,
+
+    pub fn deinit(self: *const EnvReply, allocator: std.mem.Allocator) void {
+        switch (self.*) {
+            .data => |*data| data.deinit(),
+            .refused => |response| response.deinit(allocator),
+        }
+    }
+};
+
+/// Answer to `Client.fetchDetails`.
+pub const DetailsReply = union(enum) {
+    data: protocol.DetailsData,
+    refused: protocol.Response,
+
+    pub fn deinit(self: *const DetailsReply, allocator: std.mem.Allocator) void {
+        switch (self.*) {
+            .data => |data| data.deinit(allocator),
+            .refused => |response| response.deinit(allocator),
+        }
+    }
+};
+
+/// Selects the part of a process's scrollback a fetch returns; see
+/// `protocol.ScrollbackRequest`.
+pub const ScrollbackOptions = struct {
+    lines: ?u32 = null,
+    bytes: ?u32 = null,
+    since_ms: ?i64 = null,
+    stderr: bool = false,
+};
+
+/// Limits the history an output stream sends before live output.
+pub const StreamOptions = struct {
+    lines: ?u32 = null,
+    since_ms: ?i64 = null,
+    stderr: bool = false,
+};
+
 /// Persistent client connection used by interactive TUI sessions. It preserves
 /// snapshots seen while waiting for command responses so UI state is never lost
 /// to message interleaving on the socket.
@@ -19,17 +105,27 @@ pub const Client = struct {
     pending_snapshot: ?protocol.SnapshotUpdate = null,
     response_timeout_ms: i32 = default_response_timeout_ms,
     read_buffer: std.array_list.Managed(u8),
+    /// When the server last sent any bytes; heartbeats keep this fresh on an
+    /// otherwise idle connection.
+    last_received_ms: i64,
+    /// Latest full state as a sequenced snapshot line, the base for applying
+    /// the next delta. Null until the server sends a sequenced snapshot.
+    state_line: ?[]const u8 = null,
+    state_seq: u64 = 0,
+    resync_requested: bool = false,
 
     pub fn connect(allocator: std.mem.Allocator, socket_path: []const u8) !Client {
         return .{
             .allocator = allocator,
-            .stream = try std.net.connectUnixSocket(socket_path),
+            .stream = try socket.connect(allocator, socket_path),
             .read_buffer = std.array_list.Managed(u8).init(allocator),
+            .last_received_ms = std.time.milliTimestamp(),
         };
     }
 
     pub fn deinit(self: *Client) void {
         if (self.pending_snapshot) |*snapshot| snapshot.deinit();
+        if (self.state_line) |line| self.allocator.free(line);
         self.read_buffer.deinit();
         self.close();
     }
@@ -40,9 +136,120 @@ pub const Client = struct {
         self.stream.close();
     }
 
+    /// Replaces a dead connection. Buffered bytes and any pending snapshot came
+    /// from the old server and are dropped; the new server's initial snapshot
+    /// is the resync point.
+    pub fn reconnect(self: *Client, socket_path: []const u8) !void {
+        self.close();
+        if (self.pending_snapshot) |*snapshot| snapshot.deinit();
+        self.pending_snapshot = null;
+        if (self.state_line) |line| self.allocator.free(line);
+        self.state_line = null;
+        self.resync_requested = false;
+        self.read_buffer.clearRetainingCapacity();
+
+        self.stream = try socket.connect(self.allocator, socket_path);
+        self.closed = false;
+        self.last_received_ms = std.time.milliTimestamp();
+    }
+
+    /// Milliseconds since the server last sent anything.
+    pub fn silenceMs(self: *const Client, now_ms: i64) i64 {
+        return @max(now_ms - self.last_received_ms, 0);
+    }
+
+    /// Fails once the server has missed enough heartbeats that the connection
+    /// is presumed half-open. Callers treat this like any other disconnect.
+    pub fn checkHeartbeat(self: *const Client, now_ms: i64) !void {
+        if (self.closed) return error.NotConnected;
+        if (self.silenceMs(now_ms) > protocol.heartbeat_timeout_ms) return error.HeartbeatTimeout;
+    }
+
+    /// Decodes one server line, answering heartbeats and turning deltas into
+    /// full snapshots. Returns null for lines the caller never sees.
+    fn decodeIncoming(self: *Client, line: []const u8) !?protocol.Message {
+        var message = protocol.decodeLine(self.allocator, line) catch |err| switch (err) {
+            // A primary on another protocol version has to fail loudly.
+            error.OutOfMemory, error.UnsupportedProtocolVersion => return err,
+            else => {
+                // One corrupt line does not end the session. It may have
+                // carried state, so a full snapshot replaces whatever it was.
+                log.warn("dropping IPC line that failed to decode: {s}", .{@errorName(err)});
+                if (self.state_line != null) try self.requestResync();
+                return null;
+            },
+        };
+        switch (message) {
+            .snapshot => |*snapshot| self.rememberState(line, snapshot.seq()) catch |err| {
+                snapshot.deinit();
+                return err;
+            },
+            .delta => |*delta| {
+                defer delta.deinit();
+                const update = (try self.applyDelta(delta)) orelse return null;
+                return .{ .snapshot = update };
+            },
+            .ping => |seq| {
+                try self.sendPong(seq);
+                return null;
+            },
+            .pong => return null,
+            else => {},
+        }
+        return message;
+    }
+
+    fn rememberState(self: *Client, line: []const u8, seq: ?u64) !void {
+        const copy = if (seq != null) try self.allocator.dupe(u8, line) else null;
+        if (self.state_line) |previous| self.allocator.free(previous);
+        self.state_line = copy;
+        self.state_seq = seq orelse 0;
+        self.resync_requested = false;
+    }
+
+    /// Deltas only apply to the exact state they were built against; after a
+    /// gap the client asks once for a full snapshot and drops deltas until it
+    /// arrives.
+    fn applyDelta(self: *Client, delta: *const protocol.DeltaUpdate) !?protocol.SnapshotUpdate {
+        const base_line = self.state_line orelse {
+            try self.requestResync();
+            return null;
+        };
+        if (self.state_seq != delta.baseSeq()) {
+            try self.requestResync();
+            return null;
+        }
+
+        var base = try protocol.parseSnapshotLine(self.allocator, base_line);
+        defer base.deinit();
+        const next_line = try protocol.applyDelta(self.allocator, base.snapshot(), delta);
+        errdefer self.allocator.free(next_line);
+        const update = try protocol.parseSnapshotLine(self.allocator, next_line);
+
+        self.allocator.free(base_line);
+        self.state_line = next_line;
+        self.state_seq = delta.seq();
+        return update;
+    }
+
+    fn requestResync(self: *Client) !void {
+        if (self.resync_requested) return;
+        const line = try protocol.resyncLine(self.allocator);
+        defer self.allocator.free(line);
+        try self.stream.writeAll(line);
+        self.resync_requested = true;
+    }
+
+    fn sendPong(self: *Client, seq: u64) !void {
+        const line = try protocol.pongLine(self.allocator, seq);
+        defer self.allocator.free(line);
+        try self.stream.writeAll(line);
+    }
+
     /// Sends a Process Command and returns the request id the caller should use
     /// to match the eventual response.
     pub fn sendCommand(self: *Client, action: protocol.Command, label: []const u8) !u64 {
+        if (self.closed) return error.NotConnected;
         const request_id = self.next_request_id;
         self.next_request_id += 1;
 
@@ -54,6 +261,179 @@ pub const Client = struct {
         return request_id;
     }
 
+    /// Sends one Process Command for every label in `labels`; the primary
+    /// answers the batch with a single response.
+    pub fn sendBatchCommand(self: *Client, action: protocol.Command, labels: []const []const u8) !u64 {
+        if (self.closed) return error.NotConnected;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+
+        const request = try protocol.batchCommandRequestLine(self.allocator, request_id, action, labels);
+        defer self.allocator.free(request);
+        try self.stream.writeAll(request);
+
+        return request_id;
+    }
+
+    /// Fetches the tail of `label`'s scrollback; see `protocol.scrollbackTail`
+    /// for how the options select it. A refusal, such as an unknown label,
+    /// comes back as the server's failure response.
+    pub fn fetchScrollback(self: *Client, label: []const u8, options: ScrollbackOptions) !ScrollbackReply {
+        if (self.closed) return error.NotConnected;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+
+        const request = try protocol.scrollbackRequestLine(self.allocator, .{
+            .request_id = request_id,
+            .target = label,
+            .lines = options.lines,
+            .bytes = options.bytes,
+            .since_ms = options.since_ms,
+            .stderr = options.stderr,
+        });
+        defer self.allocator.free(request);
+        try self.stream.writeAll(request);
+
+        while (true) {
+            const line = try self.readLineWithTimeout(self.response_timeout_ms);
+            defer self.allocator.free(line);
+
+            var message = (try self.decodeIncoming(line)) orelse continue;
+            switch (message) {
+                .scrollback_data => |data| {
+                    if (data.request_id == request_id) return .{ .data = data };
+                    data.deinit(self.allocator);
+                },
+                .response => |*response| {
+                    if (response.request_id == request_id) return .{ .refused = response.* };
+                    response.deinit(self.allocator);
+                },
+                .snapshot => |snapshot| {
+                    if (self.pending_snapshot) |*pending| pending.deinit();
+                    self.pending_snapshot = snapshot;
+                },
+                else => {
+                    message.deinit(self.allocator);
+                    return error.InvalidResponse;
+                },
+            }
+        }
+    }
+
+    /// Fetches the environment `label` starts with, as the primary resolves
+    /// it. Snapshots read while waiting are kept for the next snapshot read.
+    pub fn fetchEnv(self: *Client, label: []const u8) !EnvReply {
+        if (self.closed) return error.NotConnected;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+
+        const request = try protocol.envRequestLine(self.allocator, .{
+            .request_id = request_id,
+            .target = label,
+        });
+        defer self.allocator.free(request);
+        try self.stream.writeAll(request);
+
+        while (true) {
+            const line = try self.readLineWithTimeout(self.response_timeout_ms);
+            defer self.allocator.free(line);
+
+            var message = (try self.decodeIncoming(line)) orelse continue;
+            switch (message) {
+                .env_data => |*data| {
+                    if (data.requestId() == request_id) return .{ .data = data.* };
+                    data.deinit();
+                },
+                .response => |*response| {
+                    if (response.request_id == request_id) return .{ .refused = response.* };
+                    response.deinit(self.allocator);
+                },
+                .snapshot => |snapshot| {
+                    if (self.pending_snapshot) |*pending| pending.deinit();
+                    self.pending_snapshot = snapshot;
+                },
+                else => {
+                    message.deinit(self.allocator);
+                    return error.InvalidResponse;
+                },
+            }
+        }
+    }
+
+    /// Fetches `label`'s command line and working directory, which snapshots
+    /// leave out. Snapshots read while waiting are kept for the next snapshot
+    /// read.
+    pub fn fetchDetails(self: *Client, label: []const u8) !DetailsReply {
+        if (self.closed) return error.NotConnected;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+
+        const request = try protocol.detailsRequestLine(self.allocator, .{
+            .request_id = request_id,
+            .target = label,
+        });
+        defer self.allocator.free(request);
+        try self.stream.writeAll(request);
+
+        while (true) {
+            const line = try self.readLineWithTimeout(self.response_timeout_ms);
+            defer self.allocator.free(line);
+
+            var message = (try self.decodeIncoming(line)) orelse continue;
+            switch (message) {
+                .details_data => |data| {
+                    if (data.request_id == request_id) return .{ .data = data };
+                    data.deinit(self.allocator);
+                },
+                .response => |*response| {
+                    if (response.request_id == request_id) return .{ .refused = response.* };
+                    response.deinit(self.allocator);
+                },
+                .snapshot => |snapshot| {
+                    if (self.pending_snapshot) |*pending| pending.deinit();
+                    self.pending_snapshot = snapshot;
+                },
+                else => {
+                    message.deinit(self.allocator);
+                    return error.InvalidResponse;
+                },
+            }
+        }
+    }
+
+    /// Asks the server to stream `label`'s output on this connection and waits
+    /// for the answer. After a successful response the connection carries only
+    /// output frames: read them with `readOutputFrame` and send nothing else.
+    pub fn requestOutputStream(self: *Client, label: []const u8, options: StreamOptions) !protocol.Response {
+        if (self.closed) return error.NotConnected;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+
+        const request = try protocol.streamRequestLine(self.allocator, .{
+            .request_id = request_id,
+            .target = label,
+            .lines = options.lines,
+            .since_ms = options.since_ms,
+            .stderr = options.stderr,
+        });
+        defer self.allocator.free(request);
+        try self.stream.writeAll(request);
+
+        return self.readResponseFor(request_id);
+    }
+
+    /// Reads the next output frame of a stream opened with
+    /// `requestOutputStream`. Ends with EndOfStream when the server closes it.
+    pub fn readOutputFrame(self: *Client) !frame.Frame {
+        if (self.closed) return error.NotConnected;
+        // Lines are read a byte at a time, so nothing past the stream
+        // response is buffered and frames start at the socket.
+        std.debug.assert(self.read_buffer.items.len == 0);
+        const output_frame = try frame.read(self.allocator, self.stream);
+        self.last_received_ms = std.time.milliTimestamp();
+        return output_frame;
+    }
+
     pub fn readSnapshot(self: *Client) !protocol.SnapshotUpdate {
         if (self.pending_snapshot) |*snapshot| {
             const pending = snapshot.*;
@@ -65,15 +445,15 @@ pub const Client = struct {
             const line = try self.readLineBlocking();
             defer self.allocator.free(line);
 
-            var message = try protocol.decodeLine(self.allocator, line);
+            var message = (try self.decodeIncoming(line)) orelse continue;
             switch (message) {
                 .snapshot => |snapshot| return snapshot,
                 .response => |*response| {
                     response.deinit(self.allocator);
                     continue;
                 },
-                .command => |request| {
-                    protocol.deinitCommandRequest(self.allocator, request);
+                else => {
+                    message.deinit(self.allocator);
                     return error.InvalidSnapshot;
                 },
             }
@@ -116,15 +496,15 @@ pub const Client = struct {
         while (try self.readLineIfAvailable()) |line| {
             defer self.allocator.free(line);
 
-            var message = try protocol.decodeLine(self.allocator, line);
+            var message = (try self.decodeIncoming(line)) orelse continue;
             switch (message) {
                 .snapshot => |snapshot| return snapshot,
                 .response => |*response| {
                     response.deinit(self.allocator);
                     continue;
                 },
-                .command => |request| {
-                    protocol.deinitCommandRequest(self.allocator, request);
+                else => {
+                    message.deinit(self.allocator);
                     return error.InvalidSnapshot;
                 },
             }
@@ -169,15 +549,18 @@ pub const Client = struct {
     }
 
     fn readOneByte(self: *Client) !void {
+        if (self.closed) return error.NotConnected;
         if (self.read_buffer.items.len >= max_response_line) return error.LineTooLong;
 
         var byte: [1]u8 = undefined;
         const n = try self.stream.read(&byte);
         if (n == 0) return error.EndOfStream;
+        self.last_received_ms = std.time.milliTimestamp();
         try self.read_buffer.append(byte[0]);
     }
 
     fn waitForReadableData(self: *Client, timeout_ms: i32) !bool {
+        if (self.closed) return error.NotConnected;
         var poll_fds = [_]std.posix.pollfd{.{
             .fd = self.stream.handle,
             .events = std.posix.POLL.IN,
@@ -198,7 +581,7 @@ pub const Client = struct {
             const line = try self.readLineWithTimeout(self.response_timeout_ms);
             defer self.allocator.free(line);
 
-            const message = try protocol.decodeLine(self.allocator, line);
+            var message = (try self.decodeIncoming(line)) orelse continue;
             switch (message) {
                 .response => |response| {
                     if (expected_request_id) |request_id| {
@@ -215,8 +598,8 @@ pub const Client = struct {
                     self.pending_snapshot = snapshot;
                     continue;
                 },
-                .command => |request| {
-                    protocol.deinitCommandRequest(self.allocator, request);
+                else => {
+                    message.deinit(self.allocator);
                     return error.InvalidResponse;
                 },
             }
@@ -258,7 +641,7 @@ pub fn sendCommandToPathWithTimeout(
     label: []const u8,
     response_timeout_ms: i32,
 ) !protocol.Response {
-    var stream = try std.net.connectUnixSocket(socket_path);
+    var stream = try socket.connect(allocator, socket_path);
     defer stream.close();
 
     const target: ?[]const u8 = if (label.len == 0) null else label;
@@ -277,8 +660,14 @@ pub fn sendCommandToPathWithTimeout(
                 snapshot.deinit();
                 continue;
             },
-            .command => |command_request| {
-                protocol.deinitCommandRequest(allocator, command_request);
+            .delta => |*delta| {
+                delta.deinit();
+                continue;
+            },
+            // One-shot commands finish well inside a heartbeat interval.
+            .ping, .pong => continue,
+            .command, .stream, .scrollback, .scrollback_data, .details, .details_data, .env, .env_data, .resync => {
+                message.deinit(allocator);
                 return error.InvalidResponse;
             },
         }
@@ -0,0 +1,113 @@
+//! Length-prefixed binary frames for raw process output streams.
+//! A frame is a one-byte kind, a big-endian u32 payload length, and the payload, so output bytes cross the socket without JSON escaping.
+
+const std = @import("std");
+
+pub const header_len = 5;
+/// Largest payload the server sends in one frame; longer output is split.
+pub const max_payload_len = 64 * 1024;
+
+pub const Kind = enum(u8) {
+    /// Scrollback retained when the stream opened, oldest bytes first.
+    history = 1,
+    /// Empty marker sent once after the last history frame.
+    history_end = 2,
+    /// Output written after the stream opened.
+    output = 3,
+};
+
+pub const Frame = struct {
+    kind: Kind,
+    payload: []u8,
+
+    pub fn deinit(self: *const Frame, allocator: std.mem.Allocator) void {
+        allocator.free(self.payload);
+    }
+};
+
+/// Encodes one frame with its header. `payload` must not exceed
+/// `max_payload_len`; senders split longer output across frames.
+pub fn encode(allocator: std.mem.Allocator, kind: Kind, payload: []const u8) ![]u8 {
+    std.debug.assert(payload.len <= max_payload_len);
+    const bytes = try allocator.alloc(u8, header_len + payload.len);
+    bytes[0] = @intFromEnum(kind);
+    std.mem.writeInt(u32, bytes[1..header_len], @intCast(payload.len), .big);
+    @memcpy(bytes[header_len..], payload);
+    return bytes;
+}
+
+/// Reads one complete frame, rejecting unknown kinds and payloads longer than
+/// `max_payload_len` so a corrupt stream cannot force a huge allocation.
+pub fn read(allocator: std.mem.Allocator, stream: std.net.Stream) !Frame {
+    var header: [header_len]u8 = undefined;
+    try readExact(stream, &header);
+
+    const kind = std.meta.intToEnum(Kind, header[0]) catch return error.InvalidFrame;
+    const payload_len = std.mem.readInt(u32, header[1..header_len], .big);
+    if (payload_len > max_payload_len) return error.FrameTooLong;
+
+    const payload = try allocator.alloc(u8, payload_len);
+    errdefer allocator.free(payload);
+    try readExact(stream, payload);
+    return .{ .kind = kind, .payload = payload };
+}
+
+fn readExact(stream: std.net.Stream, buffer: []u8) !void {
+    var index: usize = 0;
+    while (index < buffer.len) {
+        const n = try stream.read(buffer[index..]);
+        if (n == 0) return error.EndOfStream;
+        index += n;
+    }
+}
+
+test "frames round trip through a socket pair" {
+    const writer, const reader = try testSocketPair();
+    defer writer.close();
+    defer reader.close();
+
+    const bytes = try encode(std.testing.allocator, .output, "line one\r\n\x1b[31mred\x1b[0m\n");
+    defer std.testing.allocator.free(bytes);
+    const marker = try encode(std.testing.allocator, .history_end, "");
+    defer std.testing.allocator.free(marker);
+    try writer.writeAll(bytes);
+    try writer.writeAll(marker);
+
+    const first = try read(std.testing.allocator, reader);
+    defer first.deinit(std.testing.allocator);
+    try std.testing.expectEqual(Kind.output, first.kind);
+    try std.testing.expectEqualStrings("line one\r\n\x1b[31mred\x1b[0m\n", first.payload);
+
+    const second = try read(std.testing.allocator, reader);
+    defer second.deinit(std.testing.allocator);
+    try std.testing.expectEqual(Kind.history_end, second.kind);
+    try std.testing.expectEqual(@as(usize, 0), second.payload.len);
+}
+
+test "frame reader rejects unknown kinds and oversized payloads" {
+    const writer, const reader = try testSocketPair();
+    defer writer.close();
+    defer reader.close();
+
+    try writer.writeAll(&.{ 9, 0, 0, 0, 0 });
+    try std.testing.expectError(error.InvalidFrame, read(std.testing.allocator, reader));
+
+    try writer.writeAll(&.{ @intFromEnum(Kind.output), 0xff, 0xff, 0xff, 0xff });
+    try std.testing.expectError(error.FrameTooLong, read(std.testing.allocator, reader));
+}
+
+fn testSocketPair() ![2]std.net.Stream {
+    var fds: [2]std.c.fd_t = undefined;
+    const rc = std.c.socketpair(
+        @intCast(std.posix.AF.UNIX),
+        @intCast(std.posix.SOCK.STREAM),
+        0,
+        &fds,
+    );
+    if (rc != 0) return error.SocketPairFailed;
+
+    return .{
+        .{ .handle = fds[0] },
+        .{ .handle = fds[1] },
+    };
+}
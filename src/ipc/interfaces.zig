@@ -1,7 +1,8 @@
 //! Small callback interfaces at IPC seams.
-//! These adapters let IPC transport own sockets and serialization while Primary Server owns Process Command execution and Snapshot production.
+//! These adapters let IPC transport own sockets and serialization while Primary Server owns Process Command execution, Snapshot production, and process output.
 
 const std = @import("std");
+const ring = @import("../ring/root.zig");
 const protocol = @import("protocol.zig");
 
 /// Adapter from transport-owned command requests to the domain owner that can
@@ -34,13 +35,67 @@ pub const SnapshotProvider = struct {
     }
 };
 
+/// Adapter that resolves a process label to its scrollback buffer, or with
+/// `stderr` to its stderr-only buffer, so output streams can follow it.
+/// Returns null for unknown labels. Buffers must outlive the IPC server; they
+/// are reused across restarts, so a stream keeps following a process that
+/// restarts.
+pub const OutputProvider = struct {
+    context: *anyopaque,
+    scrollback: *const fn (context: *anyopaque, label: []const u8, stderr: bool) anyerror!?*ring.RingBuffer,
+
+    pub fn scrollbackFor(self: OutputProvider, label: []const u8, stderr: bool) !?*ring.RingBuffer {
+        return self.scrollback(self.context, label, stderr);
+    }
+};
+
+/// Adapter that answers environment requests for a process label with an
+/// encoded `env_data` line. Returns null for unknown labels.
+pub const EnvProvider = struct {
+    context: *anyopaque,
+    env_line: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        request_id: u64,
+        label: []const u8,
+    ) anyerror!?[]const u8,
+
+    pub fn envLine(self: EnvProvider, allocator: std.mem.Allocator, request_id: u64, label: []const u8) !?[]const u8 {
+        return self.env_line(self.context, allocator, request_id, label);
+    }
+};
+
+/// Adapter that answers detail requests for a process label with an encoded
+/// `details_data` line. Returns null for unknown labels.
+pub const DetailsProvider = struct {
+    context: *anyopaque,
+    details_line: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        request_id: u64,
+        label: []const u8,
+    ) anyerror!?[]const u8,
+
+    pub fn detailsLine(self: DetailsProvider, allocator: std.mem.Allocator, request_id: u64, label: []const u8) !?[]const u8 {
+        return self.details_line(self.context, allocator, request_id, label);
+    }
+};
+
 /// Authorization seam for accepted Unix socket streams. Production verifies
 /// same-user peers; tests can inject success or failure.
 pub const PeerAuthorizer = struct {
     context: *anyopaque,
     authorize: *const fn (context: *anyopaque, fd: std.posix.fd_t) anyerror!void,
+    /// Checks that wait on the client, such as the token handshake. The
+    /// snapshot server runs them on the client's worker, not the accept loop.
+    handshake: ?*const fn (context: *anyopaque, fd: std.posix.fd_t) anyerror!void = null,
 
     pub fn authorizeStream(self: PeerAuthorizer, stream: std.net.Stream) !void {
         try self.authorize(self.context, stream.handle);
     }
+
+    pub fn handshakeStream(self: PeerAuthorizer, stream: std.net.Stream) !void {
+        const handshake = self.handshake orelse return;
+        try handshake(self.context, stream.handle);
+    }
 };
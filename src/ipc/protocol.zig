@@ -6,11 +6,50 @@ const domain = @import("../domain/root.zig");
 
 pub const current_protocol_version: u32 = 1;
 
+/// The Primary Server pings every stateful client at this interval; clients
+/// answer with a pong carrying the same sequence number.
+pub const heartbeat_interval_ms: i64 = 5000;
+/// Either side drops a connection that has been silent this long, which
+/// catches half-open peers (for example after a laptop sleep).
+pub const heartbeat_timeout_ms: i64 = 15_000;
+
+/// Scrollback fetches return at most this many bytes unless they ask for a
+/// line count, and never more than the maximum, which keeps the base64 reply
+/// within a client's line limit.
+pub const default_scrollback_bytes: u32 = 64 * 1024;
+pub const max_scrollback_bytes: u32 = 512 * 1024;
+
+/// Longest request line a server reads. Requests are small; anything longer is
+/// a confused or hostile client.
+pub const max_request_line = 64 * 1024;
+
+/// Machine-readable reason a request failed, sent as `code` next to the
+/// human-readable `error` so clients can branch without parsing text.
+pub const ErrorCode = enum {
+    none,
+    /// Malformed JSON, an unknown message type, field, or action, or a
+    /// message only the server sends.
+    invalid_request,
+    /// `protocol_version` is not this build's.
+    unsupported_version,
+    /// The line was longer than `max_request_line`. The server closes the
+    /// connection after saying so, since the rest of the line is unread.
+    message_too_large,
+    /// The client sent requests faster than the server's per-client limit.
+    rate_limited,
+    /// No process has the requested name.
+    not_found,
+    /// This server cannot answer this kind of request.
+    unavailable,
+    /// The request was understood but could not be carried out.
+    command_failed,
+};
+
 pub const CommandNameError = error{UnknownCommand};
 pub const DecodeError = error{
     InvalidMessageType,
     UnsupportedProtocolVersion,
-} || CommandNameError || std.mem.Allocator.Error || std.json.ParseError(std.json.Scanner) || std.json.ParseFromValueError;
+} || CommandNameError || std.mem.Allocator.Error || std.json.ParseError(std.json.Scanner) || std.json.ParseFromValueError || std.base64.Error;
 
 pub const EncodeError = std.mem.Allocator.Error || std.Io.Writer.Error;
 
@@ -23,14 +62,23 @@ pub const Command = enum {
     switch_process,
     restart_running,
     stop_running,
+    /// Selects the target and holds its output at the first retained error
+    /// line; sent again, it resumes live output.
+    jump_to_error,
+    /// Opens the target's `url` with `open_cmd`.
+    open_url,
+    /// Opens the target's working directory with `editor_cmd` or `open_cmd`.
+    open_cwd,
 };
 
 /// Wire command request after decoding. `target` is optional because bulk
 /// commands operate on all running processes instead of one process label.
+/// A batch request names its processes in `targets` instead of `target`.
 pub const CommandRequest = struct {
     request_id: u64,
     action: Command,
     target: ?[]const u8 = null,
+    targets: []const []const u8 = &.{},
 
     pub fn targetLabel(self: CommandRequest) []const u8 {
         return self.target orelse "";
@@ -39,12 +87,108 @@ pub const CommandRequest = struct {
     pub fn requiresTarget(self: CommandRequest) bool {
         return commandRequiresTarget(self.action);
     }
+
+    pub fn isBatch(self: CommandRequest) bool {
+        return self.targets.len > 0;
+    }
+};
+
+/// Request to turn a stateful connection into a raw output stream for one
+/// process. After a successful response the server sends only `ipc.frame`
+/// frames on that connection. `lines` and `since_ms` limit the history sent
+/// before live output.
+pub const StreamRequest = struct {
+    request_id: u64,
+    target: []const u8,
+    lines: ?u32 = null,
+    since_ms: ?i64 = null,
+    /// Streams only what the process wrote to stderr; see `separate_stderr`.
+    stderr: bool = false,
+};
+
+/// Request for the tail of one process's scrollback: the last `lines` lines,
+/// capped at `bytes` bytes. See `scrollbackTail` for the defaults.
+/// `since_ms` (Unix milliseconds) first drops output written before it.
+pub const ScrollbackRequest = struct {
+    request_id: u64,
+    target: []const u8,
+    lines: ?u32 = null,
+    bytes: ?u32 = null,
+    since_ms: ?i64 = null,
+    /// Reads only what the process wrote to stderr; see `separate_stderr`.
+    stderr: bool = false,
+};
+
+/// Scrollback bytes answering a ScrollbackRequest, decoded from the base64
+/// wire form so raw terminal output survives JSON.
+pub const ScrollbackData = struct {
+    request_id: u64,
+    data: []u8,
+
+    pub fn deinit(self: *const ScrollbackData, allocator: std.mem.Allocator) void {
+        allocator.free(self.data);
+    }
+};
+
+/// Request for the environment a process starts with.
+pub const EnvRequest = struct {
+    request_id: u64,
+    target: []const u8,
+};
+
+/// One variable of a process's environment. `source` is `inherited`,
+/// `metadata`, `add_path`, or `config`; `overridden` is the primary's own
+/// value when the source replaced it.
+pub const EnvVariable = struct {
+    name: []const u8,
+    value: []const u8,
+    source: []const u8,
+    overridden: ?[]const u8 = null,
+};
+
+/// Environment answering an EnvRequest. Variables borrow the parsed JSON
+/// arena, so callers must keep this object alive while using them.
+pub const EnvData = struct {
+    parsed: std.json.Parsed(EnvDataMessage),
+
+    pub fn deinit(self: *const EnvData) void {
+        self.parsed.deinit();
+    }
+
+    pub fn requestId(self: *const EnvData) u64 {
+        return self.parsed.value.request_id;
+    }
+
+    pub fn variables(self: *const EnvData) []const EnvVariable {
+        return self.parsed.value.variables;
+    }
+};
+
+/// Request for the command line and working directory of one process, which
+/// snapshots leave out.
+pub const DetailsRequest = struct {
+    request_id: u64,
+    target: []const u8,
+};
+
+/// Answer to a DetailsRequest: the process's command line as one shell
+/// string and its resolved working directory.
+pub const DetailsData = struct {
+    request_id: u64,
+    command: []const u8,
+    cwd: []const u8,
+
+    pub fn deinit(self: *const DetailsData, allocator: std.mem.Allocator) void {
+        allocator.free(self.command);
+        allocator.free(self.cwd);
+    }
 };
 
 pub const Response = struct {
     request_id: u64,
     success: bool,
     error_message: []const u8,
+    code: ErrorCode = .none,
 
     pub fn deinit(self: *const Response, allocator: std.mem.Allocator) void {
         allocator.free(self.error_message);
@@ -64,6 +208,29 @@ pub const SnapshotUpdate = struct {
     pub fn snapshot(self: *const SnapshotUpdate) *const domain.client_snapshot.ClientSnapshot {
         return &self.snapshot_value;
     }
+
+    /// Broadcast sequence number; null for snapshots not sent by a broadcaster.
+    pub fn seq(self: *const SnapshotUpdate) ?u64 {
+        return self.parsed.value.seq;
+    }
+};
+
+/// Parsed Delta message: only the process summaries that changed since the
+/// snapshot numbered `base_seq`. Apply it with `applyDelta`.
+pub const DeltaUpdate = struct {
+    parsed: std.json.Parsed(DeltaMessage),
+
+    pub fn deinit(self: *DeltaUpdate) void {
+        self.parsed.deinit();
+    }
+
+    pub fn seq(self: *const DeltaUpdate) u64 {
+        return self.parsed.value.seq;
+    }
+
+    pub fn baseSeq(self: *const DeltaUpdate) u64 {
+        return self.parsed.value.base_seq;
+    }
 };
 
 /// Top-level decoded IPC message. Use this when a reader can legally observe
@@ -71,13 +238,35 @@ pub const SnapshotUpdate = struct {
 pub const Message = union(enum) {
     snapshot: SnapshotUpdate,
     command: CommandRequest,
+    stream: StreamRequest,
+    scrollback: ScrollbackRequest,
+    scrollback_data: ScrollbackData,
+    details: DetailsRequest,
+    details_data: DetailsData,
+    env: EnvRequest,
+    env_data: EnvData,
     response: Response,
+    delta: DeltaUpdate,
+    /// Client request for a full snapshot after it detects a sequence gap.
+    resync,
+    /// Heartbeat sequence numbers.
+    ping: u64,
+    pong: u64,
 
     pub fn deinit(self: *Message, allocator: std.mem.Allocator) void {
         switch (self.*) {
             .snapshot => |*snapshot| snapshot.deinit(),
             .command => |request| deinitCommandRequest(allocator, request),
+            .stream => |request| allocator.free(request.target),
+            .scrollback => |request| allocator.free(request.target),
+            .scrollback_data => |data| data.deinit(allocator),
+            .details => |request| allocator.free(request.target),
+            .details_data => |data| data.deinit(allocator),
+            .env => |request| allocator.free(request.target),
+            .env_data => |*data| data.deinit(),
             .response => |*response| response.deinit(allocator),
+            .delta => |*delta| delta.deinit(),
+            .resync, .ping, .pong => {},
         }
     }
 };
@@ -85,7 +274,18 @@ pub const Message = union(enum) {
 const MessageType = enum {
     snapshot,
     command,
+    stream,
+    scrollback,
+    scrollback_data,
+    details,
+    details_data,
+    env,
+    env_data,
     response,
+    delta,
+    resync,
+    ping,
+    pong,
 };
 
 const Header = struct {
@@ -96,9 +296,11 @@ const Header = struct {
 const SnapshotMessage = struct {
     type: []const u8 = "snapshot",
     protocol_version: u32 = current_protocol_version,
+    seq: ?u64 = null,
     current_process_id: u32 = 0,
     exiting: bool = false,
     ui: domain.client_snapshot.UiConfig = .{},
+    startup: domain.client_snapshot.StartupSummary = .{},
     processes: []const domain.client_snapshot.ProcessSummary = &.{},
 
     fn toSnapshot(self: SnapshotMessage) domain.client_snapshot.ClientSnapshot {
@@ -106,17 +308,105 @@ const SnapshotMessage = struct {
             .current_process_id = self.current_process_id,
             .exiting = self.exiting,
             .ui = self.ui,
+            .startup = self.startup,
             .processes = self.processes,
         };
     }
 };
 
+const DeltaMessage = struct {
+    type: []const u8 = "delta",
+    protocol_version: u32 = current_protocol_version,
+    seq: u64,
+    base_seq: u64,
+    current_process_id: u32 = 0,
+    exiting: bool = false,
+    processes: []const domain.client_snapshot.ProcessSummary = &.{},
+};
+
+const ResyncMessage = struct {
+    type: []const u8 = "resync",
+    protocol_version: u32 = current_protocol_version,
+};
+
 const CommandMessage = struct {
     type: []const u8 = "command",
     protocol_version: u32 = current_protocol_version,
     request_id: u64,
     action: []const u8,
     target: ?[]const u8 = null,
+    targets: ?[]const []const u8 = null,
+};
+
+const StreamMessage = struct {
+    type: []const u8 = "stream",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    target: []const u8,
+    lines: ?u32 = null,
+    since_ms: ?i64 = null,
+    stderr: ?bool = null,
+};
+
+const ScrollbackMessage = struct {
+    type: []const u8 = "scrollback",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    target: []const u8,
+    lines: ?u32 = null,
+    bytes: ?u32 = null,
+    since_ms: ?i64 = null,
+    stderr: ?bool = null,
+};
+
+const ScrollbackDataMessage = struct {
+    type: []const u8 = "scrollback_data",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    data: []const u8,
+};
+
+const EnvMessage = struct {
+    type: []const u8 = "env",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    target: []const u8,
+};
+
+pub const EnvDataMessage = struct {
+    type: []const u8 = "env_data",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    variables: []const EnvVariable = &.{},
+};
+
+const DetailsMessage = struct {
+    type: []const u8 = "details",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    target: []const u8,
+};
+
+const DetailsDataMessage = struct {
+    type: []const u8 = "details_data",
+    protocol_version: u32 = current_protocol_version,
+    request_id: u64,
+    command: []const u8 = "",
+    cwd: []const u8 = "",
+};
+
+/// Sent before anything else to a primary that requires `security.token`.
+/// It is not a `Message`: the server reads it before serving the stream.
+const AuthMessage = struct {
+    type: []const u8 = "auth",
+    protocol_version: u32 = current_protocol_version,
+    token: []const u8,
+};
+
+const HeartbeatMessage = struct {
+    type: []const u8,
+    protocol_version: u32 = current_protocol_version,
+    seq: u64,
 };
 
 const ResponseMessage = struct {
@@ -125,6 +415,7 @@ const ResponseMessage = struct {
     request_id: u64,
     success: bool,
     @"error": []const u8 = "",
+    code: ?[]const u8 = null,
 };
 
 pub fn commandName(command: Command) []const u8 {
@@ -135,6 +426,9 @@ pub fn commandName(command: Command) []const u8 {
         .switch_process => "switch",
         .restart_running => "restart_running",
         .stop_running => "stop_running",
+        .jump_to_error => "jump_to_error",
+        .open_url => "open_url",
+        .open_cwd => "open_cwd",
     };
 }
 
@@ -145,19 +439,22 @@ pub fn commandFromName(name: []const u8) CommandNameError!Command {
     if (std.mem.eql(u8, name, "switch")) return .switch_process;
     if (std.mem.eql(u8, name, "restart_running")) return .restart_running;
     if (std.mem.eql(u8, name, "stop_running")) return .stop_running;
+    if (std.mem.eql(u8, name, "jump_to_error")) return .jump_to_error;
+    if (std.mem.eql(u8, name, "open_url")) return .open_url;
+    if (std.mem.eql(u8, name, "open_cwd")) return .open_cwd;
     return error.UnknownCommand;
 }
 
 pub fn commandRequiresTarget(command: Command) bool {
     return switch (command) {
-        .start, .stop, .restart, .switch_process => true,
+        .start, .stop, .restart, .switch_process, .jump_to_error, .open_url, .open_cwd => true,
         .restart_running, .stop_running => false,
     };
 }
 
 pub fn commandRequiresSelectedProcess(command: Command) bool {
     return switch (command) {
-        .start, .stop, .restart => true,
+        .start, .stop, .restart, .jump_to_error, .open_url, .open_cwd => true,
         .switch_process, .restart_running, .stop_running => false,
     };
 }
@@ -167,7 +464,7 @@ pub fn commandRequiresSelectedProcess(command: Command) bool {
 pub fn commandNeedsImmediateSnapshotSync(command: Command) bool {
     return switch (command) {
         .start, .stop, .restart, .restart_running => true,
-        .switch_process, .stop_running => false,
+        .switch_process, .stop_running, .jump_to_error, .open_url, .open_cwd => false,
     };
 }
 
@@ -181,18 +478,44 @@ pub fn decodeLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!Me
     return switch (try messageType(allocator, line)) {
         .snapshot => .{ .snapshot = try parseSnapshotLine(allocator, line) },
         .command => .{ .command = try parseCommandRequestLine(allocator, line) },
+        .stream => .{ .stream = try parseStreamRequestLine(allocator, line) },
+        .scrollback => .{ .scrollback = try parseScrollbackRequestLine(allocator, line) },
+        .scrollback_data => .{ .scrollback_data = try parseScrollbackDataLine(allocator, line) },
+        .details => .{ .details = try parseDetailsRequestLine(allocator, line) },
+        .details_data => .{ .details_data = try parseDetailsDataLine(allocator, line) },
+        .env => .{ .env = try parseEnvRequestLine(allocator, line) },
+        .env_data => .{ .env_data = try parseEnvDataLine(allocator, line) },
         .response => .{ .response = try parseResponseLine(allocator, line) },
+        .delta => .{ .delta = try parseDeltaLine(allocator, line) },
+        .resync => blk: {
+            try parseResyncLine(allocator, line);
+            break :blk .resync;
+        },
+        .ping => .{ .ping = try parseHeartbeatLine(allocator, line, .ping) },
+        .pong => .{ .pong = try parseHeartbeatLine(allocator, line, .pong) },
     };
 }
 
 pub fn snapshotLine(
     allocator: std.mem.Allocator,
     snapshot: *const domain.client_snapshot.ClientSnapshot,
+) EncodeError![]const u8 {
+    return snapshotLineWithSeq(allocator, snapshot, null);
+}
+
+/// Encodes a full snapshot stamped with a broadcast sequence number, which
+/// later deltas name as their base.
+pub fn snapshotLineWithSeq(
+    allocator: std.mem.Allocator,
+    snapshot: *const domain.client_snapshot.ClientSnapshot,
+    seq: ?u64,
 ) EncodeError![]const u8 {
     return jsonLine(allocator, SnapshotMessage{
+        .seq = seq,
         .current_process_id = snapshot.current_process_id,
         .exiting = snapshot.exiting,
         .ui = snapshot.ui,
+        .startup = snapshot.startup,
         .processes = snapshot.processes,
     });
 }
@@ -210,6 +533,90 @@ pub fn parseSnapshotLine(allocator: std.mem.Allocator, line: []const u8) DecodeE
     return .{ .parsed = parsed, .snapshot_value = snapshot_value };
 }
 
+/// Encodes the change from `base` to `next` as a delta carrying only changed
+/// process summaries. Returns null when a delta cannot express the change (UI
+/// config, the startup summary, or the set of processes differs) and a full
+/// snapshot is needed.
+pub fn deltaLine(
+    allocator: std.mem.Allocator,
+    base: *const domain.client_snapshot.ClientSnapshot,
+    next: *const domain.client_snapshot.ClientSnapshot,
+    base_seq: u64,
+    seq: u64,
+) EncodeError!?[]const u8 {
+    if (base.processes.len != next.processes.len) return null;
+    if (!try jsonEqual(allocator, base.ui, next.ui)) return null;
+    if (!try jsonEqual(allocator, base.startup, next.startup)) return null;
+
+    var changed = std.array_list.Managed(domain.client_snapshot.ProcessSummary).init(allocator);
+    defer changed.deinit();
+    for (base.processes, next.processes) |before, after| {
+        if (before.id != after.id) return null;
+        if (!try jsonEqual(allocator, before, after)) try changed.append(after);
+    }
+
+    return try jsonLine(allocator, DeltaMessage{
+        .seq = seq,
+        .base_seq = base_seq,
+        .current_process_id = next.current_process_id,
+        .exiting = next.exiting,
+        .processes = changed.items,
+    });
+}
+
+pub fn parseDeltaLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!DeltaUpdate {
+    try validateHeader(allocator, line, .delta);
+    const parsed = try std.json.parseFromSlice(DeltaMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    errdefer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "delta")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+    return .{ .parsed = parsed };
+}
+
+/// Applies a delta to the snapshot it was built against and returns the
+/// resulting full snapshot line, stamped with the delta's sequence number.
+pub fn applyDelta(
+    allocator: std.mem.Allocator,
+    base: *const domain.client_snapshot.ClientSnapshot,
+    delta: *const DeltaUpdate,
+) (EncodeError || error{InvalidDelta})![]const u8 {
+    const processes = try allocator.dupe(domain.client_snapshot.ProcessSummary, base.processes);
+    defer allocator.free(processes);
+
+    for (delta.parsed.value.processes) |changed| {
+        const index = for (processes, 0..) |summary, i| {
+            if (summary.id == changed.id) break i;
+        } else return error.InvalidDelta;
+        processes[index] = changed;
+    }
+
+    const next = domain.client_snapshot.ClientSnapshot{
+        .current_process_id = delta.parsed.value.current_process_id,
+        .exiting = delta.parsed.value.exiting,
+        .ui = base.ui,
+        .startup = base.startup,
+        .processes = processes,
+    };
+    return snapshotLineWithSeq(allocator, &next, delta.seq());
+}
+
+pub fn resyncLine(allocator: std.mem.Allocator) EncodeError![]const u8 {
+    return jsonLine(allocator, ResyncMessage{});
+}
+
+fn parseResyncLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!void {
+    var parsed = try std.json.parseFromSlice(ResyncMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "resync")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+}
+
 pub fn commandRequestLine(
     allocator: std.mem.Allocator,
     request_id: u64,
@@ -223,6 +630,21 @@ pub fn commandRequestLine(
     });
 }
 
+/// Encodes one command for several processes, e.g. stopping every marked
+/// process in the TUI with a single round trip.
+pub fn batchCommandRequestLine(
+    allocator: std.mem.Allocator,
+    request_id: u64,
+    action: Command,
+    targets: []const []const u8,
+) EncodeError![]const u8 {
+    return jsonLine(allocator, CommandMessage{
+        .request_id = request_id,
+        .action = commandName(action),
+        .targets = targets,
+    });
+}
+
 pub fn parseCommandRequestLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!CommandRequest {
     try validateHeader(allocator, line, .command);
     var parsed = try std.json.parseFromSlice(CommandMessage, allocator, line, .{
@@ -233,21 +655,247 @@ pub fn parseCommandRequestLine(allocator: std.mem.Allocator, line: []const u8) D
     if (!std.mem.eql(u8, parsed.value.type, "command")) return error.InvalidMessageType;
     if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
 
+    const action = try commandFromName(parsed.value.action);
     const target = if (parsed.value.target) |value| try allocator.dupe(u8, value) else null;
     errdefer if (target) |value| allocator.free(value);
+    const targets = try dupeTargets(allocator, parsed.value.targets orelse &.{});
 
     return .{
         .request_id = parsed.value.request_id,
-        .action = try commandFromName(parsed.value.action),
+        .action = action,
         .target = target,
+        .targets = targets,
+    };
+}
+
+fn dupeTargets(allocator: std.mem.Allocator, targets: []const []const u8) ![]const []const u8 {
+    if (targets.len == 0) return &.{};
+    const owned = try allocator.alloc([]const u8, targets.len);
+    var duped: usize = 0;
+    errdefer {
+        for (owned[0..duped]) |target| allocator.free(target);
+        allocator.free(owned);
+    }
+    for (targets) |target| {
+        owned[duped] = try allocator.dupe(u8, target);
+        duped += 1;
+    }
+    return owned;
+}
+
+pub fn streamRequestLine(allocator: std.mem.Allocator, request: StreamRequest) EncodeError![]const u8 {
+    return jsonLine(allocator, StreamMessage{
+        .request_id = request.request_id,
+        .target = request.target,
+        .lines = request.lines,
+        .since_ms = request.since_ms,
+        .stderr = if (request.stderr) true else null,
+    });
+}
+
+pub fn parseStreamRequestLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!StreamRequest {
+    try validateHeader(allocator, line, .stream);
+    var parsed = try std.json.parseFromSlice(StreamMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "stream")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    return .{
+        .request_id = parsed.value.request_id,
+        .target = try allocator.dupe(u8, parsed.value.target),
+        .lines = parsed.value.lines,
+        .since_ms = parsed.value.since_ms,
+        .stderr = parsed.value.stderr orelse false,
+    };
+}
+
+pub fn scrollbackRequestLine(allocator: std.mem.Allocator, request: ScrollbackRequest) EncodeError![]const u8 {
+    return jsonLine(allocator, ScrollbackMessage{
+        .request_id = request.request_id,
+        .target = request.target,
+        .lines = request.lines,
+        .bytes = request.bytes,
+        .since_ms = request.since_ms,
+        .stderr = if (request.stderr) true else null,
+    });
+}
+
+pub fn parseScrollbackRequestLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!ScrollbackRequest {
+    try validateHeader(allocator, line, .scrollback);
+    var parsed = try std.json.parseFromSlice(ScrollbackMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "scrollback")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    return .{
+        .request_id = parsed.value.request_id,
+        .target = try allocator.dupe(u8, parsed.value.target),
+        .lines = parsed.value.lines,
+        .bytes = parsed.value.bytes,
+        .since_ms = parsed.value.since_ms,
+        .stderr = parsed.value.stderr orelse false,
     };
 }
 
+pub fn scrollbackDataLine(allocator: std.mem.Allocator, request_id: u64, data: []const u8) EncodeError![]const u8 {
+    const encoder = std.base64.standard.Encoder;
+    const encoded = try allocator.alloc(u8, encoder.calcSize(data.len));
+    defer allocator.free(encoded);
+    return jsonLine(allocator, ScrollbackDataMessage{
+        .request_id = request_id,
+        .data = encoder.encode(encoded, data),
+    });
+}
+
+pub fn parseScrollbackDataLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!ScrollbackData {
+    try validateHeader(allocator, line, .scrollback_data);
+    var parsed = try std.json.parseFromSlice(ScrollbackDataMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "scrollback_data")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    const decoder = std.base64.standard.Decoder;
+    const data = try allocator.alloc(u8, try decoder.calcSizeForSlice(parsed.value.data));
+    errdefer allocator.free(data);
+    try decoder.decode(data, parsed.value.data);
+    return .{ .request_id = parsed.value.request_id, .data = data };
+}
+
+pub fn envRequestLine(allocator: std.mem.Allocator, request: EnvRequest) EncodeError![]const u8 {
+    return jsonLine(allocator, EnvMessage{
+        .request_id = request.request_id,
+        .target = request.target,
+    });
+}
+
+pub fn parseEnvRequestLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!EnvRequest {
+    try validateHeader(allocator, line, .env);
+    var parsed = try std.json.parseFromSlice(EnvMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "env")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    return .{
+        .request_id = parsed.value.request_id,
+        .target = try allocator.dupe(u8, parsed.value.target),
+    };
+}
+
+pub fn envDataLine(allocator: std.mem.Allocator, request_id: u64, variables: []const EnvVariable) EncodeError![]const u8 {
+    return jsonLine(allocator, EnvDataMessage{
+        .request_id = request_id,
+        .variables = variables,
+    });
+}
+
+pub fn parseEnvDataLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!EnvData {
+    try validateHeader(allocator, line, .env_data);
+    const parsed = try std.json.parseFromSlice(EnvDataMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    errdefer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "env_data")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+    return .{ .parsed = parsed };
+}
+
+pub fn detailsRequestLine(allocator: std.mem.Allocator, request: DetailsRequest) EncodeError![]const u8 {
+    return jsonLine(allocator, DetailsMessage{
+        .request_id = request.request_id,
+        .target = request.target,
+    });
+}
+
+pub fn parseDetailsRequestLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!DetailsRequest {
+    try validateHeader(allocator, line, .details);
+    var parsed = try std.json.parseFromSlice(DetailsMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "details")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    return .{
+        .request_id = parsed.value.request_id,
+        .target = try allocator.dupe(u8, parsed.value.target),
+    };
+}
+
+pub fn detailsDataLine(allocator: std.mem.Allocator, request_id: u64, command: []const u8, cwd: []const u8) EncodeError![]const u8 {
+    return jsonLine(allocator, DetailsDataMessage{
+        .request_id = request_id,
+        .command = command,
+        .cwd = cwd,
+    });
+}
+
+pub fn parseDetailsDataLine(allocator: std.mem.Allocator, line: []const u8) DecodeError!DetailsData {
+    try validateHeader(allocator, line, .details_data);
+    var parsed = try std.json.parseFromSlice(DetailsDataMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "details_data")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+
+    const command = try allocator.dupe(u8, parsed.value.command);
+    errdefer allocator.free(command);
+    return .{
+        .request_id = parsed.value.request_id,
+        .command = command,
+        .cwd = try allocator.dupe(u8, parsed.value.cwd),
+    };
+}
+
+/// Selects the part of `bytes` a scrollback fetch returns: the last `lines`
+/// lines when given, then at most `byte_limit` bytes. The byte limit defaults
+/// to `default_scrollback_bytes`, or to the maximum when lines were requested,
+/// and is always clamped to `max_scrollback_bytes`.
+pub fn scrollbackTail(bytes: []const u8, lines: ?u32, byte_limit: ?u32) []const u8 {
+    var tail = bytes;
+    if (lines) |count| tail = lastLines(tail, count);
+    const default_limit = if (lines == null) default_scrollback_bytes else max_scrollback_bytes;
+    const limit = @min(byte_limit orelse default_limit, max_scrollback_bytes);
+    if (tail.len > limit) tail = tail[tail.len - limit ..];
+    return tail;
+}
+
+/// Returns the last `count` lines of `bytes`, or all of it when it has fewer.
+pub fn lastLines(bytes: []const u8, count: u32) []const u8 {
+    if (count == 0) return bytes[bytes.len..];
+    // A trailing newline ends the last line rather than starting an empty one.
+    const end = if (bytes.len > 0 and bytes[bytes.len - 1] == '\n') bytes.len - 1 else bytes.len;
+    var seen: u32 = 0;
+    var index = end;
+    while (index > 0) : (index -= 1) {
+        if (bytes[index - 1] != '\n') continue;
+        seen += 1;
+        if (seen == count) return bytes[index..];
+    }
+    return bytes;
+}
+
 pub fn responseLine(allocator: std.mem.Allocator, response: Response) EncodeError![]const u8 {
     return jsonLine(allocator, ResponseMessage{
         .request_id = response.request_id,
         .success = response.success,
         .@"error" = response.error_message,
+        .code = if (response.code == .none) null else @tagName(response.code),
     });
 }
 
@@ -265,11 +913,70 @@ pub fn parseResponseLine(allocator: std.mem.Allocator, line: []const u8) DecodeE
         .request_id = parsed.value.request_id,
         .success = parsed.value.success,
         .error_message = try allocator.dupe(u8, parsed.value.@"error"),
+        // A code from a newer server is still a failure.
+        .code = if (parsed.value.code) |name| std.meta.stringToEnum(ErrorCode, name) orelse .command_failed else .none,
     };
 }
 
+/// The code for a request line that failed to read or decode.
+pub fn errorCodeFor(err: anyerror) ErrorCode {
+    return switch (err) {
+        error.UnsupportedProtocolVersion => .unsupported_version,
+        error.LineTooLong => .message_too_large,
+        else => .invalid_request,
+    };
+}
+
+/// Best-effort `request_id` of a line that failed to decode, so the failure
+/// reaches whoever is waiting for it; 0 when there is none to find.
+pub fn requestIdOf(allocator: std.mem.Allocator, line: []const u8) u64 {
+    const parsed = std.json.parseFromSlice(struct { request_id: u64 = 0 }, allocator, line, .{
+        .ignore_unknown_fields = true,
+    }) catch return 0;
+    defer parsed.deinit();
+    return parsed.value.request_id;
+}
+
+pub fn authLine(allocator: std.mem.Allocator, token: []const u8) EncodeError![]const u8 {
+    return jsonLine(allocator, AuthMessage{ .token = token });
+}
+
+/// Returns the token an `auth` line carries. The caller owns the result.
+pub fn parseAuthLine(allocator: std.mem.Allocator, line: []const u8) DecodeError![]const u8 {
+    var parsed = try std.json.parseFromSlice(AuthMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, "auth")) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+    return allocator.dupe(u8, parsed.value.token);
+}
+
+pub fn pingLine(allocator: std.mem.Allocator, seq: u64) EncodeError![]const u8 {
+    return jsonLine(allocator, HeartbeatMessage{ .type = "ping", .seq = seq });
+}
+
+pub fn pongLine(allocator: std.mem.Allocator, seq: u64) EncodeError![]const u8 {
+    return jsonLine(allocator, HeartbeatMessage{ .type = "pong", .seq = seq });
+}
+
+fn parseHeartbeatLine(allocator: std.mem.Allocator, line: []const u8, expected_type: MessageType) DecodeError!u64 {
+    var parsed = try std.json.parseFromSlice(HeartbeatMessage, allocator, line, .{
+        .allocate = .alloc_always,
+        .ignore_unknown_fields = false,
+    });
+    defer parsed.deinit();
+    if (!std.mem.eql(u8, parsed.value.type, @tagName(expected_type))) return error.InvalidMessageType;
+    if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
+    return parsed.value.seq;
+}
+
 pub fn deinitCommandRequest(allocator: std.mem.Allocator, request: CommandRequest) void {
     if (request.target) |target| allocator.free(target);
+    if (request.targets.len == 0) return;
+    for (request.targets) |target| allocator.free(target);
+    allocator.free(request.targets);
 }
 
 fn jsonLine(allocator: std.mem.Allocator, value: anytype) EncodeError![]const u8 {
@@ -279,6 +986,16 @@ fn jsonLine(allocator: std.mem.Allocator, value: anytype) EncodeError![]const u8
     return out.toOwnedSlice();
 }
 
+fn jsonEqual(allocator: std.mem.Allocator, a: anytype, b: @TypeOf(a)) EncodeError!bool {
+    var a_json = std.array_list.Managed(u8).init(allocator);
+    defer a_json.deinit();
+    var b_json = std.array_list.Managed(u8).init(allocator);
+    defer b_json.deinit();
+    try a_json.writer().print("{f}", .{std.json.fmt(a, .{})});
+    try b_json.writer().print("{f}", .{std.json.fmt(b, .{})});
+    return std.mem.eql(u8, a_json.items, b_json.items);
+}
+
 fn messageType(allocator: std.mem.Allocator, line: []const u8) DecodeError!MessageType {
     var parsed = try std.json.parseFromSlice(Header, allocator, line, .{
         .allocate = .alloc_always,
@@ -288,7 +1005,18 @@ fn messageType(allocator: std.mem.Allocator, line: []const u8) DecodeError!Messa
     if (parsed.value.protocol_version != current_protocol_version) return error.UnsupportedProtocolVersion;
     if (std.mem.eql(u8, parsed.value.type, "snapshot")) return .snapshot;
     if (std.mem.eql(u8, parsed.value.type, "command")) return .command;
+    if (std.mem.eql(u8, parsed.value.type, "stream")) return .stream;
+    if (std.mem.eql(u8, parsed.value.type, "scrollback")) return .scrollback;
+    if (std.mem.eql(u8, parsed.value.type, "scrollback_data")) return .scrollback_data;
+    if (std.mem.eql(u8, parsed.value.type, "details")) return .details;
+    if (std.mem.eql(u8, parsed.value.type, "details_data")) return .details_data;
+    if (std.mem.eql(u8, parsed.value.type, "env")) return .env;
+    if (std.mem.eql(u8, parsed.value.type, "env_data")) return .env_data;
     if (std.mem.eql(u8, parsed.value.type, "response")) return .response;
+    if (std.mem.eql(u8, parsed.value.type, "delta")) return .delta;
+    if (std.mem.eql(u8, parsed.value.type, "resync")) return .resync;
+    if (std.mem.eql(u8, parsed.value.type, "ping")) return .ping;
+    if (std.mem.eql(u8, parsed.value.type, "pong")) return .pong;
     return error.InvalidMessageType;
 }
 
@@ -340,6 +1068,7 @@ test "protocol snapshot excludes process execution and log fields" {
         "\"env\"",
         "\"shell\"",
         "\"cmd\"",
+        "\"command\"",
         "\"cwd\"",
         "\"on_kill\"",
         "\"log_file\"",
@@ -358,6 +1087,7 @@ test "protocol owns process command semantics" {
     try std.testing.expect(commandRequiresSelectedProcess(.start));
     try std.testing.expect(!commandRequiresSelectedProcess(.switch_process));
     try std.testing.expect(!commandRequiresSelectedProcess(.stop_running));
+    try std.testing.expect(commandRequiresSelectedProcess(.open_url));
 
     try std.testing.expect(commandNeedsImmediateSnapshotSync(.restart));
     try std.testing.expect(!commandNeedsImmediateSnapshotSync(.switch_process));
@@ -381,6 +1111,23 @@ test "protocol encodes and decodes command requests" {
     try std.testing.expectEqualStrings("api", parsed.target.?);
 }
 
+test "protocol encodes and decodes batch command requests" {
+    const line = try batchCommandRequestLine(std.testing.allocator, 5, .stop, &.{ "api", "db" });
+    defer std.testing.allocator.free(line);
+
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"command\",\"protocol_version\":1,\"request_id\":5,\"action\":\"stop\",\"targets\":[\"api\",\"db\"]}\n",
+        line,
+    );
+
+    const parsed = try parseCommandRequestLine(std.testing.allocator, line);
+    defer deinitCommandRequest(std.testing.allocator, parsed);
+    try std.testing.expect(parsed.isBatch());
+    try std.testing.expect(parsed.target == null);
+    try std.testing.expectEqual(@as(usize, 2), parsed.targets.len);
+    try std.testing.expectEqualStrings("db", parsed.targets[1]);
+}
+
 test "protocol encodes targetless commands without null target" {
     const line = try commandRequestLine(std.testing.allocator, 7, .stop_running, null);
     defer std.testing.allocator.free(line);
@@ -396,8 +1143,10 @@ test "protocol encodes and decodes responses" {
         .request_id = 99,
         .success = false,
         .error_message = "process not found: api",
+        .code = .not_found,
     });
     defer std.testing.allocator.free(line);
+    try std.testing.expect(std.mem.endsWith(u8, line, "\"code\":\"not_found\"}\n"));
 
     var parsed = try parseResponseLine(std.testing.allocator, line);
     defer parsed.deinit(std.testing.allocator);
@@ -405,6 +1154,25 @@ test "protocol encodes and decodes responses" {
     try std.testing.expectEqual(@as(u64, 99), parsed.request_id);
     try std.testing.expect(!parsed.success);
     try std.testing.expectEqualStrings("process not found: api", parsed.error_message);
+    try std.testing.expectEqual(ErrorCode.not_found, parsed.code);
+
+    const ok = try responseLine(std.testing.allocator, .{ .request_id = 1, .success = true, .error_message = "" });
+    defer std.testing.allocator.free(ok);
+    try std.testing.expect(std.mem.indexOf(u8, ok, "code") == null);
+}
+
+test "protocol recovers request ids and codes for lines that fail to decode" {
+    const unknown_field = "{\"type\":\"command\",\"protocol_version\":1,\"request_id\":7,\"action\":\"start\",\"extra\":true}\n";
+    const err = if (decodeLine(std.testing.allocator, unknown_field)) |message| {
+        var owned = message;
+        owned.deinit(std.testing.allocator);
+        return error.TestUnexpectedResult;
+    } else |err| err;
+    try std.testing.expectEqual(ErrorCode.invalid_request, errorCodeFor(err));
+    try std.testing.expectEqual(@as(u64, 7), requestIdOf(std.testing.allocator, unknown_field));
+    try std.testing.expectEqual(@as(u64, 0), requestIdOf(std.testing.allocator, "not json"));
+    try std.testing.expectEqual(ErrorCode.unsupported_version, errorCodeFor(error.UnsupportedProtocolVersion));
+    try std.testing.expectEqual(ErrorCode.message_too_large, errorCodeFor(error.LineTooLong));
 }
 
 test "protocol decodes any message through one interface" {
@@ -424,6 +1192,215 @@ test "protocol decodes any message through one interface" {
     }
 }
 
+test "protocol deltas carry only changed processes and apply onto their base" {
+    const base = domain.client_snapshot.ClientSnapshot{
+        .current_process_id = 1,
+        .processes = &.{
+            .{ .id = 1, .label = "api", .status = .running, .pid = 100 },
+            .{ .id = 2, .label = "worker", .status = .halted },
+        },
+    };
+    const next = domain.client_snapshot.ClientSnapshot{
+        .current_process_id = 2,
+        .processes = &.{
+            .{ .id = 1, .label = "api", .status = .running, .pid = 100 },
+            .{ .id = 2, .label = "worker", .status = .running, .pid = 200 },
+        },
+    };
+
+    const line = (try deltaLine(std.testing.allocator, &base, &next, 4, 5)).?;
+    defer std.testing.allocator.free(line);
+    try std.testing.expect(std.mem.indexOf(u8, line, "\"api\"") == null);
+
+    var delta = try parseDeltaLine(std.testing.allocator, line);
+    defer delta.deinit();
+    try std.testing.expectEqual(@as(u64, 4), delta.baseSeq());
+
+    const applied = try applyDelta(std.testing.allocator, &base, &delta);
+    defer std.testing.allocator.free(applied);
+    var update = try parseSnapshotLine(std.testing.allocator, applied);
+    defer update.deinit();
+
+    try std.testing.expectEqual(@as(?u64, 5), update.seq());
+    try std.testing.expectEqual(@as(u32, 2), update.snapshot().current_process_id);
+    try std.testing.expectEqualStrings("api", update.snapshot().processes[0].label);
+    try std.testing.expectEqual(@as(i32, 200), update.snapshot().processes[1].pid);
+}
+
+test "protocol falls back to full snapshots when a delta cannot express the change" {
+    const base = domain.client_snapshot.ClientSnapshot{
+        .processes = &.{.{ .id = 1, .label = "api" }},
+    };
+    const restyled = domain.client_snapshot.ClientSnapshot{
+        .ui = .{ .style = .{ .pointer_char = "*" } },
+        .processes = &.{.{ .id = 1, .label = "api" }},
+    };
+    const grown = domain.client_snapshot.ClientSnapshot{
+        .processes = &.{ .{ .id = 1, .label = "api" }, .{ .id = 2, .label = "worker" } },
+    };
+    const settled = domain.client_snapshot.ClientSnapshot{
+        .startup = .{ .settled_ms = 1_000, .started = &.{"api"} },
+        .processes = &.{.{ .id = 1, .label = "api" }},
+    };
+
+    try std.testing.expectEqual(@as(?[]const u8, null), try deltaLine(std.testing.allocator, &base, &restyled, 1, 2));
+    try std.testing.expectEqual(@as(?[]const u8, null), try deltaLine(std.testing.allocator, &base, &grown, 1, 2));
+    try std.testing.expectEqual(@as(?[]const u8, null), try deltaLine(std.testing.allocator, &base, &settled, 1, 2));
+}
+
+test "protocol encodes and decodes heartbeat messages" {
+    const ping = try pingLine(std.testing.allocator, 3);
+    defer std.testing.allocator.free(ping);
+    try std.testing.expectEqualStrings("{\"type\":\"ping\",\"protocol_version\":1,\"seq\":3}\n", ping);
+
+    const pong = try pongLine(std.testing.allocator, 3);
+    defer std.testing.allocator.free(pong);
+
+    var ping_message = try decodeLine(std.testing.allocator, ping);
+    defer ping_message.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 3), ping_message.ping);
+
+    var pong_message = try decodeLine(std.testing.allocator, pong);
+    defer pong_message.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 3), pong_message.pong);
+}
+
+test "protocol round trips auth lines" {
+    const line = try authLine(std.testing.allocator, "abc123");
+    defer std.testing.allocator.free(line);
+    try std.testing.expectEqualStrings("{\"type\":\"auth\",\"protocol_version\":1,\"token\":\"abc123\"}\n", line);
+
+    const token = try parseAuthLine(std.testing.allocator, line);
+    defer std.testing.allocator.free(token);
+    try std.testing.expectEqualStrings("abc123", token);
+    try std.testing.expectError(error.InvalidMessageType, parseAuthLine(std.testing.allocator, "{\"type\":\"ping\",\"protocol_version\":1,\"token\":\"x\"}\n"));
+}
+
+test "protocol encodes scrollback requests and round trips raw output as base64" {
+    const request_line = try scrollbackRequestLine(std.testing.allocator, .{ .request_id = 4, .target = "api", .lines = 20 });
+    defer std.testing.allocator.free(request_line);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"scrollback\",\"protocol_version\":1,\"request_id\":4,\"target\":\"api\",\"lines\":20}\n",
+        request_line,
+    );
+
+    var request = try decodeLine(std.testing.allocator, request_line);
+    defer request.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("api", request.scrollback.target);
+    try std.testing.expectEqual(@as(?u32, 20), request.scrollback.lines);
+    try std.testing.expectEqual(@as(?u32, null), request.scrollback.bytes);
+
+    const raw = "ok\r\n\x1b[32mgreen\x1b[0m \xff\n";
+    const data_line = try scrollbackDataLine(std.testing.allocator, 4, raw);
+    defer std.testing.allocator.free(data_line);
+
+    var reply = try decodeLine(std.testing.allocator, data_line);
+    defer reply.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 4), reply.scrollback_data.request_id);
+    try std.testing.expectEqualStrings(raw, reply.scrollback_data.data);
+}
+
+test "protocol round trips environment requests and variables" {
+    const request_line = try envRequestLine(std.testing.allocator, .{ .request_id = 5, .target = "api" });
+    defer std.testing.allocator.free(request_line);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"env\",\"protocol_version\":1,\"request_id\":5,\"target\":\"api\"}\n",
+        request_line,
+    );
+
+    var request = try decodeLine(std.testing.allocator, request_line);
+    defer request.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("api", request.env.target);
+
+    const data_line = try envDataLine(std.testing.allocator, 5, &.{
+        .{ .name = "HOME", .value = "/custom/home", .source = "config", .overridden = "/home/nick" },
+        .{ .name = "SHELL", .value = "/bin/zsh", .source = "inherited" },
+    });
+    defer std.testing.allocator.free(data_line);
+
+    var reply = try decodeLine(std.testing.allocator, data_line);
+    defer reply.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 5), reply.env_data.requestId());
+    const variables = reply.env_data.variables();
+    try std.testing.expectEqual(@as(usize, 2), variables.len);
+    try std.testing.expectEqualStrings("/home/nick", variables[0].overridden.?);
+    try std.testing.expectEqualStrings("inherited", variables[1].source);
+    try std.testing.expect(variables[1].overridden == null);
+}
+
+test "protocol round trips process detail requests" {
+    const request_line = try detailsRequestLine(std.testing.allocator, .{ .request_id = 6, .target = "api" });
+    defer std.testing.allocator.free(request_line);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"details\",\"protocol_version\":1,\"request_id\":6,\"target\":\"api\"}\n",
+        request_line,
+    );
+
+    var request = try decodeLine(std.testing.allocator, request_line);
+    defer request.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("api", request.details.target);
+
+    const data_line = try detailsDataLine(std.testing.allocator, 6, "go run ./cmd/api", "/srv/api");
+    defer std.testing.allocator.free(data_line);
+
+    var reply = try decodeLine(std.testing.allocator, data_line);
+    defer reply.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 6), reply.details_data.request_id);
+    try std.testing.expectEqualStrings("go run ./cmd/api", reply.details_data.command);
+    try std.testing.expectEqualStrings("/srv/api", reply.details_data.cwd);
+}
+
+test "protocol scrollback tail keeps the last lines within the byte limit" {
+    const bytes = "one\ntwo\nthree\n";
+    try std.testing.expectEqualStrings("two\nthree\n", scrollbackTail(bytes, 2, null));
+    try std.testing.expectEqualStrings(bytes, scrollbackTail(bytes, 10, null));
+    try std.testing.expectEqualStrings("", scrollbackTail(bytes, 0, null));
+    try std.testing.expectEqualStrings("three", scrollbackTail("one\ntwo\nthree", 1, null));
+    try std.testing.expectEqualStrings("ee\n", scrollbackTail(bytes, 2, 3));
+    try std.testing.expectEqualStrings(bytes, scrollbackTail(bytes, null, null));
+}
+
+test "protocol encodes and decodes output stream requests" {
+    const line = try streamRequestLine(std.testing.allocator, .{ .request_id = 5, .target = "api" });
+    defer std.testing.allocator.free(line);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"stream\",\"protocol_version\":1,\"request_id\":5,\"target\":\"api\"}\n",
+        line,
+    );
+
+    var message = try decodeLine(std.testing.allocator, line);
+    defer message.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(u64, 5), message.stream.request_id);
+    try std.testing.expectEqualStrings("api", message.stream.target);
+
+    const limited = try streamRequestLine(std.testing.allocator, .{
+        .request_id = 6,
+        .target = "api",
+        .lines = 10,
+        .since_ms = 1_700_000_000_000,
+    });
+    defer std.testing.allocator.free(limited);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"stream\",\"protocol_version\":1,\"request_id\":6,\"target\":\"api\",\"lines\":10,\"since_ms\":1700000000000}\n",
+        limited,
+    );
+    const request = try parseStreamRequestLine(std.testing.allocator, limited);
+    defer std.testing.allocator.free(request.target);
+    try std.testing.expectEqual(@as(?u32, 10), request.lines);
+    try std.testing.expectEqual(@as(?i64, 1_700_000_000_000), request.since_ms);
+    try std.testing.expect(!request.stderr);
+
+    const errors_only = try streamRequestLine(std.testing.allocator, .{ .request_id = 7, .target = "api", .stderr = true });
+    defer std.testing.allocator.free(errors_only);
+    try std.testing.expectEqualStrings(
+        "{\"type\":\"stream\",\"protocol_version\":1,\"request_id\":7,\"target\":\"api\",\"stderr\":true}\n",
+        errors_only,
+    );
+    const stderr_request = try parseStreamRequestLine(std.testing.allocator, errors_only);
+    defer std.testing.allocator.free(stderr_request.target);
+    try std.testing.expect(stderr_request.stderr);
+}
+
 test "protocol rejects unsupported protocol versions unknown actions and unknown message types" {
     try std.testing.expectError(
         error.UnsupportedProtocolVersion,
@@ -450,3 +1427,53 @@ test "protocol rejects unsupported protocol versions unknown actions and unknown
         ),
     );
 }
+
+/// Lines a peer can produce by accident or on purpose: valid messages of each
+/// direction, then the same cut short, run together, or replaced by noise.
+const fuzz_corpus = [_][]const u8{
+    "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":2,\"exiting\":false,\"ui\":{},\"processes\":[{\"id\":2,\"label\":\"api\",\"status\":\"running\",\"pid\":1002,\"description\":\"\",\"docs\":\"\",\"categories\":[]}]}\n",
+    "{\"type\":\"command\",\"protocol_version\":1,\"request_id\":4,\"action\":\"start\",\"target\":\"api\"}\n",
+    "{\"type\":\"response\",\"protocol_version\":1,\"request_id\":4,\"success\":false,\"error\":\"nope\",\"code\":\"invalid_request\"}\n",
+    "{\"type\":\"ping\",\"protocol_version\":1,\"seq\":3}\n",
+    "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":2,\"processes\":[{\"id\":2,",
+    "{\"type\":\"ping\",\"protocol_version\":1,\"seq\":3}{\"type\":\"pong\",\"protocol_version\":1,\"seq\":3}",
+    "{\"type\":\"command\",\"protocol_version\":1,\"request_id\":4,\"act{\"type\":\"ping\",\"protocol_version\":1,\"seq\":1}",
+    "{\"type\":\"scrollback_data\",\"protocol_version\":1,\"request_id\":1,\"data\":\"not base64!\"}",
+    "{\"type\":\"delta\",\"protocol_version\":1,\"base_seq\":18446744073709551615,\"seq\":0}",
+    "{\"type\":null,\"protocol_version\":-1}",
+    "[1,2,3]",
+    "\x00\xff\x1b[?u",
+    "",
+};
+
+fn decodeOneLine(_: void, line: []const u8) anyerror!void {
+    _ = requestIdOf(std.testing.allocator, line);
+    var message = decodeLine(std.testing.allocator, line) catch return;
+    message.deinit(std.testing.allocator);
+}
+
+fn expectRejected(line: []const u8) !void {
+    var message = decodeLine(std.testing.allocator, line) catch return;
+    message.deinit(std.testing.allocator);
+    return error.MalformedLineDecoded;
+}
+
+test "protocol decodes arbitrary lines without crashing or leaking" {
+    try std.testing.fuzz({}, decodeOneLine, .{ .corpus = &fuzz_corpus });
+}
+
+test "protocol rejects every truncation of a valid line without leaking" {
+    for (fuzz_corpus[0..4]) |line| {
+        const body = std.mem.trimRight(u8, line, "\n");
+        var message = try decodeLine(std.testing.allocator, body);
+        message.deinit(std.testing.allocator);
+
+        for (0..body.len) |len| try expectRejected(body[0..len]);
+    }
+}
+
+test "protocol rejects messages run together on one line" {
+    try expectRejected(fuzz_corpus[5]);
+    try expectRejected(fuzz_corpus[6]);
+    try std.testing.expectEqual(@as(u64, 0), requestIdOf(std.testing.allocator, fuzz_corpus[6]));
+}
@@ -1,9 +1,10 @@
 //! IPC namespace.
-//! Runtime modules import this root to access protocol, socket, client, server, and testable IPC interfaces through one stable seam.
+//! Runtime modules import this root to access protocol, framing, socket, client, server, and testable IPC interfaces through one stable seam.
 
 pub const protocol = @import("protocol.zig");
 pub const interfaces = @import("interfaces.zig");
 pub const line = @import("line.zig");
+pub const frame = @import("frame.zig");
 pub const socket = @import("socket.zig");
 pub const client = @import("client.zig");
 pub const server = @import("server.zig");
@@ -13,6 +14,7 @@ test {
     _ = protocol;
     _ = interfaces;
     _ = line;
+    _ = frame;
     _ = socket;
     _ = client;
     _ = server;
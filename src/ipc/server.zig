@@ -3,17 +3,27 @@
 
 const std = @import("std");
 const builtin = @import("builtin");
+const domain = @import("../domain/root.zig");
 const interfaces = @import("interfaces.zig");
 const line_io = @import("line.zig");
 const protocol = @import("protocol.zig");
 const snapshot_broadcaster = @import("snapshot_broadcaster.zig");
 
-const max_request_line = 1024 * 1024;
+const log = std.log.scoped(.ipc);
+
+/// Longest `auth` line a connecting client may send.
+const max_auth_line = 1024;
+/// How long a new connection has to present its token before it is dropped.
+const auth_timeout_ms = 2000;
 var peer_credential_warning_logged = std.atomic.Value(bool).init(false);
 
 pub const CommandHandler = interfaces.CommandHandler;
 pub const SnapshotProvider = interfaces.SnapshotProvider;
+pub const OutputProvider = interfaces.OutputProvider;
+pub const DetailsProvider = interfaces.DetailsProvider;
+pub const EnvProvider = interfaces.EnvProvider;
 pub const PeerAuthorizer = interfaces.PeerAuthorizer;
+pub const PollIntervals = snapshot_broadcaster.PollIntervals;
 
 const DefaultPeerAuthorizerContext = struct {};
 var default_peer_authorizer_context = DefaultPeerAuthorizerContext{};
@@ -27,6 +37,18 @@ pub fn defaultPeerAuthorizer() PeerAuthorizer {
     };
 }
 
+/// Requires each connection to pass `inner` and then send an `auth` line
+/// carrying `token`, for primaries started with `security.token`. Only the
+/// `inner` check runs on the accept loop; the `auth` line is read as a handshake.
+pub const TokenAuthorizer = struct {
+    token: []const u8,
+    inner: PeerAuthorizer,
+
+    pub fn authorizer(self: *TokenAuthorizer) PeerAuthorizer {
+        return .{ .context = self, .authorize = authorizeTokenPeer, .handshake = checkToken };
+    }
+};
+
 pub fn serveOneCommandAtPath(
     allocator: std.mem.Allocator,
     socket_path: []const u8,
@@ -59,6 +81,38 @@ pub fn serveCommandsAtPathWithSnapshots(
     } }, null);
 }
 
+/// Like `serveCommandsAtPathWithSnapshots`, but also serves output stream
+/// requests from `output_provider`, detail requests from `details_provider`,
+/// and environment requests from `env_provider`, keeps `client_gauge` equal
+/// to the number of connected clients, and republishes snapshots when
+/// `changes` fires instead of polling for them. A null `authorizer` uses the
+/// default one.
+pub fn serveCommandsAtPathWithSnapshotsAndOutput(
+    allocator: std.mem.Allocator,
+    socket_path: []const u8,
+    handler: CommandHandler,
+    snapshot_provider: SnapshotProvider,
+    output_provider: OutputProvider,
+    details_provider: DetailsProvider,
+    env_provider: EnvProvider,
+    stopped: *std.atomic.Value(bool),
+    client_gauge: *std.atomic.Value(u32),
+    changes: *domain.changes.Signal,
+    intervals: PollIntervals,
+    authorizer: ?PeerAuthorizer,
+) !void {
+    try serveAtPath(allocator, socket_path, handler, .{ .snapshot_loop = .{
+        .provider = snapshot_provider,
+        .output_provider = output_provider,
+        .details_provider = details_provider,
+        .env_provider = env_provider,
+        .stopped = stopped,
+        .client_gauge = client_gauge,
+        .changes = changes,
+        .intervals = intervals,
+    } }, authorizer);
+}
+
 pub fn serveCommandsAtPathWithSnapshotsAndAuthorizer(
     allocator: std.mem.Allocator,
     socket_path: []const u8,
@@ -80,7 +134,13 @@ const ServeMode = union(enum) {
 
 const SnapshotLoop = struct {
     provider: SnapshotProvider,
+    output_provider: ?OutputProvider = null,
+    details_provider: ?DetailsProvider = null,
+    env_provider: ?EnvProvider = null,
     stopped: *std.atomic.Value(bool),
+    client_gauge: ?*std.atomic.Value(u32) = null,
+    changes: ?*domain.changes.Signal = null,
+    intervals: PollIntervals = .{},
 };
 
 fn serveAtPath(
@@ -97,8 +157,7 @@ fn serveAtPath(
             allocator,
             socket_path,
             handler,
-            snapshot_loop.provider,
-            snapshot_loop.stopped,
+            snapshot_loop,
             authorizer,
         ),
         .one_command => try serveOneCommandListener(allocator, socket_path, handler, authorizer),
@@ -119,6 +178,10 @@ fn serveOneCommandListener(
         conn.stream.close();
         return err;
     };
+    authorizer.handshakeStream(conn.stream) catch |err| {
+        conn.stream.close();
+        return err;
+    };
     try serveCommandConnection(allocator, conn.stream, handler);
 }
 
@@ -126,19 +189,26 @@ fn serveSnapshotListener(
     allocator: std.mem.Allocator,
     socket_path: []const u8,
     handler: CommandHandler,
-    snapshot_provider: SnapshotProvider,
-    stopped: *std.atomic.Value(bool),
+    snapshot_loop: SnapshotLoop,
     authorizer: PeerAuthorizer,
 ) !void {
+    const stopped = snapshot_loop.stopped;
     var listener = try listenAtSocketPath(socket_path);
     defer listener.deinit();
 
     var broadcaster = snapshot_broadcaster.Broadcaster.init(
         allocator,
         handler,
-        snapshot_provider,
+        snapshot_loop.provider,
         stopped,
     );
+    broadcaster.client_gauge = snapshot_loop.client_gauge;
+    broadcaster.output_provider = snapshot_loop.output_provider;
+    broadcaster.details_provider = snapshot_loop.details_provider;
+    broadcaster.env_provider = snapshot_loop.env_provider;
+    broadcaster.intervals = snapshot_loop.intervals;
+    broadcaster.changes = snapshot_loop.changes;
+    broadcaster.authorizer = authorizer;
     defer broadcaster.deinit();
     try broadcaster.start();
 
@@ -157,8 +227,8 @@ fn serveSnapshotListener(
             continue;
         };
 
-        // After authorization the broadcaster owns the stream; this keeps
-        // connection lifetime separate from socket accept/permission concerns.
+        // After the peer check the broadcaster owns the stream and runs any
+        // handshake on the client's worker, so a slow client cannot stall accepts.
         try broadcaster.addClient(conn.stream);
     }
 }
@@ -183,10 +253,16 @@ fn serveCommandConnection(
 ) !void {
     defer stream.close();
 
-    const request_line = try line_io.read(allocator, stream, max_request_line);
+    const request_line = line_io.read(allocator, stream, protocol.max_request_line) catch |err| switch (err) {
+        error.LineTooLong => return writeRequestFailure(allocator, stream, 0, err),
+        else => return err,
+    };
     defer allocator.free(request_line);
 
-    const request = try protocol.parseCommandRequestLine(allocator, request_line);
+    const request = protocol.parseCommandRequestLine(allocator, request_line) catch |err| switch (err) {
+        error.OutOfMemory => return err,
+        else => return writeRequestFailure(allocator, stream, protocol.requestIdOf(allocator, request_line), err),
+    };
     defer protocol.deinitCommandRequest(allocator, request);
 
     var response = try handler.handleCommand(allocator, request);
@@ -197,11 +273,31 @@ fn serveCommandConnection(
     try stream.writeAll(line);
 }
 
+/// Answers a request that could not be read or decoded, so the client gets a
+/// failure with a code instead of a closed socket.
+fn writeRequestFailure(allocator: std.mem.Allocator, stream: std.net.Stream, request_id: u64, err: anyerror) !void {
+    log.warn("rejecting IPC command request: {s}", .{@errorName(err)});
+    const text = if (err == error.LineTooLong)
+        try allocator.dupe(u8, "request line too long")
+    else
+        try std.fmt.allocPrint(allocator, "invalid request: {s}", .{@errorName(err)});
+    defer allocator.free(text);
+
+    const line = try protocol.responseLine(allocator, .{
+        .request_id = request_id,
+        .success = false,
+        .error_message = text,
+        .code = protocol.errorCodeFor(err),
+    });
+    defer allocator.free(line);
+    stream.writeAll(line) catch {};
+}
+
 fn authorizeDefaultPeer(_: *anyopaque, fd: std.posix.fd_t) !void {
     const peer_uid = peerUID(fd) catch |err| switch (err) {
         error.PeerCredentialUnsupported => {
             if (!peer_credential_warning_logged.swap(true, .seq_cst)) {
-                std.log.warn("Peer credential checks not supported on this platform; relying on socket permissions only", .{});
+                log.warn("Peer credential checks not supported on this platform; relying on socket permissions only", .{});
             }
             return;
         },
@@ -212,6 +308,35 @@ fn authorizeDefaultPeer(_: *anyopaque, fd: std.posix.fd_t) !void {
     if (peer_uid != expected_uid) return error.UnauthorizedPeer;
 }
 
+fn authorizeTokenPeer(context: *anyopaque, fd: std.posix.fd_t) !void {
+    const self: *TokenAuthorizer = @ptrCast(@alignCast(context));
+    try self.inner.authorize(self.inner.context, fd);
+}
+
+fn checkToken(context: *anyopaque, fd: std.posix.fd_t) !void {
+    const self: *TokenAuthorizer = @ptrCast(@alignCast(context));
+
+    // Reading byte by byte leaves whatever follows the line on the socket.
+    var buffer: [max_auth_line * 4]u8 = undefined;
+    var fixed = std.heap.FixedBufferAllocator.init(&buffer);
+    const stream = std.net.Stream{ .handle = fd };
+    const line = line_io.readTimeout(fixed.allocator(), stream, max_auth_line, auth_timeout_ms) catch return error.UnauthorizedPeer;
+    const token = protocol.parseAuthLine(fixed.allocator(), line) catch return error.UnauthorizedPeer;
+    if (!tokensEqual(token, self.token)) {
+        log.warn("rejected an IPC connection with a wrong token", .{});
+        return error.UnauthorizedPeer;
+    }
+}
+
+/// Compares without stopping at the first difference, so response time does
+/// not reveal how much of a guess was right.
+fn tokensEqual(a: []const u8, b: []const u8) bool {
+    if (a.len != b.len) return false;
+    var diff: u8 = 0;
+    for (a, b) |x, y| diff |= x ^ y;
+    return diff == 0;
+}
+
 fn peerUID(fd: std.posix.fd_t) !u32 {
     return switch (builtin.os.tag) {
         .macos => peerUIDDarwin(fd),
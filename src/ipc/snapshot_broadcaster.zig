@@ -1,15 +1,39 @@
 //! Stateful Snapshot broadcasting for connected IPC clients.
-//! This module concentrates client worker threads, publish ordering, requester exclusion, write timeouts, dedupe, and reaping so `ipc.server` stays focused on sockets.
+//! This module concentrates client worker threads, outgoing queues, publish ordering, snapshot sequencing and deltas, requester exclusion, write timeouts, heartbeats, output streams, dedupe, and reaping so `ipc.server` stays focused on sockets.
 
 const std = @import("std");
+const domain = @import("../domain/root.zig");
+const ring = @import("../ring/root.zig");
+const threads = @import("../threads/root.zig");
+const frame = @import("frame.zig");
 const interfaces = @import("interfaces.zig");
 const line_io = @import("line.zig");
 const protocol = @import("protocol.zig");
 
-const max_request_line = 1024 * 1024;
 const default_client_write_timeout_ms: u64 = 2000;
+// Responses and heartbeats a client may have outstanding before it is
+// considered stuck. Snapshots do not count: they coalesce into one slot.
+const max_queued_lines = 256;
+// Every client gets a full snapshot after this many consecutive deltas, which
+// bounds how long any undetected divergence can last.
+const full_snapshot_every = 100;
+
+/// With a change signal, how long the monitor and output streams gather a
+/// burst of changes before sending; without one, how often the monitor polls.
+pub const PollIntervals = struct {
+    snapshot_ms: u32 = 50,
+    output_ms: u32 = 20,
+};
+
+/// Token bucket for the requests one client may send: `burst` at once, then
+/// `per_second`. Pings and pongs are free so heartbeats never trip it.
+/// A `per_second` of 0 turns the limit off.
+pub const RateLimit = struct {
+    per_second: u32 = 100,
+    burst: u32 = 200,
+};
 
-const log = std.log.scoped(.ipc_snapshot_broadcaster);
+const log = std.log.scoped(.ipc);
 
 /// Owns stateful IPC clients after socket acceptance. The Interface stays small
 /// so socket authorization remains in `ipc.server` while broadcast lifecycle
@@ -25,6 +49,31 @@ pub const Broadcaster = struct {
     clients_mutex: std.Thread.Mutex = .{},
     snapshot_broadcast_mutex: std.Thread.Mutex = .{},
     last_broadcast_snapshot_line: ?[]const u8 = null,
+    /// Latest published state, its sequenced full snapshot line, and how many
+    /// deltas have gone out since the last full broadcast.
+    state: ?protocol.SnapshotUpdate = null,
+    state_line: ?[]const u8 = null,
+    state_seq: u64 = 0,
+    deltas_since_full: u32 = 0,
+    /// Optional live count of connected clients, read by metrics.
+    client_gauge: ?*std.atomic.Value(u32) = null,
+    /// Resolves stream requests; without it every stream request is refused.
+    output_provider: ?interfaces.OutputProvider = null,
+    /// Resolves detail requests; without it every one is refused.
+    details_provider: ?interfaces.DetailsProvider = null,
+    /// Resolves environment requests; without it every one is refused.
+    env_provider: ?interfaces.EnvProvider = null,
+    heartbeat_interval_ms: i64 = protocol.heartbeat_interval_ms,
+    heartbeat_timeout_ms: i64 = protocol.heartbeat_timeout_ms,
+    heartbeat_seq: u64 = 0,
+    intervals: PollIntervals = .{},
+    rate_limit: RateLimit = .{},
+    /// Wakes the monitor when process state changes. Without it the monitor
+    /// polls every `intervals.snapshot_ms`.
+    changes: ?*domain.changes.Signal = null,
+    /// Supplies the handshake each client must pass on its worker before it
+    /// gets any state. The peer check itself already ran on the accept loop.
+    authorizer: ?interfaces.PeerAuthorizer = null,
 
     pub fn init(
         allocator: std.mem.Allocator,
@@ -42,29 +91,32 @@ pub const Broadcaster = struct {
         };
     }
 
-    /// Starts the polling monitor that notices process-status changes not tied
-    /// to a command response, such as a child process exiting naturally.
+    /// Starts the monitor that notices process-status changes not tied to a
+    /// command response, such as a child process exiting naturally.
     pub fn start(self: *Broadcaster) !void {
-        self.snapshot_monitor_thread = try std.Thread.spawn(.{}, runSnapshotMonitor, .{self});
+        self.snapshot_monitor_thread = try threads.spawn(.{}, runSnapshotMonitor, .{self});
     }
 
     pub fn deinit(self: *Broadcaster) void {
         self.closeAllClients();
+        if (self.changes) |changes| changes.notify();
         if (self.snapshot_monitor_thread) |thread| thread.join();
         for (self.workers.items) |worker| {
             worker.thread.join();
             self.removeClient(worker.client);
-            worker.client.close();
+            worker.client.deinit();
             self.allocator.destroy(worker.client);
         }
         self.workers.deinit();
 
         for (self.clients.items) |client| {
-            client.close();
+            client.deinit();
             self.allocator.destroy(client);
         }
         self.clients.deinit();
         if (self.last_broadcast_snapshot_line) |line| self.allocator.free(line);
+        if (self.state) |*state| state.deinit();
+        if (self.state_line) |line| self.allocator.free(line);
     }
 
     /// Takes ownership of an accepted stream and serves it on a worker thread.
@@ -85,18 +137,27 @@ pub const Broadcaster = struct {
 
         const client = try self.allocator.create(SnapshotClient);
         errdefer self.allocator.destroy(client);
-        client.* = .{ .stream = stream };
+        client.* = SnapshotClient.init(self.allocator, stream);
+        if (self.authorizer) |authorizer| client.handshaking.store(authorizer.handshake != null, .seq_cst);
+        client.last_seen_ms.store(std.time.milliTimestamp(), .seq_cst);
         stream_owned = false;
+        client.startWriter() catch |err| {
+            client.deinit();
+            return err;
+        };
 
         self.clients_mutex.lock();
         self.clients.appendAssumeCapacity(client);
         self.clients_mutex.unlock();
+        if (self.client_gauge) |gauge| _ = gauge.fetchAdd(1, .seq_cst);
+        // An idle monitor skips heartbeats until a client is connected.
+        if (self.changes) |changes| changes.notify();
 
         // Register the client before the worker starts so a fast initial
         // snapshot write can still participate in shutdown and broadcast cleanup.
-        const thread = std.Thread.spawn(.{}, handleSnapshotClient, .{ self, client }) catch |err| {
+        const thread = threads.spawn(.{}, handleSnapshotClient, .{ self, client }) catch |err| {
             self.removeClient(client);
-            client.close();
+            client.deinit();
             return err;
         };
         self.workers.appendAssumeCapacity(.{
@@ -111,6 +172,7 @@ pub const Broadcaster = struct {
         for (self.clients.items, 0..) |item, index| {
             if (item == client) {
                 _ = self.clients.swapRemove(index);
+                if (self.client_gauge) |gauge| _ = gauge.fetchSub(1, .seq_cst);
                 return;
             }
         }
@@ -130,7 +192,7 @@ pub const Broadcaster = struct {
             _ = self.workers.swapRemove(index);
             worker.thread.join();
             self.removeClient(worker.client);
-            worker.client.close();
+            worker.client.deinit();
             self.allocator.destroy(worker.client);
         }
     }
@@ -144,39 +206,218 @@ pub const Broadcaster = struct {
     }
 
     fn serveClient(self: *Broadcaster, client: *SnapshotClient) !void {
-        const initial_line = try self.snapshot_provider.snapshotLine(self.allocator);
-        defer self.allocator.free(initial_line);
-        try client.writeAll(initial_line);
+        if (self.authorizer) |authorizer| try authorizer.handshakeStream(client.stream);
+        client.handshaking.store(false, .seq_cst);
+        try self.sendInitialState(client);
+        var bucket = Bucket{ .tokens = self.rate_limit.burst, .refilled_ms = std.time.milliTimestamp() };
 
         while (!self.stopped.load(.seq_cst)) {
-            const request_line = try line_io.read(self.allocator, client.stream, max_request_line);
+            const request_line = line_io.read(self.allocator, client.stream, protocol.max_request_line) catch |err| {
+                if (err == error.LineTooLong) {
+                    // The rest of the line is unread, so the stream cannot be
+                    // trusted past this point; answer directly, then hang up.
+                    const line = try protocol.responseLine(self.allocator, .{
+                        .request_id = 0,
+                        .success = false,
+                        .error_message = "request line too long",
+                        .code = .message_too_large,
+                    });
+                    defer self.allocator.free(line);
+                    client.writeAll(line) catch {};
+                }
+                return err;
+            };
             defer self.allocator.free(request_line);
+            const now_ms = std.time.milliTimestamp();
+            client.last_seen_ms.store(now_ms, .seq_cst);
+
+            var message = protocol.decodeLine(self.allocator, request_line) catch |err| {
+                if (err == error.OutOfMemory) return err;
+                const text = try std.fmt.allocPrint(self.allocator, "invalid request: {s}", .{@errorName(err)});
+                defer self.allocator.free(text);
+                try self.queueFailure(client, protocol.requestIdOf(self.allocator, request_line), protocol.errorCodeFor(err), text);
+                continue;
+            };
+            defer message.deinit(self.allocator);
+            switch (message) {
+                .ping, .pong => {},
+                else => if (!bucket.take(self.rate_limit, now_ms)) {
+                    try self.queueFailure(client, requestIdOfMessage(message), .rate_limited, "too many requests");
+                    continue;
+                },
+            }
+            switch (message) {
+                .command => |request| try self.serveCommand(client, request),
+                .stream => |request| {
+                    const scrollback = (try self.acceptOutputStream(client, request)) orelse continue;
+                    return self.streamOutput(client, scrollback, request);
+                },
+                .scrollback => |request| try self.serveScrollback(client, request),
+                .details => |request| try self.serveDetails(client, request),
+                .env => |request| try self.serveEnv(client, request),
+                .ping => |seq| {
+                    const pong = try protocol.pongLine(self.allocator, seq);
+                    defer self.allocator.free(pong);
+                    try client.queueLine(pong);
+                },
+                .pong => {},
+                .resync => try self.resendFullState(client),
+                .snapshot, .scrollback_data, .details_data, .env_data, .response, .delta => {
+                    try self.queueFailure(client, 0, .invalid_request, "invalid request: server-only message type");
+                },
+            }
+        }
+    }
+
+    fn serveCommand(self: *Broadcaster, client: *SnapshotClient, request: protocol.CommandRequest) !void {
+        const is_switch = request.action == .switch_process;
+        var snapshot_broadcast_locked = is_switch;
+        if (snapshot_broadcast_locked) self.snapshot_broadcast_mutex.lock();
+        defer if (snapshot_broadcast_locked) self.snapshot_broadcast_mutex.unlock();
 
-            const request = try protocol.parseCommandRequestLine(self.allocator, request_line);
-            defer protocol.deinitCommandRequest(self.allocator, request);
+        var response = try self.handler.handleCommand(self.allocator, request);
+        defer response.deinit(self.allocator);
+        if (!response.success and response.code == .none) response.code = .command_failed;
 
-            const is_switch = request.action == .switch_process;
-            var snapshot_broadcast_locked = is_switch;
-            if (snapshot_broadcast_locked) self.snapshot_broadcast_mutex.lock();
-            defer if (snapshot_broadcast_locked) self.snapshot_broadcast_mutex.unlock();
+        const line = try protocol.responseLine(self.allocator, response);
+        defer self.allocator.free(line);
 
-            var response = try self.handler.handleCommand(self.allocator, request);
-            defer response.deinit(self.allocator);
+        if (is_switch) {
+            if (response.success) try self.publishCommandSnapshotExceptLocked(client);
+            self.snapshot_broadcast_mutex.unlock();
+            snapshot_broadcast_locked = false;
+        }
 
-            const line = try protocol.responseLine(self.allocator, response);
-            defer self.allocator.free(line);
+        try client.queueLine(line);
 
-            if (is_switch) {
-                if (response.success) try self.publishCommandSnapshotExceptLocked(client);
-                self.snapshot_broadcast_mutex.unlock();
-                snapshot_broadcast_locked = false;
-            }
+        if (response.success and !is_switch) {
+            try self.publishCommandSnapshot();
+        }
+    }
+
+    /// Answers a stream request. A refused request leaves the connection a
+    /// normal stateful one; an accepted one stops state updates and heartbeats
+    /// before the response is queued, so no JSON line can follow it.
+    fn acceptOutputStream(self: *Broadcaster, client: *SnapshotClient, request: protocol.StreamRequest) !?*ring.RingBuffer {
+        const scrollback = (try self.resolveOutput(client, request.request_id, request.target, request.stderr)) orelse return null;
 
-            try client.writeAll(line);
+        // Broadcasts and heartbeats check `streaming` under the clients lock.
+        self.clients_mutex.lock();
+        client.streaming.store(true, .seq_cst);
+        self.clients_mutex.unlock();
+        client.discardQueuedState();
 
-            if (response.success and !is_switch) {
-                try self.publishCommandSnapshot();
+        const line = try protocol.responseLine(self.allocator, .{
+            .request_id = request.request_id,
+            .success = true,
+            .error_message = "",
+        });
+        defer self.allocator.free(line);
+        try client.queueLine(line);
+        return scrollback;
+    }
+
+    fn serveScrollback(self: *Broadcaster, client: *SnapshotClient, request: protocol.ScrollbackRequest) !void {
+        const scrollback = (try self.resolveOutput(client, request.request_id, request.target, request.stderr)) orelse return;
+        const bytes = if (request.since_ms) |since_ms|
+            try scrollback.bytesSince(self.allocator, since_ms)
+        else
+            try scrollback.bytes(self.allocator);
+        defer self.allocator.free(bytes);
+
+        const tail = protocol.scrollbackTail(bytes, request.lines, request.bytes);
+        const line = try protocol.scrollbackDataLine(self.allocator, request.request_id, tail);
+        defer self.allocator.free(line);
+        try client.queueLine(line);
+    }
+
+    fn serveEnv(self: *Broadcaster, client: *SnapshotClient, request: protocol.EnvRequest) !void {
+        const provider = self.env_provider orelse {
+            try self.queueFailure(client, request.request_id, .unavailable, "process environments are not available");
+            return;
+        };
+        const line = (try provider.envLine(self.allocator, request.request_id, request.target)) orelse {
+            const message = try std.fmt.allocPrint(self.allocator, "process not found: {s}", .{request.target});
+            defer self.allocator.free(message);
+            try self.queueFailure(client, request.request_id, .not_found, message);
+            return;
+        };
+        defer self.allocator.free(line);
+        try client.queueLine(line);
+    }
+
+    fn serveDetails(self: *Broadcaster, client: *SnapshotClient, request: protocol.DetailsRequest) !void {
+        const provider = self.details_provider orelse {
+            try self.queueFailure(client, request.request_id, .unavailable, "process details are not available");
+            return;
+        };
+        const line = (try provider.detailsLine(self.allocator, request.request_id, request.target)) orelse {
+            const message = try std.fmt.allocPrint(self.allocator, "process not found: {s}", .{request.target});
+            defer self.allocator.free(message);
+            try self.queueFailure(client, request.request_id, .not_found, message);
+            return;
+        };
+        defer self.allocator.free(line);
+        try client.queueLine(line);
+    }
+
+    /// Looks up a process's scrollback for an output request, answering the
+    /// request with a failure response and returning null when there is none.
+    fn resolveOutput(self: *Broadcaster, client: *SnapshotClient, request_id: u64, target: []const u8, stderr: bool) !?*ring.RingBuffer {
+        const provider = self.output_provider orelse {
+            try self.queueFailure(client, request_id, .unavailable, "process output is not available");
+            return null;
+        };
+        return (try provider.scrollbackFor(target, stderr)) orelse {
+            const message = try std.fmt.allocPrint(self.allocator, "process not found: {s}", .{target});
+            defer self.allocator.free(message);
+            try self.queueFailure(client, request_id, .not_found, message);
+            return null;
+        };
+    }
+
+    fn queueFailure(self: *Broadcaster, client: *SnapshotClient, request_id: u64, code: protocol.ErrorCode, message: []const u8) !void {
+        const line = try protocol.responseLine(self.allocator, .{
+            .request_id = request_id,
+            .success = false,
+            .error_message = message,
+            .code = code,
+        });
+        defer self.allocator.free(line);
+        try client.queueLine(line);
+    }
+
+    /// Relays a process's output to a streaming client until either side
+    /// closes: retained scrollback first, limited as the request asks, then
+    /// live output as it is written. Output the ring drops for a reader more
+    /// than a megabyte behind is lost, as in the viewer.
+    fn streamOutput(self: *Broadcaster, client: *SnapshotClient, scrollback: *ring.RingBuffer, request: protocol.StreamRequest) !void {
+        const subscription = try scrollback.snapshotSinceAndSubscribe(self.allocator, request.since_ms);
+        defer scrollback.removeReader(subscription.reader_id);
+        // The ring writes a byte here per output write, so an idle stream
+        // sleeps in `poll` instead of checking the ring on a timer.
+        const wake_fds = std.posix.pipe2(.{ .NONBLOCK = true, .CLOEXEC = true }) catch |err| {
+            self.allocator.free(subscription.snapshot);
+            return err;
+        };
+        defer std.posix.close(wake_fds[0]);
+        defer std.posix.close(wake_fds[1]);
+        scrollback.setReaderWakeFd(subscription.reader_id, wake_fds[1]);
+        defer scrollback.setReaderWakeFd(subscription.reader_id, null);
+        const history = if (request.lines) |lines| protocol.lastLines(subscription.snapshot, lines) else subscription.snapshot;
+        const history_queued = if (history.len > 0) client.queueFrames(.history, history) else {};
+        self.allocator.free(subscription.snapshot);
+        try history_queued;
+        try client.queueFrames(.history_end, "");
+
+        while (!self.stopped.load(.seq_cst)) {
+            while (scrollback.readNext(subscription.reader_id)) |chunk| {
+                defer scrollback.allocator.free(chunk);
+                try client.queueFrames(.output, chunk);
             }
+            if (!client.waitWhileStreaming(wake_fds[0])) return;
+            // Gather the rest of a burst into fewer, larger frames.
+            std.Thread.sleep(@as(u64, self.intervals.output_ms) * std.time.ns_per_ms);
         }
     }
 
@@ -190,7 +431,7 @@ pub const Broadcaster = struct {
         const line = try self.snapshot_provider.snapshotLine(self.allocator);
         defer self.allocator.free(line);
         try self.rememberPublishedSnapshotLineLocked(line);
-        try self.writeSnapshotLineToClientsExcept(line, null);
+        try self.publishStateLocked(line, null);
     }
 
     fn publishCommandSnapshotExcept(self: *Broadcaster, excluded: *SnapshotClient) !void {
@@ -203,7 +444,7 @@ pub const Broadcaster = struct {
         const line = try self.snapshot_provider.snapshotLine(self.allocator);
         defer self.allocator.free(line);
         try self.rememberPublishedSnapshotLineLocked(line);
-        try self.writeSnapshotLineToClientsExcept(line, excluded);
+        try self.publishStateLocked(line, excluded);
     }
 
     fn rememberPublishedSnapshotLineLocked(self: *Broadcaster, line: []const u8) !void {
@@ -216,23 +457,85 @@ pub const Broadcaster = struct {
         self.last_broadcast_snapshot_line = copy;
     }
 
-    fn writeSnapshotLineToClientsExcept(self: *Broadcaster, line: []const u8, excluded: ?*SnapshotClient) !void {
+    /// Stamps the provider's snapshot with the next sequence number and queues
+    /// it to every client, as a delta against the previous state where one can
+    /// express the change. The excluded requester gets nothing now and a full
+    /// snapshot next time, since it would otherwise see a sequence gap.
+    fn publishStateLocked(self: *Broadcaster, line: []const u8, excluded: ?*SnapshotClient) !void {
+        var next = try protocol.parseSnapshotLine(self.allocator, line);
+        errdefer next.deinit();
+        const seq = self.state_seq + 1;
+        const full_line = try protocol.snapshotLineWithSeq(self.allocator, next.snapshot(), seq);
+        errdefer self.allocator.free(full_line);
+        const delta_line = delta: {
+            const previous = if (self.state) |*state| state else break :delta null;
+            if (self.deltas_since_full >= full_snapshot_every) break :delta null;
+            break :delta try protocol.deltaLine(self.allocator, previous.snapshot(), next.snapshot(), self.state_seq, seq);
+        };
+        defer if (delta_line) |delta| self.allocator.free(delta);
+
+        if (self.state) |*previous| previous.deinit();
+        self.state = next;
+        if (self.state_line) |previous| self.allocator.free(previous);
+        self.state_line = full_line;
+        self.state_seq = seq;
+        self.deltas_since_full = if (delta_line == null) 0 else self.deltas_since_full + 1;
+
         self.clients_mutex.lock();
         defer self.clients_mutex.unlock();
         for (self.clients.items) |client| {
             if (excluded) |skip| {
-                if (client == skip) continue;
+                if (client == skip) {
+                    client.requireFullState();
+                    continue;
+                }
             }
-            if (client.closed.load(.seq_cst)) continue;
-            client.writeAll(line) catch |err| {
+            if (client.closed.load(.seq_cst) or client.streaming.load(.seq_cst) or client.handshaking.load(.seq_cst)) continue;
+            client.queueState(full_line, delta_line) catch |err| {
                 log.debug("dropping snapshot broadcast to disconnected client: {s}", .{@errorName(err)});
             };
         }
     }
 
+    /// Sends a new client the current state as a full snapshot, publishing a
+    /// fresh sequence number first if the state moved since the last broadcast.
+    fn sendInitialState(self: *Broadcaster, client: *SnapshotClient) !void {
+        self.snapshot_broadcast_mutex.lock();
+        defer self.snapshot_broadcast_mutex.unlock();
+
+        const line = try self.snapshot_provider.snapshotLine(self.allocator);
+        defer self.allocator.free(line);
+        if (self.state_line) |state_line| {
+            if (self.last_broadcast_snapshot_line) |previous| {
+                if (std.mem.eql(u8, previous, line)) return client.queueState(state_line, null);
+            }
+        }
+        // New clients start out needing a full snapshot, so the publish
+        // reaches this one in full and everyone else as a delta.
+        try self.rememberPublishedSnapshotLineLocked(line);
+        try self.publishStateLocked(line, null);
+    }
+
+    fn resendFullState(self: *Broadcaster, client: *SnapshotClient) !void {
+        self.snapshot_broadcast_mutex.lock();
+        defer self.snapshot_broadcast_mutex.unlock();
+        const state_line = self.state_line orelse return;
+        client.requireFullState();
+        try client.queueState(state_line, null);
+    }
+
     fn monitorSnapshotChanges(self: *Broadcaster) !void {
+        var next_heartbeat_ms = std.time.milliTimestamp() + self.heartbeat_interval_ms;
+        var seen: u32 = if (self.changes) |changes| changes.current() else 0;
         while (!self.stopped.load(.seq_cst)) {
-            std.Thread.sleep(50 * std.time.ns_per_ms);
+            self.waitForChange(&seen, next_heartbeat_ms);
+            if (self.stopped.load(.seq_cst)) return;
+
+            const now_ms = std.time.milliTimestamp();
+            if (now_ms >= next_heartbeat_ms) {
+                try self.heartbeatClients(now_ms);
+                next_heartbeat_ms = now_ms + self.heartbeat_interval_ms;
+            }
 
             self.snapshot_broadcast_mutex.lock();
             defer self.snapshot_broadcast_mutex.unlock();
@@ -246,7 +549,57 @@ pub const Broadcaster = struct {
                 if (std.mem.eql(u8, previous, line)) continue;
             }
             try self.rememberPublishedSnapshotLineLocked(line);
-            try self.writeSnapshotLineToClientsExcept(line, null);
+            try self.publishStateLocked(line, null);
+        }
+    }
+
+    /// Sleeps until process state changes or the next heartbeat is due, then
+    /// a little longer so a burst of output becomes one snapshot. Heartbeats
+    /// are only waited for while a client is connected, so an idle primary
+    /// with no clients does not wake at all.
+    fn waitForChange(self: *Broadcaster, seen: *u32, next_heartbeat_ms: i64) void {
+        const batch_ns = @as(u64, self.intervals.snapshot_ms) * std.time.ns_per_ms;
+        const changes = self.changes orelse return std.Thread.sleep(batch_ns);
+
+        const timeout_ms: ?u64 = if (self.clientCount() > 0)
+            @intCast(@max(next_heartbeat_ms - std.time.milliTimestamp(), 0))
+        else
+            null;
+        const generation = changes.wait(seen.*, timeout_ms);
+        if (generation == seen.*) return;
+        std.Thread.sleep(batch_ns);
+        seen.* = changes.current();
+    }
+
+    fn clientCount(self: *Broadcaster) usize {
+        self.clients_mutex.lock();
+        defer self.clients_mutex.unlock();
+        return self.clients.items.len;
+    }
+
+    /// Pings every client and drops those that have not sent anything, pongs
+    /// included, within the heartbeat timeout. Closing the stream wakes the
+    /// client's worker so it is reaped like any other disconnect.
+    fn heartbeatClients(self: *Broadcaster, now_ms: i64) !void {
+        self.heartbeat_seq += 1;
+        const line = try protocol.pingLine(self.allocator, self.heartbeat_seq);
+        defer self.allocator.free(line);
+
+        self.clients_mutex.lock();
+        defer self.clients_mutex.unlock();
+        for (self.clients.items) |client| {
+            // Output streams only ever receive; their readers notice hang-ups.
+            // Handshaking clients are bounded by the handshake's own deadline.
+            if (client.closed.load(.seq_cst) or client.streaming.load(.seq_cst) or client.handshaking.load(.seq_cst)) continue;
+            const silent_ms = now_ms - client.last_seen_ms.load(.seq_cst);
+            if (silent_ms > self.heartbeat_timeout_ms) {
+                log.info("dropping IPC client silent for {d}ms", .{silent_ms});
+                client.close();
+                continue;
+            }
+            client.queueLine(line) catch |err| {
+                log.debug("dropping heartbeat to disconnected client: {s}", .{@errorName(err)});
+            };
         }
     }
 };
@@ -256,15 +609,167 @@ const ClientWorker = struct {
     thread: std.Thread,
 };
 
+/// One connected client. Broadcasts and responses only queue bytes here; a
+/// dedicated writer thread delivers them, so a slow reader stalls nobody but
+/// itself and is dropped once its write timeout or queue limit is hit.
 const SnapshotClient = struct {
+    allocator: std.mem.Allocator,
     stream: std.net.Stream,
     write_mutex: std.Thread.Mutex = .{},
     closed: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
     finished: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
     write_timeout_ms: u64 = default_client_write_timeout_ms,
+    last_seen_ms: std.atomic.Value(i64) = std.atomic.Value(i64).init(0),
+    /// Set once the connection has become an output stream. Changed only under
+    /// the broadcaster's clients lock.
+    streaming: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    /// Set until the client passes its handshake; broadcasts skip it meanwhile.
+    handshaking: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    queue_mutex: std.Thread.Mutex = .{},
+    queue_ready: std.Thread.Condition = .{},
+    /// Responses, heartbeats, and output frames, delivered in order ahead of
+    /// any snapshot.
+    queued_lines: std.array_list.Managed([]const u8),
+    /// Newest undelivered state update. A newer update replaces it instead of
+    /// queueing behind it, as a full snapshot since the client will have
+    /// skipped the state a delta would build on.
+    queued_snapshot: ?[]const u8 = null,
+    /// Set for new clients and skipped requesters: the next state update must
+    /// be a full snapshot. Guarded by `queue_mutex`.
+    needs_full_state: bool = true,
+    writer_thread: ?std.Thread = null,
+
+    fn init(allocator: std.mem.Allocator, stream: std.net.Stream) SnapshotClient {
+        return .{
+            .allocator = allocator,
+            .stream = stream,
+            .queued_lines = std.array_list.Managed([]const u8).init(allocator),
+        };
+    }
+
+    fn startWriter(self: *SnapshotClient) !void {
+        self.writer_thread = try threads.spawn(.{}, runClientWriter, .{self});
+    }
+
+    fn deinit(self: *SnapshotClient) void {
+        self.close();
+        if (self.writer_thread) |thread| thread.join();
+        self.writer_thread = null;
+        for (self.queued_lines.items) |line| self.allocator.free(line);
+        self.queued_lines.deinit();
+        if (self.queued_snapshot) |line| self.allocator.free(line);
+        self.queued_snapshot = null;
+    }
 
     fn close(self: *SnapshotClient) void {
-        if (!self.closed.swap(true, .seq_cst)) self.stream.close();
+        if (self.closed.swap(true, .seq_cst)) return;
+        // Shutdown wakes a worker blocked reading this stream; close alone
+        // does not on Linux.
+        std.posix.shutdown(self.stream.handle, .both) catch {};
+        self.stream.close();
+
+        self.queue_mutex.lock();
+        defer self.queue_mutex.unlock();
+        self.queue_ready.broadcast();
+    }
+
+    fn queueLine(self: *SnapshotClient, line: []const u8) !void {
+        const copy = try self.allocator.dupe(u8, line);
+        errdefer self.allocator.free(copy);
+
+        const too_slow = queue: {
+            self.queue_mutex.lock();
+            defer self.queue_mutex.unlock();
+            if (self.closed.load(.seq_cst)) return error.EndOfStream;
+            if (self.queued_lines.items.len >= max_queued_lines) break :queue true;
+            try self.queued_lines.append(copy);
+            self.queue_ready.signal();
+            break :queue false;
+        };
+        if (too_slow) {
+            // Closing takes the queue lock, so it happens after release.
+            log.warn("dropping IPC client with {d} undelivered messages", .{max_queued_lines});
+            self.close();
+            return error.ClientTooSlow;
+        }
+    }
+
+    fn queueState(self: *SnapshotClient, full_line: []const u8, delta_line: ?[]const u8) !void {
+        self.queue_mutex.lock();
+        defer self.queue_mutex.unlock();
+        if (self.closed.load(.seq_cst)) return error.EndOfStream;
+
+        const use_delta = delta_line != null and !self.needs_full_state and self.queued_snapshot == null;
+        const copy = try self.allocator.dupe(u8, if (use_delta) delta_line.? else full_line);
+        if (self.queued_snapshot) |previous| self.allocator.free(previous);
+        self.queued_snapshot = copy;
+        self.needs_full_state = false;
+        self.queue_ready.signal();
+    }
+
+    fn discardQueuedState(self: *SnapshotClient) void {
+        self.queue_mutex.lock();
+        defer self.queue_mutex.unlock();
+        if (self.queued_snapshot) |line| self.allocator.free(line);
+        self.queued_snapshot = null;
+    }
+
+    /// Queues `bytes` as frames of `kind`, split at the frame payload limit.
+    /// Empty input still queues one empty frame.
+    fn queueFrames(self: *SnapshotClient, kind: frame.Kind, bytes: []const u8) !void {
+        var index: usize = 0;
+        while (true) {
+            const end = @min(index + frame.max_payload_len, bytes.len);
+            const encoded = try frame.encode(self.allocator, kind, bytes[index..end]);
+            defer self.allocator.free(encoded);
+            try self.queueLine(encoded);
+            index = end;
+            if (index >= bytes.len) return;
+        }
+    }
+
+    /// Waits until `wake_fd` signals new output or the streaming client sends
+    /// something, which is discarded. Returns false once the client has hung
+    /// up or been closed; `close` shuts the socket down, which wakes the poll.
+    fn waitWhileStreaming(self: *SnapshotClient, wake_fd: std.posix.fd_t) bool {
+        if (self.closed.load(.seq_cst)) return false;
+        var poll_fds = [_]std.posix.pollfd{
+            .{ .fd = self.stream.handle, .events = std.posix.POLL.IN, .revents = 0 },
+            .{ .fd = wake_fd, .events = std.posix.POLL.IN, .revents = 0 },
+        };
+        _ = std.posix.poll(&poll_fds, -1) catch return false;
+
+        if (poll_fds[1].revents != 0) {
+            var drained: [64]u8 = undefined;
+            while ((std.posix.read(wake_fd, &drained) catch 0) == drained.len) {}
+        }
+        if (poll_fds[0].revents != 0) {
+            var discard: [256]u8 = undefined;
+            const n = self.stream.read(&discard) catch return false;
+            if (n == 0) return false;
+        }
+        return !self.closed.load(.seq_cst);
+    }
+
+    fn requireFullState(self: *SnapshotClient) void {
+        self.queue_mutex.lock();
+        defer self.queue_mutex.unlock();
+        self.needs_full_state = true;
+    }
+
+    /// Blocks until something is queued, then takes the next line to write.
+    /// Returns null once the client is closed.
+    fn takeQueued(self: *SnapshotClient) ?[]const u8 {
+        self.queue_mutex.lock();
+        defer self.queue_mutex.unlock();
+        while (!self.closed.load(.seq_cst) and self.queued_lines.items.len == 0 and self.queued_snapshot == null) {
+            self.queue_ready.wait(&self.queue_mutex);
+        }
+        if (self.closed.load(.seq_cst)) return null;
+        if (self.queued_lines.items.len > 0) return self.queued_lines.orderedRemove(0);
+        const snapshot = self.queued_snapshot;
+        self.queued_snapshot = null;
+        return snapshot;
     }
 
     fn writeAll(self: *SnapshotClient, bytes: []const u8) !void {
@@ -284,6 +789,49 @@ fn runSnapshotMonitor(server: *Broadcaster) void {
     };
 }
 
+fn runClientWriter(client: *SnapshotClient) void {
+    while (client.takeQueued()) |line| {
+        defer client.allocator.free(line);
+        client.writeAll(line) catch |err| {
+            log.debug("snapshot client writer stopped: {s}", .{@errorName(err)});
+            return;
+        };
+    }
+}
+
+/// Request budget for one client; only its worker thread touches it.
+const Bucket = struct {
+    tokens: u32,
+    refilled_ms: i64,
+
+    /// Spends one request, refilling first for the time since the last
+    /// refill. False when the client is over its limit.
+    fn take(self: *Bucket, limit: RateLimit, now_ms: i64) bool {
+        if (limit.per_second == 0) return true;
+        const per_second: i64 = limit.per_second;
+        const earned = @divFloor(@max(now_ms - self.refilled_ms, 0) * per_second, 1000);
+        if (earned > 0) {
+            self.tokens = @intCast(@min(@as(i64, limit.burst), @as(i64, self.tokens) + earned));
+            // Time that earned nothing yet carries over, unless the bucket is full.
+            self.refilled_ms = if (self.tokens == limit.burst) now_ms else self.refilled_ms + @divFloor(earned * 1000, per_second);
+        }
+        if (self.tokens == 0) return false;
+        self.tokens -= 1;
+        return true;
+    }
+};
+
+fn requestIdOfMessage(message: protocol.Message) u64 {
+    return switch (message) {
+        .command => |request| request.request_id,
+        .stream => |request| request.request_id,
+        .scrollback => |request| request.request_id,
+        .details => |request| request.request_id,
+        .env => |request| request.request_id,
+        else => 0,
+    };
+}
+
 fn handleSnapshotClient(server: *Broadcaster, client: *SnapshotClient) void {
     server.serveClient(client) catch |err| {
         log.debug("snapshot client handler stopped: {s}", .{@errorName(err)});
@@ -327,8 +875,8 @@ fn setStreamWriteTimeoutMs(stream: std.net.Stream, timeout_ms: u64) !void {
 
 test "snapshot client write times out and closes slow reader" {
     var streams = try testSocketPair();
-    var client = SnapshotClient{ .stream = streams[0] };
-    defer client.close();
+    var client = SnapshotClient.init(std.testing.allocator, streams[0]);
+    defer client.deinit();
     defer streams[1].close();
 
     client.write_timeout_ms = 20;
@@ -347,8 +895,8 @@ test "snapshot client write times out and closes slow reader" {
 
 test "snapshot client closes peer that disconnects before initial snapshot write" {
     var streams = try testSocketPair();
-    var client = SnapshotClient{ .stream = streams[0] };
-    defer client.close();
+    var client = SnapshotClient.init(std.testing.allocator, streams[0]);
+    defer client.deinit();
 
     streams[1].close();
 
@@ -377,18 +925,50 @@ test "snapshot monitor broadcasts its first sampled snapshot" {
 
     const client = try std.testing.allocator.create(SnapshotClient);
     errdefer std.testing.allocator.destroy(client);
-    errdefer client.close();
-    client.* = .{
-        .stream = streams[0],
-        .write_timeout_ms = 200,
-    };
+    client.* = SnapshotClient.init(std.testing.allocator, streams[0]);
+    errdefer client.deinit();
+    client.write_timeout_ms = 200;
+    try client.startWriter();
     try broadcaster.clients.append(client);
 
     try broadcaster.start();
 
     const line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
     defer std.testing.allocator.free(line);
-    try std.testing.expectEqualStrings(snapshot_line, line);
+    try expectFullSnapshot(snapshot_line, line);
+}
+
+test "idle snapshot monitor sleeps until process state changes" {
+    const snapshot_line = "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":0,\"exiting\":false,\"ui\":{},\"processes\":[]}\n";
+    var provider = StaticSnapshotProvider{ .line = snapshot_line };
+    var stopped = std.atomic.Value(bool).init(false);
+    var changes = domain.changes.Signal{};
+    var broadcaster = Broadcaster.init(
+        std.testing.allocator,
+        unusedCommandHandler(),
+        provider.provider(),
+        &stopped,
+    );
+    broadcaster.changes = &changes;
+    broadcaster.intervals.snapshot_ms = 5;
+    defer {
+        stopped.store(true, .seq_cst);
+        broadcaster.deinit();
+    }
+    try broadcaster.start();
+
+    // A polling monitor would have built about 40 snapshots by now.
+    std.Thread.sleep(200 * std.time.ns_per_ms);
+    try std.testing.expectEqual(@as(u32, 0), provider.calls.load(.seq_cst));
+
+    changes.notify();
+    changes.notify();
+    const deadline = std.time.milliTimestamp() + 1000;
+    while (provider.calls.load(.seq_cst) == 0 and std.time.milliTimestamp() < deadline) {
+        std.Thread.sleep(std.time.ns_per_ms);
+    }
+    std.Thread.sleep(100 * std.time.ns_per_ms);
+    try std.testing.expectEqual(@as(u32, 1), provider.calls.load(.seq_cst));
 }
 
 test "snapshot monitor does not echo snapshot already published except requester" {
@@ -414,27 +994,25 @@ test "snapshot monitor does not echo snapshot already published except requester
 
     const requester = try std.testing.allocator.create(SnapshotClient);
     errdefer std.testing.allocator.destroy(requester);
-    errdefer requester.close();
-    requester.* = .{
-        .stream = requester_streams[0],
-        .write_timeout_ms = 200,
-    };
+    requester.* = SnapshotClient.init(std.testing.allocator, requester_streams[0]);
+    errdefer requester.deinit();
+    requester.write_timeout_ms = 200;
+    try requester.startWriter();
     try broadcaster.clients.append(requester);
 
     const observer = try std.testing.allocator.create(SnapshotClient);
     errdefer std.testing.allocator.destroy(observer);
-    errdefer observer.close();
-    observer.* = .{
-        .stream = observer_streams[0],
-        .write_timeout_ms = 200,
-    };
+    observer.* = SnapshotClient.init(std.testing.allocator, observer_streams[0]);
+    errdefer observer.deinit();
+    observer.write_timeout_ms = 200;
+    try observer.startWriter();
     try broadcaster.clients.append(observer);
 
     try broadcaster.publishCommandSnapshotExcept(requester);
 
     const observer_line = try line_io.readTimeout(std.testing.allocator, observer_streams[1], 1024, 200);
     defer std.testing.allocator.free(observer_line);
-    try std.testing.expectEqualStrings(snapshot_line, observer_line);
+    try expectFullSnapshot(snapshot_line, observer_line);
 
     try std.testing.expectError(
         error.CommandTimeout,
@@ -473,7 +1051,7 @@ test "successful process command publishes snapshot and finished client is reape
 
     const initial_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
     defer std.testing.allocator.free(initial_line);
-    try std.testing.expectEqualStrings(snapshot_line, initial_line);
+    try expectFullSnapshot(snapshot_line, initial_line);
 
     const command_line = try protocol.commandRequestLine(std.testing.allocator, 9, .start, "api");
     defer std.testing.allocator.free(command_line);
@@ -488,7 +1066,12 @@ test "successful process command publishes snapshot and finished client is reape
 
     const published_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
     defer std.testing.allocator.free(published_line);
-    try std.testing.expectEqualStrings(snapshot_line, published_line);
+    // The state did not change, but the requester still hears about the
+    // command's completion, as an empty delta on top of its initial snapshot.
+    var published = try protocol.parseDeltaLine(std.testing.allocator, published_line);
+    defer published.deinit();
+    try std.testing.expectEqual(@as(u64, 1), published.baseSeq());
+    try std.testing.expectEqual(@as(u64, 2), published.seq());
 
     streams[1].close();
     peer_open = false;
@@ -500,6 +1083,221 @@ test "successful process command publishes snapshot and finished client is reape
     try std.testing.expectEqual(@as(usize, 1), handler.call_count);
 }
 
+test "invalid and over-limit requests are answered with codes instead of a disconnect" {
+    const snapshot_line = "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":1,\"exiting\":false,\"ui\":{},\"processes\":[]}\n";
+    var handler = SuccessCommandHandler{};
+    var provider = StaticSnapshotProvider{ .line = snapshot_line };
+    var stopped = std.atomic.Value(bool).init(false);
+    var broadcaster = Broadcaster.init(
+        std.testing.allocator,
+        handler.handler(),
+        provider.provider(),
+        &stopped,
+    );
+    broadcaster.rate_limit = .{ .per_second = 1, .burst = 1 };
+    defer {
+        stopped.store(true, .seq_cst);
+        broadcaster.closeAllClients();
+        broadcaster.deinit();
+    }
+
+    var streams = try testSocketPair();
+    defer streams[1].close();
+    try broadcaster.addClient(streams[0]);
+
+    const initial_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(initial_line);
+
+    try streams[1].writeAll("{\"type\":\"command\",\"protocol_version\":1,\"request_id\":4,\"action\":\"start\",\"extra\":1}\n");
+    try expectFailure(streams[1], 4, .invalid_request);
+
+    const first = try protocol.commandRequestLine(std.testing.allocator, 5, .start, "api");
+    defer std.testing.allocator.free(first);
+    try streams[1].writeAll(first);
+    const accepted_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(accepted_line);
+    var accepted = try protocol.parseResponseLine(std.testing.allocator, accepted_line);
+    defer accepted.deinit(std.testing.allocator);
+    try std.testing.expect(accepted.success);
+    const published_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(published_line);
+
+    const second = try protocol.commandRequestLine(std.testing.allocator, 6, .start, "api");
+    defer std.testing.allocator.free(second);
+    try streams[1].writeAll(second);
+    try expectFailure(streams[1], 6, .rate_limited);
+    try std.testing.expectEqual(@as(usize, 1), handler.call_count);
+
+    const oversized = try std.testing.allocator.alloc(u8, protocol.max_request_line + 1);
+    defer std.testing.allocator.free(oversized);
+    @memset(oversized, 'x');
+    try streams[1].writeAll(oversized);
+    try expectFailure(streams[1], 0, .message_too_large);
+}
+
+test "request bucket refills at its rate up to the burst" {
+    const limit = RateLimit{ .per_second = 10, .burst = 2 };
+    var bucket = Bucket{ .tokens = limit.burst, .refilled_ms = 0 };
+    try std.testing.expect(bucket.take(limit, 0));
+    try std.testing.expect(bucket.take(limit, 0));
+    try std.testing.expect(!bucket.take(limit, 50));
+    try std.testing.expect(bucket.take(limit, 100));
+    try std.testing.expect(!bucket.take(limit, 150));
+    try std.testing.expect(bucket.take(limit, 1000));
+    try std.testing.expect(bucket.take(limit, 1000));
+    try std.testing.expect(!bucket.take(limit, 1000));
+
+    var unlimited = Bucket{ .tokens = 0, .refilled_ms = 0 };
+    try std.testing.expect(unlimited.take(.{ .per_second = 0 }, 0));
+}
+
+test "heartbeat pings clients and drops ones that stop answering" {
+    const snapshot_line = "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":0,\"exiting\":false,\"ui\":{},\"processes\":[]}\n";
+    var provider = StaticSnapshotProvider{ .line = snapshot_line };
+    var stopped = std.atomic.Value(bool).init(false);
+    var broadcaster = Broadcaster.init(
+        std.testing.allocator,
+        unusedCommandHandler(),
+        provider.provider(),
+        &stopped,
+    );
+    broadcaster.heartbeat_interval_ms = 20;
+    broadcaster.heartbeat_timeout_ms = 150;
+    defer {
+        stopped.store(true, .seq_cst);
+        broadcaster.closeAllClients();
+        broadcaster.deinit();
+    }
+
+    var streams = try testSocketPair();
+    defer streams[1].close();
+
+    try broadcaster.addClient(streams[0]);
+    try broadcaster.start();
+
+    const initial_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(initial_line);
+    try expectFullSnapshot(snapshot_line, initial_line);
+
+    const ping_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(ping_line);
+    var ping = try protocol.decodeLine(std.testing.allocator, ping_line);
+    defer ping.deinit(std.testing.allocator);
+    const pong_line = try protocol.pongLine(std.testing.allocator, ping.ping);
+    defer std.testing.allocator.free(pong_line);
+    try streams[1].writeAll(pong_line);
+
+    // Stop answering; the broadcaster should hang up within the timeout.
+    var attempts: usize = 0;
+    while (attempts < 100) : (attempts += 1) {
+        const line = line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500) catch |err| {
+            try std.testing.expectEqual(error.EndOfStream, err);
+            break;
+        };
+        std.testing.allocator.free(line);
+    } else return error.ClientNotDropped;
+    try waitForOnlyWorkerFinished(&broadcaster);
+}
+
+test "queued snapshots coalesce to the newest for a client that is behind" {
+    var streams = try testSocketPair();
+    defer streams[1].close();
+    var client = SnapshotClient.init(std.testing.allocator, streams[0]);
+    defer client.deinit();
+
+    // Nothing is written until the writer starts, like a client stuck on a
+    // slow write.
+    try client.queueLine("response\n");
+    try client.queueState("snapshot-1\n", null);
+    try client.queueState("snapshot-2\n", null);
+    try client.startWriter();
+
+    const response = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(response);
+    try std.testing.expectEqualStrings("response\n", response);
+
+    const snapshot = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(snapshot);
+    try std.testing.expectEqualStrings("snapshot-2\n", snapshot);
+
+    try std.testing.expectError(
+        error.CommandTimeout,
+        line_io.readTimeout(std.testing.allocator, streams[1], 1024, 50),
+    );
+}
+
+test "client with too many undelivered messages is dropped" {
+    var streams = try testSocketPair();
+    defer streams[1].close();
+    var client = SnapshotClient.init(std.testing.allocator, streams[0]);
+    defer client.deinit();
+
+    for (0..max_queued_lines) |_| try client.queueLine("pong\n");
+    try std.testing.expectError(error.ClientTooSlow, client.queueLine("pong\n"));
+    try std.testing.expect(client.closed.load(.seq_cst));
+    try std.testing.expectError(error.EndOfStream, client.queueState("snapshot\n", null));
+}
+
+test "caught-up clients get deltas and a resync request gets the full state" {
+    var provider = StaticSnapshotProvider{ .line = test_api_halted_line };
+    var stopped = std.atomic.Value(bool).init(false);
+    var broadcaster = Broadcaster.init(
+        std.testing.allocator,
+        unusedCommandHandler(),
+        provider.provider(),
+        &stopped,
+    );
+    defer {
+        stopped.store(true, .seq_cst);
+        broadcaster.closeAllClients();
+        broadcaster.deinit();
+    }
+
+    var streams = try testSocketPair();
+    defer streams[1].close();
+    try broadcaster.addClient(streams[0]);
+
+    const initial_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(initial_line);
+    try expectFullSnapshot(test_api_halted_line, initial_line);
+
+    provider.line = test_api_running_line;
+    try broadcaster.publishCommandSnapshot();
+    const delta_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(delta_line);
+    var delta = try protocol.parseDeltaLine(std.testing.allocator, delta_line);
+    defer delta.deinit();
+    try std.testing.expectEqual(@as(u64, 1), delta.baseSeq());
+    try std.testing.expectEqual(@as(usize, 1), delta.parsed.value.processes.len);
+    try std.testing.expectEqualStrings("api", delta.parsed.value.processes[0].label);
+
+    const resync_line = try protocol.resyncLine(std.testing.allocator);
+    defer std.testing.allocator.free(resync_line);
+    try streams[1].writeAll(resync_line);
+    const full_line = try line_io.readTimeout(std.testing.allocator, streams[1], 1024, 500);
+    defer std.testing.allocator.free(full_line);
+    try expectFullSnapshot(test_api_running_line, full_line);
+}
+
+const test_api_halted_line = "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":1,\"exiting\":false,\"ui\":{},\"processes\":[{\"id\":1,\"label\":\"api\",\"status\":\"halted\",\"pid\":-1,\"description\":\"\",\"docs\":\"\",\"categories\":[]},{\"id\":2,\"label\":\"worker\",\"status\":\"halted\",\"pid\":-1,\"description\":\"\",\"docs\":\"\",\"categories\":[]}]}\n";
+const test_api_running_line = "{\"type\":\"snapshot\",\"protocol_version\":1,\"current_process_id\":1,\"exiting\":false,\"ui\":{},\"processes\":[{\"id\":1,\"label\":\"api\",\"status\":\"running\",\"pid\":42,\"description\":\"\",\"docs\":\"\",\"categories\":[]},{\"id\":2,\"label\":\"worker\",\"status\":\"halted\",\"pid\":-1,\"description\":\"\",\"docs\":\"\",\"categories\":[]}]}\n";
+
+/// Broadcast snapshots carry a sequence number the provider's line lacks, so
+/// compare the state they describe rather than the bytes.
+fn expectFullSnapshot(expected_line: []const u8, actual_line: []const u8) !void {
+    var expected = try protocol.parseSnapshotLine(std.testing.allocator, expected_line);
+    defer expected.deinit();
+    var actual = try protocol.parseSnapshotLine(std.testing.allocator, actual_line);
+    defer actual.deinit();
+    try std.testing.expect(actual.seq() != null);
+
+    const expected_state = try protocol.snapshotLine(std.testing.allocator, expected.snapshot());
+    defer std.testing.allocator.free(expected_state);
+    const actual_state = try protocol.snapshotLine(std.testing.allocator, actual.snapshot());
+    defer std.testing.allocator.free(actual_state);
+    try std.testing.expectEqualStrings(expected_state, actual_state);
+}
+
 fn waitForOnlyWorkerFinished(broadcaster: *Broadcaster) !void {
     var attempts: usize = 0;
     while (attempts < 200) : (attempts += 1) {
@@ -553,6 +1351,7 @@ const SuccessCommandHandler = struct {
 
 const StaticSnapshotProvider = struct {
     line: []const u8,
+    calls: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
 
     fn provider(self: *StaticSnapshotProvider) interfaces.SnapshotProvider {
         return .{
@@ -563,6 +1362,7 @@ const StaticSnapshotProvider = struct {
 
     fn snapshotLine(context: *anyopaque, allocator: std.mem.Allocator) anyerror![]const u8 {
         const self: *StaticSnapshotProvider = @ptrCast(@alignCast(context));
+        _ = self.calls.fetchAdd(1, .seq_cst);
         return allocator.dupe(u8, self.line);
     }
 };
@@ -581,3 +1381,13 @@ fn unusedHandleCommand(
 ) anyerror!protocol.Response {
     unreachable;
 }
+
+fn expectFailure(stream: std.net.Stream, request_id: u64, code: protocol.ErrorCode) !void {
+    const line = try line_io.readTimeout(std.testing.allocator, stream, 1024, 500);
+    defer std.testing.allocator.free(line);
+    var response = try protocol.parseResponseLine(std.testing.allocator, line);
+    defer response.deinit(std.testing.allocator);
+    try std.testing.expect(!response.success);
+    try std.testing.expectEqual(request_id, response.request_id);
+    try std.testing.expectEqual(code, response.code);
+}
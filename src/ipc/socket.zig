@@ -1,42 +1,258 @@
 //! Project socket path lifecycle.
-//! The socket hash is derived from Project Config so clients find the right Primary Server without a global registry or user-supplied port.
+//! The socket hash is derived from Project Config so clients find the right Primary Server without a global registry or user-supplied port; sockets live in a private directory proctmux creates under the runtime directory, and clients still look in `/tmp` for primaries started by older releases.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
+const protocol = @import("protocol.zig");
+
+const log = std.log.scoped(.ipc);
+
+/// Appended to the socket path for the file holding a `security.token`.
+const token_suffix = ".token";
+/// Random bytes in a token; the file holds them as hex.
+const token_bytes = 32;
 
 pub fn pathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+    const runtime_dir = try config.paths.runtimeDir(allocator, cfg, config.paths.Env.current());
+    defer allocator.free(runtime_dir);
+    const dir = try dirInRuntimeDir(allocator, runtime_dir);
+    defer allocator.free(dir);
+    return pathInDir(allocator, dir, cfg);
+}
+
+/// Returns the directory under `runtime_dir` that holds sockets, locks, and
+/// tokens. proctmux creates it, so it can keep it private without changing
+/// the runtime directory itself; in the shared `/tmp` its name carries the
+/// user id so users do not collide. The caller owns the result.
+pub fn dirInRuntimeDir(allocator: std.mem.Allocator, runtime_dir: []const u8) ![]const u8 {
+    const base = std.mem.trimRight(u8, runtime_dir, "/");
+    if (std.mem.eql(u8, runtime_dir, config.paths.legacy_dir)) {
+        return std.fmt.allocPrint(allocator, "{s}/proctmux-{d}", .{ base, std.posix.geteuid() });
+    }
+    return std.fmt.allocPrint(allocator, "{s}/proctmux", .{base});
+}
+
+/// The path a release without XDG support would have used.
+pub fn legacyPathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+    return pathInDir(allocator, config.paths.legacy_dir, cfg);
+}
+
+fn pathInDir(allocator: std.mem.Allocator, dir: []const u8, cfg: *const config.schema.Config) ![]const u8 {
     const hash = try config.hash.toHash(allocator, cfg);
     defer allocator.free(hash);
 
-    return std.fmt.allocPrint(allocator, "/tmp/proctmux-{s}.socket", .{hash});
+    return std.fmt.allocPrint(allocator, "{s}/proctmux-{s}.socket", .{ std.mem.trimRight(u8, dir, "/"), hash });
 }
 
-/// Computes and clears the socket path a Primary Server is about to bind.
-/// Removing a stale file here keeps startup deterministic after crashes.
-pub fn createPathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+/// A Primary Server's hold on its socket path. The exclusive lock on
+/// `<socket>.lock` is dropped by the kernel however the server exits, so a
+/// leftover socket next to an unlocked lock file is known to be stale.
+pub const Claim = struct {
+    path: []const u8,
+    lock_path: []const u8,
+    lock_file: std.fs.File,
+    /// Set when `security.token` is on; clients must present it.
+    token: ?[]const u8 = null,
+
+    /// Removes the socket, token, and lock file, then drops the lock.
+    /// Unlinking while still locked is what lets a waiting claimant notice it
+    /// locked a dead inode and retry.
+    pub fn release(self: Claim, allocator: std.mem.Allocator) void {
+        if (self.token) |token| {
+            var buffer: [std.fs.max_path_bytes]u8 = undefined;
+            if (tokenPath(&buffer, self.path)) |token_path| {
+                std.fs.deleteFileAbsolute(token_path) catch {};
+            } else |_| {}
+            allocator.free(token);
+        }
+        std.fs.deleteFileAbsolute(self.path) catch {};
+        std.fs.deleteFileAbsolute(self.lock_path) catch {};
+        self.lock_file.close();
+        allocator.free(self.lock_path);
+        allocator.free(self.path);
+    }
+};
+
+pub const ClaimOptions = struct {
+    /// SIGTERM a live owner and wait for it to exit instead of refusing.
+    takeover: bool = false,
+    takeover_timeout_ms: u64 = 15_000,
+};
+
+/// Claims the socket path a Primary Server is about to bind. A live owner
+/// makes this fail with `error.PrimaryAlreadyRunning` unless `takeover` is
+/// set; a stale socket left by a crash is removed.
+pub fn claimPathForConfig(
+    allocator: std.mem.Allocator,
+    cfg: *const config.schema.Config,
+    options: ClaimOptions,
+) !Claim {
     const path = try pathForConfig(allocator, cfg);
-    errdefer allocator.free(path);
+    var claim = blk: {
+        errdefer allocator.free(path);
+        try makePrivateDir(std.fs.path.dirname(path).?);
+        break :blk try claimPath(allocator, path, options);
+    };
+    errdefer claim.release(allocator);
+    claim.token = try publishToken(allocator, path, cfg.security.token);
+    return claim;
+}
+
+/// Creates the socket directory with mode 0700, along with a configured
+/// `runtime_dir` that does not exist yet. A socket directory that already
+/// exists must belong to this user and be private: proctmux never changes the
+/// permissions of a directory it did not create.
+pub fn makePrivateDir(dir_path: []const u8) !void {
+    if (std.fs.path.dirname(dir_path)) |parent| try std.fs.cwd().makePath(parent);
+    std.posix.mkdir(dir_path, 0o700) catch |err| switch (err) {
+        error.PathAlreadyExists => {},
+        else => return err,
+    };
+    var dir = try std.fs.openDirAbsolute(dir_path, .{ .no_follow = true });
+    defer dir.close();
+    const stat = try std.posix.fstat(dir.fd);
+    if (stat.uid != std.posix.geteuid() or stat.mode & 0o077 != 0) {
+        log.warn("socket directory {s} must belong to this user with mode 0700", .{dir_path});
+        return error.InsecureSocketDir;
+    }
+}
+
+/// Writes a fresh token next to `path` when `enabled`, readable only by this
+/// user. Any token a previous primary left is removed first, so clients stop
+/// sending one once the setting is turned off. The caller owns the result.
+fn publishToken(allocator: std.mem.Allocator, path: []const u8, enabled: bool) !?[]const u8 {
+    var buffer: [std.fs.max_path_bytes]u8 = undefined;
+    const token_path = try tokenPath(&buffer, path);
+    std.fs.deleteFileAbsolute(token_path) catch |err| switch (err) {
+        error.FileNotFound => {},
+        else => return err,
+    };
+    if (!enabled) return null;
+
+    var bytes: [token_bytes]u8 = undefined;
+    std.crypto.random.bytes(&bytes);
+    const hex = std.fmt.bytesToHex(bytes, .lower);
+    const file = try std.fs.createFileAbsolute(token_path, .{ .mode = 0o600, .exclusive = true });
+    defer file.close();
+    try file.writeAll(&hex);
+    return try allocator.dupe(u8, &hex);
+}
+
+fn tokenPath(buffer: []u8, path: []const u8) ![]const u8 {
+    return std.fmt.bufPrint(buffer, "{s}" ++ token_suffix, .{path});
+}
+
+/// Connects to the primary at `path`, first sending the token it left next
+/// to its socket when it requires one.
+pub fn connect(allocator: std.mem.Allocator, path: []const u8) !std.net.Stream {
+    var stream = try std.net.connectUnixSocket(path);
+    errdefer stream.close();
+
+    var buffer: [std.fs.max_path_bytes]u8 = undefined;
+    const token = std.fs.cwd().readFileAlloc(allocator, try tokenPath(&buffer, path), token_bytes * 2) catch |err| switch (err) {
+        error.FileNotFound => return stream,
+        else => return err,
+    };
+    defer allocator.free(token);
+    const line = try protocol.authLine(allocator, token);
+    defer allocator.free(line);
+    try stream.writeAll(line);
+    return stream;
+}
+
+/// Takes ownership of `path` on success.
+pub fn claimPath(allocator: std.mem.Allocator, path: []const u8, options: ClaimOptions) !Claim {
+    const lock_path = try std.fmt.allocPrint(allocator, "{s}.lock", .{path});
+    errdefer allocator.free(lock_path);
 
+    const lock_file = try acquireLock(lock_path, options);
+    errdefer lock_file.close();
+
+    // The lock rules out another proctmux with lock files, but only a probe
+    // can tell whether something else is still answering on the socket.
+    if (probePath(path)) |_| {
+        return error.PrimaryAlreadyRunning;
+    } else |_| {}
     std.fs.deleteFileAbsolute(path) catch |err| switch (err) {
         error.FileNotFound => {},
         else => return err,
     };
 
-    return path;
+    var pid_buffer: [16]u8 = undefined;
+    const pid_text = try std.fmt.bufPrint(&pid_buffer, "{d}\n", .{std.c.getpid()});
+    try lock_file.setEndPos(0);
+    try lock_file.pwriteAll(pid_text, 0);
+
+    return .{ .path = path, .lock_path = lock_path, .lock_file = lock_file };
+}
+
+fn acquireLock(lock_path: []const u8, options: ClaimOptions) !std.fs.File {
+    const deadline = std.time.milliTimestamp() + @as(i64, @intCast(options.takeover_timeout_ms));
+    var signalled = false;
+    while (true) {
+        const file = try std.fs.createFileAbsolute(lock_path, .{ .read = true, .truncate = false, .mode = 0o600 });
+        const locked = file.tryLock(.exclusive) catch |err| {
+            file.close();
+            return err;
+        };
+        if (locked) {
+            if (isCurrentLockFile(file, lock_path)) return file;
+            // The previous owner unlinked this file on release; lock the new one.
+            file.close();
+            continue;
+        }
+
+        defer file.close();
+        if (!options.takeover) return error.PrimaryAlreadyRunning;
+        if (!signalled) {
+            try signalOwner(file);
+            signalled = true;
+        }
+        if (std.time.milliTimestamp() >= deadline) return error.TakeoverTimeout;
+        sleepMs(50);
+    }
+}
+
+fn isCurrentLockFile(file: std.fs.File, lock_path: []const u8) bool {
+    const held = file.stat() catch return false;
+    const current = std.fs.cwd().statFile(lock_path) catch return false;
+    return held.inode == current.inode;
+}
+
+/// Asks the owning primary to shut down gracefully; it releases the lock once
+/// its processes are stopped.
+fn signalOwner(lock_file: std.fs.File) !void {
+    var buffer: [16]u8 = undefined;
+    const n = try lock_file.preadAll(&buffer, 0);
+    const pid = std.fmt.parseInt(std.posix.pid_t, std.mem.trim(u8, buffer[0..n], " \n"), 10) catch return error.TakeoverFailed;
+    if (pid <= 0) return error.TakeoverFailed;
+    std.posix.kill(pid, std.posix.SIG.TERM) catch return error.TakeoverFailed;
 }
 
 /// Computes and verifies the socket path for clients. A successful return means
-/// the file exists and accepts a probe connection.
+/// the file exists and accepts a probe connection. A primary still listening
+/// on the legacy `/tmp` path is found too, so upgrading does not strand it.
 pub fn getPathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
     const path = try pathForConfig(allocator, cfg);
     errdefer allocator.free(path);
 
-    try std.fs.accessAbsolute(path, .{});
-    try probePath(path);
+    verifyPath(path) catch |err| {
+        const legacy_path = try legacyPathForConfig(allocator, cfg);
+        errdefer allocator.free(legacy_path);
+        if (std.mem.eql(u8, legacy_path, path)) return err;
+        verifyPath(legacy_path) catch return err;
+        allocator.free(path);
+        return legacy_path;
+    };
 
     return path;
 }
 
+fn verifyPath(path: []const u8) !void {
+    try std.fs.accessAbsolute(path, .{});
+    try probePath(path);
+}
+
 /// Waits for a Primary Server to create its socket during startup, polling the
 /// same probe used by `getPathForConfig`.
 pub fn waitPathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
@@ -75,3 +291,80 @@ pub fn probePath(path: []const u8) !void {
 fn sleepMs(ms: u64) void {
     std.Thread.sleep(ms * std.time.ns_per_ms);
 }
+
+test "socket claims refuse live owners and clear stale sockets" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(dir_path);
+    const path = try std.fs.path.join(std.testing.allocator, &.{ dir_path, "primary.socket" });
+    defer std.testing.allocator.free(path);
+
+    // A socket file with nobody listening is what a crashed primary leaves.
+    {
+        const address = try std.net.Address.initUnix(path);
+        var listener = try address.listen(.{});
+        listener.deinit();
+    }
+
+    const claim = try claimPath(std.testing.allocator, try std.testing.allocator.dupe(u8, path), .{});
+    defer claim.release(std.testing.allocator);
+    try std.testing.expectError(error.FileNotFound, std.fs.accessAbsolute(path, .{}));
+
+    const second_path = try std.testing.allocator.dupe(u8, path);
+    defer std.testing.allocator.free(second_path);
+    try std.testing.expectError(error.PrimaryAlreadyRunning, claimPath(std.testing.allocator, second_path, .{}));
+}
+
+test "socket claims keep their own directory private and publish a token when asked" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makeDir("run");
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, "run");
+    defer std.testing.allocator.free(dir_path);
+    try std.posix.fchmodat(std.posix.AT.FDCWD, dir_path, 0o755, 0);
+    const socket_dir = try dirInRuntimeDir(std.testing.allocator, dir_path);
+    defer std.testing.allocator.free(socket_dir);
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.runtime_dir = dir_path;
+    cfg.security.token = true;
+
+    const claim = try claimPathForConfig(std.testing.allocator, &cfg, .{});
+    var buffer: [std.fs.max_path_bytes]u8 = undefined;
+    const token_path = try tokenPath(&buffer, claim.path);
+    {
+        defer claim.release(std.testing.allocator);
+        try std.testing.expectEqual(@as(usize, token_bytes * 2), claim.token.?.len);
+
+        try std.testing.expectEqualStrings(socket_dir, std.fs.path.dirname(claim.path).?);
+        const socket_dir_stat = try std.fs.cwd().statFile(socket_dir);
+        try std.testing.expectEqual(@as(std.fs.File.Mode, 0o700), socket_dir_stat.mode & 0o777);
+        const dir_stat = try std.fs.cwd().statFile(dir_path);
+        try std.testing.expectEqual(@as(std.fs.File.Mode, 0o755), dir_stat.mode & 0o777);
+        const token_file = try std.fs.openFileAbsolute(token_path, .{});
+        defer token_file.close();
+        try std.testing.expectEqual(@as(std.fs.File.Mode, 0o600), (try token_file.stat()).mode & 0o777);
+    }
+    try std.testing.expectError(error.FileNotFound, std.fs.accessAbsolute(token_path, .{}));
+}
+
+test "socket claims refuse an existing socket directory others can open" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("run/proctmux");
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, "run");
+    defer std.testing.allocator.free(dir_path);
+    const socket_dir = try tmp.dir.realpathAlloc(std.testing.allocator, "run/proctmux");
+    defer std.testing.allocator.free(socket_dir);
+    try std.posix.fchmodat(std.posix.AT.FDCWD, socket_dir, 0o755, 0);
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.runtime_dir = dir_path;
+
+    try std.testing.expectError(error.InsecureSocketDir, claimPathForConfig(std.testing.allocator, &cfg, .{}));
+    const stat = try std.fs.cwd().statFile(socket_dir);
+    try std.testing.expectEqual(@as(std.fs.File.Mode, 0o755), stat.mode & 0o777);
+}
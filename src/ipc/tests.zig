@@ -6,6 +6,7 @@ const domain = @import("../domain/root.zig");
 const config = @import("../config/root.zig");
 const protocol = @import("protocol.zig");
 const client = @import("client.zig");
+const line_io = @import("line.zig");
 const server = @import("server.zig");
 const test_config = @import("../test_support/config.zig");
 const test_ipc = @import("../test_support/ipc.zig");
@@ -87,6 +88,37 @@ test "one-shot command server handles clean command request" {
     try std.testing.expect(authorizer.called);
 }
 
+test "one-shot command server answers a malformed request with a failure" {
+    const path = "/tmp/proctmux-zig-clean-ipc-malformed-command-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+
+    var handler = test_ipc.FakeCommandHandler{};
+    var authorizer = test_ipc.FakePeerAuthorizer{};
+    const thread = try std.Thread.spawn(.{}, server.serveOneCommandAtPathWithAuthorizer, .{
+        std.testing.allocator,
+        path,
+        handler.handler(),
+        authorizer.authorizer(),
+    });
+    defer thread.join();
+    test_ipc.waitForSocketFile(path);
+
+    var stream = try std.net.connectUnixSocket(path);
+    defer stream.close();
+    try stream.writeAll("{\"type\":\"command\",\"protocol_version\":1,\"request_id\":9,\"action\":\"dance\"}\n");
+
+    const line = try line_io.readTimeout(std.testing.allocator, stream, 1024, 1000);
+    defer std.testing.allocator.free(line);
+    var response = try protocol.parseResponseLine(std.testing.allocator, line);
+    defer response.deinit(std.testing.allocator);
+
+    try std.testing.expect(!response.success);
+    try std.testing.expectEqual(@as(u64, 9), response.request_id);
+    try std.testing.expectEqual(protocol.ErrorCode.invalid_request, response.code);
+    try std.testing.expectEqual(@as(usize, 0), handler.call_count);
+}
+
 test "snapshot client reads initial snapshot" {
     const path = "/tmp/proctmux-zig-clean-ipc-snapshot-test.socket";
     std.fs.deleteFileAbsolute(path) catch {};
@@ -118,3 +150,161 @@ test "snapshot client reads initial snapshot" {
     try std.testing.expectEqual(@as(u32, 2), snapshot.current_process_id);
     try std.testing.expectEqualStrings("api", snapshot.processes[0].label);
 }
+
+test "token authorizer admits clients that present the primary's token" {
+    const path = "/tmp/proctmux-zig-clean-ipc-token-test.socket";
+    const token_path = path ++ ".token";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(token_path) catch {};
+
+    var handler = test_ipc.FakeCommandHandler{};
+    var provider = test_ipc.FakeSnapshotProvider{ .line = test_ipc.selectedApiSnapshotLine };
+    var peer = test_ipc.FakePeerAuthorizer{};
+    var token_authorizer = server.TokenAuthorizer{ .token = "s3cret", .inner = peer.authorizer() };
+    var stopped = std.atomic.Value(bool).init(false);
+    const thread = try std.Thread.spawn(.{}, server.serveCommandsAtPathWithSnapshotsAndAuthorizer, .{
+        std.testing.allocator,
+        path,
+        handler.handler(),
+        provider.provider(),
+        &stopped,
+        token_authorizer.authorizer(),
+    });
+    defer {
+        stopped.store(true, .seq_cst);
+        test_ipc.unblockServer(path);
+        thread.join();
+    }
+    test_ipc.waitForSocketFile(path);
+
+    try std.fs.cwd().writeFile(.{ .sub_path = token_path, .data = "s3cret" });
+    {
+        var ipc_client = try client.Client.connect(std.testing.allocator, path);
+        defer ipc_client.deinit();
+        var update = try ipc_client.readSnapshot();
+        defer update.deinit();
+        try std.testing.expectEqualStrings("api", update.snapshot().processes[0].label);
+    }
+    try std.testing.expect(peer.called);
+
+    try std.fs.cwd().writeFile(.{ .sub_path = token_path, .data = "guess" });
+    var rejected = try client.Client.connect(std.testing.allocator, path);
+    defer rejected.deinit();
+    try std.testing.expectError(error.EndOfStream, rejected.readSnapshot());
+}
+
+test "a client that never sends its token does not hold up the next one" {
+    const path = "/tmp/proctmux-zig-clean-ipc-token-stall-test.socket";
+    const token_path = path ++ ".token";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(token_path) catch {};
+
+    var handler = test_ipc.FakeCommandHandler{};
+    var provider = test_ipc.FakeSnapshotProvider{ .line = test_ipc.selectedApiSnapshotLine };
+    var peer = test_ipc.FakePeerAuthorizer{};
+    var token_authorizer = server.TokenAuthorizer{ .token = "s3cret", .inner = peer.authorizer() };
+    var stopped = std.atomic.Value(bool).init(false);
+    const thread = try std.Thread.spawn(.{}, server.serveCommandsAtPathWithSnapshotsAndAuthorizer, .{
+        std.testing.allocator,
+        path,
+        handler.handler(),
+        provider.provider(),
+        &stopped,
+        token_authorizer.authorizer(),
+    });
+    defer {
+        stopped.store(true, .seq_cst);
+        test_ipc.unblockServer(path);
+        thread.join();
+    }
+    test_ipc.waitForSocketFile(path);
+
+    var silent = try std.net.connectUnixSocket(path);
+    defer silent.close();
+
+    try std.fs.cwd().writeFile(.{ .sub_path = token_path, .data = "s3cret" });
+    const started_ms = std.time.milliTimestamp();
+    var ipc_client = try client.Client.connect(std.testing.allocator, path);
+    defer ipc_client.deinit();
+    var update = try ipc_client.readSnapshot();
+    defer update.deinit();
+    try std.testing.expectEqualStrings("api", update.snapshot().processes[0].label);
+    try std.testing.expect(std.time.milliTimestamp() - started_ms < 1000);
+}
+
+test "snapshot client reconnects and resyncs after the server restarts" {
+    const path = "/tmp/proctmux-zig-clean-ipc-reconnect-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+
+    const address = try std.net.Address.initUnix(path);
+    var listener = try address.listen(.{});
+    defer listener.deinit();
+
+    var server_result = test_ipc.ServerErrorCapture{};
+    const thread = try std.Thread.spawn(.{}, test_ipc.runSnapshotLineServer, .{
+        &listener,
+        &server_result,
+        test_ipc.selectedApiSnapshotLine,
+        2,
+    });
+    defer thread.join();
+
+    var ipc_client = try client.Client.connect(std.testing.allocator, path);
+    defer ipc_client.deinit();
+
+    var first = try ipc_client.readSnapshot();
+    first.deinit();
+    const lost = ipc_client.readSnapshot();
+    try std.testing.expectError(error.EndOfStream, lost);
+    try std.testing.expect(client.isDisconnect(error.EndOfStream));
+
+    try ipc_client.reconnect(path);
+    var resynced = try ipc_client.readSnapshot();
+    defer resynced.deinit();
+    try std.testing.expectEqualStrings("api", resynced.snapshot().processes[0].label);
+    if (server_result.err) |err| return err;
+}
+
+test "snapshot client skips truncated and interleaved lines" {
+    const path = "/tmp/proctmux-zig-clean-ipc-garbled-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+
+    const address = try std.net.Address.initUnix(path);
+    var listener = try address.listen(.{});
+    defer listener.deinit();
+
+    const garbled = "not json\n" ++
+        "{\"type\":\"snapshot\",\"protocol_version\":1,\"processes\":[{\"id\":2,\n" ++
+        "{\"type\":\"ping\",\"protocol_version\":1,\"seq\":1}{\"type\":\"ping\",\"protocol_version\":1,\"seq\":2}\n" ++
+        test_ipc.selectedApiSnapshotLine;
+    var server_result = test_ipc.ServerErrorCapture{};
+    const thread = try std.Thread.spawn(.{}, test_ipc.runSnapshotLineServer, .{
+        &listener,
+        &server_result,
+        garbled,
+        1,
+    });
+    defer thread.join();
+
+    var ipc_client = try client.Client.connect(std.testing.allocator, path);
+    defer ipc_client.deinit();
+
+    var update = try ipc_client.readSnapshot();
+    defer update.deinit();
+    try std.testing.expectEqualStrings("api", update.snapshot().processes[0].label);
+    try std.testing.expectError(error.EndOfStream, ipc_client.readSnapshot());
+    if (server_result.err) |err| return err;
+}
+
+test "reconnect backoff doubles up to its cap" {
+    var backoff = client.Backoff{ .initial_ms = 100, .max_ms = 500 };
+    try std.testing.expectEqual(@as(u64, 100), backoff.nextDelayMs());
+    try std.testing.expectEqual(@as(u64, 200), backoff.nextDelayMs());
+    try std.testing.expectEqual(@as(u64, 400), backoff.nextDelayMs());
+    try std.testing.expectEqual(@as(u64, 500), backoff.nextDelayMs());
+    try std.testing.expectEqual(@as(u64, 500), backoff.nextDelayMs());
+}
@@ -0,0 +1,349 @@
+//! Process-wide log sink behind `std.log`.
+//! Every subsystem logs through a scoped logger (`ipc`, `process`, `primary`, `unified`, `viewer`, `tui`, `terminal`); this module applies the configured `log_level` at runtime and writes text or JSON lines to `log_file`, rotating it by size, or stderr when unset.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const threads = @import("../threads/root.zig");
+
+const max_message = 1024;
+// Worst case every message byte is a control character escaped as `\u00XX`.
+const max_line = max_message * 6 + 256;
+const default_max_size_mb = 10;
+const default_max_backups = 3;
+
+var mutex: std.Thread.Mutex = .{};
+var min_level: std.log.Level = .info;
+var format: config.schema.LogFormat = .text;
+var sink: ?std.fs.File = null;
+var sink_path_buffer: [std.fs.max_path_bytes]u8 = undefined;
+var sink_path: []const u8 = "";
+/// Bytes in the current log file, tracked so rotation needs no stat per line.
+var sink_size: u64 = 0;
+var rotation: Rotation = .{};
+/// The gzip of the newest backup, joined before the next rotation and on
+/// `reset` so no compression outlives logging.
+var compress_thread: ?std.Thread = null;
+
+/// Size-based rotation of `log_file`: `<log>` becomes `<log>.1`, older
+/// backups shift up, and the oldest past `max_backups` is deleted.
+const Rotation = struct {
+    /// 0 never rotates.
+    max_bytes: u64 = 0,
+    max_backups: u32 = default_max_backups,
+    compress: bool = false,
+
+    fn fromConfig(cfg: *const config.schema.Config) Rotation {
+        const size_mb: u64 = if (cfg.log_max_size_mb < 0)
+            0
+        else if (cfg.log_max_size_mb == 0)
+            default_max_size_mb
+        else
+            @intCast(cfg.log_max_size_mb);
+        return .{
+            .max_bytes = size_mb * 1024 * 1024,
+            .max_backups = if (cfg.log_max_backups > 0) @intCast(cfg.log_max_backups) else default_max_backups,
+            .compress = cfg.log_compress,
+        };
+    }
+};
+
+/// Applies the Project Config's logging settings. The loader has already
+/// validated `log_level` and `log_format`, so unknown names only reach here
+/// from hand-built configs.
+pub fn configure(cfg: *const config.schema.Config) !void {
+    const level = if (cfg.log_level.len > 0)
+        toStdLevel(std.meta.stringToEnum(config.schema.LogLevel, cfg.log_level) orelse return error.InvalidLogLevel)
+    else
+        .info;
+    const line_format = if (cfg.log_format.len > 0)
+        std.meta.stringToEnum(config.schema.LogFormat, cfg.log_format) orelse return error.InvalidLogFormat
+    else
+        .text;
+
+    if (cfg.log_file.len > sink_path_buffer.len) return error.NameTooLong;
+    var file: ?std.fs.File = null;
+    var size: u64 = 0;
+    if (cfg.log_file.len > 0) {
+        const opened = try std.fs.cwd().createFile(cfg.log_file, .{ .truncate = false });
+        errdefer opened.close();
+        try opened.seekFromEnd(0);
+        size = try opened.getEndPos();
+        file = opened;
+    }
+
+    mutex.lock();
+    defer mutex.unlock();
+    if (sink) |previous| previous.close();
+    sink = file;
+    @memcpy(sink_path_buffer[0..cfg.log_file.len], cfg.log_file);
+    sink_path = sink_path_buffer[0..cfg.log_file.len];
+    sink_size = size;
+    rotation = Rotation.fromConfig(cfg);
+    min_level = level;
+    format = line_format;
+}
+
+/// Closes the log file and restores stderr text logging at `info`.
+pub fn reset() void {
+    mutex.lock();
+    if (sink) |previous| previous.close();
+    sink = null;
+    sink_path = "";
+    sink_size = 0;
+    rotation = .{};
+    min_level = .info;
+    format = .text;
+    const compressing = compress_thread;
+    compress_thread = null;
+    mutex.unlock();
+    // gzip never takes the mutex, but joining outside it keeps logging live.
+    if (compressing) |thread| thread.join();
+}
+
+/// `std.Options.logFn` for the binary. The compile-time level stays at
+/// `debug` so the runtime `log_level` is the only filter.
+pub fn logFn(
+    comptime level: std.log.Level,
+    comptime scope: @Type(.enum_literal),
+    comptime message_format: []const u8,
+    args: anytype,
+) void {
+    var message_buffer: [max_message]u8 = undefined;
+    var line_buffer: [max_line]u8 = undefined;
+
+    mutex.lock();
+    defer mutex.unlock();
+    if (@intFromEnum(level) > @intFromEnum(min_level)) return;
+
+    var message_writer = std.Io.Writer.fixed(&message_buffer);
+    // Overlong messages are truncated rather than dropped.
+    message_writer.print(message_format, args) catch {};
+
+    const line = formatLine(&line_buffer, format, std.time.milliTimestamp(), level, @tagName(scope), message_writer.buffered());
+    const file = sink orelse std.fs.File.stderr();
+    file.writeAll(line) catch {};
+    if (sink == null) return;
+    sink_size += line.len;
+    if (rotation.max_bytes > 0 and sink_size >= rotation.max_bytes) rotateLocked();
+}
+
+/// Rotates the log file; the caller holds `mutex`. Failures keep logging to
+/// whatever file is still open, since losing log lines is worse than an
+/// oversized file.
+fn rotateLocked() void {
+    // Backups are about to shift, so the previous gzip must finish first.
+    if (compress_thread) |previous| {
+        previous.join();
+        compress_thread = null;
+    }
+    var from_buffer: [std.fs.max_path_bytes]u8 = undefined;
+    var to_buffer: [std.fs.max_path_bytes]u8 = undefined;
+    var index = rotation.max_backups;
+    while (index > 0) : (index -= 1) {
+        for ([_][]const u8{ "", ".gz" }) |suffix| {
+            const from = std.fmt.bufPrint(&from_buffer, "{s}.{d}{s}", .{ sink_path, index, suffix }) catch return;
+            if (index == rotation.max_backups) {
+                std.fs.cwd().deleteFile(from) catch {};
+                continue;
+            }
+            const to = std.fmt.bufPrint(&to_buffer, "{s}.{d}{s}", .{ sink_path, index + 1, suffix }) catch return;
+            std.fs.cwd().rename(from, to) catch {};
+        }
+    }
+
+    const first = std.fmt.bufPrint(&to_buffer, "{s}.1", .{sink_path}) catch return;
+    std.fs.cwd().rename(sink_path, first) catch return;
+    const reopened = std.fs.cwd().createFile(sink_path, .{ .truncate = false }) catch return;
+    if (sink) |previous| previous.close();
+    sink = reopened;
+    sink_size = 0;
+
+    if (rotation.compress) {
+        var backup = Backup{};
+        @memcpy(backup.buffer[0..first.len], first);
+        backup.len = first.len;
+        compress_thread = threads.spawn(.{}, compressBackup, .{backup}) catch null;
+    }
+}
+
+const Backup = struct {
+    buffer: [std.fs.max_path_bytes]u8 = undefined,
+    len: usize = 0,
+};
+
+/// Runs `gzip` so a slow compression never holds up logging. A missing
+/// `gzip` leaves the backup uncompressed.
+fn compressBackup(backup: Backup) void {
+    const allocator = std.heap.page_allocator;
+    const result = std.process.Child.run(.{
+        .allocator = allocator,
+        .argv = &.{ "gzip", "-f", backup.buffer[0..backup.len] },
+    }) catch return;
+    allocator.free(result.stdout);
+    allocator.free(result.stderr);
+}
+
+/// Best-effort panic report with a stack trace. The mutex is skipped because
+/// the panicking thread may already hold it. Returns the log path written, or
+/// null when logging goes to stderr.
+pub fn writePanic(message: []const u8, first_trace_addr: ?usize) ?[]const u8 {
+    const file = sink orelse return null;
+    var buffer: [1024]u8 = undefined;
+    var file_writer = file.writerStreaming(&buffer);
+    const writer = &file_writer.interface;
+
+    writeTimestamp(writer, std.time.milliTimestamp()) catch return null;
+    writer.print(" panic: {s}\n", .{message}) catch return null;
+    if (std.debug.getSelfDebugInfo()) |debug_info| {
+        std.debug.writeCurrentStackTrace(writer, debug_info, .no_color, first_trace_addr) catch {};
+    } else |_| {}
+    writer.flush() catch return null;
+    return sink_path;
+}
+
+fn toStdLevel(level: config.schema.LogLevel) std.log.Level {
+    return switch (level) {
+        .debug => .debug,
+        .info => .info,
+        .warn => .warn,
+        .@"error" => .err,
+    };
+}
+
+fn formatLine(
+    buffer: []u8,
+    line_format: config.schema.LogFormat,
+    now_ms: i64,
+    level: std.log.Level,
+    scope: []const u8,
+    message: []const u8,
+) []const u8 {
+    var writer = std.Io.Writer.fixed(buffer);
+    writeLine(&writer, line_format, now_ms, level, scope, message) catch {};
+    return writer.buffered();
+}
+
+fn writeLine(
+    writer: *std.Io.Writer,
+    line_format: config.schema.LogFormat,
+    now_ms: i64,
+    level: std.log.Level,
+    scope: []const u8,
+    message: []const u8,
+) !void {
+    switch (line_format) {
+        .text => {
+            try writeTimestamp(writer, now_ms);
+            try writer.print(" {s}({s}): {s}\n", .{ level.asText(), scope, message });
+        },
+        .json => {
+            try writer.writeAll("{\"time\":\"");
+            try writeTimestamp(writer, now_ms);
+            try writer.print("\",\"level\":\"{s}\",\"scope\":\"", .{level.asText()});
+            try writeJsonEscaped(writer, scope);
+            try writer.writeAll("\",\"msg\":\"");
+            try writeJsonEscaped(writer, message);
+            try writer.writeAll("\"}\n");
+        },
+    }
+}
+
+fn writeTimestamp(writer: *std.Io.Writer, now_ms: i64) !void {
+    const ms: u64 = @intCast(@max(now_ms, 0));
+    const epoch = std.time.epoch.EpochSeconds{ .secs = ms / std.time.ms_per_s };
+    const year_day = epoch.getEpochDay().calculateYearDay();
+    const month_day = year_day.calculateMonthDay();
+    const day_seconds = epoch.getDaySeconds();
+    try writer.print("{d:0>4}-{d:0>2}-{d:0>2}T{d:0>2}:{d:0>2}:{d:0>2}.{d:0>3}Z", .{
+        year_day.year,
+        month_day.month.numeric(),
+        month_day.day_index + 1,
+        day_seconds.getHoursIntoDay(),
+        day_seconds.getMinutesIntoHour(),
+        day_seconds.getSecondsIntoMinute(),
+        ms % std.time.ms_per_s,
+    });
+}
+
+fn writeJsonEscaped(writer: *std.Io.Writer, text: []const u8) !void {
+    for (text) |byte| switch (byte) {
+        '"' => try writer.writeAll("\\\""),
+        '\\' => try writer.writeAll("\\\\"),
+        '\n' => try writer.writeAll("\\n"),
+        '\r' => try writer.writeAll("\\r"),
+        '\t' => try writer.writeAll("\\t"),
+        0...8, 11, 12, 14...0x1f => try writer.print("\\u{x:0>4}", .{byte}),
+        else => try writer.writeByte(byte),
+    };
+}
+
+test "log lines render as text and JSON" {
+    var buffer: [max_line]u8 = undefined;
+    const now_ms: i64 = 1_760_000_000_123;
+
+    try std.testing.expectEqualStrings(
+        "2025-10-09T08:53:20.123Z warning(ipc): client \"a\" dropped\n",
+        formatLine(&buffer, .text, now_ms, .warn, "ipc", "client \"a\" dropped"),
+    );
+    try std.testing.expectEqualStrings(
+        "{\"time\":\"2025-10-09T08:53:20.123Z\",\"level\":\"warning\",\"scope\":\"ipc\",\"msg\":\"client \\\"a\\\" dropped\\n\\u001b\"}\n",
+        formatLine(&buffer, .json, now_ms, .warn, "ipc", "client \"a\" dropped\n\x1b"),
+    );
+}
+
+test "logging configure validates names and opens the log file" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(dir_path);
+    const log_path = try std.fs.path.join(std.testing.allocator, &.{ dir_path, "proctmux.log" });
+    defer std.testing.allocator.free(log_path);
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.log_level = "loud";
+    try std.testing.expectError(error.InvalidLogLevel, configure(&cfg));
+
+    cfg.log_level = "debug";
+    cfg.log_format = "json";
+    cfg.log_file = log_path;
+    try configure(&cfg);
+    defer reset();
+
+    try std.testing.expectEqual(std.log.Level.debug, min_level);
+    try std.testing.expectEqual(config.schema.LogFormat.json, format);
+    try std.testing.expect(sink != null);
+}
+
+test "log file rotates by size and keeps the configured backups" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir_path = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(dir_path);
+    const log_path = try std.fs.path.join(std.testing.allocator, &.{ dir_path, "proctmux.log" });
+    defer std.testing.allocator.free(log_path);
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.log_file = log_path;
+    cfg.log_max_backups = 2;
+    try configure(&cfg);
+    defer reset();
+    try std.testing.expectEqual(@as(u64, 10 * 1024 * 1024), rotation.max_bytes);
+
+    // A tiny limit makes every line rotate.
+    rotation.max_bytes = 1;
+    inline for (.{ "first", "second", "third" }) |message| {
+        logFn(.info, .process, message, .{});
+    }
+
+    var buffer: [256]u8 = undefined;
+    try std.testing.expectEqualStrings("", try tmp.dir.readFile("proctmux.log", &buffer));
+    try std.testing.expect(std.mem.endsWith(u8, try tmp.dir.readFile("proctmux.log.1", &buffer), "info(process): third\n"));
+    try std.testing.expect(std.mem.endsWith(u8, try tmp.dir.readFile("proctmux.log.2", &buffer), "info(process): second\n"));
+    try std.testing.expectError(error.FileNotFound, tmp.dir.access("proctmux.log.3", .{}));
+
+    cfg.log_max_size_mb = -1;
+    try configure(&cfg);
+    try std.testing.expectEqual(@as(u64, 0), rotation.max_bytes);
+}
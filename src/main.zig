@@ -1,13 +1,20 @@
 //! Binary entrypoint.
-//! All substantial startup behavior is delegated to `app` so this file only owns allocator setup, logging setup, and process exit mapping.
+//! All substantial startup behavior is delegated to `app` so this file only owns allocator setup, logging setup, panic handling, and process exit mapping.
 
 const std = @import("std");
 const app = @import("app/root.zig");
+const logging = @import("logging/root.zig");
+const terminal = @import("terminal/root.zig");
 
 pub const std_options: std.Options = .{
-    .log_level = .info,
+    .log_level = .debug,
+    .logFn = logging.logFn,
 };
 
+pub const panic = std.debug.FullPanic(handlePanic);
+
+var panicking = std.atomic.Value(bool).init(false);
+
 pub fn main() !void {
     var debug_allocator = std.heap.DebugAllocator(.{}){};
     defer _ = debug_allocator.deinit();
@@ -38,6 +45,26 @@ pub fn main() !void {
     };
 }
 
+/// Hands the terminal back before reporting: a panic while stdin is raw or a
+/// child holds the alternate screen would otherwise leave the shell unusable.
+/// With a log file the full trace goes there and stderr gets one short line.
+fn handlePanic(message: []const u8, first_trace_addr: ?usize) noreturn {
+    if (panicking.swap(true, .seq_cst)) std.debug.defaultPanic(message, first_trace_addr);
+
+    terminal.mode.restoreForPanic();
+    const stdout = std.fs.File.stdout();
+    if (stdout.isTty()) stdout.writeAll(terminal.repaint.panic_restore) catch {};
+
+    const log_path = logging.writePanic(message, first_trace_addr) orelse
+        std.debug.defaultPanic(message, first_trace_addr);
+
+    var buffer: [256]u8 = undefined;
+    var stderr = std.fs.File.stderr().writerStreaming(&buffer);
+    stderr.interface.print("proctmux crashed: {s}\nStack trace written to {s}\n", .{ message, log_path }) catch {};
+    stderr.interface.flush() catch {};
+    std.posix.abort();
+}
+
 fn writeFile(context: *anyopaque, bytes: []const u8) anyerror!void {
     const file: *std.fs.File = @ptrCast(@alignCast(context));
     try file.writeAll(bytes);
@@ -4,10 +4,17 @@
 const std = @import("std");
 const config = @import("../config/root.zig");
 const ipc = @import("../ipc/root.zig");
+const logging = @import("../logging/root.zig");
 const terminal = @import("../terminal/root.zig");
 const tui = @import("../tui/root.zig");
 const io = @import("io.zig");
 
+const log = std.log.scoped(.tui);
+
+// Wake at least this often so a silent primary shows up in the connection
+// health indicator without waiting for a keypress.
+const health_check_interval_ms: i32 = 1000;
+
 pub fn run(
     allocator: std.mem.Allocator,
     dir: std.fs.Dir,
@@ -17,6 +24,8 @@ pub fn run(
 ) !void {
     var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
     defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
 
     const socket_path = ipc.socket.getPathForConfig(allocator, &loaded.config) catch
         try ipc.socket.waitPathForConfig(allocator, &loaded.config);
@@ -30,6 +39,10 @@ pub fn run(
         tui.client_session.IpcTransport.transport(&ipc_client),
     );
     defer session.deinit();
+    try session.model.addKeybindingConflicts(loaded.warnings.items);
+    const pins_path = try tui.pin_state.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(pins_path);
+    try session.restorePins(pins_path);
 
     try output.writeAll(terminal.repaint.hide_cursor);
     defer output.writeAll(terminal.repaint.show_cursor) catch {};
@@ -37,7 +50,7 @@ pub fn run(
     try render(&session, output);
 
     if (input.fd) |input_fd| {
-        try pollLoop(&session, &ipc_client, input, input_fd, output);
+        try pollLoop(&session, &ipc_client, socket_path, input, input_fd, output);
         return;
     }
 
@@ -58,13 +71,19 @@ fn inputLoop(
 fn pollLoop(
     session: *tui.client_session.ClientSession,
     ipc_client: *ipc.client.Client,
+    socket_path: []const u8,
     input: io.Input,
     input_fd: std.posix.fd_t,
     output: io.Output,
 ) !void {
     var buffer: [64]u8 = undefined;
     while (true) {
-        if (try readAvailableSnapshotUpdate(session, ipc_client)) {
+        const updated = readAvailableSnapshotUpdate(session, ipc_client) catch |err| {
+            if (!ipc.client.isDisconnect(err)) return err;
+            if (try reconnect(session, ipc_client, socket_path, input, input_fd, output)) return;
+            continue;
+        };
+        if (updated or updateConnectionHealth(session, ipc_client)) {
             try render(session, output);
             continue;
         }
@@ -82,28 +101,93 @@ fn pollLoop(
             },
         };
 
-        const ready = try std.posix.poll(&poll_fds, -1);
+        const now_ms = std.time.milliTimestamp();
+        const switch_timeout_ms = session.pendingSwitchTimeoutMs(now_ms) orelse health_check_interval_ms;
+        const expiry_timeout_ms = session.model.messageExpiryTimeoutMs(now_ms) orelse health_check_interval_ms;
+        const timeout_ms = @min(switch_timeout_ms, expiry_timeout_ms, health_check_interval_ms);
+        const ready = try std.posix.poll(&poll_fds, timeout_ms);
+        _ = try session.flushPendingSwitch(std.time.milliTimestamp());
+        if (session.model.expireMessages(std.time.milliTimestamp())) try render(session, output);
         if (ready == 0) continue;
 
-        if ((poll_fds[1].revents & std.posix.POLL.IN) != 0) {
-            if (try readAvailableSnapshotUpdate(session, ipc_client)) try render(session, output);
-        }
-
+        // Socket events are picked up by the read at the top of the loop,
+        // which also notices a hang-up.
         if ((poll_fds[0].revents & std.posix.POLL.IN) != 0) {
             if (try handleInput(session, input, output, &buffer)) return;
         }
     }
 }
 
+/// Keeps the TUI usable while the primary is gone: the last snapshot stays on
+/// screen under a banner, keys still work locally, and connection attempts
+/// back off until a primary answers. The new primary's initial snapshot
+/// replaces all process state. Returns true when the user quits meanwhile.
+fn reconnect(
+    session: *tui.client_session.ClientSession,
+    ipc_client: *ipc.client.Client,
+    socket_path: []const u8,
+    input: io.Input,
+    input_fd: std.posix.fd_t,
+    output: io.Output,
+) !bool {
+    ipc_client.close();
+    session.model.disconnected = true;
+    try render(session, output);
+
+    var backoff = ipc.client.Backoff{};
+    var buffer: [64]u8 = undefined;
+    while (true) {
+        var poll_fds = [_]std.posix.pollfd{.{ .fd = input_fd, .events = std.posix.POLL.IN, .revents = 0 }};
+        const ready = try std.posix.poll(&poll_fds, @intCast(backoff.nextDelayMs()));
+        if (ready > 0 and (poll_fds[0].revents & std.posix.POLL.IN) != 0) {
+            if (try handleInput(session, input, output, &buffer)) return true;
+            continue;
+        }
+
+        ipc_client.reconnect(socket_path) catch |err| {
+            log.debug("reconnect to primary failed: {s}", .{@errorName(err)});
+            continue;
+        };
+        session.readSnapshotUpdate() catch |err| {
+            log.debug("resync after reconnect failed: {s}", .{@errorName(err)});
+            ipc_client.close();
+            continue;
+        };
+        session.model.disconnected = false;
+        session.model.connection_stale_s = 0;
+        try render(session, output);
+        return false;
+    }
+}
+
 fn readAvailableSnapshotUpdate(
     session: *tui.client_session.ClientSession,
     ipc_client: *ipc.client.Client,
 ) !bool {
-    const update = (try ipc_client.readLatestSnapshotIfAvailable()) orelse return false;
+    const update = (try ipc_client.readLatestSnapshotIfAvailable()) orelse {
+        try ipc_client.checkHeartbeat(std.time.milliTimestamp());
+        return false;
+    };
     try session.applySnapshotUpdate(update);
     return true;
 }
 
+/// Marks the connection stale once the primary has missed a heartbeat, before
+/// the timeout forces a reconnect. Returns true when the indicator changed.
+fn updateConnectionHealth(
+    session: *tui.client_session.ClientSession,
+    ipc_client: *const ipc.client.Client,
+) bool {
+    const silence_ms = ipc_client.silenceMs(std.time.milliTimestamp());
+    const stale_s: u32 = if (silence_ms > ipc.protocol.heartbeat_interval_ms + health_check_interval_ms)
+        @intCast(@divTrunc(silence_ms, std.time.ms_per_s))
+    else
+        0;
+    if (stale_s == session.model.connection_stale_s) return false;
+    session.model.connection_stale_s = stale_s;
+    return true;
+}
+
 fn handleInput(
     session: *tui.client_session.ClientSession,
     input: io.Input,
@@ -145,8 +229,14 @@ fn render(session: *tui.client_session.ClientSession, output: io.Output) !void {
     defer session.allocator.free(rendered);
     try io.appendTextClearingLineTails(&frame, rendered, terminal.repaint.clear_line_tail);
     try frame.appendSlice(terminal.repaint.end_frame);
+    if (session.model.takeBell()) try frame.appendSlice(terminal.repaint.bell);
+    if (session.takeClipboardWrite()) |sequence| {
+        defer session.allocator.free(sequence);
+        try frame.appendSlice(sequence);
+    }
 
     try output.writeAll(frame.items);
+    session.recordFrame();
 }
 
 pub fn renderText(session: *tui.client_session.ClientSession) ![]const u8 {
@@ -0,0 +1,179 @@
+//! Config picker for interactive starts without a Project Config.
+//! Instead of failing with `ConfigFileNotFound`, the user picks one of the recently used configs or a directory below the working directory that has one.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const test_io = @import("../test_support/io.zig");
+const io = @import("io.zig");
+
+/// One keypress selects, so the list stops at nine.
+pub const max_choices = 9;
+/// How far below the working directory to look for configs.
+const max_scan_depth = 3;
+/// Directories that hold dependencies or build output rather than projects.
+const skipped_dirs = [_][]const u8{ "node_modules", "vendor", "target", "zig-out", "dist", "build" };
+
+/// Absolute config paths to offer, recent ones first. Owns its strings.
+pub const Choices = struct {
+    paths: std.array_list.Managed([]const u8),
+
+    pub fn deinit(self: *Choices) void {
+        for (self.paths.items) |path| self.paths.allocator.free(path);
+        self.paths.deinit();
+    }
+
+    fn add(self: *Choices, path: []const u8) !void {
+        if (self.paths.items.len == max_choices) return;
+        for (self.paths.items) |existing| {
+            if (std.mem.eql(u8, existing, path)) return;
+        }
+        try self.paths.append(try self.paths.allocator.dupe(u8, path));
+    }
+};
+
+/// Offers the choices for `dir` and returns the picked config path, owned by
+/// the caller, or null when the user quits. Fails with `ConfigFileNotFound`
+/// when there is nothing to offer.
+pub fn run(allocator: std.mem.Allocator, dir: std.fs.Dir, input: io.Input, output: io.Output) !?[]const u8 {
+    const recent_path = try config.recent.pathFor(allocator, config.paths.Env.current());
+    defer allocator.free(recent_path);
+    const recent = try config.recent.load(allocator, std.fs.cwd(), recent_path);
+    defer recent.deinit();
+
+    var choices = try collect(allocator, dir, recent.paths);
+    defer choices.deinit();
+    if (choices.paths.items.len == 0) return config.load.NotFoundError.ConfigFileNotFound;
+
+    const index = (try pick(choices.paths.items, input, output)) orelse return null;
+    return try allocator.dupe(u8, choices.paths.items[index]);
+}
+
+/// Recent configs that still exist, then configs found below `dir` in name
+/// order, skipping hidden and dependency directories.
+pub fn collect(allocator: std.mem.Allocator, dir: std.fs.Dir, recent_paths: []const []const u8) !Choices {
+    var choices = Choices{ .paths = std.array_list.Managed([]const u8).init(allocator) };
+    errdefer choices.deinit();
+
+    for (recent_paths) |path| {
+        std.fs.cwd().access(path, .{}) catch continue;
+        try choices.add(path);
+    }
+    // `std.fs.cwd()` cannot be iterated directly.
+    var root = dir.openDir(".", .{ .iterate = true }) catch return choices;
+    defer root.close();
+    try scan(allocator, &choices, root, 1);
+    return choices;
+}
+
+fn scan(allocator: std.mem.Allocator, choices: *Choices, dir: std.fs.Dir, depth: usize) !void {
+    var names = std.array_list.Managed([]const u8).init(allocator);
+    defer {
+        for (names.items) |name| allocator.free(name);
+        names.deinit();
+    }
+    var iterator = dir.iterate();
+    while (iterator.next() catch return) |entry| {
+        if (entry.kind != .directory or entry.name[0] == '.' or isSkipped(entry.name)) continue;
+        try names.append(try allocator.dupe(u8, entry.name));
+    }
+    std.mem.sort([]const u8, names.items, {}, lessThanString);
+
+    for (names.items) |name| {
+        if (choices.paths.items.len == max_choices) return;
+        var child = dir.openDir(name, .{ .iterate = true }) catch continue;
+        defer child.close();
+        if (defaultFileName(child)) |file_name| {
+            const path = child.realpathAlloc(allocator, file_name) catch continue;
+            defer allocator.free(path);
+            try choices.add(path);
+        }
+        if (depth < max_scan_depth) try scan(allocator, choices, child, depth + 1);
+    }
+}
+
+/// Lists `paths` numbered from 1 and waits for a digit. `q`, Esc, Ctrl-C,
+/// or the end of input quits.
+pub fn pick(paths: []const []const u8, input: io.Input, output: io.Output) !?usize {
+    try output.writeAll("No proctmux config in this directory. Recent and nearby configs:\n\n");
+    var buffer: [std.fs.max_path_bytes + 16]u8 = undefined;
+    for (paths, 1..) |path, number| {
+        try output.writeAll(try std.fmt.bufPrint(&buffer, "  {d}) {s}\n", .{ number, path }));
+    }
+    try output.writeAll(try std.fmt.bufPrint(&buffer, "\nPick 1-{d}, or q to quit: ", .{paths.len}));
+
+    var key: [1]u8 = undefined;
+    while (true) {
+        if (try input.readBytes(&key) == 0) break;
+        switch (key[0]) {
+            '1'...'9' => {
+                const index = key[0] - '1';
+                if (index >= paths.len) continue;
+                try output.writeAll(key[0..]);
+                try output.writeAll("\n");
+                return index;
+            },
+            'q', 'Q', 0x03, 0x1b => break,
+            else => {},
+        }
+    }
+    try output.writeAll("\n");
+    return null;
+}
+
+fn defaultFileName(dir: std.fs.Dir) ?[]const u8 {
+    for (config.load.default_file_names) |name| {
+        dir.access(name, .{}) catch continue;
+        return name;
+    }
+    return null;
+}
+
+fn isSkipped(name: []const u8) bool {
+    for (skipped_dirs) |skipped| {
+        if (std.mem.eql(u8, name, skipped)) return true;
+    }
+    return false;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+
+test "config picker offers existing recent configs, then nearby ones in name order" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const allocator = std.testing.allocator;
+
+    try tmp.dir.makePath("web");
+    try tmp.dir.writeFile(.{ .sub_path = "web/proctmux.yaml", .data = "{}\n" });
+    try tmp.dir.makePath("api/deep");
+    try tmp.dir.writeFile(.{ .sub_path = "api/deep/procmux.yml", .data = "{}\n" });
+    try tmp.dir.makePath("node_modules/pkg");
+    try tmp.dir.writeFile(.{ .sub_path = "node_modules/pkg/proctmux.yaml", .data = "{}\n" });
+    try tmp.dir.makePath(".git");
+    try tmp.dir.writeFile(.{ .sub_path = ".git/proctmux.yaml", .data = "{}\n" });
+
+    const web = try tmp.dir.realpathAlloc(allocator, "web/proctmux.yaml");
+    defer allocator.free(web);
+    const api = try tmp.dir.realpathAlloc(allocator, "api/deep/procmux.yml");
+    defer allocator.free(api);
+
+    var choices = try collect(allocator, tmp.dir, &.{ "/nonexistent/proctmux.yaml", web });
+    defer choices.deinit();
+    try std.testing.expectEqual(@as(usize, 2), choices.paths.items.len);
+    try std.testing.expectEqualStrings(web, choices.paths.items[0]);
+    try std.testing.expectEqualStrings(api, choices.paths.items[1]);
+}
+
+test "config picker selects by number and quits on q" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var chosen = test_io.BytesInput{ .data = "x92" };
+    try std.testing.expectEqual(@as(?usize, 1), try pick(&.{ "/a/proctmux.yaml", "/b/proctmux.yaml" }, chosen.reader(), test_io.TestOutput.writer(&out)));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "  2) /b/proctmux.yaml\n") != null);
+    try std.testing.expect(std.mem.endsWith(u8, out.items, "Pick 1-2, or q to quit: 2\n"));
+
+    var quit = test_io.BytesInput{ .data = "q1" };
+    try std.testing.expectEqual(@as(?usize, null), try pick(&.{"/a/proctmux.yaml"}, quit.reader(), test_io.TestOutput.writer(&out)));
+}
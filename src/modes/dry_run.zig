@@ -0,0 +1,72 @@
+//! Dry Run Runtime Mode.
+//! This mode loads and validates Project Config with the same launch options a primary would use, then prints the command, cwd, and environment of each process that would autostart, in start order, without starting anything or opening a socket.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const ipc = @import("../ipc/root.zig");
+const primary_mod = @import("../primary/root.zig");
+const proc = @import("../proc/root.zig");
+const io = @import("io.zig");
+
+pub fn run(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    launch: config.launch.Options,
+    output: io.Output,
+) !void {
+    var loaded = try config.runtime.loadInDirWithOptions(allocator, dir, config_file, launch);
+    defer loaded.deinit();
+
+    var state = try domain.state.AppState.init(allocator, &loaded.config);
+    defer state.deinit();
+
+    const socket_path = try ipc.socket.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(socket_path);
+    const inherited_cwd = try dir.realpathAlloc(allocator, ".");
+    defer allocator.free(inherited_cwd);
+    var parent_env = try std.process.getEnvMap(allocator);
+    defer parent_env.deinit();
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    defer out.deinit();
+    try out.writer().print("config: {s}\n", .{loaded.config.file_path});
+
+    var index: usize = 0;
+    for (state.processes.items) |*process| {
+        if (!process.config.autostart) continue;
+        defer index += 1;
+        try out.append('\n');
+        try out.writer().print("{d}. {s}", .{ index + 1, process.label });
+        const delay_ms = primary_mod.schedule.autostartDelayMs(&loaded.config, process.config, index);
+        if (delay_ms > 0) try out.writer().print(" (after {d}ms)", .{delay_ms});
+        try out.append('\n');
+
+        const spec = (try proc.builder.buildCommand(allocator, process.config, &loaded.config)) orelse {
+            try out.appendSlice("   command: (none; the process would fail to start)\n");
+            continue;
+        };
+        defer spec.deinit(allocator);
+        const command = try primary_mod.details.quoteArgv(allocator, spec.argv);
+        defer allocator.free(command);
+        try out.writer().print("   command: {s}\n", .{command});
+        try out.writer().print("   cwd: {s}\n", .{if (spec.cwd.len > 0) spec.cwd else inherited_cwd});
+
+        var inspection = try proc.env.inspect(allocator, &parent_env, process.config, .{
+            .label = process.label,
+            .id = process.id,
+            .socket_path = socket_path,
+            .config_path = loaded.config.file_path,
+        });
+        defer inspection.deinit();
+        // Inherited variables are left out; they match proctmux's own.
+        try out.appendSlice("   env:\n");
+        for (inspection.variables) |variable| {
+            if (variable.source == .inherited) continue;
+            try out.writer().print("     {s}={s}\n", .{ variable.name, variable.value });
+        }
+    }
+    if (index == 0) try out.appendSlice("\nno processes would autostart\n");
+    try output.writeAll(out.items);
+}
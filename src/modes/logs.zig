@@ -0,0 +1,28 @@
+//! Logs Runtime Mode adapter.
+//! This mode loads Project Config, locates the Primary Server socket, and delegates output printing to the logs command module.
+
+const std = @import("std");
+const commands = @import("../commands/root.zig");
+const config = @import("../config/root.zig");
+const logging = @import("../logging/root.zig");
+const io = @import("io.zig");
+
+pub fn run(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    args: []const []const u8,
+    output: io.Output,
+) !void {
+    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
+
+    try commands.logs.runWithConfig(
+        allocator,
+        &loaded.config,
+        args,
+        .{ .context = output.context, .write = output.write },
+    );
+}
@@ -5,10 +5,17 @@ const std = @import("std");
 const config = @import("../config/root.zig");
 const domain = @import("../domain/root.zig");
 const ipc = @import("../ipc/root.zig");
+const logging = @import("../logging/root.zig");
 const primary_mod = @import("../primary/root.zig");
+const terminal = @import("../terminal/root.zig");
+const threads = @import("../threads/root.zig");
 const io = @import("io.zig");
 
-const log = std.log.scoped(.primary_mode);
+const log = std.log.scoped(.primary);
+
+/// The output relay sleeps until process state changes; SIGWINCH cannot wake
+/// it, so resizes are checked at least this often.
+const resize_check_ms: u64 = 250;
 
 /// Runs the standalone Primary Mode until the shared stop flag is raised.
 /// Terminal raw-mode cleanup is kept in this mode because stdin is forwarded to PTYs.
@@ -16,41 +23,94 @@ pub fn runUntilStopped(
     allocator: std.mem.Allocator,
     dir: std.fs.Dir,
     config_file: []const u8,
+    launch: config.launch.Options,
+    takeover: bool,
     input: io.Input,
     output: io.Output,
     stopped: *std.atomic.Value(bool),
 ) !void {
-    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    var loaded = try config.runtime.loadInDirWithOptions(allocator, dir, config_file, launch);
     defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
 
-    const socket_path = try ipc.socket.createPathForConfig(allocator, &loaded.config);
-    defer allocator.free(socket_path);
-    defer std.fs.deleteFileAbsolute(socket_path) catch {};
+    const claim = try ipc.socket.claimPathForConfig(allocator, &loaded.config, .{
+        .takeover = takeover,
+        // The old primary may use its whole shutdown budget before exiting.
+        .takeover_timeout_ms = primary_mod.shutdownTimeoutMs(&loaded.config) + 2000,
+    });
+    defer claim.release(allocator);
+    const socket_path = claim.path;
 
     var primary_server = try primary_mod.Server.init(allocator, &loaded.config);
     defer primary_server.deinit();
+    primary_server.ipc_token = claim.token;
+    // Runs after every worker thread is joined, before the socket is removed.
+    defer primary_server.shutdown();
+
+    const metrics_address = if (loaded.config.metrics_addr.len > 0)
+        try primary_mod.metrics.parseAddress(loaded.config.metrics_addr)
+    else
+        null;
+    const metrics_thread = if (metrics_address) |address|
+        try threads.spawn(.{}, primary_mod.metrics.run, .{ allocator, &primary_server, address, stopped })
+    else
+        null;
+    defer if (metrics_thread) |thread| {
+        stopped.store(true, .seq_cst);
+        primary_mod.metrics.unblock(metrics_address.?);
+        thread.join();
+    };
+
+    if (output.fd != null) {
+        terminal.winch.install();
+        primary_mod.signals.install();
+        config.recent.remember(allocator, loaded.config.file_path) catch |err| {
+            log.warn("could not record recent config: {s}", .{@errorName(err)});
+        };
+    }
+
+    // Joined after the output loop's defer raises `stopped`; the wake ends
+    // the watch.
+    var signal_run = PrimarySignalRun{ .stopped = stopped, .socket_path = socket_path };
+    const signal_thread = try threads.spawn(.{}, watchSignals, .{&signal_run});
+    defer {
+        primary_mod.signals.wake();
+        signal_thread.join();
+    }
 
     var output_run = PrimaryOutputRun{
         .allocator = allocator,
         .primary_server = &primary_server,
         .output = output,
+        .input_fd = input.fd,
         .placeholder = loaded.config.layout.placeholder_banner,
+        .max_relay_bytes_per_tick = @intCast(loaded.config.layout.max_relay_bytes_per_tick),
         .stopped = stopped,
     };
-    const output_thread = try std.Thread.spawn(.{}, runOutputLoop, .{&output_run});
+    const output_thread = try threads.spawn(.{}, runOutputLoop, .{&output_run});
     defer {
         stopped.store(true, .seq_cst);
+        primary_server.controller.changes.notify();
         output_thread.join();
     }
 
+    // Written once on exit so the forwarder's `poll` needs no timeout.
+    const input_stop_fds = try std.posix.pipe2(.{ .NONBLOCK = true, .CLOEXEC = true });
+    defer std.posix.close(input_stop_fds[0]);
+    defer std.posix.close(input_stop_fds[1]);
     var input_run = PrimaryInputRun{
         .input = input,
         .primary_server = &primary_server,
         .stopped = stopped,
         .socket_path = socket_path,
+        .stop_fd = input_stop_fds[0],
     };
-    const input_thread = try std.Thread.spawn(.{}, forwardInput, .{&input_run});
-    defer input_thread.join();
+    const input_thread = try threads.spawn(.{}, forwardInput, .{&input_run});
+    defer {
+        _ = std.posix.system.write(input_stop_fds[1], "s", 1);
+        input_thread.join();
+    }
 
     try primary_server.serveCommandsAtPath(socket_path, stopped);
     try output_run.result.finish();
@@ -73,41 +133,112 @@ const PrimaryOutputRun = struct {
     allocator: std.mem.Allocator,
     primary_server: *primary_mod.Server,
     output: io.Output,
+    input_fd: ?std.posix.fd_t = null,
     placeholder: []const u8,
+    /// Live output written per pass; the rest waits for the next pass so a
+    /// chatty process cannot hold up resizes and selection changes.
+    max_relay_bytes_per_tick: usize,
     stopped: *std.atomic.Value(bool),
     result: ThreadResult = .running,
+    /// Keeps the processes' own window titles out of live output.
+    titles: terminal.title.Filter = .{},
+    /// Set once the host's title has been saved, so exit can put it back.
+    title_pushed: bool = false,
 };
 
 const clear_sequence = "\x1b[2J\x1b[H";
+/// xterm's title stack: saves the host's window title, then restores it.
+const push_title = "\x1b[22;2t";
+const pop_title = "\x1b[23;2t";
+/// Title bytes of the replayed history scanned to pick up where it left off.
+const title_sync_bytes = 4096;
 
 fn runOutputLoop(state: *PrimaryOutputRun) void {
     var last_process_id = domain.process.ProcessId.fromInt(std.math.maxInt(u32));
     var last_process_running = false;
     var emitted_len: usize = 0;
+    // Set while `jump_to_error` holds the screen at the first error.
+    var holding_error = false;
+    const changes = &state.primary_server.controller.changes;
+    var seen = changes.current();
 
     while (!state.stopped.load(.seq_cst)) {
         const process_id = state.primary_server.currentProcessID();
         const process_running = !process_id.isNone() and state.primary_server.controller.isRunning(process_id);
-        if (process_id != last_process_id or process_running != last_process_running) {
-            emitted_len = 0;
-            writeScrollbackSnapshot(state, process_id, &emitted_len, true) catch |err| {
-                state.result = .{ .failed = err };
-                return;
-            };
-            last_process_id = process_id;
-            last_process_running = process_running;
-        } else if (!process_id.isNone()) {
-            writeScrollbackDelta(state, process_id, &emitted_len) catch |err| {
-                state.result = .{ .failed = err };
-                return;
+        const resized = terminal.winch.takePending();
+        if (resized or process_id != last_process_id or process_running != last_process_running) {
+            syncProcessSize(state) catch |err| {
+                log.debug("failed to resize current process terminal: {s}", .{@errorName(err)});
             };
         }
+        const switched = process_id != last_process_id or process_running != last_process_running;
+        if (process_id != last_process_id) writeTitle(state, process_id) catch |err| {
+            log.debug("failed to set the window title: {s}", .{@errorName(err)});
+        };
+        last_process_id = process_id;
+        last_process_running = process_running;
+        if (switched) holding_error = false;
+
+        var redraw = switched;
+        const jump = state.primary_server.takeErrorJump();
+        if (!jump.isNone() and jump == process_id) {
+            if (holding_error) {
+                holding_error = false;
+                redraw = true;
+            } else {
+                holding_error = writeFirstError(state, process_id) catch |err| {
+                    state.result = .{ .failed = err };
+                    return;
+                };
+            }
+        }
+
+        // Set when live output was left for the next pass.
+        var pending = false;
+        // Live output waits while the first error is held on screen.
+        if (!holding_error) {
+            if (redraw) {
+                emitted_len = 0;
+                writeScrollbackSnapshot(state, process_id, &emitted_len, true) catch |err| {
+                    state.result = .{ .failed = err };
+                    return;
+                };
+            } else if (!process_id.isNone()) {
+                pending = writeScrollbackDelta(state, process_id, &emitted_len) catch |err| {
+                    state.result = .{ .failed = err };
+                    return;
+                };
+            }
+        }
 
-        std.Thread.sleep(25 * std.time.ns_per_ms);
+        // Output, exits, and selection changes notify; the timeout only
+        // bounds how long a resize goes unnoticed.
+        if (!pending) seen = changes.wait(seen, resize_check_ms);
     }
+    if (state.title_pushed) state.output.writeAll(pop_title) catch {};
     state.result = .completed;
 }
 
+/// Names the host terminal or tmux pane after the selected process. Output
+/// that is not a terminal has no title to set.
+fn writeTitle(state: *PrimaryOutputRun, process_id: domain.process.ProcessId) !void {
+    if (state.output.fd == null) return;
+    var out = std.array_list.Managed(u8).init(state.allocator);
+    defer out.deinit();
+    if (!state.title_pushed) try out.appendSlice(push_title);
+    const label = if (state.primary_server.getState().getProcessByID(process_id)) |process| process.label else "";
+    try terminal.title.set(&out, label);
+    try state.output.writeAll(out.items);
+    state.title_pushed = true;
+}
+
+/// Propagates the relay terminal size to the selected process. Output without a
+/// real terminal keeps processes at their configured size.
+fn syncProcessSize(state: *PrimaryOutputRun) !void {
+    const size = terminal.dimensions.probe(state.output.fd, state.input_fd) orelse return;
+    try state.primary_server.resizeCurrentProcess(@intCast(size.height), @intCast(size.width));
+}
+
 fn writePlaceholder(output: io.Output, placeholder: []const u8) !void {
     const text = std.mem.trim(u8, placeholder, " \t\r\n");
     if (text.len == 0) {
@@ -139,19 +270,21 @@ fn writeScrollbackSnapshot(
     }
 
     if (clear) try state.output.writeAll(clear_sequence);
-    try state.output.writeAll(bytes);
+    try writeReplay(state, bytes);
     emitted_len.* = bytes.len;
 }
 
+/// Writes output added since the last pass, at most `max_relay_bytes_per_tick`
+/// of it. Returns true when more is still waiting.
 fn writeScrollbackDelta(
     state: *PrimaryOutputRun,
     process_id: domain.process.ProcessId,
     emitted_len: *usize,
-) !void {
+) !bool {
     const bytes = state.primary_server.controller.getScrollback(state.allocator, process_id) catch |err| switch (err) {
         error.ProcessNotFound => {
             if (emitted_len.* != 0) try writeStoppedPlaceholder(state.output, state.placeholder, emitted_len, true);
-            return;
+            return false;
         },
         else => return err,
     };
@@ -161,29 +294,141 @@ fn writeScrollbackDelta(
         if (emitted_len.* != 0) try writeStoppedPlaceholder(state.output, state.placeholder, emitted_len, true);
     } else if (bytes.len < emitted_len.* or emitted_len.* == 0) {
         try state.output.writeAll(clear_sequence);
-        try state.output.writeAll(bytes);
+        try writeReplay(state, bytes);
     } else if (bytes.len > emitted_len.*) {
-        try state.output.writeAll(bytes[emitted_len.*..]);
+        const end = @min(bytes.len, emitted_len.* + state.max_relay_bytes_per_tick);
+        var out = std.array_list.Managed(u8).init(state.allocator);
+        defer out.deinit();
+        try state.titles.strip(&out, bytes[emitted_len.*..end]);
+        try state.output.writeAll(out.items);
+        emitted_len.* = end;
+        return end < bytes.len;
     }
     emitted_len.* = bytes.len;
+    return false;
+}
+
+/// Shows retained output from the first line matching `error_patterns`, with a
+/// footer on the last row. Returns false, leaving the screen as it was, when
+/// no retained line matches.
+fn writeFirstError(state: *PrimaryOutputRun, process_id: domain.process.ProcessId) !bool {
+    const bytes = state.primary_server.controller.getScrollback(state.allocator, process_id) catch |err| switch (err) {
+        error.ProcessNotFound => return false,
+        else => return err,
+    };
+    defer state.allocator.free(bytes);
+    const start = state.primary_server.error_scanner.firstError(bytes) orelse return false;
+
+    const size = terminal.dimensions.fromFds(state.output.fd, state.input_fd);
+    const rows: usize = @intCast(@max(size.height, 2) - 1);
+    var end = start;
+    var lines: usize = 0;
+    while (end < bytes.len and lines < rows) : (lines += 1) {
+        end = if (std.mem.indexOfScalarPos(u8, bytes, end, '\n')) |index| index + 1 else bytes.len;
+    }
+
+    try state.output.writeAll(clear_sequence);
+    try writeReplay(state, std.mem.trimRight(u8, bytes[start..end], "\r\n"));
+    var footer_buf: [32]u8 = undefined;
+    try state.output.writeAll(try std.fmt.bufPrint(&footer_buf, "\x1b[{d};1H\x1b[7m", .{rows + 1}));
+    try state.output.writeAll(" first error; press the jump key again to follow output \x1b[0m");
+    return true;
+}
+
+/// Redraws retained output from emulator state instead of replaying raw bytes,
+/// so cursor movement, progress lines, and alternate-screen programs come back
+/// as one consistent screen.
+fn writeReplay(state: *PrimaryOutputRun, bytes: []const u8) !void {
+    const size = terminal.dimensions.fromFds(state.output.fd, state.input_fd);
+    const replay = try renderReplay(state.allocator, bytes, @intCast(size.width), @intCast(size.height));
+    defer state.allocator.free(replay);
+    try state.output.writeAll(replay);
+    try syncTitles(state, bytes);
+}
+
+/// The screen `bytes` leave behind, colors included. Live deltas then continue
+/// from the emulator cursor and pen, with the cursor shown or hidden and shaped
+/// as the process last left it rather than as the previously viewed one did.
+fn renderReplay(allocator: std.mem.Allocator, bytes: []const u8, cols: u16, rows: u16) ![]const u8 {
+    var emulator = try terminal.ghostty_vt.Terminal.init(allocator, cols, rows);
+    defer emulator.deinit();
+    try emulator.write(bytes);
+
+    const text = try emulator.renderStyled(allocator);
+    defer allocator.free(text);
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    var first = true;
+    while (lines.next()) |line| {
+        if (!first) try out.appendSlice("\r\n");
+        first = false;
+        try out.appendSlice(line);
+    }
+
+    const cursor = emulator.cursorPosition();
+    try out.writer().print("\x1b[{d};{d}H", .{ cursor.row + 1, cursor.col + 1 });
+    var cursor_buf: [32]u8 = undefined;
+    try out.appendSlice(try terminal.cursor.after(bytes).sequence(&cursor_buf));
+    return out.toOwnedSlice();
+}
+
+/// Live deltas continue where `bytes` ends, possibly inside a title, so the
+/// filter takes up from the end of the replayed history.
+fn syncTitles(state: *PrimaryOutputRun, bytes: []const u8) !void {
+    var discarded = std.array_list.Managed(u8).init(state.allocator);
+    defer discarded.deinit();
+    state.titles.reset();
+    try state.titles.strip(&discarded, bytes[bytes.len -| title_sync_bytes..]);
 }
 
 fn writeStoppedPlaceholder(output: io.Output, placeholder: []const u8, emitted_len: *usize, clear: bool) !void {
     emitted_len.* = 0;
-    if (clear) try output.writeAll(clear_sequence);
+    if (clear) try output.writeAll(clear_sequence ++ terminal.cursor.reset);
     try writePlaceholder(output, placeholder);
 }
 
+const PrimarySignalRun = struct {
+    stopped: *std.atomic.Value(bool),
+    socket_path: []const u8,
+};
+
+fn watchSignals(state: *PrimarySignalRun) void {
+    while (!state.stopped.load(.seq_cst)) {
+        if (primary_mod.signals.waitRequested()) {
+            log.info("termination signal received; stopping processes", .{});
+            state.stopped.store(true, .seq_cst);
+            unblockServer(state.socket_path);
+            return;
+        }
+    }
+}
+
 const PrimaryInputRun = struct {
     input: io.Input,
     primary_server: *primary_mod.Server,
     stopped: *std.atomic.Value(bool),
     socket_path: []const u8,
+    stop_fd: std.posix.fd_t,
 };
 
 fn forwardInput(state: *PrimaryInputRun) void {
     var buffer: [64]u8 = undefined;
     while (!state.stopped.load(.seq_cst)) {
+        // A blocking read would keep shutdown waiting on a keypress.
+        if (state.input.fd) |fd| {
+            var poll_fds = [_]std.posix.pollfd{
+                .{ .fd = fd, .events = std.posix.POLL.IN, .revents = 0 },
+                .{ .fd = state.stop_fd, .events = std.posix.POLL.IN, .revents = 0 },
+            };
+            _ = std.posix.poll(&poll_fds, -1) catch |err| {
+                log.debug("stdin forwarder stopped after poll error: {s}", .{@errorName(err)});
+                return;
+            };
+            if (poll_fds[1].revents != 0) return;
+            if (poll_fds[0].revents == 0) continue;
+        }
         const n = state.input.readBytes(&buffer) catch |err| {
             log.debug("stdin forwarder stopped after read error: {s}", .{@errorName(err)});
             return;
@@ -215,3 +460,11 @@ fn unblockServer(path: []const u8) void {
     };
     stream.close();
 }
+
+test "replay keeps colors and the pen live output continues with" {
+    const replay = try renderReplay(std.testing.allocator, "\x1b[32mok\x1b[0m\r\n\x1b[31merr", 40, 5);
+    defer std.testing.allocator.free(replay);
+
+    try std.testing.expect(std.mem.indexOf(u8, replay, "\x1b[32mok\x1b[0m\r\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, replay, "\x1b[31merr\x1b[0m\x1b[0m\x1b[31m\x1b[2;4H") != null);
+}
@@ -2,13 +2,29 @@
 //! Importers use this root to avoid depending on individual mode file layout.
 
 pub const client = @import("client.zig");
+pub const config_picker = @import("config_picker.zig");
+pub const dry_run = @import("dry_run.zig");
+pub const env = @import("env.zig");
 pub const io = @import("io.zig");
+pub const logs = @import("logs.zig");
 pub const primary = @import("primary.zig");
+pub const rpc = @import("rpc.zig");
+pub const run = @import("run.zig");
 pub const signal = @import("signal.zig");
+pub const snapshot = @import("snapshot.zig");
+pub const status = @import("status.zig");
 
 test {
     _ = client;
+    _ = config_picker;
+    _ = dry_run;
+    _ = env;
     _ = io;
+    _ = logs;
     _ = primary;
+    _ = rpc;
+    _ = run;
     _ = signal;
+    _ = snapshot;
+    _ = status;
 }
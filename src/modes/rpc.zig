@@ -0,0 +1,28 @@
+//! RPC Runtime Mode adapter.
+//! This mode loads Project Config, locates the Primary Server socket, and hands stdin/stdout to the rpc command module.
+
+const std = @import("std");
+const commands = @import("../commands/root.zig");
+const config = @import("../config/root.zig");
+const logging = @import("../logging/root.zig");
+const io = @import("io.zig");
+
+pub fn run(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    input: io.Input,
+    output: io.Output,
+) !void {
+    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
+
+    try commands.rpc.runWithConfig(
+        allocator,
+        &loaded.config,
+        .{ .context = input.context, .read = input.read },
+        .{ .context = output.context, .write = output.write },
+    );
+}
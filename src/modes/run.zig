@@ -0,0 +1,181 @@
+//! Run Runtime Mode.
+//! This mode starts the named processes without a TUI or IPC server, prints their combined output with label prefixes, and fails when any of them exits non-zero, so CI can reuse the Project Config.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const logging = @import("../logging/root.zig");
+const primary_mod = @import("../primary/root.zig");
+const proc = @import("../proc/root.zig");
+const ring = @import("../ring/root.zig");
+const io = @import("io.zig");
+
+/// Starts every process matching `names`, by label or replica group, and
+/// returns once all of them have exited. A stop request or termination signal
+/// stops the ones still running. Returns `error.CommandFailed` when a process
+/// fails to start, exits non-zero, or is stopped early.
+pub fn runUntilStopped(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    launch: config.launch.Options,
+    names: []const []const u8,
+    output: io.Output,
+    stopped: *std.atomic.Value(bool),
+) !void {
+    if (names.len == 0) return error.MissingName;
+
+    var loaded = try config.runtime.loadInDirWithOptions(allocator, dir, config_file, launch);
+    defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
+
+    var state = try domain.state.AppState.init(allocator, &loaded.config);
+    defer state.deinit();
+
+    var targets = std.array_list.Managed(Target).init(allocator);
+    defer {
+        for (targets.items) |*target| target.pending.deinit();
+        targets.deinit();
+    }
+    for (names) |name| {
+        var found = false;
+        for (state.processes.items) |*process| {
+            if (!std.mem.eql(u8, name, process.label) and !std.mem.eql(u8, name, process.config.replica_group)) continue;
+            found = true;
+            if (findTarget(targets.items, process.id) != null) continue;
+            try targets.append(.{ .process = process, .pending = std.array_list.Managed(u8).init(allocator) });
+        }
+        if (!found) {
+            try output.writeAll("unknown process: ");
+            try output.writeAll(name);
+            try output.writeAll("\n");
+            return error.CommandFailed;
+        }
+    }
+
+    var printer = Printer{ .output = output };
+    for (targets.items) |target| printer.width = @max(printer.width, target.process.label.len);
+
+    var controller = proc.controller.Controller.init(allocator, &loaded.config);
+    defer controller.deinit();
+
+    if (output.fd != null) primary_mod.signals.install();
+
+    defer {
+        for (targets.items) |target| {
+            if (target.scrollback) |scrollback| scrollback.removeReader(target.reader_id);
+        }
+    }
+
+    var failed = false;
+    for (targets.items) |*target| {
+        // Subscribe before starting so no early output is missed.
+        target.scrollback = try controller.outputBuffer(target.process.id);
+        target.reader_id = try target.scrollback.?.newReader();
+        _ = controller.startProcess(target.process.id, target.process.config) catch |err| {
+            try printer.status(target.process.label, "failed to start: {s}", .{@errorName(err)});
+            target.done = true;
+            failed = true;
+        };
+    }
+
+    var interrupted = false;
+    while (true) {
+        var running: usize = 0;
+        for (targets.items) |*target| {
+            if (target.done) continue;
+            try printer.drain(allocator, target);
+            const code = controller.exitCode(target.process.id) orelse {
+                running += 1;
+                continue;
+            };
+            // Cleanup joins the output thread, so the last drain sees all output.
+            controller.cleanupProcess(target.process.id) catch {};
+            try printer.drain(allocator, target);
+            try printer.flush(target);
+            try printer.status(target.process.label, "exited with code {d}", .{code});
+            target.done = true;
+            if (code != 0) failed = true;
+        }
+        if (running == 0) break;
+
+        if (!interrupted and (stopped.load(.seq_cst) or primary_mod.signals.takeRequested())) {
+            interrupted = true;
+            for (targets.items) |*target| {
+                if (target.done) continue;
+                controller.stopProcess(target.process.id) catch {};
+                try printer.drain(allocator, target);
+                try printer.flush(target);
+                try printer.status(target.process.label, "stopped", .{});
+                target.done = true;
+            }
+            failed = true;
+            continue;
+        }
+        std.Thread.sleep(25 * std.time.ns_per_ms);
+    }
+
+    if (failed) return error.CommandFailed;
+}
+
+const Target = struct {
+    process: *const domain.process.Process,
+    scrollback: ?*ring.RingBuffer = null,
+    reader_id: usize = 0,
+    /// Output after the last newline, held until the line completes.
+    pending: std.array_list.Managed(u8),
+    done: bool = false,
+};
+
+fn findTarget(targets: []Target, id: domain.process.ProcessId) ?*Target {
+    for (targets) |*target| {
+        if (target.process.id == id) return target;
+    }
+    return null;
+}
+
+/// Writes output line by line behind a `label | ` prefix padded to the
+/// longest label, so interleaved processes stay readable in CI logs.
+const Printer = struct {
+    output: io.Output,
+    width: usize = 0,
+
+    fn drain(self: *Printer, allocator: std.mem.Allocator, target: *Target) !void {
+        const scrollback = target.scrollback orelse return;
+        while (scrollback.readNext(target.reader_id)) |chunk| {
+            defer allocator.free(chunk);
+            try target.pending.appendSlice(chunk);
+        }
+
+        var start: usize = 0;
+        while (std.mem.indexOfScalarPos(u8, target.pending.items, start, '\n')) |end| {
+            try self.line(target.process.label, target.pending.items[start..end]);
+            start = end + 1;
+        }
+        const rest = target.pending.items.len - start;
+        std.mem.copyForwards(u8, target.pending.items[0..rest], target.pending.items[start..]);
+        target.pending.shrinkRetainingCapacity(rest);
+    }
+
+    fn flush(self: *Printer, target: *Target) !void {
+        if (target.pending.items.len == 0) return;
+        try self.line(target.process.label, target.pending.items);
+        target.pending.clearRetainingCapacity();
+    }
+
+    fn status(self: *Printer, label: []const u8, comptime fmt: []const u8, args: anytype) !void {
+        var buffer: [128]u8 = undefined;
+        const text = std.fmt.bufPrint(&buffer, fmt, args) catch fmt;
+        try self.line(label, text);
+    }
+
+    fn line(self: *Printer, label: []const u8, text: []const u8) !void {
+        try self.output.writeAll(label);
+        var padding = label.len;
+        while (padding < self.width) : (padding += 1) try self.output.writeAll(" ");
+        try self.output.writeAll(" | ");
+        try self.output.writeAll(std.mem.trimRight(u8, text, "\r"));
+        try self.output.writeAll("\n");
+    }
+};
@@ -4,6 +4,7 @@
 const std = @import("std");
 const commands = @import("../commands/root.zig");
 const config = @import("../config/root.zig");
+const logging = @import("../logging/root.zig");
 const io = @import("io.zig");
 
 pub fn run(
@@ -16,6 +17,8 @@ pub fn run(
 ) !void {
     var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
     defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
 
     try commands.signal.runWithConfig(
         allocator,
@@ -0,0 +1,28 @@
+//! Status Runtime Mode adapter.
+//! This mode loads Project Config, locates the Primary Server socket, and delegates status formatting to the status command module.
+
+const std = @import("std");
+const commands = @import("../commands/root.zig");
+const config = @import("../config/root.zig");
+const logging = @import("../logging/root.zig");
+const io = @import("io.zig");
+
+pub fn run(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    config_file: []const u8,
+    args: []const []const u8,
+    output: io.Output,
+) !void {
+    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
+
+    try commands.status.runWithConfig(
+        allocator,
+        &loaded.config,
+        args,
+        .{ .context = output.context, .write = output.write },
+    );
+}
@@ -2,11 +2,17 @@
 //! This module converts IPC Process Commands into process lifecycle and selection changes while keeping response construction local to command semantics.
 
 const std = @import("std");
+const crash = @import("crash.zig");
 const domain = @import("../domain/root.zig");
 const ipc = @import("../ipc/root.zig");
+const open = @import("open.zig");
 const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
 
-const log = std.log.scoped(.primary_command_runner);
+const log = std.log.scoped(.primary);
+
+/// Pause between stopping and starting again, so ports and files are let go.
+const restart_pause_ms = 500;
 
 /// Executes Process Commands against Primary-owned state. The runner is kept
 /// concrete instead of callback-heavy so command semantics stay local to the
@@ -15,6 +21,13 @@ pub const Runner = struct {
     state: *domain.state.AppState,
     controller: *proc_mod.controller.Controller,
     current_process_id: *std.atomic.Value(u32),
+    /// Process whose output the relay should hold at its first error; 0 when
+    /// no jump is pending.
+    error_jump: *std.atomic.Value(u32),
+    crashes: *crash.Tracker,
+    /// Set for commands no person asked for, such as plugin replies; these
+    /// may not start a crash-looping process.
+    automatic: bool = false,
 
     /// Handles one decoded IPC command and returns the response that should be
     /// written to the requesting client.
@@ -23,8 +36,9 @@ pub const Runner = struct {
         allocator: std.mem.Allocator,
         request: ipc.protocol.CommandRequest,
     ) !ipc.protocol.Response {
+        if (request.isBatch()) return self.handleBatchRequest(allocator, request);
         return switch (request.action) {
-            .start, .stop, .restart, .switch_process => self.handleNamedRequest(allocator, request),
+            .start, .stop, .restart, .switch_process, .jump_to_error, .open_url, .open_cwd => self.handleNamedRequest(allocator, request),
             .stop_running => self.stopRunningResponse(allocator, request.request_id),
             .restart_running => self.restartRunningResponse(allocator, request.request_id),
         };
@@ -36,51 +50,151 @@ pub const Runner = struct {
         request: ipc.protocol.CommandRequest,
     ) !ipc.protocol.Response {
         const target = request.targetLabel();
-        if (target.len == 0) return errorResponse(allocator, request.request_id, "missing process name");
+        if (target.len == 0) return errorResponse(allocator, request.request_id, .invalid_request, "missing process name");
 
         const target_process = self.state.getProcessByLabel(target) orelse {
+            if (self.hasReplicaGroup(target)) return self.handleGroupRequest(allocator, request, target);
             const message = try std.fmt.allocPrint(allocator, "process not found: {s}", .{target});
             defer allocator.free(message);
-            return errorResponse(allocator, request.request_id, message);
+            return errorResponse(allocator, request.request_id, .not_found, message);
         };
 
-        self.handleNamedProcess(request.action, target_process) catch |err| {
-            return errorResponse(allocator, request.request_id, @errorName(err));
+        self.handleNamedProcess(allocator, request.action, target_process) catch |err| {
+            return errorResponse(allocator, request.request_id, .command_failed, @errorName(err));
         };
         return successResponse(allocator, request.request_id);
     }
 
+    /// Applies a named command to every replica expanded from `group`; a
+    /// switch, jump, or open only acts on the first one.
+    fn handleGroupRequest(
+        self: Runner,
+        allocator: std.mem.Allocator,
+        request: ipc.protocol.CommandRequest,
+        group: []const u8,
+    ) !ipc.protocol.Response {
+        for (self.state.processes.items) |*target_process| {
+            if (!std.mem.eql(u8, target_process.config.replica_group, group)) continue;
+            self.handleNamedProcess(allocator, request.action, target_process) catch |err| {
+                return errorResponse(allocator, request.request_id, .command_failed, @errorName(err));
+            };
+            switch (request.action) {
+                .switch_process, .jump_to_error, .open_url, .open_cwd => break,
+                else => {},
+            }
+        }
+        return successResponse(allocator, request.request_id);
+    }
+
+    /// Applies start, stop, or restart to every process named in
+    /// `request.targets`. Stops run concurrently, so a batch waits for its
+    /// slowest process rather than the sum of them. Nothing runs if any
+    /// label is unknown.
+    fn handleBatchRequest(
+        self: Runner,
+        allocator: std.mem.Allocator,
+        request: ipc.protocol.CommandRequest,
+    ) !ipc.protocol.Response {
+        switch (request.action) {
+            .start, .stop, .restart => {},
+            else => return errorResponse(allocator, request.request_id, .invalid_request, "batch commands support start, stop, and restart"),
+        }
+
+        var targets = std.array_list.Managed(*domain.process.Process).init(allocator);
+        defer targets.deinit();
+        for (request.targets) |label| {
+            if (self.state.getProcessByLabel(label)) |target_process| {
+                try targets.append(target_process);
+                continue;
+            }
+            if (!self.hasReplicaGroup(label)) {
+                const message = try std.fmt.allocPrint(allocator, "process not found: {s}", .{label});
+                defer allocator.free(message);
+                return errorResponse(allocator, request.request_id, .not_found, message);
+            }
+            for (self.state.processes.items) |*target_process| {
+                if (std.mem.eql(u8, target_process.config.replica_group, label)) try targets.append(target_process);
+            }
+        }
+
+        if (request.action != .start) {
+            var stop_runs = std.array_list.Managed(StopProcessRun).init(allocator);
+            defer stop_runs.deinit();
+            for (targets.items) |target_process| {
+                if (!self.controller.isRunning(target_process.id)) continue;
+                try stop_runs.append(.{ .controller = self.controller, .id = target_process.id, .label = target_process.label });
+            }
+            stopProcessesConcurrently(allocator, stop_runs.items);
+            for (stop_runs.items) |stop_run| {
+                const err = stop_run.result orelse continue;
+                return failedProcessResponse(allocator, request.request_id, stop_run.label, err);
+            }
+            if (request.action == .stop) return successResponse(allocator, request.request_id);
+            if (stop_runs.items.len > 0) std.Thread.sleep(restart_pause_ms * std.time.ns_per_ms);
+        }
+
+        for (targets.items) |target_process| {
+            self.startProcess(target_process) catch |err| {
+                return failedProcessResponse(allocator, request.request_id, target_process.label, err);
+            };
+        }
+        return successResponse(allocator, request.request_id);
+    }
+
+    fn hasReplicaGroup(self: Runner, group: []const u8) bool {
+        for (self.state.processes.items) |target_process| {
+            if (std.mem.eql(u8, target_process.config.replica_group, group)) return true;
+        }
+        return false;
+    }
+
     fn handleNamedProcess(
         self: Runner,
+        allocator: std.mem.Allocator,
         action: ipc.protocol.Command,
         target_process: *domain.process.Process,
     ) !void {
         switch (action) {
             .switch_process => self.setCurrentProcess(target_process.id),
+            .jump_to_error => self.requestErrorJump(target_process.id),
+            .open_url => try open.open(allocator, target_process, .url, self.state.config),
+            .open_cwd => try open.open(allocator, target_process, .cwd, self.state.config),
             .start => try self.startProcess(target_process),
             .stop => try self.stopProcess(target_process),
-            .restart => {
-                try self.stopProcess(target_process);
-                std.Thread.sleep(500 * std.time.ns_per_ms);
-                try self.startProcess(target_process);
-            },
+            .restart => try self.restartProcess(target_process),
             else => return error.UnsupportedCommand,
         }
     }
 
+    /// Stops and starts one process; also used by the file watcher.
+    pub fn restartProcess(self: Runner, target_process: *domain.process.Process) !void {
+        try self.crashes.beforeStart(self.controller, target_process, self.automatic, std.time.milliTimestamp());
+        if (self.currentProcessID().isNone()) self.setCurrentProcess(target_process.id);
+        _ = try self.controller.restartProcess(target_process.id, target_process.config, restart_pause_ms);
+    }
+
     fn startProcess(self: Runner, target_process: *domain.process.Process) !void {
         if (self.controller.isRunning(target_process.id)) return;
+        try self.crashes.beforeStart(self.controller, target_process, self.automatic, std.time.milliTimestamp());
         try self.controller.cleanupProcess(target_process.id);
         if (self.currentProcessID().isNone()) self.setCurrentProcess(target_process.id);
         _ = try self.controller.startProcess(target_process.id, target_process.config);
     }
 
-    fn stopProcess(self: Runner, target_process: *domain.process.Process) !void {
+    /// Stops one process if it runs; also used by the idle monitor.
+    pub fn stopProcess(self: Runner, target_process: *domain.process.Process) !void {
         if (!self.controller.isRunning(target_process.id)) return;
-        try self.controller.stopProcess(target_process.id);
+        try stopIgnoringConcurrent(self.controller, target_process.id);
     }
 
     fn stopRunningResponse(self: Runner, allocator: std.mem.Allocator, request_id: u64) !ipc.protocol.Response {
+        try self.stopAll(allocator);
+        return successResponse(allocator, request_id);
+    }
+
+    /// Stops every running process concurrently, each with its own stop signal
+    /// and timeout.
+    pub fn stopAll(self: Runner, allocator: std.mem.Allocator) !void {
         var stop_runs = std.array_list.Managed(StopProcessRun).init(allocator);
         defer stop_runs.deinit();
 
@@ -98,16 +212,12 @@ pub const Runner = struct {
         // process must not prevent stop attempts for the rest.
         stopProcessesConcurrently(allocator, stop_runs.items);
         reportStopFailures(stop_runs.items);
-
-        return successResponse(allocator, request_id);
     }
 
     fn restartRunningResponse(self: Runner, allocator: std.mem.Allocator, request_id: u64) !ipc.protocol.Response {
         for (self.state.processes.items) |*target_process| {
             if (self.controller.isRunning(target_process.id)) {
-                try self.controller.stopProcess(target_process.id);
-                std.Thread.sleep(500 * std.time.ns_per_ms);
-                _ = try self.controller.startProcess(target_process.id, target_process.config);
+                _ = try self.controller.restartProcess(target_process.id, target_process.config, restart_pause_ms);
             }
         }
         return successResponse(allocator, request_id);
@@ -117,6 +227,14 @@ pub const Runner = struct {
         return domain.process.ProcessId.fromInt(self.current_process_id.load(.seq_cst));
     }
 
+    /// Selects `id` and leaves the jump for the output relay, which toggles
+    /// between the first error and live output.
+    fn requestErrorJump(self: Runner, id: domain.process.ProcessId) void {
+        self.setCurrentProcess(id);
+        self.error_jump.store(id.toInt(), .seq_cst);
+        self.controller.changes.notify();
+    }
+
     fn setCurrentProcess(self: Runner, id: domain.process.ProcessId) void {
         self.state.current_proc_id = id;
         self.current_process_id.store(id.toInt(), .seq_cst);
@@ -136,16 +254,16 @@ fn stopProcessesConcurrently(
 ) void {
     if (stop_runs.len == 0) return;
 
-    const threads = allocator.alloc(std.Thread, stop_runs.len) catch |err| {
+    const workers = allocator.alloc(std.Thread, stop_runs.len) catch |err| {
         log.warn("failed to allocate stop-running worker threads; stopping sequentially: {s}", .{@errorName(err)});
         stopProcessesSequentially(stop_runs);
         return;
     };
-    defer allocator.free(threads);
+    defer allocator.free(workers);
 
     var started: usize = 0;
     while (started < stop_runs.len) : (started += 1) {
-        threads[started] = std.Thread.spawn(.{}, stopProcessWorker, .{&stop_runs[started]}) catch |err| {
+        workers[started] = threads.spawn(.{}, stopProcessWorker, .{&stop_runs[started]}) catch |err| {
             log.warn("failed to spawn stop-running worker for {s}; stopping remaining processes sequentially: {s}", .{
                 stop_runs[started].label,
                 @errorName(err),
@@ -156,7 +274,7 @@ fn stopProcessesConcurrently(
         };
     }
 
-    for (threads[0..started]) |thread| thread.join();
+    for (workers[0..started]) |thread| thread.join();
 
     if (started < stop_runs.len) {
         stopProcessesSequentially(stop_runs[started + 1 ..]);
@@ -168,11 +286,20 @@ fn stopProcessesSequentially(stop_runs: []StopProcessRun) void {
 }
 
 fn stopProcessWorker(stop_run: *StopProcessRun) void {
-    stop_run.controller.stopProcess(stop_run.id) catch |err| {
+    stopIgnoringConcurrent(stop_run.controller, stop_run.id) catch |err| {
         stop_run.result = err;
     };
 }
 
+/// A stop that is already in progress elsewhere (a watcher restart, another
+/// client) will finish the job, so it counts as success here.
+fn stopIgnoringConcurrent(controller: *proc_mod.controller.Controller, id: domain.process.ProcessId) !void {
+    controller.stopProcess(id) catch |err| switch (err) {
+        error.ProcessStopping => return,
+        else => return err,
+    };
+}
+
 fn reportStopFailures(stop_runs: []const StopProcessRun) void {
     var failure_count: usize = 0;
     for (stop_runs) |stop_run| {
@@ -199,14 +326,27 @@ fn successResponse(allocator: std.mem.Allocator, request_id: u64) !ipc.protocol.
     };
 }
 
+fn failedProcessResponse(
+    allocator: std.mem.Allocator,
+    request_id: u64,
+    label: []const u8,
+    err: anyerror,
+) !ipc.protocol.Response {
+    const message = try std.fmt.allocPrint(allocator, "{s}: {s}", .{ label, @errorName(err) });
+    defer allocator.free(message);
+    return errorResponse(allocator, request_id, .command_failed, message);
+}
+
 fn errorResponse(
     allocator: std.mem.Allocator,
     request_id: u64,
+    code: ipc.protocol.ErrorCode,
     message: []const u8,
 ) !ipc.protocol.Response {
     return .{
         .request_id = request_id,
         .success = false,
         .error_message = try allocator.dupe(u8, message),
+        .code = code,
     };
 }
@@ -0,0 +1,155 @@
+//! Crash-loop detection.
+//! Plugins that restart a process whenever it exits would restart a broken service forever and flood the log, so once `crash_loop_exits` failed runs land within `crash_loop_window_ms` the Primary Server marks the process crash-looping, keeps the last lines of its output, and refuses automatic starts until one is made by hand.
+
+const std = @import("std");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const startup = @import("startup.zig");
+
+const log = std.log.scoped(.primary);
+
+const default_exits = 5;
+const default_window_ms = 60_000;
+/// Output read for the kept snippet, and the lines kept from it.
+const tail_bytes = 4096;
+const snippet_lines = 5;
+
+/// Recent failed exits and the crash-looping flag of every process.
+pub const Tracker = struct {
+    allocator: std.mem.Allocator,
+    processes: []domain.process.Process,
+    /// Failed exits still inside the window, indexed like `processes`.
+    exits: []std.array_list.Managed(i64),
+    /// Backing storage for `process.crash_output`, indexed like `processes`.
+    snippets: [][]const u8,
+    /// Guards the whole check and `crash_looping` and `crash_output` on
+    /// processes; snapshot builders hold it while reading them.
+    mutex: std.Thread.Mutex = .{},
+
+    pub fn init(allocator: std.mem.Allocator, processes: []domain.process.Process) !Tracker {
+        const exits = try allocator.alloc(std.array_list.Managed(i64), processes.len);
+        errdefer allocator.free(exits);
+        for (exits) |*list| list.* = std.array_list.Managed(i64).init(allocator);
+        const snippets = try allocator.alloc([]const u8, processes.len);
+        @memset(snippets, "");
+        return .{
+            .allocator = allocator,
+            .processes = processes,
+            .exits = exits,
+            .snippets = snippets,
+        };
+    }
+
+    pub fn deinit(self: *Tracker) void {
+        for (self.exits) |*list| list.deinit();
+        self.allocator.free(self.exits);
+        for (self.snippets) |snippet| {
+            if (snippet.len > 0) self.allocator.free(snippet);
+        }
+        self.allocator.free(self.snippets);
+    }
+
+    /// Runs before every start or restart. One made by hand clears the
+    /// process's crash-loop state. An automatic one after a failed run counts
+    /// that run, and fails with `error.CrashLooping` once the process has
+    /// failed too often within the window or is already marked.
+    pub fn beforeStart(
+        self: *Tracker,
+        controller: *proc_mod.controller.Controller,
+        process: *domain.process.Process,
+        automatic: bool,
+        now_ms: i64,
+    ) !void {
+        const index = self.indexOf(process) orelse return;
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        if (!automatic) return self.clear(index);
+        if (process.crash_looping) return error.CrashLooping;
+        const code = controller.exitCode(process.id) orelse return;
+        if (code == 0) return;
+
+        const exits = &self.exits[index];
+        const window_ms: i64 = if (process.config.crash_loop_window_ms > 0) process.config.crash_loop_window_ms else default_window_ms;
+        var kept: usize = 0;
+        for (exits.items) |exit_ms| {
+            if (now_ms - exit_ms >= window_ms) continue;
+            exits.items[kept] = exit_ms;
+            kept += 1;
+        }
+        exits.shrinkRetainingCapacity(kept);
+        try exits.append(now_ms);
+
+        const limit: usize = if (process.config.crash_loop_exits > 0) @intCast(process.config.crash_loop_exits) else default_exits;
+        if (exits.items.len < limit) return;
+
+        var buffer: [tail_bytes]u8 = undefined;
+        const snippet = lastLines(self.allocator, controller.outputTail(process.id, &buffer), snippet_lines) catch "";
+        if (self.snippets[index].len > 0) self.allocator.free(self.snippets[index]);
+        self.snippets[index] = snippet;
+        process.crash_output = snippet;
+        process.crash_looping = true;
+        log.warn("process '{s}' failed {d} times within {d}ms; not restarting it until started by hand", .{ process.label, exits.items.len, window_ms });
+        controller.changes.notify();
+        return error.CrashLooping;
+    }
+
+    fn clear(self: *Tracker, index: usize) void {
+        const process = &self.processes[index];
+        self.exits[index].clearRetainingCapacity();
+        process.crash_looping = false;
+        process.crash_output = "";
+        if (self.snippets[index].len > 0) self.allocator.free(self.snippets[index]);
+        self.snippets[index] = "";
+    }
+
+    fn indexOf(self: *const Tracker, process: *const domain.process.Process) ?usize {
+        for (self.processes, 0..) |*candidate, index| {
+            if (candidate == process) return index;
+        }
+        return null;
+    }
+};
+
+/// The newest `max_lines` lines of `output` with visible text, oldest first
+/// and without escape sequences. The caller owns a non-empty result.
+pub fn lastLines(allocator: std.mem.Allocator, output: []const u8, max_lines: usize) ![]const u8 {
+    var starts: [snippet_lines][]const u8 = undefined;
+    const wanted = @min(max_lines, starts.len);
+    var count: usize = 0;
+    var lines = std.mem.splitBackwardsScalar(u8, output, '\n');
+    while (count < wanted) {
+        const raw = lines.next() orelse break;
+        const line = std.mem.trimRight(u8, raw, "\r");
+        const redrawn = if (std.mem.lastIndexOfScalar(u8, line, '\r')) |index| line[index + 1 ..] else line;
+        if (std.mem.trim(u8, redrawn, " \t").len == 0) continue;
+        starts[count] = redrawn;
+        count += 1;
+    }
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    var index = count;
+    while (index > 0) {
+        index -= 1;
+        const plain = try startup.plainReason(allocator, starts[index]);
+        defer allocator.free(plain);
+        const trimmed = std.mem.trimRight(u8, plain, " \t");
+        if (trimmed.len == 0) continue;
+        if (out.items.len > 0) try out.append('\n');
+        try out.appendSlice(trimmed);
+    }
+    if (out.items.len == 0) {
+        out.deinit();
+        return "";
+    }
+    return out.toOwnedSlice();
+}
+
+test "last lines keep the newest visible lines in order" {
+    const snippet = try lastLines(std.testing.allocator, "one\ntwo\n\n\x1b[31mError: boom\x1b[0m\n  at main.js:3\n\n", 2);
+    defer std.testing.allocator.free(snippet);
+    try std.testing.expectEqualStrings("Error: boom\n  at main.js:3", snippet);
+
+    try std.testing.expectEqualStrings("", try lastLines(std.testing.allocator, "\n \n", 5));
+}
@@ -0,0 +1,114 @@
+//! Copyable process details.
+//! The Primary Server renders each process's command line and resolves its cwd once at startup, so clients can copy them from the process list without parsing config or knowing where the config file lives. Snapshots leave them out; a client asks for one process's details with a `details` request when it copies them.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+
+const log = std.log.scoped(.primary);
+
+/// Owns the `command` and `cwd` strings set on each process, which borrow
+/// them for the Server's lifetime.
+pub const Details = struct {
+    allocator: std.mem.Allocator,
+    strings: std.array_list.Managed([]const u8),
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !Details {
+        var details = Details{
+            .allocator = allocator,
+            .strings = std.array_list.Managed([]const u8).init(allocator),
+        };
+        errdefer details.deinit();
+
+        try details.strings.ensureUnusedCapacity(1);
+        const inherited = try std.process.getCwdAlloc(allocator);
+        details.strings.appendAssumeCapacity(inherited);
+        for (processes) |*process| {
+            try details.strings.ensureUnusedCapacity(2);
+            const command = try commandLine(allocator, process.config, global_config);
+            details.strings.appendAssumeCapacity(command);
+            process.command = command;
+
+            const cwd = proc_mod.builder.resolveCwd(allocator, process.config.cwd, global_config) catch |err| blk: {
+                log.warn("could not resolve cwd of '{s}': {s}", .{ process.label, @errorName(err) });
+                break :blk try allocator.dupe(u8, process.config.cwd);
+            };
+            if (cwd.len == 0) {
+                process.cwd = inherited;
+                continue;
+            }
+            details.strings.appendAssumeCapacity(cwd);
+            process.cwd = cwd;
+        }
+        return details;
+    }
+
+    pub fn deinit(self: *Details) void {
+        for (self.strings.items) |string| self.allocator.free(string);
+        self.strings.deinit();
+    }
+};
+
+/// `shell` as written, otherwise `cmd` or the docker command, quoted for a
+/// POSIX shell. Env loaders and `shell_cmd` are left out so the line matches
+/// what the config says to run. The caller owns the result.
+pub fn commandLine(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    global_config: ?*const config.schema.Config,
+) ![]const u8 {
+    if (proc_cfg.shell.len > 0) return allocator.dupe(u8, proc_cfg.shell);
+    if (proc_cfg.cmd.items.len > 0) return quoteArgv(allocator, proc_cfg.cmd.items);
+
+    const spec = (try proc_mod.builder.buildCommand(allocator, proc_cfg, global_config)) orelse return allocator.dupe(u8, "");
+    defer spec.deinit(allocator);
+    return quoteArgv(allocator, spec.argv);
+}
+
+/// Joins `argv` into one line a shell would split back into the same words.
+pub fn quoteArgv(allocator: std.mem.Allocator, argv: []const []const u8) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    for (argv, 0..) |arg, index| {
+        if (index > 0) try out.append(' ');
+        try appendQuoted(&out, arg);
+    }
+    return out.toOwnedSlice();
+}
+
+/// Single-quotes `arg` unless every byte is safe bare, closing the quote
+/// around each embedded `'`.
+fn appendQuoted(out: *std.array_list.Managed(u8), arg: []const u8) !void {
+    const bare = arg.len > 0 and for (arg) |byte| {
+        if (!std.ascii.isAlphanumeric(byte) and std.mem.indexOfScalar(u8, "_-./:=@%+,", byte) == null) break false;
+    } else true;
+    if (bare) return out.appendSlice(arg);
+
+    try out.append('\'');
+    for (arg) |byte| {
+        if (byte == '\'') try out.appendSlice("'\\''") else try out.append(byte);
+    }
+    try out.append('\'');
+}
+
+test "command line keeps shell strings and quotes argv words that need it" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "npm run dev -- --port 3000";
+    const shell = try commandLine(std.testing.allocator, &proc_cfg, null);
+    defer std.testing.allocator.free(shell);
+    try std.testing.expectEqualStrings("npm run dev -- --port 3000", shell);
+
+    proc_cfg.shell = "";
+    for ([_][]const u8{ "go", "run", "./cmd/api", "--greeting=it's me", "" }) |part| {
+        try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, part);
+    }
+    const argv = try commandLine(std.testing.allocator, &proc_cfg, null);
+    defer std.testing.allocator.free(argv);
+    try std.testing.expectEqualStrings("go run ./cmd/api '--greeting=it'\\''s me' ''", argv);
+}
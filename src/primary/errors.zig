@@ -0,0 +1,199 @@
+//! Error-pattern scanning of process output.
+//! The Primary Server counts output lines that match `error_patterns` into each process's summary, so clients can badge processes that printed errors since they were last viewed, and finds the first retained match for `jump_to_error`. Scans run on snapshot builds, at most once per `scan_interval_ms`.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+
+const log = std.log.scoped(.primary);
+
+pub const scan_interval_ms: i64 = 1000;
+/// A line still unfinished past this many bytes is scanned as it stands.
+const max_line_bytes = 4096;
+
+/// Compiled `error_patterns` matched against lines with escape sequences
+/// dropped. One set must not match from two threads at once.
+pub const Patterns = struct {
+    set: domain.patterns.PatternSet,
+    /// Line text without escape sequences, reused between lines.
+    plain: std.array_list.Managed(u8),
+
+    /// Patterns are borrowed. A `re:` pattern that does not compile is
+    /// skipped with a warning.
+    pub fn init(allocator: std.mem.Allocator, sources: []const []const u8) !Patterns {
+        var invalid = std.array_list.Managed([]const u8).init(allocator);
+        defer invalid.deinit();
+        const set = try domain.patterns.PatternSet.init(allocator, sources, &invalid);
+        for (invalid.items) |source| log.warn("skipping error pattern '{s}': invalid regex", .{source});
+        return .{ .set = set, .plain = std.array_list.Managed(u8).init(allocator) };
+    }
+
+    pub fn deinit(self: *Patterns) void {
+        self.set.deinit();
+        self.plain.deinit();
+    }
+
+    /// Whether `line`, with escape sequences dropped, matches any pattern.
+    pub fn matches(self: *Patterns, line: []const u8) bool {
+        if (self.set.isEmpty()) return false;
+        return self.set.matches(plainText(&self.plain, line) catch line);
+    }
+
+    /// Index of the first line of `output` that matches.
+    pub fn firstMatch(self: *Patterns, output: []const u8) ?usize {
+        var start: usize = 0;
+        while (start < output.len) {
+            const end = std.mem.indexOfScalarPos(u8, output, start, '\n') orelse output.len;
+            if (self.matches(output[start..end])) return start;
+            start = end + 1;
+        }
+        return null;
+    }
+};
+
+fn plainText(out: *std.array_list.Managed(u8), line: []const u8) ![]const u8 {
+    out.clearRetainingCapacity();
+    var index: usize = 0;
+    while (index < line.len) {
+        if (line[index] == 0x1b) {
+            index += 1;
+            if (index < line.len and line[index] == '[') {
+                index += 1;
+                while (index < line.len and !(line[index] >= 0x40 and line[index] <= 0x7e)) : (index += 1) {}
+            }
+            index += 1;
+            continue;
+        }
+        try out.append(line[index]);
+        index += 1;
+    }
+    return out.items;
+}
+
+/// Per-process error counts and how far each process's output was scanned.
+pub const Scanner = struct {
+    allocator: std.mem.Allocator,
+    processes: []domain.process.Process,
+    patterns: Patterns,
+    /// Stream offset each process is scanned up to, always at a line start.
+    offsets: []u64,
+    scanned_ms: i64 = 0,
+    /// Set while a wakeup for the next scan is pending.
+    scheduled: bool = false,
+    /// Guards `patterns` and `error_count` on processes; snapshot builders
+    /// hold it while reading them.
+    mutex: std.Thread.Mutex = .{},
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !Scanner {
+        const sources: []const []const u8 = if (global_config) |cfg| cfg.error_patterns.items else &.{};
+        var patterns = try Patterns.init(allocator, sources);
+        errdefer patterns.deinit();
+        const offsets = try allocator.alloc(u64, processes.len);
+        @memset(offsets, 0);
+        return .{
+            .allocator = allocator,
+            .processes = processes,
+            .patterns = patterns,
+            .offsets = offsets,
+        };
+    }
+
+    pub fn deinit(self: *Scanner) void {
+        self.patterns.deinit();
+        self.allocator.free(self.offsets);
+    }
+
+    /// Counts matching lines in output written since the last scan. Calls
+    /// inside the interval schedule one wakeup for when it ends, so errors in
+    /// the last burst of output are still counted.
+    pub fn refresh(self: *Scanner, controller: *proc_mod.controller.Controller, now_ms: i64) void {
+        if (self.patterns.set.isEmpty()) return;
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        const due_ms = self.scanned_ms + scan_interval_ms;
+        if (now_ms < due_ms) {
+            if (!self.scheduled) {
+                self.scheduled = true;
+                controller.changes.notifyAt(due_ms);
+            }
+            return;
+        }
+        self.scanned_ms = now_ms;
+        self.scheduled = false;
+
+        for (self.processes, self.offsets) |*process, *offset| {
+            if (controller.processStats(process.id).output_bytes == offset.*) continue;
+            const chunk = controller.outputFrom(self.allocator, process.id, offset.*) catch continue;
+            defer self.allocator.free(chunk.bytes);
+
+            const scanned = self.scan(chunk.bytes);
+            process.error_count += scanned.matches;
+            offset.* = chunk.offset + @as(u64, scanned.len);
+        }
+    }
+
+    /// Index of the first line in `output` that matches, for jumping to it.
+    pub fn firstError(self: *Scanner, output: []const u8) ?usize {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.patterns.firstMatch(output);
+    }
+
+    const Scanned = struct {
+        matches: u32 = 0,
+        /// Bytes of complete lines; an unfinished line waits for the next scan.
+        len: usize = 0,
+    };
+
+    fn scan(self: *Scanner, bytes: []const u8) Scanned {
+        var scanned: Scanned = .{};
+        while (scanned.len < bytes.len) {
+            const rest = bytes[scanned.len..];
+            const end = std.mem.indexOfScalar(u8, rest, '\n') orelse {
+                if (rest.len < max_line_bytes) break;
+                if (self.patterns.matches(rest)) scanned.matches += 1;
+                scanned.len = bytes.len;
+                break;
+            };
+            if (self.patterns.matches(rest[0..end])) scanned.matches += 1;
+            scanned.len += end + 1;
+        }
+        return scanned;
+    }
+};
+
+test "patterns match substrings case-insensitively and regexes after re:" {
+    var patterns = try Patterns.init(std.testing.allocator, &.{ "error", "re:^E[0-9]+ ", "re:(broken" });
+    defer patterns.deinit();
+
+    try std.testing.expectEqual(@as(usize, 2), patterns.set.items.len);
+    try std.testing.expect(patterns.matches("Build ERROR in main.zig"));
+    try std.testing.expect(patterns.matches("\x1b[31mE0425 \x1b[0mcannot find value"));
+    try std.testing.expect(!patterns.matches("all good"));
+    try std.testing.expectEqual(@as(?usize, 8), patterns.firstMatch("booting\nerror: bad\nerror: worse\n"));
+    try std.testing.expectEqual(@as(?usize, null), patterns.firstMatch("booting\nready\n"));
+}
+
+test "scanner counts complete lines and waits for unfinished ones" {
+    var scanner = Scanner{
+        .allocator = std.testing.allocator,
+        .processes = &.{},
+        .patterns = try Patterns.init(std.testing.allocator, &.{"panic"}),
+        .offsets = &.{},
+    };
+    defer scanner.patterns.deinit();
+
+    const first = scanner.scan("ok\npanic: one\npanic: two");
+    try std.testing.expectEqual(@as(u32, 1), first.matches);
+    try std.testing.expectEqual(@as(usize, 14), first.len);
+
+    const rest = scanner.scan("panic: two\n");
+    try std.testing.expectEqual(@as(u32, 1), rest.matches);
+    try std.testing.expectEqual(@as(usize, 11), rest.len);
+}
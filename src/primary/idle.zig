@@ -0,0 +1,106 @@
+//! Idle auto-stop for processes with `idle_timeout_ms`.
+//! The Primary Server stops a process once it has gone that long without printing output or receiving input, so dev servers left running overnight stop on their own. With `auto_shutdown`, proctmux then exits once an idle stop leaves nothing running.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const signals = @import("signals.zig");
+const threads = @import("../threads/root.zig");
+
+const log = std.log.scoped(.primary);
+
+/// Output times move at most once a second, so checking more often would not
+/// stop anything sooner.
+const poll_interval_ms = 1000;
+
+/// Stops one process for the idle monitor.
+pub const Stopper = struct {
+    context: *anyopaque,
+    stop: *const fn (context: *anyopaque, process: *domain.process.Process) anyerror!void,
+};
+
+/// Watches every process that sets `idle_timeout_ms`.
+pub const Monitor = struct {
+    processes: []domain.process.Process,
+    /// Set when any process has an idle timeout.
+    enabled: bool = false,
+    auto_shutdown: bool = false,
+    controller: ?*proc_mod.controller.Controller = null,
+    stopper: ?Stopper = null,
+    /// Unix milliseconds of the latest idle stop; 0 before any.
+    idle_stopped_ms: i64 = 0,
+    /// Guards `idle_stops` on processes; snapshot builders hold it while
+    /// reading them.
+    mutex: std.Thread.Mutex = .{},
+    poller: threads.Poller = .{},
+
+    pub fn init(processes: []domain.process.Process, global_config: ?*const config.schema.Config) Monitor {
+        var monitor = Monitor{ .processes = processes };
+        for (processes) |process| {
+            if (process.config.idle_timeout_ms > 0) monitor.enabled = true;
+        }
+        if (global_config) |cfg| monitor.auto_shutdown = cfg.auto_shutdown;
+        return monitor;
+    }
+
+    pub fn deinit(self: *Monitor) void {
+        self.stop();
+    }
+
+    /// Starts the polling thread. Configs without `idle_timeout_ms` never
+    /// start one.
+    pub fn start(self: *Monitor, controller: *proc_mod.controller.Controller, stopper: Stopper) !void {
+        if (!self.enabled or self.poller.isRunning()) return;
+        self.controller = controller;
+        self.stopper = stopper;
+        try self.poller.start(self, poll, poll_interval_ms);
+    }
+
+    pub fn stop(self: *Monitor) void {
+        self.poller.stop();
+    }
+
+    /// Stops each running process idle for its whole timeout, counting from
+    /// its start, latest output, or latest input. With `auto_shutdown`, asks
+    /// for shutdown once an idle stop has left nothing running.
+    pub fn poll(self: *Monitor, now_ms: i64) void {
+        const controller = self.controller orelse return;
+        const stopper = self.stopper orelse return;
+        for (self.processes) |*process| {
+            const timeout_ms = process.config.idle_timeout_ms;
+            if (timeout_ms <= 0 or !controller.isRunning(process.id)) continue;
+            const stats = controller.processStats(process.id);
+            const active_ms = @max(stats.last_started_ms, stats.last_output_ms, stats.last_input_ms);
+            if (now_ms - active_ms < timeout_ms) continue;
+
+            stopper.stop(stopper.context, process) catch |err| {
+                log.warn("idle stop failed for process '{s}': {s}", .{ process.label, @errorName(err) });
+                continue;
+            };
+            log.info("stopped process '{s}' after {d}ms without output or input", .{ process.label, timeout_ms });
+            self.idle_stopped_ms = now_ms;
+            self.mutex.lock();
+            process.idle_stops += 1;
+            self.mutex.unlock();
+            controller.changes.notify();
+        }
+
+        if (self.auto_shutdown and self.idleStopLeftNothingRunning(controller)) {
+            log.info("no processes running after idle stop; shutting down", .{});
+            self.auto_shutdown = false;
+            signals.request();
+        }
+    }
+
+    /// A start since the latest idle stop means proctmux is in use again, so
+    /// stopping that process later does not exit.
+    fn idleStopLeftNothingRunning(self: *const Monitor, controller: *proc_mod.controller.Controller) bool {
+        if (self.idle_stopped_ms == 0) return false;
+        for (self.processes) |process| {
+            if (controller.isRunning(process.id)) return false;
+            if (controller.processStats(process.id).last_started_ms > self.idle_stopped_ms) return false;
+        }
+        return true;
+    }
+};
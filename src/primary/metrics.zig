@@ -0,0 +1,221 @@
+//! Prometheus metrics endpoint for the Primary Server.
+//! A deliberately tiny HTTP listener serves `GET /metrics` so long-lived dev environments can alert on crashing processes without a full HTTP stack.
+
+const std = @import("std");
+const domain = @import("../domain/root.zig");
+const primary = @import("root.zig");
+const test_config = @import("../test_support/config.zig");
+
+const log = std.log.scoped(.primary);
+
+const max_request_head = 4096;
+const read_timeout_ms = 2000;
+
+/// Parses `metrics_addr` as `host:port`. `localhost` is accepted as a
+/// convenience; other hosts must be IP literals.
+pub fn parseAddress(text: []const u8) !std.net.Address {
+    const separator = std.mem.lastIndexOfScalar(u8, text, ':') orelse return error.InvalidMetricsAddress;
+    const host = text[0..separator];
+    const port = std.fmt.parseInt(u16, text[separator + 1 ..], 10) catch return error.InvalidMetricsAddress;
+    if (host.len == 0 or std.mem.eql(u8, host, "localhost")) return std.net.Address.parseIp("127.0.0.1", port);
+    return std.net.Address.parseIp(host, port) catch error.InvalidMetricsAddress;
+}
+
+/// Thread entrypoint. The endpoint is optional, so failures are logged rather
+/// than taking the Primary Server down.
+pub fn run(allocator: std.mem.Allocator, server: *primary.Server, address: std.net.Address, stopped: *std.atomic.Value(bool)) void {
+    serve(allocator, server, address, stopped) catch |err| {
+        log.warn("metrics endpoint stopped: {s}", .{@errorName(err)});
+    };
+}
+
+/// Serves one connection at a time until `stopped` is raised. Callers wake a
+/// blocked accept with `unblock` after raising the flag.
+pub fn serve(allocator: std.mem.Allocator, server: *primary.Server, address: std.net.Address, stopped: *std.atomic.Value(bool)) !void {
+    var listener = try address.listen(.{ .reuse_address = true });
+    defer listener.deinit();
+
+    while (!stopped.load(.seq_cst)) {
+        const conn = listener.accept() catch |err| {
+            if (stopped.load(.seq_cst)) break;
+            return err;
+        };
+        defer conn.stream.close();
+        if (stopped.load(.seq_cst)) break;
+
+        respond(allocator, server, conn.stream) catch |err| {
+            log.debug("metrics request failed: {s}", .{@errorName(err)});
+        };
+    }
+}
+
+pub fn unblock(address: std.net.Address) void {
+    const stream = std.net.tcpConnectToAddress(address) catch |err| {
+        log.debug("failed to unblock metrics endpoint: {s}", .{@errorName(err)});
+        return;
+    };
+    stream.close();
+}
+
+/// Renders Prometheus text exposition for every configured process. Uptime is
+/// zero for processes that are not running.
+pub fn render(allocator: std.mem.Allocator, server: *primary.Server, now_ms: i64) ![]u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    const writer = out.writer();
+
+    const processes = server.state.processes.items;
+    var running: usize = 0;
+    for (processes) |process| {
+        if (server.controller.isRunning(process.id)) running += 1;
+    }
+
+    try writeHeader(writer, "proctmux_processes_running", "gauge", "Managed processes currently running.");
+    try writer.print("proctmux_processes_running {d}\n", .{running});
+
+    try writeHeader(writer, "proctmux_ipc_clients_connected", "gauge", "IPC clients connected to the primary server.");
+    try writer.print("proctmux_ipc_clients_connected {d}\n", .{server.ipc_clients.load(.seq_cst)});
+
+    try writeHeader(writer, "proctmux_process_restarts_total", "counter", "Starts after the first, per process.");
+    for (processes) |process| {
+        try writeSample(writer, "proctmux_process_restarts_total", process.label);
+        try writer.print(" {d}\n", .{server.controller.processStats(process.id).restarts()});
+    }
+
+    try writeHeader(writer, "proctmux_process_uptime_seconds", "gauge", "Seconds since the running process started.");
+    for (processes) |process| {
+        const stats = server.controller.processStats(process.id);
+        const uptime_ms = if (server.controller.isRunning(process.id)) @max(now_ms - stats.last_started_ms, 0) else 0;
+        try writeSample(writer, "proctmux_process_uptime_seconds", process.label);
+        try writer.print(" {d}.{d:0>3}\n", .{ @divTrunc(uptime_ms, 1000), @mod(uptime_ms, 1000) });
+    }
+
+    try writeHeader(writer, "proctmux_output_bytes_total", "counter", "Output bytes captured, per process.");
+    for (processes) |process| {
+        try writeSample(writer, "proctmux_output_bytes_total", process.label);
+        try writer.print(" {d}\n", .{server.controller.processStats(process.id).output_bytes});
+    }
+
+    try writeHeader(writer, "proctmux_output_lines_total", "counter", "Output lines captured, per process.");
+    for (processes) |process| {
+        try writeSample(writer, "proctmux_output_lines_total", process.label);
+        try writer.print(" {d}\n", .{server.controller.processStats(process.id).output_lines});
+    }
+
+    try writeHeader(writer, "proctmux_output_bytes_per_second", "gauge", "Output bytes per second over the last ten seconds.");
+    for (processes) |process| {
+        try writeSample(writer, "proctmux_output_bytes_per_second", process.label);
+        try writer.print(" {d}\n", .{server.controller.processStats(process.id).output_rate});
+    }
+
+    // Scans normally happen on snapshot builds, which need a connected client.
+    server.error_scanner.refresh(&server.controller, now_ms);
+    try writeHeader(writer, "proctmux_output_error_lines_total", "counter", "Output lines matching error_patterns, per process.");
+    server.error_scanner.mutex.lock();
+    defer server.error_scanner.mutex.unlock();
+    for (processes) |process| {
+        try writeSample(writer, "proctmux_output_error_lines_total", process.label);
+        try writer.print(" {d}\n", .{process.error_count});
+    }
+
+    return out.toOwnedSlice();
+}
+
+fn respond(allocator: std.mem.Allocator, server: *primary.Server, stream: std.net.Stream) !void {
+    try setReadTimeout(stream);
+
+    var buffer: [max_request_head]u8 = undefined;
+    var len: usize = 0;
+    while (std.mem.indexOf(u8, buffer[0..len], "\r\n\r\n") == null) {
+        if (len == buffer.len) return error.RequestTooLarge;
+        const n = try stream.read(buffer[len..]);
+        if (n == 0) return error.EndOfStream;
+        len += n;
+    }
+
+    const line_end = std.mem.indexOf(u8, buffer[0..len], "\r\n").?;
+    if (!isMetricsRequest(buffer[0..line_end])) {
+        try stream.writeAll("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\nConnection: close\r\n\r\n");
+        return;
+    }
+
+    const body = try render(allocator, server, std.time.milliTimestamp());
+    defer allocator.free(body);
+
+    var header_buffer: [160]u8 = undefined;
+    const header = try std.fmt.bufPrint(
+        &header_buffer,
+        "HTTP/1.1 200 OK\r\nContent-Type: text/plain; version=0.0.4\r\nContent-Length: {d}\r\nConnection: close\r\n\r\n",
+        .{body.len},
+    );
+    try stream.writeAll(header);
+    try stream.writeAll(body);
+}
+
+fn isMetricsRequest(request_line: []const u8) bool {
+    return std.mem.startsWith(u8, request_line, "GET /metrics ") or
+        std.mem.startsWith(u8, request_line, "GET /metrics?");
+}
+
+/// A scraper that connects and never sends a request must not wedge the
+/// single-threaded accept loop.
+fn setReadTimeout(stream: std.net.Stream) !void {
+    const tv = std.posix.timeval{
+        .sec = @intCast(read_timeout_ms / 1000),
+        .usec = @intCast((read_timeout_ms % 1000) * 1000),
+    };
+    try std.posix.setsockopt(stream.handle, std.posix.SOL.SOCKET, std.posix.SO.RCVTIMEO, std.mem.asBytes(&tv));
+}
+
+fn writeHeader(writer: anytype, name: []const u8, kind: []const u8, help: []const u8) !void {
+    try writer.print("# HELP {s} {s}\n# TYPE {s} {s}\n", .{ name, help, name, kind });
+}
+
+fn writeSample(writer: anytype, name: []const u8, label: []const u8) !void {
+    try writer.print("{s}{{process=\"", .{name});
+    for (label) |byte| switch (byte) {
+        '\\' => try writer.writeAll("\\\\"),
+        '"' => try writer.writeAll("\\\""),
+        '\n' => try writer.writeAll("\\n"),
+        else => try writer.writeByte(byte),
+    };
+    try writer.writeAll("\"}");
+}
+
+test "metrics address accepts host port pairs" {
+    const local = try parseAddress("localhost:9793");
+    try std.testing.expectEqual(@as(u16, 9793), local.getPort());
+
+    const any = try parseAddress("0.0.0.0:9000");
+    try std.testing.expectEqual(@as(u16, 9000), any.getPort());
+
+    try std.testing.expectError(error.InvalidMetricsAddress, parseAddress("9793"));
+    try std.testing.expectError(error.InvalidMetricsAddress, parseAddress("example.com:9793"));
+}
+
+test "metrics render counts restarts and escapes labels" {
+    var cfg = try test_config.basicConfig(std.testing.allocator);
+    defer cfg.deinit();
+    try test_config.putShellProcess(&cfg, "api \"v2\"", "printf ok");
+
+    var server = try primary.Server.init(std.testing.allocator, &cfg);
+    defer server.deinit();
+
+    const id = domain.process.ProcessId.fromInt(1);
+    const proc_cfg = cfg.procs.getPtr("api \"v2\"").?;
+    for (0..2) |_| {
+        _ = try server.controller.startProcess(id, proc_cfg);
+        while (server.controller.isRunning(id)) std.Thread.sleep(5 * std.time.ns_per_ms);
+        try server.controller.cleanupProcess(id);
+    }
+
+    const text = try render(std.testing.allocator, &server, std.time.milliTimestamp());
+    defer std.testing.allocator.free(text);
+
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_processes_running 0\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_process_restarts_total{process=\"api \\\"v2\\\"\"} 1\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_process_uptime_seconds{process=\"api \\\"v2\\\"\"} 0.000\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_output_bytes_total{process=\"api \\\"v2\\\"\"} 4\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_output_lines_total{process=\"api \\\"v2\\\"\"} 0\n") != null);
+    try std.testing.expect(std.mem.indexOf(u8, text, "proctmux_output_error_lines_total{process=\"api \\\"v2\\\"\"} 0\n") != null);
+}
@@ -0,0 +1,231 @@
+//! Opening process URLs and working directories.
+//! The Primary Server knows each process's `url` and resolved cwd, so the `open_url` and `open_cwd` commands run the opener here instead of in clients. Processes with `open_url: true` also have their URL opened once per start, as soon as a local port accepts connections.
+
+const std = @import("std");
+const builtin = @import("builtin");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
+
+const log = std.log.scoped(.primary);
+
+const poll_interval_ms = 500;
+/// A start whose port is still closed after this long is not opened.
+const give_up_ms: i64 = 60_000;
+
+const system_opener: []const []const u8 = if (builtin.os.tag == .macos) &.{"open"} else &.{"xdg-open"};
+
+/// Backgrounds the opener so a browser or editor that stays open never holds
+/// the caller, after checking it exists so a typo still fails the command.
+const launch_script = "command -v \"$1\" >/dev/null 2>&1 || exit 127; \"$@\" </dev/null >/dev/null 2>&1 &";
+
+pub const Target = enum { url, cwd };
+
+/// Opens `process`'s `url` or cwd without waiting for the opener to exit.
+pub fn open(
+    allocator: std.mem.Allocator,
+    process: *const domain.process.Process,
+    target: Target,
+    global_config: ?*const config.schema.Config,
+) !void {
+    const path = switch (target) {
+        .url => process.config.url,
+        .cwd => process.cwd,
+    };
+    if (path.len == 0) return if (target == .url) error.NoUrlConfigured else error.UnknownCwd;
+    try launch(allocator, opener(global_config, target), path);
+}
+
+/// `editor_cmd` for a cwd when set, otherwise `open_cmd`, otherwise the
+/// system opener.
+pub fn opener(global_config: ?*const config.schema.Config, target: Target) []const []const u8 {
+    const cfg = global_config orelse return system_opener;
+    if (target == .cwd and cfg.editor_cmd.items.len > 0) return cfg.editor_cmd.items;
+    if (cfg.open_cmd.items.len > 0) return cfg.open_cmd.items;
+    return system_opener;
+}
+
+fn launch(allocator: std.mem.Allocator, argv: []const []const u8, path: []const u8) !void {
+    var args = std.array_list.Managed([]const u8).init(allocator);
+    defer args.deinit();
+    try args.appendSlice(&.{ "/bin/sh", "-c", launch_script, "proctmux-open" });
+    try args.appendSlice(argv);
+    try args.append(path);
+
+    var child = std.process.Child.init(args.items, allocator);
+    child.stdin_behavior = .Ignore;
+    child.stdout_behavior = .Ignore;
+    child.stderr_behavior = .Ignore;
+    switch (try child.spawnAndWait()) {
+        .Exited => |code| switch (code) {
+            0 => {},
+            127 => return error.OpenCommandNotFound,
+            else => return error.OpenCommandFailed,
+        },
+        else => return error.OpenCommandFailed,
+    }
+}
+
+/// Opens the `url` of every `open_url` process once per start.
+pub const AutoOpener = struct {
+    allocator: std.mem.Allocator,
+    global_config: ?*const config.schema.Config,
+    targets: []AutoTarget,
+    controller: ?*proc_mod.controller.Controller = null,
+    poller: threads.Poller = .{},
+
+    const AutoTarget = struct {
+        process: *const domain.process.Process,
+        /// The start this url was last opened, or given up on, for.
+        handled_start: u32 = 0,
+    };
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !AutoOpener {
+        var targets = std.array_list.Managed(AutoTarget).init(allocator);
+        errdefer targets.deinit();
+        for (processes) |*process| {
+            if (!process.config.open_url) continue;
+            if (process.config.url.len == 0) {
+                log.warn("process '{s}' sets open_url without a url", .{process.label});
+                continue;
+            }
+            try targets.append(.{ .process = process });
+        }
+        return .{
+            .allocator = allocator,
+            .global_config = global_config,
+            .targets = try targets.toOwnedSlice(),
+        };
+    }
+
+    pub fn deinit(self: *AutoOpener) void {
+        self.stop();
+        self.allocator.free(self.targets);
+    }
+
+    /// Starts the polling thread. Configs without `open_url` never start one.
+    pub fn start(self: *AutoOpener, controller: *proc_mod.controller.Controller) !void {
+        if (self.targets.len == 0 or self.poller.isRunning()) return;
+        self.controller = controller;
+        try self.poller.start(self, poll, poll_interval_ms);
+    }
+
+    pub fn stop(self: *AutoOpener) void {
+        self.poller.stop();
+    }
+
+    /// Opens the url of each running process started since its last open,
+    /// once the url's port accepts connections.
+    pub fn poll(self: *AutoOpener, now_ms: i64) void {
+        const controller = self.controller orelse return;
+        for (self.targets) |*target| {
+            const process = target.process;
+            const stats = controller.processStats(process.id);
+            if (stats.starts == target.handled_start or !controller.isRunning(process.id)) continue;
+
+            if (!listening(process.config.url)) {
+                if (now_ms - stats.last_started_ms < give_up_ms) continue;
+                log.warn("not opening {s} for '{s}': nothing listening {d}s after start", .{ process.config.url, process.label, @divTrunc(give_up_ms, 1000) });
+            } else {
+                open(self.allocator, process, .url, self.global_config) catch |err| {
+                    log.warn("failed to open {s} for '{s}': {s}", .{ process.config.url, process.label, @errorName(err) });
+                };
+            }
+            target.handled_start = stats.starts;
+        }
+    }
+};
+
+/// Host and port of a URL, e.g. `localhost` and 3000 for
+/// `http://localhost:3000/app`.
+pub const Endpoint = struct {
+    host: []const u8,
+    port: u16,
+};
+
+pub fn endpoint(url: []const u8) ?Endpoint {
+    const scheme_end = std.mem.indexOf(u8, url, "://") orelse return null;
+    const scheme = url[0..scheme_end];
+    var authority = url[scheme_end + 3 ..];
+    if (std.mem.indexOfAny(u8, authority, "/?#")) |end| authority = authority[0..end];
+    if (std.mem.lastIndexOfScalar(u8, authority, '@')) |at| authority = authority[at + 1 ..];
+
+    var host = authority;
+    var port_text: []const u8 = "";
+    if (std.mem.startsWith(u8, authority, "[")) {
+        const close = std.mem.indexOfScalar(u8, authority, ']') orelse return null;
+        host = authority[1..close];
+        const rest = authority[close + 1 ..];
+        if (std.mem.startsWith(u8, rest, ":")) port_text = rest[1..];
+    } else if (std.mem.lastIndexOfScalar(u8, authority, ':')) |colon| {
+        host = authority[0..colon];
+        port_text = authority[colon + 1 ..];
+    }
+    if (host.len == 0) return null;
+
+    const port: u16 = if (port_text.len > 0)
+        std.fmt.parseInt(u16, port_text, 10) catch return null
+    else if (std.ascii.eqlIgnoreCase(scheme, "https"))
+        443
+    else if (std.ascii.eqlIgnoreCase(scheme, "http"))
+        80
+    else
+        return null;
+    return .{ .host = host, .port = port };
+}
+
+/// Whether something accepts connections at `url`. Only local hosts are
+/// probed; any other URL, or one without a port, counts as listening since
+/// the process cannot be what serves it until proven otherwise.
+fn listening(url: []const u8) bool {
+    const target = endpoint(url) orelse return true;
+    const addresses: []const []const u8 = if (std.ascii.eqlIgnoreCase(target.host, "localhost"))
+        &.{ "127.0.0.1", "::1" }
+    else if (std.mem.eql(u8, target.host, "127.0.0.1") or std.mem.eql(u8, target.host, "0.0.0.0"))
+        &.{"127.0.0.1"}
+    else if (std.mem.eql(u8, target.host, "::1") or std.mem.eql(u8, target.host, "::"))
+        &.{"::1"}
+    else
+        return true;
+
+    for (addresses) |ip| {
+        const address = std.net.Address.parseIp(ip, target.port) catch continue;
+        const stream = std.net.tcpConnectToAddress(address) catch continue;
+        stream.close();
+        return true;
+    }
+    return false;
+}
+
+test "endpoint finds the host and port of process urls" {
+    const dev = endpoint("http://localhost:3000/app?x=1").?;
+    try std.testing.expectEqualStrings("localhost", dev.host);
+    try std.testing.expectEqual(@as(u16, 3000), dev.port);
+
+    const secure = endpoint("https://user@api.test").?;
+    try std.testing.expectEqualStrings("api.test", secure.host);
+    try std.testing.expectEqual(@as(u16, 443), secure.port);
+
+    const ipv6 = endpoint("http://[::1]:8080/").?;
+    try std.testing.expectEqualStrings("::1", ipv6.host);
+    try std.testing.expectEqual(@as(u16, 8080), ipv6.port);
+
+    try std.testing.expect(endpoint("localhost:3000") == null);
+    try std.testing.expect(endpoint("http://localhost:99999") == null);
+}
+
+test "opener prefers editor_cmd for cwds and open_cmd for urls" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try std.testing.expectEqualStrings(system_opener[0], opener(&cfg, .cwd)[0]);
+
+    try config.schema.appendOwned(std.testing.allocator, &cfg.open_cmd, "firefox");
+    try config.schema.appendOwned(std.testing.allocator, &cfg.editor_cmd, "code");
+    try std.testing.expectEqualStrings("firefox", opener(&cfg, .url)[0]);
+    try std.testing.expectEqualStrings("code", opener(&cfg, .cwd)[0]);
+}
@@ -0,0 +1,503 @@
+//! Plugin executables driven by process lifecycle events.
+//! Every executable in `plugins_dir` runs once per event with the event as one JSON line on stdin; the JSON lines it prints on stdout are commands back to the Primary Server. Plugins run one at a time on the dispatcher thread, so a slow plugin delays later events but never process lifecycle.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const ipc = @import("../ipc/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
+const stuck = @import("stuck.zig");
+
+const log = std.log.scoped(.primary);
+
+const default_timeout_ms = 5000;
+/// Upper bound on a scan interval when nothing notifies the change signal.
+const idle_wait_ms = 500;
+const max_annotation_bytes = 200;
+
+pub const EventKind = enum {
+    started,
+    exited,
+    stopped,
+    start_failed,
+    /// The process rang the terminal bell (BEL in its output).
+    bell,
+    /// The process printed nothing for its `expect_output_within_ms`.
+    stuck,
+};
+
+/// The JSON object written to each plugin's stdin.
+pub const Event = struct {
+    event: EventKind,
+    process: []const u8,
+    id: u32,
+    pid: i32 = -1,
+    /// Set for `exited` events only.
+    exit_code: ?u32 = null,
+    /// Set for `bell` events only: bells rung since the previous scan.
+    bells: ?u32 = null,
+    time_ms: i64 = 0,
+};
+
+/// Commands a plugin may print on stdout, one JSON object per line.
+pub const Action = enum {
+    annotate,
+    start,
+    stop,
+    restart,
+};
+
+/// One decoded stdout line. `process` defaults to the event's process and
+/// `text` is only read by `annotate`.
+const Reply = struct {
+    action: []const u8,
+    process: ?[]const u8 = null,
+    text: []const u8 = "",
+};
+
+/// What the dispatcher watches and drives once started.
+pub const Sources = struct {
+    controller: domain.process.ProcessController,
+    changes: *domain.changes.Signal,
+    handler: ipc.server.CommandHandler,
+    /// Exported to plugins as `PROCTMUX_SOCKET`.
+    socket_path: []const u8 = "",
+    /// Source of `stuck` events; none are sent without it.
+    stuck_detector: ?*stuck.Detector = null,
+};
+
+/// The lifecycle event for a status change seen between two scans, if any.
+/// Cleanup of an exited run (`exited` to `halted`) is not an event.
+pub fn eventFor(previous: domain.process.ProcessStatus, current: domain.process.ProcessStatus) ?EventKind {
+    if (previous == current) return null;
+    return switch (current) {
+        .running => .started,
+        .exited => .exited,
+        .halted => switch (previous) {
+            .running, .halting => .stopped,
+            .starting, .restarting => .start_failed,
+            else => null,
+        },
+        else => null,
+    };
+}
+
+/// Runs plugins for status changes of every process. Events come from
+/// comparing statuses on each scan, so a start and exit that both land between
+/// two scans are reported as `exited` alone.
+pub const Dispatcher = struct {
+    allocator: std.mem.Allocator,
+    processes: []domain.process.Process,
+    /// Sorted absolute paths of the plugin executables found at init.
+    plugins: []const []const u8,
+    /// Plugins run from the config file's directory; borrowed from config.
+    cwd: []const u8 = "",
+    config_path: []const u8 = "",
+    timeout_ms: u64 = default_timeout_ms,
+    /// Status at the previous scan, indexed like `processes`.
+    statuses: []domain.process.ProcessStatus,
+    /// Bell count at the previous scan, indexed like `processes`.
+    bells: []u32,
+    /// Stuck flag at the previous scan, indexed like `processes`.
+    stuck: []bool,
+    /// Cleared by `general.on_bell: off`.
+    bell_events: bool = true,
+    /// Backing storage for `process.annotation`, indexed like `processes`.
+    annotations: [][]const u8,
+    /// Guards `annotation` on processes; snapshot builders hold it while
+    /// reading them.
+    mutex: std.Thread.Mutex = .{},
+    sources: ?Sources = null,
+    stopped: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    thread: ?std.Thread = null,
+    next_request_id: u64 = 1,
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !Dispatcher {
+        const statuses = try allocator.alloc(domain.process.ProcessStatus, processes.len);
+        errdefer allocator.free(statuses);
+        @memset(statuses, .halted);
+        const bells = try allocator.alloc(u32, processes.len);
+        errdefer allocator.free(bells);
+        @memset(bells, 0);
+        const stuck_flags = try allocator.alloc(bool, processes.len);
+        errdefer allocator.free(stuck_flags);
+        @memset(stuck_flags, false);
+        const annotations = try allocator.alloc([]const u8, processes.len);
+        errdefer allocator.free(annotations);
+        @memset(annotations, "");
+
+        var dispatcher: Dispatcher = .{
+            .allocator = allocator,
+            .processes = processes,
+            .plugins = &.{},
+            .statuses = statuses,
+            .bells = bells,
+            .stuck = stuck_flags,
+            .annotations = annotations,
+        };
+        const cfg = global_config orelse return dispatcher;
+        dispatcher.bell_events = !std.mem.eql(u8, cfg.general.on_bell, @tagName(config.schema.BellAction.off));
+        dispatcher.cwd = std.fs.path.dirname(cfg.file_path) orelse "";
+        dispatcher.config_path = cfg.file_path;
+        if (cfg.plugin_timeout_ms > 0) dispatcher.timeout_ms = @intCast(cfg.plugin_timeout_ms);
+        if (cfg.plugins_dir.len == 0) return dispatcher;
+
+        const dir = try resolveDir(allocator, cfg);
+        defer allocator.free(dir);
+        // A missing directory should not keep the processes from running.
+        dispatcher.plugins = discover(allocator, dir) catch |err| blk: {
+            log.warn("plugins in {s} not loaded: {s}", .{ dir, @errorName(err) });
+            break :blk &.{};
+        };
+        for (dispatcher.plugins) |path| log.info("loaded plugin {s}", .{path});
+        return dispatcher;
+    }
+
+    pub fn deinit(self: *Dispatcher) void {
+        self.stop();
+        for (self.plugins) |path| self.allocator.free(path);
+        if (self.plugins.len > 0) self.allocator.free(self.plugins);
+        for (self.annotations) |text| {
+            if (text.len > 0) self.allocator.free(text);
+        }
+        self.allocator.free(self.annotations);
+        self.allocator.free(self.statuses);
+        self.allocator.free(self.bells);
+        self.allocator.free(self.stuck);
+    }
+
+    /// Starts the dispatcher thread. Configs without plugins never start one.
+    pub fn start(self: *Dispatcher, sources: Sources) !void {
+        if (self.plugins.len == 0 or self.thread != null) return;
+        self.sources = sources;
+        self.stopped.store(false, .seq_cst);
+        self.thread = try threads.spawn(.{}, run, .{self});
+    }
+
+    pub fn stop(self: *Dispatcher) void {
+        const thread = self.thread orelse return;
+        self.stopped.store(true, .seq_cst);
+        if (self.sources) |sources| sources.changes.notify();
+        thread.join();
+        self.thread = null;
+    }
+
+    /// Compares every process's status, bell count, and stuck flag with the
+    /// previous scan and runs the plugins for each change.
+    pub fn poll(self: *Dispatcher) void {
+        const sources = self.sources orelse return;
+        for (self.processes, self.statuses, self.bells, self.stuck, 0..) |*process, *previous, *heard, *was_stuck, index| {
+            const current = sources.controller.getProcessStatus(process.id);
+            defer previous.* = current;
+            if (eventFor(previous.*, current)) |kind| self.dispatch(index, .{
+                .event = kind,
+                .process = process.label,
+                .id = process.id.toInt(),
+                .pid = sources.controller.getPID(process.id),
+                .exit_code = if (kind == .exited) sources.controller.getExitCode(process.id) else null,
+                .time_ms = std.time.milliTimestamp(),
+            });
+
+            if (sources.stuck_detector) |detector| {
+                const is_stuck = detector.isStuck(process);
+                defer was_stuck.* = is_stuck;
+                if (is_stuck and !was_stuck.*) self.dispatch(index, .{
+                    .event = .stuck,
+                    .process = process.label,
+                    .id = process.id.toInt(),
+                    .pid = sources.controller.getPID(process.id),
+                    .time_ms = std.time.milliTimestamp(),
+                });
+            }
+
+            const bells = sources.controller.getBells(process.id);
+            defer heard.* = bells;
+            if (!self.bell_events or bells <= heard.*) continue;
+            self.dispatch(index, .{
+                .event = .bell,
+                .process = process.label,
+                .id = process.id.toInt(),
+                .pid = sources.controller.getPID(process.id),
+                .bells = bells - heard.*,
+                .time_ms = std.time.milliTimestamp(),
+            });
+        }
+    }
+
+    fn run(self: *Dispatcher) void {
+        const changes = self.sources.?.changes;
+        var seen = changes.current();
+        while (!self.stopped.load(.seq_cst)) {
+            self.poll();
+            seen = changes.wait(seen, idle_wait_ms);
+        }
+    }
+
+    fn dispatch(self: *Dispatcher, index: usize, event: Event) void {
+        const input = std.fmt.allocPrint(self.allocator, "{f}\n", .{std.json.fmt(event, .{ .emit_null_optional_fields = false })}) catch |err| {
+            log.warn("failed to encode {s} event for '{s}': {s}", .{ @tagName(event.event), event.process, @errorName(err) });
+            return;
+        };
+        defer self.allocator.free(input);
+        for (self.plugins) |path| self.runPlugin(path, index, event, input);
+    }
+
+    /// Replies are applied only when the plugin exits 0; its stderr goes to
+    /// the log either way.
+    fn runPlugin(self: *Dispatcher, path: []const u8, index: usize, event: Event, input: []const u8) void {
+        const name = std.fs.path.basename(path);
+        const process = &self.processes[index];
+        var env_map = proc_mod.env.buildMetadataMap(self.allocator, .{
+            .label = process.label,
+            .id = process.id,
+            .socket_path = if (self.sources) |sources| sources.socket_path else "",
+            .config_path = self.config_path,
+        }) catch |err| {
+            log.warn("plugin {s} not run: {s}", .{ name, @errorName(err) });
+            return;
+        };
+        defer env_map.deinit();
+
+        var output = proc_mod.hooks.Output.init(self.allocator);
+        defer output.deinit();
+        const result = proc_mod.hooks.runCapture(self.allocator, &.{path}, .{
+            .cwd = self.cwd,
+            .env_map = &env_map,
+            .timeout_ms = self.timeout_ms,
+            .input = input,
+        }, &output);
+        proc_mod.hooks.logOutput(name, output.stderr.items);
+        result catch |err| {
+            log.warn("plugin {s} failed on {s} event for '{s}': {s}", .{ name, @tagName(event.event), process.label, @errorName(err) });
+            return;
+        };
+
+        var lines = std.mem.splitScalar(u8, output.stdout.items, '\n');
+        while (lines.next()) |raw| {
+            const line = std.mem.trim(u8, raw, " \t\r");
+            if (line.len == 0) continue;
+            self.applyReply(index, line) catch |err| {
+                log.warn("plugin {s} command ignored: {s}: {s}", .{ name, @errorName(err), line });
+            };
+        }
+    }
+
+    fn applyReply(self: *Dispatcher, event_index: usize, line: []const u8) !void {
+        const parsed = try std.json.parseFromSlice(Reply, self.allocator, line, .{ .ignore_unknown_fields = true });
+        defer parsed.deinit();
+        const reply = parsed.value;
+
+        const action = std.meta.stringToEnum(Action, reply.action) orelse return error.UnknownCommand;
+        const index = if (reply.process) |label| self.indexOf(label) orelse return error.ProcessNotFound else event_index;
+        switch (action) {
+            .annotate => try self.annotate(index, reply.text),
+            .start => try self.sendCommand(.start, index),
+            .stop => try self.sendCommand(.stop, index),
+            .restart => try self.sendCommand(.restart, index),
+        }
+    }
+
+    /// Replaces the process's annotation; empty text clears it.
+    pub fn annotate(self: *Dispatcher, index: usize, text: []const u8) !void {
+        const trimmed = truncate(std.mem.trim(u8, text, " \t\r\n"), max_annotation_bytes);
+        const owned: []const u8 = if (trimmed.len > 0) try self.allocator.dupe(u8, trimmed) else "";
+        {
+            self.mutex.lock();
+            defer self.mutex.unlock();
+            const previous = self.annotations[index];
+            self.annotations[index] = owned;
+            self.processes[index].annotation = owned;
+            if (previous.len > 0) self.allocator.free(previous);
+        }
+        if (self.sources) |sources| sources.changes.notify();
+    }
+
+    fn sendCommand(self: *Dispatcher, action: ipc.protocol.Command, index: usize) !void {
+        const sources = self.sources orelse return;
+        const label = self.processes[index].label;
+        const request_id = self.next_request_id;
+        self.next_request_id += 1;
+        const response = try sources.handler.handleCommand(self.allocator, .{
+            .request_id = request_id,
+            .action = action,
+            .target = label,
+        });
+        defer response.deinit(self.allocator);
+        if (!response.success) return error.CommandFailed;
+    }
+
+    fn indexOf(self: *const Dispatcher, label: []const u8) ?usize {
+        for (self.processes, 0..) |process, index| {
+            if (std.mem.eql(u8, process.label, label)) return index;
+        }
+        return null;
+    }
+};
+
+/// Relative directories resolve against the config file's directory.
+fn resolveDir(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+    if (std.fs.path.isAbsolute(cfg.plugins_dir)) return allocator.dupe(u8, cfg.plugins_dir);
+    const base = std.fs.path.dirname(cfg.file_path) orelse ".";
+    return std.fs.path.join(allocator, &.{ base, cfg.plugins_dir });
+}
+
+/// Returns the executable files in `dir_path`, sorted by name. Dotfiles and
+/// files without an execute bit (READMEs, disabled plugins) are skipped.
+fn discover(allocator: std.mem.Allocator, dir_path: []const u8) ![]const []const u8 {
+    var dir = try std.fs.cwd().openDir(dir_path, .{ .iterate = true });
+    defer dir.close();
+
+    var found = std.array_list.Managed([]const u8).init(allocator);
+    errdefer {
+        for (found.items) |path| allocator.free(path);
+        found.deinit();
+    }
+    var it = dir.iterate();
+    while (try it.next()) |entry| {
+        if (entry.name.len == 0 or entry.name[0] == '.') continue;
+        const stat = dir.statFile(entry.name) catch continue;
+        if (stat.kind != .file or (stat.mode & 0o111) == 0) continue;
+        const path = try std.fs.path.join(allocator, &.{ dir_path, entry.name });
+        errdefer allocator.free(path);
+        try found.append(path);
+    }
+    std.mem.sort([]const u8, found.items, {}, lessThanString);
+    return found.toOwnedSlice();
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.order(u8, a, b) == .lt;
+}
+
+/// Caps `text` at `limit` bytes without splitting a UTF-8 sequence.
+fn truncate(text: []const u8, limit: usize) []const u8 {
+    if (text.len <= limit) return text;
+    var end = limit;
+    while (end > 0 and (text[end] & 0xc0) == 0x80) end -= 1;
+    return text[0..end];
+}
+
+test "lifecycle events come from status changes between scans" {
+    try std.testing.expectEqual(EventKind.started, eventFor(.starting, .running).?);
+    try std.testing.expectEqual(EventKind.started, eventFor(.halted, .running).?);
+    try std.testing.expectEqual(EventKind.exited, eventFor(.running, .exited).?);
+    try std.testing.expectEqual(EventKind.stopped, eventFor(.halting, .halted).?);
+    try std.testing.expectEqual(EventKind.start_failed, eventFor(.starting, .halted).?);
+    try std.testing.expect(eventFor(.exited, .halted) == null);
+    try std.testing.expect(eventFor(.running, .running) == null);
+    try std.testing.expect(eventFor(.running, .halting) == null);
+}
+
+const FakeSources = struct {
+    status: domain.process.ProcessStatus = .halted,
+    exit_code: ?u32 = null,
+    bells: u32 = 0,
+    restarts: usize = 0,
+    changes: domain.changes.Signal = .{},
+
+    fn sources(self: *FakeSources) Sources {
+        return .{
+            .controller = .{
+                .context = self,
+                .get_process_status = getProcessStatus,
+                .get_pid = getPID,
+                .get_exit_code = getExitCode,
+                .get_bells = getBells,
+            },
+            .changes = &self.changes,
+            .handler = .{ .context = self, .handle = handle },
+        };
+    }
+
+    fn getProcessStatus(context: *anyopaque, _: domain.process.ProcessId) domain.process.ProcessStatus {
+        const self: *FakeSources = @ptrCast(@alignCast(context));
+        return self.status;
+    }
+
+    fn getPID(_: *anyopaque, _: domain.process.ProcessId) i32 {
+        return 4321;
+    }
+
+    fn getExitCode(context: *anyopaque, _: domain.process.ProcessId) ?u32 {
+        const self: *FakeSources = @ptrCast(@alignCast(context));
+        return self.exit_code;
+    }
+
+    fn getBells(context: *anyopaque, _: domain.process.ProcessId) u32 {
+        const self: *FakeSources = @ptrCast(@alignCast(context));
+        return self.bells;
+    }
+
+    fn handle(context: *anyopaque, allocator: std.mem.Allocator, request: ipc.protocol.CommandRequest) anyerror!ipc.protocol.Response {
+        const self: *FakeSources = @ptrCast(@alignCast(context));
+        if (request.action == .restart) self.restarts += 1;
+        return .{ .request_id = request.request_id, .success = true, .error_message = try allocator.dupe(u8, "") };
+    }
+};
+
+test "dispatcher sends events to executable plugins and applies their replies" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("plugins");
+    {
+        const script = try tmp.dir.createFile("plugins/notify", .{ .mode = 0o755 });
+        defer script.close();
+        try script.writeAll(
+            \\#!/bin/sh
+            \\read event
+            \\echo "$event" >> events.log
+            \\case "$event" in
+            \\  *'"event":"exited"'*) echo '{"action":"annotate","text":"crashed"}'; echo '{"action":"restart"}' ;;
+            \\esac
+            \\
+        );
+    }
+    try tmp.dir.writeFile(.{ .sub_path = "plugins/README", .data = "not a plugin" });
+    const root = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(root);
+    const config_path = try std.fs.path.join(std.testing.allocator, &.{ root, "proctmux.yaml" });
+    defer std.testing.allocator.free(config_path);
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.file_path = config_path;
+    cfg.plugins_dir = "plugins";
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    var processes = [_]domain.process.Process{.{
+        .id = domain.process.ProcessId.fromInt(1),
+        .label = "api",
+        .config = &proc_cfg,
+    }};
+    var dispatcher = try Dispatcher.init(std.testing.allocator, processes[0..], &cfg);
+    defer dispatcher.deinit();
+    try std.testing.expectEqual(@as(usize, 1), dispatcher.plugins.len);
+
+    var fake = FakeSources{};
+    dispatcher.sources = fake.sources();
+
+    fake.status = .running;
+    dispatcher.poll();
+    fake.status = .exited;
+    fake.exit_code = 2;
+    dispatcher.poll();
+    dispatcher.poll();
+    fake.bells = 2;
+    dispatcher.poll();
+    dispatcher.poll();
+
+    try std.testing.expectEqualStrings("crashed", processes[0].annotation);
+    try std.testing.expectEqual(@as(usize, 1), fake.restarts);
+    const events = try tmp.dir.readFileAlloc(std.testing.allocator, "events.log", 4096);
+    defer std.testing.allocator.free(events);
+    try std.testing.expect(std.mem.startsWith(u8, events, "{\"event\":\"started\",\"process\":\"api\",\"id\":1,\"pid\":4321,\"time_ms\":"));
+    try std.testing.expect(std.mem.indexOf(u8, events, "\"event\":\"exited\",\"process\":\"api\",\"id\":1,\"pid\":4321,\"exit_code\":2") != null);
+    try std.testing.expect(std.mem.indexOf(u8, events, "\"event\":\"bell\",\"process\":\"api\",\"id\":1,\"pid\":4321,\"bells\":2") != null);
+    try std.testing.expectEqual(@as(usize, 3), std.mem.count(u8, events, "\n"));
+}
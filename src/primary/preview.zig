@@ -0,0 +1,122 @@
+//! Last-output-line previews for the process list.
+//! With `layout.last_line_preview` on, the Primary Server copies the newest non-empty output line of each process into its summary, at most once per `refresh_interval_ms` so steady output does not publish a snapshot per line.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const startup = @import("startup.zig");
+
+pub const refresh_interval_ms: i64 = 1000;
+/// Output read per refresh; a line longer than this previews its tail.
+const tail_bytes = 4096;
+
+/// The newest output line of every process, refreshed on snapshot builds.
+pub const Previews = struct {
+    allocator: std.mem.Allocator,
+    processes: []domain.process.Process,
+    enabled: bool,
+    /// Backing storage for `process.last_line`, indexed like `processes`.
+    lines: [][]const u8,
+    /// Output total at the last refresh, so quiet processes are skipped.
+    output_totals: []u64,
+    refreshed_ms: i64 = 0,
+    /// Set while a wakeup for the next refresh is pending.
+    scheduled: bool = false,
+    /// Guards `last_line` on processes; snapshot builders hold it while
+    /// reading them.
+    mutex: std.Thread.Mutex = .{},
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !Previews {
+        const lines = try allocator.alloc([]const u8, processes.len);
+        errdefer allocator.free(lines);
+        @memset(lines, "");
+        const output_totals = try allocator.alloc(u64, processes.len);
+        @memset(output_totals, 0);
+
+        const mode = if (global_config) |cfg|
+            std.meta.stringToEnum(config.schema.LastLinePreview, cfg.layout.last_line_preview) orelse .off
+        else
+            .off;
+        return .{
+            .allocator = allocator,
+            .processes = processes,
+            .enabled = mode != .off,
+            .lines = lines,
+            .output_totals = output_totals,
+        };
+    }
+
+    pub fn deinit(self: *Previews) void {
+        for (self.lines) |line| {
+            if (line.len > 0) self.allocator.free(line);
+        }
+        self.allocator.free(self.lines);
+        self.allocator.free(self.output_totals);
+    }
+
+    /// Re-reads the newest line of every process that wrote output since the
+    /// last refresh. Calls inside the interval schedule one wakeup for when it
+    /// ends, so the last burst of output is never left out.
+    pub fn refresh(self: *Previews, controller: *proc_mod.controller.Controller, now_ms: i64) void {
+        if (!self.enabled) return;
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        const due_ms = self.refreshed_ms + refresh_interval_ms;
+        if (now_ms < due_ms) {
+            if (!self.scheduled) {
+                self.scheduled = true;
+                controller.changes.notifyAt(due_ms);
+            }
+            return;
+        }
+        self.refreshed_ms = now_ms;
+        self.scheduled = false;
+
+        var buffer: [tail_bytes]u8 = undefined;
+        for (self.processes, self.lines, self.output_totals) |*process, *line, *total| {
+            const written = controller.processStats(process.id).output_bytes;
+            if (written == total.*) continue;
+            total.* = written;
+
+            const latest = lastLine(self.allocator, controller.outputTail(process.id, &buffer)) catch continue;
+            if (line.len > 0) self.allocator.free(line.*);
+            line.* = latest;
+            process.last_line = latest;
+        }
+    }
+};
+
+/// The newest line of `output` with visible text, without escape sequences.
+/// A carriage return redraws its line, so only the text after the last one
+/// counts. The caller owns a non-empty result.
+pub fn lastLine(allocator: std.mem.Allocator, output: []const u8) ![]const u8 {
+    var lines = std.mem.splitBackwardsScalar(u8, output, '\n');
+    while (lines.next()) |raw| {
+        const line = std.mem.trimRight(u8, raw, "\r");
+        const redrawn = if (std.mem.lastIndexOfScalar(u8, line, '\r')) |index| line[index + 1 ..] else line;
+        const plain = try startup.plainReason(allocator, redrawn);
+        const trimmed = std.mem.trim(u8, plain, " \t");
+        if (trimmed.len == plain.len and plain.len > 0) return plain;
+        defer allocator.free(plain);
+        if (trimmed.len > 0) return allocator.dupe(u8, trimmed);
+    }
+    return "";
+}
+
+test "last line skips blank and color-only lines and follows carriage returns" {
+    const line = try lastLine(std.testing.allocator, "booting\n\x1b[32mlistening on :3000\x1b[0m\n\x1b[0m\n\n");
+    defer std.testing.allocator.free(line);
+    try std.testing.expectEqualStrings("listening on :3000", line);
+
+    const progress = try lastLine(std.testing.allocator, "build\n 10%\r 55%\r 90%  \r\n");
+    defer std.testing.allocator.free(progress);
+    try std.testing.expectEqualStrings("90%", progress);
+
+    try std.testing.expectEqualStrings("", try lastLine(std.testing.allocator, "\n \n"));
+}
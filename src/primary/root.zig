@@ -6,12 +6,29 @@ const config = @import("../config/root.zig");
 const domain = @import("../domain/root.zig");
 const ipc = @import("../ipc/root.zig");
 const proc_mod = @import("../proc/root.zig");
+const ring = @import("../ring/root.zig");
+const threads = @import("../threads/root.zig");
 const command_runner = @import("command_runner.zig");
+pub const crash = @import("crash.zig");
+pub const details = @import("details.zig");
+pub const errors = @import("errors.zig");
+pub const idle = @import("idle.zig");
+pub const metrics = @import("metrics.zig");
+pub const open = @import("open.zig");
+pub const plugins = @import("plugins.zig");
+pub const preview = @import("preview.zig");
+pub const schedule = @import("schedule.zig");
+pub const signals = @import("signals.zig");
+pub const startup = @import("startup.zig");
+pub const stuck = @import("stuck.zig");
+pub const watch = @import("watch.zig");
 const test_config = @import("../test_support/config.zig");
 const test_ipc = @import("../test_support/ipc.zig");
 
 const log = std.log.scoped(.primary);
 
+const default_shutdown_timeout_ms = 10_000;
+
 /// Process-owning server used by primary and unified modes. It is the only
 /// module that can mutate AppState and ProcessController together.
 pub const Server = struct {
@@ -20,20 +37,76 @@ pub const Server = struct {
     state: domain.state.AppState,
     current_proc_id: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
     controller: proc_mod.controller.Controller,
+    ipc_clients: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
+    /// Token every IPC connection must present, from the socket claim when
+    /// `security.token` is on. Borrowed.
+    ipc_token: ?[]const u8 = null,
+    /// Set by `jump_to_error` until the output relay takes it.
+    error_jump: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
+    /// When snapshots are next rebuilt for output rates that decay without
+    /// any output to wake them; 0 when none is pending.
+    rate_refresh_ms: std.atomic.Value(i64) = std.atomic.Value(i64).init(0),
+    watcher: watch.Watcher,
+    plugins: plugins.Dispatcher,
+    previews: preview.Previews,
+    error_scanner: errors.Scanner,
+    details: details.Details,
+    auto_opener: open.AutoOpener,
+    idle_monitor: idle.Monitor,
+    stuck_detector: stuck.Detector,
+    scheduler: schedule.Scheduler,
+    crash_tracker: crash.Tracker,
+    startup_report: startup.Report,
 
     pub fn init(allocator: std.mem.Allocator, cfg: *config.schema.Config) !Server {
         var state = try domain.state.AppState.init(allocator, cfg);
         errdefer state.deinit();
+        var watcher = try watch.Watcher.init(allocator, state.processes.items, cfg);
+        errdefer watcher.deinit();
+        var dispatcher = try plugins.Dispatcher.init(allocator, state.processes.items, cfg);
+        errdefer dispatcher.deinit();
+        var previews = try preview.Previews.init(allocator, state.processes.items, cfg);
+        errdefer previews.deinit();
+        var error_scanner = try errors.Scanner.init(allocator, state.processes.items, cfg);
+        errdefer error_scanner.deinit();
+        var process_details = try details.Details.init(allocator, state.processes.items, cfg);
+        errdefer process_details.deinit();
+        var auto_opener = try open.AutoOpener.init(allocator, state.processes.items, cfg);
+        errdefer auto_opener.deinit();
+        var crash_tracker = try crash.Tracker.init(allocator, state.processes.items);
+        errdefer crash_tracker.deinit();
 
         return .{
             .allocator = allocator,
             .cfg = cfg,
             .state = state,
             .controller = proc_mod.controller.Controller.init(allocator, cfg),
+            .watcher = watcher,
+            .plugins = dispatcher,
+            .previews = previews,
+            .error_scanner = error_scanner,
+            .details = process_details,
+            .auto_opener = auto_opener,
+            .idle_monitor = idle.Monitor.init(state.processes.items, cfg),
+            .stuck_detector = stuck.Detector.init(state.processes.items),
+            .scheduler = schedule.Scheduler.init(allocator),
+            .crash_tracker = crash_tracker,
+            .startup_report = startup.Report.init(allocator),
         };
     }
 
     pub fn deinit(self: *Server) void {
+        self.startup_report.deinit();
+        self.plugins.deinit();
+        self.previews.deinit();
+        self.error_scanner.deinit();
+        self.details.deinit();
+        self.scheduler.deinit();
+        self.crash_tracker.deinit();
+        self.idle_monitor.deinit();
+        self.stuck_detector.deinit();
+        self.auto_opener.deinit();
+        self.watcher.deinit();
         self.controller.deinit();
         self.state.deinit();
     }
@@ -49,6 +122,13 @@ pub const Server = struct {
     pub fn setCurrentProcess(self: *Server, id: domain.process.ProcessId) void {
         self.state.current_proc_id = id;
         self.current_proc_id.store(id.toInt(), .seq_cst);
+        self.controller.changes.notify();
+    }
+
+    /// The process a `jump_to_error` asked the output relay to hold at its
+    /// first error, once; `.none` when none is pending.
+    pub fn takeErrorJump(self: *Server) domain.process.ProcessId {
+        return domain.process.ProcessId.fromInt(self.error_jump.swap(0, .seq_cst));
     }
 
     pub fn getProcessController(self: *Server) domain.process.ProcessController {
@@ -62,6 +142,15 @@ pub const Server = struct {
         };
     }
 
+    /// Like `commandHandler`, for commands no person asked for, which may not
+    /// start a crash-looping process.
+    pub fn automaticCommandHandler(self: *Server) ipc.server.CommandHandler {
+        return .{
+            .context = self,
+            .handle = handleAutomaticCommandAdapter,
+        };
+    }
+
     /// Produces client-visible snapshots on demand for IPC clients. The snapshot
     /// projection deliberately excludes process execution config and secrets.
     pub fn snapshotProvider(self: *Server) ipc.server.SnapshotProvider {
@@ -71,16 +160,71 @@ pub const Server = struct {
         };
     }
 
+    /// Resolves output stream requests to process scrollback buffers, which
+    /// live as long as the controller and so outlive the IPC server.
+    pub fn outputProvider(self: *Server) ipc.server.OutputProvider {
+        return .{
+            .context = self,
+            .scrollback = outputBufferAdapter,
+        };
+    }
+
+    /// Answers detail requests with the command lines and working directories
+    /// `details` resolved at startup, which snapshots leave out.
+    pub fn detailsProvider(self: *Server) ipc.server.DetailsProvider {
+        return .{
+            .context = self,
+            .details_line = detailsLineAdapter,
+        };
+    }
+
+    /// Answers environment requests with what a process would start with
+    /// now, resolved from this primary's own environment.
+    pub fn envProvider(self: *Server) ipc.server.EnvProvider {
+        return .{
+            .context = self,
+            .env_line = envLineAdapter,
+        };
+    }
+
     /// Starts autostart processes before clients attach so initial snapshots
-    /// already reflect the configured startup state.
+    /// already reflect the configured startup state. Processes held back by
+    /// `startup_delay_ms` or `autostart_stagger_ms` are queued on the
+    /// scheduler instead. Each attempt goes into the startup report that
+    /// clients show once it settles.
     pub fn startAutostartProcesses(self: *Server) void {
+        const now_ms = std.time.milliTimestamp();
+        var index: usize = 0;
         for (self.state.processes.items) |*process| {
-            if (process.config.autostart) self.startProcess(process) catch |err| {
-                log.warn("autostart failed for process '{s}': {s}", .{ process.label, @errorName(err) });
-            };
+            if (!process.config.autostart) continue;
+            defer index += 1;
+            const delay_ms = schedule.autostartDelayMs(self.cfg, process.config, index);
+            if (delay_ms > 0) {
+                if (self.scheduler.add(process, now_ms + delay_ms, now_ms)) {
+                    self.startup_report.expect();
+                    continue;
+                } else |err| {
+                    log.warn("failed to delay autostart of '{s}'; starting now: {s}", .{ process.label, @errorName(err) });
+                }
+            }
+            self.autostart(process, false, now_ms);
         }
     }
 
+    fn autostart(self: *Server, process: *domain.process.Process, delayed: bool, now_ms: i64) void {
+        var spawn_error: ?anyerror = null;
+        self.startProcess(process) catch |err| {
+            log.warn("autostart failed for process '{s}': {s}", .{ process.label, @errorName(err) });
+            spawn_error = err;
+        };
+        self.startup_report.record(process, spawn_error, delayed, now_ms) catch |err| {
+            log.warn("failed to record autostart of '{s}': {s}", .{ process.label, @errorName(err) });
+        };
+        // The report settles on a snapshot build, which needs a wakeup once
+        // the settle window has passed.
+        self.controller.changes.notifyAt(now_ms + startup.settle_ms);
+    }
+
     /// Forwards raw terminal input to the selected process. Missing/stopped
     /// processes are ignored because process selection can race with exits.
     pub fn sendInputToCurrentProcess(self: *Server, bytes: []const u8) !void {
@@ -92,21 +236,84 @@ pub const Server = struct {
         };
     }
 
+    /// Resizes the selected process terminal to the viewer size. Like input
+    /// forwarding, a missing or stopped selection is not an error.
+    pub fn resizeCurrentProcess(self: *Server, rows: u16, cols: u16) !void {
+        const id = self.currentProcessID();
+        if (id.isNone()) return;
+        self.controller.resizeProcess(id, rows, cols) catch |err| switch (err) {
+            error.ProcessNotFound, error.ProcessNotRunning => return,
+            else => return err,
+        };
+    }
+
     pub fn serveCommandsAtPath(
         self: *Server,
         socket_path: []const u8,
         stopped: *std.atomic.Value(bool),
     ) !void {
+        self.controller.socket_path = socket_path;
+        // Started ahead of autostart so plugins see those processes start.
+        try self.plugins.start(.{
+            .controller = self.getProcessController(),
+            .changes = &self.controller.changes,
+            .handler = self.automaticCommandHandler(),
+            .socket_path = socket_path,
+            .stuck_detector = &self.stuck_detector,
+        });
+        defer self.plugins.stop();
+        try self.auto_opener.start(&self.controller);
+        defer self.auto_opener.stop();
         self.startAutostartProcesses();
-        try ipc.server.serveCommandsAtPathWithSnapshots(
+        try self.scheduler.start(&self.controller, .{ .context = self, .start = scheduledStartAdapter });
+        defer self.scheduler.stop();
+        // Stopped before shutdown so a late change cannot restart a process
+        // that is being stopped for exit.
+        try self.watcher.start(.{ .context = self, .restart = watchRestartAdapter });
+        defer self.watcher.stop();
+        try self.idle_monitor.start(&self.controller, self.idleStopper());
+        defer self.idle_monitor.stop();
+        try self.stuck_detector.start(&self.controller);
+        defer self.stuck_detector.stop();
+        var token_authorizer: ipc.server.TokenAuthorizer = undefined;
+        const authorizer: ?ipc.server.PeerAuthorizer = if (self.ipc_token) |token| blk: {
+            token_authorizer = .{ .token = token, .inner = ipc.server.defaultPeerAuthorizer() };
+            break :blk token_authorizer.authorizer();
+        } else null;
+        try ipc.server.serveCommandsAtPathWithSnapshotsAndOutput(
             self.allocator,
             socket_path,
             self.commandHandler(),
             self.snapshotProvider(),
+            self.outputProvider(),
+            self.detailsProvider(),
+            self.envProvider(),
             stopped,
+            &self.ipc_clients,
+            &self.controller.changes,
+            pollIntervals(self.cfg),
+            authorizer,
         );
     }
 
+    /// Stops every running process concurrently, honoring each stop signal and
+    /// timeout, then SIGKILLs whatever is left once `shutdown_timeout_ms` has
+    /// passed so one stuck process cannot hold the exit open or be orphaned.
+    pub fn shutdown(self: *Server) void {
+        const deadline_ms = shutdownTimeoutMs(self.cfg);
+        var finished = std.atomic.Value(bool).init(false);
+        const watchdog: ?std.Thread = threads.spawn(.{}, killAtDeadline, .{ &self.controller, &finished, deadline_ms }) catch |err| blk: {
+            log.warn("failed to start shutdown watchdog; stopping without a deadline: {s}", .{@errorName(err)});
+            break :blk null;
+        };
+
+        self.commandRunner().stopAll(self.allocator) catch |err| {
+            log.warn("shutdown failed to stop running processes: {s}", .{@errorName(err)});
+        };
+        finished.store(true, .seq_cst);
+        if (watchdog) |thread| thread.join();
+    }
+
     pub fn handleRequest(
         self: *Server,
         allocator: std.mem.Allocator,
@@ -120,9 +327,15 @@ pub const Server = struct {
             .state = &self.state,
             .controller = &self.controller,
             .current_process_id = &self.current_proc_id,
+            .error_jump = &self.error_jump,
+            .crashes = &self.crash_tracker,
         };
     }
 
+    fn idleStopper(self: *Server) idle.Stopper {
+        return .{ .context = self, .stop = idleStopAdapter };
+    }
+
     fn startProcess(self: *Server, process: *domain.process.Process) !void {
         if (self.controller.isRunning(process.id)) return;
         try self.controller.cleanupProcess(process.id);
@@ -131,6 +344,31 @@ pub const Server = struct {
     }
 };
 
+pub fn shutdownTimeoutMs(cfg: *const config.schema.Config) u64 {
+    if (cfg.shutdown_timeout_ms > 0) return @intCast(cfg.shutdown_timeout_ms);
+    return default_shutdown_timeout_ms;
+}
+
+/// Broadcast and stream polling from `general`; zero keeps the IPC defaults.
+fn pollIntervals(cfg: *const config.schema.Config) ipc.server.PollIntervals {
+    var intervals: ipc.server.PollIntervals = .{};
+    if (cfg.general.refresh_interval_ms > 0) intervals.snapshot_ms = @intCast(cfg.general.refresh_interval_ms);
+    if (cfg.general.output_poll_interval_ms > 0) intervals.output_ms = @intCast(cfg.general.output_poll_interval_ms);
+    return intervals;
+}
+
+fn killAtDeadline(controller: *proc_mod.controller.Controller, finished: *std.atomic.Value(bool), deadline_ms: u64) void {
+    const deadline = std.time.milliTimestamp() + @as(i64, @intCast(deadline_ms));
+    while (!finished.load(.seq_cst)) {
+        if (std.time.milliTimestamp() >= deadline) {
+            log.warn("shutdown deadline of {d}ms passed; killing remaining processes", .{deadline_ms});
+            controller.killRunning();
+            return;
+        }
+        std.Thread.sleep(10 * std.time.ns_per_ms);
+    }
+}
+
 fn handleCommandAdapter(
     context: *anyopaque,
     allocator: std.mem.Allocator,
@@ -140,13 +378,134 @@ fn handleCommandAdapter(
     return self.handleRequest(allocator, request);
 }
 
+fn handleAutomaticCommandAdapter(
+    context: *anyopaque,
+    allocator: std.mem.Allocator,
+    request: ipc.protocol.CommandRequest,
+) !ipc.protocol.Response {
+    const self: *Server = @ptrCast(@alignCast(context));
+    var runner = self.commandRunner();
+    runner.automatic = true;
+    return runner.handleRequest(allocator, request);
+}
+
 fn snapshotLineAdapter(context: *anyopaque, allocator: std.mem.Allocator) ![]const u8 {
     const self: *Server = @ptrCast(@alignCast(context));
+    self.startup_report.settle(&self.controller, std.time.milliTimestamp()) catch |err| {
+        log.warn("failed to settle startup report: {s}", .{@errorName(err)});
+    };
+    self.previews.refresh(&self.controller, std.time.milliTimestamp());
+    self.error_scanner.refresh(&self.controller, std.time.milliTimestamp());
+    // Summaries borrow `watch_change`, `annotation`, `last_line`, and
+    // `crash_output` and read `error_count`, `idle_stops`, `autostart_in_s`,
+    // `crash_looping`, and `stuck`, so hold their writers until serialized.
+    self.watcher.mutex.lock();
+    defer self.watcher.mutex.unlock();
+    self.plugins.mutex.lock();
+    defer self.plugins.mutex.unlock();
+    self.previews.mutex.lock();
+    defer self.previews.mutex.unlock();
+    self.error_scanner.mutex.lock();
+    defer self.error_scanner.mutex.unlock();
+    self.idle_monitor.mutex.lock();
+    defer self.idle_monitor.mutex.unlock();
+    self.scheduler.mutex.lock();
+    defer self.scheduler.mutex.unlock();
+    self.crash_tracker.mutex.lock();
+    defer self.crash_tracker.mutex.unlock();
+    self.stuck_detector.mutex.lock();
+    defer self.stuck_detector.mutex.unlock();
     var snapshot = try domain.client_snapshot.fromAppState(allocator, &self.state, self.getProcessController());
     defer snapshot.deinit(allocator);
+    snapshot.value.startup = self.startup_report.summary();
+    scheduleRateRefresh(self, snapshot.value.processes, std.time.milliTimestamp());
     return ipc.protocol.snapshotLine(allocator, snapshot.view());
 }
 
+/// A process that goes quiet stops waking snapshot builds, so while any
+/// output rate is above zero, rebuild once a second until it falls to zero.
+fn scheduleRateRefresh(self: *Server, processes: []const domain.client_snapshot.ProcessSummary, now_ms: i64) void {
+    if (now_ms < self.rate_refresh_ms.load(.seq_cst)) return;
+    for (processes) |summary| {
+        if (summary.output_rate == 0) continue;
+        const due_ms = now_ms + std.time.ms_per_s;
+        self.rate_refresh_ms.store(due_ms, .seq_cst);
+        self.controller.changes.notifyAt(due_ms);
+        return;
+    }
+}
+
+fn watchRestartAdapter(context: *anyopaque, process: *domain.process.Process) !bool {
+    const self: *Server = @ptrCast(@alignCast(context));
+    if (!self.controller.isRunning(process.id)) return false;
+    try self.commandRunner().restartProcess(process);
+    return true;
+}
+
+fn scheduledStartAdapter(context: *anyopaque, process: *domain.process.Process) void {
+    const self: *Server = @ptrCast(@alignCast(context));
+    if (self.controller.processStats(process.id).starts > 0) {
+        self.startup_report.skip();
+        return;
+    }
+    self.autostart(process, true, std.time.milliTimestamp());
+}
+
+fn idleStopAdapter(context: *anyopaque, process: *domain.process.Process) !void {
+    const self: *Server = @ptrCast(@alignCast(context));
+    try self.commandRunner().stopProcess(process);
+}
+
+fn outputBufferAdapter(context: *anyopaque, label: []const u8, stderr: bool) !?*ring.RingBuffer {
+    const self: *Server = @ptrCast(@alignCast(context));
+    const process = self.state.getProcessByLabel(label) orelse return null;
+    if (stderr) return try self.controller.stderrBuffer(process.id);
+    return try self.controller.outputBuffer(process.id);
+}
+
+fn detailsLineAdapter(context: *anyopaque, allocator: std.mem.Allocator, request_id: u64, label: []const u8) !?[]const u8 {
+    const self: *Server = @ptrCast(@alignCast(context));
+    const process = self.state.getProcessByLabel(label) orelse return null;
+    return try ipc.protocol.detailsDataLine(allocator, request_id, process.command, process.cwd);
+}
+
+fn envLineAdapter(context: *anyopaque, allocator: std.mem.Allocator, request_id: u64, label: []const u8) !?[]const u8 {
+    const self: *Server = @ptrCast(@alignCast(context));
+    const process = self.state.getProcessByLabel(label) orelse return null;
+    var base = try std.process.getEnvMap(allocator);
+    defer base.deinit();
+    var inspection = try proc_mod.env.inspect(allocator, &base, process.config, self.controller.metadata(process.id, process.config));
+    defer inspection.deinit();
+
+    const variables = try allocator.alloc(ipc.protocol.EnvVariable, inspection.variables.len);
+    defer allocator.free(variables);
+    for (inspection.variables, variables) |variable, *out| {
+        out.* = .{
+            .name = variable.name,
+            .value = variable.value,
+            .source = @tagName(variable.source),
+            .overridden = variable.overridden,
+        };
+    }
+    return try ipc.protocol.envDataLine(allocator, request_id, variables);
+}
+
+test {
+    _ = crash;
+    _ = details;
+    _ = errors;
+    _ = idle;
+    _ = metrics;
+    _ = open;
+    _ = plugins;
+    _ = preview;
+    _ = schedule;
+    _ = signals;
+    _ = startup;
+    _ = stuck;
+    _ = watch;
+}
+
 test "primary command handler starts switches and stops processes" {
     var cfg = config.schema.Config.empty(std.testing.allocator);
     defer cfg.deinit();
@@ -215,6 +574,112 @@ test "primary command handler stops all running processes" {
     try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(2)));
 }
 
+test "primary command handler targets every replica of a group" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    for ([_][]const u8{ "worker-1", "worker-2" }) |label| {
+        try test_config.putShellProcessWithStopTimeout(&cfg, label, "sleep 5", 500);
+        cfg.procs.getPtr(label).?.replica_group = try std.testing.allocator.dupe(u8, "worker");
+    }
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    var started = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 1,
+        .action = .start,
+        .target = "worker",
+    });
+    defer started.deinit(std.testing.allocator);
+    try std.testing.expect(started.success);
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(2)));
+
+    var stopped = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 2,
+        .action = .stop,
+        .target = "worker-2",
+    });
+    defer stopped.deinit(std.testing.allocator);
+    try std.testing.expect(stopped.success);
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+    try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(2)));
+
+    var stopped_group = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 3,
+        .action = .stop,
+        .target = "worker",
+    });
+    defer stopped_group.deinit(std.testing.allocator);
+    try std.testing.expect(stopped_group.success);
+    try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+}
+
+test "primary command handler applies batch commands to every target" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    for ([_][]const u8{ "api", "db", "worker" }) |label| {
+        try test_config.putShellProcessWithStopTimeout(&cfg, label, "sleep 5", 500);
+    }
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    var started = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 1,
+        .action = .start,
+        .targets = &.{ "api", "worker" },
+    });
+    defer started.deinit(std.testing.allocator);
+    try std.testing.expect(started.success);
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+    try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(2)));
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(3)));
+
+    var unknown = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 2,
+        .action = .stop,
+        .targets = &.{ "api", "cache" },
+    });
+    defer unknown.deinit(std.testing.allocator);
+    try std.testing.expect(!unknown.success);
+    try std.testing.expectEqualStrings("process not found: cache", unknown.error_message);
+    try std.testing.expectEqual(ipc.protocol.ErrorCode.not_found, unknown.code);
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+
+    var stopped = try primary.handleRequest(std.testing.allocator, .{
+        .request_id = 3,
+        .action = .stop,
+        .targets = &.{ "api", "worker" },
+    });
+    defer stopped.deinit(std.testing.allocator);
+    try std.testing.expect(stopped.success);
+    try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+    try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(3)));
+}
+
+test "primary shutdown kills processes that outlast the deadline" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    cfg.shutdown_timeout_ms = 200;
+    try test_config.putShellProcessWithStopTimeout(&cfg, "stubborn", "trap '' TERM; sleep 5", 5000);
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    const id = domain.process.ProcessId.fromInt(1);
+    _ = try primary.controller.startProcess(id, cfg.procs.getPtr("stubborn").?);
+    std.Thread.sleep(100 * std.time.ns_per_ms);
+
+    const started_ms = std.time.milliTimestamp();
+    primary.shutdown();
+    try std.testing.expect(!primary.controller.isRunning(id));
+    try std.testing.expect(std.time.milliTimestamp() - started_ms < 3000);
+}
+
 test "primary command handler reports missing process names" {
     var cfg = config.schema.Config.empty(std.testing.allocator);
     defer cfg.deinit();
@@ -232,6 +697,99 @@ test "primary command handler reports missing process names" {
 
     try std.testing.expect(!response.success);
     try std.testing.expectEqualStrings("missing process name", response.error_message);
+    try std.testing.expectEqual(ipc.protocol.ErrorCode.invalid_request, response.code);
+}
+
+test "primary command handler opens process urls with open_cmd" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &cfg.open_cmd, "true");
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "sleep 5", 500);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "worker", "sleep 5", 500);
+    cfg.procs.getPtr("api").?.url = try std.testing.allocator.dupe(u8, "http://localhost:3000");
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    var opened = try primary.handleRequest(std.testing.allocator, .{ .request_id = 1, .action = .open_url, .target = "api" });
+    defer opened.deinit(std.testing.allocator);
+    try std.testing.expect(opened.success);
+
+    var no_url = try primary.handleRequest(std.testing.allocator, .{ .request_id = 2, .action = .open_url, .target = "worker" });
+    defer no_url.deinit(std.testing.allocator);
+    try std.testing.expect(!no_url.success);
+    try std.testing.expectEqualStrings("NoUrlConfigured", no_url.error_message);
+
+    config.schema.deinitStringList(&cfg.open_cmd);
+    cfg.open_cmd = config.schema.StringList.init(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &cfg.open_cmd, "proctmux-missing-opener");
+    var missing = try primary.handleRequest(std.testing.allocator, .{ .request_id = 3, .action = .open_cwd, .target = "worker" });
+    defer missing.deinit(std.testing.allocator);
+    try std.testing.expect(!missing.success);
+    try std.testing.expectEqualStrings("OpenCommandNotFound", missing.error_message);
+}
+
+test "primary idle monitor stops quiet processes and requests auto shutdown" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    cfg.auto_shutdown = true;
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "sleep 5", 500);
+    cfg.procs.getPtr("api").?.idle_timeout_ms = 1000;
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+    primary.idle_monitor.controller = &primary.controller;
+    primary.idle_monitor.stopper = primary.idleStopper();
+
+    const id = domain.process.ProcessId.fromInt(1);
+    var started = try primary.handleRequest(std.testing.allocator, .{ .request_id = 1, .action = .start, .target = "api" });
+    defer started.deinit(std.testing.allocator);
+    try std.testing.expect(started.success);
+    const started_ms = primary.controller.processStats(id).last_started_ms;
+    _ = signals.takeRequested();
+
+    primary.idle_monitor.poll(started_ms + 500);
+    try std.testing.expect(primary.controller.isRunning(id));
+    try std.testing.expect(!signals.takeRequested());
+
+    primary.idle_monitor.poll(started_ms + 5000);
+    try std.testing.expect(!primary.controller.isRunning(id));
+    try std.testing.expectEqual(@as(u32, 1), primary.getState().processes.items[0].idle_stops);
+    try std.testing.expect(signals.takeRequested());
+}
+
+test "primary stuck detector flags quiet processes until they print again" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "sleep 5", 500);
+    cfg.procs.getPtr("api").?.expect_output_within_ms = 1000;
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+    try std.testing.expect(primary.stuck_detector.enabled);
+    primary.stuck_detector.controller = &primary.controller;
+
+    const id = domain.process.ProcessId.fromInt(1);
+    var started = try primary.handleRequest(std.testing.allocator, .{ .request_id = 1, .action = .start, .target = "api" });
+    defer started.deinit(std.testing.allocator);
+    try std.testing.expect(started.success);
+    const started_ms = primary.controller.processStats(id).last_started_ms;
+    const process = &primary.getState().processes.items[0];
+
+    primary.stuck_detector.poll(started_ms + 500);
+    try std.testing.expect(!primary.stuck_detector.isStuck(process));
+    primary.stuck_detector.poll(started_ms + 5000);
+    try std.testing.expect(primary.stuck_detector.isStuck(process));
+    try std.testing.expect(primary.controller.isRunning(id));
+
+    var stopped = try primary.handleRequest(std.testing.allocator, .{ .request_id = 2, .action = .stop, .target = "api" });
+    defer stopped.deinit(std.testing.allocator);
+    try waitForProcessStopped(&primary, id);
+    primary.stuck_detector.poll(started_ms + 6000);
+    try std.testing.expect(!primary.stuck_detector.isStuck(process));
 }
 
 test "primary startup starts autostart processes only" {
@@ -249,6 +807,81 @@ test "primary startup starts autostart processes only" {
 
     try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
     try std.testing.expect(!primary.controller.isRunning(domain.process.ProcessId.fromInt(2)));
+
+    try std.testing.expectEqual(@as(i64, 0), primary.startup_report.summary().settled_ms);
+    try primary.startup_report.settle(&primary.controller, primary.startup_report.last_attempt_ms + startup.settle_ms);
+    const summary = primary.startup_report.summary();
+    try std.testing.expectEqual(@as(usize, 1), summary.started.len);
+    try std.testing.expectEqualStrings("api", summary.started[0]);
+    try std.testing.expectEqual(@as(usize, 0), summary.failed.len);
+}
+
+test "primary startup staggers autostart processes" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    cfg.autostart_stagger_ms = 3000;
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "sleep 5", 500);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "worker", "sleep 5", 500);
+    cfg.procs.getPtr("api").?.autostart = true;
+    cfg.procs.getPtr("worker").?.autostart = true;
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+    primary.scheduler.controller = &primary.controller;
+    primary.scheduler.starter = .{ .context = &primary, .start = scheduledStartAdapter };
+
+    primary.startAutostartProcesses();
+    const worker_id = domain.process.ProcessId.fromInt(2);
+    try std.testing.expect(primary.controller.isRunning(domain.process.ProcessId.fromInt(1)));
+    try std.testing.expect(!primary.controller.isRunning(worker_id));
+    try std.testing.expectEqual(@as(u32, 3), primary.getState().processes.items[1].autostart_in_s);
+
+    const now_ms = primary.startup_report.last_attempt_ms;
+    try primary.startup_report.settle(&primary.controller, now_ms + startup.settle_ms);
+    try std.testing.expectEqual(@as(i64, 0), primary.startup_report.summary().settled_ms);
+
+    try std.testing.expectEqual(@as(?i64, 1000), primary.scheduler.poll(now_ms + 1000));
+    try std.testing.expectEqual(@as(u32, 2), primary.getState().processes.items[1].autostart_in_s);
+    try std.testing.expectEqual(@as(?i64, null), primary.scheduler.poll(now_ms + 3000));
+    try std.testing.expect(primary.controller.isRunning(worker_id));
+    try std.testing.expectEqual(@as(u32, 0), primary.getState().processes.items[1].autostart_in_s);
+
+    try primary.startup_report.settle(&primary.controller, primary.startup_report.last_attempt_ms + startup.settle_ms);
+    try std.testing.expectEqual(@as(usize, 2), primary.startup_report.summary().started.len);
+}
+
+test "primary refuses automatic starts of crash-looping processes" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "echo boom; sleep 0.1; exit 1", 500);
+    cfg.procs.getPtr("api").?.crash_loop_exits = 2;
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+    const automatic = primary.automaticCommandHandler();
+    const id = domain.process.ProcessId.fromInt(1);
+
+    for (0..2) |index| {
+        var started = try automatic.handleCommand(std.testing.allocator, .{ .request_id = @intCast(index + 1), .action = .start, .target = "api" });
+        defer started.deinit(std.testing.allocator);
+        try std.testing.expect(started.success);
+        try waitForProcessStopped(&primary, id);
+    }
+
+    var refused = try automatic.handleCommand(std.testing.allocator, .{ .request_id = 3, .action = .restart, .target = "api" });
+    defer refused.deinit(std.testing.allocator);
+    try std.testing.expect(!refused.success);
+    try std.testing.expectEqualStrings("CrashLooping", refused.error_message);
+    const process = &primary.getState().processes.items[0];
+    try std.testing.expect(process.crash_looping);
+    try std.testing.expectEqualStrings("boom", process.crash_output);
+
+    var manual = try primary.handleRequest(std.testing.allocator, .{ .request_id = 4, .action = .start, .target = "api" });
+    defer manual.deinit(std.testing.allocator);
+    try std.testing.expect(manual.success);
+    try std.testing.expect(!process.crash_looping);
 }
 
 test "primary can start a process again after natural exit" {
@@ -341,6 +974,40 @@ test "primary snapshot provider serializes minimal snapshot" {
     try std.testing.expect(std.mem.indexOf(u8, line, "\"env\"") == null);
 }
 
+test "primary env provider resolves a process environment" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "sleep 5", 500);
+    try config.schema.putOwnedString(std.testing.allocator, &cfg.procs.getPtr("api").?.env, "PORT", "3000");
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    const provider = primary.envProvider();
+    try std.testing.expect(try provider.envLine(std.testing.allocator, 7, "nope") == null);
+    const line = (try provider.envLine(std.testing.allocator, 7, "api")).?;
+    defer std.testing.allocator.free(line);
+
+    var data = try ipc.protocol.parseEnvDataLine(std.testing.allocator, line);
+    defer data.deinit();
+    try std.testing.expectEqual(@as(u64, 7), data.requestId());
+    var saw_port = false;
+    var saw_label = false;
+    for (data.variables()) |variable| {
+        if (std.mem.eql(u8, variable.name, "PORT")) {
+            saw_port = true;
+            try std.testing.expectEqualStrings("3000", variable.value);
+            try std.testing.expectEqualStrings("config", variable.source);
+        }
+        if (std.mem.eql(u8, variable.name, "PROCTMUX_LABEL")) {
+            saw_label = true;
+            try std.testing.expectEqualStrings("metadata", variable.source);
+        }
+    }
+    try std.testing.expect(saw_port and saw_label);
+}
+
 test "primary command server handles repeated IPC clients" {
     const path = "/tmp/proctmux-zig-primary-server-loop-test.socket";
     std.fs.deleteFileAbsolute(path) catch {};
@@ -380,6 +1047,174 @@ test "primary command server handles repeated IPC clients" {
     if (run.err) |err| return err;
 }
 
+test "primary serves scrollback tails and process details to IPC clients" {
+    const path = "/tmp/proctmux-zig-primary-scrollback-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "printf 'one\\ntwo\\nthree\\n'; sleep 5", 500);
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    var stopped = std.atomic.Value(bool).init(false);
+    var run = PrimaryServerRun{
+        .primary = &primary,
+        .path = path,
+        .stopped = &stopped,
+    };
+    const thread = try std.Thread.spawn(.{}, runPrimaryServer, .{&run});
+    test_ipc.waitForSocketFile(path);
+
+    var start_response = try ipc.client.sendCommandToPath(std.testing.allocator, path, 1, .start, "api");
+    defer start_response.deinit(std.testing.allocator);
+    try std.testing.expect(start_response.success);
+    try waitForPrimaryScrollbackContains(&primary, domain.process.ProcessId.fromInt(1), "three");
+
+    var ipc_client = try ipc.client.Client.connect(std.testing.allocator, path);
+    defer ipc_client.deinit();
+
+    const tail = try ipc_client.fetchScrollback("api", .{ .lines = 2 });
+    defer tail.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("two\r\nthree\r\n", tail.data.data);
+
+    const missing = try ipc_client.fetchScrollback("nope", .{});
+    defer missing.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("process not found: nope", missing.refused.error_message);
+
+    const process_details = try ipc_client.fetchDetails("api");
+    defer process_details.deinit(std.testing.allocator);
+    try std.testing.expectEqualStrings("printf 'one\\ntwo\\nthree\\n'; sleep 5", process_details.data.command);
+    try std.testing.expect(process_details.data.cwd.len > 0);
+
+    stopped.store(true, .seq_cst);
+    test_ipc.unblockServer(path);
+    thread.join();
+    if (run.err) |err| return err;
+}
+
+test "primary streams process output to IPC subscribers" {
+    const path = "/tmp/proctmux-zig-primary-output-stream-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    try config.defaults.apply(&cfg, std.testing.allocator);
+    try test_config.putShellProcessWithStopTimeout(&cfg, "api", "echo before; read line; echo after-$line; sleep 5", 500);
+
+    var primary = try Server.init(std.testing.allocator, &cfg);
+    defer primary.deinit();
+
+    var stopped = std.atomic.Value(bool).init(false);
+    var run = PrimaryServerRun{
+        .primary = &primary,
+        .path = path,
+        .stopped = &stopped,
+    };
+    const thread = try std.Thread.spawn(.{}, runPrimaryServer, .{&run});
+    test_ipc.waitForSocketFile(path);
+
+    var missing_client = try ipc.client.Client.connect(std.testing.allocator, path);
+    defer missing_client.deinit();
+    var missing = try missing_client.requestOutputStream("nope", .{});
+    defer missing.deinit(std.testing.allocator);
+    try std.testing.expect(!missing.success);
+    try std.testing.expectEqualStrings("process not found: nope", missing.error_message);
+
+    var start_response = try ipc.client.sendCommandToPath(std.testing.allocator, path, 1, .start, "api");
+    defer start_response.deinit(std.testing.allocator);
+    try std.testing.expect(start_response.success);
+    try waitForPrimaryScrollbackContains(&primary, domain.process.ProcessId.fromInt(1), "before");
+
+    var ipc_client = try ipc.client.Client.connect(std.testing.allocator, path);
+    defer ipc_client.deinit();
+    var accepted = try ipc_client.requestOutputStream("api", .{});
+    defer accepted.deinit(std.testing.allocator);
+    try std.testing.expect(accepted.success);
+
+    var history = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer history.deinit();
+    while (true) {
+        const output_frame = try ipc_client.readOutputFrame();
+        defer output_frame.deinit(std.testing.allocator);
+        if (output_frame.kind == .history_end) break;
+        try std.testing.expectEqual(ipc.frame.Kind.history, output_frame.kind);
+        try history.appendSlice(output_frame.payload);
+    }
+    try std.testing.expect(std.mem.indexOf(u8, history.items, "before") != null);
+
+    try primary.controller.sendBytes(domain.process.ProcessId.fromInt(1), "live\n");
+    var live = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer live.deinit();
+    while (std.mem.indexOf(u8, live.items, "after-live") == null) {
+        const output_frame = try ipc_client.readOutputFrame();
+        defer output_frame.deinit(std.testing.allocator);
+        try std.testing.expectEqual(ipc.frame.Kind.output, output_frame.kind);
+        try live.appendSlice(output_frame.payload);
+    }
+
+    stopped.store(true, .seq_cst);
+    test_ipc.unblockServer(path);
+    thread.join();
+    if (run.err) |err| return err;
+}
+
+test "primary stop and shutdown leave no threads running" {
+    const path = "/tmp/proctmux-zig-primary-thread-leak-test.socket";
+    std.fs.deleteFileAbsolute(path) catch {};
+    defer std.fs.deleteFileAbsolute(path) catch {};
+    const baseline = threads.live();
+
+    {
+        var cfg = config.schema.Config.empty(std.testing.allocator);
+        defer cfg.deinit();
+        try config.defaults.apply(&cfg, std.testing.allocator);
+        try test_config.putShellProcessWithStopTimeout(&cfg, "api", "echo ready; sleep 5", 500);
+
+        var primary = try Server.init(std.testing.allocator, &cfg);
+        defer primary.deinit();
+
+        var stopped = std.atomic.Value(bool).init(false);
+        var run = PrimaryServerRun{
+            .primary = &primary,
+            .path = path,
+            .stopped = &stopped,
+        };
+        const thread = try std.Thread.spawn(.{}, runPrimaryServer, .{&run});
+        test_ipc.waitForSocketFile(path);
+
+        var start_response = try ipc.client.sendCommandToPath(std.testing.allocator, path, 1, .start, "api");
+        defer start_response.deinit(std.testing.allocator);
+        try std.testing.expect(start_response.success);
+        try waitForPrimaryScrollbackContains(&primary, domain.process.ProcessId.fromInt(1), "ready");
+
+        var snapshot_client = try ipc.client.Client.connect(std.testing.allocator, path);
+        defer snapshot_client.deinit();
+        var snapshot_update = try snapshot_client.readSnapshot();
+        defer snapshot_update.deinit();
+
+        var stream_client = try ipc.client.Client.connect(std.testing.allocator, path);
+        defer stream_client.deinit();
+        var accepted = try stream_client.requestOutputStream("api", .{});
+        defer accepted.deinit(std.testing.allocator);
+        try std.testing.expect(accepted.success);
+        try std.testing.expect(threads.live() > baseline);
+
+        stopped.store(true, .seq_cst);
+        test_ipc.unblockServer(path);
+        thread.join();
+        if (run.err) |err| return err;
+        primary.shutdown();
+    }
+
+    // Server, broadcaster, watcher, and process threads are all joined by now.
+    try std.testing.expectEqual(baseline, threads.live());
+}
+
 const PrimaryServerRun = struct {
     primary: *Server,
     path: []const u8,
@@ -0,0 +1,126 @@
+//! Delayed autostart.
+//! Processes with `startup_delay_ms`, and every autostart after the first when `autostart_stagger_ms` is set, start from this thread instead of all at once, so a large config does not spike the CPU on launch. Each waiting process counts down in its summary.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
+
+/// Countdowns show whole seconds, so the thread never sleeps longer than one.
+const max_wait_ms = 1000;
+
+/// Starts one delayed process, or skips it when it has already run.
+pub const Starter = struct {
+    context: *anyopaque,
+    start: *const fn (context: *anyopaque, process: *domain.process.Process) void,
+};
+
+const Pending = struct {
+    process: *domain.process.Process,
+    due_ms: i64,
+};
+
+/// Delay of the `index`th autostart process, counting from 0: its own
+/// `startup_delay_ms` after `index` stagger gaps.
+pub fn autostartDelayMs(cfg: *const config.schema.Config, proc_cfg: *const config.schema.ProcessConfig, index: usize) i64 {
+    const stagger: i64 = @max(cfg.autostart_stagger_ms, 0);
+    return stagger * @as(i64, @intCast(index)) + @max(proc_cfg.startup_delay_ms, 0);
+}
+
+/// Processes waiting for their delayed autostart, each with its due time.
+pub const Scheduler = struct {
+    pending: std.array_list.Managed(Pending),
+    controller: ?*proc_mod.controller.Controller = null,
+    starter: ?Starter = null,
+    /// Guards `autostart_in_s` on processes; snapshot builders hold it while
+    /// reading them.
+    mutex: std.Thread.Mutex = .{},
+    /// Sleeps until the next start is due.
+    poller: threads.Poller = .{},
+
+    pub fn init(allocator: std.mem.Allocator) Scheduler {
+        return .{ .pending = std.array_list.Managed(Pending).init(allocator) };
+    }
+
+    pub fn deinit(self: *Scheduler) void {
+        self.stop();
+        self.pending.deinit();
+    }
+
+    /// Queues `process` to start at `due_ms`. Only valid before `start`.
+    pub fn add(self: *Scheduler, process: *domain.process.Process, due_ms: i64, now_ms: i64) !void {
+        try self.pending.append(.{ .process = process, .due_ms = due_ms });
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        process.autostart_in_s = secondsUntil(due_ms, now_ms);
+    }
+
+    /// Starts the thread when anything is queued.
+    pub fn start(self: *Scheduler, controller: *proc_mod.controller.Controller, starter: Starter) !void {
+        if (self.pending.items.len == 0 or self.poller.isRunning()) return;
+        self.controller = controller;
+        self.starter = starter;
+        try self.poller.startScheduled(self, poll);
+    }
+
+    pub fn stop(self: *Scheduler) void {
+        self.poller.stop();
+    }
+
+    /// Hands every due process to the starter, along with any started by hand
+    /// since it was queued, and refreshes the countdowns of the rest. Returns
+    /// how long to wait before the next poll, or null once nothing is queued.
+    pub fn poll(self: *Scheduler, now_ms: i64) ?i64 {
+        const controller = self.controller orelse return null;
+        const starter = self.starter orelse return null;
+
+        var next_due: ?i64 = null;
+        var index: usize = 0;
+        while (index < self.pending.items.len) {
+            const entry = self.pending.items[index];
+            const started_by_hand = controller.processStats(entry.process.id).starts > 0;
+            if (entry.due_ms > now_ms and !started_by_hand) {
+                self.setCountdown(entry.process, secondsUntil(entry.due_ms, now_ms));
+                next_due = if (next_due) |due| @min(due, entry.due_ms) else entry.due_ms;
+                index += 1;
+                continue;
+            }
+            _ = self.pending.orderedRemove(index);
+            self.setCountdown(entry.process, 0);
+            starter.start(starter.context, entry.process);
+        }
+        controller.changes.notify();
+
+        const due = next_due orelse return null;
+        return @min(due - now_ms, max_wait_ms);
+    }
+
+    fn setCountdown(self: *Scheduler, process: *domain.process.Process, seconds: u32) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        process.autostart_in_s = seconds;
+    }
+};
+
+fn secondsUntil(due_ms: i64, now_ms: i64) u32 {
+    if (due_ms <= now_ms) return 0;
+    return @intCast(@divFloor(due_ms - now_ms + std.time.ms_per_s - 1, std.time.ms_per_s));
+}
+
+test "autostart delays stagger by position and add each process delay" {
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.autostart_stagger_ms = 500;
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+
+    try std.testing.expectEqual(@as(i64, 0), autostartDelayMs(&cfg, &proc_cfg, 0));
+    try std.testing.expectEqual(@as(i64, 1000), autostartDelayMs(&cfg, &proc_cfg, 2));
+    proc_cfg.startup_delay_ms = 3000;
+    try std.testing.expectEqual(@as(i64, 4000), autostartDelayMs(&cfg, &proc_cfg, 2));
+
+    try std.testing.expectEqual(@as(u32, 3), secondsUntil(3000, 0));
+    try std.testing.expectEqual(@as(u32, 3), secondsUntil(2001, 0));
+    try std.testing.expectEqual(@as(u32, 0), secondsUntil(0, 10));
+}
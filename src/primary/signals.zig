@@ -0,0 +1,92 @@
+//! Termination signal handling for the Primary Server.
+//! Handlers only raise a flag and wake a futex; the primary mode waits on it and takes the normal stop path, so process shutdown and socket cleanup run outside signal context.
+
+const std = @import("std");
+
+var requested = std.atomic.Value(bool).init(false);
+var wakes = std.atomic.Value(u32).init(0);
+
+/// Routes SIGINT, SIGTERM, and SIGHUP to the shutdown flag instead of the
+/// default exit, which would orphan children and leave a stale socket.
+pub fn install() void {
+    const action = std.posix.Sigaction{
+        .handler = .{ .handler = handle },
+        .mask = std.posix.sigemptyset(),
+        .flags = std.posix.SA.RESTART,
+    };
+    std.posix.sigaction(std.posix.SIG.INT, &action, null);
+    std.posix.sigaction(std.posix.SIG.TERM, &action, null);
+    std.posix.sigaction(std.posix.SIG.HUP, &action, null);
+}
+
+/// Reports whether a termination signal arrived since the last call and
+/// clears the flag.
+pub fn takeRequested() bool {
+    return requested.swap(false, .seq_cst);
+}
+
+/// Blocks until a termination signal arrives or `wake` is called, then
+/// reports and clears the flag like `takeRequested`.
+pub fn waitRequested() bool {
+    const seen = wakes.load(.seq_cst);
+    if (takeRequested()) return true;
+    std.Thread.Futex.wait(&wakes, seen);
+    return takeRequested();
+}
+
+/// Asks for the same shutdown a termination signal does, for `auto_shutdown`.
+pub fn request() void {
+    requested.store(true, .seq_cst);
+    wake();
+}
+
+/// Ends a `waitRequested` without a signal, for shutdown.
+pub fn wake() void {
+    _ = wakes.fetchAdd(1, .seq_cst);
+    std.Thread.Futex.wake(&wakes, std.math.maxInt(u32));
+}
+
+fn handle(_: i32) callconv(.c) void {
+    request();
+}
+
+test "termination signals raise the shutdown flag" {
+    install();
+    defer {
+        const default_action = std.posix.Sigaction{
+            .handler = .{ .handler = std.posix.SIG.DFL },
+            .mask = std.posix.sigemptyset(),
+            .flags = 0,
+        };
+        std.posix.sigaction(std.posix.SIG.INT, &default_action, null);
+        std.posix.sigaction(std.posix.SIG.TERM, &default_action, null);
+        std.posix.sigaction(std.posix.SIG.HUP, &default_action, null);
+    }
+    _ = takeRequested();
+
+    try std.posix.raise(std.posix.SIG.TERM);
+    try std.testing.expect(takeRequested());
+    try std.testing.expect(!takeRequested());
+
+    try std.posix.raise(std.posix.SIG.HUP);
+    try std.testing.expect(waitRequested());
+
+    // Keeps waking until the wait returns, so the test cannot hang on a
+    // wake that lands before the wait starts.
+    const Waker = struct {
+        done: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+
+        fn run(self: *@This()) void {
+            while (!self.done.load(.seq_cst)) {
+                wake();
+                std.Thread.sleep(std.time.ns_per_ms);
+            }
+        }
+    };
+    var waker = Waker{};
+    const thread = try std.Thread.spawn(.{}, Waker.run, .{&waker});
+    const requested_now = waitRequested();
+    waker.done.store(true, .seq_cst);
+    thread.join();
+    try std.testing.expect(!requested_now);
+}
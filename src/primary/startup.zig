@@ -0,0 +1,184 @@
+//! Autostart outcome report.
+//! The Primary Server records each autostart attempt and settles the report once runs have had a moment to fail, so clients can show what came up and why something did not without reading the log file.
+
+const std = @import("std");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+
+/// Runs that exit within this long of autostart count as failures.
+pub const settle_ms: i64 = 2000;
+const max_reason_bytes = 160;
+
+const Attempt = struct {
+    process: *const domain.process.Process,
+    spawn_error: ?anyerror,
+};
+
+/// Labels borrow AppState processes; failure reasons are owned. Once settled
+/// the report never changes, so snapshot builders read it without locking.
+pub const Report = struct {
+    allocator: std.mem.Allocator,
+    attempts: std.array_list.Managed(Attempt),
+    /// Delayed autostarts still to be recorded or skipped; the report waits
+    /// for them before settling.
+    pending: usize = 0,
+    last_attempt_ms: i64 = 0,
+    started: std.array_list.Managed([]const u8),
+    failed: std.array_list.Managed(domain.client_snapshot.StartupFailure),
+    settled_ms: std.atomic.Value(i64) = std.atomic.Value(i64).init(0),
+    mutex: std.Thread.Mutex = .{},
+
+    pub fn init(allocator: std.mem.Allocator) Report {
+        return .{
+            .allocator = allocator,
+            .attempts = std.array_list.Managed(Attempt).init(allocator),
+            .started = std.array_list.Managed([]const u8).init(allocator),
+            .failed = std.array_list.Managed(domain.client_snapshot.StartupFailure).init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Report) void {
+        for (self.failed.items) |failure| self.allocator.free(failure.reason);
+        self.failed.deinit();
+        self.started.deinit();
+        self.attempts.deinit();
+    }
+
+    /// Holds the report open for one more autostart that starts later.
+    pub fn expect(self: *Report) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        self.pending += 1;
+    }
+
+    /// Drops an expected autostart that was started by hand before its delay
+    /// ran out.
+    pub fn skip(self: *Report) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        self.pending -|= 1;
+    }
+
+    /// Notes one autostart; `spawn_error` is set when the process never ran.
+    /// `delayed` marks an autostart announced earlier with `expect`.
+    pub fn record(self: *Report, process: *const domain.process.Process, spawn_error: ?anyerror, delayed: bool, now_ms: i64) !void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        try self.attempts.append(.{ .process = process, .spawn_error = spawn_error });
+        self.last_attempt_ms = now_ms;
+        if (delayed) self.pending -|= 1;
+    }
+
+    /// Sorts every attempt into started or failed once `settle_ms` has passed
+    /// since the last autostart and no delayed one is still waiting. Earlier
+    /// and later calls do nothing; a failure while settling still settles, so
+    /// the report cannot be retried every poll.
+    pub fn settle(self: *Report, controller: *proc_mod.controller.Controller, now_ms: i64) !void {
+        if (self.settled_ms.load(.seq_cst) != 0) return;
+
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (self.settled_ms.load(.seq_cst) != 0) return;
+        if (self.attempts.items.len == 0 or self.pending > 0) return;
+        if (now_ms < self.last_attempt_ms + settle_ms) return;
+        defer self.settled_ms.store(now_ms, .seq_cst);
+
+        for (self.attempts.items) |attempt| {
+            const process = attempt.process;
+            if (attempt.spawn_error) |err| {
+                try self.addFailure(process.label, try self.allocator.dupe(u8, @errorName(err)));
+                continue;
+            }
+            const exit_code = controller.exitCode(process.id);
+            if (controller.isRunning(process.id) or exit_code == 0) {
+                try self.started.append(process.label);
+                continue;
+            }
+            const output: []const u8 = controller.getScrollback(self.allocator, process.id) catch &.{};
+            defer if (output.len > 0) self.allocator.free(output);
+            try self.addFailure(process.label, try failureReason(self.allocator, output, exit_code));
+        }
+    }
+
+    fn addFailure(self: *Report, label: []const u8, reason: []const u8) !void {
+        errdefer self.allocator.free(reason);
+        try self.failed.append(.{ .label = label, .reason = reason });
+    }
+
+    /// Client-facing view; empty until the report settles.
+    pub fn summary(self: *const Report) domain.client_snapshot.StartupSummary {
+        const settled_ms = self.settled_ms.load(.seq_cst);
+        if (settled_ms == 0) return .{};
+        return .{
+            .settled_ms = settled_ms,
+            .started = self.started.items,
+            .failed = self.failed.items,
+        };
+    }
+};
+
+/// Picks the first output line that reads like an error, else the last
+/// non-empty line, else the exit code. The caller owns the result.
+pub fn failureReason(allocator: std.mem.Allocator, output: []const u8, exit_code: ?u32) ![]const u8 {
+    var last: []const u8 = "";
+    var lines = std.mem.splitScalar(u8, output, '\n');
+    while (lines.next()) |raw| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (line.len == 0) continue;
+        if (isErrorLine(line)) return plainReason(allocator, line);
+        last = line;
+    }
+    if (last.len > 0) return plainReason(allocator, last);
+    if (exit_code) |code| return std.fmt.allocPrint(allocator, "exited with code {d}", .{code});
+    return allocator.dupe(u8, "exited");
+}
+
+fn isErrorLine(line: []const u8) bool {
+    for ([_][]const u8{ "error", "fatal", "panic", "exception" }) |word| {
+        if (std.ascii.indexOfIgnoreCase(line, word) != null) return true;
+    }
+    return false;
+}
+
+/// Drops escape sequences and caps the length on a UTF-8 boundary.
+pub fn plainReason(allocator: std.mem.Allocator, line: []const u8) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+
+    var index: usize = 0;
+    while (index < line.len) {
+        if (line[index] == 0x1b) {
+            index += 1;
+            if (index < line.len and line[index] == '[') {
+                index += 1;
+                while (index < line.len and !(line[index] >= 0x40 and line[index] <= 0x7e)) : (index += 1) {}
+            }
+            index += 1;
+            continue;
+        }
+        try out.append(line[index]);
+        index += 1;
+    }
+
+    if (out.items.len > max_reason_bytes) {
+        var end: usize = max_reason_bytes;
+        while (end > 0 and (out.items[end] & 0xc0) == 0x80) end -= 1;
+        out.shrinkRetainingCapacity(end);
+    }
+    return out.toOwnedSlice();
+}
+
+test "failure reason prefers the first error line and strips colors" {
+    const output = "starting\n\x1b[31mError: connect ECONNREFUSED\x1b[0m\nfatal: giving up\nbye\n";
+    const reason = try failureReason(std.testing.allocator, output, 1);
+    defer std.testing.allocator.free(reason);
+    try std.testing.expectEqualStrings("Error: connect ECONNREFUSED", reason);
+
+    const last = try failureReason(std.testing.allocator, "one\ntwo\n\n", 2);
+    defer std.testing.allocator.free(last);
+    try std.testing.expectEqualStrings("two", last);
+
+    const silent = try failureReason(std.testing.allocator, "", 3);
+    defer std.testing.allocator.free(silent);
+    try std.testing.expectEqualStrings("exited with code 3", silent);
+}
@@ -0,0 +1,79 @@
+//! Stuck-process detection for processes with `expect_output_within_ms`.
+//! The Primary Server flags a running process once it has gone that long without printing anything, so a worker that hangs without exiting shows up in the TUI and reaches plugins as a `stuck` event. Unlike `idle_timeout_ms` nothing is stopped, and input does not count as activity.
+
+const std = @import("std");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
+
+const log = std.log.scoped(.primary);
+
+/// Output times move at most once a second, so checking more often would not
+/// flag anything sooner.
+const poll_interval_ms = 1000;
+
+/// Watches every process that sets `expect_output_within_ms`.
+pub const Detector = struct {
+    processes: []domain.process.Process,
+    /// Set when any process expects output.
+    enabled: bool = false,
+    controller: ?*proc_mod.controller.Controller = null,
+    /// Guards `stuck` on processes; snapshot builders and the plugin
+    /// dispatcher hold it while reading them.
+    mutex: std.Thread.Mutex = .{},
+    poller: threads.Poller = .{},
+
+    pub fn init(processes: []domain.process.Process) Detector {
+        var detector = Detector{ .processes = processes };
+        for (processes) |process| {
+            if (process.config.expect_output_within_ms > 0) detector.enabled = true;
+        }
+        return detector;
+    }
+
+    pub fn deinit(self: *Detector) void {
+        self.stop();
+    }
+
+    /// Starts the polling thread. Configs without `expect_output_within_ms`
+    /// never start one.
+    pub fn start(self: *Detector, controller: *proc_mod.controller.Controller) !void {
+        if (!self.enabled or self.poller.isRunning()) return;
+        self.controller = controller;
+        try self.poller.start(self, poll, poll_interval_ms);
+    }
+
+    pub fn stop(self: *Detector) void {
+        self.poller.stop();
+    }
+
+    /// Flags each running process quiet for its whole window, counting from
+    /// its start or latest output, and clears the flag once it prints again
+    /// or stops running.
+    pub fn poll(self: *Detector, now_ms: i64) void {
+        const controller = self.controller orelse return;
+        for (self.processes) |*process| {
+            const within_ms = process.config.expect_output_within_ms;
+            if (within_ms <= 0) continue;
+            const stuck = controller.isRunning(process.id) and blk: {
+                const stats = controller.processStats(process.id);
+                break :blk now_ms - @max(stats.last_started_ms, stats.last_output_ms) >= within_ms;
+            };
+
+            self.mutex.lock();
+            const changed = process.stuck != stuck;
+            process.stuck = stuck;
+            self.mutex.unlock();
+            if (!changed) continue;
+
+            if (stuck) log.info("process '{s}' printed nothing for {d}ms; it may be stuck", .{ process.label, within_ms });
+            controller.changes.notify();
+        }
+    }
+
+    pub fn isStuck(self: *Detector, process: *const domain.process.Process) bool {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return process.stuck;
+    }
+};
@@ -0,0 +1,359 @@
+//! File watching for processes with `watch` globs.
+//! The Primary Server polls modification times rather than using inotify or kqueue so one code path works on every platform; a change restarts the running process once its files have been quiet for the debounce interval.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const proc_mod = @import("../proc/root.zig");
+const threads = @import("../threads/root.zig");
+
+const log = std.log.scoped(.primary);
+
+const default_poll_interval_ms = 500;
+const default_debounce_ms = 500;
+
+/// Restarts one process for the watcher. Returns false when the process was
+/// not running, so a stopped process stays stopped.
+pub const Restarter = struct {
+    context: *anyopaque,
+    restart: *const fn (context: *anyopaque, process: *domain.process.Process) anyerror!bool,
+};
+
+/// Watches every process that configures `watch`.
+pub const Watcher = struct {
+    allocator: std.mem.Allocator,
+    targets: []Target,
+    restarter: ?Restarter = null,
+    /// Guards `watch_restarts` and `watch_change` on watched processes;
+    /// snapshot builders hold it while reading them.
+    mutex: std.Thread.Mutex = .{},
+    poller: threads.Poller = .{},
+    /// From `general`; configs that skipped defaults keep these.
+    poll_interval_ms: u64 = default_poll_interval_ms,
+    debounce_ms: i64 = default_debounce_ms,
+
+    pub fn init(
+        allocator: std.mem.Allocator,
+        processes: []domain.process.Process,
+        global_config: ?*const config.schema.Config,
+    ) !Watcher {
+        var targets = std.array_list.Managed(Target).init(allocator);
+        errdefer {
+            for (targets.items) |*target| target.deinit(allocator);
+            targets.deinit();
+        }
+
+        for (processes) |*process| {
+            if (process.config.watch.items.len == 0) continue;
+            const root = try proc_mod.builder.resolveCwd(allocator, process.config.cwd, global_config);
+            errdefer if (root.len > 0) allocator.free(root);
+            try targets.append(.{ .process = process, .root = root, .files = FileTimes.init(allocator) });
+        }
+        var watcher: Watcher = .{ .allocator = allocator, .targets = try targets.toOwnedSlice() };
+        if (global_config) |cfg| {
+            if (cfg.general.watch_poll_interval_ms > 0) watcher.poll_interval_ms = @intCast(cfg.general.watch_poll_interval_ms);
+            if (cfg.general.watch_debounce_ms > 0) watcher.debounce_ms = cfg.general.watch_debounce_ms;
+        }
+        return watcher;
+    }
+
+    pub fn deinit(self: *Watcher) void {
+        self.stop();
+        for (self.targets) |*target| target.deinit(self.allocator);
+        self.allocator.free(self.targets);
+    }
+
+    /// Starts the polling thread. Configs without `watch` never start one.
+    pub fn start(self: *Watcher, restarter: Restarter) !void {
+        if (self.targets.len == 0 or self.poller.isRunning()) return;
+        self.restarter = restarter;
+        try self.poller.start(self, poll, self.poll_interval_ms);
+    }
+
+    pub fn stop(self: *Watcher) void {
+        self.poller.stop();
+    }
+
+    /// Scans every target once and restarts the processes whose latest change
+    /// is older than their debounce interval. The first scan of a target only
+    /// records a baseline.
+    pub fn poll(self: *Watcher, now_ms: i64) void {
+        for (self.targets) |*target| self.pollTarget(target, now_ms);
+    }
+
+    fn debounceMs(self: *const Watcher, proc_cfg: *const config.schema.ProcessConfig) i64 {
+        return if (proc_cfg.watch_debounce_ms > 0) proc_cfg.watch_debounce_ms else self.debounce_ms;
+    }
+
+    fn pollTarget(self: *Watcher, target: *Target, now_ms: i64) void {
+        const changed = target.scan(self.allocator) catch |err| {
+            log.debug("watch scan failed for process '{s}': {s}", .{ target.process.label, @errorName(err) });
+            return;
+        };
+        if (changed) |path| {
+            if (target.pending) |previous| self.allocator.free(previous);
+            target.pending = path;
+            target.changed_at_ms = now_ms;
+        }
+
+        const path = target.pending orelse return;
+        if (now_ms - target.changed_at_ms < self.debounceMs(target.process.config)) return;
+        target.pending = null;
+
+        const restarter = self.restarter orelse {
+            self.allocator.free(path);
+            return;
+        };
+        const restarted = restarter.restart(restarter.context, target.process) catch |err| {
+            log.warn("watch restart failed for process '{s}': {s}", .{ target.process.label, @errorName(err) });
+            self.allocator.free(path);
+            return;
+        };
+        if (!restarted) {
+            self.allocator.free(path);
+            return;
+        }
+        log.info("restarted process '{s}' due to change in {s}", .{ target.process.label, path });
+
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (target.change.len > 0) self.allocator.free(target.change);
+        target.change = path;
+        target.process.watch_change = path;
+        target.process.watch_restarts += 1;
+    }
+};
+
+const FileTimes = std.StringHashMap(i128);
+
+const Target = struct {
+    process: *domain.process.Process,
+    /// Resolved process cwd; empty means the Primary's own cwd.
+    root: []const u8,
+    files: FileTimes,
+    scanned: bool = false,
+    /// Latest change not yet acted on, relative to `root`.
+    pending: ?[]const u8 = null,
+    changed_at_ms: i64 = 0,
+    /// Backing storage for `process.watch_change`.
+    change: []const u8 = "",
+
+    fn deinit(self: *Target, allocator: std.mem.Allocator) void {
+        freeFileTimes(allocator, &self.files);
+        if (self.pending) |path| allocator.free(path);
+        if (self.change.len > 0) allocator.free(self.change);
+        if (self.root.len > 0) allocator.free(self.root);
+    }
+
+    /// Rescans the watched files and returns one path that was added,
+    /// modified, or removed since the previous scan.
+    fn scan(self: *Target, allocator: std.mem.Allocator) !?[]const u8 {
+        var files = FileTimes.init(allocator);
+        errdefer freeFileTimes(allocator, &files);
+
+        var dir = try std.fs.cwd().openDir(if (self.root.len > 0) self.root else ".", .{ .iterate = true });
+        defer dir.close();
+        try collect(allocator, self.process.config, dir, "", &files);
+
+        const changed = if (self.scanned) try firstDifference(allocator, &self.files, &files) else null;
+        freeFileTimes(allocator, &self.files);
+        self.files = files;
+        self.scanned = true;
+        return changed;
+    }
+};
+
+/// Records the mtime of every file under `dir` that matches a `watch` glob.
+/// Directories are only entered while some glob could still match below them.
+fn collect(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    dir: std.fs.Dir,
+    relative: []const u8,
+    files: *FileTimes,
+) !void {
+    var it = dir.iterate();
+    while (try it.next()) |entry| {
+        if (std.mem.eql(u8, entry.name, ".git")) continue;
+        const path = if (relative.len == 0)
+            try allocator.dupe(u8, entry.name)
+        else
+            try std.fs.path.join(allocator, &.{ relative, entry.name });
+        var kept = false;
+        defer if (!kept) allocator.free(path);
+        if (isIgnored(proc_cfg.watch_ignore.items, path, entry.name)) continue;
+
+        switch (entry.kind) {
+            .directory => {
+                if (!anyCouldMatchBelow(proc_cfg.watch.items, path)) continue;
+                var child = dir.openDir(entry.name, .{ .iterate = true }) catch continue;
+                defer child.close();
+                try collect(allocator, proc_cfg, child, path, files);
+            },
+            .file, .sym_link => {
+                if (!anyMatches(proc_cfg.watch.items, path)) continue;
+                const stat = dir.statFile(entry.name) catch continue;
+                if (stat.kind == .directory) continue;
+                try files.put(path, stat.mtime);
+                kept = true;
+            },
+            else => {},
+        }
+    }
+}
+
+fn firstDifference(allocator: std.mem.Allocator, previous: *const FileTimes, current: *const FileTimes) !?[]const u8 {
+    var current_it = current.iterator();
+    while (current_it.next()) |entry| {
+        const mtime = previous.get(entry.key_ptr.*) orelse return try allocator.dupe(u8, entry.key_ptr.*);
+        if (mtime != entry.value_ptr.*) return try allocator.dupe(u8, entry.key_ptr.*);
+    }
+    var previous_it = previous.keyIterator();
+    while (previous_it.next()) |path| {
+        if (!current.contains(path.*)) return try allocator.dupe(u8, path.*);
+    }
+    return null;
+}
+
+fn freeFileTimes(allocator: std.mem.Allocator, files: *FileTimes) void {
+    var it = files.keyIterator();
+    while (it.next()) |path| allocator.free(path.*);
+    files.deinit();
+}
+
+/// Ignore globs match the relative path; a glob without `/` also matches any
+/// single file or directory name, so `node_modules` prunes it everywhere.
+fn isIgnored(patterns: []const []const u8, path: []const u8, name: []const u8) bool {
+    for (patterns) |pattern| {
+        const trimmed = trimDotSlash(pattern);
+        if (globMatches(trimmed, path)) return true;
+        if (std.mem.indexOfScalar(u8, trimmed, '/') == null and config.include.matches(trimmed, name)) return true;
+    }
+    return false;
+}
+
+fn anyMatches(patterns: []const []const u8, path: []const u8) bool {
+    for (patterns) |pattern| {
+        if (globMatches(trimDotSlash(pattern), path)) return true;
+    }
+    return false;
+}
+
+fn anyCouldMatchBelow(patterns: []const []const u8, dir: []const u8) bool {
+    for (patterns) |pattern| {
+        if (couldMatchBelow(trimDotSlash(pattern), dir)) return true;
+    }
+    return false;
+}
+
+/// Matches a `/`-separated relative path. `**` spans any number of
+/// directories; `*` and `?` stay within one segment.
+pub fn globMatches(pattern: []const u8, path: []const u8) bool {
+    if (pattern.len == 0) return path.len == 0;
+    const head = firstSegment(pattern);
+    const pattern_rest = afterSegment(pattern, head);
+    if (std.mem.eql(u8, head, "**")) {
+        if (globMatches(pattern_rest, path)) return true;
+        if (path.len == 0) return false;
+        return globMatches(pattern, afterSegment(path, firstSegment(path)));
+    }
+    if (path.len == 0) return false;
+    const segment = firstSegment(path);
+    return config.include.matches(head, segment) and globMatches(pattern_rest, afterSegment(path, segment));
+}
+
+/// Reports whether some file below directory `dir` could match `pattern`.
+fn couldMatchBelow(pattern: []const u8, dir: []const u8) bool {
+    if (dir.len == 0) return pattern.len > 0;
+    if (pattern.len == 0) return false;
+    const head = firstSegment(pattern);
+    if (std.mem.eql(u8, head, "**")) return true;
+    const segment = firstSegment(dir);
+    return config.include.matches(head, segment) and couldMatchBelow(afterSegment(pattern, head), afterSegment(dir, segment));
+}
+
+fn firstSegment(text: []const u8) []const u8 {
+    return text[0 .. std.mem.indexOfScalar(u8, text, '/') orelse text.len];
+}
+
+fn afterSegment(text: []const u8, segment: []const u8) []const u8 {
+    return if (segment.len < text.len) text[segment.len + 1 ..] else "";
+}
+
+fn trimDotSlash(pattern: []const u8) []const u8 {
+    return if (std.mem.startsWith(u8, pattern, "./")) pattern[2..] else pattern;
+}
+
+test "watch globs span directories only with double star" {
+    try std.testing.expect(globMatches("src/**/*.zig", "src/main.zig"));
+    try std.testing.expect(globMatches("src/**/*.zig", "src/a/b/c.zig"));
+    try std.testing.expect(globMatches("**", "README.md"));
+    try std.testing.expect(!globMatches("*.zig", "src/main.zig"));
+    try std.testing.expect(!globMatches("src/*.zig", "src/a/main.zig"));
+
+    try std.testing.expect(couldMatchBelow("src/*.zig", "src"));
+    try std.testing.expect(!couldMatchBelow("src/*.zig", "lib"));
+    try std.testing.expect(!couldMatchBelow("src/*.zig", "src/a"));
+    try std.testing.expect(couldMatchBelow("**/*.zig", "lib/deep"));
+
+    try std.testing.expect(isIgnored(&.{"node_modules"}, "web/node_modules", "node_modules"));
+    try std.testing.expect(!isIgnored(&.{"build/*"}, "web/build/out.js", "out.js"));
+}
+
+const FakeRestarter = struct {
+    count: usize = 0,
+
+    fn restarter(self: *FakeRestarter) Restarter {
+        return .{ .context = self, .restart = restart };
+    }
+
+    fn restart(context: *anyopaque, _: *domain.process.Process) anyerror!bool {
+        const self: *FakeRestarter = @ptrCast(@alignCast(context));
+        self.count += 1;
+        return true;
+    }
+};
+
+test "watcher restarts after changes go quiet and skips ignored paths" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("src/app");
+    try tmp.dir.makePath("src/generated");
+    try tmp.dir.writeFile(.{ .sub_path = "src/app/main.zig", .data = "one" });
+    const root = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(root);
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.cwd = root;
+    proc_cfg.watch_debounce_ms = 100;
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.watch, "src/**/*.zig");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.watch_ignore, "generated");
+
+    var processes = [_]domain.process.Process{.{
+        .id = domain.process.ProcessId.fromInt(1),
+        .label = "api",
+        .config = &proc_cfg,
+    }};
+    var watcher = try Watcher.init(std.testing.allocator, processes[0..], null);
+    defer watcher.deinit();
+    var fake = FakeRestarter{};
+    watcher.restarter = fake.restarter();
+
+    watcher.poll(0);
+    try tmp.dir.writeFile(.{ .sub_path = "src/app/routes.zig", .data = "two" });
+    try tmp.dir.writeFile(.{ .sub_path = "src/generated/schema.zig", .data = "skip" });
+    try tmp.dir.writeFile(.{ .sub_path = "src/notes.md", .data = "skip" });
+
+    watcher.poll(10);
+    try std.testing.expectEqual(@as(usize, 0), fake.count);
+    watcher.poll(200);
+    try std.testing.expectEqual(@as(usize, 1), fake.count);
+    try std.testing.expectEqual(@as(u32, 1), processes[0].watch_restarts);
+    try std.testing.expectEqualStrings("src/app/routes.zig", processes[0].watch_change);
+
+    try tmp.dir.writeFile(.{ .sub_path = "src/generated/other.zig", .data = "skip" });
+    watcher.poll(300);
+    watcher.poll(1000);
+    try std.testing.expectEqual(@as(usize, 1), fake.count);
+}
@@ -0,0 +1,67 @@
+//! BEL detection in process output.
+//! The capture thread counts bells a process rings so the Primary Server can surface them; a BEL that only terminates an OSC string (window titles, hyperlinks) is not a bell.
+
+const std = @import("std");
+
+/// Escape-sequence state carried between reads, since a sequence can span
+/// two of them. Owned by the capture thread.
+pub const Detector = struct {
+    state: State = .ground,
+
+    const State = enum {
+        ground,
+        escape,
+        /// Inside `ESC ]`, which ends at BEL or `ESC \`.
+        osc,
+        /// Inside `ESC P`, `ESC X`, `ESC ^`, or `ESC _`, which end at `ESC \`.
+        string,
+        /// Saw `ESC` inside a string; `\` ends it.
+        string_escape,
+    };
+
+    /// Bells rung in `bytes`.
+    pub fn count(self: *Detector, bytes: []const u8) u32 {
+        var rung: u32 = 0;
+        for (bytes) |byte| {
+            self.state = switch (self.state) {
+                .ground => switch (byte) {
+                    0x07 => blk: {
+                        rung +|= 1;
+                        break :blk .ground;
+                    },
+                    0x1b => .escape,
+                    else => .ground,
+                },
+                .escape => switch (byte) {
+                    ']' => .osc,
+                    'P', 'X', '^', '_' => .string,
+                    0x1b => .escape,
+                    else => .ground,
+                },
+                .osc => switch (byte) {
+                    0x07 => .ground,
+                    0x1b => .string_escape,
+                    else => .osc,
+                },
+                .string => if (byte == 0x1b) .string_escape else .string,
+                // Anything but `\` keeps the string open, as xterm does.
+                .string_escape => if (byte == '\\') .ground else .string,
+            };
+        }
+        return rung;
+    }
+};
+
+test "bell detector skips BEL that terminates OSC strings" {
+    var detector = Detector{};
+    try std.testing.expectEqual(@as(u32, 2), detector.count("done\x07 \x1b]0;title\x07again\x07"));
+    try std.testing.expectEqual(@as(u32, 0), detector.count("\x1bP\x07data\x1b\\"));
+    try std.testing.expectEqual(@as(u32, 1), detector.count("\x1b[31m\x07\x1b[0m"));
+}
+
+test "bell detector carries escape state across reads" {
+    var detector = Detector{};
+    try std.testing.expectEqual(@as(u32, 0), detector.count("\x1b]2;build"));
+    try std.testing.expectEqual(@as(u32, 0), detector.count(" finished\x07"));
+    try std.testing.expectEqual(@as(u32, 1), detector.count("\x07"));
+}
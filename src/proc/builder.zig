@@ -3,36 +3,68 @@
 
 const std = @import("std");
 const config = @import("../config/root.zig");
+const env = @import("env.zig");
 
 const default_shell_cmd = [_][]const u8{ "sh", "-c" };
+const config_dir_placeholder = "{config_dir}";
+const git_root_placeholder = "{git_root}";
+
+/// Sources nvm in a throwaway bash so `nvm use` can honor `.nvmrc`, then execs
+/// the real argv passed after `$0`.
+const nvm_wrapper =
+    \\. "${NVM_DIR:-$HOME/.nvm}/nvm.sh" && nvm use --silent >/dev/null 2>&1; exec "$@"
+;
 
 pub const CommandSpec = struct {
     argv: []const []const u8,
+    /// Working directory with placeholders resolved. Empty inherits ours.
+    cwd: []const u8 = "",
 
     pub fn deinit(self: CommandSpec, allocator: std.mem.Allocator) void {
         for (self.argv) |arg| allocator.free(arg);
         allocator.free(self.argv);
+        if (self.cwd.len > 0) allocator.free(self.cwd);
     }
 };
 
 /// Resolves process config into argv. `shell` and `cmd` are intentionally
-/// mutually exclusive so startup behavior is predictable.
+/// mutually exclusive so startup behavior is predictable. A `shell` string runs
+/// through the process's own `shell_cmd`, then the global one, then `sh -c`.
+/// Docker processes follow their container's logs; `shell`/`cmd` run inside
+/// the container instead (see `docker.zig`).
 pub fn buildCommand(
     allocator: std.mem.Allocator,
     proc_cfg: *const config.schema.ProcessConfig,
     global_config: ?*const config.schema.Config,
 ) !?CommandSpec {
+    if (proc_cfg.isDocker()) {
+        var argv = std.array_list.Managed([]const u8).init(allocator);
+        errdefer deinitArgv(allocator, &argv);
+        for ([_][]const u8{ "docker", "logs", "--follow", proc_cfg.container_name }) |part| {
+            try argv.append(try allocator.dupe(u8, part));
+        }
+        return try finishCommand(allocator, &argv, proc_cfg, global_config);
+    }
+
     if (proc_cfg.shell.len > 0) {
-        const shell_cmd = if (global_config) |cfg|
+        const shell_cmd = if (proc_cfg.shell_cmd.items.len > 0)
+            proc_cfg.shell_cmd.items
+        else if (global_config) |cfg|
             if (cfg.shell_cmd.items.len > 0) cfg.shell_cmd.items else default_shell_cmd[0..]
         else
             default_shell_cmd[0..];
 
         var argv = std.array_list.Managed([]const u8).init(allocator);
         errdefer deinitArgv(allocator, &argv);
-        for (shell_cmd) |part| try argv.append(try allocator.dupe(u8, part));
+        for (shell_cmd, 0..) |part, index| {
+            try argv.append(try allocator.dupe(u8, part));
+            // Startup flags go straight after the shell binary, ahead of `-c`.
+            if (index != 0) continue;
+            if (proc_cfg.login_shell) try argv.append(try allocator.dupe(u8, "-l"));
+            if (proc_cfg.interactive_shell) try argv.append(try allocator.dupe(u8, "-i"));
+        }
         try argv.append(try allocator.dupe(u8, proc_cfg.shell));
-        return .{ .argv = try argv.toOwnedSlice() };
+        return try finishCommand(allocator, &argv, proc_cfg, global_config);
     }
 
     if (proc_cfg.cmd.items.len == 0) return null;
@@ -40,57 +72,150 @@ pub fn buildCommand(
     var argv = std.array_list.Managed([]const u8).init(allocator);
     errdefer deinitArgv(allocator, &argv);
     for (proc_cfg.cmd.items) |part| try argv.append(try allocator.dupe(u8, part));
-    return .{ .argv = try argv.toOwnedSlice() };
+    return try finishCommand(allocator, &argv, proc_cfg, global_config);
+}
+
+/// Expands `{config_dir}` and `{git_root}` in a process cwd. Both resolve from
+/// the Project Config's location at start time, so a shared config needs no
+/// machine-specific paths. Returns an owned copy; empty means inherit.
+pub fn resolveCwd(
+    allocator: std.mem.Allocator,
+    cwd: []const u8,
+    global_config: ?*const config.schema.Config,
+) ![]const u8 {
+    if (cwd.len == 0) return "";
+
+    const config_dir = if (global_config) |cfg| std.fs.path.dirname(cfg.file_path) orelse "." else ".";
+    var resolved = try std.mem.replaceOwned(u8, allocator, cwd, config_dir_placeholder, config_dir);
+    errdefer allocator.free(resolved);
+
+    if (std.mem.indexOf(u8, resolved, git_root_placeholder) != null) {
+        const git_root = try findGitRoot(allocator, config_dir);
+        defer allocator.free(git_root);
+        const replaced = try std.mem.replaceOwned(u8, allocator, resolved, git_root_placeholder, git_root);
+        allocator.free(resolved);
+        resolved = replaced;
+    }
+    return resolved;
 }
 
+fn finishCommand(
+    allocator: std.mem.Allocator,
+    argv: *std.array_list.Managed([]const u8),
+    proc_cfg: *const config.schema.ProcessConfig,
+    global_config: ?*const config.schema.Config,
+) !CommandSpec {
+    const cwd = try resolveCwd(allocator, proc_cfg.cwd, global_config);
+    errdefer if (cwd.len > 0) allocator.free(cwd);
+    try wrapWithEnvLoader(allocator, argv, proc_cfg);
+    return .{ .argv = try argv.toOwnedSlice(), .cwd = cwd };
+}
+
+/// Prefixes argv so the configured toolchain manager loads its environment
+/// before exec, since proctmux skips the shell init that usually does this.
+fn wrapWithEnvLoader(
+    allocator: std.mem.Allocator,
+    argv: *std.array_list.Managed([]const u8),
+    proc_cfg: *const config.schema.ProcessConfig,
+) !void {
+    if (proc_cfg.env_loader.len == 0) return;
+    const loader = std.meta.stringToEnum(config.schema.EnvLoader, proc_cfg.env_loader) orelse return error.InvalidEnvLoader;
+
+    var prefix = std.array_list.Managed([]const u8).init(allocator);
+    errdefer deinitArgv(allocator, &prefix);
+    switch (loader) {
+        .direnv => {
+            // The child already starts in its cwd, so "." is the process directory.
+            try prefix.append(try allocator.dupe(u8, "direnv"));
+            try prefix.append(try allocator.dupe(u8, "exec"));
+            try prefix.append(try allocator.dupe(u8, "."));
+        },
+        .mise => {
+            try prefix.append(try allocator.dupe(u8, "mise"));
+            try prefix.append(try allocator.dupe(u8, "exec"));
+            try prefix.append(try allocator.dupe(u8, "--"));
+        },
+        .nvm => {
+            try prefix.append(try allocator.dupe(u8, "bash"));
+            try prefix.append(try allocator.dupe(u8, "-c"));
+            try prefix.append(try allocator.dupe(u8, nvm_wrapper));
+            try prefix.append(try allocator.dupe(u8, "proctmux-nvm"));
+        },
+        .custom => {
+            if (proc_cfg.env_loader_cmd.items.len == 0) return error.InvalidEnvLoader;
+            for (proc_cfg.env_loader_cmd.items) |part| try prefix.append(try allocator.dupe(u8, part));
+        },
+    }
+
+    try argv.insertSlice(0, prefix.items);
+    prefix.deinit();
+}
+
+/// Walks up from `start` to the nearest directory holding `.git`, which is a
+/// directory in a normal checkout and a file in a worktree.
+fn findGitRoot(allocator: std.mem.Allocator, start: []const u8) ![]const u8 {
+    var current = try std.fs.cwd().realpathAlloc(allocator, start);
+    errdefer allocator.free(current);
+
+    while (true) {
+        var dir = try std.fs.openDirAbsolute(current, .{});
+        defer dir.close();
+        if (dir.access(".git", .{})) |_| return current else |_| {}
+
+        const parent = std.fs.path.dirname(current) orelse return error.GitRootNotFound;
+        const next = try allocator.dupe(u8, parent);
+        allocator.free(current);
+        current = next;
+    }
+}
+
+/// Builds sorted `NAME=value` entries from `base_env` plus process config,
+/// with the same precedence as `env.buildMap` so every name appears once.
+/// The first entry for a name in `base_env` wins, as it does for `getenv`;
+/// `fallback_path` stands in for a missing PATH that `add_path` extends.
 pub fn buildEnvironmentFromBase(
     allocator: std.mem.Allocator,
     base_env: []const []const u8,
     fallback_path: []const u8,
     proc_cfg: *const config.schema.ProcessConfig,
 ) ![]const []const u8 {
-    var env = std.array_list.Managed([]const u8).init(allocator);
-    errdefer deinitEnvironment(allocator, env.items);
-
-    for (base_env) |entry| {
-        if (proc_cfg.add_path.items.len > 0 and std.mem.startsWith(u8, entry, "PATH=")) continue;
-        try env.append(try allocator.dupe(u8, entry));
-    }
-
-    if (proc_cfg.add_path.items.len > 0) {
-        var path = std.array_list.Managed(u8).init(allocator);
-        defer path.deinit();
-        try path.appendSlice(findPath(base_env) orelse fallback_path);
-        for (proc_cfg.add_path.items) |part| {
-            try path.append(':');
-            try path.appendSlice(part);
+    var env_map = std.process.EnvMap.init(allocator);
+    defer env_map.deinit();
+    if (!proc_cfg.env_clear) {
+        for (base_env) |entry| {
+            const separator = std.mem.indexOfScalar(u8, entry, '=') orelse continue;
+            const name = entry[0..separator];
+            if (env_map.get(name) == null) try env_map.put(name, entry[separator + 1 ..]);
         }
-        const path_entry = try std.fmt.allocPrint(allocator, "PATH={s}", .{path.items});
-        try env.append(path_entry);
     }
+    if (env_map.get("PATH") == null and proc_cfg.add_path.items.len > 0) {
+        try env_map.put("PATH", fallback_path);
+    }
+    try env.applyProcess(allocator, &env_map, proc_cfg);
 
-    var it = proc_cfg.env.iterator();
+    var entries = std.array_list.Managed([]const u8).init(allocator);
+    errdefer {
+        for (entries.items) |entry| allocator.free(entry);
+        entries.deinit();
+    }
+    var it = env_map.iterator();
     while (it.next()) |entry| {
-        const env_entry = try std.fmt.allocPrint(allocator, "{s}={s}", .{ entry.key_ptr.*, entry.value_ptr.* });
-        try env.append(env_entry);
+        try entries.append(try std.fmt.allocPrint(allocator, "{s}={s}", .{ entry.key_ptr.*, entry.value_ptr.* }));
     }
+    std.mem.sort([]const u8, entries.items, {}, lessThanEntry);
+    return entries.toOwnedSlice();
+}
 
-    return env.toOwnedSlice();
+pub fn deinitEnvironment(allocator: std.mem.Allocator, entries: []const []const u8) void {
+    for (entries) |entry| allocator.free(entry);
+    allocator.free(entries);
 }
 
-pub fn deinitEnvironment(allocator: std.mem.Allocator, env: []const []const u8) void {
-    for (env) |entry| allocator.free(entry);
-    allocator.free(env);
+fn lessThanEntry(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.order(u8, a, b) == .lt;
 }
 
 fn deinitArgv(allocator: std.mem.Allocator, argv: *std.array_list.Managed([]const u8)) void {
     for (argv.items) |arg| allocator.free(arg);
     argv.deinit();
 }
-
-fn findPath(base_env: []const []const u8) ?[]const u8 {
-    for (base_env) |entry| {
-        if (std.mem.startsWith(u8, entry, "PATH=")) return entry["PATH=".len..];
-    }
-    return null;
-}
@@ -5,26 +5,78 @@ const std = @import("std");
 const config = @import("../config/root.zig");
 const domain = @import("../domain/root.zig");
 const ring = @import("../ring/root.zig");
+const threads = @import("../threads/root.zig");
 const builder = @import("builder.zig");
+const docker = @import("docker.zig");
 const env = @import("env.zig");
+const hooks = @import("hooks.zig");
 const instance_mod = @import("instance.zig");
 const on_kill = @import("on_kill.zig");
 const output = @import("output.zig");
+const redact = @import("redact.zig");
+const secrets = @import("secrets.zig");
+const sink = @import("sink.zig");
 const spawn = @import("spawn.zig");
 
+const log = std.log.scoped(.process);
+
 const default_scrollback_capacity = 1024 * 1024;
+/// Stderr also lands in the main scrollback, so its own copy is smaller.
+const default_stderr_capacity = 256 * 1024;
 const default_stop_timeout_ms = 3000;
+/// How far back `ProcessStats.output_rate` averages.
+pub const rate_window_ms = 10_000;
 
 pub const Instance = instance_mod.Instance;
 
+/// Lifetime counters for one ProcessId. They survive restarts so metrics can
+/// show a process that keeps crashing.
+pub const ProcessStats = struct {
+    starts: u32 = 0,
+    last_started_ms: i64 = 0,
+    output_bytes: u64 = 0,
+    /// Output lines over every run, counted by newline.
+    output_lines: u64 = 0,
+    /// Output bytes per second over the last `rate_window_ms`.
+    output_rate: u64 = 0,
+    /// See `RingBuffer.lastOutputMs`.
+    last_output_ms: i64 = 0,
+    /// BEL characters rung over every run; see `bell.Detector`.
+    bells: u32 = 0,
+    /// Unix milliseconds when input was last forwarded to the process.
+    last_input_ms: i64 = 0,
+
+    pub fn restarts(self: ProcessStats) u32 {
+        return if (self.starts > 0) self.starts - 1 else 0;
+    }
+};
+
 /// Owns currently running process instances plus per-process scrollback history.
 /// Callers interact through stable ProcessIds; OS handles, retained output, and
-/// cleanup hooks stay behind this Module's mutex-protected maps.
+/// cleanup hooks stay behind this Module's mutex-protected maps. The mutex only
+/// covers map lookups and updates: spawning, stopping, and I/O run outside it,
+/// with each instance pinned by its own lock (see `acquireInstance`).
 pub const Controller = struct {
     allocator: std.mem.Allocator,
     global_config: ?*const config.schema.Config,
     processes: std.AutoHashMap(domain.process.ProcessId, *Instance),
+    /// Ids whose start or restart is in flight, with their status until the
+    /// instance exists, so a second start fails before running hooks or
+    /// spawning instead of racing the first.
+    starting: std.AutoHashMap(domain.process.ProcessId, domain.process.ProcessStatus),
     scrollbacks: std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer),
+    /// Stderr-only output of processes with `separate_stderr`, kept like
+    /// `scrollbacks`.
+    stderr_scrollbacks: std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer),
+    launches: std.AutoHashMap(domain.process.ProcessId, ProcessStats),
+    /// Installed on every scrollback, including ones created later.
+    output_notifier: ?ring.WriteNotifier = null,
+    /// Notified whenever a process starts, writes output, exits, or is
+    /// released, so snapshot and relay loops can sleep until then.
+    changes: domain.changes.Signal = .{},
+    /// IPC socket exported to children as `PROCTMUX_SOCKET`; empty when the
+    /// controller is not behind a server.
+    socket_path: []const u8 = "",
     mutex: std.Thread.Mutex = .{},
 
     pub fn init(
@@ -35,7 +87,10 @@ pub const Controller = struct {
             .allocator = allocator,
             .global_config = global_config,
             .processes = std.AutoHashMap(domain.process.ProcessId, *Instance).init(allocator),
+            .starting = std.AutoHashMap(domain.process.ProcessId, domain.process.ProcessStatus).init(allocator),
             .scrollbacks = std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer).init(allocator),
+            .stderr_scrollbacks = std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer).init(allocator),
+            .launches = std.AutoHashMap(domain.process.ProcessId, ProcessStats).init(allocator),
         };
     }
 
@@ -47,36 +102,111 @@ pub const Controller = struct {
             self.mutex.unlock();
 
             const id = maybe_id orelse break;
-            const instance = self.getInstance(id) orelse continue;
-            if (instance.isRunning()) {
+            if (self.isRunning(id)) {
                 self.stopProcess(id) catch {};
             } else {
                 self.cleanupProcess(id) catch {};
             }
         }
 
-        var scrollback_it = self.scrollbacks.valueIterator();
-        while (scrollback_it.next()) |scrollback| {
-            scrollback.*.deinit();
-            self.allocator.destroy(scrollback.*);
+        for ([_]*std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer){ &self.scrollbacks, &self.stderr_scrollbacks }) |buffers| {
+            var scrollback_it = buffers.valueIterator();
+            while (scrollback_it.next()) |scrollback| {
+                scrollback.*.deinit();
+                self.allocator.destroy(scrollback.*);
+            }
+            buffers.deinit();
         }
-        self.scrollbacks.deinit();
+        self.launches.deinit();
+        self.starting.deinit();
         self.processes.deinit();
     }
 
     /// Starts a new process instance for `id`. The id must not already be
     /// active; natural exits are cleaned up through `cleanupProcess` before reuse.
+    /// A failing `pre_start` hook aborts the start; `post_start` failures are
+    /// only logged because the process is already running. Docker processes
+    /// start their container before the log follower is spawned.
     pub fn startProcess(
         self: *Controller,
         id: domain.process.ProcessId,
         proc_cfg: *const config.schema.ProcessConfig,
     ) !*Instance {
-        self.mutex.lock();
-        defer self.mutex.unlock();
+        try self.reserveStart(id);
+        defer self.finishStart(id);
+        return self.startReserved(id, proc_cfg);
+    }
 
-        if (self.processes.contains(id)) return error.ProcessAlreadyExists;
-        const scrollback = try self.scrollbackForStartLocked(id);
+    /// Stops `id` if it runs, waits `pause_ms` so ports and files are let go,
+    /// and starts it again. The id stays reserved throughout and reports
+    /// `restarting`, so no other start can slip in between. A process without
+    /// an instance is simply started.
+    pub fn restartProcess(
+        self: *Controller,
+        id: domain.process.ProcessId,
+        proc_cfg: *const config.schema.ProcessConfig,
+        pause_ms: u64,
+    ) !*Instance {
+        const instance = self.claimInstance(id) catch |err| switch (err) {
+            error.ProcessNotFound => return self.startProcess(id, proc_cfg),
+            error.ProcessStopping => return err,
+        };
+        _ = instance.apply(.restart) catch {};
+        const was_running = instance.isRunning();
+        if (was_running) self.haltInstance(id, instance);
+
+        const released = self.releaseProcess(id, instance, .{ .run_on_kill = was_running, .then_restart = true });
+        defer self.finishStart(id);
+        try released;
+
+        if (was_running and pause_ms > 0) std.Thread.sleep(pause_ms * std.time.ns_per_ms);
+        return self.startReserved(id, proc_cfg);
+    }
+
+    /// The body of a start once `id` is reserved.
+    fn startReserved(
+        self: *Controller,
+        id: domain.process.ProcessId,
+        proc_cfg: *const config.schema.ProcessConfig,
+    ) !*Instance {
+        self.applyReserved(id, .start);
+        errdefer self.applyReserved(id, .start_failed);
+
+        if (proc_cfg.pre_start.items.len > 0 or proc_cfg.isDocker()) {
+            const cwd = try builder.resolveCwd(self.allocator, proc_cfg.cwd, self.global_config);
+            defer if (cwd.len > 0) self.allocator.free(cwd);
+            if (proc_cfg.pre_start.items.len > 0) {
+                self.runHook(id, proc_cfg, .pre_start, cwd) catch return error.PreStartHookFailed;
+            }
+            if (proc_cfg.isDocker()) {
+                try docker.start(self.allocator, proc_cfg, self.global_config, self.metadata(id, proc_cfg), cwd);
+            }
+        }
+
+        const instance = self.launchProcess(id, proc_cfg) catch |err| {
+            if (proc_cfg.isDocker()) {
+                docker.stop(self.allocator, proc_cfg, self.metadata(id, proc_cfg), resolveStopTimeoutMs(proc_cfg));
+            }
+            return err;
+        };
+        defer instance.lock.unlockShared();
+        self.runHook(id, proc_cfg, .post_start, instance.command_spec.cwd) catch {};
+        return instance;
+    }
+
+    /// Spawns the process without holding the mutex, so a slow fork or exec
+    /// cannot stall snapshots; `reserveStart` already keeps the id exclusive.
+    /// Returns the instance pinned with a shared lock, taken before it is
+    /// published so a concurrent stop cannot free it under the caller.
+    fn launchProcess(
+        self: *Controller,
+        id: domain.process.ProcessId,
+        proc_cfg: *const config.schema.ProcessConfig,
+    ) !*Instance {
+        const scrollback = try self.outputBuffer(id);
         scrollback.clear();
+        const stderr_scrollback = if (proc_cfg.separate_stderr) try self.stderrBuffer(id) else null;
+        if (stderr_scrollback) |buffer| buffer.clear();
 
         const command_spec = (try builder.buildCommand(self.allocator, proc_cfg, self.global_config)) orelse {
             return error.InvalidProcessConfig;
@@ -84,8 +214,20 @@ pub const Controller = struct {
         var command_spec_owned = true;
         errdefer if (command_spec_owned) command_spec.deinit(self.allocator);
 
-        var env_map = try env.buildMap(self.allocator, proc_cfg);
+        var env_map = try env.buildMap(self.allocator, proc_cfg, self.metadata(id, proc_cfg));
         defer env_map.deinit();
+        try secrets.apply(self.allocator, &env_map, proc_cfg, self.global_config, command_spec.cwd);
+
+        var sinks = try sink.Sinks.open(self.allocator, proc_cfg, self.global_config);
+        var sinks_owned = true;
+        errdefer if (sinks_owned) sinks.deinit();
+
+        const redact_patterns: []const []const u8 = if (self.global_config) |cfg| cfg.redact_patterns.items else &.{};
+        var redactor = try redact.Redactor.init(self.allocator, redact_patterns);
+        var redactor_owned = true;
+        errdefer if (redactor_owned) redactor.deinit();
+        var stderr_redactor = if (proc_cfg.separate_stderr) try redact.Redactor.init(self.allocator, redact_patterns) else null;
+        errdefer if (redactor_owned) if (stderr_redactor) |*stderr| stderr.deinit();
 
         var started = try spawn.start(self.allocator, proc_cfg, command_spec, &env_map);
         errdefer started.deinit();
@@ -100,23 +242,89 @@ pub const Controller = struct {
             .command_spec = command_spec,
             .handle = started.handle,
             .scrollback = scrollback,
+            .sinks = sinks,
+            .redactor = redactor,
+            .stderr_scrollback = stderr_scrollback,
+            .stderr_redactor = stderr_redactor,
+            .changes = &self.changes,
         };
         command_spec_owned = false;
+        sinks_owned = false;
+        redactor_owned = false;
         started.disarm();
         errdefer instance.deinit();
+        // Before the exit watcher exists, so its `exit` always finds `running`.
+        _ = try instance.apply(.spawned);
 
-        instance.output_thread = try std.Thread.spawn(.{}, output.capture, .{instance});
-        instance.wait_thread = try std.Thread.spawn(.{}, spawn.waitForExit, .{instance});
+        instance.output_thread = try threads.spawn(.{}, output.capture, .{instance});
+        instance.wait_thread = try threads.spawn(.{}, spawn.waitForExit, .{instance});
 
-        try self.processes.put(id, instance);
+        {
+            self.mutex.lock();
+            defer self.mutex.unlock();
+            try self.launches.ensureUnusedCapacity(1);
+            instance.lock.lockShared();
+            errdefer instance.lock.unlockShared();
+            try self.processes.put(id, instance);
+
+            const launch = self.launches.getOrPutAssumeCapacity(id);
+            if (!launch.found_existing) launch.value_ptr.* = .{};
+            launch.value_ptr.starts += 1;
+            launch.value_ptr.last_started_ms = std.time.milliTimestamp();
+        }
+        self.changes.notify();
         return instance;
     }
 
+    fn reserveStart(self: *Controller, id: domain.process.ProcessId) !void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (self.processes.contains(id) or self.starting.contains(id)) return error.ProcessAlreadyExists;
+        try self.starting.put(id, .halted);
+    }
+
+    /// Moves a reservation's status; events that do not fit are dropped.
+    fn applyReserved(self: *Controller, id: domain.process.ProcessId, event: domain.process.StatusEvent) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const status = self.starting.getPtr(id) orelse return;
+        status.* = status.next(event) catch return;
+        self.changes.notify();
+    }
+
+    fn finishStart(self: *Controller, id: domain.process.ProcessId) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        _ = self.starting.remove(id);
+    }
+
     /// Stops a running process with the configured signal escalation and then
-    /// releases the instance with user cleanup hooks enabled.
+    /// releases the instance with user cleanup hooks enabled. Only one stop or
+    /// cleanup owns an instance; the others get `error.ProcessStopping`.
     pub fn stopProcess(self: *Controller, id: domain.process.ProcessId) !void {
-        const instance = self.getInstance(id) orelse return error.ProcessNotFound;
+        const instance = try self.claimInstance(id);
+        _ = instance.apply(.stop) catch {};
+        self.changes.notify();
+        self.haltInstance(id, instance);
+        try self.releaseProcess(id, instance, .{ .run_on_kill = true });
+    }
 
+    /// Runs `pre_stop` and escalates signals until the process is gone; the
+    /// caller owns the instance's release.
+    fn haltInstance(self: *Controller, id: domain.process.ProcessId, instance: *Instance) void {
+        if (instance.isRunning()) {
+            self.runHook(id, instance.config, .pre_stop, instance.command_spec.cwd) catch {};
+            // The log follower exits on its own once the container stops.
+            if (instance.config.isDocker()) {
+                docker.stop(
+                    self.allocator,
+                    instance.config,
+                    self.metadata(id, instance.config),
+                    resolveStopTimeoutMs(instance.config),
+                );
+                _ = waitUntilStopped(instance, 2000);
+            }
+        }
         if (instance.isRunning()) {
             const stop_signal = resolveStopSignal(instance.config);
             signalProcessTree(instance.pid(), stop_signal);
@@ -125,24 +333,47 @@ pub const Controller = struct {
                 _ = waitUntilStopped(instance, 2000);
             }
         }
+    }
 
-        try self.releaseProcess(id, instance, true);
+    /// SIGKILLs every running process tree without reaping. Shutdown uses this
+    /// when its overall deadline passes while per-process stops are still waiting.
+    pub fn killRunning(self: *Controller) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        var it = self.processes.valueIterator();
+        while (it.next()) |instance| {
+            if (instance.*.isRunning()) signalProcessTree(instance.*.pid(), std.posix.SIG.KILL);
+        }
     }
 
     /// Releases an already-stopped instance without running `on_kill`; this path
     /// is for natural exits and pre-start cleanup, not user-requested stops.
     pub fn cleanupProcess(self: *Controller, id: domain.process.ProcessId) !void {
-        const instance = self.getInstance(id) orelse return;
-        if (instance.isRunning()) return error.ProcessStillRunning;
+        const instance = self.claimInstance(id) catch |err| switch (err) {
+            // Missing or already being released by someone else: nothing to do.
+            error.ProcessNotFound, error.ProcessStopping => return,
+        };
+        if (instance.isRunning()) {
+            instance.releasing.store(false, .release);
+            return error.ProcessStillRunning;
+        }
 
-        try self.releaseProcess(id, instance, false);
+        try self.releaseProcess(id, instance, .{ .run_on_kill = false });
     }
 
+    const ReleaseOptions = struct {
+        /// A user stop runs `on_kill` and `post_stop`; cleanup after a natural
+        /// exit does not.
+        run_on_kill: bool,
+        /// Leaves a `restarting` reservation in the instance's place.
+        then_restart: bool = false,
+    };
+
     fn releaseProcess(
         self: *Controller,
         id: domain.process.ProcessId,
         instance: *Instance,
-        run_on_kill: bool,
+        options: ReleaseOptions,
     ) !void {
         if (instance.wait_thread) |thread| {
             thread.join();
@@ -154,14 +385,29 @@ pub const Controller = struct {
         }
 
         self.mutex.lock();
+        if (options.then_restart) {
+            self.starting.put(id, .restarting) catch |err| {
+                self.mutex.unlock();
+                instance.releasing.store(false, .release);
+                return err;
+            };
+        } else {
+            _ = instance.apply(.released) catch {};
+        }
         _ = self.processes.remove(id);
         self.mutex.unlock();
+        self.changes.notify();
+
+        // Nobody can find the instance any more; wait out whoever still uses it.
+        instance.lock.lock();
+        instance.lock.unlock();
 
         // Run the hook after threads are joined and the map no longer exposes
         // the instance, so a slow hook cannot make the process appear alive.
-        const on_kill_result = if (run_on_kill)
-            on_kill.execute(self.allocator, instance.config)
+        const on_kill_result = if (options.run_on_kill)
+            on_kill.execute(self.allocator, instance.config, self.metadata(id, instance.config), instance.command_spec.cwd)
         else {};
+        if (options.run_on_kill) self.runHook(id, instance.config, .post_stop, instance.command_spec.cwd) catch {};
         instance.deinit();
         self.allocator.destroy(instance);
 
@@ -169,12 +415,26 @@ pub const Controller = struct {
     }
 
     pub fn isRunning(self: *Controller, id: domain.process.ProcessId) bool {
-        const instance = self.getInstance(id) orelse return false;
+        const instance = self.acquireInstance(id) orelse return false;
+        defer instance.lock.unlockShared();
         return instance.isRunning();
     }
 
+    /// The exit code of a process that exited and has not been cleaned up yet;
+    /// null while it runs or once its instance is released.
+    pub fn exitCode(self: *Controller, id: domain.process.ProcessId) ?u32 {
+        const instance = self.acquireInstance(id) orelse return null;
+        defer instance.lock.unlockShared();
+        return instance.exitCode();
+    }
+
+    /// The lifecycle status of `id`: its instance's status while it has one,
+    /// its reservation's while a start or restart is in flight, else `halted`.
     pub fn getProcessStatus(self: *Controller, id: domain.process.ProcessId) domain.process.ProcessStatus {
-        return if (self.isRunning(id)) .running else .halted;
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (self.processes.get(id)) |instance| return instance.status.load(.acquire);
+        return self.starting.get(id) orelse .halted;
     }
 
     pub fn processController(self: *Controller) domain.process.ProcessController {
@@ -182,11 +442,19 @@ pub const Controller = struct {
             .context = self,
             .get_process_status = adapterGetProcessStatus,
             .get_pid = adapterGetPID,
+            .get_started_ms = adapterGetStartedMs,
+            .get_exit_code = adapterGetExitCode,
+            .get_output_bytes = adapterGetOutputBytes,
+            .get_output_lines = adapterGetOutputLines,
+            .get_output_rate = adapterGetOutputRate,
+            .get_last_output_ms = adapterGetLastOutputMs,
+            .get_bells = adapterGetBells,
         };
     }
 
     pub fn getPID(self: *Controller, id: domain.process.ProcessId) i32 {
-        const instance = self.getInstance(id) orelse return -1;
+        const instance = self.acquireInstance(id) orelse return -1;
+        defer instance.lock.unlockShared();
         if (!instance.isRunning()) return -1;
         return @intCast(instance.pid());
     }
@@ -203,21 +471,146 @@ pub const Controller = struct {
         return ids;
     }
 
+    pub fn processStats(self: *Controller, id: domain.process.ProcessId) ProcessStats {
+        self.mutex.lock();
+        var stats = self.launches.get(id) orelse ProcessStats{};
+        const scrollback = self.scrollbacks.get(id);
+        self.mutex.unlock();
+
+        if (scrollback) |buffer| {
+            stats.output_bytes = buffer.totalWritten();
+            stats.output_lines = buffer.totalLines();
+            const recent = buffer.writtenSince(std.time.milliTimestamp() - rate_window_ms);
+            stats.output_rate = recent * std.time.ms_per_s / rate_window_ms;
+            stats.last_output_ms = buffer.lastOutputMs();
+            stats.bells = buffer.bellCount();
+        }
+        return stats;
+    }
+
     pub fn getScrollback(self: *Controller, allocator: std.mem.Allocator, id: domain.process.ProcessId) ![]u8 {
         const scrollback = self.getScrollbackBuffer(id) orelse return error.ProcessNotFound;
         return scrollback.bytes(allocator);
     }
 
+    /// Copies the newest output of `id` into `out`; empty before its first
+    /// start.
+    pub fn outputTail(self: *Controller, id: domain.process.ProcessId, out: []u8) []u8 {
+        const scrollback = self.getScrollbackBuffer(id) orelse return out[0..0];
+        return scrollback.tail(out);
+    }
+
+    /// Retained output of `id` from stream offset `offset` on; see
+    /// `RingBuffer.bytesFrom`.
+    pub fn outputFrom(self: *Controller, allocator: std.mem.Allocator, id: domain.process.ProcessId, offset: u64) !ring.Chunk {
+        const scrollback = self.getScrollbackBuffer(id) orelse return error.ProcessNotFound;
+        return scrollback.bytesFrom(allocator, offset);
+    }
+
+    /// Returns the scrollback for `id`, creating an empty one if the process has
+    /// not started yet. Buffers live as long as the controller and are reused
+    /// across restarts, so a live reader follows the process through restarts.
+    pub fn outputBuffer(self: *Controller, id: domain.process.ProcessId) !*ring.RingBuffer {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.scrollbackForStartLocked(id);
+    }
+
+    /// Returns the stderr-only output of `id`, created empty like
+    /// `outputBuffer`. Only processes with `separate_stderr` write to it.
+    pub fn stderrBuffer(self: *Controller, id: domain.process.ProcessId) !*ring.RingBuffer {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.bufferLocked(&self.stderr_scrollbacks, id, default_stderr_capacity);
+    }
+
+    /// Runs `notifier` after output is written to any process's scrollback;
+    /// null removes it.
+    pub fn setOutputNotifier(self: *Controller, notifier: ?ring.WriteNotifier) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        self.output_notifier = notifier;
+        var it = self.scrollbacks.valueIterator();
+        while (it.next()) |scrollback| scrollback.*.setWriteNotifier(notifier);
+        var stderr_it = self.stderr_scrollbacks.valueIterator();
+        while (stderr_it.next()) |scrollback| scrollback.*.setWriteNotifier(notifier);
+    }
+
     pub fn sendBytes(self: *Controller, id: domain.process.ProcessId, bytes: []const u8) !void {
-        const instance = self.getInstance(id) orelse return error.ProcessNotFound;
-        if (!instance.isRunning()) return error.ProcessNotRunning;
-        try instance.sendBytes(bytes);
+        {
+            const instance = self.acquireInstance(id) orelse return error.ProcessNotFound;
+            defer instance.lock.unlockShared();
+            if (!instance.isRunning()) return error.ProcessNotRunning;
+            try instance.sendBytes(bytes);
+        }
+
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (self.launches.getPtr(id)) |launch| launch.last_input_ms = std.time.milliTimestamp();
+    }
+
+    /// Resizes a running process terminal so full-screen programs lay out for
+    /// the pane they are viewed in rather than the size they were started with.
+    pub fn resizeProcess(self: *Controller, id: domain.process.ProcessId, rows: u16, cols: u16) !void {
+        const instance = self.acquireInstance(id) orelse return error.ProcessNotFound;
+        defer instance.lock.unlockShared();
+        try instance.resize(rows, cols);
     }
 
-    fn getInstance(self: *Controller, id: domain.process.ProcessId) ?*Instance {
+    /// Runs a lifecycle hook without holding the controller mutex, so a slow
+    /// hook cannot stall snapshots. Failures are logged here.
+    fn runHook(
+        self: *Controller,
+        id: domain.process.ProcessId,
+        proc_cfg: *const config.schema.ProcessConfig,
+        hook: hooks.Hook,
+        cwd: []const u8,
+    ) !void {
+        hooks.run(self.allocator, proc_cfg, self.metadata(id, proc_cfg), hook, cwd) catch |err| {
+            log.warn("{s} hook for process {d} failed: {s}", .{ @tagName(hook), id.toInt(), @errorName(err) });
+            return err;
+        };
+    }
+
+    /// The `PROCTMUX_*` values for a process and its hooks. The label is the
+    /// config key whose entry is `proc_cfg`.
+    pub fn metadata(
+        self: *const Controller,
+        id: domain.process.ProcessId,
+        proc_cfg: *const config.schema.ProcessConfig,
+    ) env.Metadata {
+        var result = env.Metadata{ .id = id, .socket_path = self.socket_path };
+        const global_config = self.global_config orelse return result;
+        result.config_path = global_config.file_path;
+        var it = global_config.procs.iterator();
+        while (it.next()) |entry| {
+            if (entry.value_ptr == proc_cfg) {
+                result.label = entry.key_ptr.*;
+                break;
+            }
+        }
+        return result;
+    }
+
+    /// Looks up `id` and pins it with a shared instance lock taken before the
+    /// map is unlocked; callers release it with `unlockShared`.
+    fn acquireInstance(self: *Controller, id: domain.process.ProcessId) ?*Instance {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const instance = self.processes.get(id) orelse return null;
+        instance.lock.lockShared();
+        return instance;
+    }
+
+    /// Looks up `id` for release. The caller becomes its only releaser, which
+    /// keeps it alive without a shared lock until `releaseProcess` frees it.
+    fn claimInstance(self: *Controller, id: domain.process.ProcessId) error{ ProcessNotFound, ProcessStopping }!*Instance {
         self.mutex.lock();
         defer self.mutex.unlock();
-        return self.processes.get(id);
+        const instance = self.processes.get(id) orelse return error.ProcessNotFound;
+        if (!instance.claimRelease()) return error.ProcessStopping;
+        return instance;
     }
 
     fn getScrollbackBuffer(self: *Controller, id: domain.process.ProcessId) ?*ring.RingBuffer {
@@ -227,14 +620,24 @@ pub const Controller = struct {
     }
 
     fn scrollbackForStartLocked(self: *Controller, id: domain.process.ProcessId) !*ring.RingBuffer {
-        if (self.scrollbacks.get(id)) |scrollback| return scrollback;
+        return self.bufferLocked(&self.scrollbacks, id, default_scrollback_capacity);
+    }
+
+    fn bufferLocked(
+        self: *Controller,
+        buffers: *std.AutoHashMap(domain.process.ProcessId, *ring.RingBuffer),
+        id: domain.process.ProcessId,
+        capacity: usize,
+    ) !*ring.RingBuffer {
+        if (buffers.get(id)) |scrollback| return scrollback;
 
         const scrollback = try self.allocator.create(ring.RingBuffer);
         errdefer self.allocator.destroy(scrollback);
-        scrollback.* = try ring.RingBuffer.init(self.allocator, default_scrollback_capacity);
+        scrollback.* = try ring.RingBuffer.init(self.allocator, capacity);
         errdefer scrollback.deinit();
+        scrollback.write_notifier = self.output_notifier;
 
-        try self.scrollbacks.put(id, scrollback);
+        try buffers.put(id, scrollback);
         return scrollback;
     }
 };
@@ -253,6 +656,42 @@ fn adapterGetPID(context: *anyopaque, id: domain.process.ProcessId) i32 {
     return self.getPID(id);
 }
 
+fn adapterGetStartedMs(context: *anyopaque, id: domain.process.ProcessId) i64 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    if (!self.isRunning(id)) return 0;
+    return self.processStats(id).last_started_ms;
+}
+
+fn adapterGetExitCode(context: *anyopaque, id: domain.process.ProcessId) ?u32 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.exitCode(id);
+}
+
+fn adapterGetOutputBytes(context: *anyopaque, id: domain.process.ProcessId) u64 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.processStats(id).output_bytes;
+}
+
+fn adapterGetOutputLines(context: *anyopaque, id: domain.process.ProcessId) u64 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.processStats(id).output_lines;
+}
+
+fn adapterGetOutputRate(context: *anyopaque, id: domain.process.ProcessId) u64 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.processStats(id).output_rate;
+}
+
+fn adapterGetLastOutputMs(context: *anyopaque, id: domain.process.ProcessId) i64 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.processStats(id).last_output_ms;
+}
+
+fn adapterGetBells(context: *anyopaque, id: domain.process.ProcessId) u32 {
+    const self: *Controller = @ptrCast(@alignCast(context));
+    return self.processStats(id).bells;
+}
+
 fn resolveStopSignal(proc_cfg: *const config.schema.ProcessConfig) u8 {
     if (proc_cfg.stop > 0) return @intCast(proc_cfg.stop);
     return std.posix.SIG.TERM;
@@ -0,0 +1,147 @@
+//! Docker-backed processes.
+//! A `type: docker` process starts its container detached, follows `docker logs` in the PTY so output reaches the scrollback like any other process, and stops through `docker stop`.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const builder = @import("builder.zig");
+const env = @import("env.zig");
+const hooks = @import("hooks.zig");
+
+const log = std.log.scoped(.process);
+
+/// `docker run` may pull the image first, so it gets far longer than a hook.
+const run_timeout_ms = 10 * 60 * 1000;
+const remove_timeout_ms = 30_000;
+/// Slack on top of the stop timeout for the docker CLI itself.
+const stop_grace_ms = 10_000;
+
+/// Replaces any container left over under the same name, then starts a new
+/// one detached. The previous container is kept until here so its logs stay
+/// inspectable after an exit.
+pub fn start(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    global_config: ?*const config.schema.Config,
+    metadata: env.Metadata,
+    cwd: []const u8,
+) !void {
+    const remove = [_][]const u8{ "docker", "rm", "--force", proc_cfg.container_name };
+    hooks.runCommand(allocator, &remove, proc_cfg, metadata, cwd, remove_timeout_ms, "docker rm") catch {};
+
+    const argv = try runArgv(allocator, proc_cfg, global_config);
+    defer freeArgv(allocator, argv);
+    hooks.runCommand(allocator, argv, proc_cfg, metadata, cwd, run_timeout_ms, "docker run") catch |err| switch (err) {
+        error.HookFailed, error.HookTimedOut => return error.DockerRunFailed,
+        else => return err,
+    };
+}
+
+/// Asks Docker to stop the container, which SIGKILLs it once `timeout_ms`
+/// passes. Failures are logged; the caller still stops the log follower.
+pub fn stop(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    metadata: env.Metadata,
+    timeout_ms: u64,
+) void {
+    var seconds_buffer: [24]u8 = undefined;
+    const seconds = std.fmt.bufPrint(&seconds_buffer, "{d}", .{(timeout_ms + 999) / 1000}) catch unreachable;
+    const argv = [_][]const u8{ "docker", "stop", "--time", seconds, proc_cfg.container_name };
+    hooks.runCommand(allocator, &argv, proc_cfg, metadata, "", timeout_ms + stop_grace_ms, "docker stop") catch |err| {
+        log.warn("docker stop failed for container {s}: {s}", .{ proc_cfg.container_name, @errorName(err) });
+    };
+}
+
+/// Argv for `docker run --detach`. `env` names are passed with `--env NAME`
+/// so values come from the CLI's environment instead of its argv. The
+/// container command is `cmd`, or `sh -c <shell>` when `shell` is set.
+pub fn runArgv(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    global_config: ?*const config.schema.Config,
+) ![]const []const u8 {
+    var argv = std.array_list.Managed([]const u8).init(allocator);
+    errdefer {
+        for (argv.items) |arg| allocator.free(arg);
+        argv.deinit();
+    }
+
+    for ([_][]const u8{ "docker", "run", "--detach", "--name", proc_cfg.container_name }) |arg| {
+        try argv.append(try allocator.dupe(u8, arg));
+    }
+    for (proc_cfg.ports.items) |port| try appendFlag(allocator, &argv, "--publish", port);
+    for (proc_cfg.volumes.items) |volume| {
+        const resolved = try builder.resolveCwd(allocator, volume, global_config);
+        defer if (resolved.len > 0) allocator.free(resolved);
+        try appendFlag(allocator, &argv, "--volume", resolved);
+    }
+    var env_it = proc_cfg.env.keyIterator();
+    while (env_it.next()) |name| try appendFlag(allocator, &argv, "--env", name.*);
+
+    try argv.append(try allocator.dupe(u8, proc_cfg.image));
+    if (proc_cfg.shell.len > 0) {
+        for ([_][]const u8{ "sh", "-c", proc_cfg.shell }) |arg| try argv.append(try allocator.dupe(u8, arg));
+    } else {
+        for (proc_cfg.cmd.items) |arg| try argv.append(try allocator.dupe(u8, arg));
+    }
+    return argv.toOwnedSlice();
+}
+
+pub fn freeArgv(allocator: std.mem.Allocator, argv: []const []const u8) void {
+    for (argv) |arg| allocator.free(arg);
+    allocator.free(argv);
+}
+
+fn appendFlag(
+    allocator: std.mem.Allocator,
+    argv: *std.array_list.Managed([]const u8),
+    flag: []const u8,
+    value: []const u8,
+) !void {
+    try argv.append(try allocator.dupe(u8, flag));
+    try argv.append(try allocator.dupe(u8, value));
+}
+
+test "docker processes run detached and follow their logs" {
+    var global = config.schema.Config.empty(std.testing.allocator);
+    defer global.deinit();
+    global.file_path = "/srv/app/proctmux.yaml";
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.@"type" = "docker";
+    proc_cfg.image = "postgres:16";
+    proc_cfg.container_name = "proctmux-db";
+    proc_cfg.shell = "postgres -c log_statement=all";
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.ports, "5432:5432");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.volumes, "{config_dir}/data:/var/lib/postgresql/data");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "POSTGRES_PASSWORD", "secret");
+
+    const run = try runArgv(std.testing.allocator, &proc_cfg, &global);
+    defer freeArgv(std.testing.allocator, run);
+    const expected = [_][]const u8{
+        "docker",
+        "run",
+        "--detach",
+        "--name",
+        "proctmux-db",
+        "--publish",
+        "5432:5432",
+        "--volume",
+        "/srv/app/data:/var/lib/postgresql/data",
+        "--env",
+        "POSTGRES_PASSWORD",
+        "postgres:16",
+        "sh",
+        "-c",
+        "postgres -c log_statement=all",
+    };
+    try std.testing.expectEqual(expected.len, run.len);
+    for (expected, run) |want, got| try std.testing.expectEqualStrings(want, got);
+
+    const logs = try builder.buildCommand(std.testing.allocator, &proc_cfg, &global) orelse return error.ExpectedCommand;
+    defer logs.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(usize, 4), logs.argv.len);
+    try std.testing.expectEqualStrings("logs", logs.argv[1]);
+    try std.testing.expectEqualStrings("proctmux-db", logs.argv[3]);
+}
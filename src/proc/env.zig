@@ -1,34 +1,343 @@
 //! Environment construction for child processes and hooks.
-//! Parent environment inheritance, PATH augmentation, and per-process overrides are resolved here to keep spawn paths deterministic.
+//! Parent environment inheritance, PATH augmentation, and per-process overrides are resolved here into one map, so every name has exactly one value and precedence never depends on exec semantics.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+
+/// Identifies the process to its children through `PROCTMUX_*` variables so a
+/// child can find itself and drive proctmux over the socket. Empty fields are
+/// left out of the environment.
+pub const Metadata = struct {
+    label: []const u8 = "",
+    id: domain.process.ProcessId = .none,
+    socket_path: []const u8 = "",
+    config_path: []const u8 = "",
+};
+
+/// PATH searched when a child environment has none, e.g. with `env_clear`.
+pub const default_path = "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin";
+
+/// Shown in place of a secret's value wherever the environment is displayed.
+pub const redacted_value = "<redacted>";
+
+/// Where a variable in a child environment got its value.
+pub const Source = enum {
+    /// Unchanged from proctmux's own environment.
+    inherited,
+    /// One of the `PROCTMUX_*` variables.
+    metadata,
+    /// The process's `term`, `colorterm`, or `force_color`.
+    terminal,
+    /// PATH with the process's `add_path` entries appended.
+    add_path,
+    /// The process's `env`, including a PATH that `add_path` then extends.
+    config,
+    /// The process's `secrets`; the value is shown as `redacted_value`.
+    secret,
+};
+
+/// One variable of a child environment. `overridden` is proctmux's own value
+/// when the variable's source replaced it with a different one.
+pub const Variable = struct {
+    name: []const u8,
+    value: []const u8,
+    source: Source,
+    overridden: ?[]const u8 = null,
+};
+
+/// Every variable a process would start with, sorted by name. Values borrow
+/// the inspection and the base environment it was built from.
+pub const Inspection = struct {
+    env_map: std.process.EnvMap,
+    variables: []Variable,
+
+    pub fn deinit(self: *Inspection) void {
+        self.env_map.allocator.free(self.variables);
+        self.env_map.deinit();
+    }
+};
 
 /// Builds the child environment from parent process state plus process config.
-/// Configured env values override inherited values after PATH augmentation.
+/// Later layers override earlier ones: the inherited environment, or none with
+/// `env_clear`; the metadata; the terminal settings; then `env`. `add_path`
+/// extends the resulting PATH.
 pub fn buildMap(
     allocator: std.mem.Allocator,
     proc_cfg: *const config.schema.ProcessConfig,
+    metadata: Metadata,
 ) !std.process.EnvMap {
-    var env_map = try std.process.getEnvMap(allocator);
+    var env_map = if (proc_cfg.env_clear)
+        std.process.EnvMap.init(allocator)
+    else
+        try std.process.getEnvMap(allocator);
     errdefer env_map.deinit();
+    try putMetadata(&env_map, metadata);
+    try applyProcess(allocator, &env_map, proc_cfg);
+    return env_map;
+}
+
+/// Builds the environment `buildMap` would give a process started from
+/// `base`, and says where each variable's value came from. Secrets are not
+/// resolved; they appear as `redacted_value`.
+pub fn inspect(
+    allocator: std.mem.Allocator,
+    base: *const std.process.EnvMap,
+    proc_cfg: *const config.schema.ProcessConfig,
+    metadata: Metadata,
+) !Inspection {
+    var env_map = std.process.EnvMap.init(allocator);
+    errdefer env_map.deinit();
+    if (!proc_cfg.env_clear) {
+        var base_it = base.iterator();
+        while (base_it.next()) |entry| try env_map.put(entry.key_ptr.*, entry.value_ptr.*);
+    }
+    try putMetadata(&env_map, metadata);
+    try applyProcess(allocator, &env_map, proc_cfg);
+    var secret_it = proc_cfg.secrets.iterator();
+    while (secret_it.next()) |entry| try env_map.put(entry.key_ptr.*, redacted_value);
+
+    const variables = try allocator.alloc(Variable, env_map.count());
+    errdefer allocator.free(variables);
+    var index: usize = 0;
+    var it = env_map.iterator();
+    while (it.next()) |entry| : (index += 1) {
+        const name = entry.key_ptr.*;
+        const value = entry.value_ptr.*;
+        const source: Source = if (proc_cfg.secrets.contains(name))
+            .secret
+        else if (proc_cfg.env.contains(name))
+            .config
+        else if (terminalValue(proc_cfg, name) != null)
+            .terminal
+        else if (std.mem.eql(u8, name, "PATH") and proc_cfg.add_path.items.len > 0)
+            .add_path
+        else if (isMetadataName(name))
+            .metadata
+        else
+            .inherited;
+        // A cleared environment replaced nothing.
+        var overridden = if (proc_cfg.env_clear) null else base.get(name);
+        if (overridden) |own| {
+            if (std.mem.eql(u8, own, value)) overridden = null;
+        }
+        variables[index] = .{ .name = name, .value = value, .source = source, .overridden = overridden };
+    }
+    std.mem.sort(Variable, variables, {}, lessThanVariable);
+    return .{ .env_map = env_map, .variables = variables };
+}
+
+/// Applies the terminal settings, `env`, and then `add_path`, the parts of a
+/// child environment that come from process config. Each put replaces the
+/// name's earlier value, and `add_path` extends whichever PATH won, or
+/// `default_path` when none is set.
+pub fn applyProcess(
+    allocator: std.mem.Allocator,
+    env_map: *std.process.EnvMap,
+    proc_cfg: *const config.schema.ProcessConfig,
+) !void {
+    for (terminal_names) |name| {
+        if (terminalValue(proc_cfg, name)) |value| try env_map.put(name, value);
+    }
+
+    var it = proc_cfg.env.iterator();
+    while (it.next()) |entry| {
+        try env_map.put(entry.key_ptr.*, entry.value_ptr.*);
+    }
 
     if (proc_cfg.add_path.items.len > 0) {
         var path = std.array_list.Managed(u8).init(allocator);
         defer path.deinit();
 
-        if (env_map.get("PATH")) |current| try path.appendSlice(current);
+        try path.appendSlice(env_map.get("PATH") orelse default_path);
         for (proc_cfg.add_path.items) |part| {
             try path.append(':');
             try path.appendSlice(part);
         }
         try env_map.put("PATH", path.items);
     }
+}
 
-    var it = proc_cfg.env.iterator();
-    while (it.next()) |entry| {
-        try env_map.put(entry.key_ptr.*, entry.value_ptr.*);
+/// The parent environment with only the `PROCTMUX_*` metadata applied, for
+/// commands such as plugins that act for proctmux rather than one process.
+pub fn buildMetadataMap(allocator: std.mem.Allocator, metadata: Metadata) !std.process.EnvMap {
+    var env_map = try std.process.getEnvMap(allocator);
+    errdefer env_map.deinit();
+    try putMetadata(&env_map, metadata);
+    return env_map;
+}
+
+const terminal_names = [_][]const u8{ "TERM", "COLORTERM", "FORCE_COLOR", "CLICOLOR_FORCE" };
+
+/// The value the process's terminal settings give `name`, if any.
+fn terminalValue(proc_cfg: *const config.schema.ProcessConfig, name: []const u8) ?[]const u8 {
+    if (std.mem.eql(u8, name, "TERM")) return if (proc_cfg.term.len > 0) proc_cfg.term else null;
+    if (std.mem.eql(u8, name, "COLORTERM")) return if (proc_cfg.colorterm.len > 0) proc_cfg.colorterm else null;
+    if (std.mem.eql(u8, name, "FORCE_COLOR") or std.mem.eql(u8, name, "CLICOLOR_FORCE")) {
+        return if (proc_cfg.force_color) "1" else null;
     }
+    return null;
+}
 
-    return env_map;
+const metadata_names = [_][]const u8{ "PROCTMUX_LABEL", "PROCTMUX_PROC_ID", "PROCTMUX_SOCKET", "PROCTMUX_CONFIG" };
+
+fn putMetadata(env_map: *std.process.EnvMap, metadata: Metadata) !void {
+    // Inherited values would name the parent proctmux's process, not this one.
+    for (metadata_names) |name| env_map.remove(name);
+    if (metadata.label.len > 0) try env_map.put("PROCTMUX_LABEL", metadata.label);
+    if (metadata.id != .none) {
+        var id_buffer: [16]u8 = undefined;
+        const id = std.fmt.bufPrint(&id_buffer, "{d}", .{metadata.id.toInt()}) catch unreachable;
+        try env_map.put("PROCTMUX_PROC_ID", id);
+    }
+    if (metadata.socket_path.len > 0) try env_map.put("PROCTMUX_SOCKET", metadata.socket_path);
+    if (metadata.config_path.len > 0) try env_map.put("PROCTMUX_CONFIG", metadata.config_path);
+}
+
+fn isMetadataName(name: []const u8) bool {
+    for (metadata_names) |metadata_name| {
+        if (std.mem.eql(u8, name, metadata_name)) return true;
+    }
+    return false;
+}
+
+fn lessThanVariable(_: void, a: Variable, b: Variable) bool {
+    return std.mem.order(u8, a.name, b.name) == .lt;
+}
+
+test "child environment carries proctmux metadata under configured env" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "PROCTMUX_CONFIG", "/override.yaml");
+
+    var env_map = try buildMap(std.testing.allocator, &proc_cfg, .{
+        .label = "api",
+        .id = domain.process.ProcessId.fromInt(3),
+        .socket_path = "/tmp/proctmux-abc.socket",
+        .config_path = "/srv/app/proctmux.yaml",
+    });
+    defer env_map.deinit();
+
+    try std.testing.expectEqualStrings("api", env_map.get("PROCTMUX_LABEL").?);
+    try std.testing.expectEqualStrings("3", env_map.get("PROCTMUX_PROC_ID").?);
+    try std.testing.expectEqualStrings("/tmp/proctmux-abc.socket", env_map.get("PROCTMUX_SOCKET").?);
+    try std.testing.expectEqualStrings("/override.yaml", env_map.get("PROCTMUX_CONFIG").?);
+
+    var bare = try buildMap(std.testing.allocator, &proc_cfg, .{});
+    defer bare.deinit();
+    try std.testing.expect(bare.get("PROCTMUX_LABEL") == null);
+}
+
+test "inspection says where each variable came from and what it overrode" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.add_path, "/custom/bin");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "HOME", "/custom/home");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "KEEP", "yes");
+    var secret = config.schema.SecretConfig.empty(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &secret.cmd, "pass");
+    try config.schema.putOwnedSecret(std.testing.allocator, &proc_cfg.secrets, "DB_PASSWORD", secret);
+
+    var base = std.process.EnvMap.init(std.testing.allocator);
+    defer base.deinit();
+    try base.put("PATH", "/usr/bin");
+    try base.put("HOME", "/home/nick");
+    try base.put("KEEP", "yes");
+    try base.put("SHELL", "/bin/zsh");
+
+    var inspection = try inspect(std.testing.allocator, &base, &proc_cfg, .{ .label = "api" });
+    defer inspection.deinit();
+
+    const expected = [_]Variable{
+        .{ .name = "DB_PASSWORD", .value = redacted_value, .source = .secret },
+        .{ .name = "HOME", .value = "/custom/home", .source = .config, .overridden = "/home/nick" },
+        .{ .name = "KEEP", .value = "yes", .source = .config },
+        .{ .name = "PATH", .value = "/usr/bin:/custom/bin", .source = .add_path, .overridden = "/usr/bin" },
+        .{ .name = "PROCTMUX_LABEL", .value = "api", .source = .metadata },
+        .{ .name = "SHELL", .value = "/bin/zsh", .source = .inherited },
+    };
+    try std.testing.expectEqual(expected.len, inspection.variables.len);
+    for (expected, inspection.variables) |want, got| {
+        try std.testing.expectEqualStrings(want.name, got.name);
+        try std.testing.expectEqualStrings(want.value, got.value);
+        try std.testing.expectEqual(want.source, got.source);
+        try std.testing.expectEqualDeep(want.overridden, got.overridden);
+    }
+}
+
+test "child environment layers override by name with env above metadata and add_path on top" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.add_path, "/custom/bin");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "PATH", "/env/bin");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "PROCTMUX_LABEL", "renamed");
+
+    var base = std.process.EnvMap.init(std.testing.allocator);
+    defer base.deinit();
+    try base.put("PATH", "/usr/bin");
+    try base.put("PROCTMUX_LABEL", "parent");
+
+    var inspection = try inspect(std.testing.allocator, &base, &proc_cfg, .{ .label = "api" });
+    defer inspection.deinit();
+
+    try std.testing.expectEqual(@as(u32, 2), inspection.env_map.count());
+    try std.testing.expectEqualStrings("/env/bin:/custom/bin", inspection.env_map.get("PATH").?);
+    try std.testing.expectEqualStrings("renamed", inspection.env_map.get("PROCTMUX_LABEL").?);
+    try std.testing.expectEqual(Source.config, inspection.variables[0].source);
+}
+
+test "env_clear starts the child from an empty environment" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.env_clear = true;
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.add_path, "/custom/bin");
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "HOME", "/custom/home");
+
+    var env_map = try buildMap(std.testing.allocator, &proc_cfg, .{ .label = "api" });
+    defer env_map.deinit();
+
+    try std.testing.expectEqual(@as(u32, 3), env_map.count());
+    try std.testing.expectEqualStrings("/custom/home", env_map.get("HOME").?);
+    try std.testing.expectEqualStrings("api", env_map.get("PROCTMUX_LABEL").?);
+    try std.testing.expectEqualStrings(default_path ++ ":/custom/bin", env_map.get("PATH").?);
+
+    var base = std.process.EnvMap.init(std.testing.allocator);
+    defer base.deinit();
+    try base.put("HOME", "/home/nick");
+    try base.put("SHELL", "/bin/zsh");
+    var inspection = try inspect(std.testing.allocator, &base, &proc_cfg, .{});
+    defer inspection.deinit();
+    try std.testing.expectEqual(@as(usize, 2), inspection.variables.len);
+    try std.testing.expectEqualStrings("HOME", inspection.variables[0].name);
+    try std.testing.expect(inspection.variables[0].overridden == null);
+}
+
+test "terminal settings replace the inherited TERM and yield to env" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.term = "xterm-256color";
+    proc_cfg.colorterm = "truecolor";
+    proc_cfg.force_color = true;
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "FORCE_COLOR", "3");
+
+    var base = std.process.EnvMap.init(std.testing.allocator);
+    defer base.deinit();
+    try base.put("TERM", "dumb");
+
+    var inspection = try inspect(std.testing.allocator, &base, &proc_cfg, .{});
+    defer inspection.deinit();
+
+    const expected = [_]Variable{
+        .{ .name = "CLICOLOR_FORCE", .value = "1", .source = .terminal },
+        .{ .name = "COLORTERM", .value = "truecolor", .source = .terminal },
+        .{ .name = "FORCE_COLOR", .value = "3", .source = .config },
+        .{ .name = "TERM", .value = "xterm-256color", .source = .terminal, .overridden = "dumb" },
+    };
+    try std.testing.expectEqual(expected.len, inspection.variables.len);
+    for (expected, inspection.variables) |want, got| {
+        try std.testing.expectEqualStrings(want.name, got.name);
+        try std.testing.expectEqualStrings(want.value, got.value);
+        try std.testing.expectEqual(want.source, got.source);
+        try std.testing.expectEqualDeep(want.overridden, got.overridden);
+    }
 }
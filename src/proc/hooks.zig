@@ -0,0 +1,249 @@
+//! Lifecycle hook commands.
+//! `pre_start`, `post_start`, `pre_stop`, `post_stop`, and `on_kill` run outside the PTY with the process's cwd and env, a timeout, and their output written to the log. The same bounded runner executes plugins.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const threads = @import("../threads/root.zig");
+const env = @import("env.zig");
+
+const log = std.log.scoped(.process);
+
+const default_timeout_ms = 30_000;
+/// Output kept per stream and run; the rest is drained and dropped.
+const max_output_bytes = 64 * 1024;
+
+pub const Hook = enum {
+    pre_start,
+    post_start,
+    pre_stop,
+    post_stop,
+};
+
+pub fn command(proc_cfg: *const config.schema.ProcessConfig, hook: Hook) []const []const u8 {
+    return switch (hook) {
+        .pre_start => proc_cfg.pre_start.items,
+        .post_start => proc_cfg.post_start.items,
+        .pre_stop => proc_cfg.pre_stop.items,
+        .post_stop => proc_cfg.post_stop.items,
+    };
+}
+
+/// Runs `hook` if the process configures it, bounded by `hook_timeout_ms`.
+pub fn run(
+    allocator: std.mem.Allocator,
+    proc_cfg: *const config.schema.ProcessConfig,
+    metadata: env.Metadata,
+    hook: Hook,
+    cwd: []const u8,
+) !void {
+    const argv = command(proc_cfg, hook);
+    if (argv.len == 0) return;
+    return runCommand(allocator, argv, proc_cfg, metadata, cwd, timeoutMs(proc_cfg), @tagName(hook));
+}
+
+/// Runs one hook argv to completion. A non-zero exit returns
+/// `error.HookFailed`; a timeout kills the hook's whole process group and
+/// returns `error.HookTimedOut`. Output is logged either way.
+pub fn runCommand(
+    allocator: std.mem.Allocator,
+    argv: []const []const u8,
+    proc_cfg: *const config.schema.ProcessConfig,
+    metadata: env.Metadata,
+    cwd: []const u8,
+    timeout_ms: u64,
+    name: []const u8,
+) !void {
+    var env_map = try env.buildMap(allocator, proc_cfg, metadata);
+    defer env_map.deinit();
+
+    var output = Output.init(allocator);
+    defer output.deinit();
+    const result = runCapture(allocator, argv, .{ .cwd = cwd, .env_map = &env_map, .timeout_ms = timeout_ms }, &output);
+    logOutput(name, output.stdout.items);
+    logOutput(name, output.stderr.items);
+    return result;
+}
+
+/// Output of one run, each stream capped at `max_output_bytes`. Plugins answer
+/// on stdout, so the streams are kept apart.
+pub const Output = struct {
+    stdout: std.array_list.Managed(u8),
+    stderr: std.array_list.Managed(u8),
+
+    pub fn init(allocator: std.mem.Allocator) Output {
+        return .{
+            .stdout = std.array_list.Managed(u8).init(allocator),
+            .stderr = std.array_list.Managed(u8).init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Output) void {
+        self.stdout.deinit();
+        self.stderr.deinit();
+    }
+};
+
+pub const RunOptions = struct {
+    cwd: []const u8 = "",
+    env_map: ?*const std.process.EnvMap = null,
+    timeout_ms: u64 = default_timeout_ms,
+    /// Written to the command's stdin, which is then closed; null leaves stdin
+    /// unattached.
+    input: ?[]const u8 = null,
+};
+
+/// Runs `argv` to completion with the same failure and timeout rules as
+/// `runCommand`, collecting its output into `output` even when it fails.
+pub fn runCapture(
+    allocator: std.mem.Allocator,
+    argv: []const []const u8,
+    options: RunOptions,
+    output: *Output,
+) !void {
+    var child = std.process.Child.init(argv, allocator);
+    child.stdin_behavior = if (options.input != null) .Pipe else .Ignore;
+    child.stdout_behavior = .Pipe;
+    child.stderr_behavior = .Pipe;
+    // A group of its own lets a timeout kill whatever the hook spawned, which
+    // would otherwise hold the output pipes open.
+    child.pgid = 0;
+    if (options.cwd.len > 0) child.cwd = options.cwd;
+    child.env_map = options.env_map;
+
+    try child.spawn();
+    const child_pid = child.id;
+
+    if (options.input) |input| {
+        // Input is one short message, well under a pipe buffer, so this
+        // cannot block on a command that never reads it.
+        child.stdin.?.writeAll(input) catch {};
+        child.stdin.?.close();
+        child.stdin = null;
+    }
+
+    var run_state = RunState{ .child = &child, .output = output };
+    const wait_thread = threads.spawn(.{}, waitChild, .{&run_state}) catch |err| {
+        std.posix.kill(-child_pid, std.posix.SIG.KILL) catch {};
+        _ = child.wait() catch {};
+        return err;
+    };
+
+    const finished = waitForChild(&run_state.done, options.timeout_ms);
+    if (!finished) std.posix.kill(-child_pid, std.posix.SIG.KILL) catch {};
+    wait_thread.join();
+    if (!finished) return error.HookTimedOut;
+
+    const term = switch (run_state.result) {
+        .running, .failed => return error.HookFailed,
+        .exited => |term| term,
+    };
+    switch (term) {
+        .Exited => |code| if (code != 0) return error.HookFailed,
+        else => return error.HookFailed,
+    }
+}
+
+pub fn timeoutMs(proc_cfg: *const config.schema.ProcessConfig) u64 {
+    if (proc_cfg.hook_timeout_ms > 0) return @intCast(proc_cfg.hook_timeout_ms);
+    return default_timeout_ms;
+}
+
+pub fn logOutput(name: []const u8, output: []const u8) void {
+    var lines = std.mem.splitScalar(u8, output, '\n');
+    while (lines.next()) |line| {
+        const text = std.mem.trimRight(u8, line, "\r");
+        if (text.len > 0) log.info("{s}: {s}", .{ name, text });
+    }
+}
+
+const WaitResult = union(enum) {
+    running,
+    exited: std.process.Child.Term,
+    failed: anyerror,
+};
+
+const RunState = struct {
+    child: *std.process.Child,
+    output: *Output,
+    done: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    result: WaitResult = .running,
+};
+
+fn waitChild(state: *RunState) void {
+    drainOutput(state);
+    state.result = .{ .exited = state.child.wait() catch |err| {
+        state.result = .{ .failed = err };
+        state.done.store(true, .release);
+        return;
+    } };
+    state.done.store(true, .release);
+}
+
+/// Reads stdout and stderr together until both close so neither pipe can fill
+/// and stall the hook.
+fn drainOutput(state: *RunState) void {
+    var fds = [_]std.posix.pollfd{
+        .{ .fd = state.child.stdout.?.handle, .events = std.posix.POLL.IN, .revents = 0 },
+        .{ .fd = state.child.stderr.?.handle, .events = std.posix.POLL.IN, .revents = 0 },
+    };
+    var open: usize = fds.len;
+    var buffer: [4096]u8 = undefined;
+    while (open > 0) {
+        _ = std.posix.poll(&fds, -1) catch return;
+        for (&fds, 0..) |*pollfd, index| {
+            if (pollfd.fd < 0 or pollfd.revents == 0) continue;
+            const n = std.posix.read(pollfd.fd, &buffer) catch 0;
+            if (n == 0) {
+                // Negative fds are skipped by poll; wait() closes the pipes.
+                pollfd.fd = -1;
+                open -= 1;
+                continue;
+            }
+            const target = if (index == 0) &state.output.stdout else &state.output.stderr;
+            const room = max_output_bytes -| target.items.len;
+            target.appendSlice(buffer[0..@min(n, room)]) catch {};
+        }
+    }
+}
+
+fn waitForChild(done: *const std.atomic.Value(bool), timeout_ms: u64) bool {
+    const sleep_ms: u64 = 5;
+    var elapsed_ms: u64 = 0;
+
+    while (elapsed_ms < timeout_ms) {
+        if (done.load(.acquire)) return true;
+        const remaining_ms = timeout_ms - elapsed_ms;
+        const current_sleep_ms: u64 = @min(sleep_ms, remaining_ms);
+        std.Thread.sleep(current_sleep_ms * @as(u64, std.time.ns_per_ms));
+        elapsed_ms += current_sleep_ms;
+    }
+
+    return done.load(.acquire);
+}
+
+test "hooks report failures and kill background children on timeout" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+
+    try runCommand(std.testing.allocator, &.{ "sh", "-c", "echo ready; echo warn >&2" }, &proc_cfg, .{}, "", 5000, "pre_start");
+    try std.testing.expectError(
+        error.HookFailed,
+        runCommand(std.testing.allocator, &.{ "sh", "-c", "exit 3" }, &proc_cfg, .{}, "", 5000, "pre_start"),
+    );
+
+    // The backgrounded sleep keeps the pipes open unless the group is killed.
+    const started = std.time.milliTimestamp();
+    try std.testing.expectError(
+        error.HookTimedOut,
+        runCommand(std.testing.allocator, &.{ "sh", "-c", "sleep 5 & sleep 5" }, &proc_cfg, .{}, "", 50, "post_stop"),
+    );
+    try std.testing.expect(std.time.milliTimestamp() - started < 1000);
+}
+
+test "captured runs feed stdin and keep stdout apart from stderr" {
+    var output = Output.init(std.testing.allocator);
+    defer output.deinit();
+    try runCapture(std.testing.allocator, &.{ "sh", "-c", "read line; echo \"got $line\"; echo note >&2" }, .{ .input = "ping\n", .timeout_ms = 5000 }, &output);
+    try std.testing.expectEqualStrings("got ping\n", output.stdout.items);
+    try std.testing.expectEqualStrings("note\n", output.stderr.items);
+}
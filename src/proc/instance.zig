@@ -6,7 +6,11 @@ const std = @import("std");
 const config = @import("../config/root.zig");
 const domain = @import("../domain/root.zig");
 const ring = @import("../ring/root.zig");
+const bell = @import("bell.zig");
 const builder = @import("builder.zig");
+const pty_mod = @import("pty.zig");
+const redact = @import("redact.zig");
+const sink = @import("sink.zig");
 
 pub const ProcessHandle = union(enum) {
     pty: PtyHandle,
@@ -33,13 +37,32 @@ pub const ProcessHandle = union(enum) {
         };
     }
 
+    /// The child's stderr when `separate_stderr` keeps it off `outputFile`.
+    pub fn stderrFile(self: *ProcessHandle) ?std.fs.File {
+        return switch (self.*) {
+            .pty => |*pty| pty.stderr,
+            .pipe => |*pipe| pipe.stderr,
+        };
+    }
+
+    /// Waits for the child and returns its exit code, or 128 plus the signal
+    /// number when a signal ended it.
     pub fn wait(self: *ProcessHandle) !u32 {
         return switch (self.*) {
-            .pty => |pty| std.posix.waitpid(pty.pid, 0).status,
+            .pty => |pty| waitStatus(std.posix.waitpid(pty.pid, 0).status),
             .pipe => |*pipe| termStatus(try pipe.child.wait()),
         };
     }
 
+    /// Resizes the child terminal. Pipe-backed processes have no terminal, so
+    /// the request is accepted and ignored.
+    pub fn resize(self: *ProcessHandle, rows: u16, cols: u16) !void {
+        switch (self.*) {
+            .pty => |pty| try pty_mod.resize(pty.master, rows, cols),
+            .pipe => {},
+        }
+    }
+
     pub fn killForStartupCleanup(self: *ProcessHandle) void {
         switch (self.*) {
             .pty => |pty| std.posix.kill(pty.pid, std.posix.SIG.KILL) catch {},
@@ -49,10 +72,14 @@ pub const ProcessHandle = union(enum) {
 
     pub fn deinit(self: *ProcessHandle) void {
         switch (self.*) {
-            .pty => |pty| pty.master.close(),
+            .pty => |pty| {
+                pty.master.close();
+                if (pty.stderr) |file| file.close();
+            },
             .pipe => |pipe| {
                 pipe.stdin.close();
                 pipe.stdout.close();
+                if (pipe.stderr) |file| file.close();
             },
         }
     }
@@ -61,6 +88,7 @@ pub const ProcessHandle = union(enum) {
 pub const PtyHandle = struct {
     pid: std.posix.pid_t,
     master: std.fs.File,
+    stderr: ?std.fs.File = null,
 };
 
 pub const PipeHandle = struct {
@@ -68,16 +96,11 @@ pub const PipeHandle = struct {
     child: std.process.Child,
     stdin: std.fs.File,
     stdout: std.fs.File,
+    stderr: ?std.fs.File = null,
 };
 
-pub const Lifecycle = union(enum) {
-    running,
-    exited: u32,
-
-    pub fn isRunning(self: Lifecycle) bool {
-        return self == .running;
-    }
-};
+/// `Instance.exit_status` while the process runs; wait statuses never reach it.
+const still_running = std.math.maxInt(u32);
 
 pub const Instance = struct {
     allocator: std.mem.Allocator,
@@ -86,14 +109,36 @@ pub const Instance = struct {
     command_spec: builder.CommandSpec,
     handle: ProcessHandle,
     scrollback: *ring.RingBuffer,
+    sinks: sink.Sinks,
+    /// Used only by the output capture thread.
+    bells: bell.Detector = .{},
+    /// Used only by the output capture thread.
+    redactor: redact.Redactor,
+    /// With `separate_stderr`: the stderr-only copy of the output, and the
+    /// capture thread's redactor for it, since a line can be split across
+    /// reads on either stream.
+    stderr_scrollback: ?*ring.RingBuffer = null,
+    stderr_redactor: ?redact.Redactor = null,
     output_thread: ?std.Thread = null,
     wait_thread: ?std.Thread = null,
-    mutex: std.Thread.Mutex = .{},
-    lifecycle: Lifecycle = .running,
+    /// Written once by the exit watcher, so status reads never block on it.
+    exit_status: std.atomic.Value(u32) = std.atomic.Value(u32).init(still_running),
+    /// Held shared by anyone using the instance outside the controller mutex;
+    /// release takes it exclusively before freeing, so no reader outlives it.
+    lock: std.Thread.RwLock = .{},
+    /// Set by the one stop or cleanup that will release this instance.
+    releasing: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    /// Lifecycle status; only moved by `apply`.
+    status: std.atomic.Value(domain.process.ProcessStatus) = std.atomic.Value(domain.process.ProcessStatus).init(.starting),
+    /// Controller-owned; notified on output and exit.
+    changes: ?*domain.changes.Signal = null,
 
     pub fn deinit(self: *Instance) void {
         if (self.output_thread) |thread| thread.join();
         if (self.wait_thread) |thread| thread.join();
+        self.sinks.deinit();
+        self.redactor.deinit();
+        if (self.stderr_redactor) |*redactor| redactor.deinit();
         self.handle.deinit();
         self.command_spec.deinit(self.allocator);
     }
@@ -102,10 +147,24 @@ pub const Instance = struct {
         return self.handle.pid();
     }
 
-    pub fn isRunning(self: *Instance) bool {
-        self.mutex.lock();
-        defer self.mutex.unlock();
-        return self.lifecycle.isRunning();
+    pub fn isRunning(self: *const Instance) bool {
+        return self.exit_status.load(.acquire) == still_running;
+    }
+
+    /// Moves the status through `event`, leaving it unchanged when the event
+    /// is not valid from the current status.
+    pub fn apply(self: *Instance, event: domain.process.StatusEvent) error{InvalidTransition}!domain.process.ProcessStatus {
+        var current = self.status.load(.acquire);
+        while (true) {
+            const next = try current.next(event);
+            current = self.status.cmpxchgWeak(current, next, .acq_rel, .acquire) orelse return next;
+        }
+    }
+
+    /// Claims the release of this instance; false when another caller already
+    /// has it.
+    pub fn claimRelease(self: *Instance) bool {
+        return self.releasing.cmpxchgStrong(false, true, .acq_rel, .acquire) == null;
     }
 
     pub fn sendBytes(self: *Instance, bytes: []const u8) !void {
@@ -114,13 +173,31 @@ pub const Instance = struct {
         try file.writeAll(bytes);
     }
 
+    pub fn resize(self: *Instance, rows: u16, cols: u16) !void {
+        if (!self.isRunning()) return error.ProcessNotRunning;
+        try self.handle.resize(rows, cols);
+    }
+
     pub fn markExited(self: *Instance, term_status: u32) void {
-        self.mutex.lock();
-        defer self.mutex.unlock();
-        self.lifecycle = .{ .exited = term_status };
+        self.exit_status.store(@min(term_status, still_running - 1), .release);
+        // Every status that can have a live process accepts `exit`.
+        _ = self.apply(.exit) catch {};
+        if (self.changes) |changes| changes.notify();
+    }
+
+    /// The code the process exited with; null while it is still running.
+    pub fn exitCode(self: *const Instance) ?u32 {
+        const status = self.exit_status.load(.acquire);
+        return if (status == still_running) null else status;
     }
 };
 
+fn waitStatus(status: u32) u32 {
+    if (std.posix.W.IFEXITED(status)) return std.posix.W.EXITSTATUS(status);
+    if (std.posix.W.IFSIGNALED(status)) return 128 + std.posix.W.TERMSIG(status);
+    return status;
+}
+
 fn termStatus(term: std.process.Child.Term) u32 {
     return switch (term) {
         .Exited => |code| code,
@@ -1,94 +1,38 @@
 //! User-configured cleanup hook execution.
-//! Hooks are intentionally separate from normal child spawn so stop cleanup has its own timeout and environment behavior.
+//! Hooks are intentionally separate from normal child spawn so stop cleanup has its own timeout and environment behavior; execution is shared with the lifecycle hooks.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
 const env = @import("env.zig");
+const hooks = @import("hooks.zig");
 
 const default_timeout_ms = 30_000;
 
 /// Runs the configured cleanup hook, if any, with the same environment/cwd
-/// semantics as the managed process but with an independent timeout.
+/// semantics as the managed process but with an independent timeout. `cwd` is
+/// the managed process's resolved directory, not the raw config value.
 pub fn execute(
     allocator: std.mem.Allocator,
     proc_cfg: *const config.schema.ProcessConfig,
+    metadata: env.Metadata,
+    cwd: []const u8,
 ) !void {
-    return executeWithTimeoutMs(allocator, proc_cfg, default_timeout_ms);
+    return executeWithTimeoutMs(allocator, proc_cfg, metadata, cwd, default_timeout_ms);
 }
 
 pub fn executeWithTimeoutMs(
     allocator: std.mem.Allocator,
     proc_cfg: *const config.schema.ProcessConfig,
+    metadata: env.Metadata,
+    cwd: []const u8,
     timeout_ms: u64,
 ) !void {
     if (proc_cfg.on_kill.items.len == 0) return;
 
-    var env_map = try env.buildMap(allocator, proc_cfg);
-    defer env_map.deinit();
-
-    var child = std.process.Child.init(proc_cfg.on_kill.items, allocator);
-    child.stdin_behavior = .Ignore;
-    child.stdout_behavior = .Ignore;
-    child.stderr_behavior = .Ignore;
-    if (proc_cfg.cwd.len > 0) child.cwd = proc_cfg.cwd;
-    child.env_map = &env_map;
-
-    try child.spawn();
-    const child_pid = child.id;
-
-    var wait_state = WaitState{ .child = &child };
-    const wait_thread = try std.Thread.spawn(.{}, waitChild, .{&wait_state});
-    if (!waitForChild(&wait_state.done, timeout_ms)) {
-        std.posix.kill(child_pid, std.posix.SIG.KILL) catch {};
-        wait_thread.join();
-        return error.OnKillFailed;
-    }
-    wait_thread.join();
-
-    const term = switch (wait_state.result) {
-        .running, .failed => return error.OnKillFailed,
-        .exited => |term| term,
+    hooks.runCommand(allocator, proc_cfg.on_kill.items, proc_cfg, metadata, cwd, timeout_ms, "on_kill") catch |err| switch (err) {
+        error.HookFailed, error.HookTimedOut => return error.OnKillFailed,
+        else => return err,
     };
-    switch (term) {
-        .Exited => |code| if (code != 0) return error.OnKillFailed,
-        else => return error.OnKillFailed,
-    }
-}
-
-const WaitResult = union(enum) {
-    running,
-    exited: std.process.Child.Term,
-    failed: anyerror,
-};
-
-const WaitState = struct {
-    child: *std.process.Child,
-    done: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
-    result: WaitResult = .running,
-};
-
-fn waitChild(state: *WaitState) void {
-    state.result = .{ .exited = state.child.wait() catch |err| {
-        state.result = .{ .failed = err };
-        state.done.store(true, .release);
-        return;
-    } };
-    state.done.store(true, .release);
-}
-
-fn waitForChild(done: *const std.atomic.Value(bool), timeout_ms: u64) bool {
-    const sleep_ms: u64 = 5;
-    var elapsed_ms: u64 = 0;
-
-    while (elapsed_ms < timeout_ms) {
-        if (done.load(.acquire)) return true;
-        const remaining_ms = timeout_ms - elapsed_ms;
-        const current_sleep_ms: u64 = @min(sleep_ms, remaining_ms);
-        std.Thread.sleep(current_sleep_ms * @as(u64, std.time.ns_per_ms));
-        elapsed_ms += current_sleep_ms;
-    }
-
-    return done.load(.acquire);
 }
 
 test "on kill hook times out and kills long running hook" {
@@ -106,7 +50,7 @@ test "on kill hook times out and kills long running hook" {
     try config.schema.appendOwned(std.testing.allocator, &proc_cfg.on_kill, "sleep 5; printf late > on_kill.txt");
 
     const started = std.time.milliTimestamp();
-    try std.testing.expectError(error.OnKillFailed, executeWithTimeoutMs(std.testing.allocator, &proc_cfg, 50));
+    try std.testing.expectError(error.OnKillFailed, executeWithTimeoutMs(std.testing.allocator, &proc_cfg, .{}, cwd, 50));
     const elapsed = std.time.milliTimestamp() - started;
 
     try std.testing.expect(elapsed < 1000);
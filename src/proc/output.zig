@@ -1,23 +1,145 @@
 //! Process output capture thread.
-//! Output is copied from PTY/pipe handles into ring buffers without blocking process lifecycle orchestration.
+//! Output is copied from PTY/pipe handles into ring buffers, with `redact_patterns` applied, without blocking process lifecycle orchestration.
+//! With `separate_stderr` the thread also reads the child's stderr pipe; that output is colored in the scrollback and kept on its own for `logs --stderr`.
 
 const std = @import("std");
 const instance_mod = @import("instance.zig");
+const redact = @import("redact.zig");
 
-const log = std.log.scoped(.proc_output);
+const log = std.log.scoped(.process);
 
-/// Copies child output into the process scrollback until the handle closes.
+/// Wraps stderr output in the scrollback, so it reads as red in any viewer.
+const stderr_color_on = "\x1b[31m";
+const stderr_color_off = "\x1b[39m";
+
+const Stream = enum { output, stderr };
+
+/// Copies child output into the process scrollback and any configured output
+/// sinks until the handle closes, redacting it on the way.
 /// Errors end capture instead of surfacing through the controller thread.
 pub fn capture(instance: *instance_mod.Instance) void {
-    var file = instance.handle.outputFile();
+    var colored = std.array_list.Managed(u8).init(instance.allocator);
+    defer colored.deinit();
 
+    const output_file = instance.handle.outputFile();
+    var stderr_file = instance.handle.stderrFile();
     var buf: [4096]u8 = undefined;
     while (true) {
-        const n = file.read(&buf) catch |err| {
-            log.debug("process output capture stopped after read error: {s}", .{@errorName(err)});
-            return;
+        var fds = [_]std.posix.pollfd{
+            .{ .fd = output_file.handle, .events = std.posix.POLL.IN, .revents = 0 },
+            .{ .fd = if (stderr_file) |file| file.handle else -1, .events = std.posix.POLL.IN, .revents = 0 },
         };
-        if (n == 0) return;
-        _ = instance.scrollback.write(buf[0..n]);
+        const ready = std.posix.poll(&fds, if (hasPending(instance)) redact.flush_after_ms else -1) catch 1;
+        if (ready == 0) {
+            publish(instance, .output, instance.redactor.flush() catch "", &colored);
+            if (instance.stderr_redactor) |*redactor| publish(instance, .stderr, redactor.flush() catch "", &colored);
+            continue;
+        }
+
+        if (fds[1].revents != 0) {
+            if (!copy(instance, .stderr, stderr_file.?, &buf, &colored)) stderr_file = null;
+        }
+        // A ready stderr alone does not mean the terminal has output, and a
+        // read there would block.
+        if (fds[0].revents == 0 and fds[1].revents != 0) continue;
+        if (!copy(instance, .output, output_file, &buf, &colored)) {
+            // Whatever the child wrote to stderr before exiting is still
+            // worth keeping; a grandchild holding the pipe open is not
+            // waited for.
+            if (stderr_file) |file| {
+                while (readable(file, 0) and copy(instance, .stderr, file, &buf, &colored)) {}
+                if (instance.stderr_redactor) |*redactor| publish(instance, .stderr, redactor.flush() catch "", &colored);
+            }
+            return;
+        }
+    }
+}
+
+/// Reads once from `file` and publishes what it got. Returns false, after
+/// flushing the stream's held-back line, once the stream has closed.
+fn copy(
+    instance: *instance_mod.Instance,
+    stream: Stream,
+    file: std.fs.File,
+    buf: []u8,
+    colored: *std.array_list.Managed(u8),
+) bool {
+    const redactor = redactorFor(instance, stream);
+    const n = file.read(buf) catch |err| blk: {
+        log.debug("process {s} capture stopped after read error: {s}", .{ @tagName(stream), @errorName(err) });
+        break :blk 0;
+    };
+    if (n == 0) {
+        publish(instance, stream, redactor.flush() catch "", colored);
+        return false;
+    }
+    // Counted before the write so whoever it wakes sees the bells too.
+    const rung = instance.bells.count(buf[0..n]);
+    if (rung > 0) instance.scrollback.addBells(rung);
+    // Output that cannot be redacted is dropped rather than kept as is.
+    const bytes = redactor.feed(buf[0..n]) catch |err| blk: {
+        log.warn("dropping process output that could not be redacted: {s}", .{@errorName(err)});
+        break :blk "";
+    };
+    publish(instance, stream, bytes, colored);
+    return true;
+}
+
+fn publish(
+    instance: *instance_mod.Instance,
+    stream: Stream,
+    bytes: []const u8,
+    colored: *std.array_list.Managed(u8),
+) void {
+    if (bytes.len > 0) {
+        switch (stream) {
+            .output => _ = instance.scrollback.write(bytes),
+            .stderr => {
+                if (instance.stderr_scrollback) |scrollback| _ = scrollback.write(bytes);
+                colored.clearRetainingCapacity();
+                if (wrapStderr(colored, bytes)) {
+                    _ = instance.scrollback.write(colored.items);
+                } else |_| {
+                    _ = instance.scrollback.write(bytes);
+                }
+            },
+        }
+        instance.sinks.write(bytes);
     }
+    if (instance.changes) |changes| changes.notify();
+}
+
+/// Appends `bytes` to `out` between the stderr color codes.
+fn wrapStderr(out: *std.array_list.Managed(u8), bytes: []const u8) !void {
+    try out.ensureUnusedCapacity(stderr_color_on.len + bytes.len + stderr_color_off.len);
+    out.appendSliceAssumeCapacity(stderr_color_on);
+    out.appendSliceAssumeCapacity(bytes);
+    out.appendSliceAssumeCapacity(stderr_color_off);
+}
+
+fn redactorFor(instance: *instance_mod.Instance, stream: Stream) *redact.Redactor {
+    return switch (stream) {
+        .output => &instance.redactor,
+        .stderr => if (instance.stderr_redactor) |*redactor| redactor else &instance.redactor,
+    };
+}
+
+fn hasPending(instance: *instance_mod.Instance) bool {
+    if (instance.redactor.hasPending()) return true;
+    if (instance.stderr_redactor) |*redactor| return redactor.hasPending();
+    return false;
+}
+
+/// Whether `file` has output (or has closed) within `timeout_ms`.
+fn readable(file: std.fs.File, timeout_ms: i32) bool {
+    var fds = [_]std.posix.pollfd{.{ .fd = file.handle, .events = std.posix.POLL.IN, .revents = 0 }};
+    const ready = std.posix.poll(&fds, timeout_ms) catch return true;
+    return ready > 0;
+}
+
+test "stderr output is wrapped in color codes" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+    try wrapStderr(&out, "boom\n");
+    try std.testing.expectEqualStrings("\x1b[31mboom\n\x1b[39m", out.items);
 }
@@ -2,6 +2,7 @@
 //! PTY setup is isolated because it is platform-sensitive and because proctmux intentionally gives managed processes a real terminal interface.
 
 const std = @import("std");
+const env = @import("env.zig");
 
 const default_rows: u16 = 24;
 const default_cols: u16 = 80;
@@ -19,7 +20,8 @@ pub const Spawned = struct {
 };
 
 /// Spawns a child attached to a PTY so managed commands behave as if they were
-/// running in a real terminal instead of a pipe.
+/// running in a real terminal instead of a pipe. A `stderr` descriptor
+/// replaces the terminal as the child's stderr; the caller still owns it.
 pub fn spawn(
     allocator: std.mem.Allocator,
     argv: []const []const u8,
@@ -27,6 +29,7 @@ pub fn spawn(
     cwd: []const u8,
     rows: u16,
     cols: u16,
+    stderr: ?std.posix.fd_t,
 ) !Spawned {
     if (argv.len == 0) return error.InvalidProcessConfig;
 
@@ -56,6 +59,7 @@ pub fn spawn(
 
     if (pid == 0) {
         configureChildTerminal() catch {};
+        if (stderr) |fd| std.posix.dup2(fd, std.posix.STDERR_FILENO) catch std.process.exit(127);
         if (cwd_z) |path| std.posix.chdirZ(path.ptr) catch std.process.exit(127);
         std.posix.execveZ(argv_z.ptrs[0].?, argv_z.ptrs.ptr, envp.ptr) catch {};
         std.process.exit(127);
@@ -67,6 +71,21 @@ pub fn spawn(
     };
 }
 
+/// Updates the PTY window size. The kernel only signals SIGWINCH to the child
+/// when the size actually changes, so repeated calls with one size are cheap.
+pub fn resize(master: std.fs.File, rows: u16, cols: u16) !void {
+    if (rows == 0 or cols == 0) return error.InvalidTerminalSize;
+
+    const size: std.posix.winsize = .{
+        .row = rows,
+        .col = cols,
+        .xpixel = 0,
+        .ypixel = 0,
+    };
+    const rc = std.posix.system.ioctl(master.handle, std.posix.T.IOCSWINSZ, @intFromPtr(&size));
+    if (std.posix.errno(rc) != .SUCCESS) return error.PtyResizeFailed;
+}
+
 fn configureChildTerminal() !void {
     // Shell/readline programs depend on canonical-mode erase; normalize it to
     // the DEL byte sent by xterm-compatible Backspace keys.
@@ -113,7 +132,7 @@ fn resolveExecutable(
 ) !?[]const u8 {
     if (std.mem.indexOfScalar(u8, executable, '/') != null) return null;
 
-    const path_value = env_map.get("PATH") orelse "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin";
+    const path_value = env_map.get("PATH") orelse env.default_path;
     var path_it = std.mem.splitScalar(u8, path_value, ':');
     while (path_it.next()) |dir| {
         if (dir.len == 0) continue;
@@ -0,0 +1,145 @@
+//! Credential redaction in process output.
+//! The capture thread rewrites spans matching `redact_patterns` to `[REDACTED]` before output reaches the scrollback or any output sink, so shared logs and screenshots do not carry tokens. Matching is per line, so an unfinished last line is held back briefly in case the rest of a token is still on its way.
+
+const std = @import("std");
+const domain = @import("../domain/root.zig");
+
+const log = std.log.scoped(.process);
+
+pub const replacement = "[REDACTED]";
+/// A held-back line is released after this long without more output, so
+/// prompts still appear.
+pub const flush_after_ms: i32 = 50;
+/// A line still unfinished past this many bytes is released as it stands;
+/// a token straddling that cut is not redacted.
+const max_pending = 4096;
+
+/// Compiled `redact_patterns` plus the unfinished line carried between reads.
+/// Owned by the capture thread.
+pub const Redactor = struct {
+    allocator: std.mem.Allocator,
+    patterns: domain.patterns.PatternSet,
+    pending: std.array_list.Managed(u8),
+    out: std.array_list.Managed(u8),
+    spans: std.array_list.Managed(domain.regex.Span),
+
+    /// Patterns are borrowed. A `re:` pattern that does not compile is
+    /// skipped with a warning.
+    pub fn init(allocator: std.mem.Allocator, sources: []const []const u8) !Redactor {
+        var invalid = std.array_list.Managed([]const u8).init(allocator);
+        defer invalid.deinit();
+        const patterns = try domain.patterns.PatternSet.init(allocator, sources, &invalid);
+        for (invalid.items) |source| log.warn("skipping redact pattern '{s}': invalid regex", .{source});
+        return .{
+            .allocator = allocator,
+            .patterns = patterns,
+            .pending = std.array_list.Managed(u8).init(allocator),
+            .out = std.array_list.Managed(u8).init(allocator),
+            .spans = std.array_list.Managed(domain.regex.Span).init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Redactor) void {
+        self.patterns.deinit();
+        self.pending.deinit();
+        self.out.deinit();
+        self.spans.deinit();
+    }
+
+    /// Whether an unfinished line is waiting for more output or `flush`.
+    pub fn hasPending(self: *const Redactor) bool {
+        return self.pending.items.len > 0;
+    }
+
+    /// Returns the complete lines of the held-back line plus `bytes`,
+    /// redacted, and holds back what follows the last newline. Without
+    /// patterns `bytes` passes straight through. The result is valid until
+    /// the next call.
+    pub fn feed(self: *Redactor, bytes: []const u8) ![]const u8 {
+        if (self.patterns.isEmpty()) return bytes;
+        try self.pending.appendSlice(bytes);
+        const cut = if (std.mem.lastIndexOfScalar(u8, self.pending.items, '\n')) |index|
+            index + 1
+        else if (self.pending.items.len > max_pending)
+            self.pending.items.len
+        else
+            return "";
+        return self.release(cut);
+    }
+
+    /// Releases the held-back line, redacted.
+    pub fn flush(self: *Redactor) ![]const u8 {
+        return self.release(self.pending.items.len);
+    }
+
+    fn release(self: *Redactor, cut: usize) ![]const u8 {
+        self.out.clearRetainingCapacity();
+        var lines = std.mem.splitScalar(u8, self.pending.items[0..cut], '\n');
+        var first = true;
+        while (lines.next()) |line| {
+            if (!first) try self.out.append('\n');
+            first = false;
+            try self.redactLine(line);
+        }
+        const rest = self.pending.items.len - cut;
+        std.mem.copyForwards(u8, self.pending.items[0..rest], self.pending.items[cut..]);
+        self.pending.shrinkRetainingCapacity(rest);
+        return self.out.items;
+    }
+
+    /// Appends `line` to `out` with every matched span, overlaps merged,
+    /// replaced.
+    fn redactLine(self: *Redactor, line: []const u8) !void {
+        self.spans.clearRetainingCapacity();
+        for (self.patterns.items) |*pattern| {
+            var from: usize = 0;
+            while (from < line.len) {
+                const span = pattern.find(line, from) orelse break;
+                try self.spans.append(span);
+                from = span.end;
+            }
+        }
+        std.mem.sort(domain.regex.Span, self.spans.items, {}, startsBefore);
+
+        var written: usize = 0;
+        for (self.spans.items) |span| {
+            if (span.end <= written) continue;
+            if (span.start >= written) {
+                try self.out.appendSlice(line[written..span.start]);
+                try self.out.appendSlice(replacement);
+            }
+            written = span.end;
+        }
+        try self.out.appendSlice(line[written..]);
+    }
+};
+
+fn startsBefore(_: void, a: domain.regex.Span, b: domain.regex.Span) bool {
+    return a.start < b.start;
+}
+
+test "redactor replaces substring and regex matches and merges overlaps" {
+    var redactor = try Redactor.init(std.testing.allocator, &.{ "hunter2", "re:ghp_[A-Za-z0-9]+", "re:[Bb]earer [A-Za-z0-9._-]+", "re:(" });
+    defer redactor.deinit();
+
+    try std.testing.expectEqualStrings(
+        "pw=[REDACTED] token [REDACTED]\nAuthorization: [REDACTED]\n",
+        try redactor.feed("pw=HUNTER2 token ghp_abc123\nAuthorization: Bearer ghp_x1.y\n"),
+    );
+    try std.testing.expect(!redactor.hasPending());
+}
+
+test "redactor holds an unfinished line until it ends or is flushed" {
+    var redactor = try Redactor.init(std.testing.allocator, &.{"re:ghp_[a-z0-9]+"});
+    defer redactor.deinit();
+
+    try std.testing.expectEqualStrings("one\n", try redactor.feed("one\ntoken ghp_ab"));
+    try std.testing.expect(redactor.hasPending());
+    try std.testing.expectEqualStrings("token [REDACTED] done\n", try redactor.feed("c123 done\nprompt> "));
+    try std.testing.expectEqualStrings("prompt> ", try redactor.flush());
+    try std.testing.expect(!redactor.hasPending());
+
+    var passthrough = try Redactor.init(std.testing.allocator, &.{});
+    defer passthrough.deinit();
+    try std.testing.expectEqualStrings("ghp_abc", try passthrough.feed("ghp_abc"));
+}
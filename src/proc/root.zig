@@ -5,21 +5,33 @@ const std = @import("std");
 const config = @import("../config/root.zig");
 const domain = @import("../domain/root.zig");
 
+pub const bell = @import("bell.zig");
 pub const builder = @import("builder.zig");
 pub const controller = @import("controller.zig");
+pub const docker = @import("docker.zig");
 pub const env = @import("env.zig");
+pub const hooks = @import("hooks.zig");
 pub const instance = @import("instance.zig");
 pub const on_kill = @import("on_kill.zig");
 pub const output = @import("output.zig");
+pub const redact = @import("redact.zig");
+pub const secrets = @import("secrets.zig");
+pub const sink = @import("sink.zig");
 pub const spawn = @import("spawn.zig");
 
 test {
+    _ = bell;
     _ = builder;
     _ = controller;
+    _ = docker;
     _ = env;
+    _ = hooks;
     _ = instance;
     _ = on_kill;
     _ = output;
+    _ = redact;
+    _ = secrets;
+    _ = sink;
     _ = spawn;
 }
 
@@ -56,6 +68,59 @@ test "command builder uses custom shell command" {
     try std.testing.expectEqualStrings("echo custom", spec.argv[2]);
 }
 
+test "command builder prefers per-process shell command and startup flags" {
+    var global = config.schema.Config.empty(std.testing.allocator);
+    defer global.deinit();
+    try config.schema.appendOwned(std.testing.allocator, &global.shell_cmd, "/bin/bash");
+    try config.schema.appendOwned(std.testing.allocator, &global.shell_cmd, "-c");
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "echo zsh";
+    proc_cfg.login_shell = true;
+    proc_cfg.interactive_shell = true;
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.shell_cmd, "zsh");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.shell_cmd, "-c");
+
+    const spec = try builder.buildCommand(std.testing.allocator, &proc_cfg, &global) orelse return error.ExpectedCommand;
+    defer spec.deinit(std.testing.allocator);
+
+    try std.testing.expectEqual(@as(usize, 5), spec.argv.len);
+    try std.testing.expectEqualStrings("zsh", spec.argv[0]);
+    try std.testing.expectEqualStrings("-l", spec.argv[1]);
+    try std.testing.expectEqualStrings("-i", spec.argv[2]);
+    try std.testing.expectEqualStrings("-c", spec.argv[3]);
+    try std.testing.expectEqualStrings("echo zsh", spec.argv[4]);
+}
+
+test "command builder wraps commands with the configured env loader" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.cwd = "/srv/app";
+    proc_cfg.env_loader = "direnv";
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, "npm");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, "start");
+
+    const direnv = try builder.buildCommand(std.testing.allocator, &proc_cfg, null) orelse return error.ExpectedCommand;
+    defer direnv.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(usize, 5), direnv.argv.len);
+    try std.testing.expectEqualStrings("direnv", direnv.argv[0]);
+    try std.testing.expectEqualStrings("exec", direnv.argv[1]);
+    try std.testing.expectEqualStrings(".", direnv.argv[2]);
+    try std.testing.expectEqualStrings("/srv/app", direnv.cwd);
+    try std.testing.expectEqualStrings("npm", direnv.argv[3]);
+
+    proc_cfg.env_loader = "custom";
+    try std.testing.expectError(error.InvalidEnvLoader, builder.buildCommand(std.testing.allocator, &proc_cfg, null));
+
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.env_loader_cmd, "with-env");
+    const custom = try builder.buildCommand(std.testing.allocator, &proc_cfg, null) orelse return error.ExpectedCommand;
+    defer custom.deinit(std.testing.allocator);
+    try std.testing.expectEqual(@as(usize, 3), custom.argv.len);
+    try std.testing.expectEqualStrings("with-env", custom.argv[0]);
+    try std.testing.expectEqualStrings("start", custom.argv[2]);
+}
+
 test "command builder prefers shell over cmd array" {
     var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
     defer proc_cfg.deinit(std.testing.allocator);
@@ -89,7 +154,41 @@ test "command builder uses argv command and returns null for empty command" {
     try std.testing.expectEqualStrings("/tmp", spec.argv[2]);
 }
 
-test "environment builder appends add_path and custom env like legacy behavior" {
+test "command builder resolves cwd placeholders from the config location" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("repo/.git");
+    try tmp.dir.makePath("repo/tools/dev");
+
+    const repo = try tmp.dir.realpathAlloc(std.testing.allocator, "repo");
+    defer std.testing.allocator.free(repo);
+    const config_path = try std.fs.path.join(std.testing.allocator, &.{ repo, "tools", "dev", "proctmux.yaml" });
+    defer std.testing.allocator.free(config_path);
+
+    var global = config.schema.Config.empty(std.testing.allocator);
+    defer global.deinit();
+    global.file_path = config_path;
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "make";
+    proc_cfg.cwd = "{git_root}/services/api";
+
+    const spec = try builder.buildCommand(std.testing.allocator, &proc_cfg, &global) orelse return error.ExpectedCommand;
+    defer spec.deinit(std.testing.allocator);
+
+    const expected_api = try std.fs.path.join(std.testing.allocator, &.{ repo, "services", "api" });
+    defer std.testing.allocator.free(expected_api);
+    try std.testing.expectEqualStrings(expected_api, spec.cwd);
+
+    const scripts = try builder.resolveCwd(std.testing.allocator, "{config_dir}/scripts", &global);
+    defer std.testing.allocator.free(scripts);
+    const expected_scripts = try std.fs.path.join(std.testing.allocator, &.{ repo, "tools", "dev", "scripts" });
+    defer std.testing.allocator.free(expected_scripts);
+    try std.testing.expectEqualStrings(expected_scripts, scripts);
+}
+
+test "environment builder gives each name one value with env overriding the base" {
     var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
     defer proc_cfg.deinit(std.testing.allocator);
     try config.schema.appendOwned(std.testing.allocator, &proc_cfg.add_path, "/custom/bin");
@@ -105,12 +204,31 @@ test "environment builder appends add_path and custom env like legacy behavior"
     );
     defer builder.deinitEnvironment(std.testing.allocator, built_env);
 
-    try std.testing.expect(contains(built_env, "HOME=/home/nick"));
-    try std.testing.expect(contains(built_env, "KEEP=yes"));
-    try std.testing.expect(contains(built_env, "PATH=/usr/bin:/custom/bin:/second/bin"));
-    try std.testing.expect(contains(built_env, "CUSTOM=value"));
-    try std.testing.expect(contains(built_env, "HOME=/custom/home"));
-    try std.testing.expectEqual(@as(usize, 1), countPrefix(built_env, "PATH="));
+    try std.testing.expectEqual(@as(usize, 4), built_env.len);
+    try std.testing.expectEqualStrings("CUSTOM=value", built_env[0]);
+    try std.testing.expectEqualStrings("HOME=/custom/home", built_env[1]);
+    try std.testing.expectEqualStrings("KEEP=yes", built_env[2]);
+    try std.testing.expectEqualStrings("PATH=/usr/bin:/custom/bin:/second/bin", built_env[3]);
+}
+
+test "environment builder keeps the first base value and honors env_clear" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.add_path, "/custom/bin");
+
+    const base = [_][]const u8{ "HOME=/home/nick", "HOME=/home/other", "KEEP=yes" };
+    const built_env = try builder.buildEnvironmentFromBase(std.testing.allocator, &base, "/bin", &proc_cfg);
+    defer builder.deinitEnvironment(std.testing.allocator, built_env);
+    try std.testing.expectEqual(@as(usize, 3), built_env.len);
+    try std.testing.expectEqualStrings("HOME=/home/nick", built_env[0]);
+    try std.testing.expectEqualStrings("PATH=/bin:/custom/bin", built_env[2]);
+
+    proc_cfg.env_clear = true;
+    try config.schema.putOwnedString(std.testing.allocator, &proc_cfg.env, "PATH", "/env/bin");
+    const cleared = try builder.buildEnvironmentFromBase(std.testing.allocator, &base, "/bin", &proc_cfg);
+    defer builder.deinitEnvironment(std.testing.allocator, cleared);
+    try std.testing.expectEqual(@as(usize, 1), cleared.len);
+    try std.testing.expectEqualStrings("PATH=/env/bin:/custom/bin", cleared[0]);
 }
 
 test "controller starts process captures output and stops it" {
@@ -185,6 +303,78 @@ test "controller runs on kill hook after user stop" {
     try std.testing.expectEqualStrings("hook", contents);
 }
 
+test "controller runs lifecycle hooks around start and stop" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    const cwd = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(cwd);
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.cwd = cwd;
+    proc_cfg.shell = "sleep 5";
+    proc_cfg.stop_timeout_ms = 500;
+    for ([_]*config.schema.StringList{ &proc_cfg.pre_start, &proc_cfg.post_start, &proc_cfg.pre_stop, &proc_cfg.post_stop }, [_][]const u8{
+        "printf 'pre_start ' >> hooks.txt",
+        "printf 'post_start ' >> hooks.txt",
+        "printf 'pre_stop ' >> hooks.txt",
+        "printf post_stop >> hooks.txt",
+    }) |list, script| {
+        try config.schema.appendOwned(std.testing.allocator, list, "sh");
+        try config.schema.appendOwned(std.testing.allocator, list, "-c");
+        try config.schema.appendOwned(std.testing.allocator, list, script);
+    }
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(11);
+    _ = try ctl.startProcess(id, &proc_cfg);
+    try ctl.stopProcess(id);
+
+    const contents = try tmp.dir.readFileAlloc(std.testing.allocator, "hooks.txt", 1024);
+    defer std.testing.allocator.free(contents);
+    try std.testing.expectEqualStrings("pre_start post_start pre_stop post_stop", contents);
+}
+
+test "controller exports proctmux metadata to the process" {
+    const test_config = @import("../test_support/config.zig");
+
+    var cfg = config.schema.Config.empty(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.file_path = "/srv/app/proctmux.yaml";
+    try test_config.putShellProcessWithStopTimeout(
+        &cfg,
+        "api",
+        "echo \"$PROCTMUX_LABEL|$PROCTMUX_PROC_ID|$PROCTMUX_SOCKET|$PROCTMUX_CONFIG\"; sleep 5",
+        500,
+    );
+
+    var ctl = controller.Controller.init(std.testing.allocator, &cfg);
+    defer ctl.deinit();
+    ctl.socket_path = "/tmp/proctmux-test.socket";
+
+    const id = domain.process.ProcessId.fromInt(4);
+    _ = try ctl.startProcess(id, cfg.procs.getPtr("api").?);
+    try waitForScrollbackContains(&ctl, id, "api|4|/tmp/proctmux-test.socket|/srv/app/proctmux.yaml");
+    try ctl.stopProcess(id);
+}
+
+test "controller does not start a process whose pre start hook fails" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "sleep 5";
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.pre_start, "false");
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(12);
+    try std.testing.expectError(error.PreStartHookFailed, ctl.startProcess(id, &proc_cfg));
+    try std.testing.expect(!ctl.isRunning(id));
+}
+
 test "controller cleanup skips on kill hook after natural exit" {
     var tmp = std.testing.tmpDir(.{});
     defer tmp.cleanup();
@@ -218,6 +408,57 @@ test "controller cleanup skips on kill hook after natural exit" {
     try std.testing.expect(std.mem.indexOf(u8, retained, "done") != null);
 }
 
+test "controller reports the exit code of a finished process" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "exit 3";
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(13);
+    _ = try ctl.startProcess(id, &proc_cfg);
+    try waitForControllerStopped(&ctl, id);
+    try std.testing.expectEqual(@as(?u32, 3), ctl.exitCode(id));
+
+    try ctl.cleanupProcess(id);
+    try std.testing.expectEqual(@as(?u32, null), ctl.exitCode(id));
+}
+
+test "controller drives process status through exit restart and stop" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "exit 3";
+    proc_cfg.stop_timeout_ms = 500;
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const Status = domain.process.ProcessStatus;
+    const id = domain.process.ProcessId.fromInt(17);
+    try std.testing.expectEqual(Status.halted, ctl.getProcessStatus(id));
+
+    _ = try ctl.startProcess(id, &proc_cfg);
+    try waitForControllerStatus(&ctl, id, .exited);
+    try std.testing.expectEqual(@as(?u32, 3), ctl.exitCode(id));
+
+    // Restarting an exited run releases it and starts the new command.
+    proc_cfg.shell = "sleep 5";
+    const restarted = try ctl.restartProcess(id, &proc_cfg, 0);
+    try std.testing.expectEqual(Status.running, restarted.status.load(.acquire));
+    try std.testing.expectEqual(Status.running, ctl.getProcessStatus(id));
+    try std.testing.expectEqual(@as(?u32, null), ctl.exitCode(id));
+    const first_pid = ctl.getPID(id);
+
+    _ = try ctl.restartProcess(id, &proc_cfg, 0);
+    try std.testing.expectEqual(Status.running, ctl.getProcessStatus(id));
+    try std.testing.expect(ctl.getPID(id) != first_pid);
+    try std.testing.expectEqual(@as(u32, 3), ctl.processStats(id).starts);
+
+    try ctl.stopProcess(id);
+    try std.testing.expectEqual(Status.halted, ctl.getProcessStatus(id));
+}
+
 test "controller deinit skips on kill hook after natural exit" {
     var tmp = std.testing.tmpDir(.{});
     defer tmp.cleanup();
@@ -306,6 +547,52 @@ test "controller starts process in pty and forwards input" {
     try ctl.stopProcess(id);
 }
 
+test "controller keeps separate stderr apart and colors it in the scrollback" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "printf out; printf err >&2; sleep 5";
+    proc_cfg.stop_timeout_ms = 500;
+    proc_cfg.separate_stderr = true;
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(6);
+    _ = try ctl.startProcess(id, &proc_cfg);
+    try waitForScrollbackContains(&ctl, id, "\x1b[31merr\x1b[39m");
+    try waitForScrollbackContains(&ctl, id, "out");
+    try ctl.stopProcess(id);
+
+    const stderr_buffer = try ctl.stderrBuffer(id);
+    const stderr_bytes = try stderr_buffer.bytes(std.testing.allocator);
+    defer std.testing.allocator.free(stderr_bytes);
+    try std.testing.expectEqualStrings("err", stderr_bytes);
+}
+
+test "controller resizes pty process terminal" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.stop_timeout_ms = 500;
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, "sh");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, "-c");
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.cmd, "stty size; IFS= read line; printf 'resized:'; stty size");
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(10);
+    try std.testing.expectError(error.ProcessNotFound, ctl.resizeProcess(id, 40, 120));
+
+    const proc_instance = try ctl.startProcess(id, &proc_cfg);
+    try waitForScrollbackContains(&ctl, id, "24 80");
+
+    try ctl.resizeProcess(id, 40, 120);
+    try proc_instance.sendBytes("\n");
+    try waitForScrollbackContains(&ctl, id, "resized:40 120");
+
+    try ctl.stopProcess(id);
+}
+
 test "controller exposes pid and managed process ids" {
     var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
     defer proc_cfg.deinit(std.testing.allocator);
@@ -358,19 +645,121 @@ test "controller adapts to domain process view controller" {
     try std.testing.expectEqual(@as(i32, -1), halted_view.pid);
 }
 
-fn contains(environment: []const []const u8, needle: []const u8) bool {
-    for (environment) |entry| {
-        if (std.mem.eql(u8, entry, needle)) return true;
+test "controller lets exactly one concurrent stop release a process" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "sleep 5";
+    proc_cfg.stop_timeout_ms = 500;
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const id = domain.process.ProcessId.fromInt(8);
+    _ = try ctl.startProcess(id, &proc_cfg);
+
+    const Stopper = struct {
+        ctl: *controller.Controller,
+        id: domain.process.ProcessId,
+        result: ?anyerror = null,
+
+        fn run(self: *@This()) void {
+            self.ctl.stopProcess(self.id) catch |err| {
+                self.result = err;
+            };
+        }
+    };
+    var stoppers = [_]Stopper{ .{ .ctl = &ctl, .id = id }, .{ .ctl = &ctl, .id = id } };
+    var stop_threads: [stoppers.len]std.Thread = undefined;
+    for (&stoppers, &stop_threads) |*stopper, *thread| thread.* = try std.Thread.spawn(.{}, Stopper.run, .{stopper});
+    for (stop_threads) |thread| thread.join();
+
+    var succeeded: usize = 0;
+    for (stoppers) |stopper| {
+        const err = stopper.result orelse {
+            succeeded += 1;
+            continue;
+        };
+        // The loser either saw the claim or arrived after the release.
+        try std.testing.expect(err == error.ProcessStopping or err == error.ProcessNotFound);
     }
-    return false;
+    try std.testing.expectEqual(@as(usize, 1), succeeded);
+    try std.testing.expect(!ctl.isRunning(id));
 }
 
-fn countPrefix(environment: []const []const u8, prefix: []const u8) usize {
-    var count: usize = 0;
-    for (environment) |entry| {
-        if (std.mem.startsWith(u8, entry, prefix)) count += 1;
+test "controller survives concurrent start stop and list" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    proc_cfg.shell = "sleep 5";
+    proc_cfg.stop_timeout_ms = 500;
+
+    var ctl = controller.Controller.init(std.testing.allocator, null);
+    defer ctl.deinit();
+
+    const ids = [_]domain.process.ProcessId{ domain.process.ProcessId.fromInt(9), domain.process.ProcessId.fromInt(10) };
+    var done = std.atomic.Value(bool).init(false);
+
+    const Churn = struct {
+        ctl: *controller.Controller,
+        proc_cfg: *const config.schema.ProcessConfig,
+        id: domain.process.ProcessId,
+        result: ?anyerror = null,
+
+        fn run(self: *@This()) void {
+            var round: usize = 0;
+            while (round < 10) : (round += 1) {
+                self.ctl.cleanupProcess(self.id) catch |err| switch (err) {
+                    error.ProcessStillRunning => {},
+                    else => return self.fail(err),
+                };
+                _ = self.ctl.startProcess(self.id, self.proc_cfg) catch |err| switch (err) {
+                    error.ProcessAlreadyExists => {},
+                    else => return self.fail(err),
+                };
+                self.ctl.stopProcess(self.id) catch |err| switch (err) {
+                    error.ProcessNotFound, error.ProcessStopping => {},
+                    else => return self.fail(err),
+                };
+            }
+        }
+
+        fn fail(self: *@This(), err: anyerror) void {
+            self.result = err;
+        }
+    };
+    const Lister = struct {
+        fn run(list_ctl: *controller.Controller, list_ids: []const domain.process.ProcessId, stop: *std.atomic.Value(bool)) void {
+            while (!stop.load(.seq_cst)) {
+                const active = list_ctl.getAllProcessIDs(std.testing.allocator) catch continue;
+                std.testing.allocator.free(active);
+                for (list_ids) |list_id| {
+                    _ = list_ctl.isRunning(list_id);
+                    _ = list_ctl.getPID(list_id);
+                    _ = list_ctl.exitCode(list_id);
+                    _ = list_ctl.processStats(list_id);
+                    list_ctl.resizeProcess(list_id, 24, 80) catch {};
+                }
+            }
+        }
+    };
+
+    var churns: [4]Churn = undefined;
+    for (&churns, 0..) |*churn, index| churn.* = .{ .ctl = &ctl, .proc_cfg = &proc_cfg, .id = ids[index % ids.len] };
+    const lister = try std.Thread.spawn(.{}, Lister.run, .{ &ctl, ids[0..], &done });
+    var churn_threads: [churns.len]std.Thread = undefined;
+    for (&churns, &churn_threads) |*churn, *thread| thread.* = try std.Thread.spawn(.{}, Churn.run, .{churn});
+    for (churn_threads) |thread| thread.join();
+    done.store(true, .seq_cst);
+    lister.join();
+
+    for (churns) |churn| {
+        if (churn.result) |err| return err;
     }
-    return count;
+    for (ids) |id| {
+        if (ctl.isRunning(id)) try ctl.stopProcess(id);
+    }
+    const remaining = try ctl.getAllProcessIDs(std.testing.allocator);
+    defer std.testing.allocator.free(remaining);
+    try std.testing.expectEqual(@as(usize, 0), remaining.len);
 }
 
 fn waitForScrollbackContains(
@@ -396,3 +785,12 @@ fn waitForControllerStopped(ctl: *controller.Controller, id: domain.process.Proc
     }
     return error.ExpectedProcessStopped;
 }
+
+fn waitForControllerStatus(ctl: *controller.Controller, id: domain.process.ProcessId, status: domain.process.ProcessStatus) !void {
+    var attempts: usize = 0;
+    while (attempts < 200) : (attempts += 1) {
+        if (ctl.getProcessStatus(id) == status) return;
+        std.Thread.sleep(5 * std.time.ns_per_ms);
+    }
+    return error.ExpectedProcessStatus;
+}
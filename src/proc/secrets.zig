@@ -0,0 +1,159 @@
+//! Secret environment values.
+//! `secrets` entries are resolved here from a command or a file just before the process starts. Values go straight into the child's environment map; they are never logged, kept in config, or sent to clients.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const builder = @import("builder.zig");
+const hooks = @import("hooks.zig");
+
+const log = std.log.scoped(.process);
+
+/// Largest secret file read without a `decrypt_cmd`.
+const max_file_bytes = 64 * 1024;
+
+/// Resolves every secret of `proc_cfg` into `env_map`, overriding `env`.
+/// Commands run in `cwd` with `env_map` as it was before any secret was
+/// added, bounded by `hook_timeout_ms`; their stderr is logged, their stdout
+/// never is. The first failure returns `error.SecretFailed`.
+pub fn apply(
+    allocator: std.mem.Allocator,
+    env_map: *std.process.EnvMap,
+    proc_cfg: *const config.schema.ProcessConfig,
+    global_config: ?*const config.schema.Config,
+    cwd: []const u8,
+) !void {
+    if (proc_cfg.secrets.count() == 0) return;
+
+    var command_env = std.process.EnvMap.init(allocator);
+    defer command_env.deinit();
+    var env_it = env_map.iterator();
+    while (env_it.next()) |entry| try command_env.put(entry.key_ptr.*, entry.value_ptr.*);
+
+    var it = proc_cfg.secrets.iterator();
+    while (it.next()) |entry| {
+        const value = resolve(allocator, entry.value_ptr, global_config, cwd, &command_env, hooks.timeoutMs(proc_cfg)) catch |err| {
+            log.warn("secret {s} could not be resolved: {s}", .{ entry.key_ptr.*, @errorName(err) });
+            return error.SecretFailed;
+        };
+        defer {
+            std.crypto.secureZero(u8, value);
+            allocator.free(value);
+        }
+        try env_map.put(entry.key_ptr.*, trimNewline(value));
+    }
+}
+
+/// One secret's raw value, owned by the caller, who should zero it.
+fn resolve(
+    allocator: std.mem.Allocator,
+    secret: *const config.schema.SecretConfig,
+    global_config: ?*const config.schema.Config,
+    cwd: []const u8,
+    command_env: *const std.process.EnvMap,
+    timeout_ms: u64,
+) ![]u8 {
+    if (secret.cmd.items.len > 0) {
+        return runForValue(allocator, secret.cmd.items, cwd, command_env, timeout_ms);
+    }
+
+    const path = try filePath(allocator, secret.file, global_config, cwd);
+    defer allocator.free(path);
+    if (secret.decrypt_cmd.items.len == 0) {
+        return std.fs.cwd().readFileAlloc(allocator, path, max_file_bytes);
+    }
+
+    const argv = try allocator.alloc([]const u8, secret.decrypt_cmd.items.len + 1);
+    defer allocator.free(argv);
+    @memcpy(argv[0..secret.decrypt_cmd.items.len], secret.decrypt_cmd.items);
+    argv[argv.len - 1] = path;
+    return runForValue(allocator, argv, cwd, command_env, timeout_ms);
+}
+
+fn runForValue(
+    allocator: std.mem.Allocator,
+    argv: []const []const u8,
+    cwd: []const u8,
+    command_env: *const std.process.EnvMap,
+    timeout_ms: u64,
+) ![]u8 {
+    var output = hooks.Output.init(allocator);
+    defer {
+        std.crypto.secureZero(u8, output.stdout.items);
+        output.deinit();
+    }
+    const result = hooks.runCapture(allocator, argv, .{ .cwd = cwd, .env_map = command_env, .timeout_ms = timeout_ms }, &output);
+    hooks.logOutput(std.fs.path.basename(argv[0]), output.stderr.items);
+    try result;
+    return allocator.dupe(u8, output.stdout.items);
+}
+
+/// `file` with `{config_dir}` and `{git_root}` expanded, relative to `cwd`.
+fn filePath(
+    allocator: std.mem.Allocator,
+    file: []const u8,
+    global_config: ?*const config.schema.Config,
+    cwd: []const u8,
+) ![]const u8 {
+    const resolved = try builder.resolveCwd(allocator, file, global_config);
+    if (std.fs.path.isAbsolute(resolved) or cwd.len == 0) return resolved;
+    defer allocator.free(resolved);
+    return std.fs.path.join(allocator, &.{ cwd, resolved });
+}
+
+/// Drops the one trailing newline `pass`, `op read`, and files usually end
+/// with; the value is otherwise used byte for byte.
+fn trimNewline(value: []const u8) []const u8 {
+    if (std.mem.endsWith(u8, value, "\r\n")) return value[0 .. value.len - 2];
+    if (std.mem.endsWith(u8, value, "\n")) return value[0 .. value.len - 1];
+    return value;
+}
+
+test "secrets resolve from commands and files into the child environment" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.writeFile(.{ .sub_path = "token.txt", .data = "from-file\n" });
+    const cwd = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(cwd);
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    var from_cmd = config.schema.SecretConfig.empty(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &from_cmd.cmd, "sh");
+    try config.schema.appendOwned(std.testing.allocator, &from_cmd.cmd, "-c");
+    try config.schema.appendOwned(std.testing.allocator, &from_cmd.cmd, "printf 'hunter2\\n'; echo checked >&2");
+    try config.schema.putOwnedSecret(std.testing.allocator, &proc_cfg.secrets, "DB_PASSWORD", from_cmd);
+    var from_file = config.schema.SecretConfig.empty(std.testing.allocator);
+    from_file.file = try std.testing.allocator.dupe(u8, "token.txt");
+    try config.schema.putOwnedSecret(std.testing.allocator, &proc_cfg.secrets, "API_TOKEN", from_file);
+    var decrypted = config.schema.SecretConfig.empty(std.testing.allocator);
+    decrypted.file = try std.testing.allocator.dupe(u8, "token.txt");
+    // The path is appended as `$0`; `tr` stands in for a real decryptor.
+    try config.schema.appendOwned(std.testing.allocator, &decrypted.decrypt_cmd, "sh");
+    try config.schema.appendOwned(std.testing.allocator, &decrypted.decrypt_cmd, "-c");
+    try config.schema.appendOwned(std.testing.allocator, &decrypted.decrypt_cmd, "tr a-z A-Z < \"$0\"");
+    try config.schema.putOwnedSecret(std.testing.allocator, &proc_cfg.secrets, "SIGNING_KEY", decrypted);
+
+    var env_map = std.process.EnvMap.init(std.testing.allocator);
+    defer env_map.deinit();
+    try env_map.put("DB_PASSWORD", "from-env");
+
+    try apply(std.testing.allocator, &env_map, &proc_cfg, null, cwd);
+    try std.testing.expectEqualStrings("hunter2", env_map.get("DB_PASSWORD").?);
+    try std.testing.expectEqualStrings("from-file", env_map.get("API_TOKEN").?);
+    try std.testing.expectEqualStrings("FROM-FILE", env_map.get("SIGNING_KEY").?);
+}
+
+test "a failing secret command fails the start" {
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    var secret = config.schema.SecretConfig.empty(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &secret.cmd, "sh");
+    try config.schema.appendOwned(std.testing.allocator, &secret.cmd, "-c");
+    try config.schema.appendOwned(std.testing.allocator, &secret.cmd, "echo locked >&2; exit 1");
+    try config.schema.putOwnedSecret(std.testing.allocator, &proc_cfg.secrets, "DB_PASSWORD", secret);
+
+    var env_map = std.process.EnvMap.init(std.testing.allocator);
+    defer env_map.deinit();
+    try std.testing.expectError(error.SecretFailed, apply(std.testing.allocator, &env_map, &proc_cfg, null, ""));
+    try std.testing.expect(env_map.get("DB_PASSWORD") == null);
+}
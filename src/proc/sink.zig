@@ -0,0 +1,271 @@
+//! Process output sinks.
+//! Captured output is copied to files, syslog, or journald next to the scrollback so service logs can be collected outside proctmux.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const builder = @import("builder.zig");
+
+const log = std.log.scoped(.process);
+
+const syslog_path = "/dev/log";
+const journald_path = "/run/systemd/journal/socket";
+/// Longest line sent as one datagram; longer lines are split.
+const max_line_len = 4096;
+/// user.info
+const syslog_priority = 14;
+
+/// The sinks opened for one process launch. Writes come from the output
+/// capture thread only; `deinit` runs after that thread has been joined.
+pub const Sinks = struct {
+    allocator: std.mem.Allocator,
+    entries: []Entry = &.{},
+
+    /// Opens every resolved `output_sinks` spec. A sink that cannot be opened
+    /// is logged and skipped so logging problems never block a start.
+    pub fn open(
+        allocator: std.mem.Allocator,
+        proc_cfg: *const config.schema.ProcessConfig,
+        global_config: ?*const config.schema.Config,
+    ) !Sinks {
+        var entries = std.array_list.Managed(Entry).init(allocator);
+        errdefer {
+            for (entries.items) |*entry| entry.deinit(allocator);
+            entries.deinit();
+        }
+
+        for (proc_cfg.output_sinks.items) |spec| {
+            const entry = openEntry(allocator, spec, global_config) catch |err| {
+                log.warn("output sink {s} unavailable: {s}", .{ spec, @errorName(err) });
+                continue;
+            };
+            try entries.append(entry);
+        }
+        return .{ .allocator = allocator, .entries = try entries.toOwnedSlice() };
+    }
+
+    pub fn deinit(self: *Sinks) void {
+        for (self.entries) |*entry| {
+            entry.flush();
+            entry.deinit(self.allocator);
+        }
+        self.allocator.free(self.entries);
+    }
+
+    /// Copies output to every sink. A sink that fails is closed and dropped
+    /// for the rest of the launch instead of retrying on every read.
+    pub fn write(self: *Sinks, bytes: []const u8) void {
+        for (self.entries) |*entry| {
+            if (entry.target == .closed) continue;
+            entry.write(bytes) catch |err| {
+                log.warn("output sink failed and was closed: {s}", .{@errorName(err)});
+                entry.close();
+            };
+        }
+    }
+};
+
+const Entry = struct {
+    target: Target,
+    /// syslog tag or journald identifier.
+    tag: []const u8 = "",
+    /// Partial line held until its newline arrives; unused by file sinks.
+    line: std.array_list.Managed(u8),
+
+    const Target = union(enum) {
+        file: std.fs.File,
+        syslog: std.posix.socket_t,
+        journald: std.posix.socket_t,
+        closed,
+    };
+
+    fn write(self: *Entry, bytes: []const u8) !void {
+        switch (self.target) {
+            .file => |file| return file.writeAll(bytes),
+            .closed => return,
+            .syslog, .journald => {},
+        }
+
+        var rest = bytes;
+        while (rest.len > 0) {
+            const newline = std.mem.indexOfScalar(u8, rest, '\n');
+            const take = @min(newline orelse rest.len, max_line_len - self.line.items.len);
+            try self.line.appendSlice(rest[0..take]);
+            rest = rest[take..];
+            if (rest.len > 0 and rest[0] == '\n') {
+                rest = rest[1..];
+                try self.sendLine();
+            } else if (self.line.items.len >= max_line_len) {
+                try self.sendLine();
+            }
+        }
+    }
+
+    fn flush(self: *Entry) void {
+        if (self.line.items.len == 0) return;
+        self.sendLine() catch {};
+    }
+
+    fn sendLine(self: *Entry) !void {
+        defer self.line.clearRetainingCapacity();
+        const text = std.mem.trimRight(u8, self.line.items, "\r");
+        if (text.len == 0) return;
+
+        var buffer: [max_line_len + 256]u8 = undefined;
+        const datagram = switch (self.target) {
+            .syslog => try syslogDatagram(&buffer, self.tag, text),
+            .journald => try journaldDatagram(&buffer, self.tag, text),
+            .file, .closed => return,
+        };
+        const socket = switch (self.target) {
+            .syslog, .journald => |fd| fd,
+            .file, .closed => unreachable,
+        };
+        // A busy log daemon drops lines rather than stalling capture.
+        _ = std.posix.send(socket, datagram, 0) catch |err| switch (err) {
+            error.WouldBlock => return,
+            else => return err,
+        };
+    }
+
+    fn close(self: *Entry) void {
+        switch (self.target) {
+            .file => |file| file.close(),
+            .syslog, .journald => |fd| std.posix.close(fd),
+            .closed => {},
+        }
+        self.target = .closed;
+    }
+
+    fn deinit(self: *Entry, allocator: std.mem.Allocator) void {
+        self.close();
+        if (self.tag.len > 0) allocator.free(self.tag);
+        self.line.deinit();
+    }
+};
+
+fn openEntry(
+    allocator: std.mem.Allocator,
+    spec: []const u8,
+    global_config: ?*const config.schema.Config,
+) !Entry {
+    const colon = std.mem.indexOfScalar(u8, spec, ':') orelse return error.InvalidOutputSink;
+    const kind = std.meta.stringToEnum(config.schema.OutputSinkKind, spec[0..colon]) orelse return error.InvalidOutputSink;
+    const target = spec[colon + 1 ..];
+
+    var entry = Entry{ .target = .closed, .line = std.array_list.Managed(u8).init(allocator) };
+    errdefer entry.deinit(allocator);
+    switch (kind) {
+        .file => entry.target = .{ .file = try openFile(allocator, target, global_config) },
+        .syslog => {
+            entry.tag = try allocator.dupe(u8, target);
+            entry.target = .{ .syslog = try connectDatagram(syslog_path) };
+        },
+        .journald => {
+            entry.tag = try allocator.dupe(u8, target);
+            entry.target = .{ .journald = try connectDatagram(journald_path) };
+        },
+    }
+    return entry;
+}
+
+/// Appends to the file, creating it and its directory. `{config_dir}` and
+/// `{git_root}` resolve like a process `cwd`.
+fn openFile(
+    allocator: std.mem.Allocator,
+    path_template: []const u8,
+    global_config: ?*const config.schema.Config,
+) !std.fs.File {
+    const path = try builder.resolveCwd(allocator, path_template, global_config);
+    defer allocator.free(path);
+
+    if (std.fs.path.dirname(path)) |dir| try std.fs.cwd().makePath(dir);
+    const file = try std.fs.cwd().createFile(path, .{ .truncate = false });
+    errdefer file.close();
+    try file.seekFromEnd(0);
+    return file;
+}
+
+fn connectDatagram(path: []const u8) !std.posix.socket_t {
+    const socket = try std.posix.socket(
+        std.posix.AF.UNIX,
+        std.posix.SOCK.DGRAM | std.posix.SOCK.CLOEXEC | std.posix.SOCK.NONBLOCK,
+        0,
+    );
+    errdefer std.posix.close(socket);
+
+    const address = try std.net.Address.initUnix(path);
+    try std.posix.connect(socket, &address.any, address.getOsSockLen());
+    return socket;
+}
+
+fn syslogDatagram(buffer: []u8, tag: []const u8, text: []const u8) ![]const u8 {
+    return std.fmt.bufPrint(buffer, "<{d}>{s}: {s}", .{ syslog_priority, tag, text });
+}
+
+/// journald's native protocol; `text` never holds a newline, so the simple
+/// `KEY=value` form is enough.
+fn journaldDatagram(buffer: []u8, tag: []const u8, text: []const u8) ![]const u8 {
+    return std.fmt.bufPrint(buffer, "MESSAGE={s}\nSYSLOG_IDENTIFIER={s}\nPRIORITY=6\n", .{ text, tag });
+}
+
+test "file sinks append raw output and create their directory" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(root);
+    const spec = try std.fmt.allocPrint(std.testing.allocator, "file:{s}/logs/api.log", .{root});
+
+    var proc_cfg = config.schema.ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try proc_cfg.output_sinks.append(spec);
+    try config.schema.appendOwned(std.testing.allocator, &proc_cfg.output_sinks, "bogus:target");
+
+    try tmp.dir.makePath("logs");
+    try tmp.dir.writeFile(.{ .sub_path = "logs/api.log", .data = "earlier\n" });
+
+    var sinks = try Sinks.open(std.testing.allocator, &proc_cfg, null);
+    sinks.write("\x1b[32mready\x1b[0m\r\n");
+    sinks.write("partial");
+    sinks.deinit();
+
+    const contents = try tmp.dir.readFileAlloc(std.testing.allocator, "logs/api.log", 1024);
+    defer std.testing.allocator.free(contents);
+    try std.testing.expectEqualStrings("earlier\n\x1b[32mready\x1b[0m\r\npartial", contents);
+}
+
+test "datagram sinks frame one message per line" {
+    var buffer: [max_line_len + 256]u8 = undefined;
+    try std.testing.expectEqualStrings("<14>api: ready", try syslogDatagram(&buffer, "api", "ready"));
+    try std.testing.expectEqualStrings(
+        "MESSAGE=ready\nSYSLOG_IDENTIFIER=api\nPRIORITY=6\n",
+        try journaldDatagram(&buffer, "api", "ready"),
+    );
+
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(std.testing.allocator, ".");
+    defer std.testing.allocator.free(root);
+    const socket_path = try std.fs.path.join(std.testing.allocator, &.{ root, "log.sock" });
+    defer std.testing.allocator.free(socket_path);
+
+    const listener = try std.posix.socket(std.posix.AF.UNIX, std.posix.SOCK.DGRAM | std.posix.SOCK.CLOEXEC, 0);
+    defer std.posix.close(listener);
+    const address = try std.net.Address.initUnix(socket_path);
+    try std.posix.bind(listener, &address.any, address.getOsSockLen());
+
+    var entry = Entry{
+        .target = .{ .syslog = try connectDatagram(socket_path) },
+        .tag = try std.testing.allocator.dupe(u8, "api"),
+        .line = std.array_list.Managed(u8).init(std.testing.allocator),
+    };
+    defer entry.deinit(std.testing.allocator);
+
+    try entry.write("first\r\nsec");
+    try entry.write("ond\n");
+
+    var received: [64]u8 = undefined;
+    const first = try std.posix.recv(listener, &received, 0);
+    try std.testing.expectEqualStrings("<14>api: first", received[0..first]);
+    const second = try std.posix.recv(listener, &received, 0);
+    try std.testing.expectEqualStrings("<14>api: second", received[0..second]);
+}
@@ -56,13 +56,22 @@ fn startPty(
     command_spec: builder.CommandSpec,
     env_map: *const std.process.EnvMap,
 ) !Started {
+    // Both ends are close-on-exec; the child's dup2 onto stderr clears it there.
+    const stderr_pipe: ?[2]std.posix.fd_t = if (proc_cfg.separate_stderr)
+        try std.posix.pipe2(.{ .CLOEXEC = true })
+    else
+        null;
+    errdefer if (stderr_pipe) |fds| std.posix.close(fds[0]);
+    defer if (stderr_pipe) |fds| std.posix.close(fds[1]);
+
     const spawned = try pty.spawn(
         allocator,
         command_spec.argv,
         env_map,
-        proc_cfg.cwd,
+        command_spec.cwd,
         resolveTerminalRows(proc_cfg),
         resolveTerminalCols(proc_cfg),
+        if (stderr_pipe) |fds| fds[1] else null,
     );
     errdefer spawned.master.close();
 
@@ -70,6 +79,7 @@ fn startPty(
         .handle = .{ .pty = .{
             .pid = spawned.pid,
             .master = spawned.master,
+            .stderr = if (stderr_pipe) |fds| .{ .handle = fds[0] } else null,
         } },
     };
 }
@@ -83,9 +93,9 @@ fn startPipe(
     var child = std.process.Child.init(command_spec.argv, allocator);
     child.stdin_behavior = .Pipe;
     child.stdout_behavior = .Pipe;
-    child.stderr_behavior = .Ignore;
+    child.stderr_behavior = if (proc_cfg.separate_stderr) .Pipe else .Ignore;
     child.pgid = 0;
-    if (proc_cfg.cwd.len > 0) child.cwd = proc_cfg.cwd;
+    if (command_spec.cwd.len > 0) child.cwd = command_spec.cwd;
     child.env_map = env_map;
     try child.spawn();
     errdefer _ = child.kill() catch null;
@@ -94,6 +104,8 @@ fn startPipe(
     child.stdin = null;
     const stdout = child.stdout.?;
     child.stdout = null;
+    const stderr = child.stderr;
+    child.stderr = null;
 
     return .{
         .handle = .{ .pipe = .{
@@ -101,6 +113,7 @@ fn startPipe(
             .child = child,
             .stdin = stdin,
             .stdout = stdout,
+            .stderr = stderr,
         } },
     };
 }
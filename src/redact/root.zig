@@ -1,5 +1,5 @@
 //! Redaction helpers for safe diagnostic output.
-//! This module mirrors Project Config and process views while replacing secret-bearing values before they are logged or displayed in tests.
+//! This module mirrors Project Config and process views while replacing secret-bearing values before they are logged or displayed in tests. Process `env` and `secrets` are never copied, so neither values nor secret references leave the primary.
 
 const std = @import("std");
 const config = @import("../config/root.zig");
@@ -68,9 +68,15 @@ pub fn configForIPC(
     out.layout = source.layout;
     out.style = source.style;
     out.general = source.general;
+    out.security = source.security;
 
     try cloneKeybindingConfig(allocator, &out.keybinding, &source.keybinding);
     try cloneStringList(allocator, &out.shell_cmd, source.shell_cmd.items);
+    try cloneStringList(allocator, &out.error_patterns, source.error_patterns.items);
+    try cloneStringList(allocator, &out.redact_patterns, source.redact_patterns.items);
+    try cloneStringList(allocator, &out.clipboard_cmd, source.clipboard_cmd.items);
+    try cloneStringList(allocator, &out.open_cmd, source.open_cmd.items);
+    try cloneStringList(allocator, &out.editor_cmd, source.editor_cmd.items);
 
     var it = source.procs.iterator();
     while (it.next()) |entry| {
@@ -92,18 +98,51 @@ pub fn processConfig(
     out.cwd = try dupeOptional(allocator, source.cwd);
     out.description = try dupeOptional(allocator, source.description);
     out.docs = try dupeOptional(allocator, source.docs);
+    out.url = try dupeOptional(allocator, source.url);
+    out.term = try dupeOptional(allocator, source.term);
+    out.colorterm = try dupeOptional(allocator, source.colorterm);
+    out.env_loader = try dupeOptional(allocator, source.env_loader);
+    out.@"type" = try dupeOptional(allocator, source.@"type");
+    out.image = try dupeOptional(allocator, source.image);
+    out.container_name = try dupeOptional(allocator, source.container_name);
+    out.replica_group = try dupeOptional(allocator, source.replica_group);
+    out.env_clear = source.env_clear;
     out.stop = source.stop;
     out.stop_timeout_ms = source.stop_timeout_ms;
     out.autostart = source.autostart;
     out.autofocus = source.autofocus;
+    out.open_url = source.open_url;
     out.terminal_rows = source.terminal_rows;
     out.terminal_cols = source.terminal_cols;
+    out.force_color = source.force_color;
+    out.separate_stderr = source.separate_stderr;
+    out.login_shell = source.login_shell;
+    out.interactive_shell = source.interactive_shell;
+    out.watch_debounce_ms = source.watch_debounce_ms;
+    out.hook_timeout_ms = source.hook_timeout_ms;
+    out.idle_timeout_ms = source.idle_timeout_ms;
+    out.expect_output_within_ms = source.expect_output_within_ms;
+    out.startup_delay_ms = source.startup_delay_ms;
+    out.crash_loop_exits = source.crash_loop_exits;
+    out.crash_loop_window_ms = source.crash_loop_window_ms;
+    out.replicas = source.replicas;
 
     try cloneStringList(allocator, &out.cmd, source.cmd.items);
     try cloneStringList(allocator, &out.meta_tags, source.meta_tags.items);
     try cloneStringList(allocator, &out.categories, source.categories.items);
     try cloneStringList(allocator, &out.add_path, source.add_path.items);
     try cloneStringList(allocator, &out.on_kill, source.on_kill.items);
+    try cloneStringList(allocator, &out.pre_start, source.pre_start.items);
+    try cloneStringList(allocator, &out.post_start, source.post_start.items);
+    try cloneStringList(allocator, &out.pre_stop, source.pre_stop.items);
+    try cloneStringList(allocator, &out.post_stop, source.post_stop.items);
+    try cloneStringList(allocator, &out.shell_cmd, source.shell_cmd.items);
+    try cloneStringList(allocator, &out.env_loader_cmd, source.env_loader_cmd.items);
+    try cloneStringList(allocator, &out.output_sinks, source.output_sinks.items);
+    try cloneStringList(allocator, &out.watch, source.watch.items);
+    try cloneStringList(allocator, &out.watch_ignore, source.watch_ignore.items);
+    try cloneStringList(allocator, &out.ports, source.ports.items);
+    try cloneStringList(allocator, &out.volumes, source.volumes.items);
     return out;
 }
 
@@ -125,7 +164,23 @@ fn cloneKeybindingConfig(
     try cloneStringList(allocator, &out.toggle_focus, source.toggle_focus.items);
     try cloneStringList(allocator, &out.focus_client, source.focus_client.items);
     try cloneStringList(allocator, &out.focus_server, source.focus_server.items);
+    try cloneStringList(allocator, &out.rotate_split, source.rotate_split.items);
+    try cloneStringList(allocator, &out.grow_client, source.grow_client.items);
+    try cloneStringList(allocator, &out.shrink_client, source.shrink_client.items);
+    try cloneStringList(allocator, &out.cycle_view, source.cycle_view.items);
+    try cloneStringList(allocator, &out.cycle_sort, source.cycle_sort.items);
+    try cloneStringList(allocator, &out.toggle_pin, source.toggle_pin.items);
+    try cloneStringList(allocator, &out.toggle_mark, source.toggle_mark.items);
+    try cloneStringList(allocator, &out.toggle_messages, source.toggle_messages.items);
     try cloneStringList(allocator, &out.docs, source.docs.items);
+    try cloneStringList(allocator, &out.jump_to_error, source.jump_to_error.items);
+    try cloneStringList(allocator, &out.copy_command, source.copy_command.items);
+    try cloneStringList(allocator, &out.copy_pid, source.copy_pid.items);
+    try cloneStringList(allocator, &out.copy_cwd, source.copy_cwd.items);
+    try cloneStringList(allocator, &out.copy_output, source.copy_output.items);
+    try cloneStringList(allocator, &out.open_url, source.open_url.items);
+    try cloneStringList(allocator, &out.open_cwd, source.open_cwd.items);
+    try cloneStringList(allocator, &out.show_env, source.show_env.items);
 }
 
 fn putRedactedProcess(
@@ -164,7 +219,7 @@ fn cloneStringList(
     for (values) |value| try config.schema.appendOwned(allocator, out, value);
 }
 
-test "process config redaction strips env and deep-copies active slices" {
+test "process config redaction strips env and secrets and deep-copies active slices" {
     var original = config.schema.ProcessConfig.empty(std.testing.allocator);
     defer original.deinit(std.testing.allocator);
     original.shell = "run api";
@@ -183,11 +238,15 @@ test "process config redaction strips env and deep-copies active slices" {
     try config.schema.appendOwned(std.testing.allocator, &original.add_path, "./node_modules/.bin");
     try config.schema.appendOwned(std.testing.allocator, &original.on_kill, "cleanup");
     try config.schema.putOwnedString(std.testing.allocator, &original.env, "TOKEN", "secret");
+    var secret = config.schema.SecretConfig.empty(std.testing.allocator);
+    try config.schema.appendOwned(std.testing.allocator, &secret.cmd, "pass");
+    try config.schema.putOwnedSecret(std.testing.allocator, &original.secrets, "DB_PASSWORD", secret);
 
     var redacted = try processConfig(std.testing.allocator, &original);
     defer redacted.deinit(std.testing.allocator);
 
     try std.testing.expectEqual(@as(usize, 0), redacted.env.count());
+    try std.testing.expectEqual(@as(usize, 0), redacted.secrets.count());
     try std.testing.expectEqualStrings("run api", redacted.shell);
     try std.testing.expectEqualStrings(".", redacted.cwd);
     try std.testing.expectEqualStrings("API", redacted.description);
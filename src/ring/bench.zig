@@ -0,0 +1,56 @@
+//! Ring buffer write throughput benchmark, run with `zig build bench`.
+//! It pushes PTY-sized chunks through a scrollback-sized buffer with and without a live reader and prints MB/s for each case.
+
+const std = @import("std");
+const ring = @import("root.zig");
+
+const capacity = 1024 * 1024;
+const total_bytes = 512 * 1024 * 1024;
+
+const Case = struct {
+    name: []const u8,
+    chunk_len: usize,
+    with_reader: bool,
+};
+
+const cases = [_]Case{
+    .{ .name = "64B chunks", .chunk_len = 64, .with_reader = false },
+    .{ .name = "4KiB chunks", .chunk_len = 4096, .with_reader = false },
+    .{ .name = "4KiB chunks, live reader", .chunk_len = 4096, .with_reader = true },
+    .{ .name = "2MiB chunks", .chunk_len = 2 * 1024 * 1024, .with_reader = false },
+};
+
+pub fn main() !void {
+    var debug_allocator = std.heap.DebugAllocator(.{}){};
+    defer _ = debug_allocator.deinit();
+    const allocator = debug_allocator.allocator();
+
+    const stdout = std.fs.File.stdout();
+    for (cases) |case| {
+        const mb_per_s = try run(allocator, case);
+        var line: [128]u8 = undefined;
+        try stdout.writeAll(try std.fmt.bufPrint(&line, "{s}: {d:.0} MB/s\n", .{ case.name, mb_per_s }));
+    }
+}
+
+fn run(allocator: std.mem.Allocator, case: Case) !f64 {
+    var rb = try ring.RingBuffer.init(allocator, capacity);
+    defer rb.deinit();
+    const reader_id: ?usize = if (case.with_reader) try rb.newReader() else null;
+
+    const chunk = try allocator.alloc(u8, case.chunk_len);
+    defer allocator.free(chunk);
+    for (chunk, 0..) |*byte, index| byte.* = @intCast(' ' + index % 94);
+
+    var timer = try std.time.Timer.start();
+    var written: usize = 0;
+    while (written < total_bytes) : (written += chunk.len) {
+        _ = rb.write(chunk);
+        // Drain like the viewer so the reader measures copies, not drops.
+        if (reader_id) |id| {
+            while (rb.readNext(id)) |data| allocator.free(data);
+        }
+    }
+    const elapsed_s = @as(f64, @floatFromInt(timer.read())) / std.time.ns_per_s;
+    return @as(f64, @floatFromInt(written)) / (1024 * 1024) / elapsed_s;
+}
@@ -4,6 +4,12 @@
 const std = @import("std");
 
 const max_reader_queue = 100;
+/// Output a reader may have queued before new writes are dropped for it.
+const max_reader_bytes = 1024 * 1024;
+/// Writes within this long of the latest time mark share it, so `--since`
+/// style queries are accurate to about a second.
+const time_mark_interval_ms = 1000;
+const max_time_marks = 1024;
 
 /// Result of atomically reading scrollback and registering for future output.
 /// The caller owns `snapshot` and later removes `reader_id`.
@@ -15,6 +21,9 @@ pub const SnapshotSubscription = struct {
 const Reader = struct {
     id: usize,
     queue: std.array_list.Managed([]u8),
+    queued_bytes: usize = 0,
+    /// Gets one byte per write so the reader can sleep in `poll`.
+    wake_fd: ?std.posix.fd_t = null,
 
     fn init(allocator: std.mem.Allocator, id: usize) Reader {
         return .{
@@ -29,25 +38,77 @@ const Reader = struct {
         self.queue.deinit();
     }
 
+    /// Queues a copy of `data`. A full queue merges it into the newest chunk
+    /// so chatty processes cost fewer, larger reads; output is only dropped
+    /// once `max_reader_bytes` are waiting.
     fn enqueue(self: *Reader, data: []const u8) void {
-        if (self.queue.items.len >= max_reader_queue) return;
+        if (self.queued_bytes + data.len > max_reader_bytes) return;
 
         const allocator = self.queue.allocator;
+        if (self.queue.items.len >= max_reader_queue) {
+            const newest = &self.queue.items[self.queue.items.len - 1];
+            const old_len = newest.len;
+            const merged = allocator.realloc(newest.*, old_len + data.len) catch return;
+            @memcpy(merged[old_len..], data);
+            newest.* = merged;
+            self.queued_bytes += data.len;
+            return;
+        }
+
         const owned = allocator.dupe(u8, data) catch return;
         self.queue.append(owned) catch {
             allocator.free(owned);
             return;
         };
+        self.queued_bytes += data.len;
     }
 
-    fn readNext(self: *Reader) ?[]u8 {
-        if (self.queue.items.len == 0) return null;
+    fn readNext(self: *Reader, max_bytes: usize) ?[]u8 {
+        if (self.queue.items.len == 0 or max_bytes == 0) return null;
+
+        const oldest = self.queue.items[0];
+        if (oldest.len > max_bytes) {
+            const allocator = self.queue.allocator;
+            const head = allocator.dupe(u8, oldest[0..max_bytes]) catch return null;
+            const rest = allocator.dupe(u8, oldest[max_bytes..]) catch {
+                allocator.free(head);
+                return null;
+            };
+            allocator.free(oldest);
+            self.queue.items[0] = rest;
+            self.queued_bytes -= head.len;
+            return head;
+        }
+
+        self.queued_bytes -= oldest.len;
         return self.queue.orderedRemove(0);
     }
 };
 
+/// Hook run after each non-empty write, outside the buffer lock, so a consumer
+/// can wake up instead of polling for output.
+pub const WriteNotifier = struct {
+    context: *anyopaque,
+    notify: *const fn (context: *anyopaque) void,
+};
+
+/// Stream offset (counted like `written_total`) of the first byte written at
+/// or after `ms`, in Unix milliseconds.
+const TimeMark = struct {
+    offset: u64,
+    ms: i64,
+};
+
+/// Output copied from a stream offset. `offset` is where `bytes` actually
+/// starts, past the requested one when older output was overwritten or cleared.
+pub const Chunk = struct {
+    bytes: []u8,
+    offset: u64,
+};
+
 /// Fixed-capacity byte history with non-blocking live-reader queues.
-/// Slow readers drop live chunks rather than blocking process output capture.
+/// Slow readers have live chunks coalesced and, past `max_reader_bytes`,
+/// dropped rather than blocking process output capture.
 pub const RingBuffer = struct {
     allocator: std.mem.Allocator,
     buf: []u8,
@@ -56,15 +117,28 @@ pub const RingBuffer = struct {
     mutex: std.Thread.Mutex = .{},
     readers: std.array_list.Managed(Reader),
     next_id: usize = 0,
+    /// Every byte ever written, including overwritten and cleared history.
+    written_total: u64 = 0,
+    /// Newlines in everything written, counted like `written_total`.
+    lines_total: u64 = 0,
+    /// Oldest first; the oldest marks are dropped once `max_time_marks` is hit.
+    time_marks: std.array_list.Managed(TimeMark),
+    /// Bells the writer found in everything written; see `addBells`.
+    bells: u32 = 0,
+    write_notifier: ?WriteNotifier = null,
 
     pub fn init(allocator: std.mem.Allocator, capacity: usize) !RingBuffer {
         if (capacity == 0) return error.InvalidCapacity;
         const buf = try allocator.alloc(u8, capacity);
         errdefer allocator.free(buf);
+        var time_marks = std.array_list.Managed(TimeMark).init(allocator);
+        errdefer time_marks.deinit();
+        try time_marks.ensureTotalCapacity(max_time_marks);
         return .{
             .allocator = allocator,
             .buf = buf,
             .readers = std.array_list.Managed(Reader).init(allocator),
+            .time_marks = time_marks,
         };
     }
 
@@ -74,6 +148,7 @@ pub const RingBuffer = struct {
 
         for (self.readers.items) |*reader| reader.deinit();
         self.readers.deinit();
+        self.time_marks.deinit();
         self.allocator.free(self.buf);
         self.buf = &.{};
         self.w = 0;
@@ -81,35 +156,147 @@ pub const RingBuffer = struct {
     }
 
     pub fn write(self: *RingBuffer, data: []const u8) usize {
+        return self.writeAt(data, std.time.milliTimestamp());
+    }
+
+    fn writeAt(self: *RingBuffer, data: []const u8, now_ms: i64) usize {
+        self.mutex.lock();
+        self.appendLocked(data, now_ms);
+        const notifier = self.write_notifier;
+        self.mutex.unlock();
+
+        if (data.len > 0) {
+            if (notifier) |hook| hook.notify(hook.context);
+        }
+        return data.len;
+    }
+
+    pub fn setWriteNotifier(self: *RingBuffer, notifier: ?WriteNotifier) void {
         self.mutex.lock();
         defer self.mutex.unlock();
+        self.write_notifier = notifier;
+    }
+
+    fn appendLocked(self: *RingBuffer, data: []const u8, now_ms: i64) void {
+        if (data.len > 0) self.markTimeLocked(now_ms);
 
-        for (data) |byte| {
-            self.buf[self.w] = byte;
-            self.w += 1;
-            if (self.w >= self.buf.len) {
+        // Only the newest `buf.len` bytes can survive, so skip the rest and
+        // copy at most two segments: up to the end of `buf`, then from its start.
+        var remaining = data;
+        if (remaining.len >= self.buf.len) {
+            const skipped = remaining.len - self.buf.len;
+            self.w = (self.w + skipped) % self.buf.len;
+            remaining = remaining[skipped..];
+        }
+        while (remaining.len > 0) {
+            const n = @min(remaining.len, self.buf.len - self.w);
+            @memcpy(self.buf[self.w..][0..n], remaining[0..n]);
+            self.w += n;
+            remaining = remaining[n..];
+            if (self.w == self.buf.len) {
                 self.w = 0;
                 self.full = true;
             }
         }
 
-        for (self.readers.items) |*reader| reader.enqueue(data);
-        return data.len;
+        for (self.readers.items) |*reader| {
+            reader.enqueue(data);
+            // A full non-blocking pipe already means "wake up", so the
+            // result is ignored.
+            if (reader.wake_fd) |fd| {
+                if (data.len > 0) _ = std.posix.system.write(fd, "w", 1);
+            }
+        }
+        self.written_total += data.len;
+        self.lines_total += std.mem.count(u8, data, "\n");
+    }
+
+    pub fn totalWritten(self: *RingBuffer) u64 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.written_total;
+    }
+
+    pub fn totalLines(self: *RingBuffer) u64 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.lines_total;
+    }
+
+    /// Bytes written at or after `since_ms`, with the same accuracy as
+    /// `bytesSince` but counting output that is no longer retained.
+    pub fn writtenSince(self: *RingBuffer, since_ms: i64) u64 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.written_total - self.offsetSinceLocked(since_ms);
+    }
+
+    /// Unix milliseconds when the newest burst of output began, to within a
+    /// time mark; 0 before anything is written. Writes less than a second
+    /// after that do not move it.
+    pub fn lastOutputMs(self: *RingBuffer) i64 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const marks = self.time_marks.items;
+        return if (marks.len > 0) marks[marks.len - 1].ms else 0;
+    }
+
+    /// Records bells the writer counted in its output. The buffer only keeps
+    /// the total, which like `written_total` spans every run of a process.
+    pub fn addBells(self: *RingBuffer, count: u32) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        self.bells +|= count;
+    }
+
+    pub fn bellCount(self: *RingBuffer) u32 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        return self.bells;
     }
 
     pub fn bytes(self: *RingBuffer, allocator: std.mem.Allocator) ![]u8 {
         self.mutex.lock();
         defer self.mutex.unlock();
 
-        return self.copyBytesLocked(allocator);
+        return self.copyBytesLocked(allocator, null);
+    }
+
+    /// Returns retained bytes written at or after `since_ms` (Unix
+    /// milliseconds). Output up to a second older may be included; none newer
+    /// is left out.
+    pub fn bytesSince(self: *RingBuffer, allocator: std.mem.Allocator, since_ms: i64) ![]u8 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        return self.copyBytesLocked(allocator, since_ms);
+    }
+
+    /// Copies the newest retained bytes, at most `out.len`, into `out`.
+    pub fn tail(self: *RingBuffer, out: []u8) []u8 {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        return self.copyTailLocked(out);
+    }
+
+    /// Returns the retained bytes from stream offset `offset` (counted like
+    /// `totalWritten`) on.
+    pub fn bytesFrom(self: *RingBuffer, allocator: std.mem.Allocator, offset: u64) !Chunk {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        const oldest = self.written_total - @as(u64, self.lenLocked());
+        const start = std.math.clamp(offset, oldest, self.written_total);
+        const out = try allocator.alloc(u8, @intCast(self.written_total - start));
+        return .{ .bytes = self.copyTailLocked(out), .offset = start };
     }
 
     pub fn len(self: *RingBuffer) usize {
         self.mutex.lock();
         defer self.mutex.unlock();
 
-        if (self.full) return self.buf.len;
-        return self.w;
+        return self.lenLocked();
     }
 
     pub fn cap(self: *RingBuffer) usize {
@@ -134,21 +321,42 @@ pub const RingBuffer = struct {
         return id;
     }
 
+    /// Makes every later write also write one byte to `fd`, normally the
+    /// non-blocking write end of a pipe the reader polls; null stops it.
+    pub fn setReaderWakeFd(self: *RingBuffer, reader_id: usize, fd: ?std.posix.fd_t) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+
+        if (self.findReader(reader_id)) |reader| reader.wake_fd = fd;
+    }
+
     pub fn readNext(self: *RingBuffer, reader_id: usize) ?[]u8 {
+        return self.readNextUpTo(reader_id, std.math.maxInt(usize));
+    }
+
+    /// Like `readNext`, returning at most `max_bytes`; the rest of a larger
+    /// chunk stays queued for the next read.
+    pub fn readNextUpTo(self: *RingBuffer, reader_id: usize, max_bytes: usize) ?[]u8 {
         self.mutex.lock();
         defer self.mutex.unlock();
 
-        if (self.findReader(reader_id)) |reader| return reader.readNext();
+        if (self.findReader(reader_id)) |reader| return reader.readNext(max_bytes);
         return null;
     }
 
     /// Captures historical bytes and registers a live reader under one lock so
     /// switching viewers cannot miss bytes between the two operations.
     pub fn snapshotAndSubscribe(self: *RingBuffer, allocator: std.mem.Allocator) !SnapshotSubscription {
+        return self.snapshotSinceAndSubscribe(allocator, null);
+    }
+
+    /// Like `snapshotAndSubscribe`, limiting history to output written since
+    /// `since_ms` as `bytesSince` does.
+    pub fn snapshotSinceAndSubscribe(self: *RingBuffer, allocator: std.mem.Allocator, since_ms: ?i64) !SnapshotSubscription {
         self.mutex.lock();
         defer self.mutex.unlock();
 
-        const snapshot = try self.copyBytesLocked(allocator);
+        const snapshot = try self.copyBytesLocked(allocator, since_ms);
         errdefer allocator.free(snapshot);
 
         const id = self.next_id;
@@ -181,12 +389,54 @@ pub const RingBuffer = struct {
         return null;
     }
 
-    fn copyBytesLocked(self: *RingBuffer, allocator: std.mem.Allocator) ![]u8 {
-        if (!self.full) return allocator.dupe(u8, self.buf[0..self.w]);
+    fn lenLocked(self: *RingBuffer) usize {
+        if (self.full) return self.buf.len;
+        return self.w;
+    }
+
+    fn copyTailLocked(self: *RingBuffer, out: []u8) []u8 {
+        const n = @min(out.len, self.lenLocked());
+        const start = (self.w + self.buf.len - n) % self.buf.len;
+        const first_len = @min(n, self.buf.len - start);
+        @memcpy(out[0..first_len], self.buf[start .. start + first_len]);
+        @memcpy(out[first_len..n], self.buf[0 .. n - first_len]);
+        return out[0..n];
+    }
 
-        var out = try allocator.alloc(u8, self.buf.len);
-        @memcpy(out[0 .. self.buf.len - self.w], self.buf[self.w..]);
-        @memcpy(out[self.buf.len - self.w ..], self.buf[0..self.w]);
+    fn markTimeLocked(self: *RingBuffer, now_ms: i64) void {
+        const marks = &self.time_marks;
+        if (marks.items.len > 0 and now_ms - marks.items[marks.items.len - 1].ms < time_mark_interval_ms) return;
+        if (marks.items.len == max_time_marks) _ = marks.orderedRemove(0);
+        marks.appendAssumeCapacity(.{ .offset = self.written_total, .ms = now_ms });
+    }
+
+    /// Stream offset where output written at or after `since_ms` begins. A
+    /// mark covers writes until the next one, so only marks that ended before
+    /// `since_ms` are skipped.
+    fn offsetSinceLocked(self: *RingBuffer, since_ms: i64) u64 {
+        const marks = self.time_marks.items;
+        var offset: u64 = 0;
+        for (marks, 0..) |mark, index| {
+            if (mark.ms + time_mark_interval_ms > since_ms) break;
+            offset = if (index + 1 < marks.len) marks[index + 1].offset else self.written_total;
+        }
+        return offset;
+    }
+
+    fn copyBytesLocked(self: *RingBuffer, allocator: std.mem.Allocator, since_ms: ?i64) ![]u8 {
+        const retained = self.lenLocked();
+        const retained_start = self.written_total - retained;
+        const skip: usize = if (since_ms) |since|
+            @intCast(@max(self.offsetSinceLocked(since), retained_start) - retained_start)
+        else
+            0;
+
+        const out = try allocator.alloc(u8, retained - skip);
+        const oldest = if (self.full) self.w else 0;
+        const start = (oldest + skip) % self.buf.len;
+        const first_len = @min(out.len, self.buf.len - start);
+        @memcpy(out[0..first_len], self.buf[start .. start + first_len]);
+        @memcpy(out[first_len..], self.buf[0 .. out.len - first_len]);
         return out;
     }
 };
@@ -205,6 +455,18 @@ test "ring buffer stores small writes and reports capacity" {
     try std.testing.expectEqualStrings("hello world", out);
 }
 
+test "ring buffer tail copies the newest bytes across the wrap" {
+    var rb = try RingBuffer.init(std.testing.allocator, 10);
+    defer rb.deinit();
+
+    var out: [4]u8 = undefined;
+    try std.testing.expectEqual(@as(usize, 0), rb.tail(&out).len);
+    _ = rb.write("ab");
+    try std.testing.expectEqualStrings("ab", rb.tail(&out));
+    _ = rb.write("0123456789xy");
+    try std.testing.expectEqualStrings("89xy", rb.tail(&out));
+}
+
 test "ring buffer keeps only newest data after overflow" {
     var rb = try RingBuffer.init(std.testing.allocator, 10);
     defer rb.deinit();
@@ -231,6 +493,27 @@ test "ring buffer preserves chronological order across multiple wraps" {
     try std.testing.expectEqualStrings("efghijklmn", out);
 }
 
+test "ring buffer writes larger than capacity land where byte-wise writes would" {
+    var rb = try RingBuffer.init(std.testing.allocator, 10);
+    defer rb.deinit();
+
+    _ = rb.write("abc");
+    _ = rb.write("0123456789xyz");
+    const out = try rb.bytes(std.testing.allocator);
+    defer std.testing.allocator.free(out);
+    try std.testing.expectEqualStrings("3456789xyz", out);
+
+    _ = rb.write("0123456789");
+    const exact = try rb.bytes(std.testing.allocator);
+    defer std.testing.allocator.free(exact);
+    try std.testing.expectEqualStrings("0123456789", exact);
+
+    _ = rb.write("!");
+    const after = try rb.bytes(std.testing.allocator);
+    defer std.testing.allocator.free(after);
+    try std.testing.expectEqualStrings("123456789!", after);
+}
+
 test "ring buffer clears and can be reused" {
     var rb = try RingBuffer.init(std.testing.allocator, 10);
     defer rb.deinit();
@@ -298,6 +581,56 @@ test "snapshot and subscribe captures history and future writes" {
     try std.testing.expect(std.mem.indexOf(u8, live, "live data") != null);
 }
 
+test "write notifier runs for non-empty writes" {
+    var rb = try RingBuffer.init(std.testing.allocator, 100);
+    defer rb.deinit();
+
+    const Counter = struct {
+        count: usize = 0,
+
+        fn notify(context: *anyopaque) void {
+            const self: *@This() = @ptrCast(@alignCast(context));
+            self.count += 1;
+        }
+    };
+    var counter = Counter{};
+    rb.setWriteNotifier(.{ .context = &counter, .notify = Counter.notify });
+
+    _ = rb.write("one");
+    _ = rb.write("");
+    try std.testing.expectEqual(@as(usize, 1), counter.count);
+
+    rb.setWriteNotifier(null);
+    _ = rb.write("two");
+    try std.testing.expectEqual(@as(usize, 1), counter.count);
+}
+
+test "reader wake fd becomes readable after each write" {
+    var rb = try RingBuffer.init(std.testing.allocator, 100);
+    defer rb.deinit();
+
+    const fds = try std.posix.pipe2(.{ .NONBLOCK = true, .CLOEXEC = true });
+    defer std.posix.close(fds[0]);
+    defer std.posix.close(fds[1]);
+
+    const reader_id = try rb.newReader();
+    rb.setReaderWakeFd(reader_id, fds[1]);
+    var poll_fds = [_]std.posix.pollfd{.{ .fd = fds[0], .events = std.posix.POLL.IN, .revents = 0 }};
+    try std.testing.expectEqual(@as(usize, 0), try std.posix.poll(&poll_fds, 0));
+
+    _ = rb.write("one");
+    try std.testing.expectEqual(@as(usize, 1), try std.posix.poll(&poll_fds, 0));
+    var drained: [8]u8 = undefined;
+    _ = try std.posix.read(fds[0], &drained);
+
+    rb.setReaderWakeFd(reader_id, null);
+    _ = rb.write("two");
+    try std.testing.expectEqual(@as(usize, 0), try std.posix.poll(&poll_fds, 0));
+    const queued = rb.readNext(reader_id) orelse return error.ExpectedReaderData;
+    defer std.testing.allocator.free(queued);
+    try std.testing.expectEqualStrings("one", queued);
+}
+
 test "removing reader stops future deliveries" {
     var rb = try RingBuffer.init(std.testing.allocator, 100);
     defer rb.deinit();
@@ -309,7 +642,7 @@ test "removing reader stops future deliveries" {
     try std.testing.expect(rb.readNext(reader_id) == null);
 }
 
-test "slow readers drop writes after queue capacity" {
+test "slow readers coalesce writes once the queue is full" {
     var rb = try RingBuffer.init(std.testing.allocator, 100);
     defer rb.deinit();
 
@@ -320,10 +653,112 @@ test "slow readers drop writes after queue capacity" {
     }
 
     i = 0;
-    while (i < max_reader_queue) : (i += 1) {
+    while (i < max_reader_queue - 1) : (i += 1) {
         const item = rb.readNext(reader_id) orelse return error.ExpectedReaderData;
         defer std.testing.allocator.free(item);
         try std.testing.expectEqualStrings("x", item);
     }
+    const merged = rb.readNext(reader_id) orelse return error.ExpectedReaderData;
+    defer std.testing.allocator.free(merged);
+    try std.testing.expectEqualStrings("xxxxxx", merged);
+    try std.testing.expect(rb.readNext(reader_id) == null);
+}
+
+test "readers drop writes past the queued byte limit" {
+    var rb = try RingBuffer.init(std.testing.allocator, 100);
+    defer rb.deinit();
+
+    const reader_id = try rb.newReader();
+    const big = try std.testing.allocator.alloc(u8, max_reader_bytes);
+    defer std.testing.allocator.free(big);
+    @memset(big, 'a');
+    _ = rb.write(big);
+    _ = rb.write("dropped");
+
+    const first = rb.readNextUpTo(reader_id, 10) orelse return error.ExpectedReaderData;
+    defer std.testing.allocator.free(first);
+    try std.testing.expectEqual(@as(usize, 10), first.len);
+
+    const rest = rb.readNext(reader_id) orelse return error.ExpectedReaderData;
+    defer std.testing.allocator.free(rest);
+    try std.testing.expectEqual(@as(usize, max_reader_bytes - 10), rest.len);
     try std.testing.expect(rb.readNext(reader_id) == null);
 }
+
+test "bytes since a time keep output from that second onward" {
+    var rb = try RingBuffer.init(std.testing.allocator, 100);
+    defer rb.deinit();
+
+    _ = rb.writeAt("old\n", 1_000);
+    _ = rb.writeAt("older-same-second\n", 1_500);
+    _ = rb.writeAt("recent\n", 5_000);
+    rb.clear();
+    _ = rb.writeAt("restarted\n", 9_000);
+
+    const recent = try rb.bytesSince(std.testing.allocator, 4_000);
+    defer std.testing.allocator.free(recent);
+    try std.testing.expectEqualStrings("restarted\n", recent);
+
+    const nothing = try rb.bytesSince(std.testing.allocator, 20_000);
+    defer std.testing.allocator.free(nothing);
+    try std.testing.expectEqualStrings("", nothing);
+
+    var wrapped = try RingBuffer.init(std.testing.allocator, 10);
+    defer wrapped.deinit();
+    _ = wrapped.writeAt("aaaaaa", 1_000);
+    _ = wrapped.writeAt("bbbbbb", 3_000);
+    const tail = try wrapped.bytesSince(std.testing.allocator, 1_200);
+    defer std.testing.allocator.free(tail);
+    try std.testing.expectEqualStrings("aaaabbbbbb", tail);
+    const newest = try wrapped.bytesSince(std.testing.allocator, 2_500);
+    defer std.testing.allocator.free(newest);
+    try std.testing.expectEqualStrings("bbbbbb", newest);
+}
+
+test "last output time follows the newest time mark" {
+    var rb = try RingBuffer.init(std.testing.allocator, 100);
+    defer rb.deinit();
+
+    try std.testing.expectEqual(@as(i64, 0), rb.lastOutputMs());
+    _ = rb.writeAt("a", 1_000);
+    _ = rb.writeAt("b", 1_400);
+    try std.testing.expectEqual(@as(i64, 1_000), rb.lastOutputMs());
+    _ = rb.writeAt("", 3_000);
+    try std.testing.expectEqual(@as(i64, 1_000), rb.lastOutputMs());
+    _ = rb.writeAt("c", 3_000);
+    try std.testing.expectEqual(@as(i64, 3_000), rb.lastOutputMs());
+}
+
+test "line and window counters span cleared output" {
+    var rb = try RingBuffer.init(std.testing.allocator, 4);
+    defer rb.deinit();
+
+    _ = rb.writeAt("one\ntwo\n", 1_000);
+    rb.clear();
+    _ = rb.writeAt("three\npart", 5_000);
+    try std.testing.expectEqual(@as(u64, 3), rb.totalLines());
+    try std.testing.expectEqual(@as(u64, 10), rb.writtenSince(4_000));
+    try std.testing.expectEqual(@as(u64, 18), rb.writtenSince(0));
+    try std.testing.expectEqual(@as(u64, 0), rb.writtenSince(6_000));
+}
+
+test "bytes from an offset skip output that is no longer retained" {
+    var rb = try RingBuffer.init(std.testing.allocator, 8);
+    defer rb.deinit();
+
+    _ = rb.write("abcd");
+    const from_two = try rb.bytesFrom(std.testing.allocator, 2);
+    defer std.testing.allocator.free(from_two.bytes);
+    try std.testing.expectEqualStrings("cd", from_two.bytes);
+    try std.testing.expectEqual(@as(u64, 2), from_two.offset);
+
+    _ = rb.write("efghij");
+    const wrapped = try rb.bytesFrom(std.testing.allocator, 0);
+    defer std.testing.allocator.free(wrapped.bytes);
+    try std.testing.expectEqualStrings("cdefghij", wrapped.bytes);
+    try std.testing.expectEqual(@as(u64, 2), wrapped.offset);
+
+    const caught_up = try rb.bytesFrom(std.testing.allocator, 10);
+    defer std.testing.allocator.free(caught_up.bytes);
+    try std.testing.expectEqual(@as(usize, 0), caught_up.bytes.len);
+}
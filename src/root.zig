@@ -12,10 +12,13 @@ pub const config = @import("config/root.zig");
 pub const domain = @import("domain/root.zig");
 pub const discover = @import("discover/root.zig");
 pub const ipc = @import("ipc/root.zig");
+pub const logging = @import("logging/root.zig");
 pub const cli = @import("cli/root.zig");
 pub const modes = @import("modes/root.zig");
 pub const proc = @import("proc/root.zig");
 pub const ring = @import("ring/root.zig");
+pub const supervise = @import("supervise.zig");
+pub const threads = @import("threads/root.zig");
 pub const viewer = @import("viewer/root.zig");
 pub const terminal = @import("terminal/root.zig");
 pub const tui = @import("tui/root.zig");
@@ -31,10 +34,13 @@ test {
     _ = domain;
     _ = discover;
     _ = ipc;
+    _ = logging;
     _ = cli;
     _ = modes;
     _ = proc;
     _ = ring;
+    _ = supervise;
+    _ = threads;
     _ = viewer;
     _ = terminal;
     _ = tui;
@@ -0,0 +1,65 @@
+//! Embeddable process supervision.
+//! This root exposes the process controller, its instances, scrollback ring buffers, and lifecycle types without the TUI, IPC, or terminal modules, so other Zig tools can manage processes the way proctmux does.
+
+const std = @import("std");
+const config = @import("config/root.zig");
+const domain = @import("domain/root.zig");
+const proc = @import("proc/root.zig");
+const ring = @import("ring/root.zig");
+
+/// Starts, stops, and restarts processes by ProcessId and keeps each one's
+/// scrollback across restarts. Pass null for the global config unless the
+/// processes rely on `shell_cmd`, `env_loader`, or config-relative `cwd`.
+pub const Controller = proc.controller.Controller;
+pub const Instance = proc.controller.Instance;
+pub const ProcessStats = proc.controller.ProcessStats;
+
+pub const Config = config.schema.Config;
+pub const ProcessConfig = config.schema.ProcessConfig;
+/// Copies `value` into `list` with `allocator`, for building ProcessConfig
+/// lists such as `cmd`, `env`, and hooks in code.
+pub const appendOwned = config.schema.appendOwned;
+
+pub const ProcessId = domain.process.ProcessId;
+pub const ProcessStatus = domain.process.ProcessStatus;
+pub const StatusEvent = domain.process.StatusEvent;
+/// `Controller.changes` is bumped on every start, output write, exit, and
+/// release; wait on it instead of polling.
+pub const ChangeSignal = domain.changes.Signal;
+
+pub const RingBuffer = ring.RingBuffer;
+pub const SnapshotSubscription = ring.SnapshotSubscription;
+pub const WriteNotifier = ring.WriteNotifier;
+
+/// Tracked thread spawning, for checking an embedder's shutdown leaves no
+/// controller thread behind.
+pub const threads = @import("threads/root.zig");
+
+test "supervise runs a process through the public surface" {
+    var proc_cfg = ProcessConfig.empty(std.testing.allocator);
+    defer proc_cfg.deinit(std.testing.allocator);
+    try appendOwned(std.testing.allocator, &proc_cfg.cmd, "sh");
+    try appendOwned(std.testing.allocator, &proc_cfg.cmd, "-c");
+    try appendOwned(std.testing.allocator, &proc_cfg.cmd, "echo supervised; sleep 5");
+    proc_cfg.stop_timeout_ms = 500;
+
+    var controller = Controller.init(std.testing.allocator, null);
+    defer controller.deinit();
+
+    const id = ProcessId.fromInt(1);
+    var seen = controller.changes.current();
+    _ = try controller.startProcess(id, &proc_cfg);
+    try std.testing.expectEqual(ProcessStatus.running, controller.getProcessStatus(id));
+
+    const output = try controller.outputBuffer(id);
+    var attempts: usize = 0;
+    while (attempts < 200) : (attempts += 1) {
+        const bytes = try output.bytes(std.testing.allocator);
+        defer std.testing.allocator.free(bytes);
+        if (std.mem.indexOf(u8, bytes, "supervised") != null) break;
+        seen = controller.changes.wait(seen, 10);
+    } else return error.ExpectedOutput;
+
+    try controller.stopProcess(id);
+    try std.testing.expectEqual(ProcessStatus.halted, controller.getProcessStatus(id));
+}
@@ -0,0 +1,94 @@
+//! Terminal background detection.
+//! Clients pick the light or dark palette from `COLORFGBG` or, failing that, the terminal's answer to an OSC 11 background color query; detection runs once at startup while the terminal is in raw mode.
+
+const std = @import("std");
+
+pub const Background = enum {
+    dark,
+    light,
+};
+
+/// How long to wait for an OSC 11 answer. Terminals that ignore the query
+/// never answer, so this bounds the startup delay they cost.
+const query_timeout_ms = 150;
+
+var detected: ?Background = null;
+
+/// The background found by `detect`, or dark when detection never ran or
+/// found nothing.
+pub fn current() Background {
+    return detected orelse .dark;
+}
+
+/// Detects the background of the terminal on `input`/`output`. Call it with
+/// the terminal in raw mode and before anything else reads `input`.
+pub fn detect(input: std.posix.fd_t, output: std.posix.fd_t) void {
+    detected = fromColorFgBg(std.posix.getenv("COLORFGBG") orelse "") orelse query(input, output);
+}
+
+/// Reads the `fg;bg` palette indexes some terminals export. Indexes 7 and
+/// 9-15 are light backgrounds; anything unparseable, like `default`, is null.
+pub fn fromColorFgBg(value: []const u8) ?Background {
+    const separator = std.mem.lastIndexOfScalar(u8, value, ';') orelse return null;
+    const index = std.fmt.parseUnsigned(u8, value[separator + 1 ..], 10) catch return null;
+    if (index > 15) return null;
+    return if (index == 7 or index >= 9) .light else .dark;
+}
+
+/// Parses an OSC 11 answer such as `\x1b]11;rgb:ffff/ffff/ffff\x1b\\`.
+/// Channels may have one to four hex digits each.
+pub fn fromOsc11(response: []const u8) ?Background {
+    const start = std.mem.indexOf(u8, response, "rgb:") orelse return null;
+    var rest = response[start + "rgb:".len ..];
+    var channels: [3]f32 = undefined;
+    for (&channels) |*channel| {
+        const end = std.mem.indexOfAny(u8, rest, "/\x07\x1b") orelse rest.len;
+        const digits = rest[0..end];
+        if (digits.len == 0 or digits.len > 4) return null;
+        const value = std.fmt.parseUnsigned(u16, digits, 16) catch return null;
+        const max = (@as(u32, 1) << @intCast(digits.len * 4)) - 1;
+        channel.* = @as(f32, @floatFromInt(value)) / @as(f32, @floatFromInt(max));
+        rest = rest[@min(end + 1, rest.len)..];
+    }
+    const luminance = 0.299 * channels[0] + 0.587 * channels[1] + 0.114 * channels[2];
+    return if (luminance > 0.5) .light else .dark;
+}
+
+fn query(input: std.posix.fd_t, output: std.posix.fd_t) ?Background {
+    if (!std.posix.isatty(input) or !std.posix.isatty(output)) return null;
+    _ = std.posix.write(output, "\x1b]11;?\x1b\\") catch return null;
+
+    var response: [64]u8 = undefined;
+    var len: usize = 0;
+    var fds = [_]std.posix.pollfd{.{ .fd = input, .events = std.posix.POLL.IN, .revents = 0 }};
+    const deadline = std.time.milliTimestamp() + query_timeout_ms;
+    while (len < response.len) {
+        const remaining = deadline - std.time.milliTimestamp();
+        if (remaining <= 0) return null;
+        const ready = std.posix.poll(&fds, @intCast(remaining)) catch return null;
+        if (ready == 0) return null;
+        const count = std.posix.read(input, response[len..]) catch return null;
+        if (count == 0) return null;
+        len += count;
+        const answer = response[0..len];
+        if (std.mem.indexOfScalar(u8, answer, 0x07) != null or std.mem.indexOf(u8, answer, "\x1b\\") != null) break;
+    }
+    return fromOsc11(response[0..len]);
+}
+
+test "COLORFGBG picks the background from the last palette index" {
+    try std.testing.expectEqual(Background.dark, fromColorFgBg("15;0").?);
+    try std.testing.expectEqual(Background.light, fromColorFgBg("0;15").?);
+    try std.testing.expectEqual(Background.light, fromColorFgBg("0;default;7").?);
+    try std.testing.expectEqual(@as(?Background, null), fromColorFgBg("15;default"));
+    try std.testing.expectEqual(@as(?Background, null), fromColorFgBg(""));
+}
+
+test "OSC 11 answers are classified by luminance" {
+    try std.testing.expectEqual(Background.light, fromOsc11("\x1b]11;rgb:ffff/ffff/ffff\x1b\\").?);
+    try std.testing.expectEqual(Background.light, fromOsc11("\x1b]11;rgb:fd/f6/e3\x07").?);
+    try std.testing.expectEqual(Background.dark, fromOsc11("\x1b]11;rgb:2828/2a2a/3636\x1b\\").?);
+    try std.testing.expectEqual(Background.dark, fromOsc11("\x1b]11;rgb:0/0/0\x07").?);
+    try std.testing.expectEqual(@as(?Background, null), fromOsc11("\x1b]11;rgb:ffff/ffff\x07"));
+    try std.testing.expectEqual(@as(?Background, null), fromOsc11("garbage"));
+}
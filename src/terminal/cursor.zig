@@ -0,0 +1,62 @@
+//! Cursor visibility and shape tracking for output relays.
+//! Programs hide the cursor (DECTCEM) or change its shape (DECSCUSR) and put it back when they exit, so a relay that switches processes mid-stream re-applies whatever the newly shown process last asked for, or the defaults when it never asked.
+
+const std = @import("std");
+
+/// Shows the cursor and gives it the terminal's default shape.
+pub const reset = "\x1b[?25h\x1b[0 q";
+
+pub const State = struct {
+    visible: bool = true,
+    /// DECSCUSR parameter; 0 is the terminal's default shape.
+    shape: u8 = 0,
+
+    /// Folds every cursor sequence in `bytes` into the state, later ones
+    /// winning. A sequence split at either end of `bytes` is ignored.
+    pub fn scan(self: *State, bytes: []const u8) void {
+        var index: usize = 0;
+        while (std.mem.indexOfPos(u8, bytes, index, "\x1b[")) |start| {
+            index = start + 2;
+            const private = index < bytes.len and bytes[index] == '?';
+            if (private) index += 1;
+            const params_start = index;
+            while (index < bytes.len and (std.ascii.isDigit(bytes[index]) or bytes[index] == ';')) : (index += 1) {}
+            const params = bytes[params_start..index];
+            if (index >= bytes.len) return;
+
+            if (private and (bytes[index] == 'h' or bytes[index] == 'l')) {
+                var modes = std.mem.splitScalar(u8, params, ';');
+                while (modes.next()) |mode| {
+                    if (std.mem.eql(u8, mode, "25")) self.visible = bytes[index] == 'h';
+                }
+            } else if (!private and bytes[index] == ' ' and index + 1 < bytes.len and bytes[index + 1] == 'q') {
+                self.shape = if (params.len == 0) 0 else std.fmt.parseInt(u8, params, 10) catch self.shape;
+            }
+        }
+    }
+
+    /// Sequences that put a terminal's cursor into this state.
+    pub fn sequence(self: State, buffer: []u8) ![]const u8 {
+        const visibility = if (self.visible) "\x1b[?25h" else "\x1b[?25l";
+        return std.fmt.bufPrint(buffer, "{s}\x1b[{d} q", .{ visibility, self.shape });
+    }
+};
+
+/// The cursor state replaying `bytes` leaves a fresh terminal in.
+pub fn after(bytes: []const u8) State {
+    var state = State{};
+    state.scan(bytes);
+    return state;
+}
+
+test "cursor state follows the last visibility and shape sequences" {
+    try std.testing.expectEqual(State{}, after("plain output\n"));
+    try std.testing.expectEqual(State{ .visible = false, .shape = 0 }, after("\x1b[?25lframe"));
+    try std.testing.expectEqual(State{ .visible = true, .shape = 5 }, after("\x1b[?25l\x1b[6 q\x1b[?1049;25h\x1b[5 q"));
+    try std.testing.expectEqual(State{ .visible = true, .shape = 0 }, after("\x1b[2 q\x1b[ q"));
+    try std.testing.expectEqual(State{}, after("\x1b[25l\x1b[?25"));
+
+    var buffer: [32]u8 = undefined;
+    try std.testing.expectEqualStrings("\x1b[?25l\x1b[2 q", try (State{ .visible = false, .shape = 2 }).sequence(&buffer));
+    try std.testing.expectEqualStrings(reset, try (State{}).sequence(&buffer));
+}
@@ -12,16 +12,23 @@ pub const Size = struct {
 };
 
 pub fn fromFds(output_fd: ?std.posix.fd_t, input_fd: ?std.posix.fd_t) Size {
+    return probe(output_fd, input_fd) orelse .{
+        .width = default_terminal_width,
+        .height = default_terminal_height,
+    };
+}
+
+/// Returns the real terminal size, or null when neither fd is a terminal.
+/// Callers that propagate sizes to child PTYs use this to avoid pushing the
+/// rendering fallback onto processes.
+pub fn probe(output_fd: ?std.posix.fd_t, input_fd: ?std.posix.fd_t) ?Size {
     if (output_fd) |fd| {
         if (fromFd(fd)) |size| return size;
     }
     if (input_fd) |fd| {
         if (fromFd(fd)) |size| return size;
     }
-    return .{
-        .width = default_terminal_width,
-        .height = default_terminal_height,
-    };
+    return null;
 }
 
 fn fromFd(fd: std.posix.fd_t) ?Size {
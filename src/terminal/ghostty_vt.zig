@@ -3,6 +3,12 @@
 
 const std = @import("std");
 const vt = @import("ghostty-vt");
+const sanitize = @import("sanitize.zig");
+
+pub const CursorPosition = struct {
+    row: u16,
+    col: u16,
+};
 
 pub const Terminal = struct {
     allocator: std.mem.Allocator,
@@ -12,6 +18,9 @@ pub const Terminal = struct {
         terminal: vt.Terminal,
         stream: vt.TerminalStream,
         render_state: vt.RenderState = .empty,
+        sanitizer: sanitize.Sanitizer = .{},
+        /// Reused for each write's sanitized bytes.
+        sanitized: std.array_list.Managed(u8),
     };
 
     pub fn init(allocator: std.mem.Allocator, cols: u16, rows: u16) !Terminal {
@@ -19,6 +28,9 @@ pub const Terminal = struct {
         errdefer allocator.destroy(inner);
 
         inner.render_state = .empty;
+        inner.sanitizer = .{};
+        inner.sanitized = std.array_list.Managed(u8).init(allocator);
+        errdefer inner.sanitized.deinit();
         inner.terminal = try vt.Terminal.init(allocator, .{
             .cols = @intCast(@max(cols, 1)),
             .rows = @intCast(@max(rows, 1)),
@@ -35,6 +47,7 @@ pub const Terminal = struct {
 
     pub fn deinit(self: *Terminal) void {
         self.inner.render_state.deinit(self.allocator);
+        self.inner.sanitized.deinit();
         self.inner.stream.deinit();
         self.inner.terminal.deinit(self.allocator);
         self.allocator.destroy(self.inner);
@@ -49,30 +62,53 @@ pub const Terminal = struct {
         );
     }
 
+    /// Feeds process output through `sanitize` first, so sequences meant for
+    /// a real terminal never reach the emulator.
     pub fn write(self: *Terminal, bytes: []const u8) !void {
-        self.inner.stream.nextSlice(bytes);
+        self.inner.sanitized.clearRetainingCapacity();
+        try self.inner.sanitizer.feed(&self.inner.sanitized, bytes);
+        self.inner.stream.nextSlice(self.inner.sanitized.items);
+    }
+
+    /// Zero-based cursor position on the active screen, used by relays that
+    /// redraw emulator state and then resume passing raw bytes through.
+    pub fn cursorPosition(self: *const Terminal) CursorPosition {
+        const cursor = self.inner.terminal.screens.active.cursor;
+        return .{ .row = cursor.y, .col = cursor.x };
     }
 
+    /// Visible rows joined by `\n`, each cell run wrapped in its SGR style
+    /// and every styled row ending in a reset.
     pub fn renderText(self: *Terminal, allocator: std.mem.Allocator) ![]const u8 {
         try self.inner.render_state.update(self.allocator, &self.inner.terminal);
-        return renderStateText(allocator, &self.inner.render_state);
+        var out = std.array_list.Managed(u8).init(allocator);
+        errdefer out.deinit();
+        try appendRenderedRows(&out, &self.inner.render_state);
+        return out.toOwnedSlice();
     }
-};
 
-fn renderStateText(allocator: std.mem.Allocator, state: *const vt.RenderState) ![]const u8 {
-    var out = std.array_list.Managed(u8).init(allocator);
-    errdefer out.deinit();
+    /// `renderText` followed by the SGR state the cursor is left with, for
+    /// relays that redraw emulator state and then pass raw bytes through:
+    /// output continuing a colored run keeps its colors.
+    pub fn renderStyled(self: *Terminal, allocator: std.mem.Allocator) ![]const u8 {
+        try self.inner.render_state.update(self.allocator, &self.inner.terminal);
+        var out = std.array_list.Managed(u8).init(allocator);
+        errdefer out.deinit();
+        try appendRenderedRows(&out, &self.inner.render_state);
+        try appendStyleTransition(&out, self.inner.terminal.screens.active.cursor.style);
+        return out.toOwnedSlice();
+    }
+};
 
+fn appendRenderedRows(out: *std.array_list.Managed(u8), state: *const vt.RenderState) !void {
     const row_data = state.row_data.slice();
     const row_cells = row_data.items(.cells);
     const visible_rows = visibleRowCount(row_cells);
 
     for (row_cells[0..visible_rows], 0..) |cells, row_index| {
         if (row_index != 0) try out.append('\n');
-        try appendRenderedRow(&out, cells);
+        try appendRenderedRow(out, cells);
     }
-
-    return out.toOwnedSlice();
 }
 
 fn visibleRowCount(rows: []const std.MultiArrayList(vt.RenderState.Cell)) usize {
@@ -255,6 +291,19 @@ test "ghostty vt preserves ANSI foreground colors in rendered text" {
     try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[32mGreen") != null);
 }
 
+test "ghostty vt styled render ends with the pen the output left set" {
+    var term = try Terminal.init(std.testing.allocator, 40, 3);
+    defer term.deinit();
+
+    try term.write("\x1b[1;31mbold red\r\n\x1b[0;44mblue");
+    const rendered = try term.renderStyled(std.testing.allocator);
+    defer std.testing.allocator.free(rendered);
+
+    try std.testing.expect(std.mem.startsWith(u8, rendered, "\x1b[0m\x1b[1m\x1b[31mbold red\x1b[0m\n"));
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[44mblue") != null);
+    try std.testing.expect(std.mem.endsWith(u8, rendered, "\x1b[0m\x1b[44m"));
+}
+
 test "ghostty vt carriage return replaces current line" {
     var term = try Terminal.init(std.testing.allocator, 20, 3);
     defer term.deinit();
@@ -326,3 +375,23 @@ test "ghostty vt resize updates visible viewport" {
 
     try std.testing.expectEqualStrings("three\nfour", rendered);
 }
+
+test "ghostty vt reports cursor position after output" {
+    var term = try Terminal.init(std.testing.allocator, 20, 4);
+    defer term.deinit();
+
+    try term.write("one\r\ntwo\x1b[1D");
+    try std.testing.expectEqual(CursorPosition{ .row = 1, .col = 2 }, term.cursorPosition());
+}
+
+test "ghostty vt draws around sanitized terminal queries" {
+    var term = try Terminal.init(std.testing.allocator, 20, 3);
+    defer term.deinit();
+
+    try term.write("\x1b[?2026h\x1b[?u\x1b[>1uready\x1b[");
+    try term.write("?2026l done");
+    const rendered = try term.renderText(std.testing.allocator);
+    defer std.testing.allocator.free(rendered);
+
+    try std.testing.expectEqualStrings("ready done", rendered);
+}
@@ -3,6 +3,10 @@
 
 const std = @import("std");
 
+// The panic handler cannot reach the `Mode` owned by a Runtime Mode's stack,
+// so the entered mode is mirrored here for `restoreForPanic`.
+var active: ?Mode = null;
+
 /// Saved terminal mode for restoration after raw input. Holding the original
 /// termios value here makes cleanup explicit at Runtime Mode boundaries.
 pub const Mode = struct {
@@ -32,12 +36,22 @@ pub const Mode = struct {
         raw.cc[@intFromEnum(std.c.V.TIME)] = 0;
 
         std.posix.tcsetattr(fd, .FLUSH, raw) catch return .{ .fd = fd };
-        return .{ .fd = fd, .original = original };
+        const entered = Mode{ .fd = fd, .original = original };
+        active = entered;
+        return entered;
     }
 
     pub fn restore(self: *Mode) void {
         const original = self.original orelse return;
         std.posix.tcsetattr(self.fd, .FLUSH, original) catch {};
         self.original = null;
+        active = null;
     }
 };
+
+/// Restores whatever raw mode is still active. Only the panic handler should
+/// call this; normal exits go through `Mode.restore`.
+pub fn restoreForPanic() void {
+    var mode = active orelse return;
+    mode.restore();
+}
@@ -8,3 +8,8 @@ pub const end_synchronized_update = "\x1b[?2026l";
 pub const begin_frame = "\x1b[H";
 pub const clear_line_tail = "\x1b[K";
 pub const end_frame = "\x1b[J";
+pub const bell = "\x07";
+
+/// Leaves any alternate screen, resets attributes, and shows the cursor, for
+/// handing a terminal back to the shell after an abnormal exit.
+pub const panic_restore = "\x1b[?1049l\x1b[0m" ++ show_cursor;
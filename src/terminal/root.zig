@@ -1,14 +1,24 @@
 //! Terminal subsystem namespace.
-//! Importers use this root for dimensions, raw-mode lifecycle, repaint sequences, and VT rendering adapters.
+//! Importers use this root for background detection, cursor state, dimensions, raw-mode lifecycle, repaint sequences, escape-sequence sanitizing, window titles, resize notification, and VT rendering adapters.
 
+pub const background = @import("background.zig");
+pub const cursor = @import("cursor.zig");
 pub const dimensions = @import("dimensions.zig");
 pub const ghostty_vt = @import("ghostty_vt.zig");
 pub const mode = @import("mode.zig");
 pub const repaint = @import("repaint.zig");
+pub const sanitize = @import("sanitize.zig");
+pub const title = @import("title.zig");
+pub const winch = @import("winch.zig");
 
 test {
+    _ = background;
+    _ = cursor;
     _ = dimensions;
     _ = ghostty_vt;
     _ = mode;
     _ = repaint;
+    _ = sanitize;
+    _ = title;
+    _ = winch;
 }
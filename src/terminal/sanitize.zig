@@ -0,0 +1,228 @@
+//! Escape-sequence sanitizing in front of the VT emulator.
+//! Processes print some sequences for the real terminal rather than the screen: queries that wait for a reply, keyboard protocol switches, and synchronized-output brackets. The emulator only draws, so a table strips or translates those before it sees them. Private sequences missing from the table pass through and are logged once each, which is how new entries get found.
+
+const std = @import("std");
+
+const log = std.log.scoped(.terminal);
+
+/// Longest CSI sequence held back while it is classified; longer ones pass
+/// through untouched.
+const max_held = 32;
+/// Distinct unknown sequences logged per sanitizer.
+const max_logged = 16;
+
+pub const Action = enum {
+    /// Keep the sequence as it is.
+    pass,
+    /// Drop the whole sequence.
+    strip,
+    /// Drop the modes in `dropped_modes` from a mode set or reset, and the
+    /// sequence itself when none are left.
+    drop_modes,
+};
+
+/// What a CSI sequence does, apart from its numeric parameters.
+pub const Shape = struct {
+    /// Private marker before the parameters (`<`, `=`, `>`, `?`), or 0.
+    prefix: u8 = 0,
+    /// Intermediate byte before the final byte, such as `$` or a space, or 0.
+    intermediate: u8 = 0,
+    final: u8,
+};
+
+pub const Rule = struct {
+    shape: Shape,
+    action: Action,
+    what: []const u8,
+};
+
+/// Only sequences with a private marker or an intermediate byte are looked
+/// up; plain CSI sequences always go to the emulator.
+pub const rules = [_]Rule{
+    .{ .shape = .{ .prefix = '?', .final = 'u' }, .action = .strip, .what = "kitty keyboard query" },
+    .{ .shape = .{ .prefix = '>', .final = 'u' }, .action = .strip, .what = "kitty keyboard push" },
+    .{ .shape = .{ .prefix = '<', .final = 'u' }, .action = .strip, .what = "kitty keyboard pop" },
+    .{ .shape = .{ .prefix = '=', .final = 'u' }, .action = .strip, .what = "kitty keyboard set" },
+    .{ .shape = .{ .prefix = '?', .intermediate = '$', .final = 'p' }, .action = .strip, .what = "DEC mode query (DECRQM)" },
+    .{ .shape = .{ .intermediate = '$', .final = 'p' }, .action = .strip, .what = "ANSI mode query (DECRQM)" },
+    .{ .shape = .{ .prefix = '>', .final = 'q' }, .action = .strip, .what = "terminal version query (XTVERSION)" },
+    .{ .shape = .{ .prefix = '>', .final = 'c' }, .action = .strip, .what = "secondary device attributes query" },
+    .{ .shape = .{ .prefix = '=', .final = 'c' }, .action = .strip, .what = "tertiary device attributes query" },
+    .{ .shape = .{ .prefix = '>', .final = 'm' }, .action = .strip, .what = "modifyOtherKeys set (XTMODKEYS)" },
+    .{ .shape = .{ .prefix = '>', .final = 'n' }, .action = .strip, .what = "modifyOtherKeys reset" },
+    .{ .shape = .{ .prefix = '?', .final = 'h' }, .action = .drop_modes, .what = "DEC private mode set" },
+    .{ .shape = .{ .prefix = '?', .final = 'l' }, .action = .drop_modes, .what = "DEC private mode reset" },
+    .{ .shape = .{ .prefix = '?', .final = 'J' }, .action = .pass, .what = "selective erase in display" },
+    .{ .shape = .{ .prefix = '?', .final = 'K' }, .action = .pass, .what = "selective erase in line" },
+    .{ .shape = .{ .prefix = '?', .final = 's' }, .action = .pass, .what = "save DEC private modes" },
+    .{ .shape = .{ .prefix = '?', .final = 'r' }, .action = .pass, .what = "restore DEC private modes" },
+    .{ .shape = .{ .prefix = '?', .final = 'n' }, .action = .pass, .what = "DEC device status report" },
+    .{ .shape = .{ .intermediate = ' ', .final = 'q' }, .action = .pass, .what = "cursor shape (DECSCUSR)" },
+    .{ .shape = .{ .intermediate = '!', .final = 'p' }, .action = .pass, .what = "soft reset (DECSTR)" },
+    .{ .shape = .{ .intermediate = '"', .final = 'q' }, .action = .pass, .what = "character protection (DECSCA)" },
+};
+
+/// Synchronized output (2026) brackets frames for a real terminal. Panes
+/// already draw whole frames, and a process killed inside a bracket must not
+/// leave the emulator holding output back.
+pub const dropped_modes = [_][]const u8{"2026"};
+
+/// Rewrites a byte stream for the emulator. A sequence can span two chunks,
+/// so its start is held back until the final byte shows what it is. Owned by
+/// one emulator.
+pub const Sanitizer = struct {
+    state: State = .ground,
+    held: [max_held]u8 = undefined,
+    held_len: usize = 0,
+    logged: [max_logged]Shape = undefined,
+    logged_len: usize = 0,
+
+    const State = enum {
+        ground,
+        /// Holding an `ESC`.
+        escape,
+        /// Holding `ESC [` and the parameter and intermediate bytes after it.
+        csi,
+    };
+
+    /// Appends `bytes` to `out` with problem sequences stripped or translated.
+    pub fn feed(self: *Sanitizer, out: *std.array_list.Managed(u8), bytes: []const u8) !void {
+        for (bytes) |byte| {
+            switch (self.state) {
+                .ground => try self.ground(out, byte),
+                .escape => if (byte == '[') {
+                    self.hold(byte);
+                    self.state = .csi;
+                } else {
+                    try self.release(out);
+                    try self.ground(out, byte);
+                },
+                .csi => if (byte >= 0x40 and byte <= 0x7e) {
+                    try self.finish(out, byte);
+                } else if (byte >= 0x20 and byte <= 0x3f and self.held_len < max_held) {
+                    self.hold(byte);
+                } else {
+                    try self.release(out);
+                    try self.ground(out, byte);
+                },
+            }
+        }
+    }
+
+    fn ground(self: *Sanitizer, out: *std.array_list.Managed(u8), byte: u8) !void {
+        if (byte == 0x1b) {
+            self.hold(byte);
+            self.state = .escape;
+            return;
+        }
+        try out.append(byte);
+    }
+
+    fn hold(self: *Sanitizer, byte: u8) void {
+        self.held[self.held_len] = byte;
+        self.held_len += 1;
+    }
+
+    fn release(self: *Sanitizer, out: *std.array_list.Managed(u8)) !void {
+        try out.appendSlice(self.held[0..self.held_len]);
+        self.held_len = 0;
+        self.state = .ground;
+    }
+
+    fn finish(self: *Sanitizer, out: *std.array_list.Managed(u8), final: u8) !void {
+        self.hold(final);
+        const sequence = self.held[0..self.held_len];
+        const body = sequence[2 .. sequence.len - 1];
+        const shape = shapeOf(body, final);
+        if (shape.prefix == 0 and shape.intermediate == 0) return self.release(out);
+
+        const action = if (ruleFor(shape)) |rule| rule.action else blk: {
+            self.logUnknown(shape, sequence);
+            break :blk .pass;
+        };
+        switch (action) {
+            .pass => try out.appendSlice(sequence),
+            .strip => {},
+            .drop_modes => try appendKeptModes(out, shape, body[1..]),
+        }
+        self.held_len = 0;
+        self.state = .ground;
+    }
+
+    fn logUnknown(self: *Sanitizer, shape: Shape, sequence: []const u8) void {
+        for (self.logged[0..self.logged_len]) |logged| {
+            if (std.meta.eql(logged, shape)) return;
+        }
+        if (self.logged_len == max_logged) return;
+        self.logged[self.logged_len] = shape;
+        self.logged_len += 1;
+        log.debug("passing unrecognized escape sequence ESC{s} to the emulator", .{sequence[1..]});
+    }
+};
+
+fn shapeOf(body: []const u8, final: u8) Shape {
+    var shape = Shape{ .final = final };
+    if (body.len > 0 and body[0] >= '<' and body[0] <= '?') shape.prefix = body[0];
+    if (body.len > 0 and body[body.len - 1] >= 0x20 and body[body.len - 1] <= 0x2f) shape.intermediate = body[body.len - 1];
+    return shape;
+}
+
+pub fn ruleFor(shape: Shape) ?Rule {
+    for (rules) |rule| {
+        if (std.meta.eql(rule.shape, shape)) return rule;
+    }
+    return null;
+}
+
+/// Re-emits a private mode set or reset without the dropped modes.
+fn appendKeptModes(out: *std.array_list.Managed(u8), shape: Shape, params: []const u8) !void {
+    const start = out.items.len;
+    try out.appendSlice("\x1b[?");
+    var kept: usize = 0;
+    var modes = std.mem.splitScalar(u8, params, ';');
+    while (modes.next()) |mode| {
+        if (isDropped(mode)) continue;
+        if (kept > 0) try out.append(';');
+        try out.appendSlice(mode);
+        kept += 1;
+    }
+    if (kept == 0) {
+        out.shrinkRetainingCapacity(start);
+        return;
+    }
+    try out.append(shape.final);
+}
+
+fn isDropped(mode: []const u8) bool {
+    for (dropped_modes) |dropped| {
+        if (std.mem.eql(u8, mode, dropped)) return true;
+    }
+    return false;
+}
+
+test "sanitizer strips terminal queries and synchronized output" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var sanitizer = Sanitizer{};
+    try sanitizer.feed(&out, "a\x1b[?u\x1b[>1ub\x1b[<u\x1b[?2026$p\x1b[>qc\x1b[?2026hframe\x1b[?2026l");
+    try std.testing.expectEqualStrings("abcframe", out.items);
+
+    out.clearRetainingCapacity();
+    try sanitizer.feed(&out, "\x1b[?1049;2026h\x1b[31mred\x1b[0m\x1b[2 q\x1b[?25l\x1b]8;;x\x1b\\");
+    try std.testing.expectEqualStrings("\x1b[?1049h\x1b[31mred\x1b[0m\x1b[2 q\x1b[?25l\x1b]8;;x\x1b\\", out.items);
+}
+
+test "sanitizer holds a split sequence and passes unknown ones once logged" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var sanitizer = Sanitizer{};
+    try sanitizer.feed(&out, "one\x1b");
+    try sanitizer.feed(&out, "[?");
+    try std.testing.expectEqualStrings("one", out.items);
+    try sanitizer.feed(&out, "u\x1b[>4;1mtwo\x1b[?5W\x1b[?7W");
+    try std.testing.expectEqualStrings("onetwo\x1b[?5W\x1b[?7W", out.items);
+    try std.testing.expectEqual(@as(usize, 1), sanitizer.logged_len);
+    try std.testing.expect(ruleFor(.{ .prefix = '?', .final = 'W' }) == null);
+}
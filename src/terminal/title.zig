@@ -0,0 +1,132 @@
+//! Window titles for output relays.
+//! Child processes set the window title with OSC 0, 1, and 2, which would rename whatever terminal or tmux pane hosts the relay. Relays drop those and title the host after the selected process instead; other OSC strings, such as hyperlinks, pass through.
+
+const std = @import("std");
+
+pub const prefix = "proctmux";
+/// Room for `ESC ]` and an OSC number; longer numbers are not titles.
+const max_held = 8;
+
+/// Title sequence naming `label`, or plain `proctmux` when it is empty.
+/// Control characters in the label are left out.
+pub fn set(out: *std.array_list.Managed(u8), label: []const u8) !void {
+    try out.appendSlice("\x1b]2;" ++ prefix);
+    if (label.len > 0) {
+        try out.appendSlice(": ");
+        for (label) |byte| {
+            if (!std.ascii.isControl(byte)) try out.append(byte);
+        }
+    }
+    try out.append(0x07);
+}
+
+/// Strips title sequences from a byte stream. A sequence can span two chunks,
+/// so its start is held back until the OSC number shows what it is. Owned by
+/// one relay; `reset` when it switches to another stream.
+pub const Filter = struct {
+    state: State = .ground,
+    held: [max_held]u8 = undefined,
+    held_len: usize = 0,
+
+    const State = enum {
+        ground,
+        /// Holding an `ESC`.
+        escape,
+        /// Holding `ESC ]` and the digits after it.
+        osc_number,
+        /// Dropping a title until BEL or `ESC \`.
+        title,
+        /// Saw `ESC` inside a title; `\` ends it.
+        title_escape,
+    };
+
+    pub fn reset(self: *Filter) void {
+        self.* = .{};
+    }
+
+    /// Appends `bytes` to `out` without title sequences.
+    pub fn strip(self: *Filter, out: *std.array_list.Managed(u8), bytes: []const u8) !void {
+        for (bytes) |byte| {
+            switch (self.state) {
+                .ground => try self.ground(out, byte),
+                .escape => if (byte == ']') {
+                    self.hold(byte);
+                    self.state = .osc_number;
+                } else {
+                    try self.release(out);
+                    try self.ground(out, byte);
+                },
+                .osc_number => if (std.ascii.isDigit(byte) and self.held_len < max_held) {
+                    self.hold(byte);
+                } else if (byte == ';' and self.isTitle()) {
+                    self.held_len = 0;
+                    self.state = .title;
+                } else {
+                    try self.release(out);
+                    try self.ground(out, byte);
+                },
+                .title => switch (byte) {
+                    0x07 => self.state = .ground,
+                    0x1b => self.state = .title_escape,
+                    else => {},
+                },
+                // Anything but `\` keeps the string open, as xterm does.
+                .title_escape => self.state = if (byte == '\\') .ground else if (byte == 0x1b) .title_escape else .title,
+            }
+        }
+    }
+
+    fn ground(self: *Filter, out: *std.array_list.Managed(u8), byte: u8) !void {
+        if (byte == 0x1b) {
+            self.hold(byte);
+            self.state = .escape;
+            return;
+        }
+        try out.append(byte);
+        self.state = .ground;
+    }
+
+    fn hold(self: *Filter, byte: u8) void {
+        self.held[self.held_len] = byte;
+        self.held_len += 1;
+    }
+
+    fn release(self: *Filter, out: *std.array_list.Managed(u8)) !void {
+        try out.appendSlice(self.held[0..self.held_len]);
+        self.held_len = 0;
+        self.state = .ground;
+    }
+
+    /// OSC 0, 1, and 2 set the window title, the icon name, or both.
+    fn isTitle(self: *const Filter) bool {
+        const number = self.held[2..self.held_len];
+        return number.len == 1 and number[0] >= '0' and number[0] <= '2';
+    }
+};
+
+test "title filter drops OSC titles and keeps other sequences" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var filter = Filter{};
+    try filter.strip(&out, "a\x1b]0;vim\x07b\x1b]2;make\x1b\\c\x1b]8;;https://x\x1b\\link\x1b[1md");
+    try std.testing.expectEqualStrings("abc\x1b]8;;https://x\x1b\\link\x1b[1md", out.items);
+}
+
+test "title filter holds a split sequence until it is known" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var filter = Filter{};
+    try filter.strip(&out, "one\x1b");
+    try std.testing.expectEqualStrings("one", out.items);
+    try filter.strip(&out, "]2");
+    try filter.strip(&out, ";title\x07two\x1b]");
+    try std.testing.expectEqualStrings("onetwo", out.items);
+    try filter.strip(&out, "52;c;eA==\x07");
+    try std.testing.expectEqualStrings("onetwo\x1b]52;c;eA==\x07", out.items);
+
+    out.clearRetainingCapacity();
+    try set(&out, "api\x1b");
+    try std.testing.expectEqualStrings("\x1b]2;proctmux: api\x07", out.items);
+}
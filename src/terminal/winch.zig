@@ -0,0 +1,44 @@
+//! Terminal resize notification.
+//! The SIGWINCH handler only raises a flag and optionally writes one byte to a wake fd; runtime loops then probe sizes and resize PTYs outside signal context.
+
+const std = @import("std");
+
+var pending = std.atomic.Value(bool).init(false);
+var wake_fd = std.atomic.Value(std.posix.fd_t).init(-1);
+
+/// Installs the process-wide SIGWINCH handler. Installing more than once is
+/// harmless because the handler only stores to a shared flag.
+pub fn install() void {
+    const action = std.posix.Sigaction{
+        .handler = .{ .handler = handle },
+        .mask = std.posix.sigemptyset(),
+        .flags = std.posix.SA.RESTART,
+    };
+    std.posix.sigaction(std.posix.SIG.WINCH, &action, null);
+}
+
+/// Reports whether a resize arrived since the last call and clears the flag.
+pub fn takePending() bool {
+    return pending.swap(false, .seq_cst);
+}
+
+/// Makes each resize also write a byte to `fd`, typically a non-blocking
+/// pipe a loop polls on; null stops it.
+pub fn setWakeFd(fd: ?std.posix.fd_t) void {
+    wake_fd.store(fd orelse -1, .seq_cst);
+}
+
+fn handle(_: i32) callconv(.c) void {
+    pending.store(true, .seq_cst);
+    const fd = wake_fd.load(.seq_cst);
+    if (fd >= 0) _ = std.posix.system.write(fd, "w", 1);
+}
+
+test "winch flag is consumed once per signal" {
+    install();
+    _ = takePending();
+
+    try std.posix.raise(std.posix.SIG.WINCH);
+    try std.testing.expect(takePending());
+    try std.testing.expect(!takePending());
+}
@@ -0,0 +1,149 @@
+//! Tracked runtime threads.
+//! Every long-lived runtime thread is spawned through here so tests can check that stopping a server, controller, or broadcaster leaves no thread behind; `Poller` is the periodic thread the Primary Server's monitors share.
+
+const std = @import("std");
+
+var live_count = std.atomic.Value(u32).init(0);
+
+/// `std.Thread.spawn` that counts the thread in `live` until `function`
+/// returns. The caller still owns joining it.
+pub fn spawn(spawn_config: std.Thread.SpawnConfig, comptime function: anytype, args: anytype) std.Thread.SpawnError!std.Thread {
+    _ = live_count.fetchAdd(1, .seq_cst);
+    errdefer _ = live_count.fetchSub(1, .seq_cst);
+    return std.Thread.spawn(spawn_config, Tracked(function, @TypeOf(args)).run, .{args});
+}
+
+/// Threads spawned through `spawn` whose function has not yet returned.
+pub fn live() u32 {
+    return live_count.load(.seq_cst);
+}
+
+/// A tracked thread that calls a poll function until `stop`, sleeping between
+/// calls on an event `stop` sets, so stopping never waits out a full interval.
+pub const Poller = struct {
+    stopped: std.Thread.ResetEvent = .{},
+    thread: ?std.Thread = null,
+
+    /// Calls `poll(context, now_ms)` every `interval_ms`. Does nothing while
+    /// already running.
+    pub fn start(
+        self: *Poller,
+        context: anytype,
+        comptime poll: fn (@TypeOf(context), i64) void,
+        interval_ms: u64,
+    ) std.Thread.SpawnError!void {
+        if (self.thread != null) return;
+        self.stopped.reset();
+        self.thread = try spawn(.{}, Every(@TypeOf(context), poll).run, .{ self, context, interval_ms });
+    }
+
+    /// Like `start`, but `poll` returns how long to wait before the next
+    /// call, or null to end the thread.
+    pub fn startScheduled(
+        self: *Poller,
+        context: anytype,
+        comptime poll: fn (@TypeOf(context), i64) ?i64,
+    ) std.Thread.SpawnError!void {
+        if (self.thread != null) return;
+        self.stopped.reset();
+        self.thread = try spawn(.{}, Scheduled(@TypeOf(context), poll).run, .{ self, context });
+    }
+
+    pub fn isRunning(self: *const Poller) bool {
+        return self.thread != null;
+    }
+
+    /// Wakes the thread and joins it.
+    pub fn stop(self: *Poller) void {
+        const thread = self.thread orelse return;
+        self.stopped.set();
+        thread.join();
+        self.thread = null;
+    }
+
+    fn Every(comptime Context: type, comptime poll: fn (Context, i64) void) type {
+        return struct {
+            fn run(poller: *Poller, context: Context, interval_ms: u64) void {
+                while (!poller.stopped.isSet()) {
+                    poll(context, std.time.milliTimestamp());
+                    poller.stopped.timedWait(interval_ms * std.time.ns_per_ms) catch {};
+                }
+            }
+        };
+    }
+
+    fn Scheduled(comptime Context: type, comptime poll: fn (Context, i64) ?i64) type {
+        return struct {
+            fn run(poller: *Poller, context: Context) void {
+                while (!poller.stopped.isSet()) {
+                    const wait_ms = poll(context, std.time.milliTimestamp()) orelse return;
+                    poller.stopped.timedWait(@as(u64, @intCast(@max(wait_ms, 0))) * std.time.ns_per_ms) catch {};
+                }
+            }
+        };
+    }
+};
+
+fn Tracked(comptime function: anytype, comptime Args: type) type {
+    const Return = @typeInfo(@TypeOf(function)).@"fn".return_type.?;
+    return struct {
+        fn run(args: Args) Return {
+            defer _ = live_count.fetchSub(1, .seq_cst);
+            return @call(.auto, function, args);
+        }
+    };
+}
+
+test "tracked threads count until their function returns" {
+    const baseline = live();
+    var release = std.Thread.ResetEvent{};
+
+    const Worker = struct {
+        fn run(event: *std.Thread.ResetEvent) void {
+            event.wait();
+        }
+
+        fn fail() !void {
+            return error.Expected;
+        }
+    };
+    const thread = try spawn(.{}, Worker.run, .{&release});
+    try std.testing.expectEqual(baseline + 1, live());
+    release.set();
+    thread.join();
+    try std.testing.expectEqual(baseline, live());
+
+    const failing = try spawn(.{}, Worker.fail, .{});
+    failing.join();
+    try std.testing.expectEqual(baseline, live());
+}
+
+test "pollers call until stopped or until a scheduled poll ends them" {
+    const baseline = live();
+    const Counter = struct {
+        calls: std.atomic.Value(u32) = std.atomic.Value(u32).init(0),
+
+        fn poll(self: *@This(), _: i64) void {
+            _ = self.calls.fetchAdd(1, .seq_cst);
+        }
+
+        fn pollTwice(self: *@This(), _: i64) ?i64 {
+            return if (self.calls.fetchAdd(1, .seq_cst) == 0) 0 else null;
+        }
+    };
+
+    var counter = Counter{};
+    var poller = Poller{};
+    try poller.start(&counter, Counter.poll, 60_000);
+    try std.testing.expect(poller.isRunning());
+    while (counter.calls.load(.seq_cst) == 0) std.Thread.yield() catch {};
+    poller.stop();
+    try std.testing.expect(!poller.isRunning());
+    try std.testing.expectEqual(@as(u32, 1), counter.calls.load(.seq_cst));
+
+    var scheduled_counter = Counter{};
+    var scheduled = Poller{};
+    try scheduled.startScheduled(&scheduled_counter, Counter.pollTwice);
+    scheduled.stop();
+    try std.testing.expectEqual(baseline, live());
+}
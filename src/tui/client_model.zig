@@ -12,12 +12,102 @@ const test_config = @import("../test_support/config.zig");
 pub const CommandIntent = struct {
     action: ipc.protocol.Command,
     label: []const u8,
+    /// Marked processes a batch command applies to instead of `label`;
+    /// borrowed from the model.
+    labels: []const []const u8 = &.{},
 };
 
 pub const message_timeout_ms: i64 = 5000;
 
+/// Messages kept for the history overlay; older ones are dropped.
+pub const max_message_history = 100;
+
+/// How long after autostart settles its summary stays on screen.
+pub const startup_panel_ms: i64 = 15_000;
+
+pub const Severity = enum {
+    info,
+    warn,
+    @"error",
+};
+
+const SeenOutput = struct {
+    last_output_ms: i64,
+    error_count: u32,
+    bells: u32,
+    /// Bells in the latest snapshot, selected or not, so each new one rings
+    /// once.
+    heard_bells: u32,
+
+    fn of(summary: domain.client_snapshot.ProcessSummary) SeenOutput {
+        return .{
+            .last_output_ms = summary.last_output_ms,
+            .error_count = summary.error_count,
+            .bells = summary.bells,
+            .heard_bells = summary.bells,
+        };
+    }
+};
+
+/// The environment inspector's contents: a process's environment as the
+/// primary would start it, owned by the model while the overlay is open.
+pub const EnvView = struct {
+    label: []const u8,
+    variables: []ipc.protocol.EnvVariable,
+    /// Rows the overlay is scrolled down from the first variable.
+    offset: usize = 0,
+
+    fn init(allocator: std.mem.Allocator, label: []const u8, variables: []const ipc.protocol.EnvVariable) !EnvView {
+        const owned = try allocator.alloc(ipc.protocol.EnvVariable, variables.len);
+        var copied: usize = 0;
+        errdefer {
+            for (owned[0..copied]) |variable| freeVariable(allocator, variable);
+            allocator.free(owned);
+        }
+        for (variables) |variable| {
+            owned[copied] = try dupeVariable(allocator, variable);
+            copied += 1;
+        }
+        return .{ .label = try allocator.dupe(u8, label), .variables = owned };
+    }
+
+    fn deinit(self: EnvView, allocator: std.mem.Allocator) void {
+        for (self.variables) |variable| freeVariable(allocator, variable);
+        allocator.free(self.variables);
+        allocator.free(self.label);
+    }
+
+    fn dupeVariable(allocator: std.mem.Allocator, variable: ipc.protocol.EnvVariable) !ipc.protocol.EnvVariable {
+        const name = try allocator.dupe(u8, variable.name);
+        errdefer allocator.free(name);
+        const value = try allocator.dupe(u8, variable.value);
+        errdefer allocator.free(value);
+        const source = try allocator.dupe(u8, variable.source);
+        errdefer allocator.free(source);
+        const overridden = if (variable.overridden) |own| try allocator.dupe(u8, own) else null;
+        return .{ .name = name, .value = value, .source = source, .overridden = overridden };
+    }
+
+    fn freeVariable(allocator: std.mem.Allocator, variable: ipc.protocol.EnvVariable) void {
+        allocator.free(variable.name);
+        allocator.free(variable.value);
+        allocator.free(variable.source);
+        if (variable.overridden) |own| allocator.free(own);
+    }
+};
+
+/// Detail of the selected process a `copy_*` key puts on the clipboard.
+pub const CopyTarget = enum {
+    command,
+    pid,
+    cwd,
+    output,
+};
+
 pub const TimedMessage = struct {
+    severity: Severity = .info,
     text: []const u8,
+    created_ms: i64 = 0,
     expires_at_ms: i64,
 };
 
@@ -29,15 +119,70 @@ pub const ClientModel = struct {
     filtered_processes: []domain.client_snapshot.ProcessSummary,
     filter_text: std.array_list.Managed(u8),
     messages: std.array_list.Managed(TimedMessage),
+    /// Every message, oldest first, outliving the panel's timeout so the
+    /// history overlay can show what scrolled by.
+    message_history: std.array_list.Managed(TimedMessage),
+    show_history: bool = false,
+    /// Rows the history overlay is scrolled down from the newest message.
+    history_offset: usize = 0,
     entering_filter_text: bool = false,
     show_only_running: bool = false,
+    /// Index into the snapshot's `views` of the quick view last selected;
+    /// cleared once the filter or running-only toggle is changed by hand.
+    active_view: ?usize = null,
+    /// Runtime process list order; unified mode restores it from UI state.
+    sort_mode: domain.client_snapshot.SortMode = .config,
+    /// Owned labels of pinned processes, in the order they were pinned.
+    pinned: std.array_list.Managed([]const u8),
+    /// How many entries at the front of `filtered_processes` are pinned.
+    pinned_count: usize = 0,
+    /// Set when `toggle_pin` changes `pinned`, until `takePinsChanged`.
+    pins_changed: bool = false,
+    /// Owned labels marked with `toggle_mark`; while any are marked, start,
+    /// stop, and restart apply to all of them.
+    marked: std.array_list.Managed([]const u8),
+    /// Output, error, and bell counts seen per process id while it was
+    /// selected, or when the process first appeared; anything newer is unread.
+    seen_output: std.AutoHashMap(u32, SeenOutput),
+    /// Set when a snapshot brings a bell to ring, until `takeBell`.
+    bell_pending: bool = false,
+    /// Whether the output pane passes the selected process's bells through to
+    /// the terminal itself. Cleared by unified mode, which draws the pane
+    /// through an emulator.
+    output_pane_rings: bool = true,
+    /// Set by a `copy_*` key with a process selected, until `takeCopyRequest`.
+    copy_request: ?CopyTarget = null,
+    /// Set by `show_env` with a process selected, until `takeEnvRequest`.
+    env_request: bool = false,
+    /// Open while the environment inspector shows a process's environment.
+    env_view: ?EnvView = null,
+    /// Open while `general.on_quit: ask` waits for stop, detach, or cancel.
+    quit_prompt: bool = false,
+    /// Set when the user quits without stopping processes, until `takeDetach`.
+    detach_requested: bool = false,
+    /// Cleared by unified mode, whose primary exits with the client.
+    can_detach: bool = true,
+    /// Set by `esc` to hide the startup summary before it times out.
+    startup_dismissed: bool = false,
     show_help: bool = false,
     mode: domain.state.Mode = .normal,
     active_proc_id: domain.process.ProcessId = .none,
     term_width: usize = 80,
     term_height: usize = 0,
     no_color: bool = false,
+    /// Whether this client's terminal has a light background; only consulted
+    /// when the config's `background` is `auto`.
+    light_terminal: bool = false,
+    /// Set for terminals that cannot draw the configured glyphs, which then
+    /// get the ASCII icon preset.
+    ascii_icons: bool = false,
     show_panel_headers: bool = false,
+    /// Set while the client mode is reconnecting to a restarted primary; the
+    /// snapshot shown is the last one received before the connection dropped.
+    disconnected: bool = false,
+    /// Seconds since the primary last answered, once it has missed a
+    /// heartbeat; 0 while the connection is healthy.
+    connection_stale_s: u32 = 0,
 
     pub fn init(
         allocator: std.mem.Allocator,
@@ -48,38 +193,194 @@ pub const ClientModel = struct {
             .snapshot = snapshot,
             .filtered_processes = try allocator.alloc(domain.client_snapshot.ProcessSummary, 0),
             .filter_text = std.array_list.Managed(u8).init(allocator),
+            .pinned = std.array_list.Managed([]const u8).init(allocator),
+            .marked = std.array_list.Managed([]const u8).init(allocator),
+            .seen_output = std.AutoHashMap(u32, SeenOutput).init(allocator),
             .messages = std.array_list.Managed(TimedMessage).init(allocator),
+            .message_history = std.array_list.Managed(TimedMessage).init(allocator),
             .active_proc_id = snapshot.currentProcessId(),
         };
         errdefer model.deinit();
         try model.rebuildProcessList();
+        try model.recordSeenOutput();
         return model;
     }
 
     pub fn deinit(self: *ClientModel) void {
         self.allocator.free(self.filtered_processes);
         self.filter_text.deinit();
+        self.clearPinned();
+        self.pinned.deinit();
+        self.clearMarked();
+        self.marked.deinit();
+        self.seen_output.deinit();
         for (self.messages.items) |message_entry| self.allocator.free(message_entry.text);
         self.messages.deinit();
+        for (self.message_history.items) |message_entry| self.allocator.free(message_entry.text);
+        self.message_history.deinit();
+        self.closeEnv();
     }
 
     pub fn filterText(self: *const ClientModel) []const u8 {
         return self.filter_text.items;
     }
 
-    pub fn addMessage(self: *ClientModel, text: []const u8) !void {
-        try self.addMessageAt(text, std.time.milliTimestamp());
+    pub fn activeView(self: *const ClientModel) ?domain.client_snapshot.ViewConfig {
+        const index = self.active_view orelse return null;
+        if (index >= self.snapshot.ui.views.len) return null;
+        return self.snapshot.ui.views[index];
+    }
+
+    /// Reorders the visible list without moving the selection.
+    pub fn setSortMode(self: *ClientModel, mode: domain.client_snapshot.SortMode) !void {
+        self.sort_mode = mode;
+        try self.rebuildProcessList();
+    }
+
+    pub fn isPinned(self: *const ClientModel, label: []const u8) bool {
+        return indexOfLabel(self.pinned.items, label) != null;
+    }
+
+    pub fn pinnedLabels(self: *const ClientModel) []const []const u8 {
+        return self.pinned.items;
+    }
+
+    /// Replaces the pinned set, e.g. with labels saved by an earlier session.
+    pub fn setPinned(self: *ClientModel, labels: []const []const u8) !void {
+        self.clearPinned();
+        for (labels) |label| {
+            if (self.isPinned(label)) continue;
+            const owned = try self.allocator.dupe(u8, label);
+            errdefer self.allocator.free(owned);
+            try self.pinned.append(owned);
+        }
+        try self.rebuildProcessList();
+    }
+
+    /// Reports whether `toggle_pin` changed the pinned set since the last
+    /// call, so the session knows when to save it.
+    pub fn takePinsChanged(self: *ClientModel) bool {
+        defer self.pins_changed = false;
+        return self.pins_changed;
+    }
+
+    pub fn isMarked(self: *const ClientModel, label: []const u8) bool {
+        return indexOfLabel(self.marked.items, label) != null;
+    }
+
+    pub fn markedCount(self: *const ClientModel) usize {
+        return self.marked.items.len;
+    }
+
+    pub fn clearMarked(self: *ClientModel) void {
+        for (self.marked.items) |label| self.allocator.free(label);
+        self.marked.clearRetainingCapacity();
+    }
+
+    /// What `quit` does; `stop` wherever detaching is not possible.
+    pub fn quitAction(self: *const ClientModel) config.schema.QuitAction {
+        if (!self.can_detach) return .stop;
+        return std.meta.stringToEnum(config.schema.QuitAction, self.snapshot.ui.on_quit) orelse .stop;
+    }
+
+    /// Reports whether the user chose to detach, leaving processes running
+    /// under the primary.
+    pub fn takeDetach(self: *ClientModel) bool {
+        defer self.detach_requested = false;
+        return self.detach_requested;
+    }
+
+    pub fn bellAction(self: *const ClientModel) config.schema.BellAction {
+        return std.meta.stringToEnum(config.schema.BellAction, self.snapshot.ui.on_bell) orelse .ring;
+    }
+
+    /// Reports whether the terminal bell should ring with the next frame.
+    pub fn takeBell(self: *ClientModel) bool {
+        defer self.bell_pending = false;
+        return self.bell_pending;
+    }
+
+    /// The copy the session should perform for the selected process, once.
+    pub fn takeCopyRequest(self: *ClientModel) ?CopyTarget {
+        defer self.copy_request = null;
+        return self.copy_request;
+    }
+
+    /// Reports whether the session should fetch the selected process's
+    /// environment for the inspector, once.
+    pub fn takeEnvRequest(self: *ClientModel) bool {
+        defer self.env_request = false;
+        return self.env_request;
+    }
+
+    /// Opens the environment inspector on a copy of `variables`.
+    pub fn openEnv(self: *ClientModel, label: []const u8, variables: []const ipc.protocol.EnvVariable) !void {
+        const view = try EnvView.init(self.allocator, label, variables);
+        self.closeEnv();
+        self.env_view = view;
+    }
+
+    pub fn closeEnv(self: *ClientModel) void {
+        if (self.env_view) |view| view.deinit(self.allocator);
+        self.env_view = null;
+    }
+
+    pub fn runningCount(self: *const ClientModel) usize {
+        var count: usize = 0;
+        for (self.snapshot.processes) |summary| {
+            if (summary.status == .running) count += 1;
+        }
+        return count;
+    }
+
+    /// The primary's autostart summary while it is recent and not dismissed.
+    pub fn startupSummary(self: *const ClientModel, now_ms: i64) ?domain.client_snapshot.StartupSummary {
+        const summary = self.snapshot.startup;
+        if (self.startup_dismissed or summary.settled_ms == 0) return null;
+        if (now_ms - summary.settled_ms >= startup_panel_ms) return null;
+        return summary;
+    }
+
+    pub fn addMessage(self: *ClientModel, severity: Severity, text: []const u8) !void {
+        try self.addMessageAt(severity, text, std.time.milliTimestamp());
     }
 
-    pub fn addMessageAt(self: *ClientModel, text: []const u8, now_ms: i64) !void {
+    pub fn addMessageAt(self: *ClientModel, severity: Severity, text: []const u8, now_ms: i64) !void {
         if (text.len == 0) return;
         self.pruneExpiredMessages(now_ms);
-        const owned = try self.allocator.dupe(u8, text);
-        errdefer self.allocator.free(owned);
-        try self.messages.append(.{
-            .text = owned,
+        const entry = TimedMessage{
+            .severity = severity,
+            .text = text,
+            .created_ms = now_ms,
             .expires_at_ms = now_ms + message_timeout_ms,
-        });
+        };
+        try self.appendOwnedMessage(&self.messages, entry);
+        try self.appendOwnedMessage(&self.message_history, entry);
+        if (self.message_history.items.len > max_message_history) {
+            self.allocator.free(self.message_history.orderedRemove(0).text);
+        }
+    }
+
+    fn appendOwnedMessage(self: *ClientModel, list: *std.array_list.Managed(TimedMessage), entry: TimedMessage) !void {
+        var owned = entry;
+        owned.text = try self.allocator.dupe(u8, entry.text);
+        errdefer self.allocator.free(owned.text);
+        try list.append(owned);
+    }
+
+    pub fn messageHistory(self: *const ClientModel) []const TimedMessage {
+        return self.message_history.items;
+    }
+
+    /// Shows keybinding conflicts found while loading config, so a key that
+    /// does something unexpected explains itself on startup.
+    pub fn addKeybindingConflicts(self: *ClientModel, warnings: []const config.schema.Warning) !void {
+        for (warnings) |warning| {
+            if (warning.kind != .keybinding_conflict) continue;
+            const text = try std.fmt.allocPrint(self.allocator, "{s}: {s}", .{ warning.path, warning.message });
+            defer self.allocator.free(text);
+            try self.addMessage(.warn, text);
+        }
     }
 
     pub fn pruneExpiredMessages(self: *ClientModel, now_ms: i64) void {
@@ -97,6 +398,35 @@ pub const ClientModel = struct {
         self.messages.items.len = write_index;
     }
 
+    /// Prunes expired messages. Returns true when any were dropped, so the
+    /// frame that showed them needs redrawing.
+    pub fn expireMessages(self: *ClientModel, now_ms: i64) bool {
+        const before = self.messages.items.len;
+        self.pruneExpiredMessages(now_ms);
+        return self.messages.items.len != before;
+    }
+
+    /// Milliseconds until the next message expires, for use as a poll timeout
+    /// so the panel clears without waiting for other activity.
+    pub fn messageExpiryTimeoutMs(self: *const ClientModel, now_ms: i64) ?i32 {
+        var deadline: ?i64 = null;
+        for (self.messages.items) |message_entry| {
+            if (deadline == null or message_entry.expires_at_ms < deadline.?) deadline = message_entry.expires_at_ms;
+        }
+        const soonest = deadline orelse return null;
+        if (soonest <= now_ms) return 0;
+        return @intCast(@min(soonest - now_ms, std.math.maxInt(i32)));
+    }
+
+    /// Messages the panel shows at `now_ms`.
+    pub fn visibleMessageCount(self: *const ClientModel, now_ms: i64) usize {
+        var count: usize = 0;
+        for (self.messages.items) |message_entry| {
+            if (now_ms < message_entry.expires_at_ms) count += 1;
+        }
+        return count;
+    }
+
     pub fn messageCount(self: *const ClientModel) usize {
         return self.messages.items.len;
     }
@@ -113,6 +443,32 @@ pub const ClientModel = struct {
         return self.filtered_processes[index].label;
     }
 
+    /// Palette for this client's terminal background.
+    pub fn style(self: *const ClientModel) *const domain.client_snapshot.UiStyleConfig {
+        const configured = std.meta.stringToEnum(config.schema.Background, self.snapshot.ui.background) orelse .auto;
+        const light = switch (configured) {
+            .auto => self.light_terminal,
+            .dark => false,
+            .light => true,
+        };
+        return if (light) &self.snapshot.ui.light_style else &self.snapshot.ui.style;
+    }
+
+    /// Pointer and status markers for this client's terminal.
+    pub fn icons(self: *const ClientModel) config.icons.Icons {
+        if (self.ascii_icons) return config.icons.ascii;
+        const style = self.style();
+        return .{
+            .pointer = style.pointer_char,
+            .running = style.status_running_icon,
+            .halting = style.status_halting_icon,
+            .stopped = style.status_stopped_icon,
+            .failed = style.status_failed_icon,
+            .succeeded = style.status_succeeded_icon,
+            .unhealthy = style.status_unhealthy_icon,
+        };
+    }
+
     pub fn visibleProcesses(self: *const ClientModel) []const domain.client_snapshot.ProcessSummary {
         return self.filtered_processes;
     }
@@ -142,21 +498,84 @@ pub const ClientModel = struct {
         self: *ClientModel,
         snapshot: *const domain.client_snapshot.ClientSnapshot,
     ) !void {
-        const new_filtered_processes = try domain.client_snapshot.filteredProcesses(
-            self.allocator,
-            snapshot,
-            self.filter_text.items,
-            self.show_only_running,
-        );
+        try self.announceWatchRestarts(snapshot);
+        try self.announceIdleStops(snapshot);
+        try self.announceCrashLoops(snapshot);
+        try self.announceStuck(snapshot);
+        const list = try self.buildProcessList(snapshot);
 
         self.allocator.free(self.filtered_processes);
         self.snapshot = snapshot;
-        self.filtered_processes = new_filtered_processes;
+        self.filtered_processes = list.processes;
+        self.pinned_count = list.pinned_count;
+        try self.recordSeenOutput();
+    }
+
+    /// Whether the process printed output since it was last selected. The
+    /// selected process never has unread output.
+    pub fn hasUnreadOutput(self: *const ClientModel, summary: domain.client_snapshot.ProcessSummary) bool {
+        if (domain.process.ProcessId.fromInt(summary.id) == self.active_proc_id) return false;
+        const seen = self.seen_output.get(summary.id) orelse return false;
+        return summary.last_output_ms > seen.last_output_ms;
+    }
+
+    /// Bells the process rang since it was last selected.
+    pub fn unreadBells(self: *const ClientModel, summary: domain.client_snapshot.ProcessSummary) u32 {
+        if (self.bellAction() == .off) return 0;
+        if (domain.process.ProcessId.fromInt(summary.id) == self.active_proc_id) return 0;
+        const seen = self.seen_output.get(summary.id) orelse return 0;
+        return summary.bells -| seen.bells;
+    }
+
+    /// Error-pattern lines the process printed since it was last selected.
+    pub fn unreadErrors(self: *const ClientModel, summary: domain.client_snapshot.ProcessSummary) u32 {
+        if (domain.process.ProcessId.fromInt(summary.id) == self.active_proc_id) return 0;
+        const seen = self.seen_output.get(summary.id) orelse return 0;
+        return summary.error_count -| seen.error_count;
+    }
+
+    /// Starts tracking processes new to the snapshot from their current
+    /// output, rings for new bells, and marks the selected process's output
+    /// as read.
+    fn recordSeenOutput(self: *ClientModel) !void {
+        for (self.snapshot.processes) |summary| {
+            const entry = try self.seen_output.getOrPut(summary.id);
+            if (!entry.found_existing) {
+                entry.value_ptr.* = SeenOutput.of(summary);
+                continue;
+            }
+            if (summary.bells > entry.value_ptr.heard_bells) self.heardBell(summary);
+            entry.value_ptr.heard_bells = summary.bells;
+        }
+        self.markActiveSeen();
+    }
+
+    fn heardBell(self: *ClientModel, summary: domain.client_snapshot.ProcessSummary) void {
+        if (self.bellAction() != .ring) return;
+        const selected = domain.process.ProcessId.fromInt(summary.id) == self.active_proc_id;
+        if (selected and self.output_pane_rings) return;
+        self.bell_pending = true;
+    }
+
+    fn markActiveSeen(self: *ClientModel) void {
+        const summary = self.activeProcessSummary() orelse return;
+        if (self.seen_output.getPtr(summary.id)) |seen| seen.* = SeenOutput.of(summary);
     }
 
     /// Applies one normalized key. Local UI keys are handled immediately;
     /// process lifecycle keys return an intent for the Client Session to send.
     pub fn handleKey(self: *ClientModel, key: []const u8) !?CommandIntent {
+        // Output shown until the selection moves away counts as read.
+        self.markActiveSeen();
+        if (self.quit_prompt) return self.quitPromptIntent(key);
+        if (self.show_history) {
+            self.handleHistoryKey(key);
+            return null;
+        }
+        if (self.env_view != null) {
+            self.handleEnvKey(key);
+            return null;
+        }
         if (self.entering_filter_text) {
             if (self.processListIntentForControlModifiedKey(key)) |intent| return intent;
 
@@ -176,6 +595,7 @@ pub const ClientModel = struct {
                 self.entering_filter_text = false;
                 self.mode = .normal;
                 self.filter_text.clearRetainingCapacity();
+                self.active_view = null;
                 try self.applyFilterLocal();
                 return null;
             }
@@ -198,6 +618,7 @@ pub const ClientModel = struct {
             self.entering_filter_text = true;
             self.mode = .filter;
             self.filter_text.clearRetainingCapacity();
+            self.active_view = null;
             self.active_proc_id = .none;
             try self.rebuildProcessList();
             return null;
@@ -212,31 +633,246 @@ pub const ClientModel = struct {
         }
         if (matches(self.snapshot.ui.keybinding.toggle_running, key)) {
             self.show_only_running = !self.show_only_running;
+            self.active_view = null;
             try self.applyFilterLocal();
             return self.syncActiveSelection();
         }
+        if (matches(self.snapshot.ui.keybinding.cycle_view, key)) {
+            const views = self.snapshot.ui.views.len;
+            if (views == 0) return null;
+            const next: ?usize = if (self.active_view) |index| (if (index + 1 < views) index + 1 else null) else 0;
+            return self.selectView(next);
+        }
+        if (matches(self.snapshot.ui.keybinding.cycle_sort, key)) {
+            try self.setSortMode(self.sort_mode.next());
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.toggle_pin, key)) {
+            try self.togglePin();
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.toggle_mark, key)) {
+            try self.toggleMark();
+            return null;
+        }
         if (matches(self.snapshot.ui.keybinding.start, key)) {
-            return self.commandIntent(.start);
+            return self.processIntent(.start);
         }
         if (matches(self.snapshot.ui.keybinding.stop, key)) {
-            return self.commandIntent(.stop);
+            return self.processIntent(.stop);
         }
         if (matches(self.snapshot.ui.keybinding.restart, key)) {
-            return self.commandIntent(.restart);
+            return self.processIntent(.restart);
         }
         if (matches(self.snapshot.ui.keybinding.toggle_help, key)) {
             self.show_help = !self.show_help;
             return null;
         }
+        if (matches(self.snapshot.ui.keybinding.toggle_messages, key)) {
+            self.show_history = true;
+            self.history_offset = 0;
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.jump_to_error, key)) {
+            return self.jumpToErrorIntent();
+        }
+        if (matches(self.snapshot.ui.keybinding.copy_command, key)) {
+            try self.requestCopy(.command);
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.copy_pid, key)) {
+            try self.requestCopy(.pid);
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.copy_cwd, key)) {
+            try self.requestCopy(.cwd);
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.copy_output, key)) {
+            try self.requestCopy(.output);
+            return null;
+        }
+        if (matches(self.snapshot.ui.keybinding.open_url, key)) {
+            return self.openUrlIntent();
+        }
+        if (matches(self.snapshot.ui.keybinding.open_cwd, key)) {
+            if (self.activeProcessSummary() == null) return null;
+            return self.commandIntent(.open_cwd);
+        }
+        if (matches(self.snapshot.ui.keybinding.show_env, key)) {
+            if (self.activeProcessSummary() == null) {
+                try self.addMessage(.warn, "no process selected");
+                return null;
+            }
+            self.env_request = true;
+            return null;
+        }
         if (matches(self.snapshot.ui.keybinding.quit, key)) {
+            return self.quitIntent();
+        }
+        if (std.mem.eql(u8, key, "esc")) {
+            self.clearMarked();
+            self.startup_dismissed = true;
+            return null;
+        }
+        // Number keys come last so any configured binding on a digit wins.
+        if (viewNumber(key)) |index| {
+            if (index >= self.snapshot.ui.views.len) return null;
+            const again = if (self.active_view) |active| active == index else false;
+            return self.selectView(if (again) null else index);
+        }
+        return null;
+    }
+
+    /// The history overlay is modal: the arrows or `up`/`down` bindings
+    /// scroll it and `toggle_messages` or `esc` closes it.
+    fn handleHistoryKey(self: *ClientModel, key: []const u8) void {
+        const bindings = &self.snapshot.ui.keybinding;
+        if (matches(bindings.toggle_messages, key) or std.mem.eql(u8, key, "esc")) {
+            self.show_history = false;
+        } else if (matches(bindings.down, key) or std.mem.eql(u8, key, "down")) {
+            if (self.history_offset + 1 < self.message_history.items.len) self.history_offset += 1;
+        } else if (matches(bindings.up, key) or std.mem.eql(u8, key, "up")) {
+            self.history_offset -|= 1;
+        }
+    }
+
+    /// The environment inspector is modal like the history overlay;
+    /// `show_env` or `esc` closes it.
+    fn handleEnvKey(self: *ClientModel, key: []const u8) void {
+        const bindings = &self.snapshot.ui.keybinding;
+        const view = &self.env_view.?;
+        if (matches(bindings.show_env, key) or std.mem.eql(u8, key, "esc")) {
+            self.closeEnv();
+        } else if (matches(bindings.down, key) or std.mem.eql(u8, key, "down")) {
+            if (view.offset + 1 < view.variables.len) view.offset += 1;
+        } else if (matches(bindings.up, key) or std.mem.eql(u8, key, "up")) {
+            view.offset -|= 1;
+        }
+    }
+
+    /// Jumps to the first visible process with unread errors, else within
+    /// the selected process, where pressing again goes back to live output.
+    fn jumpToErrorIntent(self: *ClientModel) !?CommandIntent {
+        for (self.filtered_processes) |summary| {
+            if (self.unreadErrors(summary) == 0) continue;
+            self.active_proc_id = domain.process.ProcessId.fromInt(summary.id);
+            return self.commandIntent(.jump_to_error);
+        }
+        const active = self.activeProcessSummary() orelse return null;
+        if (active.error_count == 0) {
+            try self.addMessage(.info, "no errors in output");
+            return null;
+        }
+        return self.commandIntent(.jump_to_error);
+    }
+
+    fn requestCopy(self: *ClientModel, target: CopyTarget) !void {
+        const active = self.activeProcessSummary() orelse {
+            try self.addMessage(.warn, "no process selected");
+            return;
+        };
+        if (target == .pid and active.pid <= 0) {
+            try self.addMessage(.info, "process is not running");
+            return;
+        }
+        self.copy_request = target;
+    }
+
+    fn openUrlIntent(self: *ClientModel) !?CommandIntent {
+        const active = self.activeProcessSummary() orelse return null;
+        if (active.url.len == 0) {
+            try self.addMessage(.info, "no url configured");
+            return null;
+        }
+        return self.commandIntent(.open_url);
+    }
+
+    fn quitIntent(self: *ClientModel) ?CommandIntent {
+        switch (self.quitAction()) {
+            .stop => {},
+            .detach => {
+                self.detach_requested = true;
+                return null;
+            },
+            // With nothing running there is nothing to ask about.
+            .ask => if (self.runningCount() > 0) {
+                self.quit_prompt = true;
+                return null;
+            },
+        }
+        return .{
+            .action = .stop_running,
+            .label = "",
+        };
+    }
+
+    /// `s`, `enter`, or `quit` again stops everything; `d` detaches; `esc`
+    /// or `n` goes back to the list. Other keys leave the prompt open.
+    fn quitPromptIntent(self: *ClientModel, key: []const u8) ?CommandIntent {
+        if (std.mem.eql(u8, key, "s") or std.mem.eql(u8, key, "enter") or matches(self.snapshot.ui.keybinding.quit, key)) {
+            self.quit_prompt = false;
             return .{
                 .action = .stop_running,
                 .label = "",
             };
         }
+        if (std.mem.eql(u8, key, "d")) {
+            self.quit_prompt = false;
+            self.detach_requested = true;
+        } else if (std.mem.eql(u8, key, "esc") or std.mem.eql(u8, key, "n")) {
+            self.quit_prompt = false;
+        }
         return null;
     }
 
+    /// Replaces the filter and running-only toggle with those of view
+    /// `index`, or clears both when `index` is null.
+    fn selectView(self: *ClientModel, index: ?usize) !?CommandIntent {
+        self.active_view = index;
+        self.filter_text.clearRetainingCapacity();
+        self.show_only_running = false;
+        if (self.activeView()) |view| {
+            try self.filter_text.appendSlice(view.filter);
+            self.show_only_running = view.running_only;
+        }
+        try self.applyFilterLocal();
+        return self.syncActiveSelection();
+    }
+
+    fn togglePin(self: *ClientModel) !void {
+        const label = self.activeProcLabel();
+        if (label.len == 0) return;
+        if (indexOfLabel(self.pinned.items, label)) |index| {
+            self.allocator.free(self.pinned.orderedRemove(index));
+        } else {
+            const owned = try self.allocator.dupe(u8, label);
+            errdefer self.allocator.free(owned);
+            try self.pinned.append(owned);
+        }
+        self.pins_changed = true;
+        try self.rebuildProcessList();
+    }
+
+    fn toggleMark(self: *ClientModel) !void {
+        const label = self.activeProcLabel();
+        if (label.len == 0) return;
+        if (indexOfLabel(self.marked.items, label)) |index| {
+            self.allocator.free(self.marked.orderedRemove(index));
+            return;
+        }
+        const owned = try self.allocator.dupe(u8, label);
+        errdefer self.allocator.free(owned);
+        try self.marked.append(owned);
+    }
+
+    fn clearPinned(self: *ClientModel) void {
+        for (self.pinned.items) |label| self.allocator.free(label);
+        self.pinned.clearRetainingCapacity();
+    }
+
+    /// Selects the first filter match, skipping the pinned processes that
+    /// stay on top whatever the filter.
     fn applyFilterLocal(self: *ClientModel) !void {
         try self.rebuildProcessList();
         if (self.filtered_processes.len == 0) {
@@ -244,7 +880,8 @@ pub const ClientModel = struct {
             return;
         }
 
-        self.active_proc_id = domain.process.ProcessId.fromInt(self.filtered_processes[0].id);
+        const first = if (self.pinned_count < self.filtered_processes.len) self.pinned_count else 0;
+        self.active_proc_id = domain.process.ProcessId.fromInt(self.filtered_processes[first].id);
     }
 
     fn syncActiveSelection(self: *ClientModel) ?CommandIntent {
@@ -267,9 +904,9 @@ pub const ClientModel = struct {
         // otherwise text input would temporarily make the TUI unable to stop work.
 
         if (self.navigationIntentForKey(process_list_key)) |intent| return intent;
-        if (matches(bindings.start, process_list_key)) return self.commandIntent(.start);
-        if (matches(bindings.stop, process_list_key)) return self.commandIntent(.stop);
-        if (matches(bindings.restart, process_list_key)) return self.commandIntent(.restart);
+        if (matches(bindings.start, process_list_key)) return self.processIntent(.start);
+        if (matches(bindings.stop, process_list_key)) return self.processIntent(.stop);
+        if (matches(bindings.restart, process_list_key)) return self.processIntent(.restart);
         return null;
     }
 
@@ -293,6 +930,12 @@ pub const ClientModel = struct {
         return null;
     }
 
+    /// Targets the marked processes when there are any, else the selection.
+    fn processIntent(self: *ClientModel, action: ipc.protocol.Command) CommandIntent {
+        if (self.marked.items.len > 0) return .{ .action = action, .label = "", .labels = self.marked.items };
+        return self.commandIntent(action);
+    }
+
     fn commandIntent(self: *ClientModel, action: ipc.protocol.Command) CommandIntent {
         return .{
             .action = action,
@@ -331,25 +974,116 @@ pub const ClientModel = struct {
         self.active_proc_id = domain.process.ProcessId.fromInt(self.filtered_processes[next_index].id);
     }
 
+    /// Adds a message for each process whose watch restart counter moved
+    /// since the current snapshot.
+    fn announceWatchRestarts(
+        self: *ClientModel,
+        next: *const domain.client_snapshot.ClientSnapshot,
+    ) !void {
+        for (next.processes) |summary| {
+            const previous = findSummary(self.snapshot.processes, summary.id) orelse continue;
+            if (summary.watch_restarts <= previous.watch_restarts) continue;
+            const text = try std.fmt.allocPrint(self.allocator, "{s} restarted due to change in {s}", .{ summary.label, summary.watch_change });
+            defer self.allocator.free(text);
+            try self.addMessage(.info, text);
+        }
+    }
+
+    fn announceIdleStops(
+        self: *ClientModel,
+        next: *const domain.client_snapshot.ClientSnapshot,
+    ) !void {
+        for (next.processes) |summary| {
+            const previous = findSummary(self.snapshot.processes, summary.id) orelse continue;
+            if (summary.idle_stops <= previous.idle_stops) continue;
+            const text = try std.fmt.allocPrint(self.allocator, "{s} stopped after idle timeout", .{summary.label});
+            defer self.allocator.free(text);
+            try self.addMessage(.info, text);
+        }
+    }
+
+    fn announceCrashLoops(
+        self: *ClientModel,
+        next: *const domain.client_snapshot.ClientSnapshot,
+    ) !void {
+        for (next.processes) |summary| {
+            const previous = findSummary(self.snapshot.processes, summary.id) orelse continue;
+            if (!summary.crash_looping or previous.crash_looping) continue;
+            const text = try std.fmt.allocPrint(self.allocator, "{s} is crash-looping; automatic restarts stopped", .{summary.label});
+            defer self.allocator.free(text);
+            try self.addMessage(.@"error", text);
+        }
+    }
+
+    fn announceStuck(
+        self: *ClientModel,
+        next: *const domain.client_snapshot.ClientSnapshot,
+    ) !void {
+        for (next.processes) |summary| {
+            const previous = findSummary(self.snapshot.processes, summary.id) orelse continue;
+            if (!summary.stuck or previous.stuck) continue;
+            const text = try std.fmt.allocPrint(self.allocator, "{s} has printed nothing for a while; it may be stuck", .{summary.label});
+            defer self.allocator.free(text);
+            try self.addMessage(.warn, text);
+        }
+    }
+
     fn activeProcLabel(self: *const ClientModel) []const u8 {
         const summary = self.activeProcessSummary() orelse return "";
         return summary.label;
     }
 
     fn rebuildProcessList(self: *ClientModel) !void {
+        const list = try self.buildProcessList(self.snapshot);
         self.allocator.free(self.filtered_processes);
-        self.filtered_processes = try domain.client_snapshot.filteredProcesses(
+        self.filtered_processes = list.processes;
+        self.pinned_count = list.pinned_count;
+    }
+
+    fn buildProcessList(
+        self: *const ClientModel,
+        snapshot: *const domain.client_snapshot.ClientSnapshot,
+    ) !domain.client_snapshot.PinnedList {
+        const filtered = try domain.client_snapshot.filteredProcesses(
             self.allocator,
-            self.snapshot,
+            snapshot,
             self.filter_text.items,
             self.show_only_running,
+            self.sort_mode,
         );
+        defer self.allocator.free(filtered);
+        return domain.client_snapshot.pinFirst(self.allocator, snapshot, filtered, self.pinned.items, self.sort_mode);
     }
 };
 
+fn indexOfLabel(labels: []const []const u8, label: []const u8) ?usize {
+    for (labels, 0..) |candidate, index| {
+        if (std.mem.eql(u8, candidate, label)) return index;
+    }
+    return null;
+}
+
+/// Maps "1" through "9" to view indexes 0 through 8.
+fn viewNumber(key: []const u8) ?usize {
+    if (key.len != 1 or key[0] < '1' or key[0] > '9') return null;
+    return key[0] - '1';
+}
+
+fn findSummary(
+    processes: []const domain.client_snapshot.ProcessSummary,
+    id: u32,
+) ?domain.client_snapshot.ProcessSummary {
+    for (processes) |summary| {
+        if (summary.id == id) return summary;
+    }
+    return null;
+}
+
 fn matches(bindings: domain.client_snapshot.StringList, key: []const u8) bool {
     for (bindings) |binding| {
         if (std.mem.eql(u8, binding, key)) return true;
+        // Space arrives as itself but reads better as a name in config.
+        if (std.mem.eql(u8, binding, "space") and std.mem.eql(u8, key, " ")) return true;
     }
     return false;
 }
@@ -740,6 +1474,45 @@ test "client model quit key emits stop-running intent" {
     try std.testing.expectEqualStrings("", intent.?.label);
 }
 
+test "client model asks before quitting with processes running" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.general.on_quit = "ask";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expectEqual(@as(usize, 2), model.runningCount());
+    try std.testing.expect((try model.handleKey("q")) == null);
+    try std.testing.expect(model.quit_prompt);
+    try std.testing.expect((try model.handleKey("j")) == null);
+    try std.testing.expect(model.quit_prompt);
+    _ = try model.handleKey("esc");
+    try std.testing.expect(!model.quit_prompt);
+
+    _ = try model.handleKey("q");
+    try std.testing.expect((try model.handleKey("d")) == null);
+    try std.testing.expect(!model.quit_prompt);
+    try std.testing.expect(model.takeDetach());
+    try std.testing.expect(!model.takeDetach());
+
+    _ = try model.handleKey("q");
+    const stop = try model.handleKey("s");
+    try std.testing.expectEqual(ipc.protocol.Command.stop_running, stop.?.action);
+
+    model.can_detach = false;
+    const forced = try model.handleKey("q");
+    try std.testing.expectEqual(ipc.protocol.Command.stop_running, forced.?.action);
+    try std.testing.expect(!model.quit_prompt);
+}
+
 test "client model prunes messages after five second timeout" {
     var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
     defer cfg.deinit();
@@ -754,11 +1527,556 @@ test "client model prunes messages after five second timeout" {
     var model = try ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
 
-    try model.addMessageAt("expired", 0);
-    try model.addMessageAt("fresh", 1);
+    try model.addMessageAt(.info, "expired", 0);
+    try model.addMessageAt(.info, "fresh", 1);
 
     model.pruneExpiredMessages(message_timeout_ms);
 
     try std.testing.expectEqual(@as(usize, 1), model.messageCount());
     try std.testing.expectEqualStrings("fresh", model.message(0));
 }
+
+test "client model times message expiry for redraws" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expectEqual(@as(?i32, null), model.messageExpiryTimeoutMs(0));
+    try model.addMessageAt(.info, "first", 0);
+    try model.addMessageAt(.info, "second", 1000);
+    try std.testing.expectEqual(@as(?i32, 4000), model.messageExpiryTimeoutMs(1000));
+    try std.testing.expectEqual(@as(usize, 1), model.visibleMessageCount(message_timeout_ms));
+
+    try std.testing.expect(!model.expireMessages(message_timeout_ms - 1));
+    try std.testing.expect(model.expireMessages(message_timeout_ms));
+    try std.testing.expectEqual(@as(?i32, 1000), model.messageExpiryTimeoutMs(message_timeout_ms));
+    try std.testing.expectEqual(@as(?i32, 0), model.messageExpiryTimeoutMs(message_timeout_ms + 2000));
+}
+
+test "client model keeps message history past the panel timeout" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try model.addMessageAt(.@"error", "first", 0);
+    for (0..max_message_history) |index| try model.addMessageAt(.info, "later", @intCast(index + 1));
+    model.pruneExpiredMessages(message_timeout_ms * 1000);
+
+    try std.testing.expectEqual(@as(usize, 0), model.messageCount());
+    try std.testing.expectEqual(@as(usize, max_message_history), model.messageHistory().len);
+    try std.testing.expectEqualStrings("later", model.messageHistory()[0].text);
+
+    _ = try model.handleKey("m");
+    try std.testing.expect(model.show_history);
+    try std.testing.expect((try model.handleKey("j")) == null);
+    try std.testing.expectEqual(@as(usize, 1), model.history_offset);
+    _ = try model.handleKey("up");
+    _ = try model.handleKey("up");
+    try std.testing.expectEqual(@as(usize, 0), model.history_offset);
+    _ = try model.handleKey("esc");
+    try std.testing.expect(!model.show_history);
+}
+
+test "client model shows only keybinding conflicts from config warnings" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try model.addKeybindingConflicts(&.{
+        .{ .kind = .unknown_field, .path = "colour", .message = "unknown config field ignored" },
+        .{ .kind = .keybinding_conflict, .path = "keybinding.quit", .message = "\"q\" is also bound to start, which takes precedence" },
+    });
+
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+    try std.testing.expectEqualStrings("keybinding.quit: \"q\" is also bound to start, which takes precedence", model.message(0));
+}
+
+test "client model announces watch restarts from snapshot updates" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[0].watch_restarts = 1;
+    views[0].watch_change = "src/main.zig";
+    var restarted = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer restarted.deinit(std.testing.allocator);
+
+    try model.replaceSnapshotPreservingUI(restarted.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+    try std.testing.expectEqualStrings("alpha-api restarted due to change in src/main.zig", model.message(0));
+
+    try model.replaceSnapshotPreservingUI(restarted.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+}
+
+test "client model announces idle stops from snapshot updates" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[1].idle_stops = 1;
+    var stopped = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer stopped.deinit(std.testing.allocator);
+
+    try model.replaceSnapshotPreservingUI(stopped.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+    try std.testing.expectEqualStrings("beta-worker stopped after idle timeout", model.message(0));
+}
+
+test "client model announces crash loops once" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[2].crash_looping = true;
+    views[2].crash_output = "Error: port 5432 in use";
+    var looping = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer looping.deinit(std.testing.allocator);
+
+    try model.replaceSnapshotPreservingUI(looping.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+    try std.testing.expectEqualStrings("gamma-db is crash-looping; automatic restarts stopped", model.message(0));
+
+    try model.replaceSnapshotPreservingUI(looping.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+}
+
+test "client model announces stuck processes once" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[0].stuck = true;
+    var quiet = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer quiet.deinit(std.testing.allocator);
+
+    try model.replaceSnapshotPreservingUI(quiet.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+    try std.testing.expectEqualStrings("alpha-api has printed nothing for a while; it may be stuck", model.message(0));
+
+    try model.replaceSnapshotPreservingUI(quiet.view());
+    try std.testing.expectEqual(@as(usize, 1), model.messageCount());
+}
+
+test "client model flags output from unselected processes until viewed" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(1);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    views[1].last_output_ms = 1_000;
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expect(!model.hasUnreadOutput(model.processSummaries()[1]));
+
+    views[0].last_output_ms = 2_000;
+    views[1].last_output_ms = 2_000;
+    var busy = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer busy.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(busy.view());
+    try std.testing.expect(!model.hasUnreadOutput(model.processSummaries()[0]));
+    try std.testing.expect(model.hasUnreadOutput(model.processSummaries()[1]));
+
+    _ = try model.handleKey("j");
+    _ = try model.handleKey("k");
+    try std.testing.expect(!model.hasUnreadOutput(model.processSummaries()[0]));
+    try std.testing.expect(!model.hasUnreadOutput(model.processSummaries()[1]));
+}
+
+test "client model counts unread errors and jumps to the process that printed them" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(1);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    views[1].error_count = 1;
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expectEqual(@as(u32, 0), model.unreadErrors(model.processSummaries()[1]));
+    try std.testing.expect((try model.handleKey("e")) == null);
+    try std.testing.expectEqualStrings("no errors in output", model.message(0));
+
+    views[1].error_count = 3;
+    var failing = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer failing.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(failing.view());
+    try std.testing.expectEqual(@as(u32, 2), model.unreadErrors(model.processSummaries()[1]));
+
+    const intent = (try model.handleKey("e")).?;
+    try std.testing.expectEqual(ipc.protocol.Command.jump_to_error, intent.action);
+    try std.testing.expectEqualStrings(model.processSummaries()[1].label, intent.label);
+    try std.testing.expectEqual(@as(u32, 0), model.unreadErrors(model.processSummaries()[1]));
+}
+
+test "client model requests copies for the selected process" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    views[0].pid = 4242;
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, domain.process.ProcessId.fromInt(2), views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expect((try model.handleKey("P")) == null);
+    try std.testing.expectEqual(@as(?CopyTarget, null), model.takeCopyRequest());
+    try std.testing.expectEqualStrings("process is not running", model.message(0));
+
+    try std.testing.expect((try model.handleKey("Y")) == null);
+    try std.testing.expectEqual(@as(?CopyTarget, .output), model.takeCopyRequest());
+    try std.testing.expectEqual(@as(?CopyTarget, null), model.takeCopyRequest());
+
+    model.active_proc_id = domain.process.ProcessId.fromInt(1);
+    try std.testing.expect((try model.handleKey("P")) == null);
+    try std.testing.expectEqual(@as(?CopyTarget, .pid), model.takeCopyRequest());
+}
+
+test "client model opens urls only for processes that configure one" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.procs.getPtr("alpha-api").?.url = try std.testing.allocator.dupe(u8, "http://localhost:3000");
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, domain.process.ProcessId.fromInt(2), views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expect((try model.handleKey("o")) == null);
+    try std.testing.expectEqualStrings("no url configured", model.message(0));
+
+    const cwd = (try model.handleKey("O")).?;
+    try std.testing.expectEqual(ipc.protocol.Command.open_cwd, cwd.action);
+    try std.testing.expectEqualStrings("beta-worker", cwd.label);
+
+    model.active_proc_id = domain.process.ProcessId.fromInt(1);
+    const url = (try model.handleKey("o")).?;
+    try std.testing.expectEqual(ipc.protocol.Command.open_url, url.action);
+    try std.testing.expectEqualStrings("alpha-api", url.label);
+}
+
+test "client model opens the environment inspector for the selected process" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, .none, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expect((try model.handleKey("E")) == null);
+    try std.testing.expect(!model.takeEnvRequest());
+    try std.testing.expectEqualStrings("no process selected", model.message(0));
+
+    model.active_proc_id = domain.process.ProcessId.fromInt(1);
+    _ = try model.handleKey("E");
+    try std.testing.expect(model.takeEnvRequest());
+    try std.testing.expect(!model.takeEnvRequest());
+
+    try model.openEnv("alpha-api", &.{
+        .{ .name = "HOME", .value = "/custom/home", .source = "config", .overridden = "/home/nick" },
+        .{ .name = "SHELL", .value = "/bin/zsh", .source = "inherited" },
+    });
+    try std.testing.expect((try model.handleKey("s")) == null);
+    try std.testing.expect(model.env_view != null);
+    _ = try model.handleKey("down");
+    _ = try model.handleKey("down");
+    try std.testing.expectEqual(@as(usize, 1), model.env_view.?.offset);
+    _ = try model.handleKey("up");
+    try std.testing.expectEqual(@as(usize, 0), model.env_view.?.offset);
+    _ = try model.handleKey("E");
+    try std.testing.expect(model.env_view == null);
+}
+
+test "client model rings once per new bell and marks unselected processes" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(1);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try std.testing.expect(!model.takeBell());
+
+    views[0].bells = 1;
+    views[1].bells = 2;
+    var rung = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer rung.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(rung.view());
+    try std.testing.expect(model.takeBell());
+    try std.testing.expect(!model.takeBell());
+    try std.testing.expectEqual(@as(u32, 0), model.unreadBells(model.processSummaries()[0]));
+    try std.testing.expectEqual(@as(u32, 2), model.unreadBells(model.processSummaries()[1]));
+
+    // The selected process rings through the output pane unless it drops bells.
+    views[0].bells = 2;
+    var selected = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer selected.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(selected.view());
+    try std.testing.expect(!model.takeBell());
+    model.output_pane_rings = false;
+    views[0].bells = 3;
+    var emulated = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer emulated.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(emulated.view());
+    try std.testing.expect(model.takeBell());
+
+    cfg.general.on_bell = "mark";
+    views[2].bells = 1;
+    var marked = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer marked.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(marked.view());
+    try std.testing.expect(!model.takeBell());
+    try std.testing.expectEqual(@as(u32, 1), model.unreadBells(model.processSummaries()[2]));
+}
+
+test "client model picks the palette for its terminal background" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expectEqualStrings("yellow", model.style().status_halting_color);
+    model.light_terminal = true;
+    try std.testing.expectEqualStrings("136", model.style().status_halting_color);
+
+    cfg.background = "dark";
+    var forced_dark = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer forced_dark.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(forced_dark.view());
+    try std.testing.expectEqualStrings("yellow", model.style().status_halting_color);
+}
+
+test "client model selects quick views by number and cycles through them" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+    try cfg.views.append(.{ .name = try std.testing.allocator.dupe(u8, "db"), .filter = "db" });
+    try cfg.views.append(.{ .name = try std.testing.allocator.dupe(u8, "live"), .running_only = true });
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const intent = try model.handleKey("1");
+    try std.testing.expectEqualStrings("db", model.activeView().?.name);
+    try std.testing.expectEqualStrings("db", model.filterText());
+    try std.testing.expectEqual(@as(usize, 1), model.visibleCount());
+    try std.testing.expectEqualStrings("gamma-db", intent.?.label);
+
+    _ = try model.handleKey("v");
+    try std.testing.expectEqualStrings("live", model.activeView().?.name);
+    try std.testing.expectEqualStrings("", model.filterText());
+    try std.testing.expect(model.show_only_running);
+    try std.testing.expectEqual(@as(usize, 2), model.visibleCount());
+
+    _ = try model.handleKey("v");
+    try std.testing.expect(model.activeView() == null);
+    try std.testing.expect(!model.show_only_running);
+    try std.testing.expectEqual(@as(usize, 3), model.visibleCount());
+
+    _ = try model.handleKey("2");
+    _ = try model.handleKey("2");
+    try std.testing.expect(model.activeView() == null);
+    try std.testing.expectEqual(@as(usize, 3), model.visibleCount());
+
+    try std.testing.expect(try model.handleKey("9") == null);
+    _ = try model.handleKey("1");
+    _ = try model.handleKey("R");
+    try std.testing.expect(model.activeView() == null);
+    try std.testing.expectEqualStrings("db", model.filterText());
+}
+
+test "client model cycles sort modes and keeps the selection" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expect(try model.handleKey("S") == null);
+    try std.testing.expectEqual(domain.client_snapshot.SortMode.alpha, model.sort_mode);
+    _ = try model.handleKey("S");
+    try std.testing.expectEqual(domain.client_snapshot.SortMode.running_first, model.sort_mode);
+    try std.testing.expectEqualStrings("alpha-api", model.visibleLabel(0));
+    try std.testing.expectEqualStrings("gamma-db", model.visibleLabel(1));
+    try std.testing.expectEqualStrings("beta-worker", model.visibleLabel(2));
+    try std.testing.expectEqual(domain.process.ProcessId.fromInt(2), model.active_proc_id);
+}
+
+test "client model keeps pinned processes on top through filters" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(3);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expect(try model.handleKey("p") == null);
+    try std.testing.expect(model.takePinsChanged());
+    try std.testing.expect(!model.takePinsChanged());
+    try std.testing.expect(model.isPinned("gamma-db"));
+    try std.testing.expectEqual(@as(usize, 1), model.pinned_count);
+    try std.testing.expectEqualStrings("gamma-db", model.visibleLabel(0));
+
+    _ = try model.handleKey("/");
+    for ([_][]const u8{ "a", "p", "i" }) |key| _ = try model.handleKey(key);
+    try std.testing.expectEqual(@as(usize, 2), model.visibleCount());
+    try std.testing.expectEqualStrings("gamma-db", model.visibleLabel(0));
+    try std.testing.expectEqualStrings("alpha-api", model.visibleLabel(1));
+    try std.testing.expectEqual(domain.process.ProcessId.fromInt(1), model.active_proc_id);
+    _ = try model.handleKey("enter");
+
+    _ = try model.handleKey("k");
+    _ = try model.handleKey("p");
+    try std.testing.expect(!model.isPinned("gamma-db"));
+    try std.testing.expectEqual(@as(usize, 0), model.pinned_count);
+}
+
+test "client model sends start stop and restart to marked processes" {
+    var cfg = try test_config.standardClientModelConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(1);
+
+    var views = test_config.standardClientModelViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    try std.testing.expect(try model.handleKey(" ") == null);
+    _ = try model.handleKey("j");
+    _ = try model.handleKey("j");
+    try std.testing.expect(try model.handleKey(" ") == null);
+    try std.testing.expect(model.isMarked("alpha-api"));
+    try std.testing.expect(model.isMarked("gamma-db"));
+
+    const intent = (try model.handleKey("x")).?;
+    try std.testing.expectEqual(ipc.protocol.Command.stop, intent.action);
+    try std.testing.expectEqual(@as(usize, 2), intent.labels.len);
+    try std.testing.expectEqualStrings("gamma-db", intent.labels[1]);
+
+    _ = try model.handleKey(" ");
+    try std.testing.expectEqual(@as(usize, 1), model.markedCount());
+    _ = try model.handleKey("esc");
+    try std.testing.expectEqual(@as(usize, 0), model.markedCount());
+    const single = (try model.handleKey("x")).?;
+    try std.testing.expectEqual(@as(usize, 0), single.labels.len);
+    try std.testing.expectEqualStrings("gamma-db", single.label);
+}
@@ -4,9 +4,15 @@
 const std = @import("std");
 const domain = @import("../domain/root.zig");
 const ipc = @import("../ipc/root.zig");
+const terminal = @import("../terminal/root.zig");
 const test_config = @import("../test_support/config.zig");
 const test_ipc = @import("../test_support/ipc.zig");
 const client_model = @import("client_model.zig");
+const clipboard = @import("clipboard.zig");
+const frame_log = @import("frame_log.zig");
+const pin_state = @import("pin_state.zig");
+
+const log = std.log.scoped(.tui);
 
 /// Transport seam used by Client Session. Production uses `ipc.client.Client`;
 /// tests provide fake snapshots and command results without a socket.
@@ -20,6 +26,28 @@ pub const Transport = struct {
         action: ipc.protocol.Command,
         label: []const u8,
     ) anyerror!CommandResult,
+    send_batch_command: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        action: ipc.protocol.Command,
+        labels: []const []const u8,
+    ) anyerror!CommandResult,
+    fetch_output: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+        lines: u32,
+    ) anyerror!OutputResult,
+    fetch_details: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+    ) anyerror!DetailsResult,
+    fetch_env: *const fn (
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+    ) anyerror!EnvResult,
 
     fn readSnapshot(self: Transport, allocator: std.mem.Allocator) !ipc.protocol.SnapshotUpdate {
         return self.read_snapshot(self.context, allocator);
@@ -37,6 +65,27 @@ pub const Transport = struct {
     ) !CommandResult {
         return self.send_command(self.context, allocator, action, label);
     }
+
+    fn sendBatchCommand(
+        self: Transport,
+        allocator: std.mem.Allocator,
+        action: ipc.protocol.Command,
+        labels: []const []const u8,
+    ) !CommandResult {
+        return self.send_batch_command(self.context, allocator, action, labels);
+    }
+
+    fn fetchOutput(self: Transport, allocator: std.mem.Allocator, label: []const u8, lines: u32) !OutputResult {
+        return self.fetch_output(self.context, allocator, label, lines);
+    }
+
+    fn fetchDetails(self: Transport, allocator: std.mem.Allocator, label: []const u8) !DetailsResult {
+        return self.fetch_details(self.context, allocator, label);
+    }
+
+    fn fetchEnv(self: Transport, allocator: std.mem.Allocator, label: []const u8) !EnvResult {
+        return self.fetch_env(self.context, allocator, label);
+    }
 };
 
 pub const CommandResult = struct {
@@ -48,6 +97,44 @@ pub const CommandResult = struct {
     }
 };
 
+/// The last lines of a process's output, as the primary retains them.
+pub const OutputResult = struct {
+    success: bool,
+    output: []const u8,
+    error_message: []const u8,
+
+    pub fn deinit(self: *const OutputResult, allocator: std.mem.Allocator) void {
+        allocator.free(self.output);
+        allocator.free(self.error_message);
+    }
+};
+
+/// A process's command line and working directory, which snapshots leave out.
+pub const DetailsResult = struct {
+    success: bool,
+    command: []const u8,
+    cwd: []const u8,
+    error_message: []const u8,
+
+    pub fn deinit(self: *const DetailsResult, allocator: std.mem.Allocator) void {
+        allocator.free(self.command);
+        allocator.free(self.cwd);
+        allocator.free(self.error_message);
+    }
+};
+
+/// A process's environment as the primary would start it; `data` is null
+/// when the primary refused the request.
+pub const EnvResult = struct {
+    data: ?ipc.protocol.EnvData,
+    error_message: []const u8,
+
+    pub fn deinit(self: *const EnvResult, allocator: std.mem.Allocator) void {
+        if (self.data) |data| data.deinit();
+        allocator.free(self.error_message);
+    }
+};
+
 pub const KeyInteractionOptions = struct {
     sync_selection_after_command: bool = false,
 };
@@ -67,6 +154,17 @@ pub const ClientSession = struct {
     transport: Transport,
     snapshot_update: *ipc.protocol.SnapshotUpdate,
     model: client_model.ClientModel,
+    /// Set while a debounced selection switch waits to be sent.
+    pending_switch_deadline_ms: ?i64 = null,
+    /// Pin file saved whenever `toggle_pin` changes the pins; empty keeps
+    /// pins for this session only.
+    pin_state_path: []const u8 = "",
+    /// OSC 52 sequence for the next frame to write, until
+    /// `takeClipboardWrite`.
+    clipboard_write: ?[]u8 = null,
+    /// Frame markers for end-to-end tests; off unless `PROCTMUX_FRAME_LOG`
+    /// is set.
+    frame_log: frame_log.FrameLog = .{},
 
     pub fn init(allocator: std.mem.Allocator, transport: Transport) !ClientSession {
         const snapshot_update = try allocator.create(ipc.protocol.SnapshotUpdate);
@@ -81,21 +179,35 @@ pub const ClientSession = struct {
         );
         errdefer model.deinit();
         model.no_color = std.process.hasEnvVarConstant("NO_COLOR");
+        model.light_terminal = terminal.background.current() == .light;
+        model.ascii_icons = std.mem.eql(u8, std.posix.getenv("TERM") orelse "", "dumb");
 
         return .{
             .allocator = allocator,
             .transport = transport,
             .snapshot_update = snapshot_update,
             .model = model,
+            .frame_log = frame_log.FrameLog.fromEnv(),
         };
     }
 
     pub fn deinit(self: *ClientSession) void {
+        self.frame_log.deinit();
+        if (self.clipboard_write) |sequence| self.allocator.free(sequence);
         self.model.deinit();
         self.snapshot_update.deinit();
         self.allocator.destroy(self.snapshot_update);
     }
 
+    /// Pins the labels saved at `path` and keeps saving changes there.
+    /// `path` must outlive the session.
+    pub fn restorePins(self: *ClientSession, path: []const u8) !void {
+        const labels = try pin_state.load(self.allocator, std.fs.cwd(), path);
+        defer pin_state.freeLabels(self.allocator, labels);
+        try self.model.setPinned(labels);
+        self.pin_state_path = path;
+    }
+
     pub fn handleKey(self: *ClientSession, key: []const u8) !void {
         _ = try self.handleKeyAction(key);
     }
@@ -107,7 +219,8 @@ pub const ClientSession = struct {
         key: []const u8,
         options: KeyInteractionOptions,
     ) !KeyInteraction {
-        const action = (try self.handleKeyAction(key)) orelse return .{};
+        // Detaching sends nothing; the primary keeps every process running.
+        const action = (try self.handleKeyAction(key)) orelse return .{ .stop = self.model.takeDetach() };
         if (ipc.protocol.commandNeedsImmediateSnapshotSync(action)) {
             try self.readSnapshotUpdate();
             if (options.sync_selection_after_command) try self.syncSelectionAfterAction(action);
@@ -120,9 +233,15 @@ pub const ClientSession = struct {
     }
 
     pub fn handleKeyAction(self: *ClientSession, key: []const u8) !?ipc.protocol.Command {
-        if (try self.model.handleKey(key)) |intent| {
+        const key_intent = try self.model.handleKey(key);
+        if (self.model.takePinsChanged()) self.savePins();
+        if (self.model.takeCopyRequest()) |target| try self.copySelected(target);
+        if (self.model.takeEnvRequest()) try self.showSelectedEnv();
+        if (key_intent) |intent| {
+            if (intent.labels.len > 0) return self.sendBatch(intent);
+            if (intent.action == .switch_process and self.deferSwitch(std.time.milliTimestamp())) return null;
             if (ipc.protocol.commandRequiresSelectedProcess(intent.action) and intent.label.len == 0) {
-                try self.model.addMessage("no process selected");
+                try self.model.addMessage(.warn, "no process selected");
                 return null;
             }
 
@@ -131,7 +250,8 @@ pub const ClientSession = struct {
                 intent.action,
                 intent.label,
             ) catch |err| {
-                try self.model.addMessage(@errorName(err));
+                log.debug("{s} command for '{s}' failed to send: {s}", .{ @tagName(intent.action), intent.label, @errorName(err) });
+                try self.model.addMessage(.@"error", @errorName(err));
                 return null;
             };
             defer result.deinit(self.allocator);
@@ -141,7 +261,7 @@ pub const ClientSession = struct {
                     "command failed"
                 else
                     result.error_message;
-                try self.model.addMessage(message);
+                try self.model.addMessage(.@"error", message);
                 return null;
             }
             return intent.action;
@@ -149,6 +269,152 @@ pub const ClientSession = struct {
         return null;
     }
 
+    /// Sends a command for every marked process and clears the marks once
+    /// the primary accepts it.
+    fn sendBatch(self: *ClientSession, intent: client_model.CommandIntent) !?ipc.protocol.Command {
+        const result = self.transport.sendBatchCommand(self.allocator, intent.action, intent.labels) catch |err| {
+            log.debug("{s} command for {d} marked processes failed to send: {s}", .{ @tagName(intent.action), intent.labels.len, @errorName(err) });
+            try self.model.addMessage(.@"error", @errorName(err));
+            return null;
+        };
+        defer result.deinit(self.allocator);
+
+        if (!result.success) {
+            try self.model.addMessage(.@"error", if (result.error_message.len == 0) "command failed" else result.error_message);
+            return null;
+        }
+        self.model.clearMarked();
+        return intent.action;
+    }
+
+    /// Puts a detail of the selected process on the clipboard and says what
+    /// was copied, or why nothing was.
+    fn copySelected(self: *ClientSession, target: client_model.CopyTarget) !void {
+        const active = self.model.activeProcessSummary() orelse return;
+        var pid_buffer: [16]u8 = undefined;
+        var fetched: ?OutputResult = null;
+        defer if (fetched) |result| result.deinit(self.allocator);
+        var details: ?DetailsResult = null;
+        defer if (details) |result| result.deinit(self.allocator);
+        var plain: ?[]u8 = null;
+        defer if (plain) |text| self.allocator.free(text);
+
+        const text = switch (target) {
+            .command, .cwd => blk: {
+                details = self.transport.fetchDetails(self.allocator, active.label) catch |err| {
+                    log.debug("details fetch for '{s}' failed: {s}", .{ active.label, @errorName(err) });
+                    try self.model.addMessage(.@"error", @errorName(err));
+                    return;
+                };
+                if (!details.?.success) {
+                    try self.model.addMessage(.@"error", if (details.?.error_message.len == 0) "details fetch failed" else details.?.error_message);
+                    return;
+                }
+                break :blk if (target == .command) details.?.command else details.?.cwd;
+            },
+            .pid => try std.fmt.bufPrint(&pid_buffer, "{d}", .{active.pid}),
+            .output => blk: {
+                fetched = self.transport.fetchOutput(self.allocator, active.label, self.model.snapshot.ui.clipboard_output_lines) catch |err| {
+                    log.debug("output fetch for '{s}' failed: {s}", .{ active.label, @errorName(err) });
+                    try self.model.addMessage(.@"error", @errorName(err));
+                    return;
+                };
+                if (!fetched.?.success) {
+                    try self.model.addMessage(.@"error", if (fetched.?.error_message.len == 0) "output fetch failed" else fetched.?.error_message);
+                    return;
+                }
+                plain = try clipboard.plainText(self.allocator, std.mem.trimRight(u8, fetched.?.output, "\r\n"));
+                break :blk plain.?;
+            },
+        };
+        if (text.len == 0) {
+            try self.model.addMessage(.info, "nothing to copy");
+            return;
+        }
+
+        const delivery = clipboard.copy(self.allocator, text, self.model.snapshot.ui.clipboard_cmd) catch |err| {
+            log.debug("copying {s} of '{s}' failed: {s}", .{ @tagName(target), active.label, @errorName(err) });
+            try self.model.addMessage(.@"error", switch (err) {
+                error.ClipboardTextTooLarge => "too large for OSC 52; install pbcopy, wl-copy, xclip, or xsel, or set clipboard_cmd",
+                else => @errorName(err),
+            });
+            return;
+        };
+        switch (delivery) {
+            .osc52 => |sequence| {
+                if (self.clipboard_write) |pending| self.allocator.free(pending);
+                self.clipboard_write = sequence;
+            },
+            .command => {},
+        }
+        const message = try std.fmt.allocPrint(self.allocator, "copied {s} of {s}", .{ @tagName(target), active.label });
+        defer self.allocator.free(message);
+        try self.model.addMessage(.info, message);
+    }
+
+    /// Opens the environment inspector on the selected process, or says why
+    /// its environment could not be fetched.
+    fn showSelectedEnv(self: *ClientSession) !void {
+        const active = self.model.activeProcessSummary() orelse return;
+        const result = self.transport.fetchEnv(self.allocator, active.label) catch |err| {
+            log.debug("env fetch for '{s}' failed: {s}", .{ active.label, @errorName(err) });
+            try self.model.addMessage(.@"error", @errorName(err));
+            return;
+        };
+        defer result.deinit(self.allocator);
+        const data = result.data orelse {
+            try self.model.addMessage(.@"error", if (result.error_message.len == 0) "env fetch failed" else result.error_message);
+            return;
+        };
+        try self.model.openEnv(active.label, data.variables());
+    }
+
+    /// The clipboard sequence the next frame must write, once; the caller
+    /// frees it with the session allocator.
+    pub fn takeClipboardWrite(self: *ClientSession) ?[]u8 {
+        defer self.clipboard_write = null;
+        return self.clipboard_write;
+    }
+
+    fn savePins(self: *ClientSession) void {
+        if (self.pin_state_path.len == 0) return;
+        pin_state.save(self.allocator, std.fs.cwd(), self.pin_state_path, self.model.pinnedLabels()) catch |err| {
+            log.debug("failed to save pinned processes: {s}", .{@errorName(err)});
+        };
+    }
+
+    /// Holds selection switches back while the user keeps moving, so the
+    /// primary only redraws the process they settle on.
+    fn deferSwitch(self: *ClientSession, now_ms: i64) bool {
+        const debounce_ms = self.model.snapshot.ui.layout.selection_switch_debounce_ms;
+        if (debounce_ms <= 0) return false;
+        self.pending_switch_deadline_ms = now_ms + debounce_ms;
+        return true;
+    }
+
+    /// Called by runtime loops once a frame reaches the terminal.
+    pub fn recordFrame(self: *ClientSession) void {
+        self.frame_log.record(&self.model, std.time.milliTimestamp());
+    }
+
+    /// Milliseconds until a deferred switch is due, for use as a poll timeout.
+    pub fn pendingSwitchTimeoutMs(self: *const ClientSession, now_ms: i64) ?i32 {
+        const deadline = self.pending_switch_deadline_ms orelse return null;
+        if (deadline <= now_ms) return 0;
+        return @intCast(@min(deadline - now_ms, std.math.maxInt(i32)));
+    }
+
+    /// Sends a deferred switch once its debounce window has passed. Returns
+    /// true when a switch was sent.
+    pub fn flushPendingSwitch(self: *ClientSession, now_ms: i64) !bool {
+        const deadline = self.pending_switch_deadline_ms orelse return false;
+        if (now_ms < deadline) return false;
+
+        self.pending_switch_deadline_ms = null;
+        try self.switchToActiveProcess();
+        return true;
+    }
+
     fn syncSelectionAfterAction(self: *ClientSession, action: ipc.protocol.Command) !void {
         switch (action) {
             .start, .restart => try self.switchToActiveProcess(),
@@ -165,7 +431,8 @@ pub const ClientSession = struct {
             .switch_process,
             label,
         ) catch |err| {
-            try self.model.addMessage(@errorName(err));
+            log.debug("switch to '{s}' failed to send: {s}", .{ label, @errorName(err) });
+            try self.model.addMessage(.@"error", @errorName(err));
             return;
         };
         defer result.deinit(self.allocator);
@@ -175,7 +442,7 @@ pub const ClientSession = struct {
                 "command failed"
             else
                 result.error_message;
-            try self.model.addMessage(message);
+            try self.model.addMessage(.@"error", message);
         }
     }
 
@@ -213,6 +480,10 @@ pub const IpcTransport = struct {
             .read_snapshot = readSnapshot,
             .read_latest_snapshot = readLatestSnapshot,
             .send_command = sendCommand,
+            .send_batch_command = sendBatchCommand,
+            .fetch_output = fetchOutput,
+            .fetch_details = fetchDetails,
+            .fetch_env = fetchEnv,
         };
     }
 
@@ -245,6 +516,90 @@ pub const IpcTransport = struct {
             };
         }
 
+        return readCommandResult(client, allocator, request_id);
+    }
+
+    fn sendBatchCommand(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        action: ipc.protocol.Command,
+        labels: []const []const u8,
+    ) anyerror!CommandResult {
+        const client: *ipc.client.Client = @ptrCast(@alignCast(context));
+        const request_id = try client.sendBatchCommand(action, labels);
+        return readCommandResult(client, allocator, request_id);
+    }
+
+    fn fetchOutput(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+        lines: u32,
+    ) anyerror!OutputResult {
+        const client: *ipc.client.Client = @ptrCast(@alignCast(context));
+        const reply = try client.fetchScrollback(label, .{ .lines = lines });
+        defer reply.deinit(client.allocator);
+        return switch (reply) {
+            .data => |data| .{
+                .success = true,
+                .output = try allocator.dupe(u8, data.data),
+                .error_message = try allocator.dupe(u8, ""),
+            },
+            .refused => |response| .{
+                .success = false,
+                .output = try allocator.dupe(u8, ""),
+                .error_message = try allocator.dupe(u8, response.error_message),
+            },
+        };
+    }
+
+    fn fetchDetails(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+    ) anyerror!DetailsResult {
+        const client: *ipc.client.Client = @ptrCast(@alignCast(context));
+        const reply = try client.fetchDetails(label);
+        defer reply.deinit(client.allocator);
+        return switch (reply) {
+            .data => |data| .{
+                .success = true,
+                .command = try allocator.dupe(u8, data.command),
+                .cwd = try allocator.dupe(u8, data.cwd),
+                .error_message = try allocator.dupe(u8, ""),
+            },
+            .refused => |response| .{
+                .success = false,
+                .command = try allocator.dupe(u8, ""),
+                .cwd = try allocator.dupe(u8, ""),
+                .error_message = try allocator.dupe(u8, response.error_message),
+            },
+        };
+    }
+
+    fn fetchEnv(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+    ) anyerror!EnvResult {
+        const client: *ipc.client.Client = @ptrCast(@alignCast(context));
+        const reply = try client.fetchEnv(label);
+        return switch (reply) {
+            .data => |data| .{
+                .data = data,
+                .error_message = try allocator.dupe(u8, ""),
+            },
+            .refused => |response| blk: {
+                defer response.deinit(client.allocator);
+                break :blk .{
+                    .data = null,
+                    .error_message = try allocator.dupe(u8, response.error_message),
+                };
+            },
+        };
+    }
+
+    fn readCommandResult(client: *ipc.client.Client, allocator: std.mem.Allocator, request_id: u64) !CommandResult {
         var response = try client.readResponseFor(request_id);
         defer response.deinit(client.allocator);
         return .{
@@ -364,6 +719,107 @@ test "client session records command failures as messages without exiting" {
     try std.testing.expectEqualStrings("already running", session.model.message(0));
 }
 
+test "client session sends marked processes as one batch command" {
+    var cfg = try test_config.standardSessionConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var fake_controller = test_ipc.FakeProcessController{ .running_id = domain.process.ProcessId.fromInt(2) };
+    const line = try test_ipc.snapshotLineFromAppState(
+        std.testing.allocator,
+        &app_state,
+        fake_controller.controller(),
+    );
+    defer std.testing.allocator.free(line);
+
+    var fake = FakeTransport{ .snapshot_line = line };
+    var session = try ClientSession.init(std.testing.allocator, FakeTransport.transport(&fake));
+    defer session.deinit();
+
+    try std.testing.expectEqual(@as(?ipc.protocol.Command, null), try session.handleKeyAction(" "));
+    session.model.active_proc_id = domain.process.ProcessId.fromInt(3);
+    _ = try session.handleKeyAction(" ");
+
+    const action = try session.handleKeyAction("x");
+
+    try std.testing.expectEqual(ipc.protocol.Command.stop, action.?);
+    try std.testing.expectEqual(@as(usize, 2), fake.last_batch_len);
+    try std.testing.expectEqual(@as(usize, 0), fake.last_label_len);
+    try std.testing.expectEqual(@as(usize, 0), session.model.markedCount());
+}
+
+test "client session copies selected process details with OSC 52" {
+    var cfg = try test_config.standardSessionConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var fake_controller = test_ipc.FakeProcessController{ .running_id = domain.process.ProcessId.fromInt(2) };
+    const line = try test_ipc.snapshotLineFromAppState(
+        std.testing.allocator,
+        &app_state,
+        fake_controller.controller(),
+    );
+    defer std.testing.allocator.free(line);
+
+    var fake = FakeTransport{
+        .snapshot_line = line,
+        .output = "\x1b[31mboom\x1b[0m\r\n",
+        .command = "go run ./cmd/worker",
+    };
+    var session = try ClientSession.init(std.testing.allocator, FakeTransport.transport(&fake));
+    defer session.deinit();
+
+    try session.handleKey("y");
+    const command = session.takeClipboardWrite().?;
+    defer std.testing.allocator.free(command);
+    try std.testing.expectEqualStrings("\x1b]52;c;Z28gcnVuIC4vY21kL3dvcmtlcg==\x07", command);
+    try std.testing.expectEqualStrings("copied command of beta-worker", session.model.message(0));
+    try std.testing.expectEqualStrings("beta-worker", fake.lastLabel());
+
+    try session.handleKey("Y");
+    const output = session.takeClipboardWrite().?;
+    defer std.testing.allocator.free(output);
+    try std.testing.expectEqualStrings("\x1b]52;c;Ym9vbQ==\x07", output);
+    try std.testing.expectEqual(domain.client_snapshot.default_clipboard_output_lines, fake.last_output_lines);
+    try std.testing.expect(session.takeClipboardWrite() == null);
+}
+
+test "client session opens the environment inspector on the selected process" {
+    var cfg = try test_config.standardSessionConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var fake_controller = test_ipc.FakeProcessController{ .running_id = domain.process.ProcessId.fromInt(2) };
+    const line = try test_ipc.snapshotLineFromAppState(
+        std.testing.allocator,
+        &app_state,
+        fake_controller.controller(),
+    );
+    defer std.testing.allocator.free(line);
+
+    var fake = FakeTransport{ .snapshot_line = line, .env = &.{
+        .{ .name = "PORT", .value = "4000", .source = "config", .overridden = "3000" },
+    } };
+    var session = try ClientSession.init(std.testing.allocator, FakeTransport.transport(&fake));
+    defer session.deinit();
+
+    try session.handleKey("E");
+    const view = session.model.env_view.?;
+    try std.testing.expectEqualStrings("beta-worker", view.label);
+    try std.testing.expectEqual(@as(usize, 1), view.variables.len);
+    try std.testing.expectEqualStrings("4000", view.variables[0].value);
+    try std.testing.expectEqualStrings("3000", view.variables[0].overridden.?);
+}
+
 test "client session records no process selected locally without IPC command" {
     var cfg = try test_config.standardSessionConfig(std.testing.allocator);
     defer cfg.deinit();
@@ -480,6 +936,41 @@ test "client session preserves local ui state across snapshot updates" {
     try std.testing.expectEqual(domain.process.ProcessId.fromInt(3), session.model.active_proc_id);
 }
 
+test "client session debounces selection switches" {
+    var cfg = try test_config.standardSessionConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.layout.selection_switch_debounce_ms = 200;
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(1);
+
+    var fake_controller = test_ipc.FakeProcessController{ .running_id = domain.process.ProcessId.fromInt(2) };
+    const line = try test_ipc.snapshotLineFromAppState(
+        std.testing.allocator,
+        &app_state,
+        fake_controller.controller(),
+    );
+    defer std.testing.allocator.free(line);
+
+    var fake = FakeTransport{ .snapshot_line = line };
+    var session = try ClientSession.init(std.testing.allocator, FakeTransport.transport(&fake));
+    defer session.deinit();
+
+    try session.handleKey("j");
+    try session.handleKey("j");
+
+    try std.testing.expectEqual(@as(?ipc.protocol.Command, null), fake.last_action);
+    const deadline = session.pending_switch_deadline_ms.?;
+    try std.testing.expect(!try session.flushPendingSwitch(deadline - 1));
+    try std.testing.expectEqual(@as(?i32, 1), session.pendingSwitchTimeoutMs(deadline - 1));
+
+    try std.testing.expect(try session.flushPendingSwitch(deadline));
+    try std.testing.expectEqual(ipc.protocol.Command.switch_process, fake.last_action.?);
+    try std.testing.expectEqualStrings(session.model.activeProcessLabel(), fake.lastLabel());
+    try std.testing.expectEqual(@as(?i32, null), session.pendingSwitchTimeoutMs(deadline));
+}
+
 const FakeTransport = struct {
     snapshot_line: []const u8,
     next_snapshot_line: ?[]const u8 = null,
@@ -489,6 +980,11 @@ const FakeTransport = struct {
     last_action: ?ipc.protocol.Command = null,
     last_label_buf: [64]u8 = undefined,
     last_label_len: usize = 0,
+    last_batch_len: usize = 0,
+    output: []const u8 = "",
+    last_output_lines: u32 = 0,
+    command: []const u8 = "",
+    env: []const ipc.protocol.EnvVariable = &.{},
 
     fn transport(self: *FakeTransport) Transport {
         return .{
@@ -496,6 +992,10 @@ const FakeTransport = struct {
             .read_snapshot = readSnapshot,
             .read_latest_snapshot = readSnapshot,
             .send_command = sendCommand,
+            .send_batch_command = sendBatchCommand,
+            .fetch_output = fetchOutput,
+            .fetch_details = fetchDetails,
+            .fetch_env = fetchEnv,
         };
     }
 
@@ -528,4 +1028,64 @@ const FakeTransport = struct {
             .error_message = try allocator.dupe(u8, self.command_error_message),
         };
     }
+
+    fn sendBatchCommand(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        action: ipc.protocol.Command,
+        labels: []const []const u8,
+    ) anyerror!CommandResult {
+        const self: *FakeTransport = @ptrCast(@alignCast(context));
+        self.last_action = action;
+        self.last_batch_len = labels.len;
+        return .{
+            .success = self.command_success,
+            .error_message = try allocator.dupe(u8, self.command_error_message),
+        };
+    }
+
+    fn fetchOutput(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        _: []const u8,
+        lines: u32,
+    ) anyerror!OutputResult {
+        const self: *FakeTransport = @ptrCast(@alignCast(context));
+        self.last_output_lines = lines;
+        return .{
+            .success = true,
+            .output = try allocator.dupe(u8, self.output),
+            .error_message = try allocator.dupe(u8, ""),
+        };
+    }
+
+    fn fetchDetails(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        label: []const u8,
+    ) anyerror!DetailsResult {
+        const self: *FakeTransport = @ptrCast(@alignCast(context));
+        @memcpy(self.last_label_buf[0..label.len], label);
+        self.last_label_len = label.len;
+        return .{
+            .success = true,
+            .command = try allocator.dupe(u8, self.command),
+            .cwd = try allocator.dupe(u8, ""),
+            .error_message = try allocator.dupe(u8, ""),
+        };
+    }
+
+    fn fetchEnv(
+        context: *anyopaque,
+        allocator: std.mem.Allocator,
+        _: []const u8,
+    ) anyerror!EnvResult {
+        const self: *FakeTransport = @ptrCast(@alignCast(context));
+        const line = try ipc.protocol.envDataLine(allocator, 1, self.env);
+        defer allocator.free(line);
+        return .{
+            .data = try ipc.protocol.parseEnvDataLine(allocator, line),
+            .error_message = try allocator.dupe(u8, ""),
+        };
+    }
 };
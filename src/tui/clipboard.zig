@@ -0,0 +1,167 @@
+//! Clipboard delivery for copied process details.
+//! Text reaches the clipboard as an OSC 52 sequence written with the next frame, which works over SSH and needs nothing installed. Text too large for terminals to accept goes to a local clipboard tool instead, and `clipboard_cmd` replaces both.
+
+const std = @import("std");
+
+/// Largest text sent with OSC 52; its base64 stays under the 100000 bytes
+/// xterm and most terminals that copy it accept.
+pub const max_osc52_bytes = 74_994;
+
+pub const Delivery = union(enum) {
+    /// Sequence to write to the terminal; owned by the caller.
+    osc52: []u8,
+    /// Name of the command that took the text.
+    command: []const u8,
+};
+
+const Tool = struct {
+    argv: []const []const u8,
+    /// Set for tools that only work while this variable names a display.
+    display_env: ?[]const u8 = null,
+};
+
+/// Tried in order when text is too large for OSC 52.
+const tools = [_]Tool{
+    .{ .argv = &.{"pbcopy"} },
+    .{ .argv = &.{"wl-copy"}, .display_env = "WAYLAND_DISPLAY" },
+    .{ .argv = &.{ "xclip", "-selection", "clipboard" }, .display_env = "DISPLAY" },
+    .{ .argv = &.{ "xsel", "--clipboard", "--input" }, .display_env = "DISPLAY" },
+};
+
+/// Copies `text` with `clipboard_cmd` when set, otherwise with OSC 52, or a
+/// detected tool once the text is too large for it.
+pub fn copy(allocator: std.mem.Allocator, text: []const u8, clipboard_cmd: []const []const u8) !Delivery {
+    if (clipboard_cmd.len > 0) {
+        try runTool(allocator, clipboard_cmd, text);
+        return .{ .command = clipboard_cmd[0] };
+    }
+    if (text.len <= max_osc52_bytes) return .{ .osc52 = try osc52(allocator, text) };
+
+    const tool = detectTool() orelse return error.ClipboardTextTooLarge;
+    try runTool(allocator, tool, text);
+    return .{ .command = tool[0] };
+}
+
+/// OSC 52 sequence setting the system clipboard to `text`. The caller owns
+/// the result.
+pub fn osc52(allocator: std.mem.Allocator, text: []const u8) ![]u8 {
+    const prefix = "\x1b]52;c;";
+    const suffix = "\x07";
+    const encoder = std.base64.standard.Encoder;
+    const encoded_len = encoder.calcSize(text.len);
+
+    const out = try allocator.alloc(u8, prefix.len + encoded_len + suffix.len);
+    @memcpy(out[0..prefix.len], prefix);
+    _ = encoder.encode(out[prefix.len..][0..encoded_len], text);
+    @memcpy(out[prefix.len + encoded_len ..], suffix);
+    return out;
+}
+
+fn detectTool() ?[]const []const u8 {
+    const path = std.posix.getenv("PATH") orelse return null;
+    for (tools) |tool| {
+        if (tool.display_env) |name| {
+            if ((std.posix.getenv(name) orelse "").len == 0) continue;
+        }
+        if (onPath(path, tool.argv[0])) return tool.argv;
+    }
+    return null;
+}
+
+fn onPath(path: []const u8, name: []const u8) bool {
+    var buffer: [std.fs.max_path_bytes]u8 = undefined;
+    var dirs = std.mem.tokenizeScalar(u8, path, ':');
+    while (dirs.next()) |dir| {
+        if (!std.fs.path.isAbsolute(dir)) continue;
+        const candidate = std.fmt.bufPrint(&buffer, "{s}/{s}", .{ dir, name }) catch continue;
+        std.fs.accessAbsolute(candidate, .{}) catch continue;
+        return true;
+    }
+    return false;
+}
+
+/// Pipes `text` to `argv` and waits for it to exit. Tools that keep serving
+/// the selection fork first, so this returns once the text is handed over.
+fn runTool(allocator: std.mem.Allocator, argv: []const []const u8, text: []const u8) !void {
+    var child = std.process.Child.init(argv, allocator);
+    child.stdin_behavior = .Pipe;
+    child.stdout_behavior = .Ignore;
+    child.stderr_behavior = .Ignore;
+    try child.spawn();
+
+    child.stdin.?.writeAll(text) catch {};
+    child.stdin.?.close();
+    child.stdin = null;
+    switch (try child.wait()) {
+        .Exited => |code| if (code != 0) return error.ClipboardCommandFailed,
+        else => return error.ClipboardCommandFailed,
+    }
+}
+
+/// Output as it reads on screen: escape sequences and stray control bytes
+/// dropped, and each line cut to the text after its last carriage return.
+/// The caller owns the result.
+pub fn plainText(allocator: std.mem.Allocator, output: []const u8) ![]u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+
+    var lines = std.mem.splitScalar(u8, output, '\n');
+    var first = true;
+    while (lines.next()) |raw| {
+        if (!first) try out.append('\n');
+        first = false;
+        const line = std.mem.trimRight(u8, raw, "\r");
+        const redrawn = if (std.mem.lastIndexOfScalar(u8, line, '\r')) |index| line[index + 1 ..] else line;
+        try appendPlain(&out, redrawn);
+    }
+    return out.toOwnedSlice();
+}
+
+fn appendPlain(out: *std.array_list.Managed(u8), line: []const u8) !void {
+    var index: usize = 0;
+    while (index < line.len) {
+        const byte = line[index];
+        if (byte != 0x1b) {
+            if (byte >= 0x20 or byte == '\t') try out.append(byte);
+            index += 1;
+            continue;
+        }
+        index += 1;
+        if (index >= line.len) break;
+        switch (line[index]) {
+            '[' => {
+                index += 1;
+                while (index < line.len and !(line[index] >= 0x40 and line[index] <= 0x7e)) : (index += 1) {}
+                index += 1;
+            },
+            // OSC and other strings end at BEL or ST.
+            ']', 'P', 'X', '^', '_' => {
+                index += 1;
+                while (index < line.len) : (index += 1) {
+                    if (line[index] == 0x07) break;
+                    if (line[index] == 0x1b and index + 1 < line.len and line[index + 1] == '\\') {
+                        index += 1;
+                        break;
+                    }
+                }
+                index += 1;
+            },
+            else => index += 1,
+        }
+    }
+}
+
+test "osc52 wraps base64 text for the system clipboard" {
+    const sequence = try osc52(std.testing.allocator, "npm run dev");
+    defer std.testing.allocator.free(sequence);
+    try std.testing.expectEqualStrings("\x1b]52;c;bnBtIHJ1biBkZXY=\x07", sequence);
+}
+
+test "plain text drops escape sequences and redrawn progress" {
+    const text = try plainText(
+        std.testing.allocator,
+        "\x1b[32mready\x1b[0m on :3000\r\n\x1b]8;;http://x\x07link\x1b]8;;\x1b\\\n 10%\r 90%\r\ndone\x07",
+    );
+    defer std.testing.allocator.free(text);
+    try std.testing.expectEqualStrings("ready on :3000\nlink\n 90%\ndone", text);
+}
@@ -0,0 +1,145 @@
+//! Style color parsing for the TUI.
+//! Every configured color goes through here, so names, 256-color indexes, and `#rrggbb`/`#rgb` truecolor hex work the same in every field.
+
+const std = @import("std");
+
+pub const Layer = enum {
+    foreground,
+    background,
+};
+
+/// Longest parameter list `sgr` produces: `48;2;255;255;255`.
+pub const max_sgr_len = 16;
+
+const named_colors = [_]struct {
+    name: []const u8,
+    code: u8,
+}{
+    .{ .name = "black", .code = 30 },
+    .{ .name = "red", .code = 31 },
+    .{ .name = "green", .code = 32 },
+    .{ .name = "yellow", .code = 33 },
+    .{ .name = "blue", .code = 34 },
+    .{ .name = "magenta", .code = 35 },
+    .{ .name = "cyan", .code = 36 },
+    .{ .name = "white", .code = 37 },
+    .{ .name = "brightblack", .code = 90 },
+    .{ .name = "gray", .code = 90 },
+    .{ .name = "grey", .code = 90 },
+    .{ .name = "brightred", .code = 91 },
+    .{ .name = "lightred", .code = 91 },
+    .{ .name = "brightgreen", .code = 92 },
+    .{ .name = "lightgreen", .code = 92 },
+    .{ .name = "brightyellow", .code = 93 },
+    .{ .name = "brightblue", .code = 94 },
+    .{ .name = "brightmagenta", .code = 95 },
+    .{ .name = "brightcyan", .code = 96 },
+    .{ .name = "brightwhite", .code = 97 },
+    .{ .name = "ansiblack", .code = 30 },
+    .{ .name = "ansired", .code = 31 },
+    .{ .name = "ansigreen", .code = 32 },
+    .{ .name = "ansiyellow", .code = 33 },
+    .{ .name = "ansiblue", .code = 34 },
+    .{ .name = "ansimagenta", .code = 35 },
+    .{ .name = "ansicyan", .code = 36 },
+    .{ .name = "ansiwhite", .code = 37 },
+    .{ .name = "ansibrightblack", .code = 90 },
+    .{ .name = "ansigray", .code = 90 },
+    .{ .name = "ansigrey", .code = 90 },
+    .{ .name = "ansibrightred", .code = 91 },
+    .{ .name = "ansibrightgreen", .code = 92 },
+    .{ .name = "ansibrightyellow", .code = 93 },
+    .{ .name = "ansibrightblue", .code = 94 },
+    .{ .name = "ansibrightmagenta", .code = 95 },
+    .{ .name = "ansibrightcyan", .code = 96 },
+    .{ .name = "ansibrightwhite", .code = 97 },
+};
+
+/// Writes the SGR parameters for `color` into `buffer`, e.g. `32`,
+/// `38;5;208`, or `48;2;40;42;54`. Empty, `none`, and unrecognized colors
+/// return null so callers print the text unstyled.
+pub fn sgr(buffer: *[max_sgr_len]u8, color: []const u8, layer: Layer) ?[]const u8 {
+    const trimmed = std.mem.trim(u8, color, " \t\r\n");
+    if (trimmed.len == 0 or std.ascii.eqlIgnoreCase(trimmed, "none")) return null;
+    const offset: u8 = if (layer == .background) 10 else 0;
+    const extended: u8 = if (layer == .background) 48 else 38;
+
+    if (trimmed[0] == '#') {
+        const rgb = parseHex(trimmed[1..]) orelse return null;
+        return std.fmt.bufPrint(buffer, "{d};2;{d};{d};{d}", .{ extended, rgb[0], rgb[1], rgb[2] }) catch unreachable;
+    }
+
+    for (named_colors) |entry| {
+        if (std.ascii.eqlIgnoreCase(trimmed, entry.name)) {
+            return std.fmt.bufPrint(buffer, "{d}", .{entry.code + offset}) catch unreachable;
+        }
+    }
+
+    const index = std.fmt.parseUnsigned(u8, trimmed, 10) catch return null;
+    if (index <= 7) return std.fmt.bufPrint(buffer, "{d}", .{30 + offset + index}) catch unreachable;
+    if (index <= 15) return std.fmt.bufPrint(buffer, "{d}", .{90 + offset + index - 8}) catch unreachable;
+    return std.fmt.bufPrint(buffer, "{d};5;{d}", .{ extended, index }) catch unreachable;
+}
+
+/// Writes `text` wrapped in the foreground and background colors, or plain
+/// when neither color applies.
+pub fn appendStyled(
+    out: *std.array_list.Managed(u8),
+    text: []const u8,
+    foreground: []const u8,
+    background: []const u8,
+) !void {
+    var fg_buffer: [max_sgr_len]u8 = undefined;
+    var bg_buffer: [max_sgr_len]u8 = undefined;
+    const fg = sgr(&fg_buffer, foreground, .foreground);
+    const bg = sgr(&bg_buffer, background, .background);
+    if (fg == null and bg == null) return out.appendSlice(text);
+
+    try out.appendSlice("\x1b[");
+    if (fg) |params| try out.appendSlice(params);
+    if (fg != null and bg != null) try out.append(';');
+    if (bg) |params| try out.appendSlice(params);
+    try out.append('m');
+    try out.appendSlice(text);
+    try out.appendSlice("\x1b[0m");
+}
+
+fn parseHex(digits: []const u8) ?[3]u8 {
+    var rgb: [3]u8 = undefined;
+    switch (digits.len) {
+        6 => for (&rgb, 0..) |*channel, index| {
+            channel.* = std.fmt.parseUnsigned(u8, digits[index * 2 ..][0..2], 16) catch return null;
+        },
+        3 => for (&rgb, 0..) |*channel, index| {
+            const nibble = std.fmt.charToDigit(digits[index], 16) catch return null;
+            channel.* = nibble * 17;
+        },
+        else => return null,
+    }
+    return rgb;
+}
+
+test "colors resolve names, palette indexes, and hex for both layers" {
+    var buffer: [max_sgr_len]u8 = undefined;
+    try std.testing.expectEqualStrings("32", sgr(&buffer, "green", .foreground).?);
+    try std.testing.expectEqualStrings("45", sgr(&buffer, "Magenta", .background).?);
+    try std.testing.expectEqualStrings("93", sgr(&buffer, "brightyellow", .foreground).?);
+    try std.testing.expectEqualStrings("31", sgr(&buffer, "1", .foreground).?);
+    try std.testing.expectEqualStrings("100", sgr(&buffer, "8", .background).?);
+    try std.testing.expectEqualStrings("38;5;208", sgr(&buffer, "208", .foreground).?);
+    try std.testing.expectEqualStrings("38;2;80;250;123", sgr(&buffer, "#50fa7b", .foreground).?);
+    try std.testing.expectEqualStrings("48;2;255;255;255", sgr(&buffer, "#FFF", .background).?);
+    try std.testing.expect(sgr(&buffer, "", .foreground) == null);
+    try std.testing.expect(sgr(&buffer, "none", .foreground) == null);
+    try std.testing.expect(sgr(&buffer, "#12345", .foreground) == null);
+    try std.testing.expect(sgr(&buffer, "chartreuse", .foreground) == null);
+}
+
+test "styled text combines foreground and background" {
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try appendStyled(&out, "api", "white", "#44475a");
+    try appendStyled(&out, " plain", "", "none");
+    try std.testing.expectEqualStrings("\x1b[37;48;2;68;71;90mapi\x1b[0m plain", out.items);
+}
@@ -0,0 +1,57 @@
+//! Test-only frame markers.
+//! With `PROCTMUX_FRAME_LOG` set to a path, every TUI frame written to the terminal appends one line to that file: its sequence number, how many messages it shows, and the selected process. End-to-end tests wait for the frame that reflects a change instead of sleeping. Unset, nothing is opened or written.
+
+const std = @import("std");
+const client_model = @import("client_model.zig");
+
+pub const env_name = "PROCTMUX_FRAME_LOG";
+
+pub const FrameLog = struct {
+    file: ?std.fs.File = null,
+    sequence: u64 = 0,
+
+    /// Truncates and opens the file `PROCTMUX_FRAME_LOG` names. A path that
+    /// cannot be opened leaves the log off rather than failing the TUI.
+    pub fn fromEnv() FrameLog {
+        const path = std.posix.getenv(env_name) orelse return .{};
+        if (path.len == 0) return .{};
+        const file = std.fs.cwd().createFile(path, .{}) catch return .{};
+        return .{ .file = file };
+    }
+
+    pub fn deinit(self: *FrameLog) void {
+        if (self.file) |file| file.close();
+        self.* = .{};
+    }
+
+    /// Marks one frame as written. Call after the frame's bytes reach the
+    /// terminal, so a test that sees the line can read the screen.
+    pub fn record(self: *FrameLog, model: *const client_model.ClientModel, now_ms: i64) void {
+        const file = self.file orelse return;
+        self.sequence += 1;
+        const active = if (model.activeProcessSummary()) |summary| summary.label else "";
+        var buffer: [256]u8 = undefined;
+        const line = formatLine(&buffer, self.sequence, model.visibleMessageCount(now_ms), active);
+        file.writeAll(line) catch {};
+    }
+};
+
+/// `frame=<n> messages=<count> active=<label>`; a label too long for
+/// `buffer` is cut short.
+fn formatLine(buffer: []u8, sequence: u64, messages: usize, active: []const u8) []const u8 {
+    const head = std.fmt.bufPrint(buffer, "frame={d} messages={d} active=", .{ sequence, messages }) catch unreachable;
+    const room = buffer.len - head.len - 1;
+    const label = active[0..@min(active.len, room)];
+    @memcpy(buffer[head.len..][0..label.len], label);
+    buffer[head.len + label.len] = '\n';
+    return buffer[0 .. head.len + label.len + 1];
+}
+
+test "frame log lines name the frame, its messages, and the selection" {
+    var buffer: [256]u8 = undefined;
+    try std.testing.expectEqualStrings("frame=3 messages=1 active=api\n", formatLine(&buffer, 3, 1, "api"));
+    try std.testing.expectEqualStrings("frame=1 messages=0 active=\n", formatLine(&buffer, 1, 0, ""));
+
+    var small: [32]u8 = undefined;
+    try std.testing.expectEqualStrings("frame=1 messages=0 active=long-\n", formatLine(&small, 1, 0, "long-label-that-does-not-fit"));
+}
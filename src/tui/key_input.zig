@@ -13,6 +13,8 @@ const escape_sequences = [_]KeySequence{
     .{ .bytes = "\x1b[1;5B", .key = "ctrl+down" },
     .{ .bytes = "\x1b[1;5C", .key = "ctrl+right" },
     .{ .bytes = "\x1b[1;5D", .key = "ctrl+left" },
+    .{ .bytes = "\x1b[1;6C", .key = "ctrl+shift+right" },
+    .{ .bytes = "\x1b[1;6D", .key = "ctrl+shift+left" },
     .{ .bytes = "\x1b[5A", .key = "ctrl+up" },
     .{ .bytes = "\x1b[5B", .key = "ctrl+down" },
     .{ .bytes = "\x1b[15~", .key = "f5" },
@@ -187,6 +189,10 @@ test "key input maps arrow and ctrl-arrow sequences" {
     index = 0;
     try std.testing.expectEqualStrings("ctrl+left", keyForInput("\x1b[1;5D", &index, &scratch).?);
     try std.testing.expectEqual(@as(usize, 6), index);
+
+    index = 0;
+    try std.testing.expectEqualStrings("ctrl+shift+right", keyForInput("\x1b[1;6C", &index, &scratch).?);
+    try std.testing.expectEqual(@as(usize, 6), index);
 }
 
 test "key input maps modified character escape sequences" {
@@ -0,0 +1,70 @@
+//! Pinned process file.
+//! Labels pinned with `toggle_pin` are saved per Project Config in the state directory, one per line, so every client mode reopens with the same pins.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+
+/// A larger pin file reads as no pins.
+const max_file_bytes = 16 * 1024;
+
+/// Returns the pin file path for `cfg`. The caller owns the result.
+pub fn pathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+    const dir = try config.paths.stateDir(allocator, cfg, config.paths.Env.current());
+    defer allocator.free(dir);
+    const hash = try config.hash.toHash(allocator, cfg);
+    defer allocator.free(hash);
+
+    return std.fmt.allocPrint(allocator, "{s}/proctmux-{s}.pins", .{ std.mem.trimRight(u8, dir, "/"), hash });
+}
+
+/// Reads saved pin labels; a missing or unreadable file reads as no pins.
+/// The caller frees the result with `freeLabels`.
+pub fn load(allocator: std.mem.Allocator, dir: std.fs.Dir, path: []const u8) ![][]const u8 {
+    const contents = dir.readFileAlloc(allocator, path, max_file_bytes) catch |err| switch (err) {
+        error.OutOfMemory => return err,
+        else => return allocator.alloc([]const u8, 0),
+    };
+    defer allocator.free(contents);
+
+    var labels = std.array_list.Managed([]const u8).init(allocator);
+    errdefer freeLabels(allocator, labels.items);
+    var lines = std.mem.splitScalar(u8, contents, '\n');
+    while (lines.next()) |line| {
+        const label = std.mem.trim(u8, line, " \t\r");
+        if (label.len == 0) continue;
+        try labels.append(try allocator.dupe(u8, label));
+    }
+    return labels.toOwnedSlice();
+}
+
+pub fn freeLabels(allocator: std.mem.Allocator, labels: []const []const u8) void {
+    for (labels) |label| allocator.free(label);
+    allocator.free(labels);
+}
+
+pub fn save(allocator: std.mem.Allocator, dir: std.fs.Dir, path: []const u8, labels: []const []const u8) !void {
+    var contents = std.array_list.Managed(u8).init(allocator);
+    defer contents.deinit();
+    for (labels) |label| {
+        try contents.appendSlice(label);
+        try contents.append('\n');
+    }
+    if (std.fs.path.dirname(path)) |parent| try dir.makePath(parent);
+    try dir.writeFile(.{ .sub_path = path, .data = contents.items });
+}
+
+test "pin state round-trips labels and reads a missing file as empty" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    const missing = try load(std.testing.allocator, tmp.dir, "state/pins");
+    defer freeLabels(std.testing.allocator, missing);
+    try std.testing.expectEqual(@as(usize, 0), missing.len);
+
+    try save(std.testing.allocator, tmp.dir, "state/pins", &.{ "api", "db" });
+    const labels = try load(std.testing.allocator, tmp.dir, "state/pins");
+    defer freeLabels(std.testing.allocator, labels);
+    try std.testing.expectEqual(@as(usize, 2), labels.len);
+    try std.testing.expectEqualStrings("api", labels[0]);
+    try std.testing.expectEqualStrings("db", labels[1]);
+}
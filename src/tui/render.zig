@@ -7,6 +7,13 @@ const domain = @import("../domain/root.zig");
 const test_ansi = @import("../test_support/ansi.zig");
 const test_config = @import("../test_support/config.zig");
 const client_model = @import("client_model.zig");
+const color = @import("color.zig");
+
+const pin_separator_width = 24;
+const preview_color = "brightblack";
+const preview_indent = "    ";
+/// Narrowest suffix preview worth showing after the label.
+const min_preview_suffix_width = 4;
 
 /// Renders the process-list pane from local UI state and the current Client
 /// Snapshot. The renderer does not mutate model or perform IPC.
@@ -14,10 +21,26 @@ pub fn renderProcessList(allocator: std.mem.Allocator, model: *const client_mode
     var out = std.array_list.Managed(u8).init(allocator);
     errdefer out.deinit();
 
+    try appendConnectionBanner(&out, model);
+    if (model.quit_prompt) {
+        try appendQuitPrompt(&out, model);
+        return out.toOwnedSlice();
+    }
+    if (model.show_history) {
+        const height = if (model.term_height == 0) 0 else model.term_height -| renderedLineCount(out.items);
+        try appendMessageHistory(&out, model, model.term_width, height);
+        return out.toOwnedSlice();
+    }
+    if (model.env_view != null) {
+        const height = if (model.term_height == 0) 0 else model.term_height -| renderedLineCount(out.items);
+        try appendEnvView(&out, model, model.term_width, height);
+        return out.toOwnedSlice();
+    }
     try appendProcessHeader(&out, model);
     try appendHelpPanel(&out, model);
     try appendSelectedDescription(&out, model);
     try appendMessagesPanel(&out, model);
+    try appendStartupPanel(&out, model);
     try appendFilterPanel(&out, model);
 
     const processes = model.visibleProcesses();
@@ -26,28 +49,37 @@ pub fn renderProcessList(allocator: std.mem.Allocator, model: *const client_mode
         return out.toOwnedSlice();
     }
 
-    const process_start = selectedProcessWindowStart(
-        model,
-        renderedLineCount(out.items),
-        processes.len,
-    );
-    const process_end = selectedProcessWindowEnd(model, renderedLineCount(out.items), process_start);
+    // The separator under pinned processes takes a row from the window.
+    const has_separator = model.pinned_count > 0 and model.pinned_count < processes.len;
+    const reserved_lines = renderedLineCount(out.items) + @intFromBool(has_separator);
+    const process_start = selectedProcessWindowStart(model, reserved_lines, processes.len);
+    const process_end = selectedProcessWindowEnd(model, reserved_lines, process_start);
 
+    const preview = lastLinePreview(model);
     for (processes[process_start..process_end], process_start..) |summary, index| {
+        if (has_separator and index == model.pinned_count and index > process_start) try appendPinSeparator(&out, model);
+        const row_start = out.items.len;
         const selected = if (model.active_proc_id.isNone())
             index == 0
         else
             domain.process.ProcessId.fromInt(summary.id) == model.active_proc_id;
         if (selected) {
-            try out.appendSlice(model.snapshot.ui.style.pointer_char);
+            try out.appendSlice(model.icons().pointer);
             try out.append(' ');
         } else {
             try out.appendSlice("  ");
         }
 
-        try appendStatusMarker(&out, &model.snapshot.ui.style, summary.status, !model.no_color);
+        // The mark column only appears while something is marked.
+        if (model.markedCount() > 0) {
+            try out.appendSlice(if (!model.isMarked(summary.label)) " " else if (model.ascii_icons) "#" else "◆");
+            try out.append(' ');
+        }
+        try appendCategorySwatch(&out, model, summary);
+        try appendStatusMarker(&out, model, summary);
         try out.append(' ');
-        if (model.snapshot.ui.layout.enable_debug_process_info) {
+        const debug_info = model.snapshot.ui.layout.enable_debug_process_info;
+        if (debug_info) {
             try out.appendSlice(summary.label);
             try out.appendSlice(" [");
             try out.appendSlice(domain.process.statusName(summary.status));
@@ -61,19 +93,161 @@ pub fn renderProcessList(allocator: std.mem.Allocator, model: *const client_mode
                 try out.append(']');
             }
         } else {
-            try out.appendSlice(summary.label);
+            const tint = if (tintsCategories(model, .label)) categoryColor(model.style(), summary.categories) else null;
+            try appendLabel(&out, model, summary.label, selected, tint);
         }
+        if (model.hasUnreadOutput(summary)) try appendUnreadBadge(&out, model);
+        if (model.unreadBells(summary) > 0) try appendBellBadge(&out, model);
+        const errors = model.unreadErrors(summary);
+        if (errors > 0) try appendErrorBadge(&out, model, errors);
+        if (summary.crash_looping) try appendCrashLoopBadge(&out, model);
+        if (summary.autostart_in_s > 0 and summary.status != .running) try appendScheduledBadge(&out, model, summary.autostart_in_s);
+        if (preview == .suffix and !debug_info) try appendPreviewSuffix(&out, model, summary.last_line, visibleWidth(out.items[row_start..]));
         try out.append('\n');
+        if (preview == .line) {
+            try out.appendSlice(preview_indent);
+            const room = if (model.term_width == 0) std.math.maxInt(usize) else model.term_width -| preview_indent.len;
+            try appendPreviewText(&out, model, summary.last_line, room);
+            try out.append('\n');
+        }
     }
 
     return out.toOwnedSlice();
 }
 
+/// Replaces the list while `general.on_quit: ask` waits for an answer. The
+/// keys come first so a long list of running processes cannot hide them.
+fn appendQuitPrompt(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    try out.appendSlice("Quit: s stop all, d detach and leave running, esc cancel\n");
+    try out.writer().print("Running ({d}):\n", .{model.runningCount()});
+    for (model.processSummaries()) |summary| {
+        if (summary.status != .running) continue;
+        try out.appendSlice("  ");
+        try appendStatusMarker(out, model, summary);
+        try out.append(' ');
+        try out.appendSlice(summary.label);
+        try out.append('\n');
+    }
+}
+
+fn appendPinSeparator(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    const rule = if (model.ascii_icons) "-" else "─";
+    for (0..@min(model.term_width, pin_separator_width)) |_| try out.appendSlice(rule);
+    try out.append('\n');
+}
+
+fn appendLabel(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    label: []const u8,
+    selected: bool,
+    tint: ?[]const u8,
+) !void {
+    if (model.no_color) return out.appendSlice(label);
+    const style = model.style();
+    if (selected) return color.appendStyled(out, label, style.selected_process_color, style.selected_process_bg_color);
+    try color.appendStyled(out, label, tint orelse style.unselected_process_color, "");
+}
+
+fn appendUnreadBadge(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    const badge = if (model.ascii_icons) "*" else "•";
+    try out.append(' ');
+    if (model.no_color) return out.appendSlice(badge);
+    try color.appendStyled(out, badge, model.style().unread_output_color, "");
+}
+
+fn appendBellBadge(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    const badge = if (model.ascii_icons) "^G" else "♪";
+    try out.append(' ');
+    if (model.no_color) return out.appendSlice(badge);
+    try color.appendStyled(out, badge, model.style().status_halting_color, "");
+}
+
+fn appendErrorBadge(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel, errors: u32) !void {
+    var buffer: [16]u8 = undefined;
+    const badge = try std.fmt.bufPrint(&buffer, "!{d}", .{errors});
+    try out.append(' ');
+    if (model.no_color) return out.appendSlice(badge);
+    try color.appendStyled(out, badge, model.style().status_stopped_color, "");
+}
+
+fn appendCrashLoopBadge(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    const badge = "crash-looping";
+    try out.append(' ');
+    if (model.no_color) return out.appendSlice(badge);
+    try color.appendStyled(out, badge, model.style().status_stopped_color, "");
+}
+
+fn appendScheduledBadge(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel, seconds: u32) !void {
+    var buffer: [32]u8 = undefined;
+    const badge = try std.fmt.bufPrint(&buffer, "scheduled in {d}s", .{seconds});
+    try out.append(' ');
+    if (model.no_color) return out.appendSlice(badge);
+    try color.appendStyled(out, badge, model.style().status_halting_color, "");
+}
+
+/// Whether category colors apply to `target`. Without color there is nothing
+/// to tint, so the swatch column disappears too.
+fn tintsCategories(model: *const client_model.ClientModel, target: config.schema.CategoryColorTarget) bool {
+    if (model.no_color) return false;
+    const style = model.style();
+    return style.category_colors.len > 0 and std.mem.eql(u8, style.category_color_target, @tagName(target));
+}
+
+/// The color of the process's first category that has one.
+fn categoryColor(
+    style: *const domain.client_snapshot.UiStyleConfig,
+    categories: domain.client_snapshot.StringList,
+) ?[]const u8 {
+    for (categories) |category| {
+        for (style.category_colors) |entry| {
+            if (std.mem.eql(u8, entry.category, category)) return entry.color;
+        }
+    }
+    return null;
+}
+
+fn categorySwatch(model: *const client_model.ClientModel) []const u8 {
+    return if (model.ascii_icons) "|" else "▌";
+}
+
+fn appendCategorySwatch(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    summary: domain.client_snapshot.ProcessSummary,
+) !void {
+    if (!tintsCategories(model, .marker)) return;
+    if (categoryColor(model.style(), summary.categories)) |tint| {
+        try color.appendStyled(out, categorySwatch(model), tint, "");
+    } else {
+        try out.append(' ');
+    }
+    try out.append(' ');
+}
+
+fn appendConnectionBanner(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    if (!model.disconnected and model.connection_stale_s == 0) return;
+    var text_buffer: [64]u8 = undefined;
+    const text = if (model.disconnected)
+        "disconnected — reconnecting…"
+    else
+        std.fmt.bufPrint(&text_buffer, "connection stale — no reply for {d}s", .{model.connection_stale_s}) catch unreachable;
+    if (model.no_color) {
+        try out.appendSlice(text);
+    } else {
+        try color.appendStyled(out, text, model.style().warning_color, "");
+    }
+    try out.append('\n');
+}
+
 fn appendProcessHeader(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
     if (!model.show_panel_headers) return;
 
     try out.writer().print("Processes {}/{}", .{ model.visibleCount(), model.processCount() });
     if (model.show_only_running) try out.appendSlice("  running only");
+    if (model.activeView()) |view| try out.writer().print("  view: {s}", .{view.name});
+    if (model.sort_mode != .config) try out.writer().print("  sort: {s}", .{model.sort_mode.label()});
+    if (model.markedCount() > 0) try out.writer().print("  marked: {d}", .{model.markedCount()});
     if (model.filterText().len > 0) try out.writer().print("  filter: {s}", .{model.filterText()});
     try out.append('\n');
 }
@@ -86,7 +260,7 @@ fn selectedProcessWindowStart(
     if (model.term_height == 0 or process_count == 0) return 0;
     if (reserved_lines >= model.term_height) return 0;
 
-    const available_rows = model.term_height - reserved_lines;
+    const available_rows = availableProcessRows(model, reserved_lines);
     if (available_rows >= process_count) return 0;
 
     const selected_index = selectedProcessIndex(model);
@@ -102,10 +276,70 @@ fn selectedProcessWindowEnd(
     if (model.term_height == 0) return model.visibleCount();
     if (reserved_lines >= model.term_height) return start;
 
-    const available_rows = model.term_height - reserved_lines;
+    const available_rows = availableProcessRows(model, reserved_lines);
     return @min(start + available_rows, model.visibleCount());
 }
 
+/// Processes that fit below `reserved_lines`; a line preview gives each
+/// process two rows.
+fn availableProcessRows(model: *const client_model.ClientModel, reserved_lines: usize) usize {
+    const rows = model.term_height - reserved_lines;
+    if (lastLinePreview(model) != .line) return rows;
+    return @max(rows / 2, 1);
+}
+
+fn lastLinePreview(model: *const client_model.ClientModel) config.schema.LastLinePreview {
+    return std.meta.stringToEnum(config.schema.LastLinePreview, model.snapshot.ui.layout.last_line_preview) orelse .off;
+}
+
+/// Right-aligns the preview in the room the row leaves, or skips it when too
+/// little is left to read.
+fn appendPreviewSuffix(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    text: []const u8,
+    row_width: usize,
+) !void {
+    if (text.len == 0 or model.term_width == 0) return;
+    const free = model.term_width -| row_width;
+    if (free < min_preview_suffix_width + 2) return;
+    const shown = @min(displayWidth(text), free - 2);
+    try appendSpaces(out, free - shown);
+    try appendPreviewText(out, model, text, shown);
+}
+
+fn appendPreviewText(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    text: []const u8,
+    max_codepoints: usize,
+) !void {
+    if (text.len == 0) return;
+    var sgr_buffer: [color.max_sgr_len]u8 = undefined;
+    const params = if (model.no_color) null else color.sgr(&sgr_buffer, preview_color, .foreground);
+    if (params) |sgr_params| try out.writer().print("\x1b[{s}m", .{sgr_params});
+    try appendTruncated(out, text, max_codepoints);
+    if (params != null) try out.appendSlice("\x1b[0m");
+}
+
+/// Columns `text` takes on screen, skipping SGR escape sequences.
+fn visibleWidth(text: []const u8) usize {
+    var width: usize = 0;
+    var index: usize = 0;
+    while (index < text.len) {
+        if (text[index] == 0x1b) {
+            index += 1;
+            if (index < text.len and text[index] == '[') index += 1;
+            while (index < text.len and !(text[index] >= 0x40 and text[index] <= 0x7e)) : (index += 1) {}
+            index += 1;
+            continue;
+        }
+        index += @min(std.unicode.utf8ByteSequenceLength(text[index]) catch 1, text.len - index);
+        width += 1;
+    }
+    return width;
+}
+
 fn selectedProcessIndex(model: *const client_model.ClientModel) usize {
     if (model.active_proc_id.isNone()) return 0;
     for (model.visibleProcesses(), 0..) |summary, index| {
@@ -129,7 +363,7 @@ fn appendMessagesPanel(out: *std.array_list.Managed(u8), model: *const client_mo
     if (model.messageCount() == 0) return;
 
     const now_ms = std.time.milliTimestamp();
-    const visible_count = countVisibleMessages(model, now_ms);
+    const visible_count = model.visibleMessageCount(now_ms);
     if (visible_count == 0) return;
 
     try out.appendSlice("Messages:\n");
@@ -141,17 +375,168 @@ fn appendMessagesPanel(out: *std.array_list.Managed(u8), model: *const client_mo
         visible_index += 1;
         if (current_index < start) continue;
 
-        try appendWrappedBulletLine(out, message_entry.text, model.term_width);
+        var bullet = std.array_list.Managed(u8).init(out.allocator);
+        defer bullet.deinit();
+        try appendWrappedBulletLine(&bullet, message_entry.text, model.term_width);
+        // Styled line by line so a split pane that clips lines keeps colors.
+        var lines = std.mem.splitScalar(u8, bullet.items, '\n');
+        while (lines.next()) |line| {
+            try color.appendStyled(out, line, severityColor(model, message_entry.severity), "");
+            try out.append('\n');
+        }
+    }
+}
+
+fn severityColor(model: *const client_model.ClientModel, severity: client_model.Severity) []const u8 {
+    if (model.no_color) return "";
+    return switch (severity) {
+        .info => "",
+        .warn => model.style().warning_color,
+        .@"error" => model.style().status_stopped_color,
+    };
+}
+
+/// Full message history, newest first, for the `toggle_messages` overlay.
+/// `height` 0 means unlimited; `width` 0 leaves lines untruncated.
+pub fn renderMessageHistory(
+    allocator: std.mem.Allocator,
+    model: *const client_model.ClientModel,
+    width: usize,
+    height: usize,
+) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    try appendMessageHistory(&out, model, width, height);
+    return out.toOwnedSlice();
+}
+
+fn appendMessageHistory(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    width: usize,
+    height: usize,
+) !void {
+    const history = model.messageHistory();
+    try out.writer().print("Messages ({d})  ↑/↓ scroll  esc close\n", .{history.len});
+    if (history.len == 0) {
+        try out.appendSlice("No messages yet\n");
+        return;
+    }
+
+    const now_ms = std.time.milliTimestamp();
+    var rows: usize = 1;
+    var index = history.len - @min(model.history_offset, history.len - 1);
+    while (index > 0) {
+        index -= 1;
+        if (height != 0 and rows >= height) break;
+        rows += 1;
+
+        const entry = history[index];
+        var age_buffer: [16]u8 = undefined;
+        const age = formatAge(&age_buffer, now_ms - entry.created_ms);
+        const severity = @tagName(entry.severity);
+        try out.writer().print("{s:>7}  ", .{age});
+        try color.appendStyled(out, severity, severityColor(model, entry.severity), "");
+        try appendSpaces(out, 7 - severity.len);
+        const prefix_width = @max(age.len, 7) + 2 + 7;
+        const text_width = if (width > prefix_width) width - prefix_width else 0;
+        try appendTruncated(out, entry.text, if (width == 0) entry.text.len else text_width);
+        try out.append('\n');
+    }
+}
+
+/// The environment inspector overlay: every variable of the process, with
+/// those set by `env`, `add_path`, or proctmux marked and highlighted. Same
+/// `width` and `height` rules as `renderMessageHistory`.
+pub fn renderEnvView(
+    allocator: std.mem.Allocator,
+    model: *const client_model.ClientModel,
+    width: usize,
+    height: usize,
+) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+    try appendEnvView(&out, model, width, height);
+    return out.toOwnedSlice();
+}
+
+fn appendEnvView(
+    out: *std.array_list.Managed(u8),
+    model: *const client_model.ClientModel,
+    width: usize,
+    height: usize,
+) !void {
+    const view = model.env_view orelse return;
+    try out.writer().print("Environment: {s} ({d})  ↑/↓ scroll  esc close\n", .{ view.label, view.variables.len });
+
+    var line = std.array_list.Managed(u8).init(out.allocator);
+    defer line.deinit();
+    var shown = std.array_list.Managed(u8).init(out.allocator);
+    defer shown.deinit();
+    var rows: usize = 1;
+    for (view.variables[@min(view.offset, view.variables.len)..]) |variable| {
+        if (height != 0 and rows >= height) break;
+        rows += 1;
+
+        const inherited = std.mem.eql(u8, variable.source, "inherited");
+        line.clearRetainingCapacity();
+        try line.writer().print("{s} {s}={s}", .{ if (inherited) " " else "*", variable.name, variable.value });
+        if (!inherited) {
+            try line.writer().print("  ({s}", .{variable.source});
+            if (variable.overridden) |own| try line.writer().print(", was {s}", .{own});
+            try line.append(')');
+        }
+        shown.clearRetainingCapacity();
+        try appendTruncated(&shown, line.items, if (width == 0) line.items.len else width);
+        if (inherited or model.no_color) {
+            try out.appendSlice(shown.items);
+        } else {
+            try color.appendStyled(out, shown.items, model.style().warning_color, "");
+        }
         try out.append('\n');
     }
 }
 
-fn countVisibleMessages(model: *const client_model.ClientModel, now_ms: i64) usize {
+/// Ages such as `12s ago`, `4m ago`, or `2h ago`.
+fn formatAge(buffer: *[16]u8, age_ms: i64) []const u8 {
+    const seconds: u64 = @intCast(@divFloor(@max(age_ms, 0), std.time.ms_per_s));
+    return if (seconds < 60)
+        std.fmt.bufPrint(buffer, "{d}s ago", .{seconds}) catch unreachable
+    else if (seconds < 60 * 60)
+        std.fmt.bufPrint(buffer, "{d}m ago", .{seconds / 60}) catch unreachable
+    else
+        std.fmt.bufPrint(buffer, "{d}h ago", .{seconds / (60 * 60)}) catch unreachable;
+}
+
+/// Appends at most `max_codepoints` characters of the first line of `text`.
+fn appendTruncated(out: *std.array_list.Managed(u8), text: []const u8, max_codepoints: usize) !void {
+    const line = text[0 .. std.mem.indexOfScalar(u8, text, '\n') orelse text.len];
     var count: usize = 0;
-    for (model.messages.items) |message_entry| {
-        if (now_ms < message_entry.expires_at_ms) count += 1;
+    var index: usize = 0;
+    while (index < line.len and count < max_codepoints) : (count += 1) {
+        index += @min(std.unicode.utf8ByteSequenceLength(line[index]) catch 1, line.len - index);
+    }
+    try out.appendSlice(line[0..index]);
+}
+
+fn appendStartupPanel(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
+    const summary = model.startupSummary(std.time.milliTimestamp()) orelse return;
+
+    try out.writer().print("Startup: {d} started, {d} failed (esc to dismiss)\n", .{ summary.started.len, summary.failed.len });
+    if (summary.started.len > 0) {
+        try out.appendSlice("  started: ");
+        for (summary.started, 0..) |label, index| {
+            if (index != 0) try out.appendSlice(", ");
+            try out.appendSlice(label);
+        }
+        try out.append('\n');
+    }
+    for (summary.failed) |failure| {
+        const text = try std.fmt.allocPrint(out.allocator, "{s} failed: {s}", .{ failure.label, failure.reason });
+        defer out.allocator.free(text);
+        try appendWrappedBulletLine(out, text, model.term_width);
+        try out.append('\n');
     }
-    return count;
 }
 
 fn appendHelpPanel(out: *std.array_list.Managed(u8), model: *const client_model.ClientModel) !void {
@@ -170,21 +555,27 @@ fn appendHelpPanel(out: *std.array_list.Managed(u8), model: *const client_model.
     try appendHelpEntry(out, keys.toggle_help, "toggle help", 11, 0);
     try out.append('\n');
 
-    try appendSpaces(out, 17);
+    try appendHelpEntry(out, keys.toggle_messages, "messages", 4, 17);
     try appendHelpEntry(out, keys.restart, "restart process", 4, 23);
     try appendHelpEntry(out, keys.toggle_running, "toggle running only", 2, 25);
     try appendHelpEntry(out, keys.toggle_focus, "toggle focus", 11, 0);
     try out.append('\n');
 
-    try appendSpaces(out, 65);
+    try appendHelpEntry(out, keys.copy_command, "copy command", 4, 17);
+    try appendHelpEntry(out, keys.copy_output, "copy output", 4, 23);
+    try appendHelpEntry(out, keys.cycle_view, "cycle views", 2, 25);
     try appendHelpEntry(out, keys.focus_client, "focus client", 11, 0);
     try out.append('\n');
 
-    try appendSpaces(out, 65);
+    try appendHelpEntry(out, keys.copy_cwd, "copy cwd", 4, 17);
+    try appendHelpEntry(out, keys.copy_pid, "copy pid", 4, 23);
+    try appendHelpEntry(out, keys.cycle_sort, "cycle sort", 2, 25);
     try appendHelpEntry(out, keys.focus_server, "focus server", 11, 0);
     try out.append('\n');
 
-    try appendSpaces(out, 65);
+    try appendHelpEntry(out, keys.jump_to_error, "first error", 4, 17);
+    try appendHelpEntry(out, keys.toggle_pin, "pin process", 4, 23);
+    try appendHelpEntry(out, keys.toggle_mark, "mark process", 2, 25);
     try appendHelpEntry(out, keys.quit, "quit", 11, 0);
     try out.append('\n');
 
@@ -241,6 +632,13 @@ fn appendFilterPanel(out: *std.array_list.Managed(u8), model: *const client_mode
         return;
     }
 
+    if (model.activeView()) |view| {
+        try out.writer().print("View: {s}", .{view.name});
+        if (filter_text.len > 0) try out.writer().print("  Filter: {s}", .{filter_text});
+        try out.appendSlice(" (/ to edit, esc to clear)\n");
+        return;
+    }
+
     if (filter_text.len == 0) return;
     try out.appendSlice("Filter: ");
     try out.appendSlice(filter_text);
@@ -275,6 +673,7 @@ fn formatKey(key: []const u8) []const u8 {
     if (std.mem.eql(u8, key, "right")) return "→";
     if (std.mem.eql(u8, key, "enter")) return "⏎";
     if (std.mem.eql(u8, key, "ctrl+c")) return "^C";
+    if (std.mem.eql(u8, key, "space")) return "␣";
     return key;
 }
 
@@ -283,10 +682,55 @@ fn appendSelectedDescription(out: *std.array_list.Managed(u8), model: *const cli
 
     const summary = model.activeProcessSummary() orelse return;
     const description = std.mem.trim(u8, summary.description, " \t\r\n");
-    if (description.len == 0) return;
+    if (description.len > 0) {
+        try appendWrapped(out, description, model.term_width);
+        try out.append('\n');
+    }
+    if (summary.url.len > 0) {
+        try appendWrapped(out, summary.url, model.term_width);
+        try out.append('\n');
+    }
+    if (summary.annotation.len > 0) {
+        try appendWrapped(out, summary.annotation, model.term_width);
+        try out.append('\n');
+    }
+    if (summary.output_bytes > 0) {
+        var buffer: [128]u8 = undefined;
+        try appendWrapped(out, try formatOutputStats(&buffer, summary), model.term_width);
+        try out.append('\n');
+    }
+    if (summary.crash_looping) {
+        try out.appendSlice("Crash-looping; start it to try again. Last output:\n");
+        var lines = std.mem.splitScalar(u8, summary.crash_output, '\n');
+        while (lines.next()) |line| {
+            if (line.len == 0) continue;
+            try appendWrapped(out, line, model.term_width);
+            try out.append('\n');
+        }
+    }
+}
 
-    try appendWrapped(out, description, model.term_width);
-    try out.append('\n');
+/// One line of output counters, so a service that went quiet shows a rate of
+/// zero next to the lines it printed before.
+fn formatOutputStats(buffer: []u8, summary: domain.client_snapshot.ProcessSummary) ![]const u8 {
+    var size: [16]u8 = undefined;
+    var rate: [16]u8 = undefined;
+    const line = try std.fmt.bufPrint(buffer, "Output: {s}, {d} lines, {s}/s", .{
+        try formatSize(&size, summary.output_bytes),
+        summary.output_lines,
+        try formatSize(&rate, summary.output_rate),
+    });
+    if (summary.error_count == 0) return line;
+    const errors = try std.fmt.bufPrint(buffer[line.len..], ", {d} errors", .{summary.error_count});
+    return buffer[0 .. line.len + errors.len];
+}
+
+fn formatSize(buffer: []u8, bytes: u64) ![]const u8 {
+    const units = [_][]const u8{ "B", "KiB", "MiB", "GiB" };
+    var value = bytes;
+    var unit: usize = 0;
+    while (value >= 1024 and unit + 1 < units.len) : (unit += 1) value /= 1024;
+    return std.fmt.bufPrint(buffer, "{d} {s}", .{ value, units[unit] });
 }
 
 fn appendWrapped(out: *std.array_list.Managed(u8), text: []const u8, width: usize) !void {
@@ -342,29 +786,67 @@ fn appendWrappedBulletLine(out: *std.array_list.Managed(u8), text: []const u8, w
     }
 }
 
-fn statusMarker(status: domain.process.ProcessStatus) []const u8 {
-    return switch (status) {
-        .running => "●",
-        .halting => "◐",
-        .halted, .exited, .unknown => "■",
+const MarkerKind = enum {
+    running,
+    halting,
+    stopped,
+    failed,
+    succeeded,
+    unhealthy,
+};
+
+/// Runs that exited on their own keep their code, which separates finished
+/// tasks from failures and from processes that were stopped. A running process
+/// flagged as stuck is the only one marked unhealthy.
+fn markerKind(summary: domain.client_snapshot.ProcessSummary) MarkerKind {
+    return switch (summary.status) {
+        .running => if (summary.stuck) .unhealthy else .running,
+        // Every in-between status shares the halting marker.
+        .halting, .starting, .restarting => .halting,
+        .halted, .exited, .unknown => {
+            const code = summary.exit_code orelse return .stopped;
+            return if (code == 0) .succeeded else .failed;
+        },
     };
 }
 
 fn appendStatusMarker(
     out: *std.array_list.Managed(u8),
-    style: *const domain.client_snapshot.UiStyleConfig,
-    status: domain.process.ProcessStatus,
-    colors_enabled: bool,
+    model: *const client_model.ClientModel,
+    summary: domain.client_snapshot.ProcessSummary,
 ) !void {
-    const color = statusMarkerColor(style, status);
-    if (colors_enabled) {
-        if (ansiForegroundCode(color)) |code| {
-            try out.writer().print("\x1b[{}m{s}\x1b[0m", .{ code, statusMarker(status) });
-            return;
-        }
-    }
+    const kind = markerKind(summary);
+    const icons = model.icons();
+    const marker = switch (kind) {
+        .running => icons.running,
+        .halting => icons.halting,
+        .stopped => icons.stopped,
+        .failed => icons.failed,
+        .succeeded => icons.succeeded,
+        .unhealthy => icons.unhealthy,
+    };
+    if (model.no_color) return out.appendSlice(marker);
+    try color.appendStyled(out, marker, statusMarkerColor(model.style(), kind), "");
+}
+
+/// Colors every line separately so pane renderers that clip line by line
+/// never carry an open color into the neighbouring pane.
+pub fn colorizeLines(allocator: std.mem.Allocator, text: []const u8, line_color: []const u8) ![]const u8 {
+    var sgr_buffer: [color.max_sgr_len]u8 = undefined;
+    const params = color.sgr(&sgr_buffer, line_color, .foreground) orelse return allocator.dupe(u8, text);
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
 
-    try out.appendSlice(statusMarker(status));
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    var first = true;
+    while (lines.next()) |line| {
+        if (!first) try out.append('\n');
+        first = false;
+        if (line.len == 0) continue;
+        try out.writer().print("\x1b[{s}m{s}\x1b[0m", .{ params, line });
+    }
+    return out.toOwnedSlice();
 }
 
 pub fn renderHelpOverlay(
@@ -391,11 +873,16 @@ pub fn renderHelpOverlay(
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.start, "start process");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.stop, "stop process");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.restart, "restart process");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_pin, "pin/unpin process");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_mark, "mark for start/stop/restart");
     try appendHelpOverlayLine(&out, &lines, height, "");
     try appendHelpOverlayLine(&out, &lines, height, "Filter");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.filter, "filter processes");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.submit_filter, "apply filter");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_running, "toggle running only");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.cycle_view, "cycle views");
+    try appendHelpOverlayLiteralLine(&out, &lines, height, "1-9", "select view");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.cycle_sort, "cycle sort order");
     try appendHelpOverlayLine(&out, &lines, height, "");
     try appendHelpOverlayLine(&out, &lines, height, "Focus");
     try appendHelpOverlayLiteralLine(&out, &lines, height, "Tab", "focus next pane");
@@ -403,15 +890,53 @@ pub fn renderHelpOverlay(
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_focus, "toggle focus");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.focus_client, "focus client");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.focus_server, "focus server");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.rotate_split, "rotate split");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.grow_client, "grow process list");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.shrink_client, "shrink process list");
     try appendHelpOverlayLine(&out, &lines, height, "");
     try appendHelpOverlayLine(&out, &lines, height, "Other");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_help, "close help");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.toggle_messages, "message history");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.jump_to_error, "jump to first error");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.copy_command, "copy command");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.copy_pid, "copy pid");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.copy_cwd, "copy working directory");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.copy_output, "copy recent output");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.open_url, "open url");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.open_cwd, "open working directory");
+    try appendHelpOverlayBindingLine(&out, &lines, height, keys.show_env, "show environment");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.docs, "show docs");
     try appendHelpOverlayBindingLine(&out, &lines, height, keys.quit, "quit");
 
+    const category_colors = model.style().category_colors;
+    if (category_colors.len > 0) {
+        try appendHelpOverlayLine(&out, &lines, height, "");
+        try appendHelpOverlayLine(&out, &lines, height, "Categories");
+        for (category_colors) |entry| try appendHelpOverlayCategoryLine(&out, &lines, height, model, entry);
+    }
+
     return out.toOwnedSlice();
 }
 
+fn appendHelpOverlayCategoryLine(
+    out: *std.array_list.Managed(u8),
+    lines: *usize,
+    height: usize,
+    model: *const client_model.ClientModel,
+    entry: domain.client_snapshot.CategoryColor,
+) !void {
+    if (height != 0 and lines.* >= height) return;
+    if (model.no_color) {
+        try out.appendSlice(categorySwatch(model));
+    } else {
+        try color.appendStyled(out, categorySwatch(model), entry.color, "");
+    }
+    try out.append(' ');
+    try out.appendSlice(entry.category);
+    try out.append('\n');
+    lines.* += 1;
+}
+
 fn appendHelpOverlayLine(
     out: *std.array_list.Managed(u8),
     lines: *usize,
@@ -454,70 +979,23 @@ fn appendHelpOverlayLiteralLine(
     lines.* += 1;
 }
 
-fn statusMarkerColor(style: *const domain.client_snapshot.UiStyleConfig, status: domain.process.ProcessStatus) []const u8 {
-    return switch (status) {
-        .running => style.status_running_color,
+fn statusMarkerColor(style: *const domain.client_snapshot.UiStyleConfig, kind: MarkerKind) []const u8 {
+    return switch (kind) {
+        .running, .succeeded => style.status_running_color,
         .halting => style.status_halting_color,
-        .halted, .exited, .unknown => style.status_stopped_color,
+        .stopped, .failed => style.status_stopped_color,
+        .unhealthy => style.warning_color,
     };
 }
 
-fn ansiForegroundCode(color: []const u8) ?u8 {
-    const trimmed = std.mem.trim(u8, color, " \t\r\n");
-    if (trimmed.len == 0 or std.ascii.eqlIgnoreCase(trimmed, "none")) return null;
-
-    const named_colors = [_]struct {
-        name: []const u8,
-        code: u8,
-    }{
-        .{ .name = "black", .code = 30 },
-        .{ .name = "red", .code = 31 },
-        .{ .name = "green", .code = 32 },
-        .{ .name = "yellow", .code = 33 },
-        .{ .name = "blue", .code = 34 },
-        .{ .name = "magenta", .code = 35 },
-        .{ .name = "cyan", .code = 36 },
-        .{ .name = "white", .code = 37 },
-        .{ .name = "brightblack", .code = 90 },
-        .{ .name = "gray", .code = 90 },
-        .{ .name = "grey", .code = 90 },
-        .{ .name = "brightred", .code = 91 },
-        .{ .name = "lightred", .code = 91 },
-        .{ .name = "brightgreen", .code = 92 },
-        .{ .name = "lightgreen", .code = 92 },
-        .{ .name = "brightyellow", .code = 93 },
-        .{ .name = "brightblue", .code = 94 },
-        .{ .name = "brightmagenta", .code = 95 },
-        .{ .name = "brightcyan", .code = 96 },
-        .{ .name = "brightwhite", .code = 97 },
-        .{ .name = "ansiblack", .code = 30 },
-        .{ .name = "ansired", .code = 31 },
-        .{ .name = "ansigreen", .code = 32 },
-        .{ .name = "ansiyellow", .code = 33 },
-        .{ .name = "ansiblue", .code = 34 },
-        .{ .name = "ansimagenta", .code = 35 },
-        .{ .name = "ansicyan", .code = 36 },
-        .{ .name = "ansiwhite", .code = 37 },
-        .{ .name = "ansibrightblack", .code = 90 },
-        .{ .name = "ansigray", .code = 90 },
-        .{ .name = "ansigrey", .code = 90 },
-        .{ .name = "ansibrightred", .code = 91 },
-        .{ .name = "ansibrightgreen", .code = 92 },
-        .{ .name = "ansibrightyellow", .code = 93 },
-        .{ .name = "ansibrightblue", .code = 94 },
-        .{ .name = "ansibrightmagenta", .code = 95 },
-        .{ .name = "ansibrightcyan", .code = 96 },
-        .{ .name = "ansibrightwhite", .code = 97 },
-    };
-
-    for (named_colors) |entry| {
-        if (std.ascii.eqlIgnoreCase(trimmed, entry.name)) return entry.code;
-    }
+test "colorize lines wraps each non-empty line" {
+    const colored = try colorizeLines(std.testing.allocator, "ONE\n\nTWO", "cyan");
+    defer std.testing.allocator.free(colored);
+    try std.testing.expectEqualStrings("\x1b[36mONE\x1b[0m\n\n\x1b[36mTWO\x1b[0m", colored);
 
-    const color_index = std.fmt.parseUnsigned(u8, trimmed, 10) catch return null;
-    if (color_index <= 7) return 30 + color_index;
-    if (color_index <= 15) return 90 + color_index - 8;
-    return null;
+    const plain = try colorizeLines(std.testing.allocator, "ONE", "none");
+    defer std.testing.allocator.free(plain);
+    try std.testing.expectEqualStrings("ONE", plain);
 }
 
 test "process list renderer writes pointer status marker and labels" {
@@ -566,13 +1044,16 @@ test "process list renderer colors status markers from config" {
     defer std.testing.allocator.free(rendered);
 
     try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[31m■\x1b[0m alpha-api") != null);
-    try std.testing.expect(std.mem.indexOf(u8, rendered, "> \x1b[32m●\x1b[0m beta-worker") != null);
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "> \x1b[32m●\x1b[0m \x1b[37;45mbeta-worker\x1b[0m") != null);
 }
 
-test "process list renderer omits status colors when disabled" {
+test "process list renderer tints category markers or labels and lists them in help" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
     cfg.style.pointer_char = ">";
+    const category_colors = [_]config.schema.CategoryColor{.{ .category = "backend", .color = "cyan" }};
+    cfg.style.category_colors = category_colors[0..];
+    try config.schema.appendOwned(std.testing.allocator, &cfg.procs.getPtr("alpha-api").?.categories, "backend");
 
     var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
     defer app_state.deinit();
@@ -584,16 +1065,24 @@ test "process list renderer omits status colors when disabled" {
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
-    model.no_color = true;
 
-    const rendered = try renderProcessList(std.testing.allocator, &model);
-    defer std.testing.allocator.free(rendered);
+    const marked = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(marked);
+    try std.testing.expect(std.mem.indexOf(u8, marked, "  \x1b[36m▌\x1b[0m \x1b[31m■\x1b[0m alpha-api") != null);
+    try std.testing.expect(std.mem.indexOf(u8, marked, ">   \x1b[32m●\x1b[0m") != null);
 
-    try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[") == null);
-    try std.testing.expect(std.mem.indexOf(u8, rendered, "> ● beta-worker") != null);
+    const help = try renderHelpOverlay(std.testing.allocator, &model, 100, 0);
+    defer std.testing.allocator.free(help);
+    try std.testing.expect(std.mem.indexOf(u8, help, "Categories\n\x1b[36m▌\x1b[0m backend\n") != null);
+
+    snapshot.value.ui.style.category_color_target = "label";
+    const labelled = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(labelled);
+    try std.testing.expect(std.mem.indexOf(u8, labelled, "\x1b[31m■\x1b[0m \x1b[36malpha-api\x1b[0m") != null);
+    try std.testing.expect(std.mem.indexOf(u8, labelled, "▌") == null);
 }
 
-test "process list renderer adds compact header when enabled" {
+test "process list renderer omits status colors when disabled" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
     cfg.style.pointer_char = ">";
@@ -608,76 +1097,78 @@ test "process list renderer adds compact header when enabled" {
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
-    model.show_panel_headers = true;
+    model.no_color = true;
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
 
-    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "Processes 3/3\n");
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[") == null);
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "> ● beta-worker") != null);
 }
 
-test "process list renderer reports active filter in header" {
+test "process list renderer marks finished runs and falls back to ascii icons" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
-    cfg.style.pointer_char = ">";
 
     var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
     defer app_state.deinit();
     app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
 
     var views = test_config.standardRenderViews(&cfg);
+    views[0].exit_code = 0;
+    views[2].exit_code = 2;
     var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
     defer snapshot.deinit(std.testing.allocator);
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
-    model.show_panel_headers = true;
-
-    _ = try model.handleKey("/");
-    for ("alpha") |ch| {
-        const key = [_]u8{ch};
-        _ = try model.handleKey(key[0..]);
-    }
-    _ = try model.handleKey("enter");
+    model.no_color = true;
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
+    try std.testing.expectEqualStrings("  ✔ alpha-api\n▶ ● beta-worker\n  ✖ gamma-db\n", rendered);
 
-    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "Processes 1/3  filter: alpha\n");
+    model.ascii_icons = true;
+    const ascii = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(ascii);
+    try std.testing.expectEqualStrings("  + alpha-api\n> * beta-worker\n  x gamma-db\n", ascii);
 }
 
-test "process list renderer keeps selected process visible inside terminal height" {
+test "process list renderer marks stuck running processes unhealthy" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
-    cfg.style.pointer_char = ">";
 
     var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
     defer app_state.deinit();
-    app_state.current_proc_id = domain.process.ProcessId.fromInt(3);
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
 
     var views = test_config.standardRenderViews(&cfg);
+    views[1].stuck = true;
     var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
     defer snapshot.deinit(std.testing.allocator);
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
-    model.show_panel_headers = true;
-    model.term_height = 2;
+    model.no_color = true;
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "▶ ▲ beta-worker\n") != null);
 
-    try test_ansi.expectEqualPlain(std.testing.allocator, "Processes 3/3\n> ■ gamma-db\n", rendered);
+    model.ascii_icons = true;
+    const ascii = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(ascii);
+    try std.testing.expect(std.mem.indexOf(u8, ascii, "> ? beta-worker\n") != null);
 }
 
-test "process list renderer selects first row when active id is zero like legacy behavior" {
+test "process list renderer adds compact header when enabled" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
     cfg.style.pointer_char = ">";
 
     var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
     defer app_state.deinit();
-    app_state.current_proc_id = .none;
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
 
     var views = test_config.standardRenderViews(&cfg);
     var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
@@ -685,24 +1176,18 @@ test "process list renderer selects first row when active id is zero like legacy
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
+    model.show_panel_headers = true;
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
 
-    try test_ansi.expectEqualPlain(
-        std.testing.allocator,
-        "> ■ alpha-api\n  ● beta-worker\n  ■ gamma-db\n",
-        rendered,
-    );
+    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "Processes 3/3\n");
 }
 
-test "process list renderer includes status pid and categories in debug mode" {
+test "process list renderer shows connection banner when disconnected or stale" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
     cfg.style.pointer_char = ">";
-    cfg.layout.enable_debug_process_info = true;
-    try config.schema.appendOwned(std.testing.allocator, &cfg.procs.getPtr("beta-worker").?.categories, "worker");
-    try config.schema.appendOwned(std.testing.allocator, &cfg.procs.getPtr("beta-worker").?.categories, "queue");
 
     var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
     defer app_state.deinit();
@@ -714,18 +1199,163 @@ test "process list renderer includes status pid and categories in debug mode" {
 
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
+    model.disconnected = true;
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
 
-    try test_ansi.expectContainsPlain(
-        std.testing.allocator,
-        rendered,
-        "> ● beta-worker [Running] PID:1234 [worker,queue]\n",
-    );
+    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "disconnected — reconnecting…\n");
+    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "beta-worker");
+
+    model.disconnected = false;
+    model.connection_stale_s = 7;
+    const stale = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(stale);
+    try test_ansi.expectContainsPlain(std.testing.allocator, stale, "connection stale — no reply for 7s\n");
 }
 
-test "process list renderer shows friendly empty message" {
+test "process list renderer reports active filter in header" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    model.show_panel_headers = true;
+
+    _ = try model.handleKey("/");
+    for ("alpha") |ch| {
+        const key = [_]u8{ch};
+        _ = try model.handleKey(key[0..]);
+    }
+    _ = try model.handleKey("enter");
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectContainsPlain(std.testing.allocator, rendered, "Processes 1/3  filter: alpha\n");
+}
+
+test "process list renderer keeps selected process visible inside terminal height" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(3);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    model.show_panel_headers = true;
+    model.term_height = 2;
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(std.testing.allocator, "Processes 3/3\n> ■ gamma-db\n", rendered);
+}
+
+test "process list renderer previews the last output line as a suffix or second row" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+    cfg.layout.last_line_preview = "suffix";
+
+    var views = test_config.standardRenderViews(&cfg);
+    views[1].last_line = "listening on :3000";
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, domain.process.ProcessId.fromInt(2), views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    model.no_color = true;
+    model.term_width = 40;
+
+    const suffix = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(suffix);
+    try std.testing.expect(std.mem.indexOf(u8, suffix, "> ● beta-worker       listening on :3000\n") != null);
+
+    model.term_width = 24;
+    const narrow = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(narrow);
+    try std.testing.expect(std.mem.indexOf(u8, narrow, "> ● beta-worker  listeni\n") != null);
+
+    snapshot.value.ui.layout.last_line_preview = "line";
+    model.term_width = 40;
+    const second_row = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(second_row);
+    try std.testing.expect(std.mem.indexOf(u8, second_row, "  ■ alpha-api\n    \n> ● beta-worker\n    listening on :3000\n") != null);
+}
+
+test "process list renderer selects first row when active id is zero like legacy behavior" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = .none;
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "> ■ alpha-api\n  ● beta-worker\n  ■ gamma-db\n",
+        rendered,
+    );
+}
+
+test "process list renderer includes status pid and categories in debug mode" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+    cfg.layout.enable_debug_process_info = true;
+    try config.schema.appendOwned(std.testing.allocator, &cfg.procs.getPtr("beta-worker").?.categories, "worker");
+    try config.schema.appendOwned(std.testing.allocator, &cfg.procs.getPtr("beta-worker").?.categories, "queue");
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectContainsPlain(
+        std.testing.allocator,
+        rendered,
+        "> ● beta-worker [Running] PID:1234 [worker,queue]\n",
+    );
+}
+
+test "process list renderer shows friendly empty message" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
 
@@ -778,6 +1408,35 @@ test "process list renderer shows selected process description above list" {
     );
 }
 
+test "process list renderer shows output counters for the selected process" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    views[1].output_bytes = 3 * 1024 * 1024;
+    views[1].output_lines = 40_000;
+    views[1].error_count = 2;
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "Output: 3 MiB, 36864 lines, 0 B/s, 2 errors\n  ■ alpha-api\n> ● beta-worker\n  ■ gamma-db\n",
+        rendered,
+    );
+}
+
 test "process list renderer wraps selected process description to terminal width" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
@@ -859,10 +1518,10 @@ test "process list renderer shows help panel when toggled" {
         std.testing.allocator,
         "k/↑ move up      s/⏎ start process      / filter processes       d          show docs\n" ++
             "j/↓ move down    x   stop process       ⏎ apply filter           ?          toggle help\n" ++
-            "                 r   restart process    R toggle running only    ctrl+w     toggle focus\n" ++
-            "                                                                 ctrl+left  focus client\n" ++
-            "                                                                 ctrl+right focus server\n" ++
-            "                                                                 q/^C       quit\n" ++
+            "m   messages     r   restart process    R toggle running only    ctrl+w     toggle focus\n" ++
+            "y   copy command Y   copy output        v cycle views            ctrl+left  focus client\n" ++
+            "C   copy cwd     P   copy pid           S cycle sort             ctrl+right focus server\n" ++
+            "e   first error  p   pin process        ␣ mark process           q/^C       quit\n" ++
             "[Client Mode - Connected to Primary]\n" ++
             "  ■ alpha-api\n> ● beta-worker\n  ■ gamma-db\n",
         rendered,
@@ -907,12 +1566,12 @@ test "process list renderer shows only the five most recent messages" {
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
 
-    try model.addMessage("oldest message");
-    try model.addMessage("message two");
-    try model.addMessage("message three");
-    try model.addMessage("message four");
-    try model.addMessage("message five");
-    try model.addMessage("newest message");
+    try model.addMessage(.info, "oldest message");
+    try model.addMessage(.info, "message two");
+    try model.addMessage(.info, "message three");
+    try model.addMessage(.info, "message four");
+    try model.addMessage(.info, "message five");
+    try model.addMessage(.info, "newest message");
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
@@ -939,7 +1598,7 @@ test "process list renderer wraps long messages with bullet indentation" {
     defer model.deinit();
     model.term_width = 10;
 
-    try model.addMessage("alpha beta gamma");
+    try model.addMessage(.info, "alpha beta gamma");
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
@@ -963,7 +1622,7 @@ test "process list renderer hides expired messages before pruning" {
     var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
     defer model.deinit();
 
-    try model.addMessageAt("expired message", std.time.milliTimestamp() - client_model.message_timeout_ms - 1);
+    try model.addMessageAt(.info, "expired message", std.time.milliTimestamp() - client_model.message_timeout_ms - 1);
 
     const rendered = try renderProcessList(std.testing.allocator, &model);
     defer std.testing.allocator.free(rendered);
@@ -971,6 +1630,71 @@ test "process list renderer hides expired messages before pruning" {
     try std.testing.expect(std.mem.indexOf(u8, rendered, "expired message") == null);
 }
 
+test "message history overlay lists newest messages first" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const now_ms = std.time.milliTimestamp();
+    try model.addMessageAt(.@"error", "port in use", now_ms - 90 * std.time.ms_per_s);
+    try model.addMessageAt(.info, "api restarted due to change in main.go", now_ms);
+    _ = try model.handleKey("m");
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "Messages (2)  ↑/↓ scroll  esc close\n" ++
+            " 0s ago  info   api restarted due to change in main.go\n" ++
+            " 1m ago  error  port in use\n",
+        rendered,
+    );
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[31merror\x1b[0m") != null);
+}
+
+test "environment overlay highlights variables the config or proctmux set" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try model.openEnv("api", &.{
+        .{ .name = "HOME", .value = "/custom/home", .source = "config", .overridden = "/home/nick" },
+        .{ .name = "PROCTMUX_LABEL", .value = "api", .source = "metadata" },
+        .{ .name = "SHELL", .value = "/bin/zsh", .source = "inherited" },
+    });
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "Environment: api (3)  ↑/↓ scroll  esc close\n" ++
+            "* HOME=/custom/home  (config, was /home/nick)\n" ++
+            "* PROCTMUX_LABEL=api  (metadata)\n" ++
+            "  SHELL=/bin/zsh\n",
+        rendered,
+    );
+    try std.testing.expect(std.mem.indexOf(u8, rendered, "\x1b[33m* HOME") != null);
+}
+
 test "process list renderer shows focused filter prompt" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
@@ -1028,6 +1752,272 @@ test "process list renderer shows submitted filter indicator" {
     try test_ansi.expectEqualPlain(std.testing.allocator, "Filter: alpha (/ to edit, esc to clear)\n> ■ alpha-api\n", rendered);
 }
 
+test "process list renderer names the active quick view" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+    try cfg.views.append(.{ .name = try std.testing.allocator.dupe(u8, "api"), .filter = "api" });
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    _ = try model.handleKey("1");
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(std.testing.allocator, "View: api  Filter: api (/ to edit, esc to clear)\n> ■ alpha-api\n", rendered);
+}
+
+test "process list renderer draws pinned processes above a separator" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    try model.setPinned(&.{"gamma-db"});
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "  ■ gamma-db\n" ++ ("─" ** pin_separator_width) ++ "\n  ■ alpha-api\n> ● beta-worker\n",
+        rendered,
+    );
+}
+
+test "process list renderer shows a mark column while processes are marked" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    _ = try model.handleKey(" ");
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "    ■ alpha-api\n> ◆ ● beta-worker\n    ■ gamma-db\n",
+        rendered,
+    );
+}
+
+test "process list renderer badges unread output on unselected processes" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    for (&views) |*view| view.last_output_ms = 5_000;
+    var busy = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer busy.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(busy.view());
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "  ■ alpha-api •\n> ● beta-worker\n  ■ gamma-db •\n",
+        rendered,
+    );
+
+    model.ascii_icons = true;
+    model.no_color = true;
+    const ascii = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(ascii);
+    try std.testing.expect(std.mem.indexOf(u8, ascii, "alpha-api *\n") != null);
+}
+
+test "process list renderer marks processes that rang the bell" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[2].bells = 1;
+    var rung = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer rung.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(rung.view());
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "  ■ alpha-api\n> ● beta-worker\n  ■ gamma-db ♪\n",
+        rendered,
+    );
+
+    model.ascii_icons = true;
+    model.no_color = true;
+    const ascii = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(ascii);
+    try std.testing.expect(std.mem.indexOf(u8, ascii, "gamma-db ^G\n") != null);
+}
+
+test "process list renderer counts unread errors after the label" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.style.pointer_char = ">";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    views[0].error_count = 2;
+    views[1].error_count = 4;
+    var failing = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer failing.deinit(std.testing.allocator);
+    try model.replaceSnapshotPreservingUI(failing.view());
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "  ■ alpha-api !2\n> ● beta-worker\n  ■ gamma-db\n",
+        rendered,
+    );
+}
+
+test "process list renderer shows delayed autostart countdowns" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    views[2].autostart_in_s = 3;
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "  ■ alpha-api\n> ● beta-worker\n  ■ gamma-db scheduled in 3s\n",
+        rendered,
+    );
+}
+
+test "process list renderer lists running processes in the quit prompt" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+    cfg.general.on_quit = "ask";
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+    _ = try model.handleKey("q");
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+
+    try test_ansi.expectEqualPlain(
+        std.testing.allocator,
+        "Quit: s stop all, d detach and leave running, esc cancel\nRunning (1):\n  ● beta-worker\n",
+        rendered,
+    );
+}
+
+test "process list renderer summarizes autostart until dismissed" {
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+    snapshot.value.startup = .{
+        .settled_ms = std.time.milliTimestamp(),
+        .started = &.{"beta-worker"},
+        .failed = &.{.{ .label = "gamma-db", .reason = "Error: port 5432 in use" }},
+    };
+
+    var model = try client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer model.deinit();
+
+    const rendered = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(rendered);
+    try test_ansi.expectContainsPlain(
+        std.testing.allocator,
+        rendered,
+        "Startup: 1 started, 1 failed (esc to dismiss)\n  started: beta-worker\n- gamma-db failed: Error: port 5432 in use\n",
+    );
+
+    _ = try model.handleKey("esc");
+    const dismissed = try renderProcessList(std.testing.allocator, &model);
+    defer std.testing.allocator.free(dismissed);
+    try std.testing.expect(std.mem.indexOf(u8, dismissed, "Startup:") == null);
+}
+
 test "process list renderer keeps filter prompt when no processes match" {
     var cfg = try test_config.standardRenderConfig(std.testing.allocator);
     defer cfg.deinit();
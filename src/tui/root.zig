@@ -1,16 +1,24 @@
 //! TUI namespace.
-//! Runtime modes import this root to access the client model, session, key input, renderer, and split layout model.
+//! Runtime modes import this root to access the client model, session, clipboard, frame log, key input, renderer, and split layout model.
 
 pub const client_model = @import("client_model.zig");
+pub const clipboard = @import("clipboard.zig");
+pub const color = @import("color.zig");
 pub const client_session = @import("client_session.zig");
+pub const frame_log = @import("frame_log.zig");
 pub const key_input = @import("key_input.zig");
+pub const pin_state = @import("pin_state.zig");
 pub const render = @import("render.zig");
 pub const split_model = @import("split_model.zig");
 
 test {
     _ = client_model;
+    _ = clipboard;
+    _ = color;
     _ = client_session;
+    _ = frame_log;
     _ = key_input;
+    _ = pin_state;
     _ = render;
     _ = split_model;
 }
@@ -6,22 +6,55 @@ const config = @import("../config/root.zig");
 
 const unified_status_lines = 1;
 const unified_client_ratio = 55;
+const min_client_ratio = 10;
+const max_client_ratio = 90;
+const client_ratio_step = 5;
 const min_client_width = 24;
 const min_terminal_width = 32;
 const client_width_padding = 6;
 const min_client_height = 8;
 const min_terminal_height = 10;
+const details_ratio = 35;
+const min_details_width = 20;
+const min_details_height = 4;
+
+/// Columns reserved between the output and a right-hand details pane.
+pub const details_separator_width = 3;
 
 pub const Orientation = enum {
     left,
     right,
     top,
     bottom,
+
+    /// Runtime rotation order: the process list travels clockwise around output.
+    pub fn next(self: Orientation) Orientation {
+        return switch (self) {
+            .left => .top,
+            .top => .right,
+            .right => .bottom,
+            .bottom => .left,
+        };
+    }
 };
 
 pub const Pane = enum {
     client,
     server,
+    details,
+};
+
+/// Where the optional details pane sits relative to process output.
+pub const DetailsPlacement = enum {
+    none,
+    right,
+    bottom,
+
+    pub fn fromName(name: []const u8) DetailsPlacement {
+        if (std.mem.eql(u8, name, "right")) return .right;
+        if (std.mem.eql(u8, name, "bottom")) return .bottom;
+        return .none;
+    }
 };
 
 pub const Size = struct {
@@ -43,7 +76,11 @@ pub const InputSink = struct {
 pub const Model = struct {
     orientation: Orientation,
     app_config: *const config.schema.Config,
+    details_placement: DetailsPlacement,
     focus: Pane = .client,
+    /// Client share of the content area in percent. Null keeps the automatic
+    /// label-based width for side layouts and `unified_client_ratio` otherwise.
+    client_ratio: ?i32 = null,
     server_input: ?InputSink = null,
     status_height: i32 = 0,
     content_width: i32 = 0,
@@ -52,12 +89,16 @@ pub const Model = struct {
     client_height: i32 = 0,
     server_width: i32 = 0,
     server_height: i32 = 0,
+    details_width: i32 = 0,
+    details_height: i32 = 0,
     longest_process_label_width: i32 = 0,
 
     pub fn init(orientation: Orientation, app_config: *const config.schema.Config) Model {
         return .{
             .orientation = orientation,
             .app_config = app_config,
+            .details_placement = DetailsPlacement.fromName(app_config.layout.details_pane),
+            .client_ratio = clampClientRatio(app_config.layout.unified_client_ratio),
         };
     }
 
@@ -70,6 +111,11 @@ pub const Model = struct {
         return self.focus == .client;
     }
 
+    /// True when the details pane has room to render at the current size.
+    pub fn detailsVisible(self: *const Model) bool {
+        return self.details_width > 0 and self.details_height > 0;
+    }
+
     pub fn setServerInput(self: *Model, sink: InputSink) void {
         self.server_input = sink;
     }
@@ -83,8 +129,13 @@ pub const Model = struct {
     }
 
     pub fn handleKey(self: *Model, key: []const u8) !void {
-        if (std.mem.eql(u8, key, "tab") or std.mem.eql(u8, key, "shift+tab")) {
-            self.focus = if (self.focus == .client) .server else .client;
+        if (std.mem.eql(u8, key, "tab")) {
+            self.focus = self.nextPane();
+            self.relayoutAfterFocusChange();
+            return;
+        }
+        if (std.mem.eql(u8, key, "shift+tab")) {
+            self.focus = self.previousPane();
             self.relayoutAfterFocusChange();
             return;
         }
@@ -98,8 +149,20 @@ pub const Model = struct {
             self.relayoutAfterFocusChange();
             return;
         }
+        if (matches(self.app_config.keybinding.rotate_split, key)) {
+            self.rotate();
+            return;
+        }
+        if (matches(self.app_config.keybinding.grow_client, key)) {
+            self.adjustClientRatio(client_ratio_step);
+            return;
+        }
+        if (matches(self.app_config.keybinding.shrink_client, key)) {
+            self.adjustClientRatio(-client_ratio_step);
+            return;
+        }
         if (matches(self.app_config.keybinding.toggle_focus, key)) {
-            self.focus = if (self.focus == .client) .server else .client;
+            self.focus = self.nextPane();
             self.relayoutAfterFocusChange();
             return;
         }
@@ -112,6 +175,36 @@ pub const Model = struct {
         }
     }
 
+    pub fn rotate(self: *Model) void {
+        self.orientation = self.orientation.next();
+        if (self.content_width > 0) self.recalculateLayout();
+    }
+
+    /// Applies a saved client ratio. Out-of-range values fall back to the
+    /// automatic split.
+    pub fn setClientRatio(self: *Model, ratio: i32) void {
+        self.client_ratio = clampClientRatio(ratio);
+        if (self.content_width > 0) self.recalculateLayout();
+    }
+
+    fn adjustClientRatio(self: *Model, delta: i32) void {
+        const current = self.client_ratio orelse self.currentClientRatio();
+        self.client_ratio = std.math.clamp(current + delta, min_client_ratio, max_client_ratio);
+        if (self.content_width > 0) self.recalculateLayout();
+    }
+
+    /// Ratio matching the current automatic layout, so the first adjustment
+    /// starts from what the user sees rather than jumping.
+    fn currentClientRatio(self: *const Model) i32 {
+        return switch (self.orientation) {
+            .left, .right => if (self.content_width > 0 and self.client_width > 0)
+                @divTrunc(self.client_width * 100, self.content_width)
+            else
+                unified_client_ratio,
+            .top, .bottom => unified_client_ratio,
+        };
+    }
+
     /// Recomputes pane sizes from terminal dimensions. Invalid dimensions are
     /// ignored because resize probes may fail transiently during startup.
     pub fn resize(self: *Model, width: i32, height: i32) !void {
@@ -131,6 +224,27 @@ pub const Model = struct {
         return .{ .width = self.server_width, .height = self.server_height };
     }
 
+    pub fn detailsSize(self: *const Model) Size {
+        return .{ .width = self.details_width, .height = self.details_height };
+    }
+
+    /// Area shared by the output and details panes. Renderers compose both
+    /// panes inside it; emulator and PTY sizing keep using `serverSize`.
+    pub fn serverRegionSize(self: *const Model) Size {
+        if (!self.detailsVisible()) return self.serverSize();
+        return switch (self.details_placement) {
+            .none => self.serverSize(),
+            .right => .{
+                .width = self.server_width + details_separator_width + self.details_width,
+                .height = self.server_height,
+            },
+            .bottom => .{
+                .width = self.server_width,
+                .height = self.server_height + self.details_height,
+            },
+        };
+    }
+
     pub fn statusBar(self: *const Model, allocator: std.mem.Allocator) ![]const u8 {
         if (self.status_height == 0) return allocator.dupe(u8, "");
 
@@ -146,6 +260,14 @@ pub const Model = struct {
             );
         }
 
+        if (self.focus == .details) {
+            return std.fmt.allocPrint(
+                allocator,
+                "Details  [Tab] client  [{s}] quit",
+                .{firstBinding(self.app_config.keybinding.quit)},
+            );
+        }
+
         if (!self.clientVisible()) {
             return std.fmt.allocPrint(
                 allocator,
@@ -161,6 +283,22 @@ pub const Model = struct {
         );
     }
 
+    fn nextPane(self: *const Model) Pane {
+        return switch (self.focus) {
+            .client => .server,
+            .server => if (self.detailsVisible()) .details else .client,
+            .details => .client,
+        };
+    }
+
+    fn previousPane(self: *const Model) Pane {
+        return switch (self.focus) {
+            .client => if (self.detailsVisible()) .details else .server,
+            .server => .client,
+            .details => .server,
+        };
+    }
+
     fn relayoutAfterFocusChange(self: *Model) void {
         if (self.app_config.layout.hide_process_list_when_unfocused and self.content_width > 0) {
             self.recalculateLayout();
@@ -168,6 +306,11 @@ pub const Model = struct {
     }
 
     fn recalculateLayout(self: *Model) void {
+        self.layoutClientAndServer();
+        self.carveDetailsPane();
+    }
+
+    fn layoutClientAndServer(self: *Model) void {
         const hidden = !self.clientVisible();
         switch (self.orientation) {
             .left, .right => {
@@ -179,7 +322,10 @@ pub const Model = struct {
                     return;
                 }
 
-                var client_width = self.desiredClientWidth();
+                var client_width = if (self.client_ratio) |ratio|
+                    @divTrunc(self.content_width * ratio, 100)
+                else
+                    self.desiredClientWidth();
                 if (client_width < min_client_width and self.content_width >= min_client_width) {
                     client_width = min_client_width;
                 }
@@ -211,7 +357,8 @@ pub const Model = struct {
                     return;
                 }
 
-                var client_height = @divTrunc(self.content_height * unified_client_ratio, 100);
+                const ratio = self.client_ratio orelse unified_client_ratio;
+                var client_height = @divTrunc(self.content_height * ratio, 100);
                 if (client_height < min_client_height and self.content_height >= min_client_height) {
                     client_height = min_client_height;
                 }
@@ -234,6 +381,35 @@ pub const Model = struct {
         }
     }
 
+    /// Splits the details pane off the server region. The output pane keeps
+    /// its minimum size, so details disappear on terminals too small for both.
+    fn carveDetailsPane(self: *Model) void {
+        self.details_width = 0;
+        self.details_height = 0;
+
+        switch (self.details_placement) {
+            .none => {},
+            .right => {
+                const width = @max(@divTrunc(self.server_width * details_ratio, 100), min_details_width);
+                const output_width = self.server_width - details_separator_width - width;
+                if (output_width < min_terminal_width) return;
+
+                self.details_width = width;
+                self.details_height = self.server_height;
+                self.server_width = output_width;
+            },
+            .bottom => {
+                const height = @max(@divTrunc(self.server_height * details_ratio, 100), min_details_height);
+                const output_height = self.server_height - height;
+                if (output_height < min_terminal_height) return;
+
+                self.details_width = self.server_width;
+                self.details_height = height;
+                self.server_height = output_height;
+            },
+        }
+    }
+
     fn desiredClientWidth(self: *const Model) i32 {
         var desired: i32 = @max(self.longest_process_label_width + client_width_padding, min_client_width);
 
@@ -252,6 +428,11 @@ pub const Model = struct {
     }
 };
 
+fn clampClientRatio(ratio: i32) ?i32 {
+    if (ratio < min_client_ratio or ratio > max_client_ratio) return null;
+    return ratio;
+}
+
 const control_letter_inputs = [_][]const u8{
     "",
     "\x01",
@@ -506,6 +687,115 @@ test "split model forwards server-focused control keys as terminal input" {
     try std.testing.expectEqualStrings("\x04\x0c\x1a\x0a\x0b\x13\x18", capture.bytes());
 }
 
+test "split model carves details pane from the server region" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+    cfg.layout.details_pane = "right";
+
+    var model = Model.init(.left, &cfg);
+    model.setProcessLabels(&.{ "api", "background-worker" });
+    try model.resize(160, 40);
+
+    try std.testing.expect(model.detailsVisible());
+    try std.testing.expectEqual(Size{ .width = 47, .height = 39 }, model.detailsSize());
+    try std.testing.expectEqual(Size{ .width = 86, .height = 39 }, model.serverSize());
+    try std.testing.expectEqual(Size{ .width = 136, .height = 39 }, model.serverRegionSize());
+}
+
+test "split model drops details pane when output would get too small" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+    cfg.layout.details_pane = "right";
+
+    var model = Model.init(.left, &cfg);
+    try model.resize(70, 30);
+
+    try std.testing.expect(!model.detailsVisible());
+    try std.testing.expectEqual(model.serverSize(), model.serverRegionSize());
+}
+
+test "split model cycles focus through the details pane" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+    cfg.layout.details_pane = "bottom";
+
+    var capture = InputCapture{};
+    var model = Model.init(.left, &cfg);
+    model.setServerInput(InputCapture.sink(&capture));
+    try model.resize(120, 40);
+
+    try model.handleKey("tab");
+    try std.testing.expectEqual(Pane.server, model.focusedPane());
+    try model.handleKey("tab");
+    try std.testing.expectEqual(Pane.details, model.focusedPane());
+
+    try model.handleKey("x");
+    try std.testing.expectEqualStrings("", capture.bytes());
+
+    const status = try model.statusBar(std.testing.allocator);
+    defer std.testing.allocator.free(status);
+    try std.testing.expectEqualStrings("Details  [Tab] client  [q] quit", status);
+
+    try model.handleKey("tab");
+    try std.testing.expectEqual(Pane.client, model.focusedPane());
+    try model.handleKey("shift+tab");
+    try std.testing.expectEqual(Pane.details, model.focusedPane());
+}
+
+test "split model rotates orientation and re-lays out panes" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+
+    var capture = InputCapture{};
+    var model = Model.init(.left, &cfg);
+    model.setServerInput(InputCapture.sink(&capture));
+    try model.resize(120, 40);
+
+    try model.handleKey("ctrl+right");
+    try model.handleKey("ctrl+o");
+    try std.testing.expectEqual(Orientation.top, model.orientation);
+    try std.testing.expectEqual(@as(i32, 120), model.serverSize().width);
+    try std.testing.expectEqual(@as(i32, 120), model.clientSize().width);
+    try std.testing.expectEqualStrings("", capture.bytes());
+
+    try model.handleKey("ctrl+o");
+    try model.handleKey("ctrl+o");
+    try model.handleKey("ctrl+o");
+    try std.testing.expectEqual(Orientation.left, model.orientation);
+}
+
+test "split model grows and shrinks the client pane by ratio" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+
+    var model = Model.init(.left, &cfg);
+    model.setProcessLabels(&.{"api"});
+    try model.resize(200, 40);
+    try std.testing.expectEqual(@as(i32, 24), model.clientSize().width);
+
+    try model.handleKey("ctrl+shift+right");
+    try std.testing.expectEqual(@as(?i32, 17), model.client_ratio);
+    try std.testing.expectEqual(@as(i32, 34), model.clientSize().width);
+    try std.testing.expectEqual(@as(i32, 166), model.serverSize().width);
+
+    try model.handleKey("ctrl+shift+left");
+    try model.handleKey("ctrl+shift+left");
+    try std.testing.expectEqual(@as(?i32, 10), model.client_ratio);
+    try std.testing.expectEqual(@as(i32, 24), model.clientSize().width);
+}
+
+test "split model uses configured client ratio for stacked layouts" {
+    var cfg = try testConfig(false);
+    defer cfg.deinit();
+    cfg.layout.unified_client_ratio = 30;
+
+    var model = Model.init(.top, &cfg);
+    try model.resize(120, 41);
+
+    try std.testing.expectEqual(@as(i32, 12), model.clientSize().height);
+    try std.testing.expectEqual(@as(i32, 28), model.serverSize().height);
+}
+
 fn testConfig(hide_process_list_when_unfocused: bool) !config.schema.Config {
     var cfg = config.schema.Config.empty(std.testing.allocator);
     errdefer cfg.deinit();
@@ -3,10 +3,14 @@
 
 const std = @import("std");
 const pty = @import("../proc/pty.zig");
+const threads = @import("../threads/root.zig");
 const tui = @import("../tui/root.zig");
+const wakeup = @import("wakeup.zig");
 
-const log = std.log.scoped(.child_primary);
+const log = std.log.scoped(.unified);
 const max_output = 1024 * 1024;
+// Slack past the child's own shutdown deadline for socket cleanup and exit.
+const shutdown_grace_ms = 2000;
 
 pub const OutputCursor = struct {
     offset: u64 = 0,
@@ -25,6 +29,10 @@ pub const ChildPrimary = struct {
     output_thread: ?std.Thread = null,
     wait_thread: ?std.Thread = null,
     exited: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+    /// Signaled after captured output is appended; guarded by `mutex`.
+    output_wakeup: ?*wakeup.Wakeup = null,
+    /// How long the child may spend stopping its processes after SIGTERM.
+    shutdown_timeout_ms: u64 = 10_000,
 
     /// Relaunches proctmux as a primary server inside a PTY. The fixed initial
     /// size is corrected later by unified resize synchronization.
@@ -34,7 +42,7 @@ pub const ChildPrimary = struct {
         env_map: *const std.process.EnvMap,
         cwd: []const u8,
     ) !*ChildPrimary {
-        const spawned = try pty.spawn(allocator, argv, env_map, cwd, 30, 100);
+        const spawned = try pty.spawn(allocator, argv, env_map, cwd, 30, 100, null);
         errdefer spawned.master.close();
 
         const output_fd = try std.posix.dup(spawned.master.handle);
@@ -53,19 +61,17 @@ pub const ChildPrimary = struct {
         };
         errdefer child.output.deinit();
 
-        child.output_thread = try std.Thread.spawn(.{}, captureOutput, .{child});
-        child.wait_thread = try std.Thread.spawn(.{}, waitChild, .{child});
+        child.output_thread = try threads.spawn(.{}, captureOutput, .{child});
+        child.wait_thread = try threads.spawn(.{}, waitChild, .{child});
         return child;
     }
 
     pub fn deinit(self: *ChildPrimary) void {
         if (!self.exited.load(.seq_cst)) {
-            std.posix.kill(self.pid, std.posix.SIG.INT) catch {};
-            std.Thread.sleep(50 * std.time.ns_per_ms);
-        }
-        if (!self.exited.load(.seq_cst)) {
+            // The child stops its managed processes on SIGTERM; killing it
+            // before that finishes would orphan them.
             std.posix.kill(self.pid, std.posix.SIG.TERM) catch {};
-            std.Thread.sleep(50 * std.time.ns_per_ms);
+            self.waitForExit(self.shutdown_timeout_ms + shutdown_grace_ms);
         }
         if (!self.exited.load(.seq_cst)) std.posix.kill(self.pid, std.posix.SIG.KILL) catch {};
 
@@ -89,6 +95,13 @@ pub const ChildPrimary = struct {
         self.allocator.destroy(self);
     }
 
+    fn waitForExit(self: *ChildPrimary, timeout_ms: u64) void {
+        const deadline = std.time.milliTimestamp() + @as(i64, @intCast(timeout_ms));
+        while (!self.exited.load(.seq_cst) and std.time.milliTimestamp() < deadline) {
+            std.Thread.sleep(10 * std.time.ns_per_ms);
+        }
+    }
+
     pub fn sink(self: *ChildPrimary) tui.split_model.InputSink {
         return .{
             .context = self,
@@ -96,6 +109,13 @@ pub const ChildPrimary = struct {
         };
     }
 
+    /// Resizes the child primary PTY to the server pane; the child primary then
+    /// propagates the new size to its selected process.
+    pub fn resize(self: *ChildPrimary, rows: u16, cols: u16) !void {
+        const file = self.pty_file orelse return;
+        try pty.resize(file, rows, cols);
+    }
+
     pub fn readSince(
         self: *ChildPrimary,
         allocator: std.mem.Allocator,
@@ -115,6 +135,12 @@ pub const ChildPrimary = struct {
         return result;
     }
 
+    pub fn setOutputWakeup(self: *ChildPrimary, output_wakeup: ?*wakeup.Wakeup) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        self.output_wakeup = output_wakeup;
+    }
+
     pub fn outputEndOffset(self: *ChildPrimary) u64 {
         self.mutex.lock();
         defer self.mutex.unlock();
@@ -131,6 +157,7 @@ pub const ChildPrimary = struct {
             self.output.shrinkRetainingCapacity(max_output);
             self.output_base_offset += trim;
         }
+        if (self.output_wakeup) |target| target.signal();
     }
 
     fn writeInput(context: *anyopaque, bytes: []const u8) anyerror!void {
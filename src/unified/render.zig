@@ -13,24 +13,30 @@ const side_by_side_separator_width = 3;
 const min_unified_width = 80;
 const min_unified_height = 24;
 
+/// Text for the output pane plus how many lines of it scrolled by undrawn.
+pub const ServerPane = struct {
+    text: []const u8,
+    skipped_lines: u64 = 0,
+};
+
 pub fn frame(
     session: *tui.client_session.ClientSession,
     split: *const tui.split_model.Model,
-    server_text: []const u8,
+    server: ServerPane,
     output: io.Output,
 ) !void {
     var frame_buffer = std.array_list.Managed(u8).init(session.allocator);
     defer frame_buffer.deinit();
 
     const buffered_output = io.BufferOutput.writer(&frame_buffer, output.fd);
-    try writeFrame(session, split, server_text, buffered_output);
+    try writeFrame(session, split, server, buffered_output);
     try output.writeAll(frame_buffer.items);
 }
 
 fn writeFrame(
     session: *tui.client_session.ClientSession,
     split: *const tui.split_model.Model,
-    server_text: []const u8,
+    server: ServerPane,
     output: io.Output,
 ) !void {
     try output.writeAll(terminal.repaint.hide_cursor);
@@ -41,13 +47,18 @@ fn writeFrame(
     if (terminalTooSmall(split)) {
         try writeSmallTerminalMessage(split, output);
     } else {
-        try writeSplitContent(session, split, server_text, output);
+        try writeSplitContent(session, split, server, output);
     }
     try output.writeAll(terminal.repaint.end_frame);
     try writeStatusBar(session, split, output);
     try output.writeAll(terminal.repaint.end_frame);
     try output.writeAll(terminal.repaint.hide_cursor);
     try output.writeAll(terminal.repaint.end_synchronized_update);
+    if (session.model.takeBell()) try output.writeAll(terminal.repaint.bell);
+    if (session.takeClipboardWrite()) |sequence| {
+        defer session.allocator.free(sequence);
+        try output.writeAll(sequence);
+    }
 }
 
 fn terminalTooSmall(split: *const tui.split_model.Model) bool {
@@ -74,13 +85,19 @@ fn writeStatusBar(
     split: *const tui.split_model.Model,
     output: io.Output,
 ) !void {
-    const status = try split.statusBar(session.allocator);
-    defer session.allocator.free(status);
-    if (status.len > 0) {
-        try writeCursorPosition(output, statusRow(split), 1);
-        _ = try writeFittedLine(output, status, positiveWidth(split.content_width));
-        try output.writeAll(terminal.repaint.clear_line_tail);
-    }
+    const base = try split.statusBar(session.allocator);
+    defer session.allocator.free(base);
+    if (base.len == 0) return;
+
+    var status = std.array_list.Managed(u8).init(session.allocator);
+    defer status.deinit();
+    try status.appendSlice(base);
+    if (session.model.activeView()) |view| try status.writer().print("  view: {s}", .{view.name});
+    if (session.model.sort_mode != .config) try status.writer().print("  sort: {s}", .{session.model.sort_mode.label()});
+
+    try writeCursorPosition(output, statusRow(split), 1);
+    _ = try writeFittedLine(output, status.items, positiveWidth(split.content_width));
+    try output.writeAll(terminal.repaint.clear_line_tail);
 }
 
 fn statusRow(split: *const tui.split_model.Model) usize {
@@ -97,7 +114,7 @@ fn writeCursorPosition(output: io.Output, row: usize, column: usize) !void {
 fn writeSplitContent(
     session: *tui.client_session.ClientSession,
     split: *const tui.split_model.Model,
-    server_text: []const u8,
+    server: ServerPane,
     output: io.Output,
 ) !void {
     if (session.model.show_help) {
@@ -111,17 +128,47 @@ fn writeSplitContent(
         try writeTextBlock(output, overlay);
         return;
     }
+    if (session.model.show_history) {
+        const overlay = try tui.render.renderMessageHistory(
+            session.allocator,
+            &session.model,
+            positiveWidth(split.content_width),
+            positiveHeight(split.content_height),
+        );
+        defer session.allocator.free(overlay);
+        try writeTextBlock(output, overlay);
+        return;
+    }
+    if (session.model.env_view != null) {
+        const overlay = try tui.render.renderEnvView(
+            session.allocator,
+            &session.model,
+            positiveWidth(split.content_width),
+            positiveHeight(split.content_height),
+        );
+        defer session.allocator.free(overlay);
+        try writeTextBlock(output, overlay);
+        return;
+    }
 
     const server_panel_text = try renderServerPanelText(
         session.allocator,
         &session.model,
-        server_text,
+        server,
         positiveHeight(split.serverSize().height),
     );
     defer session.allocator.free(server_panel_text);
 
+    const server_region_text = try renderServerRegionText(
+        session.allocator,
+        split,
+        &session.model,
+        server_panel_text,
+    );
+    defer session.allocator.free(server_region_text);
+
     if (!split.clientVisible()) {
-        try writeTextBlock(output, server_panel_text);
+        try writeTextBlock(output, server_region_text);
         return;
     }
 
@@ -136,29 +183,29 @@ fn writeSplitContent(
         .left => try writeSideBySide(
             output,
             client_text,
-            server_panel_text,
+            server_region_text,
             positiveWidth(split.clientSize().width),
-            widthWithoutSeparator(positiveWidth(split.serverSize().width)),
-            positiveHeight(split.serverSize().height),
+            widthWithoutSeparator(positiveWidth(split.serverRegionSize().width)),
+            positiveHeight(split.serverRegionSize().height),
             .head,
             .head,
         ),
         .right => try writeSideBySide(
             output,
-            server_panel_text,
+            server_region_text,
             client_text,
-            widthWithoutSeparator(positiveWidth(split.serverSize().width)),
+            widthWithoutSeparator(positiveWidth(split.serverRegionSize().width)),
             positiveWidth(split.clientSize().width),
-            positiveHeight(split.serverSize().height),
+            positiveHeight(split.serverRegionSize().height),
             .head,
             .head,
         ),
         .top => {
             try writeTextBlock(output, client_text);
-            try writeTextBlock(output, server_panel_text);
+            try writeTextBlock(output, server_region_text);
         },
         .bottom => {
-            try writeTextBlock(output, server_panel_text);
+            try writeTextBlock(output, server_region_text);
             try writeTextBlock(output, client_text);
         },
     }
@@ -167,21 +214,101 @@ fn writeSplitContent(
 fn renderServerPanelText(
     allocator: std.mem.Allocator,
     model: *const tui.client_model.ClientModel,
-    server_text: []const u8,
+    server: ServerPane,
     height: usize,
 ) ![]const u8 {
     var out = std.array_list.Managed(u8).init(allocator);
     errdefer out.deinit();
 
-    try appendServerHeader(&out, model);
+    try appendServerHeader(&out, model, server.skipped_lines);
     const available_lines = if (height > 1) height - 1 else 0;
-    try appendTailLines(&out, server_text, available_lines);
+    try appendTailLines(&out, server.text, available_lines);
+    return out.toOwnedSlice();
+}
+
+/// Joins process output with the optional details pane so the outer layout can
+/// treat both as a single server region.
+fn renderServerRegionText(
+    allocator: std.mem.Allocator,
+    split: *const tui.split_model.Model,
+    model: *const tui.client_model.ClientModel,
+    server_panel_text: []const u8,
+) ![]const u8 {
+    if (!split.detailsVisible()) return allocator.dupe(u8, server_panel_text);
+
+    const details_text = try renderDetailsPanelText(allocator, model);
+    defer allocator.free(details_text);
+
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+
+    const output_height = positiveHeight(split.serverSize().height);
+    switch (split.details_placement) {
+        .none => try out.appendSlice(server_panel_text),
+        .right => try appendColumns(
+            io.BufferOutput.writer(&out, null),
+            server_panel_text,
+            details_text,
+            outputColumnWidth(split),
+            positiveWidth(split.detailsSize().width),
+            output_height,
+        ),
+        .bottom => {
+            try appendHeadLines(&out, server_panel_text, output_height);
+            var padding = visibleLineCount(server_panel_text);
+            while (padding < output_height) : (padding += 1) try out.append('\n');
+            try appendHeadLines(&out, details_text, positiveHeight(split.detailsSize().height));
+        },
+    }
+    return out.toOwnedSlice();
+}
+
+/// Visible width of the output column. Side-by-side client layouts reserve
+/// their separator out of the server region.
+fn outputColumnWidth(split: *const tui.split_model.Model) usize {
+    const width = positiveWidth(split.serverSize().width);
+    if (!split.clientVisible()) return width;
+    return switch (split.orientation) {
+        .left, .right => widthWithoutSeparator(width),
+        .top, .bottom => width,
+    };
+}
+
+fn renderDetailsPanelText(
+    allocator: std.mem.Allocator,
+    model: *const tui.client_model.ClientModel,
+) ![]const u8 {
+    var out = std.array_list.Managed(u8).init(allocator);
+    errdefer out.deinit();
+
+    const summary = model.activeProcessSummary() orelse {
+        try out.appendSlice("Details\nNo process selected\n");
+        return out.toOwnedSlice();
+    };
+
+    try out.writer().print("Details: {s}\n", .{summary.label});
+    if (summary.description.len > 0) try out.writer().print("{s}\n", .{summary.description});
+    if (summary.annotation.len > 0) try out.writer().print("Note: {s}\n", .{summary.annotation});
+    if (summary.categories.len > 0) {
+        try out.appendSlice("Categories: ");
+        for (summary.categories, 0..) |category, index| {
+            if (index > 0) try out.appendSlice(", ");
+            try out.appendSlice(category);
+        }
+        try out.append('\n');
+    }
+    if (summary.docs.len > 0) {
+        try out.append('\n');
+        try out.appendSlice(summary.docs);
+        if (summary.docs[summary.docs.len - 1] != '\n') try out.append('\n');
+    }
     return out.toOwnedSlice();
 }
 
 fn appendServerHeader(
     out: *std.array_list.Managed(u8),
     model: *const tui.client_model.ClientModel,
+    skipped_lines: u64,
 ) !void {
     const label = activeProcessLabel(model);
     if (label.len == 0) {
@@ -189,12 +316,14 @@ fn appendServerHeader(
         return;
     }
 
+    try out.writer().print("Output: {s}", .{label});
     if (activeProcessStatus(model)) |status| {
-        try out.writer().print("Output: {s}  {s}\n", .{ label, statusText(status) });
-        return;
+        try out.writer().print("  {s}", .{statusText(status)});
     }
-
-    try out.writer().print("Output: {s}\n", .{label});
+    if (skipped_lines > 0) {
+        try out.writer().print("  ({d} lines skipped)", .{skipped_lines});
+    }
+    try out.append('\n');
 }
 
 fn activeProcessLabel(model: *const tui.client_model.ClientModel) []const u8 {
@@ -213,6 +342,8 @@ fn statusText(status: domain.process.ProcessStatus) []const u8 {
         .halting => "halting",
         .halted => "halted",
         .exited => "exited",
+        .starting => "starting",
+        .restarting => "restarting",
         .unknown => "unknown",
     };
 }
@@ -234,6 +365,16 @@ fn appendTailLines(out: *std.array_list.Managed(u8), text: []const u8, max_lines
     }
 }
 
+fn appendHeadLines(out: *std.array_list.Managed(u8), text: []const u8, max_lines: usize) !void {
+    const count = @min(visibleLineCount(text), max_lines);
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    var line_index: usize = 0;
+    while (line_index < count) : (line_index += 1) {
+        try out.appendSlice(lines.next() orelse "");
+        try out.append('\n');
+    }
+}
+
 const LineWindow = enum {
     head,
     tail,
@@ -279,6 +420,32 @@ fn writeSideBySide(
     }
 }
 
+/// Like `writeSideBySide`, but leaves line tails alone because the result is
+/// embedded in another pane rather than written to the terminal directly.
+fn appendColumns(
+    output: io.Output,
+    left: []const u8,
+    right: []const u8,
+    left_width: usize,
+    right_width: usize,
+    height: usize,
+) !void {
+    var left_lines = std.mem.splitScalar(u8, left, '\n');
+    var right_lines = std.mem.splitScalar(u8, right, '\n');
+
+    var row: usize = 0;
+    while (row < height) : (row += 1) {
+        const left_line = trimLineRight(left_lines.next() orelse "");
+        const right_line = trimLineRight(right_lines.next() orelse "");
+
+        const left_display_width = try writeFittedLine(output, left_line, left_width);
+        if (left_display_width < left_width) try writeSpaces(output, left_width - left_display_width);
+        try output.writeAll(side_by_side_separator);
+        _ = try writeFittedLine(output, right_line, right_width);
+        try output.writeAll("\n");
+    }
+}
+
 fn skipWindowedLines(lines: anytype, text: []const u8, height: usize, window: LineWindow) void {
     if (window == .head or height == 0) return;
     const count = visibleLineCount(text);
@@ -484,6 +651,27 @@ test "side-by-side renderer tails server output without scrolling client away" {
     try std.testing.expect(std.mem.indexOf(u8, out.items, "five") != null);
 }
 
+test "details columns pad output before the details separator" {
+    const test_io = @import("../test_support/io.zig");
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try appendColumns(
+        test_io.TestOutput.writer(&out),
+        "Output: api\nready\n",
+        "Details: api\nServes HTTP\n",
+        8,
+        12,
+        3,
+    );
+
+    var lines = std.mem.splitScalar(u8, out.items, '\n');
+    try std.testing.expectEqualStrings("Output: " ++ side_by_side_separator ++ "Details: api", lines.next().?);
+    try std.testing.expectEqualStrings("ready   " ++ side_by_side_separator ++ "Serves HTTP", lines.next().?);
+    try std.testing.expectEqualStrings("        " ++ side_by_side_separator, lines.next().?);
+    try std.testing.expect(std.mem.indexOf(u8, out.items, terminal.repaint.clear_line_tail) == null);
+}
+
 test "status bar moves to bottom row before rendering" {
     const test_config = @import("../test_support/config.zig");
     const test_io = @import("../test_support/io.zig");
@@ -496,6 +684,8 @@ test "status bar moves to bottom row before rendering" {
 
     var session: tui.client_session.ClientSession = undefined;
     session.allocator = std.testing.allocator;
+    session.model.active_view = null;
+    session.model.sort_mode = .config;
 
     var out = std.array_list.Managed(u8).init(std.testing.allocator);
     defer out.deinit();
@@ -521,6 +711,8 @@ test "status bar clips to terminal width before clearing line tail" {
 
     var session: tui.client_session.ClientSession = undefined;
     session.allocator = std.testing.allocator;
+    session.model.active_view = null;
+    session.model.sort_mode = .config;
 
     var out = std.array_list.Managed(u8).init(std.testing.allocator);
     defer out.deinit();
@@ -567,7 +759,7 @@ test "frame clears stale content before pinned status bar" {
     var out = std.array_list.Managed(u8).init(std.testing.allocator);
     defer out.deinit();
 
-    try frame(&session, &split, "NO PROCESS", test_io.TestOutput.writer(&out));
+    try frame(&session, &split, .{ .text = "NO PROCESS" }, test_io.TestOutput.writer(&out));
 
     try std.testing.expect(std.mem.indexOf(
         u8,
@@ -576,6 +768,40 @@ test "frame clears stale content before pinned status bar" {
     ) != null);
 }
 
+test "frame header reports lines skipped between frames" {
+    const test_config = @import("../test_support/config.zig");
+    const test_io = @import("../test_support/io.zig");
+
+    var cfg = try test_config.standardRenderConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var split = tui.split_model.Model.init(.left, &cfg);
+    try split.resize(100, 24);
+
+    var app_state = try domain.state.AppState.init(std.testing.allocator, &cfg);
+    defer app_state.deinit();
+    app_state.current_proc_id = domain.process.ProcessId.fromInt(2);
+
+    var views = test_config.standardRenderViews(&cfg);
+    var snapshot = try test_config.snapshotFromViews(std.testing.allocator, &cfg, app_state.current_proc_id, views[0..]);
+    defer snapshot.deinit(std.testing.allocator);
+
+    var session: tui.client_session.ClientSession = undefined;
+    session.allocator = std.testing.allocator;
+    session.model = try tui.client_model.ClientModel.init(std.testing.allocator, snapshot.view());
+    defer session.model.deinit();
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    try frame(&session, &split, .{ .text = "SERVER" }, test_io.TestOutput.writer(&out));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "lines skipped") == null);
+
+    out.clearRetainingCapacity();
+    try frame(&session, &split, .{ .text = "SERVER", .skipped_lines = 1200 }, test_io.TestOutput.writer(&out));
+    try std.testing.expect(std.mem.indexOf(u8, out.items, "(1200 lines skipped)") != null);
+}
+
 test "frame renders small terminal resize message" {
     const test_config = @import("../test_support/config.zig");
     const test_io = @import("../test_support/io.zig");
@@ -592,7 +818,7 @@ test "frame renders small terminal resize message" {
     var out = std.array_list.Managed(u8).init(std.testing.allocator);
     defer out.deinit();
 
-    try frame(&session, &split, "READY", test_io.TestOutput.writer(&out));
+    try frame(&session, &split, .{ .text = "READY" }, test_io.TestOutput.writer(&out));
 
     try std.testing.expect(std.mem.indexOf(u8, out.items, "Terminal too small") != null);
     try std.testing.expect(std.mem.indexOf(u8, out.items, "80x24") != null);
@@ -627,7 +853,7 @@ test "frame renders help as full width overlay" {
     var out = std.array_list.Managed(u8).init(std.testing.allocator);
     defer out.deinit();
 
-    try frame(&session, &split, "SERVER", test_io.TestOutput.writer(&out));
+    try frame(&session, &split, .{ .text = "SERVER" }, test_io.TestOutput.writer(&out));
 
     try std.testing.expect(std.mem.indexOf(u8, out.items, "Help") != null);
     try std.testing.expect(std.mem.indexOf(u8, out.items, "Focus") != null);
@@ -1,5 +1,5 @@
 //! Unified-mode namespace.
-//! Importers use this root for argument rewriting, child primary adapters, rendering, runtime loops, and server-output state.
+//! Importers use this root for argument rewriting, child primary adapters, rendering, runtime loops, render wakeups, and server-output state.
 
 pub const args = @import("args.zig");
 pub const child_primary = @import("child_primary.zig");
@@ -7,6 +7,8 @@ pub const in_process_primary = @import("in_process_primary.zig");
 pub const render = @import("render.zig");
 pub const runtime = @import("runtime.zig");
 pub const server_output = @import("server_output.zig");
+pub const ui_state = @import("ui_state.zig");
+pub const wakeup = @import("wakeup.zig");
 
 pub const orientationForCli = args.orientationForCli;
 pub const childArgs = args.childArgs;
@@ -19,4 +21,6 @@ test {
     _ = render;
     _ = runtime;
     _ = server_output;
+    _ = ui_state;
+    _ = wakeup;
 }
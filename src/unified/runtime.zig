@@ -6,17 +6,25 @@ const builtin = @import("builtin");
 const cli = @import("../cli/root.zig");
 const config = @import("../config/root.zig");
 const ipc = @import("../ipc/root.zig");
+const logging = @import("../logging/root.zig");
 const io = @import("../modes/io.zig");
 const primary = @import("../primary/root.zig");
 const terminal = @import("../terminal/root.zig");
+const threads = @import("../threads/root.zig");
 const tui = @import("../tui/root.zig");
 const args_mod = @import("args.zig");
 const child_primary = @import("child_primary.zig");
 const in_process_primary = @import("in_process_primary.zig");
 const render = @import("render.zig");
 const server_output = @import("server_output.zig");
+const ui_state = @import("ui_state.zig");
+const wakeup_mod = @import("wakeup.zig");
 
-const log = std.log.scoped(.unified_runtime);
+const log = std.log.scoped(.unified);
+
+/// Longest the render loop sleeps without a wakeup, so terminal size is still
+/// checked when SIGWINCH is unavailable.
+const idle_wait_ms = 1000;
 
 /// Runs Unified Mode, choosing the production child-process adapter or the
 /// in-process test adapter while sharing the same event-loop implementation.
@@ -25,16 +33,17 @@ pub fn run(
     dir: std.fs.Dir,
     parent_args: []const []const u8,
     config_file: []const u8,
+    launch: config.launch.Options,
     orientation: cli.UnifiedSplit,
     input: io.Input,
     output: io.Output,
 ) !void {
     if (builtin.is_test) {
-        try runInProcess(allocator, dir, config_file, orientation, input, output);
+        try runInProcess(allocator, dir, config_file, launch, orientation, input, output);
         return;
     }
 
-    try runWithChildProcess(allocator, dir, parent_args, config_file, orientation, input, output);
+    try runWithChildProcess(allocator, dir, parent_args, config_file, launch, orientation, input, output);
 }
 
 fn runWithChildProcess(
@@ -42,12 +51,22 @@ fn runWithChildProcess(
     dir: std.fs.Dir,
     parent_args: []const []const u8,
     config_file: []const u8,
+    launch: config.launch.Options,
     orientation: cli.UnifiedSplit,
     input: io.Input,
     output: io.Output,
 ) !void {
-    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    // The child primary gets the same flags through `childArgs`.
+    var loaded = try config.runtime.loadInDirWithOptions(allocator, dir, config_file, launch);
     defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
+    // Only interactive launches count as recent, as in primary mode.
+    if (output.fd != null) {
+        config.recent.remember(allocator, loaded.config.file_path) catch |err| {
+            log.warn("could not record recent config: {s}", .{@errorName(err)});
+        };
+    }
 
     const child_args = try args_mod.childArgs(allocator, parent_args);
     defer args_mod.deinitArgs(allocator, child_args);
@@ -67,6 +86,7 @@ fn runWithChildProcess(
     const child_cwd = std.fs.path.dirname(loaded.config.file_path) orelse ".";
     const child = try child_primary.ChildPrimary.init(allocator, child_argv, &env_map, child_cwd);
     defer child.deinit();
+    child.shutdown_timeout_ms = primary.shutdownTimeoutMs(&loaded.config);
 
     const socket_path = try ipc.socket.waitPathForConfig(allocator, &loaded.config);
     defer allocator.free(socket_path);
@@ -79,8 +99,22 @@ fn runWithChildProcess(
         tui.client_session.IpcTransport.transport(&ipc_client),
     );
     defer session.deinit();
-
-    var split = tui.split_model.Model.init(args_mod.orientationForCli(orientation), &loaded.config);
+    try session.model.addKeybindingConflicts(loaded.warnings.items);
+    // The embedded primary exits with the UI, so quitting always stops.
+    session.model.can_detach = false;
+    // The output pane is drawn through an emulator, which drops BEL.
+    session.model.output_pane_rings = false;
+    const pins_path = try tui.pin_state.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(pins_path);
+    try session.restorePins(pins_path);
+
+    const ui_state_path = try ui_state.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(ui_state_path);
+
+    const saved = ui_state.load(std.fs.cwd(), ui_state_path);
+    if (saved.sort_mode) |mode| try session.model.setSortMode(mode);
+
+    var split = initSplit(orientation, &loaded.config, saved);
     split.setServerInput(child.sink());
     const labels = try processLabels(allocator, &session);
     defer allocator.free(labels);
@@ -95,6 +129,7 @@ fn runWithChildProcess(
         .input = input,
         .output = output,
         .stopped = &stopped,
+        .ui_state_path = ui_state_path,
         .sync_selection_after_command = true,
     });
 }
@@ -103,19 +138,23 @@ fn runInProcess(
     allocator: std.mem.Allocator,
     dir: std.fs.Dir,
     config_file: []const u8,
+    launch: config.launch.Options,
     orientation: cli.UnifiedSplit,
     input: io.Input,
     output: io.Output,
 ) !void {
-    var loaded = try config.runtime.loadInDir(allocator, dir, config_file);
+    var loaded = try config.runtime.loadInDirWithOptions(allocator, dir, config_file, launch);
     defer loaded.deinit();
+    try logging.configure(&loaded.config);
+    defer logging.reset();
 
-    const socket_path = try ipc.socket.createPathForConfig(allocator, &loaded.config);
-    defer allocator.free(socket_path);
-    defer std.fs.deleteFileAbsolute(socket_path) catch {};
+    const claim = try ipc.socket.claimPathForConfig(allocator, &loaded.config, .{});
+    defer claim.release(allocator);
+    const socket_path = claim.path;
 
     var primary_server = try primary.Server.init(allocator, &loaded.config);
     defer primary_server.deinit();
+    primary_server.ipc_token = claim.token;
 
     var stopped = std.atomic.Value(bool).init(false);
     var primary_run = in_process_primary.PrimaryRun{
@@ -123,7 +162,7 @@ fn runInProcess(
         .socket_path = socket_path,
         .stopped = &stopped,
     };
-    const primary_thread = try std.Thread.spawn(.{}, in_process_primary.runPrimaryServer, .{&primary_run});
+    const primary_thread = try threads.spawn(.{}, in_process_primary.runPrimaryServer, .{&primary_run});
     var primary_joined = false;
     errdefer {
         stopped.store(true, .seq_cst);
@@ -140,12 +179,26 @@ fn runInProcess(
         tui.client_session.IpcTransport.transport(&ipc_client),
     );
     defer session.deinit();
+    try session.model.addKeybindingConflicts(loaded.warnings.items);
+    // The embedded primary exits with the UI, so quitting always stops.
+    session.model.can_detach = false;
+    // The output pane is drawn through an emulator, which drops BEL.
+    session.model.output_pane_rings = false;
+    const pins_path = try tui.pin_state.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(pins_path);
+    try session.restorePins(pins_path);
 
     var server_input = in_process_primary.ServerInput{
         .primary_server = &primary_server,
         .session = &session,
     };
-    var split = tui.split_model.Model.init(args_mod.orientationForCli(orientation), &loaded.config);
+    const ui_state_path = try ui_state.pathForConfig(allocator, &loaded.config);
+    defer allocator.free(ui_state_path);
+
+    const saved = ui_state.load(std.fs.cwd(), ui_state_path);
+    if (saved.sort_mode) |mode| try session.model.setSortMode(mode);
+
+    var split = initSplit(orientation, &loaded.config, saved);
     split.setServerInput(server_input.sink());
     const labels = try processLabels(allocator, &session);
     defer allocator.free(labels);
@@ -159,6 +212,7 @@ fn runInProcess(
         .input = input,
         .output = output,
         .stopped = &stopped,
+        .ui_state_path = ui_state_path,
     });
 
     stopped.store(true, .seq_cst);
@@ -176,9 +230,27 @@ const RuntimeSession = struct {
     input: io.Input,
     output: io.Output,
     stopped: *std.atomic.Value(bool),
+    ui_state_path: []const u8,
     sync_selection_after_command: bool = false,
 };
 
+/// Explicit orientation flags win; plain `--unified` reopens the saved split.
+/// A saved client ratio overrides `layout.unified_client_ratio`.
+fn initSplit(
+    orientation: cli.UnifiedSplit,
+    cfg: *const config.schema.Config,
+    saved: ui_state.State,
+) tui.split_model.Model {
+    const initial = if (orientation != .none)
+        args_mod.orientationForCli(orientation)
+    else
+        saved.orientation orelse .left;
+
+    var split = tui.split_model.Model.init(initial, cfg);
+    if (saved.client_ratio) |ratio| split.setClientRatio(ratio);
+    return split;
+}
+
 fn runInteractiveRuntime(runtime: RuntimeSession) !void {
     try runtime.output.writeAll(terminal.repaint.hide_cursor);
     defer runtime.output.writeAll(terminal.repaint.show_cursor) catch {};
@@ -188,6 +260,16 @@ fn runInteractiveRuntime(runtime: RuntimeSession) !void {
     var output_state = try server_output.State.init(runtime.session.allocator, runtime.target);
     defer output_state.deinit();
 
+    // Process output, resizes, and shutdown wake the render loop instead of
+    // it polling on a fixed tick.
+    var wakeup = try wakeup_mod.Wakeup.init();
+    defer wakeup.deinit();
+    setOutputWakeup(runtime.target, &wakeup);
+    defer setOutputWakeup(runtime.target, null);
+    if (runtime.output.fd != null) terminal.winch.install();
+    terminal.winch.setWakeFd(wakeup.write_fd);
+    defer terminal.winch.setWakeFd(null);
+
     // Input and render loops both touch ClientSession and split/output state;
     // one mutex keeps terminal frames coherent without splitting ownership.
     var render_mutex = std.Thread.Mutex{};
@@ -201,11 +283,13 @@ fn runInteractiveRuntime(runtime: RuntimeSession) !void {
         .output = runtime.output,
         .stopped = runtime.stopped,
         .mutex = &render_mutex,
+        .wakeup = &wakeup,
     };
-    const render_thread = try std.Thread.spawn(.{}, runRenderLoop, .{&render_run});
+    const render_thread = try threads.spawn(.{}, runRenderLoop, .{&render_run});
     var render_joined = false;
     errdefer {
         runtime.stopped.store(true, .seq_cst);
+        wakeup.signal();
         if (!render_joined) render_thread.join();
     }
 
@@ -216,15 +300,25 @@ fn runInteractiveRuntime(runtime: RuntimeSession) !void {
         .input = runtime.input,
         .output = runtime.output,
         .mutex = &render_mutex,
+        .wakeup = &wakeup,
+        .ui_state_path = runtime.ui_state_path,
         .sync_selection_after_command = runtime.sync_selection_after_command,
     });
 
     runtime.stopped.store(true, .seq_cst);
+    wakeup.signal();
     render_thread.join();
     render_joined = true;
     try render_run.result.finish();
 }
 
+fn setOutputWakeup(target: server_output.Target, wakeup: ?*wakeup_mod.Wakeup) void {
+    switch (target) {
+        .child => |child| child.setOutputWakeup(wakeup),
+        .in_process => |server| server.controller.setOutputNotifier(if (wakeup) |value| value.writeNotifier() else null),
+    }
+}
+
 const ThreadResult = union(enum) {
     running,
     completed,
@@ -245,6 +339,8 @@ const InputLoop = struct {
     input: io.Input,
     output: io.Output,
     mutex: *std.Thread.Mutex,
+    wakeup: *wakeup_mod.Wakeup,
+    ui_state_path: []const u8,
     sync_selection_after_command: bool,
 };
 
@@ -256,6 +352,9 @@ fn runInputLoop(state: InputLoop) !void {
 
         state.mutex.lock();
         defer state.mutex.unlock();
+        // Keys can defer a selection switch; the render loop has to learn the
+        // new deadline rather than sleep through it.
+        defer state.wakeup.signal();
 
         var should_render = false;
         var index: usize = 0;
@@ -263,6 +362,7 @@ fn runInputLoop(state: InputLoop) !void {
             var key_buf: [1]u8 = undefined;
             if (tui.key_input.keyForInput(buffer[0..n], &index, &key_buf)) |key| {
                 const previous_focus = state.split.focusedPane();
+                const previous_layout = ui_state.State.fromUi(state.split, &state.session.model);
                 should_render = true;
                 const handling = try handleKey(state, key);
                 if (handling.stop) {
@@ -274,7 +374,14 @@ fn runInputLoop(state: InputLoop) !void {
                     should_render = false;
                     continue;
                 }
-                if (state.split.focusedPane() != previous_focus) {
+                const layout = ui_state.State.fromUi(state.split, &state.session.model);
+                const layout_changed = !std.meta.eql(layout, previous_layout);
+                if (layout_changed) {
+                    ui_state.save(std.fs.cwd(), state.ui_state_path, layout) catch |err| {
+                        log.debug("failed to save unified UI state: {s}", .{@errorName(err)});
+                    };
+                }
+                if (state.split.focusedPane() != previous_focus or layout_changed) {
                     try renderFrame(state.session, state.split, state.output_state, state.output);
                     should_render = false;
                 }
@@ -316,10 +423,23 @@ fn renderFrame(
     output_state: *server_output.State,
     output: io.Output,
 ) !void {
-    const placeholder = std.mem.trim(u8, split.app_config.layout.placeholder_banner, " \t\r\n");
+    try output_state.syncProcessSize(split, session.model.active_proc_id);
+    const placeholder = try placeholderText(session, split.app_config);
+    defer session.allocator.free(placeholder);
     const server_text = try output_state.renderText(split, session.model.active_proc_id, placeholder);
     defer session.allocator.free(server_text);
-    try render.frame(session, split, server_text, output);
+    try render.frame(session, split, .{
+        .text = server_text,
+        .skipped_lines = output_state.skipped_lines,
+    }, output);
+    session.recordFrame();
+}
+
+/// Banner shown in the output pane until the selected process prints anything.
+fn placeholderText(session: *tui.client_session.ClientSession, app_config: *const config.schema.Config) ![]const u8 {
+    const banner = std.mem.trim(u8, app_config.layout.placeholder_banner, " \t\r\n");
+    if (session.model.no_color) return session.allocator.dupe(u8, banner);
+    return tui.render.colorizeLines(session.allocator, banner, session.model.style().placeholder_banner_color);
 }
 
 fn resizeLayout(
@@ -374,15 +494,35 @@ const RenderLoop = struct {
     output: io.Output,
     stopped: *std.atomic.Value(bool),
     mutex: *std.Thread.Mutex,
+    wakeup: *wakeup_mod.Wakeup,
     result: ThreadResult = .running,
 };
 
 fn runRenderLoop(state: *RenderLoop) void {
+    const frame_interval_ms = frameIntervalMs(state.split.app_config);
+    var last_frame_ms: i64 = 0;
     while (!state.stopped.load(.seq_cst)) {
-        std.Thread.sleep(75 * std.time.ns_per_ms);
+        waitForWork(state) catch |err| {
+            state.result = .{ .failed = err };
+            return;
+        };
+        if (state.stopped.load(.seq_cst)) break;
+
+        // Bursty output keeps waking the loop; holding off until the frame
+        // interval has passed batches it into one redraw.
+        const since_frame_ms = std.time.milliTimestamp() - last_frame_ms;
+        if (since_frame_ms >= 0 and since_frame_ms < frame_interval_ms) {
+            std.Thread.sleep(@as(u64, @intCast(frame_interval_ms - since_frame_ms)) * std.time.ns_per_ms);
+        }
+        state.wakeup.drain();
+
         state.mutex.lock();
         defer state.mutex.unlock();
 
+        _ = state.session.flushPendingSwitch(std.time.milliTimestamp()) catch |err| {
+            state.result = .{ .failed = err };
+            return;
+        };
         const snapshot_changed = readPendingSnapshot(state.session, state.ipc_client) catch |err| {
             if (state.stopped.load(.seq_cst) or err == error.EndOfStream) break;
             state.result = .{ .failed = err };
@@ -396,16 +536,47 @@ fn runRenderLoop(state: *RenderLoop) void {
             state.result = .{ .failed = err };
             return;
         };
-        if (!snapshot_changed and !resized and !output_changed) continue;
+        const messages_expired = state.session.model.expireMessages(std.time.milliTimestamp());
+        if (!snapshot_changed and !resized and !output_changed and !messages_expired) continue;
 
         renderFrame(state.session, state.split, state.output_state, state.output) catch |err| {
             state.result = .{ .failed = err };
             return;
         };
+        last_frame_ms = std.time.milliTimestamp();
     }
     state.result = .completed;
 }
 
+/// Shortest gap between render-loop frames, from `layout.max_output_fps`.
+/// Output arriving in between lands in the next frame. Configs that skipped
+/// defaults, such as test fixtures, get the default 30 fps.
+fn frameIntervalMs(app_config: *const config.schema.Config) i64 {
+    const fps: i64 = if (app_config.layout.max_output_fps > 0) app_config.layout.max_output_fps else 30;
+    return @divTrunc(std.time.ms_per_s, fps);
+}
+
+/// Sleeps until IPC data arrives, the wakeup is signaled, a deferred selection
+/// switch or message expiry is due, or `idle_wait_ms` passes.
+fn waitForWork(state: *RenderLoop) !void {
+    var timeout_ms: i32 = idle_wait_ms;
+    state.mutex.lock();
+    const now_ms = std.time.milliTimestamp();
+    if (state.session.pendingSwitchTimeoutMs(now_ms)) |switch_ms| {
+        timeout_ms = @min(timeout_ms, switch_ms);
+    }
+    if (state.session.model.messageExpiryTimeoutMs(now_ms)) |expiry_ms| {
+        timeout_ms = @min(timeout_ms, expiry_ms);
+    }
+    state.mutex.unlock();
+
+    var poll_fds = [_]std.posix.pollfd{
+        .{ .fd = state.ipc_client.stream.handle, .events = std.posix.POLL.IN, .revents = 0 },
+        .{ .fd = state.wakeup.read_fd, .events = std.posix.POLL.IN, .revents = 0 },
+    };
+    _ = try std.posix.poll(&poll_fds, timeout_ms);
+}
+
 fn readPendingSnapshot(
     session: *tui.client_session.ClientSession,
     ipc_client: *ipc.client.Client,
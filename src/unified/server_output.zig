@@ -22,9 +22,23 @@ pub const State = struct {
     target: Target,
     child: ?ChildState = null,
     processes: ProcessMap,
+    synced_size: ?SyncedSize = null,
+    viewed_process_id: ?domain.process.ProcessId = null,
+    /// Output lines that scrolled through the pane between frames without ever
+    /// being drawn, counted since the viewed process was selected.
+    skipped_lines: u64 = 0,
 
     const ProcessMap = std.AutoHashMap(domain.process.ProcessId, ProcessState);
 
+    /// Last PTY size pushed to the target. The pid is part of the key so a
+    /// restarted process, which starts at its configured size, is resized again.
+    const SyncedSize = struct {
+        process_id: domain.process.ProcessId,
+        pid: i32,
+        cols: u16,
+        rows: u16,
+    };
+
     const ChildState = struct {
         terminal: terminal.ghostty_vt.Terminal,
         selected_process_id: domain.process.ProcessId,
@@ -92,10 +106,53 @@ pub const State = struct {
         const cols = dimension(size.width);
         const rows = dimension(size.height);
 
+        // The first frame after a switch replays history rather than skipping it.
+        const switched = self.viewed_process_id == null or self.viewed_process_id.? != active_proc_id;
+        if (switched) {
+            self.viewed_process_id = active_proc_id;
+            self.skipped_lines = 0;
+        }
+
         return switch (self.target) {
-            .child => |child| self.renderChild(child, active_proc_id, cols, rows, placeholder),
-            .in_process => |server| self.renderProcess(server, active_proc_id, cols, rows, placeholder),
+            .child => |child| self.renderChild(child, active_proc_id, cols, rows, placeholder, !switched),
+            .in_process => |server| self.renderProcess(server, active_proc_id, cols, rows, placeholder, !switched),
+        };
+    }
+
+    /// Keeps the viewed PTY the same size as the server pane so full-screen
+    /// programs render for the space they are drawn into.
+    pub fn syncProcessSize(
+        self: *State,
+        split: *const tui.split_model.Model,
+        active_proc_id: domain.process.ProcessId,
+    ) !void {
+        const size = split.serverSize();
+        if (size.width <= 0 or size.height <= 0) return;
+
+        const next = SyncedSize{
+            .process_id = active_proc_id,
+            .pid = switch (self.target) {
+                .child => |child| child.pid,
+                .in_process => |server| server.controller.getPID(active_proc_id),
+            },
+            .cols = dimension(size.width),
+            .rows = dimension(size.height),
         };
+        if (self.synced_size) |previous| {
+            if (std.meta.eql(previous, next)) return;
+        }
+
+        switch (self.target) {
+            .child => |child| try child.resize(next.rows, next.cols),
+            .in_process => |server| {
+                if (next.pid < 0) return;
+                server.controller.resizeProcess(active_proc_id, next.rows, next.cols) catch |err| switch (err) {
+                    error.ProcessNotFound, error.ProcessNotRunning => return,
+                    else => return err,
+                };
+            },
+        }
+        self.synced_size = next;
     }
 
     pub fn hasPendingOutput(
@@ -142,6 +199,7 @@ pub const State = struct {
         cols: u16,
         rows: u16,
         placeholder: []const u8,
+        count_skipped: bool,
     ) ![]const u8 {
         if (self.child == null) {
             self.child = .{
@@ -159,8 +217,11 @@ pub const State = struct {
 
         const bytes = try child.readSince(self.allocator, &state.cursor);
         defer self.allocator.free(bytes);
+        // The snapshot that follows a switch is a redraw, not streamed output.
+        const streaming = count_skipped and !state.awaiting_snapshot;
         const bytes_to_write = try bytesForSelectedProcess(state, bytes);
         if (bytes_to_write.len > 0) {
+            if (streaming) self.skipped_lines += skippedLineCount(bytes_to_write, rows);
             state.has_output = true;
             try state.terminal.write(bytes_to_write);
         }
@@ -176,6 +237,7 @@ pub const State = struct {
         cols: u16,
         rows: u16,
         placeholder: []const u8,
+        count_skipped: bool,
     ) ![]const u8 {
         if (active_proc_id.isNone()) return self.allocator.dupe(u8, placeholder);
 
@@ -187,6 +249,7 @@ pub const State = struct {
         if (scrollback.len == 0) return self.allocator.dupe(u8, placeholder);
 
         const entry = try self.processes.getOrPut(active_proc_id);
+        var catching_up = !count_skipped or !entry.found_existing;
         if (!entry.found_existing) {
             entry.value_ptr.* = .{
                 .terminal = try terminal.ghostty_vt.Terminal.init(self.allocator, cols, rows),
@@ -201,10 +264,13 @@ pub const State = struct {
             process.* = .{
                 .terminal = try terminal.ghostty_vt.Terminal.init(self.allocator, cols, rows),
             };
+            catching_up = true;
         }
 
         if (scrollback.len > process.consumed_len) {
-            try process.terminal.write(scrollback[process.consumed_len..]);
+            const bytes = scrollback[process.consumed_len..];
+            if (!catching_up) self.skipped_lines += skippedLineCount(bytes, rows);
+            try process.terminal.write(bytes);
             process.consumed_len = scrollback.len;
         }
 
@@ -223,6 +289,13 @@ fn bytesForSelectedProcess(state: *State.ChildState, bytes: []const u8) ![]const
     return pending[reset_index..];
 }
 
+/// Lines in one frame's worth of new output that scroll past a pane of
+/// `rows` rows before the frame is drawn.
+fn skippedLineCount(bytes: []const u8, rows: u16) u64 {
+    const lines: u64 = std.mem.count(u8, bytes, "\n");
+    return lines -| rows;
+}
+
 fn dimension(value: i32) u16 {
     if (value <= 0) return 1;
     return @intCast(@min(value, std.math.maxInt(u16)));
@@ -333,3 +406,46 @@ test "child target reports pending output only when child output advances" {
     try child.output.appendSlice("SECOND\n");
     try std.testing.expect(try output.hasPendingOutput(domain.process.ProcessId.fromInt(1)));
 }
+
+test "child target counts lines that scroll past between frames" {
+    const test_config = @import("../test_support/config.zig");
+
+    var cfg = try test_config.basicConfig(std.testing.allocator);
+    defer cfg.deinit();
+
+    var split = tui.split_model.Model.init(.left, &cfg);
+    try split.resize(120, 40);
+    const rows = dimension(split.serverSize().height);
+
+    var child = child_primary.ChildPrimary{
+        .allocator = std.testing.allocator,
+        .pid = 0,
+        .pty_file = null,
+        .output_file = null,
+        .output = std.array_list.Managed(u8).init(std.testing.allocator),
+    };
+    defer child.output.deinit();
+
+    var output = try State.init(std.testing.allocator, .{ .child = &child });
+    defer output.deinit();
+
+    // History already on screen when the process is selected is not skipped.
+    for (0..rows + 5) |_| try child.output.appendSlice("history\n");
+    const first = try output.renderText(&split, domain.process.ProcessId.fromInt(1), "NO PROCESS");
+    defer std.testing.allocator.free(first);
+    try std.testing.expectEqual(@as(u64, 0), output.skipped_lines);
+
+    for (0..rows + 12) |_| try child.output.appendSlice("burst\n");
+    const second = try output.renderText(&split, domain.process.ProcessId.fromInt(1), "NO PROCESS");
+    defer std.testing.allocator.free(second);
+    try std.testing.expectEqual(@as(u64, 12), output.skipped_lines);
+
+    try child.output.appendSlice("one more\n");
+    const third = try output.renderText(&split, domain.process.ProcessId.fromInt(1), "NO PROCESS");
+    defer std.testing.allocator.free(third);
+    try std.testing.expectEqual(@as(u64, 12), output.skipped_lines);
+
+    const switched = try output.renderText(&split, domain.process.ProcessId.fromInt(2), "NO PROCESS");
+    defer std.testing.allocator.free(switched);
+    try std.testing.expectEqual(@as(u64, 0), output.skipped_lines);
+}
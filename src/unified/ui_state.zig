@@ -0,0 +1,124 @@
+//! Unified-mode UI state file.
+//! Layout choices made at runtime are saved per Project Config in the state directory, so the next unified session reopens the same way.
+
+const std = @import("std");
+const config = @import("../config/root.zig");
+const domain = @import("../domain/root.zig");
+const tui = @import("../tui/root.zig");
+
+const orientation_key = "orientation=";
+const client_ratio_key = "client_ratio=";
+const sort_mode_key = "sort_mode=";
+
+/// Layout choices the user made at runtime. Null fields were never saved.
+pub const State = struct {
+    orientation: ?tui.split_model.Orientation = null,
+    client_ratio: ?i32 = null,
+    sort_mode: ?domain.client_snapshot.SortMode = null,
+
+    pub fn fromUi(split: *const tui.split_model.Model, model: *const tui.client_model.ClientModel) State {
+        return .{
+            .orientation = split.orientation,
+            .client_ratio = split.client_ratio,
+            .sort_mode = model.sort_mode,
+        };
+    }
+};
+
+/// Returns the state file path for `cfg`. A file an older release saved in
+/// `/tmp` is moved there the first time, so the layout survives upgrading.
+pub fn pathForConfig(allocator: std.mem.Allocator, cfg: *const config.schema.Config) ![]const u8 {
+    const dir = try config.paths.stateDir(allocator, cfg, config.paths.Env.current());
+    defer allocator.free(dir);
+    const path = try pathInDir(allocator, dir, cfg);
+    errdefer allocator.free(path);
+
+    const legacy_path = try pathInDir(allocator, config.paths.legacy_dir, cfg);
+    defer allocator.free(legacy_path);
+    if (!std.mem.eql(u8, path, legacy_path)) migrate(std.fs.cwd(), legacy_path, path);
+    return path;
+}
+
+fn pathInDir(allocator: std.mem.Allocator, dir: []const u8, cfg: *const config.schema.Config) ![]const u8 {
+    const hash = try config.hash.toHash(allocator, cfg);
+    defer allocator.free(hash);
+
+    return std.fmt.allocPrint(allocator, "{s}/proctmux-{s}.ui-state", .{ std.mem.trimRight(u8, dir, "/"), hash });
+}
+
+/// Best effort: copies rather than renames because `/tmp` is often a separate
+/// filesystem, and leaves both files alone if `path` already exists.
+fn migrate(dir: std.fs.Dir, legacy_path: []const u8, path: []const u8) void {
+    dir.access(path, .{}) catch |err| switch (err) {
+        error.FileNotFound => {
+            dir.access(legacy_path, .{}) catch return;
+            if (std.fs.path.dirname(path)) |parent| dir.makePath(parent) catch return;
+            dir.copyFile(legacy_path, dir, path, .{}) catch return;
+            dir.deleteFile(legacy_path) catch {};
+        },
+        else => {},
+    };
+}
+
+/// Reads saved layout state. Missing or malformed entries read as unsaved
+/// because the state is only a convenience.
+pub fn load(dir: std.fs.Dir, path: []const u8) State {
+    var buffer: [256]u8 = undefined;
+    const contents = dir.readFile(path, &buffer) catch return .{};
+
+    var state = State{};
+    var lines = std.mem.splitScalar(u8, contents, '\n');
+    while (lines.next()) |line| {
+        const trimmed = std.mem.trim(u8, line, " \t\r");
+        if (std.mem.startsWith(u8, trimmed, orientation_key)) {
+            state.orientation = std.meta.stringToEnum(tui.split_model.Orientation, trimmed[orientation_key.len..]);
+        } else if (std.mem.startsWith(u8, trimmed, client_ratio_key)) {
+            state.client_ratio = std.fmt.parseInt(i32, trimmed[client_ratio_key.len..], 10) catch null;
+        } else if (std.mem.startsWith(u8, trimmed, sort_mode_key)) {
+            state.sort_mode = std.meta.stringToEnum(domain.client_snapshot.SortMode, trimmed[sort_mode_key.len..]);
+        }
+    }
+    return state;
+}
+
+pub fn save(dir: std.fs.Dir, path: []const u8, state: State) !void {
+    var buffer: [128]u8 = undefined;
+    var writer = std.Io.Writer.fixed(&buffer);
+    if (state.orientation) |orientation| try writer.print("{s}{s}\n", .{ orientation_key, @tagName(orientation) });
+    if (state.client_ratio) |ratio| try writer.print("{s}{d}\n", .{ client_ratio_key, ratio });
+    if (state.sort_mode) |mode| try writer.print("{s}{s}\n", .{ sort_mode_key, @tagName(mode) });
+    if (std.fs.path.dirname(path)) |parent| try dir.makePath(parent);
+    try dir.writeFile(.{ .sub_path = path, .data = writer.buffered() });
+}
+
+test "ui state round-trips split layout and sort mode" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try std.testing.expectEqual(State{}, load(tmp.dir, "ui-state"));
+
+    try save(tmp.dir, "ui-state", .{ .orientation = .bottom, .client_ratio = 40, .sort_mode = .most_output });
+    try std.testing.expectEqual(State{ .orientation = .bottom, .client_ratio = 40, .sort_mode = .most_output }, load(tmp.dir, "ui-state"));
+}
+
+test "ui state ignores malformed entries" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.writeFile(.{ .sub_path = "ui-state", .data = "orientation=diagonal\nclient_ratio=wide\nsort_mode=random\n" });
+    try std.testing.expectEqual(State{}, load(tmp.dir, "ui-state"));
+}
+
+test "ui state moves a legacy file and creates the state directory" {
+    var tmp = std.testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try save(tmp.dir, "legacy.ui-state", .{ .orientation = .top });
+    migrate(tmp.dir, "legacy.ui-state", "state/proctmux/current.ui-state");
+    try std.testing.expectEqual(State{ .orientation = .top }, load(tmp.dir, "state/proctmux/current.ui-state"));
+    try std.testing.expectError(error.FileNotFound, tmp.dir.access("legacy.ui-state", .{}));
+
+    try save(tmp.dir, "legacy.ui-state", .{ .orientation = .right });
+    migrate(tmp.dir, "legacy.ui-state", "state/proctmux/current.ui-state");
+    try std.testing.expectEqual(State{ .orientation = .top }, load(tmp.dir, "state/proctmux/current.ui-state"));
+}
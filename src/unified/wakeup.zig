@@ -0,0 +1,60 @@
+//! Self-pipe wakeup for the unified render loop.
+//! Output capture threads, the SIGWINCH handler, and shutdown write one byte so the loop can sleep in `poll` until there is something to draw.
+
+const std = @import("std");
+const ring = @import("../ring/root.zig");
+
+/// Non-blocking pipe whose read end becomes readable after `signal`. Repeated
+/// signals before a `drain` collapse into one wakeup once the pipe is full.
+pub const Wakeup = struct {
+    read_fd: std.posix.fd_t,
+    write_fd: std.posix.fd_t,
+
+    pub fn init() !Wakeup {
+        const fds = try std.posix.pipe2(.{ .NONBLOCK = true, .CLOEXEC = true });
+        return .{ .read_fd = fds[0], .write_fd = fds[1] };
+    }
+
+    pub fn deinit(self: *Wakeup) void {
+        std.posix.close(self.read_fd);
+        std.posix.close(self.write_fd);
+    }
+
+    /// Safe from any thread and from signal handlers.
+    pub fn signal(self: *Wakeup) void {
+        _ = std.posix.system.write(self.write_fd, "w", 1);
+    }
+
+    pub fn drain(self: *Wakeup) void {
+        var buffer: [64]u8 = undefined;
+        while (true) {
+            const n = std.posix.read(self.read_fd, &buffer) catch return;
+            if (n < buffer.len) return;
+        }
+    }
+
+    /// Ring-buffer hook that signals after every process output write.
+    pub fn writeNotifier(self: *Wakeup) ring.WriteNotifier {
+        return .{ .context = self, .notify = notify };
+    }
+
+    fn notify(context: *anyopaque) void {
+        const self: *Wakeup = @ptrCast(@alignCast(context));
+        self.signal();
+    }
+};
+
+test "wakeup becomes readable after signal and quiet after drain" {
+    var wakeup = try Wakeup.init();
+    defer wakeup.deinit();
+
+    var poll_fds = [_]std.posix.pollfd{.{ .fd = wakeup.read_fd, .events = std.posix.POLL.IN, .revents = 0 }};
+    try std.testing.expectEqual(@as(usize, 0), try std.posix.poll(&poll_fds, 0));
+
+    wakeup.signal();
+    wakeup.writeNotifier().notify(&wakeup);
+    try std.testing.expectEqual(@as(usize, 1), try std.posix.poll(&poll_fds, 0));
+
+    wakeup.drain();
+    try std.testing.expectEqual(@as(usize, 0), try std.posix.poll(&poll_fds, 0));
+}
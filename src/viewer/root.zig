@@ -4,9 +4,19 @@
 const std = @import("std");
 const domain = @import("../domain/root.zig");
 const ring = @import("../ring/root.zig");
+const terminal = @import("../terminal/root.zig");
+
+const log = std.log.scoped(.viewer);
 
 const clear_sequence = "\x1b[2J\x1b[H";
 const default_placeholder = "Select a process to stream output.";
+/// Default for `Viewer.max_relay_bytes_per_tick`.
+pub const default_max_relay_bytes_per_tick = 256 * 1024;
+
+// xterm private modes 1049, 1047, and 47 all switch to the alternate screen;
+// full-screen programs pick one and toggle it with the h/l suffix.
+const alt_screen_enter = [_][]const u8{ "\x1b[?1049h", "\x1b[?1047h", "\x1b[?47h" };
+const alt_screen_exit = [_][]const u8{ "\x1b[?1049l", "\x1b[?1047l", "\x1b[?47l" };
 
 pub const ProcessRef = struct {
     id: domain.process.ProcessId,
@@ -42,6 +52,11 @@ pub const Viewer = struct {
     current_reader_id: ?usize = null,
     current_scrollback: ?*ring.RingBuffer = null,
     placeholder: []const u8 = "",
+    /// Output written per `relayPending` call. A chatty process leaves the rest
+    /// queued for later ticks so the caller's loop still gets to handle input.
+    max_relay_bytes_per_tick: usize = default_max_relay_bytes_per_tick,
+    /// Keeps the processes' own window titles off the host terminal.
+    titles: terminal.title.Filter = .{},
 
     pub fn init(allocator: std.mem.Allocator, provider: ProcessProvider, output: Output) Viewer {
         return .{
@@ -78,9 +93,15 @@ pub const Viewer = struct {
         const reader_id = self.current_reader_id orelse return;
         const scrollback = self.current_scrollback orelse return;
 
-        while (scrollback.readNext(reader_id)) |data| {
+        var out = std.array_list.Managed(u8).init(self.allocator);
+        defer out.deinit();
+        var budget = self.max_relay_bytes_per_tick;
+        while (scrollback.readNextUpTo(reader_id, budget)) |data| {
             defer self.allocator.free(data);
-            try self.output.writeAll(data);
+            budget -= data.len;
+            out.clearRetainingCapacity();
+            try self.titles.strip(&out, data);
+            try self.output.writeAll(out.items);
         }
     }
 
@@ -91,20 +112,34 @@ pub const Viewer = struct {
         self.current_process_id = process_id;
 
         if (process_id.isNone()) {
-            try self.output.writeAll(clear_sequence);
+            try self.output.writeAll(clear_sequence ++ terminal.cursor.reset);
             try self.writePlaceholder();
             return;
         }
 
-        const proc = self.provider.getProcess(process_id) orelse return;
+        const proc = self.provider.getProcess(process_id) orelse {
+            log.debug("process {d} has no output to view", .{process_id.toInt()});
+            return;
+        };
         const sub = try proc.scrollback.snapshotAndSubscribe(self.allocator);
         defer self.allocator.free(sub.snapshot);
+        log.debug("viewing process {d} with {d} bytes of scrollback", .{ process_id.toInt(), sub.snapshot.len });
 
         self.current_reader_id = sub.reader_id;
         self.current_scrollback = proc.scrollback;
 
+        // Replaying a full-screen program's history garbles the display, so a
+        // process on the alternate screen only gets live frames from here on.
         try self.output.writeAll(clear_sequence);
-        if (sub.snapshot.len > 0) try self.output.writeAll(sub.snapshot);
+        var history = std.array_list.Managed(u8).init(self.allocator);
+        defer history.deinit();
+        self.titles.reset();
+        try self.titles.strip(&history, sub.snapshot);
+        if (sub.snapshot.len > 0 and !endsInAltScreen(sub.snapshot)) try self.output.writeAll(history.items);
+        // The previous process may have hidden or reshaped the cursor, and a
+        // skipped or partly overwritten history may not set it back.
+        var cursor_buf: [32]u8 = undefined;
+        try self.output.writeAll(try terminal.cursor.after(sub.snapshot).sequence(&cursor_buf));
     }
 
     fn writePlaceholder(self: *Viewer) !void {
@@ -128,6 +163,23 @@ pub const Viewer = struct {
     }
 };
 
+/// Reports whether replaying `bytes` would leave a terminal on the alternate
+/// screen, i.e. the last alternate-screen toggle in the stream is an enter.
+pub fn endsInAltScreen(bytes: []const u8) bool {
+    const enter = lastIndexOfAny(bytes, &alt_screen_enter) orelse return false;
+    const exit = lastIndexOfAny(bytes, &alt_screen_exit) orelse return true;
+    return enter > exit;
+}
+
+fn lastIndexOfAny(bytes: []const u8, needles: []const []const u8) ?usize {
+    var last: ?usize = null;
+    for (needles) |needle| {
+        const index = std.mem.lastIndexOf(u8, bytes, needle) orelse continue;
+        if (last == null or index > last.?) last = index;
+    }
+    return last;
+}
+
 test "viewer switch writes clear sequence and process scrollback" {
     var store = TestStore.init(std.testing.allocator);
     defer store.deinit();
@@ -142,7 +194,7 @@ test "viewer switch writes clear sequence and process scrollback" {
     try viewer.switchToProcess(domain.process.ProcessId.fromInt(1));
 
     try std.testing.expectEqual(domain.process.ProcessId.fromInt(1), viewer.currentProcessID());
-    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hexisting output\n", out.items);
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hexisting output\n\x1b[?25h\x1b[0 q", out.items);
 }
 
 test "viewer live relay follows only the current process reader" {
@@ -174,6 +226,29 @@ test "viewer live relay follows only the current process reader" {
     try std.testing.expect(std.mem.indexOf(u8, out.items, "old hidden\n") == null);
 }
 
+test "viewer relay leaves output past the per-tick budget for the next tick" {
+    var store = TestStore.init(std.testing.allocator);
+    defer store.deinit();
+    const proc = try store.add(1, 111, "");
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var viewer = Viewer.init(std.testing.allocator, TestStore.provider(&store), TestOutput.writer(&out));
+    defer viewer.deinit();
+    viewer.max_relay_bytes_per_tick = 8;
+
+    try viewer.switchToProcess(domain.process.ProcessId.fromInt(1));
+    out.clearRetainingCapacity();
+    _ = proc.write("12345");
+    _ = proc.write("67890abc");
+
+    try viewer.relayPending();
+    try std.testing.expectEqualStrings("12345678", out.items);
+    try viewer.relayPending();
+    try std.testing.expectEqualStrings("1234567890abc", out.items);
+}
+
 test "viewer process zero renders placeholder" {
     var store = TestStore.init(std.testing.allocator);
     defer store.deinit();
@@ -190,7 +265,7 @@ test "viewer process zero renders placeholder" {
     out.clearRetainingCapacity();
     try viewer.switchToProcess(.none);
 
-    try std.testing.expectEqualStrings("\x1b[2J\x1b[HNo process selected\n", out.items);
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[H\x1b[?25h\x1b[0 qNo process selected\n", out.items);
 }
 
 test "viewer refresh resends current process scrollback" {
@@ -209,7 +284,71 @@ test "viewer refresh resends current process scrollback" {
     _ = proc.write("after\n");
     try viewer.refreshCurrentProcess();
 
-    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hinitial\nafter\n", out.items);
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hinitial\nafter\n\x1b[?25h\x1b[0 q", out.items);
+}
+
+test "viewer skips scrollback dump while process is on the alternate screen" {
+    var store = TestStore.init(std.testing.allocator);
+    defer store.deinit();
+    const proc = try store.add(1, 111, "shell prompt\n\x1b[?1049h\x1b[?25l\x1b[Hfull screen frame");
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var viewer = Viewer.init(std.testing.allocator, TestStore.provider(&store), TestOutput.writer(&out));
+    defer viewer.deinit();
+
+    try viewer.switchToProcess(domain.process.ProcessId.fromInt(1));
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[H\x1b[?25l\x1b[0 q", out.items);
+
+    _ = proc.write("\x1b[Hnext frame");
+    try viewer.relayPending();
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[H\x1b[?25l\x1b[0 q\x1b[Hnext frame", out.items);
+}
+
+test "viewer switch restores the cursor a hidden-cursor process left behind" {
+    var store = TestStore.init(std.testing.allocator);
+    defer store.deinit();
+    _ = try store.add(1, 111, "\x1b[?25l\x1b[6 qspinner");
+    _ = try store.add(2, 222, "plain log\n");
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var viewer = Viewer.init(std.testing.allocator, TestStore.provider(&store), TestOutput.writer(&out));
+    defer viewer.deinit();
+
+    try viewer.switchToProcess(domain.process.ProcessId.fromInt(1));
+    try std.testing.expect(std.mem.endsWith(u8, out.items, "spinner\x1b[?25l\x1b[6 q"));
+    out.clearRetainingCapacity();
+    try viewer.switchToProcess(domain.process.ProcessId.fromInt(2));
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hplain log\n\x1b[?25h\x1b[0 q", out.items);
+}
+
+test "viewer keeps process window titles off the host terminal" {
+    var store = TestStore.init(std.testing.allocator);
+    defer store.deinit();
+    const proc = try store.add(1, 111, "\x1b]0;vim\x07history\n\x1b]2;");
+
+    var out = std.array_list.Managed(u8).init(std.testing.allocator);
+    defer out.deinit();
+
+    var viewer = Viewer.init(std.testing.allocator, TestStore.provider(&store), TestOutput.writer(&out));
+    defer viewer.deinit();
+
+    try viewer.switchToProcess(domain.process.ProcessId.fromInt(1));
+    try std.testing.expectEqualStrings("\x1b[2J\x1b[Hhistory\n\x1b[?25h\x1b[0 q", out.items);
+    out.clearRetainingCapacity();
+    _ = proc.write("make\x07live\n");
+    try viewer.relayPending();
+    try std.testing.expectEqualStrings("live\n", out.items);
+}
+
+test "alternate screen detection follows the last toggle" {
+    try std.testing.expect(!endsInAltScreen("plain output\n"));
+    try std.testing.expect(endsInAltScreen("a\x1b[?1049hb"));
+    try std.testing.expect(!endsInAltScreen("a\x1b[?1049hb\x1b[?1049lc"));
+    try std.testing.expect(endsInAltScreen("\x1b[?1049h\x1b[?1049l\x1b[?47h"));
 }
 
 const TestProcess = struct {
@@ -1,4 +1,5 @@
-from .agent_tui import AgentTuiRunner, Session, Snapshot
+from .agent_tui import AgentTuiRunner, Frame, Session, Snapshot
 from .proctmux_app import ProctmuxApp
+from .styled import Style, StyledScreen
 
-__all__ = ["AgentTuiRunner", "ProctmuxApp", "Session", "Snapshot"]
+__all__ = ["AgentTuiRunner", "Frame", "ProctmuxApp", "Session", "Snapshot", "Style", "StyledScreen"]
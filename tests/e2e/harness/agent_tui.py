@@ -12,12 +12,16 @@ from dataclasses import dataclass
 from pathlib import Path
 from typing import Callable, Optional
 
+from .styled import StyledScreen
+
 
 REPO_ROOT = Path(__file__).resolve().parents[3]
 DEFAULT_BIN = REPO_ROOT / "bin" / "proctmux"
 AGENT_TUI = os.environ.get("AGENT_TUI", "agent-tui")
 PROCTMUX_BIN = Path(os.environ.get("PROCTMUX_E2E_BIN", str(DEFAULT_BIN))).resolve()
 SHORT_TMP_PARENT = Path(os.environ.get("PROCTMUX_E2E_TMPDIR", "/tmp"))
+FRAME_LOG_ENV = "PROCTMUX_FRAME_LOG"
+FRAME_LOG_NAME = "frames.log"
 
 
 class AgentTuiError(RuntimeError):
@@ -45,6 +49,10 @@ class Snapshot:
                 server_lines.append(line.split(" │ ", 1)[1])
         return "\n".join(server_lines)
 
+    @property
+    def styled(self) -> StyledScreen:
+        return StyledScreen(self.text)
+
     def column_of(self, needle: str) -> Optional[tuple[int, str]]:
         for line in self.text.splitlines():
             idx = line.find(needle)
@@ -53,6 +61,20 @@ class Snapshot:
         return None
 
 
+@dataclass
+class Frame:
+    """One line of the `PROCTMUX_FRAME_LOG` file the TUI writes per frame."""
+
+    sequence: int
+    messages: int
+    active: str
+
+    @classmethod
+    def parse(cls, line: str) -> "Frame":
+        fields = dict(part.split("=", 1) for part in line.split(" ", 2))
+        return cls(int(fields["frame"]), int(fields["messages"]), fields.get("active", ""))
+
+
 class AgentTuiRunner:
     def __init__(self) -> None:
         SHORT_TMP_PARENT.mkdir(parents=True, exist_ok=True)
@@ -110,12 +132,14 @@ class AgentTuiRunner:
         ]
         if no_color:
             args.extend(["--env", "NO_COLOR=1"])
+        frame_log_path = cfg_dir / FRAME_LOG_NAME
+        args.extend(["--env", f"{FRAME_LOG_ENV}={frame_log_path}"])
         args.extend([str(PROCTMUX_BIN), "--", unified_flag, "-f", str(cfg_path)])
 
         result = self.agent_json(args, timeout=20)
         session_id = str(result["session_id"])
         self.sessions.append(session_id)
-        return Session(self, session_id, cfg_dir, cfg_path)
+        return Session(self, session_id, cfg_dir, cfg_path, cols=cols, rows=rows, frame_log_path=frame_log_path)
 
     def start_primary(
         self,
@@ -146,7 +170,7 @@ class AgentTuiRunner:
         result = self.agent_json(args, timeout=20)
         session_id = str(result["session_id"])
         self.sessions.append(session_id)
-        return Session(self, session_id, cfg_dir, cfg_path)
+        return Session(self, session_id, cfg_dir, cfg_path, cols=cols, rows=rows)
 
     def start_primary_client(
         self,
@@ -156,8 +180,10 @@ class AgentTuiRunner:
         cols: int = 120,
         rows: int = 40,
         no_color: bool = True,
+        extra_env: Optional[dict[str, str]] = None,
     ) -> "PrimaryClientSession":
         cfg_dir, cfg_path = self.write_config(name, config)
+        env_args = [arg for key, value in (extra_env or {}).items() for arg in ("--env", f"{key}={value}")]
         primary_args = [
             "run",
             "--json",
@@ -169,6 +195,7 @@ class AgentTuiRunner:
             str(rows),
             "--env",
             "TERM=xterm-256color",
+            *env_args,
             str(PROCTMUX_BIN),
             "--",
             "start",
@@ -190,9 +217,12 @@ class AgentTuiRunner:
             str(rows),
             "--env",
             "TERM=xterm-256color",
+            *env_args,
         ]
         if no_color:
             client_args.extend(["--env", "NO_COLOR=1"])
+        frame_log_path = cfg_dir / FRAME_LOG_NAME
+        client_args.extend(["--env", f"{FRAME_LOG_ENV}={frame_log_path}"])
         client_args.extend([str(PROCTMUX_BIN), "--", "--client", "-f", str(cfg_path)])
 
         try:
@@ -205,8 +235,20 @@ class AgentTuiRunner:
 
         client_session_id = str(client_result["session_id"])
         self.sessions.append(client_session_id)
-        client = Session(self, client_session_id, cfg_dir, cfg_path)
-        return PrimaryClientSession(self, primary_session_id, client)
+        primary = Session(self, primary_session_id, cfg_dir, cfg_path, cols=cols, rows=rows)
+        client = Session(
+            self,
+            client_session_id,
+            cfg_dir,
+            cfg_path,
+            cols=cols,
+            rows=rows,
+            frame_log_path=frame_log_path,
+        )
+        return PrimaryClientSession(self, primary, client)
+
+    def tmux_stub(self) -> "TmuxStub":
+        return TmuxStub(self.tmp_root / "tmux-stub")
 
     def write_config(self, name: str, body: str) -> tuple[Path, Path]:
         cfg_dir = self.tmp_root / slug(name)
@@ -279,11 +321,24 @@ class AgentTuiRunner:
 
 
 class Session:
-    def __init__(self, runner: AgentTuiRunner, session_id: str, config_dir: Path, config_path: Path) -> None:
+    def __init__(
+        self,
+        runner: AgentTuiRunner,
+        session_id: str,
+        config_dir: Path,
+        config_path: Path,
+        *,
+        cols: int,
+        rows: int,
+        frame_log_path: Optional[Path] = None,
+    ) -> None:
         self.runner = runner
         self.session_id = session_id
         self.config_dir = config_dir
         self.config_path = config_path
+        self.cols = cols
+        self.rows = rows
+        self.frame_log_path = frame_log_path
         self.client = Pane(self, "client")
         self.server = Pane(self, "server")
 
@@ -317,6 +372,18 @@ class Session:
             ["--session", self.session_id, "resize", "--json", "--cols", str(cols), "--rows", str(rows)],
             timeout=5,
         )
+        self.cols = cols
+        self.rows = rows
+
+    def set_size(self, *, cols: int, rows: int, settle_ms: int = 5_000) -> Snapshot:
+        """Resizes the terminal and waits for the redraw to settle, so the
+        snapshot that follows reflects the new size."""
+        self.resize(cols=cols, rows=rows)
+        snap = self.wait_stable(timeout_ms=settle_ms)
+        lines = snap.text.splitlines()
+        if len(lines) > rows or any(len(line) > cols for line in lines):
+            raise AssertionError(f"screen did not shrink to {cols}x{rows}\n{snap.text}")
+        return snap
 
     def snapshot(self, *, retain_ansi: bool = False) -> Snapshot:
         args = ["--session", self.session_id, "screenshot", "--json", "--include-cursor"]
@@ -376,6 +443,42 @@ class Session:
             time.sleep(interval)
         raise AssertionError(f"timed out waiting for {description}\n\nSnapshot:\n{last.text}")
 
+    def frames(self) -> list[Frame]:
+        """Frames the TUI has drawn so far, oldest first. Only TUI sessions
+        (unified and client) write frame markers."""
+        if self.frame_log_path is None:
+            raise AgentTuiError("session has no frame log")
+        if not self.frame_log_path.exists():
+            return []
+        lines = self.frame_log_path.read_text(encoding="utf-8").splitlines(keepends=True)
+        # A line still being written has no newline yet.
+        return [Frame.parse(line.rstrip("\n")) for line in lines if line.endswith("\n")]
+
+    def last_frame(self) -> int:
+        frames = self.frames()
+        return frames[-1].sequence if frames else 0
+
+    def wait_for_frame(
+        self,
+        description: str,
+        predicate: Callable[[Frame], bool],
+        *,
+        after: int = 0,
+        timeout: float = 10.0,
+    ) -> Frame:
+        """First frame drawn after sequence `after` that matches. The screen
+        is complete once its marker is written, so a snapshot taken next shows
+        at least that frame, with no sleep to tune."""
+        deadline = time.monotonic() + timeout
+        while True:
+            for frame in self.frames():
+                if frame.sequence > after and predicate(frame):
+                    return frame
+            if time.monotonic() >= deadline:
+                break
+            time.sleep(0.02)
+        raise AssertionError(f"timed out waiting for {description}\n\nSnapshot:\n{self.snapshot().text}")
+
     def samples(self, *, duration: float, interval: float) -> list[Snapshot]:
         deadline = time.monotonic() + duration
         samples: list[Snapshot] = []
@@ -406,9 +509,10 @@ class Session:
 
 
 class PrimaryClientSession:
-    def __init__(self, runner: AgentTuiRunner, primary_session_id: str, client: Session) -> None:
+    def __init__(self, runner: AgentTuiRunner, primary: Session, client: Session) -> None:
         self.runner = runner
-        self.primary_session_id = primary_session_id
+        self.primary = primary
+        self.primary_session_id = primary.session_id
         self.client = client
 
     def __enter__(self) -> Session:
@@ -424,6 +528,53 @@ class PrimaryClientSession:
             self.runner.sessions.remove(self.primary_session_id)
 
 
+class TmuxStub:
+    """A fake `tmux` for sessions that must not depend on a tmux server.
+
+    proctmux runs processes in its own PTYs, so nothing it does should shell
+    out to tmux even when started inside a tmux pane. The stub answers the
+    commands a pane-managing caller would use, as if one session with one
+    pane existed, and records every invocation so tests can check what was
+    called.
+    """
+
+    SCRIPT = textwrap.dedent(
+        """\
+        #!/bin/sh
+        printf '%s\\n' "$*" >> "$PROCTMUX_TMUX_STUB_LOG"
+        for arg in "$@"; do
+          case "$arg" in
+            new-session|new-window|split-window|display-message|list-panes) echo "%1"; break ;;
+          esac
+        done
+        exit 0
+        """
+    )
+
+    def __init__(self, directory: Path) -> None:
+        self.directory = directory
+        self.log_path = directory / "invocations.log"
+        directory.mkdir(parents=True, exist_ok=True)
+        script = directory / "tmux"
+        script.write_text(self.SCRIPT, encoding="utf-8")
+        script.chmod(0o755)
+
+    def env(self) -> dict[str, str]:
+        """Environment that puts the stub first on PATH and makes the session
+        look like it runs inside a tmux pane."""
+        return {
+            "PATH": f"{self.directory}:{os.environ.get('PATH', '')}",
+            "PROCTMUX_TMUX_STUB_LOG": str(self.log_path),
+            "TMUX": f"{self.directory}/socket,1,0",
+            "TMUX_PANE": "%0",
+        }
+
+    def invocations(self) -> list[str]:
+        if not self.log_path.exists():
+            return []
+        return self.log_path.read_text(encoding="utf-8").splitlines()
+
+
 class Pane:
     def __init__(self, session: Session, name: str) -> None:
         self.session = session
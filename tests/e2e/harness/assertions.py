@@ -3,6 +3,7 @@ from __future__ import annotations
 import re
 
 from .agent_tui import Snapshot
+from .styled import color
 
 
 def expect(condition: bool, message: str, snapshot: Snapshot | str | None = None) -> None:
@@ -46,5 +47,25 @@ def expect_ansi_colored_word(text: str, color_code: int, word: str) -> None:
     )
 
 
+def expect_styled(
+    snapshot: Snapshot,
+    needle: str,
+    *,
+    fg: str | int | None = None,
+    bg: str | int | None = None,
+    bold: bool | None = None,
+) -> None:
+    """Checks the cells of `needle` in a `retain_ansi` snapshot. Colors take
+    proctmux style names or palette indexes; `None` leaves a field unchecked."""
+    style = snapshot.styled.style_of(needle)
+    expect(style is not None, f"expected {needle!r} to be rendered in a single style", snapshot)
+    if fg is not None:
+        expect(style.fg == color(fg), f"expected {needle!r} foreground {fg!r}, got {style.fg!r}", snapshot)
+    if bg is not None:
+        expect(style.bg == color(bg), f"expected {needle!r} background {bg!r}, got {style.bg!r}", snapshot)
+    if bold is not None:
+        expect(style.bold == bold, f"expected {needle!r} bold={bold}, got bold={style.bold}", snapshot)
+
+
 def is_mostly_blank(text: str) -> bool:
     return sum(1 for ch in text if not ch.isspace()) < 8
@@ -1,6 +1,8 @@
 from __future__ import annotations
 
-from .agent_tui import AgentTuiRunner, Session
+from typing import Optional
+
+from .agent_tui import AgentTuiRunner, PrimaryClientSession, Session
 
 
 class ProctmuxApp:
@@ -55,5 +57,13 @@ class ProctmuxApp:
         cols: int = 120,
         rows: int = 40,
         no_color: bool = True,
-    ) -> Session:
-        return self.runner.start_primary_client(name, config, cols=cols, rows=rows, no_color=no_color)
+        extra_env: Optional[dict[str, str]] = None,
+    ) -> PrimaryClientSession:
+        return self.runner.start_primary_client(
+            name,
+            config,
+            cols=cols,
+            rows=rows,
+            no_color=no_color,
+            extra_env=extra_env,
+        )
@@ -0,0 +1,165 @@
+"""Styled-cell view of agent-tui screenshots.
+
+agent-tui emulates the terminal, so cursor movement, scroll regions, and the
+alternate screen are already resolved; a `--retain-ansi` screenshot is the
+final screen with SGR codes left in. This module folds those codes into a
+style per cell, so tests can assert colors on text instead of matching raw
+escape sequences.
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass, replace
+from typing import Optional, Union
+
+Color = Union[int, tuple[int, int, int]]
+
+SGR_PATTERN = re.compile(r"\x1b\[([0-9;:]*)m")
+OTHER_ESCAPE_PATTERN = re.compile(r"\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])")
+
+NAMED_COLORS = {
+    "black": 0,
+    "red": 1,
+    "green": 2,
+    "yellow": 3,
+    "blue": 4,
+    "magenta": 5,
+    "cyan": 6,
+    "white": 7,
+    "brightblack": 8,
+    "gray": 8,
+    "grey": 8,
+    "brightred": 9,
+    "lightred": 9,
+    "brightgreen": 10,
+    "lightgreen": 10,
+    "brightyellow": 11,
+    "brightblue": 12,
+    "brightmagenta": 13,
+    "brightcyan": 14,
+    "brightwhite": 15,
+}
+
+
+@dataclass(frozen=True)
+class Style:
+    fg: Optional[Color] = None
+    bg: Optional[Color] = None
+    bold: bool = False
+    reverse: bool = False
+
+
+@dataclass(frozen=True)
+class Cell:
+    char: str
+    style: Style
+
+
+class StyledScreen:
+    """Cells of a `--retain-ansi` screenshot, each with the SGR style it was drawn in.
+
+    Colors are normalized to palette indexes or RGB tuples, so `31`, `38;5;1`,
+    and the name `red` all compare equal regardless of how the terminal
+    emulator re-encoded them.
+    """
+
+    def __init__(self, text: str) -> None:
+        self.rows = [parse_row(line) for line in text.splitlines()]
+
+    def row_text(self, index: int) -> str:
+        return "".join(cell.char for cell in self.rows[index])
+
+    def find(self, needle: str) -> Optional[list[Cell]]:
+        for row in self.rows:
+            text = "".join(cell.char for cell in row)
+            idx = text.find(needle)
+            if idx >= 0:
+                return row[idx : idx + len(needle)]
+        return None
+
+    def style_of(self, needle: str) -> Optional[Style]:
+        """Style shared by every cell of the first occurrence of `needle`, or
+        None when it is missing or drawn in more than one style."""
+        cells = self.find(needle)
+        if not cells:
+            return None
+        styles = {cell.style for cell in cells}
+        if len(styles) != 1:
+            return None
+        return cells[0].style
+
+
+def parse_row(line: str) -> list[Cell]:
+    cells: list[Cell] = []
+    style = Style()
+    pos = 0
+    while pos < len(line):
+        sgr = SGR_PATTERN.match(line, pos)
+        if sgr is not None:
+            style = apply_sgr(style, sgr.group(1))
+            pos = sgr.end()
+            continue
+        other = OTHER_ESCAPE_PATTERN.match(line, pos)
+        if other is not None:
+            pos = other.end()
+            continue
+        cells.append(Cell(line[pos], style))
+        pos += 1
+    return cells
+
+
+def apply_sgr(style: Style, params: str) -> Style:
+    codes = [int(part) if part else 0 for part in re.split(r"[;:]", params)] if params else [0]
+    i = 0
+    while i < len(codes):
+        code = codes[i]
+        if code == 0:
+            style = Style()
+        elif code == 1:
+            style = replace(style, bold=True)
+        elif code == 22:
+            style = replace(style, bold=False)
+        elif code == 7:
+            style = replace(style, reverse=True)
+        elif code == 27:
+            style = replace(style, reverse=False)
+        elif 30 <= code <= 37:
+            style = replace(style, fg=code - 30)
+        elif 90 <= code <= 97:
+            style = replace(style, fg=code - 90 + 8)
+        elif code == 39:
+            style = replace(style, fg=None)
+        elif 40 <= code <= 47:
+            style = replace(style, bg=code - 40)
+        elif 100 <= code <= 107:
+            style = replace(style, bg=code - 100 + 8)
+        elif code == 49:
+            style = replace(style, bg=None)
+        elif code in (38, 48):
+            color, consumed = extended_color(codes[i + 1 :])
+            style = replace(style, fg=color) if code == 38 else replace(style, bg=color)
+            i += consumed
+        i += 1
+    return style
+
+
+def extended_color(rest: list[int]) -> tuple[Optional[Color], int]:
+    if len(rest) >= 2 and rest[0] == 5:
+        return rest[1], 2
+    if len(rest) >= 4 and rest[0] == 2:
+        return (rest[1], rest[2], rest[3]), 4
+    return None, len(rest)
+
+
+def color(value: Union[str, int, tuple[int, int, int], None]) -> Optional[Color]:
+    """Normalizes a proctmux style color (name, palette index, or `#rrggbb`)
+    to the form `Style` uses."""
+    if value is None or isinstance(value, (int, tuple)):
+        return value
+    name = value.strip().lower().removeprefix("ansi")
+    if name in NAMED_COLORS:
+        return NAMED_COLORS[name]
+    if name.startswith("#") and len(name) == 7:
+        return tuple(int(name[i : i + 2], 16) for i in (1, 3, 5))
+    return int(name)
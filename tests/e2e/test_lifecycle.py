@@ -180,6 +180,40 @@ def test_client_process_exit_updates_status_without_input(app: ProctmuxApp) -> N
         )
 
 
+@pytest.mark.go_name("TestPrimaryClientStartProcess")
+def test_primary_client_start_process(app: ProctmuxApp) -> None:
+    tmux = app.runner.tmux_stub()
+    session = app.primary_client(
+        "lifecycle-primary-client-start",
+        """
+        layout:
+          placeholder_banner: "NO PROCESS"
+        log_file: proctmux.log
+        procs:
+          client-started:
+            shell: "printf 'CLIENT_STARTED_OUTPUT\\n'; sleep 60"
+            autostart: false
+        """,
+        extra_env=tmux.env(),
+    )
+    with session as tui:
+        listed = tui.wait_until("client process list", lambda snap: "client-started" in snap.text)
+        expect("■ client-started" in listed.text, f"process was not shown stopped before start:\n{listed.text}")
+        session.primary.wait_for_text("NO PROCESS")
+
+        tui.type("s")
+        started = tui.wait_until("client shows the process running", lambda snap: "● client-started" in snap.text)
+        expect("■ client-started" not in started.text, f"process still shown stopped after start:\n{started.text}")
+        expect("client-started\trunning" in tui.signal("signal-list").stdout, "client start did not reach the primary")
+
+        relayed = session.primary.wait_for_text("CLIENT_STARTED_OUTPUT")
+        expect("NO PROCESS" not in relayed.text, f"primary kept the placeholder after start:\n{relayed.text}")
+        logs = tui.signal("logs", "client-started")
+        expect("CLIENT_STARTED_OUTPUT" in logs.stdout, f"output was not recorded for the process:\n{logs.stdout}")
+
+    expect(not tmux.invocations(), f"proctmux shelled out to tmux: {tmux.invocations()!r}")
+
+
 @pytest.mark.go_name("TestUnified_StopSelectedWithOnKillTerminatesProcessGroup")
 def test_stop_selected_with_on_kill_terminates_process_group(app: ProctmuxApp) -> None:
     events_path, run_count_path, child_pid_path = lifecycle_paths(app, "stop-default")
@@ -1,13 +0,0 @@
-from __future__ import annotations
-
-import pytest
-
-
-@pytest.mark.skip(reason="unified e2e pending deterministic TUI synchronization")
-def test_unified_error_message_expires() -> None:
-    pass
-
-
-@pytest.mark.skip(reason="primary/client e2e pending tmux stub implementation")
-def test_primary_client_start_process() -> None:
-    pass
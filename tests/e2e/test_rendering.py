@@ -23,6 +23,7 @@ from harness.assertions import (
     expect_ansi_colored_word,
     expect_contains,
     expect_not_contains,
+    expect_styled,
     is_mostly_blank,
 )
 
@@ -566,3 +567,65 @@ def test_unified_output_restores_main_screen_after_alternate_screen(app: Proctmu
         snap = tui.wait_for_text("after-alt")
         expect_contains(snap, "main-screen")
         expect_not_contains(snap, "alt-screen", "alternate-screen contents remained visible after returning to main screen")
+
+
+@pytest.mark.go_name("TestUnified_SelectedProcessLabelUsesSelectedColors")
+def test_unified_selected_process_label_uses_selected_colors(app: ProctmuxApp) -> None:
+    with app.unified(
+        "selected-colors",
+        """
+        log_file: proctmux.log
+        style:
+          selected_process_color: white
+          selected_process_bg_color: magenta
+        procs:
+          alpha-styled:
+            shell: "sleep 60"
+          beta-styled:
+            shell: "sleep 60"
+        """,
+        no_color=False,
+    ) as tui:
+        snap = tui.wait_until(
+            "selected label drawn on the selected background",
+            lambda s: (style := s.styled.style_of("alpha-styled")) is not None and style.bg is not None,
+            retain_ansi=True,
+        )
+        expect_styled(snap, "alpha-styled", fg="white", bg="magenta")
+        expect(snap.styled.style_of("beta-styled").bg is None, "unselected label has a background", snap)
+
+        tui.type("j")
+        snap = tui.wait_until(
+            "selection background moved to the next label",
+            lambda s: (style := s.styled.style_of("beta-styled")) is not None and style.bg is not None,
+            retain_ansi=True,
+        )
+        expect_styled(snap, "beta-styled", fg="white", bg="magenta")
+        expect(snap.styled.style_of("alpha-styled").bg is None, "previously selected label kept its background", snap)
+
+
+@pytest.mark.go_name("TestUnifiedErrorMessageExpires")
+def test_unified_error_message_expires(app: ProctmuxApp) -> None:
+    message = "process is not running"
+    with app.unified(
+        "message-expires",
+        """
+        log_file: proctmux.log
+        procs:
+          idle-target:
+            shell: "sleep 60"
+        """,
+    ) as tui:
+        tui.wait_for_text("idle-target")
+        before = tui.last_frame()
+        tui.type("P")
+        shown = tui.wait_for_frame("frame showing the message", lambda frame: frame.messages == 1, after=before)
+        expect_contains(tui.snapshot(), message)
+
+        tui.wait_for_frame(
+            "frame drawn once the message expires",
+            lambda frame: frame.messages == 0,
+            after=shown.sequence,
+            timeout=8.0,
+        )
+        expect_not_contains(tui.snapshot(), message, "expired message stayed on screen")